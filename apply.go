@@ -0,0 +1,153 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"context"
+	"net/url"
+	"sort"
+)
+
+// ChangeKind represents how syncing a store with a mapping set changes the
+// mapping of an obscured URL.
+type ChangeKind int
+
+const (
+	// ChangeAdded represents a mapping placed for an obscured URL that
+	// doesn't currently resolve.
+	ChangeAdded ChangeKind = iota
+	// ChangeChanged represents a currently resolving obscured URL whose
+	// original form is replaced.
+	ChangeChanged
+	// ChangeRemoved represents a currently resolving obscured URL whose
+	// mapping is removed.
+	ChangeRemoved
+)
+
+// String provides the string representation of the kind of change.
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeChanged:
+		return "changed"
+	case ChangeRemoved:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// Change represents the change to the mapping of an obscured URL.
+type Change struct {
+	// Kind is how the mapping changes.
+	Kind ChangeKind
+	// Obscured is the obscured URL whose mapping changes.
+	Obscured *url.URL
+	// Before is the original URL the obscured URL currently resolves to,
+	// which is nil when the mapping is added.
+	Before *url.URL
+	// After is the original URL the obscured URL resolves to once synced,
+	// which is nil when the mapping is removed.
+	After *url.URL
+}
+
+// Breaking indicates whether the change breaks a currently resolving
+// obscured URL, as it is either removed or resolves elsewhere.
+func (c Change) Breaking() bool {
+	return c.Kind != ChangeAdded
+}
+
+// Impact represents the changes of syncing a store with a mapping set,
+// ordered by obscured URL.
+type Impact []Change
+
+// Breaking provides the changes breaking currently resolving obscured URLs.
+func (i Impact) Breaking() Impact {
+	var breaking Impact
+	for _, c := range i {
+		if c.Breaking() {
+			breaking = append(breaking, c)
+		}
+	}
+	return breaking
+}
+
+// Plan computes the impact of syncing the provided store with the provided
+// mappings, such that it holds exactly them, without changing the store.
+// The keys of the mappings are compared with the keys their key strategy
+// derives from the obscured URLs in the store, which should match the key
+// strategy of the store.
+func Plan(ctx context.Context, s RangeStore, m Mappings) (Impact, error) {
+	var impact Impact
+	seen := make(map[string]bool, m.Len())
+	err := s.Range(ctx, func(obscured, original *url.URL) bool {
+		key := m.KeyStrategy.Key(obscured)
+		seen[key] = true
+		after, ok := m.Entries[key]
+		switch {
+		case !ok:
+			impact = append(impact, Change{Kind: ChangeRemoved, Obscured: obscured, Before: original})
+		case after.String() != original.String():
+			impact = append(impact, Change{Kind: ChangeChanged, Obscured: obscured, Before: original, After: after})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	for key, after := range m.Entries {
+		if seen[key] {
+			continue
+		}
+		impact = append(impact, Change{Kind: ChangeAdded, Obscured: m.KeyStrategy.URL(key), After: after})
+	}
+	sort.Slice(impact, func(i, j int) bool {
+		return impact[i].Obscured.String() < impact[j].Obscured.String()
+	})
+	return impact, nil
+}
+
+// Apply syncs the provided store with the provided mappings, such that it
+// holds exactly them, providing the impact of doing so as Plan does.
+// Mappings are removed before the mappings replacing them are placed. When
+// syncing fails part way, the changes made so far are kept.
+func Apply(ctx context.Context, s RangeStore, m Mappings) (Impact, error) {
+	impact, err := Plan(ctx, s, m)
+	if err != nil {
+		return nil, err
+	}
+	var removed []*url.URL
+	placed := make(map[*url.URL]*url.URL)
+	for _, c := range impact {
+		if c.Before != nil {
+			removed = append(removed, c.Obscured)
+		}
+		if c.After != nil {
+			placed[c.Obscured] = c.After
+		}
+	}
+	if len(removed) > 0 {
+		if err := RemoveAll(ctx, s, removed); err != nil {
+			return nil, err
+		}
+	}
+	if len(placed) > 0 {
+		if err := PutAll(ctx, s, placed); err != nil {
+			return nil, err
+		}
+	}
+	return impact, nil
+}
@@ -0,0 +1,106 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seeded provides a memory store holding mappings for '/abc', '/def', and
+// '/ghi'.
+func seeded(t *testing.T) obscurer.RangeStore {
+	ctx := context.Background()
+	s := obscurer.NewMemoryStore().(obscurer.RangeStore)
+	require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+	require.NoError(t, s.Put(ctx, mustParse("/def"), mustParse("/hey/der")))
+	require.NoError(t, s.Put(ctx, mustParse("/ghi"), mustParse("/products/1")))
+	return s
+}
+
+// mappingSet provides the mapping set keeping '/abc', changing '/def',
+// dropping '/ghi', and adding '/jkl'.
+func mappingSet() obscurer.Mappings {
+	return obscurer.Mappings{Entries: map[string]*url.URL{
+		"/abc": mustParse("/this/is/the/way"),
+		"/def": mustParse("/hey/there"),
+		"/jkl": mustParse("/products/2"),
+	}}
+}
+
+// TestPlan tests that the changes of syncing a store are reported, in
+// order, without changing the store.
+func TestPlan(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := seeded(t)
+
+	// action.
+	impact, err := obscurer.Plan(ctx, s, mappingSet())
+
+	// assert.
+	require.NoError(t, err)
+	require.Len(t, impact, 3)
+	assert.Equal(t, obscurer.ChangeChanged, impact[0].Kind)
+	assert.Equal(t, "/def", impact[0].Obscured.String())
+	assert.Equal(t, "/hey/der", impact[0].Before.String())
+	assert.Equal(t, "/hey/there", impact[0].After.String())
+	assert.Equal(t, obscurer.ChangeRemoved, impact[1].Kind)
+	assert.Equal(t, "/ghi", impact[1].Obscured.String())
+	assert.Nil(t, impact[1].After)
+	assert.Equal(t, obscurer.ChangeAdded, impact[2].Kind)
+	assert.Equal(t, "/jkl", impact[2].Obscured.String())
+	assert.Nil(t, impact[2].Before)
+	assert.Equal(t, impact[:2], impact.Breaking())
+	assert.Equal(t, 3, s.Size(ctx))
+	original, ok, err := s.Get(ctx, mustParse("/def"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/hey/der", original.String())
+}
+
+// TestApply tests that syncing a store leaves it holding exactly the
+// mapping set.
+func TestApply(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := seeded(t)
+
+	// action.
+	impact, err := obscurer.Apply(ctx, s, mappingSet())
+
+	// assert.
+	require.NoError(t, err)
+	assert.Len(t, impact, 3)
+	assert.Equal(t, 3, s.Size(ctx))
+	for key, want := range mappingSet().Entries {
+		original, ok, err := s.Get(ctx, mustParse(key))
+		require.NoError(t, err)
+		require.True(t, ok, key)
+		assert.Equal(t, want.String(), original.String())
+	}
+	_, ok, err := s.Get(ctx, mustParse("/ghi"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	impact, err = obscurer.Plan(ctx, s, mappingSet())
+	require.NoError(t, err)
+	assert.Empty(t, impact)
+}
@@ -0,0 +1,142 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"context"
+	"encoding/hex"
+	"net/url"
+	"strings"
+)
+
+// BatchObscurer represents an obscurer capable of obscuring many URLs at
+// once.
+type BatchObscurer interface {
+	Obscurer
+
+	// ObscureAll obscures the provided URLs. The resulting map is keyed by
+	// the obscured URLs, with the values being their corresponding
+	// originals, such that it can be provided directly to Store.Load.
+	ObscureAll(context.Context, []*url.URL) (map[*url.URL]*url.URL, error)
+}
+
+// ObscureAll obscures the provided URLs with the provided obscurer, using
+// the batch capabilities of the obscurer when it has them. The resulting
+// map is keyed by the obscured URLs, with the values being their
+// corresponding originals.
+func ObscureAll(ctx context.Context, o Obscurer, urls []*url.URL) (map[*url.URL]*url.URL, error) {
+	if batch, ok := o.(BatchObscurer); ok {
+		return batch.ObscureAll(ctx, urls)
+	}
+	return obscureAll(ctx, o, urls)
+}
+
+// obscureAll obscures each of the provided URLs in turn, stopping early
+// when the provided context is done.
+func obscureAll(ctx context.Context, o Obscurer, urls []*url.URL) (map[*url.URL]*url.URL, error) {
+	mappings := make(map[*url.URL]*url.URL, len(urls))
+	for _, u := range urls {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		mappings[o.Obscure(u)] = u
+	}
+	return mappings, nil
+}
+
+// obscureDigests obscures each of the provided URLs in turn as the hashing
+// obscurers do, replacing the path of the normalized URL with its digest,
+// as computed by the provided function. The buffers holding the path and
+// its digest are reused across the URLs, as is the hasher of the function,
+// stopping early when the provided context is done.
+func obscureDigests(ctx context.Context, options ObscurerOptions, urls []*url.URL, digest func(dst, message []byte) []byte) (map[*url.URL]*url.URL, error) {
+	mappings := make(map[*url.URL]*url.URL, len(urls))
+	var message, sum []byte
+	for _, u := range urls {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result := options.normalize(u)
+		message = append(message[:0], strings.TrimLeft(result.Path, "/")...)
+		sum = digest(sum[:0], message)
+		result.Path = "/" + hex.EncodeToString(sum)
+		result.RawPath = ""
+		mappings[&result] = u
+	}
+	return mappings, nil
+}
+
+// BatchStore represents a store capable of placing and removing many
+// mappings at once, such as by pipelining the writes to a remote store.
+type BatchStore interface {
+	Store
+
+	// PutAll places the provided mappings into the store, where the keys
+	// are obscured URLs and the values are their corresponding originals.
+	// Mappings are placed with the semantics of Put, and the first error
+	// encountered is returned, which may leave some mappings placed.
+	PutAll(context.Context, map[*url.URL]*url.URL) error
+	// RemoveAll deletes the entries in the store for the provided obscured
+	// URLs.
+	RemoveAll(context.Context, []*url.URL) error
+}
+
+// PutAll places the provided mappings into the provided store, using the
+// batch capabilities of the store when it has them. The first error
+// encountered is returned, which may leave some mappings placed.
+func PutAll(ctx context.Context, s Store, mappings map[*url.URL]*url.URL) error {
+	if batch, ok := s.(BatchStore); ok {
+		return batch.PutAll(ctx, mappings)
+	}
+	return putAll(ctx, s, mappings)
+}
+
+// putAll places each of the provided mappings in turn, stopping early when
+// the provided context is done.
+func putAll(ctx context.Context, s Store, mappings map[*url.URL]*url.URL) error {
+	for obscured, original := range mappings {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.Put(ctx, obscured, original); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveAll deletes the entries in the provided store for the provided
+// obscured URLs, using the batch capabilities of the store when it has them.
+func RemoveAll(ctx context.Context, s Store, urls []*url.URL) error {
+	if batch, ok := s.(BatchStore); ok {
+		return batch.RemoveAll(ctx, urls)
+	}
+	return removeAll(ctx, s, urls)
+}
+
+// removeAll deletes each of the entries for the provided obscured URLs in
+// turn, stopping early when the provided context is done.
+func removeAll(ctx context.Context, s Store, urls []*url.URL) error {
+	for _, u := range urls {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.Remove(ctx, u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
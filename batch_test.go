@@ -0,0 +1,197 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/internal/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// obscurerFunc adapts a function into an obscurer without batch support.
+type obscurerFunc func(*url.URL) *url.URL
+
+func (f obscurerFunc) Obscure(u *url.URL) *url.URL { return f(u) }
+
+// TestObscureAll tests that all of the provided URLs are obscured, and that
+// the result can be loaded into the store.
+func TestObscureAll(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	store := obscurer.DefaultStore
+	urls := []*url.URL{
+		mustParse("http://www.example.com/products?page=1"),
+		mustParse("http://www.example.com/products?page=2"),
+		mustParse("http://www.example.com/products/1"),
+	}
+
+	// action.
+	mappings, err := obscurer.ObscureAll(ctx, obscurer.Default, urls)
+
+	// assert.
+	require.NoError(t, err)
+	assert.Len(t, mappings, len(urls))
+	for obscured, original := range mappings {
+		assert.Equal(t, obscurer.Default.Obscure(original).String(), obscured.String())
+	}
+	require.NoError(t, obscurer.LoadAll(ctx, store, mappings))
+	assert.Equal(t, 2, store.Size(ctx), "expected URLs sharing a path to share a mapping")
+
+	// cleanup.
+	t.Cleanup(func() {
+		store.Clear(ctx)
+	})
+}
+
+// TestObscureAll_Obscurers tests that the obscurers obscuring URLs in
+// batches obscure them as they do one at a time.
+func TestObscureAll_Obscurers(t *testing.T) {
+	normalize := obscurer.WithNormalizer(obscurer.DefaultNormalizer)
+	obscurers := map[string]obscurer.Obscurer{
+		"MD5":     obscurer.NewMD5(normalize),
+		"SHA256":  obscurer.NewSHA256(normalize),
+		"HMAC":    obscurer.NewSHA256(normalize, obscurer.WithKey([]byte("secret"))),
+		"SipHash": obscurer.NewSipHash(sipHashKey, normalize),
+	}
+	urls := []*url.URL{
+		mustParse("http://WWW.EXAMPLE.COM/products/"),
+		mustParse("/this/is/the/way"),
+		mustParse("/hey/der?page=2"),
+	}
+	for name, o := range obscurers {
+		t.Run(name, func(t *testing.T) {
+			// action.
+			mappings, err := obscurer.ObscureAll(context.Background(), o, urls)
+
+			// assert.
+			require.NoError(t, err)
+			assert.Len(t, mappings, len(urls))
+			for obscured, original := range mappings {
+				assert.Equal(t, o.Obscure(original).String(), obscured.String())
+			}
+		})
+	}
+}
+
+// TestObscureAll_NotBatchObscurer tests that obscurers without batch
+// support are still able to obscure all of the provided URLs.
+func TestObscureAll_NotBatchObscurer(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	o := obscurerFunc(func(u *url.URL) *url.URL {
+		result := *u
+		result.Path = "/obscured" + u.Path
+		return &result
+	})
+	urls := []*url.URL{mustParse("/this/is/the/way"), mustParse("/hey/der")}
+
+	// action.
+	mappings, err := obscurer.ObscureAll(ctx, o, urls)
+
+	// assert.
+	require.NoError(t, err)
+	assert.Len(t, mappings, len(urls))
+	for obscured, original := range mappings {
+		assert.Equal(t, "/obscured"+original.Path, obscured.Path)
+	}
+}
+
+// TestObscureAll_Cancelled tests that obscuring stops when the provided
+// context is cancelled.
+func TestObscureAll_Cancelled(t *testing.T) {
+	// arrange.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// action.
+	mappings, err := obscurer.ObscureAll(ctx, obscurer.Default, []*url.URL{mustParse("/hey/der")})
+
+	// assert.
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, mappings)
+}
+
+// TestPutAll tests that many mappings can be placed into and removed from
+// the store at once.
+func TestPutAll(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	store := obscurer.DefaultStore
+	a, b := mustParse("/a"), mustParse("/b")
+
+	// action.
+	err := obscurer.PutAll(ctx, store, map[*url.URL]*url.URL{
+		a: mustParse("/this/is/the/way"),
+		b: mustParse("/hey/der"),
+	})
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 2, store.Size(ctx))
+	require.NoError(t, obscurer.RemoveAll(ctx, store, []*url.URL{a, b}))
+	assert.Equal(t, 0, store.Size(ctx))
+
+	// cleanup.
+	t.Cleanup(func() {
+		store.Clear(ctx)
+	})
+}
+
+// TestPutAll_NotBatchStore tests that stores without batch support have
+// each of the mappings placed and removed in turn.
+func TestPutAll_NotBatchStore(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	store := mock.NewStore(ctrl)
+	obscured, original := mustParse("/a"), mustParse("/this/is/the/way")
+	store.EXPECT().Put(ctx, obscured, original).Return(nil)
+	store.EXPECT().Remove(ctx, obscured).Return(nil)
+
+	// action + assert.
+	assert.NoError(t, obscurer.PutAll(ctx, store, map[*url.URL]*url.URL{obscured: original}))
+	assert.NoError(t, obscurer.RemoveAll(ctx, store, []*url.URL{obscured}))
+}
+
+// TestPutAll_Error tests that the first error encountered is returned.
+func TestPutAll_Error(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	store := mock.NewStore(ctrl)
+	expectedErr := errors.New("whoa")
+	store.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(expectedErr)
+	store.EXPECT().Remove(gomock.Any(), gomock.Any()).Return(expectedErr)
+
+	// action.
+	putErr := obscurer.PutAll(ctx, store, map[*url.URL]*url.URL{
+		mustParse("/a"): mustParse("/this/is/the/way"),
+	})
+	removeErr := obscurer.RemoveAll(ctx, store, []*url.URL{mustParse("/a"), mustParse("/b")})
+
+	// assert.
+	assert.Equal(t, expectedErr, putErr)
+	assert.Equal(t, expectedErr, removeErr)
+}
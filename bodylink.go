@@ -0,0 +1,145 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// ErrBodyFailure represents an error that occurs when obscuring the URLs
+// within the body of a response.
+var ErrBodyFailure = errors.New("obscurer: unable to obscure URLs within response body")
+
+// WithLinkFields configures the handler to obscure the URLs held by the
+// members of JSON response bodies with the provided names, such as 'href',
+// including those held by arrays.
+func WithLinkFields(fields ...string) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.LinkFields = append(o.LinkFields, fields...)
+	}
+}
+
+// WithLinkPatterns configures the handler to obscure the URLs held by any
+// member of JSON response bodies whose path matches any of the provided
+// patterns, such as Glob("/users/*").
+func WithLinkPatterns(patterns ...PathPattern) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.LinkPatterns = append(o.LinkPatterns, patterns...)
+	}
+}
+
+// WithHypermedia configures the handler to obscure the URLs of the links
+// of JSON response bodies in the HAL and JSON:API formats, which are the
+// 'href' members of the link objects of HAL '_links' objects, and the
+// members of JSON:API 'links' objects, including those of relationships,
+// or their 'href' members. Templated HAL links are left as is.
+func WithHypermedia() HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Hypermedia = true
+	}
+}
+
+// bodyLinks selects the URLs of JSON documents that are obscured.
+type bodyLinks struct {
+	fields     map[string]bool
+	patterns   []PathPattern
+	hypermedia bool
+}
+
+// newBodyLinks constructs the selection of the URLs of JSON documents
+// configured by the provided options, which is nil when none are.
+func newBodyLinks(o HandlerOptions) *bodyLinks {
+	if len(o.LinkFields) == 0 && len(o.LinkPatterns) == 0 && !o.Hypermedia {
+		return nil
+	}
+	links := &bodyLinks{fields: make(map[string]bool), patterns: o.LinkPatterns, hypermedia: o.Hypermedia}
+	for _, field := range o.LinkFields {
+		links.fields[field] = true
+	}
+	return links
+}
+
+// link provides the URL held by the provided value of the member with the
+// provided path, if it is to be obscured.
+func (l *bodyLinks) link(path []string, value interface{}) (*url.URL, bool) {
+	s, ok := value.(string)
+	if !ok || s == "" {
+		return nil, false
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, false
+	}
+	if l.fields[path[len(path)-1]] || (l.hypermedia && hypermediaLink(path, s)) {
+		return u, true
+	}
+	return u, strings.HasPrefix(u.Path, "/") && matchAny(l.patterns, u.Path)
+}
+
+// hypermediaLink indicates whether the provided value of the member with
+// the provided path is the URL of a HAL or JSON:API link.
+func hypermediaLink(path []string, value string) bool {
+	for i := len(path) - 1; i >= 0; i-- {
+		tail := path[i+1:]
+		switch path[i] {
+		case "_links":
+			// HAL: "_links": {"self": {"href": "/orders/42"}}
+			return len(tail) == 2 && tail[1] == "href" && !strings.Contains(value, "{")
+		case "links":
+			// JSON:API: "links": {"self": "/orders/42"}, or
+			// "links": {"related": {"href": "/orders/42/items"}}
+			return len(tail) == 1 || (len(tail) == 2 && tail[1] == "href")
+		}
+	}
+	return false
+}
+
+// rewriteBody encodes the identifiers and obscures the URLs within the
+// provided JSON document, as configured. Nil is provided for documents that
+// aren't valid JSON, which are left as is.
+func (h *handler) rewriteBody(ctx context.Context, body []byte, ids, links bool) ([]byte, error) {
+	var failure error
+	rewritten, err := rewriteJSONPath(body, func(path []string, value interface{}) (interface{}, bool) {
+		if ids {
+			if encoded, ok := h.ids.encodeValue(path[len(path)-1], value); ok {
+				return encoded, true
+			}
+		}
+		if !links || failure != nil {
+			return nil, false
+		}
+		u, ok := h.links.link(path, value)
+		if !ok {
+			return nil, false
+		}
+		obscured, err := h.obscure(ctx, u)
+		if err != nil {
+			failure = err
+		}
+		if obscured == nil || err != nil {
+			return nil, false
+		}
+		return obscured.String(), true
+	})
+	if err != nil {
+		h.logger().Warnf("obscurer: left the response body as is, as it isn't valid JSON: %v", err)
+		return nil, nil
+	}
+	return rewritten, failure
+}
@@ -0,0 +1,241 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/internal/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHandler_LinkFields tests that the URLs held by the configured members
+// of JSON response bodies are obscured, fixing the 'Content-Length' header.
+func TestHandler_LinkFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []obscurer.HandlerOption
+		expected func(obscure func(string) string) string
+	}{
+		{
+			name: "Fields",
+			opts: []obscurer.HandlerOption{obscurer.WithLinkFields("href", "related")},
+			expected: func(obscure func(string) string) string {
+				return `{"href":"` + obscure("/users/42") + `","name":"/users/7","related":["` + obscure("/users/1") + `"],"count":2}`
+			},
+		},
+		{
+			name: "Patterns",
+			opts: []obscurer.HandlerOption{obscurer.WithLinkPatterns(obscurer.Glob("/users/*"))},
+			expected: func(obscure func(string) string) string {
+				return `{"href":"` + obscure("/users/42") + `","name":"` + obscure("/users/7") + `","related":["` + obscure("/users/1") + `"],"count":2}`
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			ctx := context.Background()
+			store := obscurer.NewMemoryStore()
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Length", "1")
+				w.Write([]byte(`{"href":"/users/42","name":"/users/7","related":["/users/1"],"count":2}`))
+			})
+			handler := obscurer.NewHandler(obscurer.Default, store, h, test.opts...)
+			response := httptest.NewRecorder()
+
+			// action.
+			handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/users", nil))
+
+			// assert.
+			obscure := func(path string) string {
+				return obscurer.Default.Obscure(mustParse(path)).String()
+			}
+			expected := test.expected(obscure)
+			assert.Equal(t, expected, response.Body.String())
+			assert.Equal(t, strconv.Itoa(len(expected)), response.Header().Get("Content-Length"))
+			original, ok, err := store.Get(ctx, mustParse(obscure("/users/42")))
+			require.NoError(t, err)
+			require.True(t, ok)
+			assert.Equal(t, "/users/42", original.String())
+		})
+	}
+}
+
+// TestHandler_MaxBufferedBody tests that response bodies exceeding the
+// maximum buffered body are passed through as is, rather than rewritten.
+func TestHandler_MaxBufferedBody(t *testing.T) {
+	body := `{"href":"/users/42","count":2}`
+	tests := []struct {
+		name      string
+		max       int
+		rewritten bool
+	}{
+		{name: "Unlimited", rewritten: true},
+		{name: "Within", max: len(body), rewritten: true},
+		{name: "Exceeded", max: len(body) - 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(body[:10]))
+				w.Write([]byte(body[10:]))
+			})
+			handler := obscurer.NewHandler(
+				obscurer.Default,
+				obscurer.NewMemoryStore(),
+				h,
+				obscurer.WithLinkFields("href"),
+				obscurer.WithMaxBufferedBody(test.max),
+			)
+			response := httptest.NewRecorder()
+
+			// action.
+			handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/users", nil))
+
+			// assert.
+			assert.Equal(t, http.StatusCreated, response.Code)
+			expected := body
+			if test.rewritten {
+				expected = `{"href":"` + obscurer.Default.Obscure(mustParse("/users/42")).String() + `","count":2}`
+			}
+			assert.Equal(t, expected, response.Body.String())
+		})
+	}
+}
+
+// TestHandler_ContentEncoding tests that encoded bodies are passed through
+// as is, rather than buffered to be rewritten.
+func TestHandler_ContentEncoding(t *testing.T) {
+	body := `{"href":"/users/42"}`
+	tests := []struct {
+		name      string
+		encoding  string
+		rewritten bool
+	}{
+		{name: "None", rewritten: true},
+		{name: "Identity", encoding: "identity", rewritten: true},
+		{name: "Gzip", encoding: "gzip"},
+		{name: "Multiple", encoding: "identity, br"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if test.encoding != "" {
+					w.Header().Set("Content-Encoding", test.encoding)
+				}
+				w.Write([]byte(body))
+			})
+			handler := obscurer.NewHandler(
+				obscurer.Default, obscurer.NewMemoryStore(), h, obscurer.WithLinkFields("href"))
+			response := httptest.NewRecorder()
+
+			// action.
+			handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/users", nil))
+
+			// assert.
+			expected := body
+			if test.rewritten {
+				expected = `{"href":"` + obscurer.Default.Obscure(mustParse("/users/42")).String() + `"}`
+			}
+			assert.Equal(t, expected, response.Body.String())
+		})
+	}
+}
+
+// TestHandler_LinkFields_Failure tests that the response is replaced with
+// an error when the URLs within its body fail to be obscured.
+func TestHandler_LinkFields_Failure(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mock.NewStore(ctrl)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"href":"/users/42"}`))
+	})
+	handler := obscurer.NewHandler(obscurer.Default, store, h, obscurer.WithLinkFields("href"))
+	store.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, false, nil)
+	store.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("whoa"))
+	response := httptest.NewRecorder()
+
+	// action.
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/users", nil))
+
+	// assert.
+	assert.Equal(t, http.StatusInternalServerError, response.Code)
+	assert.Equal(t, obscurer.ErrBodyFailure.Error()+"\n", response.Body.String())
+}
+
+// TestHandler_Hypermedia tests that the URLs of the links of HAL and
+// JSON:API response bodies are obscured.
+func TestHandler_Hypermedia(t *testing.T) {
+	obscure := func(path string) string {
+		return obscurer.Default.Obscure(mustParse(path)).String()
+	}
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name:     "HAL",
+			body:     `{"_links":{"self":{"href":"/orders/42"},"items":[{"href":"/items/1","title":"/items/1"}],"find":{"href":"/orders{?id}","templated":true}},"href":"/orders/7"}`,
+			expected: `{"_links":{"self":{"href":"` + obscure("/orders/42") + `"},"items":[{"href":"` + obscure("/items/1") + `","title":"/items/1"}],"find":{"href":"/orders{?id}","templated":true}},"href":"/orders/7"}`,
+		},
+		{
+			name:     "HALEmbedded",
+			body:     `{"_embedded":{"orders":[{"_links":{"self":{"href":"/orders/42"}}}]}}`,
+			expected: `{"_embedded":{"orders":[{"_links":{"self":{"href":"` + obscure("/orders/42") + `"}}}]}}`,
+		},
+		{
+			name:     "JSONAPI",
+			body:     `{"links":{"self":"/orders","next":{"href":"/orders?page=2","meta":{"count":2}}},"data":[{"id":"42","relationships":{"customer":{"links":{"related":"/orders/42/customer"},"data":{"id":"7"}}}}]}`,
+			expected: `{"links":{"self":"` + obscure("/orders") + `","next":{"href":"` + obscure("/orders?page=2") + `","meta":{"count":2}}},"data":[{"id":"42","relationships":{"customer":{"links":{"related":"` + obscure("/orders/42/customer") + `"},"data":{"id":"7"}}}}]}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/hal+json")
+				w.Write([]byte(test.body))
+			})
+			handler := obscurer.NewHandler(obscurer.Default, obscurer.NewMemoryStore(), h, obscurer.WithHypermedia())
+			response := httptest.NewRecorder()
+
+			// action.
+			handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+			// assert.
+			assert.Equal(t, test.expected, response.Body.String())
+		})
+	}
+}
@@ -0,0 +1,378 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package boltstore provides an obscurer.Store that persists mappings in an
+// embedded bbolt database, for single binary deployments that want
+// persistence without running a separate database.
+package boltstore
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/codec"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrClosed represents an error that occurs when using a store that has been
+// closed.
+var ErrClosed = errors.New("boltstore: store is closed")
+
+// Options represents the configuration options for the store.
+type Options struct {
+	// Bucket is the name of the bucket mappings are stored in.
+	Bucket []byte
+	// MaxBatchSize is the maximum number of concurrent writes that are
+	// combined into a single transaction. Zero disables batching.
+	MaxBatchSize int
+	// MaxBatchDelay is the maximum amount of time a write waits for other
+	// writes to combine with.
+	MaxBatchDelay time.Duration
+	// Timeout is the amount of time to wait for the file lock when opening
+	// a database. Zero waits indefinitely.
+	Timeout time.Duration
+	// Codec serializes the values written to the bucket. When nil, the
+	// original URL is written as is.
+	Codec codec.Codec
+	// Key is the strategy the keys of mappings are derived with from their
+	// obscured URLs.
+	Key obscurer.KeyStrategy
+}
+
+// Option applies an option to the provided configuration.
+type Option func(*Options)
+
+// WithKeyStrategy configures the strategy the keys of mappings are derived
+// with from their obscured URLs.
+func WithKeyStrategy(k obscurer.KeyStrategy) Option {
+	return func(o *Options) {
+		o.Key = k
+	}
+}
+
+// WithBucket configures the name of the bucket mappings are stored in.
+func WithBucket(bucket string) Option {
+	return func(o *Options) {
+		o.Bucket = []byte(bucket)
+	}
+}
+
+// WithBatch configures concurrent writes to be combined into transactions of
+// at most the provided size, waiting at most the provided delay.
+func WithBatch(size int, delay time.Duration) Option {
+	return func(o *Options) {
+		o.MaxBatchSize = size
+		o.MaxBatchDelay = delay
+	}
+}
+
+// WithTimeout configures the amount of time to wait for the file lock when
+// opening a database.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.Timeout = timeout
+	}
+}
+
+// WithCodec configures the codec values are serialized with. Values written
+// with other codecs, or without one, remain readable.
+func WithCodec(c codec.Codec) Option {
+	return func(o *Options) {
+		o.Codec = c
+	}
+}
+
+// Store stores mappings in a bbolt database.
+type Store struct {
+	db      *bolt.DB
+	owned   bool
+	options Options
+
+	mutex    sync.RWMutex
+	closed   bool
+	inflight sync.WaitGroup
+}
+
+// Open opens the bbolt database at the provided path, creating it when it
+// doesn't exist, and constructs a store on top of it. The database is
+// closed when the store is closed.
+func Open(path string, mode os.FileMode, opts ...Option) (*Store, error) {
+	options := options(opts)
+	db, err := bolt.Open(path, mode, &bolt.Options{Timeout: options.Timeout})
+	if err != nil {
+		return nil, err
+	}
+	s, err := New(db, opts...)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	s.owned = true
+	return s, nil
+}
+
+// New constructs a store on top of the provided bbolt database, creating
+// the bucket when it doesn't exist. The database is left open when the
+// store is closed.
+func New(db *bolt.DB, opts ...Option) (*Store, error) {
+	options := options(opts)
+	if options.MaxBatchSize > 0 {
+		db.MaxBatchSize = options.MaxBatchSize
+		db.MaxBatchDelay = options.MaxBatchDelay
+	}
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(options.Bucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db, options: options}, nil
+}
+
+// options applies the provided options to the default configuration.
+func options(opts []Option) Options {
+	options := Options{Bucket: []byte("obscurer")}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// DB provides the underlying database.
+func (s *Store) DB() *bolt.DB {
+	return s.db
+}
+
+// Close waits for in-flight operations to complete and closes the store,
+// after which operations fail with ErrClosed. The underlying database is
+// closed when it was opened by the store.
+func (s *Store) Close() error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mutex.Unlock()
+	s.inflight.Wait()
+	if s.owned {
+		return s.db.Close()
+	}
+	return nil
+}
+
+// Ping verifies the store is open, and its bucket readable.
+func (s *Store) Ping(ctx context.Context) error {
+	if err := s.acquire(); err != nil {
+		return err
+	}
+	defer s.release()
+	return s.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(s.options.Bucket) == nil {
+			return bolt.ErrBucketNotFound
+		}
+		return nil
+	})
+}
+
+// acquire registers an in-flight operation, failing when the store is
+// closed. release must be called once the operation completes.
+func (s *Store) acquire() error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.closed {
+		return ErrClosed
+	}
+	s.inflight.Add(1)
+	return nil
+}
+
+// release deregisters an in-flight operation.
+func (s *Store) release() {
+	s.inflight.Done()
+}
+
+// update runs the provided function within a read-write transaction,
+// combining it with concurrent writes when batching is enabled.
+func (s *Store) update(fn func(*bolt.Tx) error) error {
+	if s.options.MaxBatchSize > 0 {
+		return s.db.Batch(fn)
+	}
+	return s.db.Update(fn)
+}
+
+// value serializes the provided original URL with the configured codec.
+func (s *Store) value(original *url.URL) ([]byte, error) {
+	if s.options.Codec == nil {
+		return []byte(original.String()), nil
+	}
+	return codec.Encode(s.options.Codec, codec.Entry{
+		Original:  original,
+		CreatedAt: time.Now(),
+	})
+}
+
+// put places the provided mapping into the provided bucket. Since batched
+// functions may be retried, put must remain idempotent.
+func (s *Store) put(b *bolt.Bucket, obscured, original *url.URL) error {
+	key := []byte(s.options.Key.Key(obscured))
+	if value := b.Get(key); value != nil {
+		entry, err := codec.Decode(value)
+		if err != nil {
+			return err
+		}
+		if entry.Original.Path != original.Path {
+			return &obscurer.CollisionError{
+				Obscured: obscured,
+				Existing: entry.Original,
+				Original: original,
+			}
+		}
+		return nil
+	}
+	value, err := s.value(original)
+	if err != nil {
+		return err
+	}
+	return b.Put(key, value)
+}
+
+// Put places the mapping between the provided obscured URL and it's original
+// form into the store. A *obscurer.CollisionError is returned when the
+// obscured URL is already mapped to a URL with a different path.
+func (s *Store) Put(ctx context.Context, obscured, original *url.URL) error {
+	if err := s.acquire(); err != nil {
+		return err
+	}
+	defer s.release()
+	return s.update(func(tx *bolt.Tx) error {
+		return s.put(tx.Bucket(s.options.Bucket), obscured, original)
+	})
+}
+
+// Get retrieves the original form of the provided obscured URL.
+func (s *Store) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	if err := s.acquire(); err != nil {
+		return nil, false, err
+	}
+	defer s.release()
+	var original *url.URL
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(s.options.Bucket).Get([]byte(s.options.Key.Key(obscured)))
+		if value == nil {
+			return nil
+		}
+		entry, err := codec.Decode(value)
+		original = entry.Original
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return original, original != nil, nil
+}
+
+// Range invokes the provided function for each mapping in the store, in the
+// order of their obscured paths, until the function returns false. The
+// mappings are read within a single read-only transaction, so the function
+// must not modify the store.
+func (s *Store) Range(ctx context.Context, fn func(obscured, original *url.URL) bool) error {
+	if err := s.acquire(); err != nil {
+		return err
+	}
+	defer s.release()
+	return s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(s.options.Bucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			entry, err := codec.Decode(v)
+			if err != nil {
+				return err
+			}
+			if !fn(s.options.Key.URL(string(k)), entry.Original) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// Remove deletes the entry in the store for the provided obscured URL.
+func (s *Store) Remove(ctx context.Context, obscured *url.URL) error {
+	if err := s.acquire(); err != nil {
+		return err
+	}
+	defer s.release()
+	return s.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(s.options.Bucket).Delete([]byte(s.options.Key.Key(obscured)))
+	})
+}
+
+// Clear removes all entries in the store.
+func (s *Store) Clear(ctx context.Context) error {
+	if err := s.acquire(); err != nil {
+		return err
+	}
+	defer s.release()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(s.options.Bucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(s.options.Bucket)
+		return err
+	})
+}
+
+// Size computes the size of the store.
+func (s *Store) Size(ctx context.Context) (size int) {
+	if err := s.acquire(); err != nil {
+		return 0
+	}
+	defer s.release()
+	s.db.View(func(tx *bolt.Tx) error {
+		size = tx.Bucket(s.options.Bucket).Stats().KeyN
+		return nil
+	})
+	return
+}
+
+// Load loads the store with the provided mappings. The mappings are loaded
+// within a single transaction.
+func (s *Store) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	if err := s.acquire(); err != nil {
+		return err
+	}
+	defer s.release()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.options.Bucket)
+		for obscured, original := range mappings.Pointers() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := s.put(b, obscured, original); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,272 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package boltstore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/boltstore"
+	"github.com/freerware/obscurer/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func path(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "boltstore")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	return filepath.Join(dir, "obscurer.db")
+}
+
+func open(t *testing.T, opts ...boltstore.Option) *boltstore.Store {
+	s, err := boltstore.Open(path(t), 0600, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		s.Close()
+	})
+	return s
+}
+
+// TestStore tests that mappings can be placed, retrieved, and removed.
+func TestStore(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := open(t)
+	obscured, original := mustParse("/abc"), mustParse("http://www.example.com/this/is/the/way")
+
+	// action + assert.
+	require.NoError(t, s.Put(ctx, obscured, original))
+	require.NoError(t, s.Put(ctx, obscured, original))
+	assert.Equal(t, 1, s.Size(ctx))
+	got, ok, err := s.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, original.String(), got.String())
+	require.NoError(t, s.Remove(ctx, obscured))
+	_, ok, err = s.Get(ctx, obscured)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// TestStore_Put_Collision tests that placing a mapping for an obscured URL
+// that already maps to a different URL results in a collision error.
+func TestStore_Put_Collision(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := open(t)
+	require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+
+	// action.
+	err := s.Put(ctx, mustParse("/abc"), mustParse("/hey/der"))
+
+	// assert.
+	assert.True(t, errors.Is(err, obscurer.ErrCollision))
+}
+
+// TestStore_Put_Batch tests that concurrent writes are combined into
+// batches.
+func TestStore_Put_Batch(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := open(t, boltstore.WithBatch(10, 5*time.Millisecond))
+	var wg sync.WaitGroup
+
+	// action.
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, s.Put(ctx,
+				mustParse(fmt.Sprintf("/%d", i)),
+				mustParse(fmt.Sprintf("/this/is/the/way/%d", i))))
+		}(i)
+	}
+	wg.Wait()
+
+	// assert.
+	assert.Equal(t, 50, s.Size(ctx))
+}
+
+// TestStore_Load tests that the store can be loaded, and cleared.
+func TestStore_Load(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := open(t, boltstore.WithBucket("mappings"))
+
+	// action.
+	err := obscurer.LoadAll(ctx, s, map[*url.URL]*url.URL{
+		mustParse("/a"): mustParse("/this/is/the/way"),
+		mustParse("/b"): mustParse("/hey/der"),
+	})
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.Size(ctx))
+	require.NoError(t, s.Clear(ctx))
+	assert.Equal(t, 0, s.Size(ctx))
+}
+
+// TestStore_Reopen tests that mappings survive the store being reopened.
+func TestStore_Reopen(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	p := path(t)
+	s, err := boltstore.Open(p, 0600)
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+	require.NoError(t, s.Close())
+
+	// action.
+	s, err = boltstore.Open(p, 0600)
+	require.NoError(t, err)
+	defer s.Close()
+
+	// assert.
+	got, ok, err := s.Get(ctx, mustParse("/abc"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/this/is/the/way", got.String())
+}
+
+// TestStore_Codec tests that values are serialized with the configured
+// codec, and that values written without one remain readable.
+func TestStore_Codec(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	p := path(t)
+	s, err := boltstore.Open(p, 0600)
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, mustParse("/a"), mustParse("/this/is/the/way")))
+	require.NoError(t, s.Close())
+	s, err = boltstore.Open(p, 0600, boltstore.WithCodec(codec.JSON))
+	require.NoError(t, err)
+	defer s.Close()
+
+	// action.
+	err = s.Put(ctx, mustParse("/b"), mustParse("/hey/der"))
+
+	// assert.
+	require.NoError(t, err)
+	for key, original := range map[string]string{"/a": "/this/is/the/way", "/b": "/hey/der"} {
+		got, ok, err := s.Get(ctx, mustParse(key))
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, original, got.String())
+	}
+	assert.True(t, errors.Is(
+		s.Put(ctx, mustParse("/b"), mustParse("/this/is/the/way")), obscurer.ErrCollision))
+}
+
+// TestStore_Close tests that operations fail once the store is closed.
+func TestStore_Close(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := open(t)
+
+	// action.
+	require.NoError(t, s.Close())
+
+	// assert.
+	assert.NoError(t, s.Close())
+	assert.Equal(t, boltstore.ErrClosed, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+	_, ok, err := s.Get(ctx, mustParse("/abc"))
+	assert.Equal(t, boltstore.ErrClosed, err)
+	assert.False(t, ok)
+}
+
+// TestStore_Range tests that every mapping is visited until the function
+// returns false.
+func TestStore_Range(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := open(t)
+	require.NoError(t, obscurer.LoadAll(ctx, s, map[*url.URL]*url.URL{
+		mustParse("/a"): mustParse("/this/is/the/way"),
+		mustParse("/b"): mustParse("/hey/der"),
+	}))
+	visited := make(map[string]string)
+
+	// action.
+	err := s.Range(ctx, func(obscured, original *url.URL) bool {
+		visited[obscured.Path] = original.String()
+		return true
+	})
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"/a": "/this/is/the/way", "/b": "/hey/der"}, visited)
+	count := 0
+	require.NoError(t, s.Range(ctx, func(obscured, original *url.URL) bool {
+		count++
+		return false
+	}))
+	assert.Equal(t, 1, count, "expected ranging to stop")
+}
+
+func mustParse(str string) *url.URL {
+	u, err := url.Parse(str)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// TestStore_Ping tests that the store is reachable until it is closed.
+func TestStore_Ping(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := open(t)
+
+	// action.
+	err := s.Ping(ctx)
+
+	// assert.
+	assert.NoError(t, err)
+	require.NoError(t, s.Close())
+	assert.Equal(t, boltstore.ErrClosed, s.Ping(ctx))
+}
+
+// TestStore_KeyStrategy tests that obscured URLs differing only by their
+// query have mappings of their own when keyed by request URI.
+func TestStore_KeyStrategy(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := open(t, boltstore.WithKeyStrategy(obscurer.KeyRequestURI))
+	require.NoError(t, s.Put(ctx, mustParse("/abc?page=1"), mustParse("/users?page=1")))
+
+	// action.
+	err := s.Put(ctx, mustParse("/abc?page=2"), mustParse("/users?page=2"))
+
+	// assert.
+	require.NoError(t, err)
+	got, ok, err := s.Get(ctx, mustParse("/abc?page=2"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/users?page=2", got.String())
+	assert.Equal(t, 2, s.Size(ctx))
+}
@@ -0,0 +1,136 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package boltstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/freerware/obscurer/codec"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Damage describes how an entry of the store is damaged.
+type Damage int
+
+const (
+	// Malformed indicates the value of the entry can't be decoded.
+	Malformed Damage = iota + 1
+	// Orphaned indicates the entry is a nested bucket, which the store
+	// never writes.
+	Orphaned
+	// Expired indicates the entry has expired, yet remains in the store.
+	Expired
+)
+
+// String provides the name of the damage.
+func (d Damage) String() string {
+	switch d {
+	case Malformed:
+		return "malformed"
+	case Orphaned:
+		return "orphaned"
+	case Expired:
+		return "expired"
+	default:
+		return fmt.Sprintf("Damage(%d)", int(d))
+	}
+}
+
+// Problem describes a damaged entry of the store.
+type Problem struct {
+	// Key is the key of the entry, which is the obscured path of its
+	// mapping.
+	Key string
+	// Damage describes how the entry is damaged.
+	Damage Damage
+	// Err is the error encountered decoding the entry, if any.
+	Err error
+}
+
+// String describes the problem.
+func (p Problem) String() string {
+	if p.Err != nil {
+		return fmt.Sprintf("%q: %s: %v", p.Key, p.Damage, p.Err)
+	}
+	return fmt.Sprintf("%q: %s", p.Key, p.Damage)
+}
+
+// Report describes the outcome of checking the store.
+type Report struct {
+	// Scanned is the number of entries scanned.
+	Scanned int
+	// Problems describes the damaged entries found, in the order of their
+	// keys.
+	Problems []Problem
+	// Repaired indicates whether the damaged entries were removed.
+	Repaired bool
+}
+
+// Check scans every entry of the store for damage, such as values that
+// can't be decoded, nested buckets, and expired entries, removing the
+// damaged entries when repair is true. The entries are scanned, and
+// repaired, within a single transaction.
+func (s *Store) Check(ctx context.Context, repair bool) (Report, error) {
+	if err := s.acquire(); err != nil {
+		return Report{}, err
+	}
+	defer s.release()
+	check := s.db.View
+	if repair {
+		check = s.db.Update
+	}
+	var report Report
+	err := check(func(tx *bolt.Tx) error {
+		report = Report{}
+		b := tx.Bucket(s.options.Bucket)
+		now := time.Now()
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			report.Scanned++
+			if v == nil {
+				report.Problems = append(report.Problems, Problem{Key: string(k), Damage: Orphaned})
+				continue
+			}
+			entry, err := codec.Decode(v)
+			switch {
+			case err != nil:
+				report.Problems = append(report.Problems, Problem{Key: string(k), Damage: Malformed, Err: err})
+			case !entry.ExpiresAt.IsZero() && !now.Before(entry.ExpiresAt):
+				report.Problems = append(report.Problems, Problem{Key: string(k), Damage: Expired})
+			}
+		}
+		if !repair {
+			return nil
+		}
+		for _, p := range report.Problems {
+			remove := b.Delete
+			if p.Damage == Orphaned {
+				remove = b.DeleteBucket
+			}
+			if err := remove([]byte(p.Key)); err != nil {
+				return err
+			}
+		}
+		report.Repaired = true
+		return nil
+	})
+	return report, err
+}
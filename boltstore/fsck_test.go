@@ -0,0 +1,100 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package boltstore_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/freerware/obscurer/boltstore"
+	"github.com/freerware/obscurer/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+// damage places a healthy mapping into the provided store, along with a
+// malformed, an orphaned, and an expired entry.
+func damage(t *testing.T, s *boltstore.Store) {
+	ctx := context.Background()
+	require.NoError(t, s.Put(ctx, mustParse("/a"), mustParse("/this/is/the/way")))
+	expired, err := codec.Encode(codec.JSON, codec.Entry{
+		Original:  mustParse("/hey/der"),
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.DB().Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("obscurer"))
+		if err := b.Put([]byte("/b"), []byte("%zz")); err != nil {
+			return err
+		}
+		if _, err := b.CreateBucket([]byte("/c")); err != nil {
+			return err
+		}
+		return b.Put([]byte("/d"), expired)
+	}))
+}
+
+// TestStore_Check tests that damaged entries are reported without being
+// removed.
+func TestStore_Check(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := open(t)
+	damage(t, s)
+
+	// action.
+	report, err := s.Check(ctx, false)
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Scanned)
+	assert.False(t, report.Repaired)
+	require.Len(t, report.Problems, 3)
+	var damages []boltstore.Damage
+	for _, p := range report.Problems {
+		damages = append(damages, p.Damage)
+	}
+	assert.Equal(t, []boltstore.Damage{boltstore.Malformed, boltstore.Orphaned, boltstore.Expired}, damages)
+	assert.Error(t, report.Problems[0].Err)
+	assert.Equal(t, 4, s.Size(ctx))
+}
+
+// TestStore_Check_Repair tests that damaged entries are removed, leaving
+// the healthy mappings in place.
+func TestStore_Check_Repair(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := open(t)
+	damage(t, s)
+
+	// action.
+	report, err := s.Check(ctx, true)
+
+	// assert.
+	require.NoError(t, err)
+	assert.True(t, report.Repaired)
+	assert.Len(t, report.Problems, 3)
+	assert.Equal(t, 1, s.Size(ctx))
+	got, ok, err := s.Get(ctx, mustParse("/a"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/this/is/the/way", got.String())
+	report, err = s.Check(ctx, false)
+	require.NoError(t, err)
+	assert.Empty(t, report.Problems)
+}
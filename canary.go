@@ -0,0 +1,163 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"math/rand"
+	"net/url"
+)
+
+// CanaryOutcome represents the outcome of a resolution routed through the
+// candidate of a canary, compared with the resolution of the current
+// obscurer.
+type CanaryOutcome int
+
+const (
+	// CanaryMatch represents a resolution where the candidate and the
+	// current obscurer agreed.
+	CanaryMatch CanaryOutcome = iota
+	// CanaryMismatch represents a resolution where the candidate and the
+	// current obscurer disagreed.
+	CanaryMismatch
+	// CanaryMiss represents a resolution the candidate couldn't resolve,
+	// which fell back to the current obscurer.
+	CanaryMiss
+)
+
+// String provides the name of the outcome.
+func (o CanaryOutcome) String() string {
+	switch o {
+	case CanaryMatch:
+		return "match"
+	case CanaryMismatch:
+		return "mismatch"
+	case CanaryMiss:
+		return "miss"
+	}
+	return "unknown"
+}
+
+// CanaryObserver is notified of the outcome of each resolution routed
+// through the candidate of a canary.
+type CanaryObserver func(obscured *url.URL, outcome CanaryOutcome)
+
+// CanaryOptions represents the configuration options for a canary.
+type CanaryOptions struct {
+	// Percent is the percentage of resolutions routed through the
+	// candidate, between 0 and 100.
+	Percent float64
+	// Observer is notified of the outcome of each resolution routed
+	// through the candidate.
+	Observer CanaryObserver
+}
+
+// CanaryOption applies an option to the provided configuration.
+type CanaryOption func(*CanaryOptions)
+
+// WithCanaryPercent configures the percentage of resolutions routed through
+// the candidate.
+func WithCanaryPercent(percent float64) CanaryOption {
+	return func(o *CanaryOptions) {
+		o.Percent = percent
+	}
+}
+
+// WithCanaryObserver configures the observer notified of the outcome of
+// each resolution routed through the candidate.
+func WithCanaryObserver(observer CanaryObserver) CanaryOption {
+	return func(o *CanaryOptions) {
+		o.Observer = observer
+	}
+}
+
+// Canary routes a percentage of resolutions through a candidate resolver,
+// such as one with a new algorithm or key, in order to compare it with the
+// current obscurer on live traffic before switching to it. URLs are always
+// obscured by the current obscurer.
+type Canary struct {
+	current   Obscurer
+	candidate Resolver
+	options   CanaryOptions
+}
+
+// NewCanary constructs a canary routing resolutions through the provided
+// candidate, and otherwise through the provided current obscurer. None are
+// routed through the candidate until a percentage is configured with
+// WithCanaryPercent.
+func NewCanary(current Obscurer, candidate Resolver, opts ...CanaryOption) *Canary {
+	c := &Canary{current: current, candidate: candidate}
+	for _, opt := range opts {
+		opt(&c.options)
+	}
+	return c
+}
+
+// Obscure obscures the provided URL with the current obscurer.
+func (c *Canary) Obscure(u *url.URL) *url.URL {
+	return c.current.Obscure(u)
+}
+
+// Reroll obscures the provided URL for the provided attempt with the
+// current obscurer, falling back to Obscure when it can't reroll.
+func (c *Canary) Reroll(u *url.URL, attempt int) *url.URL {
+	if reroller, ok := c.current.(Reroller); ok {
+		return reroller.Reroll(u, attempt)
+	}
+	return c.current.Obscure(u)
+}
+
+// Normalize normalizes the provided URL as the current obscurer does, when
+// it normalizes URLs, and provides it as is otherwise.
+func (c *Canary) Normalize(u *url.URL) *url.URL {
+	return normalized(c.current, u)
+}
+
+// resolveCurrent resolves the provided obscured URL with the current
+// obscurer, when it is a resolver.
+func (c *Canary) resolveCurrent(obscured *url.URL) (*url.URL, bool) {
+	resolver, ok := c.current.(Resolver)
+	if !ok {
+		return nil, false
+	}
+	return resolver.Resolve(obscured)
+}
+
+// Resolve resolves the provided obscured URL with the current obscurer, or
+// for the configured percentage of resolutions, with the candidate. Those
+// resolved with the candidate are also resolved with the current obscurer,
+// so that their outcomes can be observed, and fall back to it when the
+// candidate can't resolve them.
+func (c *Canary) Resolve(obscured *url.URL) (*url.URL, bool) {
+	if c.options.Percent <= 0 || rand.Float64()*100 >= c.options.Percent {
+		return c.resolveCurrent(obscured)
+	}
+	candidate, candidateOK := c.candidate.Resolve(obscured)
+	current, currentOK := c.resolveCurrent(obscured)
+	outcome := CanaryMatch
+	switch {
+	case !candidateOK:
+		outcome = CanaryMiss
+	case !currentOK || candidate.String() != current.String():
+		outcome = CanaryMismatch
+	}
+	if c.options.Observer != nil {
+		c.options.Observer(obscured, outcome)
+	}
+	if !candidateOK {
+		return current, currentOK
+	}
+	return candidate, true
+}
@@ -0,0 +1,121 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticResolver resolves every URL to the same original.
+type staticResolver struct {
+	obscurer.Obscurer
+	original *url.URL
+}
+
+func (r staticResolver) Resolve(obscured *url.URL) (*url.URL, bool) {
+	if r.original == nil {
+		return nil, false
+	}
+	return r.original, true
+}
+
+// TestCanary_Resolve tests that resolutions routed through the candidate
+// are compared with those of the current obscurer.
+func TestCanary_Resolve(t *testing.T) {
+	current, err := obscurer.NewRouteObscurer(obscurer.Default, "/users/{id}")
+	require.NoError(t, err)
+	original := mustParse("/users/42")
+	obscured := current.Obscure(original)
+	tests := []struct {
+		name      string
+		percent   float64
+		candidate obscurer.Resolver
+		expected  string
+		outcomes  []obscurer.CanaryOutcome
+	}{
+		{
+			name:      "Match",
+			percent:   100,
+			candidate: current,
+			expected:  "/users/42",
+			outcomes:  []obscurer.CanaryOutcome{obscurer.CanaryMatch},
+		},
+		{
+			name:      "Mismatch",
+			percent:   100,
+			candidate: staticResolver{Obscurer: obscurer.Default, original: mustParse("/users/7")},
+			expected:  "/users/7",
+			outcomes:  []obscurer.CanaryOutcome{obscurer.CanaryMismatch},
+		},
+		{
+			name:      "Miss",
+			percent:   100,
+			candidate: staticResolver{Obscurer: obscurer.Default},
+			expected:  "/users/42",
+			outcomes:  []obscurer.CanaryOutcome{obscurer.CanaryMiss},
+		},
+		{
+			name:      "NotRouted",
+			percent:   0,
+			candidate: staticResolver{Obscurer: obscurer.Default, original: mustParse("/users/7")},
+			expected:  "/users/42",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			var outcomes []obscurer.CanaryOutcome
+			canary := obscurer.NewCanary(
+				current,
+				test.candidate,
+				obscurer.WithCanaryPercent(test.percent),
+				obscurer.WithCanaryObserver(func(obscured *url.URL, outcome obscurer.CanaryOutcome) {
+					outcomes = append(outcomes, outcome)
+				}),
+			)
+
+			// action.
+			resolved, ok := canary.Resolve(obscured)
+
+			// assert.
+			require.True(t, ok)
+			assert.Equal(t, test.expected, resolved.String())
+			assert.Equal(t, test.outcomes, outcomes)
+		})
+	}
+}
+
+// TestCanary_Obscure tests that URLs are obscured by the current obscurer.
+func TestCanary_Obscure(t *testing.T) {
+	// arrange.
+	u := mustParse("/this/is/the/way")
+	canary := obscurer.NewCanary(
+		obscurer.Default,
+		staticResolver{Obscurer: obscurer.Default},
+		obscurer.WithCanaryPercent(100),
+	)
+
+	// action.
+	obscured := canary.Obscure(u)
+
+	// assert.
+	assert.Equal(t, obscurer.Default.Obscure(u).String(), obscured.String())
+}
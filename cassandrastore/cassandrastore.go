@@ -0,0 +1,359 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package cassandrastore provides an obscurer.Store backed by a Cassandra
+// or Scylla table, for very large mapping sets where keeping every mapping
+// in memory is prohibitively expensive.
+//
+// Each mapping is its own partition, keyed by the obscured URL path, and
+// may carry a TTL, either configured for the store or provided per mapping
+// through PutWithTTL. The consistency of reads, writes, and the lightweight
+// transactions placing mappings can be tuned independently, such as to
+// trade the durability of writes for their throughput. When loading
+// mappings in bulk, writes are grouped into unlogged batches by the token
+// range that owns them, such that each batch is handled by a single set of
+// replicas.
+package cassandrastore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/freerware/obscurer"
+)
+
+// ErrNotFound represents the error returned by a Session when a query
+// results in no rows.
+var ErrNotFound = errors.New("cassandrastore: not found")
+
+// Consistency represents the consistency level of a statement, named as
+// in CQL. The empty consistency leaves the level of the session as is.
+type Consistency string
+
+const (
+	// Any represents the ANY consistency level.
+	Any Consistency = "ANY"
+	// One represents the ONE consistency level.
+	One Consistency = "ONE"
+	// Two represents the TWO consistency level.
+	Two Consistency = "TWO"
+	// Three represents the THREE consistency level.
+	Three Consistency = "THREE"
+	// Quorum represents the QUORUM consistency level.
+	Quorum Consistency = "QUORUM"
+	// All represents the ALL consistency level.
+	All Consistency = "ALL"
+	// LocalQuorum represents the LOCAL_QUORUM consistency level.
+	LocalQuorum Consistency = "LOCAL_QUORUM"
+	// EachQuorum represents the EACH_QUORUM consistency level.
+	EachQuorum Consistency = "EACH_QUORUM"
+	// LocalOne represents the LOCAL_ONE consistency level.
+	LocalOne Consistency = "LOCAL_ONE"
+	// Serial represents the SERIAL consistency level, which applies to the
+	// Paxos phase of lightweight transactions.
+	Serial Consistency = "SERIAL"
+	// LocalSerial represents the LOCAL_SERIAL consistency level, which
+	// applies to the Paxos phase of lightweight transactions.
+	LocalSerial Consistency = "LOCAL_SERIAL"
+)
+
+// Statement represents a single CQL statement and its bound values.
+type Statement struct {
+	Query  string
+	Values []interface{}
+	// Consistency is the consistency level of the statement. The
+	// statements of a batch share the consistency of the first.
+	Consistency Consistency
+	// SerialConsistency is the consistency level of the Paxos phase of a
+	// lightweight transaction.
+	SerialConsistency Consistency
+}
+
+// Session represents the subset of a CQL session needed by the store.
+type Session interface {
+	// Exec executes the provided statement.
+	Exec(ctx context.Context, stmt Statement) error
+	// Query executes the provided statement, scanning the first row into
+	// the provided destinations, returning ErrNotFound when there are no
+	// rows.
+	Query(ctx context.Context, stmt Statement, dest ...interface{}) error
+	// ExecCAS executes the provided lightweight transaction, scanning the
+	// existing row into the provided destinations when not applied.
+	ExecCAS(ctx context.Context, stmt Statement, dest ...interface{}) (bool, error)
+	// Batch executes the provided statements as an unlogged batch.
+	Batch(ctx context.Context, stmts []Statement) error
+}
+
+// TokenRing represents a session that is aware of the token ring of the
+// cluster. Sessions implementing it have bulk writes batched by token
+// range.
+type TokenRing interface {
+	// Tokens provides the tokens of the ring, each of which ends the token
+	// range it owns.
+	Tokens(ctx context.Context) ([]int64, error)
+}
+
+// Options represents the configuration options for the store.
+type Options struct {
+	// Table is the fully qualified name of the table mappings are stored
+	// in.
+	Table string
+	// TTL is the duration mappings are kept for. Zero keeps mappings
+	// indefinitely.
+	TTL time.Duration
+	// BatchSize is the maximum number of statements per batch.
+	BatchSize int
+	// ReadConsistency is the consistency level of lookups.
+	ReadConsistency Consistency
+	// WriteConsistency is the consistency level of writes, including the
+	// commit phase of the lightweight transactions placing mappings.
+	WriteConsistency Consistency
+	// SerialConsistency is the consistency level of the Paxos phase of the
+	// lightweight transactions placing mappings.
+	SerialConsistency Consistency
+}
+
+// Option applies an option to the provided configuration.
+type Option func(*Options)
+
+// WithTable configures the fully qualified name of the table mappings are
+// stored in.
+func WithTable(table string) Option {
+	return func(o *Options) {
+		o.Table = table
+	}
+}
+
+// WithTTL configures the duration mappings are kept for.
+func WithTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.TTL = ttl
+	}
+}
+
+// WithBatchSize configures the maximum number of statements per batch.
+func WithBatchSize(size int) Option {
+	return func(o *Options) {
+		o.BatchSize = size
+	}
+}
+
+// WithReadConsistency configures the consistency level of lookups.
+func WithReadConsistency(c Consistency) Option {
+	return func(o *Options) {
+		o.ReadConsistency = c
+	}
+}
+
+// WithWriteConsistency configures the consistency level of writes.
+func WithWriteConsistency(c Consistency) Option {
+	return func(o *Options) {
+		o.WriteConsistency = c
+	}
+}
+
+// WithSerialConsistency configures the consistency level of the Paxos phase
+// of the lightweight transactions placing mappings.
+func WithSerialConsistency(c Consistency) Option {
+	return func(o *Options) {
+		o.SerialConsistency = c
+	}
+}
+
+// Store stores mappings in a Cassandra or Scylla table.
+type Store struct {
+	session Session
+	options Options
+}
+
+// New constructs a store backed by the provided session.
+func New(session Session, opts ...Option) *Store {
+	options := Options{Table: "obscurer.mappings", BatchSize: 50}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &Store{session: session, options: options}
+}
+
+// CreateTable creates the table mappings are stored in when it doesn't
+// exist. The keyspace must already exist.
+func (s *Store) CreateTable(ctx context.Context) error {
+	return s.session.Exec(ctx, Statement{Query: fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (obscured text PRIMARY KEY, original text)",
+		s.options.Table)})
+}
+
+// insert constructs the statement inserting the provided mapping, expiring
+// once the provided TTL has elapsed.
+func (s *Store) insert(obscured, original *url.URL, conditional bool, ttl time.Duration) Statement {
+	query := fmt.Sprintf("INSERT INTO %s (obscured, original) VALUES (?, ?)", s.options.Table)
+	stmt := Statement{Consistency: s.options.WriteConsistency}
+	if conditional {
+		query = query + " IF NOT EXISTS"
+		stmt.SerialConsistency = s.options.SerialConsistency
+	}
+	stmt.Query = query + " USING TTL ?"
+	stmt.Values = []interface{}{obscured.Path, original.String(), int(ttl / time.Second)}
+	return stmt
+}
+
+// Put places the mapping between the provided obscured URL and it's original
+// form into the store, using a lightweight transaction. A
+// *obscurer.CollisionError is returned when the obscured URL is already
+// mapped to a URL with a different path.
+func (s *Store) Put(ctx context.Context, obscured, original *url.URL) error {
+	return s.PutWithTTL(ctx, obscured, original, s.options.TTL)
+}
+
+// PutWithTTL places the mapping into the store as Put does, expiring it
+// once the provided TTL has elapsed rather than the TTL of the store. The
+// TTL is truncated to seconds, and zero keeps the mapping indefinitely.
+func (s *Store) PutWithTTL(ctx context.Context, obscured, original *url.URL, ttl time.Duration) error {
+	var existingObscured, existingOriginal string
+	applied, err := s.session.ExecCAS(
+		ctx, s.insert(obscured, original, true, ttl), &existingObscured, &existingOriginal)
+	if err != nil || applied {
+		return err
+	}
+	existing, err := url.Parse(existingOriginal)
+	if err != nil {
+		return err
+	}
+	if existing.Path != original.Path {
+		return &obscurer.CollisionError{
+			Obscured: obscured,
+			Existing: existing,
+			Original: original,
+		}
+	}
+	return nil
+}
+
+// Ping verifies the cluster is reachable and the table readable, by reading
+// the partition of the root path at the read consistency of the store.
+func (s *Store) Ping(ctx context.Context) error {
+	_, _, err := s.Get(ctx, &url.URL{Path: "/"})
+	return err
+}
+
+// Get retrieves the original form of the provided obscured URL.
+func (s *Store) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	var original string
+	err := s.session.Query(ctx, Statement{
+		Query:       fmt.Sprintf("SELECT original FROM %s WHERE obscured = ?", s.options.Table),
+		Values:      []interface{}{obscured.Path},
+		Consistency: s.options.ReadConsistency,
+	}, &original)
+	if errors.Is(err, ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	u, err := url.Parse(original)
+	if err != nil {
+		return nil, false, err
+	}
+	return u, true, nil
+}
+
+// Remove deletes the entry in the store for the provided obscured URL.
+func (s *Store) Remove(ctx context.Context, obscured *url.URL) error {
+	return s.session.Exec(ctx, Statement{
+		Query:       fmt.Sprintf("DELETE FROM %s WHERE obscured = ?", s.options.Table),
+		Values:      []interface{}{obscured.Path},
+		Consistency: s.options.WriteConsistency,
+	})
+}
+
+// Clear removes all entries in the store by truncating the table.
+func (s *Store) Clear(ctx context.Context) error {
+	return s.session.Exec(ctx, Statement{Query: fmt.Sprintf("TRUNCATE %s", s.options.Table)})
+}
+
+// Size computes the size of the store. Counting requires a scan of the
+// entire table, so it should be used sparingly.
+func (s *Store) Size(ctx context.Context) int {
+	var size int64
+	err := s.session.Query(ctx, Statement{
+		Query:       fmt.Sprintf("SELECT COUNT(*) FROM %s", s.options.Table),
+		Consistency: s.options.ReadConsistency,
+	}, &size)
+	if err != nil {
+		return 0
+	}
+	return int(size)
+}
+
+// Load loads the store with the provided mappings. The mappings are written
+// in unlogged batches grouped by token range when the session implements
+// TokenRing, replacing any existing mappings for the same obscured URLs.
+func (s *Store) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	var tokens []int64
+	if ring, ok := s.session.(TokenRing); ok {
+		var err error
+		if tokens, err = ring.Tokens(ctx); err != nil {
+			return err
+		}
+		sort.Slice(tokens, func(i, j int) bool { return tokens[i] < tokens[j] })
+	}
+	ranges := make(map[int][]Statement)
+	for obscured, original := range mappings.Pointers() {
+		r := -1
+		if len(tokens) > 0 {
+			r = owner(tokens, Token([]byte(obscured.Path)))
+		}
+		ranges[r] = append(ranges[r], s.insert(obscured, original, false, s.options.TTL))
+	}
+	for r, stmts := range ranges {
+		// without knowledge of the ring, batching across partitions only
+		// burdens the coordinator.
+		size := 1
+		if r >= 0 {
+			size = s.options.BatchSize
+		}
+		for start := 0; start < len(stmts); start += size {
+			end := start + size
+			if end > len(stmts) {
+				end = len(stmts)
+			}
+			var err error
+			if batch := stmts[start:end]; len(batch) == 1 {
+				err = s.session.Exec(ctx, batch[0])
+			} else {
+				err = s.session.Batch(ctx, batch)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// owner determines the index of the token range owning the provided token,
+// where each range ends with (and includes) its token, and the first range
+// wraps around the ring.
+func owner(tokens []int64, token int64) int {
+	i := sort.Search(len(tokens), func(i int) bool { return tokens[i] >= token })
+	if i == len(tokens) {
+		return 0
+	}
+	return i
+}
@@ -0,0 +1,297 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cassandrastore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/cassandrastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// session is an in-memory session understanding the statements issued by
+// the store.
+type session struct {
+	mutex   sync.Mutex
+	rows    map[string]string
+	ttls    map[string]int
+	batches [][]cassandrastore.Statement
+	tokens  []int64
+	stmts   []cassandrastore.Statement
+	err     error
+}
+
+func newSession() *session {
+	return &session{rows: make(map[string]string), ttls: make(map[string]int)}
+}
+
+func (s *session) exec(stmt cassandrastore.Statement) {
+	s.stmts = append(s.stmts, stmt)
+	switch {
+	case strings.HasPrefix(stmt.Query, "INSERT"):
+		key := stmt.Values[0].(string)
+		s.rows[key] = stmt.Values[1].(string)
+		s.ttls[key] = stmt.Values[2].(int)
+	case strings.HasPrefix(stmt.Query, "DELETE"):
+		delete(s.rows, stmt.Values[0].(string))
+	case strings.HasPrefix(stmt.Query, "TRUNCATE"):
+		s.rows = make(map[string]string)
+	}
+}
+
+func (s *session) Exec(ctx context.Context, stmt cassandrastore.Statement) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.exec(stmt)
+	return nil
+}
+
+func (s *session) Query(ctx context.Context, stmt cassandrastore.Statement, dest ...interface{}) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stmts = append(s.stmts, stmt)
+	if s.err != nil {
+		return s.err
+	}
+	if strings.HasPrefix(stmt.Query, "SELECT COUNT(*)") {
+		*dest[0].(*int64) = int64(len(s.rows))
+		return nil
+	}
+	original, ok := s.rows[stmt.Values[0].(string)]
+	if !ok {
+		return cassandrastore.ErrNotFound
+	}
+	*dest[0].(*string) = original
+	return nil
+}
+
+func (s *session) ExecCAS(ctx context.Context, stmt cassandrastore.Statement, dest ...interface{}) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := stmt.Values[0].(string)
+	if original, ok := s.rows[key]; ok {
+		s.stmts = append(s.stmts, stmt)
+		*dest[0].(*string) = key
+		*dest[1].(*string) = original
+		return false, nil
+	}
+	s.exec(stmt)
+	return true, nil
+}
+
+func (s *session) Batch(ctx context.Context, stmts []cassandrastore.Statement) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.batches = append(s.batches, stmts)
+	for _, stmt := range stmts {
+		s.exec(stmt)
+	}
+	return nil
+}
+
+// ringSession is a session aware of the token ring.
+type ringSession struct {
+	*session
+}
+
+func (s ringSession) Tokens(ctx context.Context) ([]int64, error) {
+	return s.tokens, nil
+}
+
+// TestToken tests that tokens match those computed by the Murmur3
+// partitioner.
+func TestToken(t *testing.T) {
+	assert.Equal(t, int64(-3758069500696749310), cassandrastore.Token([]byte("hello")))
+	assert.Equal(t, int64(0), cassandrastore.Token(nil))
+}
+
+// TestStore tests that mappings can be placed, retrieved, and removed.
+func TestStore(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	sess := newSession()
+	s := cassandrastore.New(sess, cassandrastore.WithTTL(time.Hour))
+	obscured, original := mustParse("/abc"), mustParse("http://www.example.com/this/is/the/way")
+
+	// action + assert.
+	require.NoError(t, s.Put(ctx, obscured, original))
+	require.NoError(t, s.Put(ctx, obscured, original))
+	assert.Equal(t, 1, s.Size(ctx))
+	assert.Equal(t, 3600, sess.ttls["/abc"])
+	got, ok, err := s.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, original.String(), got.String())
+	require.NoError(t, s.Remove(ctx, obscured))
+	_, ok, err = s.Get(ctx, obscured)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// TestStore_PutWithTTL tests that mappings can carry their own TTL.
+func TestStore_PutWithTTL(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	sess := newSession()
+	s := cassandrastore.New(sess, cassandrastore.WithTTL(time.Hour))
+
+	// action.
+	err := s.PutWithTTL(ctx, mustParse("/abc"), mustParse("/this/is/the/way"), time.Minute)
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 60, sess.ttls["/abc"])
+	var _ obscurer.ExpiringStore = s
+}
+
+// TestStore_Consistency tests that statements carry the consistency levels
+// configured for reads and writes.
+func TestStore_Consistency(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	sess := newSession()
+	s := cassandrastore.New(
+		sess,
+		cassandrastore.WithReadConsistency(cassandrastore.LocalOne),
+		cassandrastore.WithWriteConsistency(cassandrastore.LocalQuorum),
+		cassandrastore.WithSerialConsistency(cassandrastore.LocalSerial),
+	)
+
+	// action.
+	require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+	_, _, err := s.Get(ctx, mustParse("/abc"))
+	require.NoError(t, err)
+	require.NoError(t, s.Remove(ctx, mustParse("/abc")))
+
+	// assert.
+	require.Len(t, sess.stmts, 3)
+	assert.Equal(t, cassandrastore.LocalQuorum, sess.stmts[0].Consistency)
+	assert.Equal(t, cassandrastore.LocalSerial, sess.stmts[0].SerialConsistency)
+	assert.Equal(t, cassandrastore.LocalOne, sess.stmts[1].Consistency)
+	assert.Equal(t, cassandrastore.LocalQuorum, sess.stmts[2].Consistency)
+	assert.Empty(t, sess.stmts[2].SerialConsistency)
+}
+
+// TestStore_Ping tests that the store is reachable while queries succeed.
+func TestStore_Ping(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	sess := newSession()
+	s := cassandrastore.New(sess)
+
+	// action.
+	err := s.Ping(ctx)
+
+	// assert.
+	assert.NoError(t, err)
+	sess.err = errors.New("whoa")
+	assert.Error(t, s.Ping(ctx))
+	var _ obscurer.Pinger = s
+}
+
+// TestStore_Put_Collision tests that placing a mapping for an obscured URL
+// that already maps to a different URL results in a collision error.
+func TestStore_Put_Collision(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := cassandrastore.New(newSession())
+	require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+
+	// action.
+	err := s.Put(ctx, mustParse("/abc"), mustParse("/hey/der"))
+
+	// assert.
+	assert.True(t, errors.Is(err, obscurer.ErrCollision))
+}
+
+// TestStore_Load tests that mappings are written individually when the
+// session is not aware of the token ring.
+func TestStore_Load(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	sess := newSession()
+	s := cassandrastore.New(sess)
+
+	// action.
+	err := obscurer.LoadAll(ctx, s, mappings(10))
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 10, s.Size(ctx))
+	assert.Empty(t, sess.batches)
+	require.NoError(t, s.Clear(ctx))
+	assert.Equal(t, 0, s.Size(ctx))
+}
+
+// TestStore_Load_TokenAware tests that mappings are batched by the token
+// range that owns them.
+func TestStore_Load_TokenAware(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	sess := newSession()
+	sess.tokens = []int64{4611686018427387904, -4611686018427387904, 0}
+	s := cassandrastore.New(ringSession{sess}, cassandrastore.WithBatchSize(1000))
+
+	// action.
+	err := obscurer.LoadAll(ctx, s, mappings(100))
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 100, s.Size(ctx))
+	require.NotEmpty(t, sess.batches)
+	assert.True(t, len(sess.batches) <= 4)
+	for _, batch := range sess.batches {
+		rangeOf := func(stmt cassandrastore.Statement) int {
+			token := cassandrastore.Token([]byte(stmt.Values[0].(string)))
+			switch {
+			case token <= -4611686018427387904 || token > 4611686018427387904:
+				return 0
+			case token <= 0:
+				return 1
+			default:
+				return 2
+			}
+		}
+		for _, stmt := range batch {
+			assert.Equal(t, rangeOf(batch[0]), rangeOf(stmt), "expected batch to contain a single token range")
+		}
+	}
+}
+
+func mappings(n int) map[*url.URL]*url.URL {
+	m := make(map[*url.URL]*url.URL, n)
+	for i := 0; i < n; i++ {
+		m[mustParse(fmt.Sprintf("/%d", i))] = mustParse(fmt.Sprintf("/products/%d", i))
+	}
+	return m
+}
+
+func mustParse(str string) *url.URL {
+	u, err := url.Parse(str)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
@@ -0,0 +1,109 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cassandrastore
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// Token computes the token of the provided partition key as computed by the
+// Murmur3Partitioner, the default partitioner of Cassandra and Scylla.
+//
+// The partitioner uses the first half of MurmurHash3_x64_128, with the quirk
+// that the trailing bytes are sign extended.
+func Token(key []byte) int64 {
+	const (
+		c1 = 0x87c37b91114253d5
+		c2 = 0x4cf5ad432745937f
+	)
+	var h1, h2 uint64
+	length := len(key)
+
+	// body.
+	for ; len(key) >= 16; key = key[16:] {
+		k1 := binary.LittleEndian.Uint64(key)
+		k2 := binary.LittleEndian.Uint64(key[8:])
+
+		k1 *= c1
+		k1 = bits.RotateLeft64(k1, 31)
+		k1 *= c2
+		h1 ^= k1
+
+		h1 = bits.RotateLeft64(h1, 27)
+		h1 += h2
+		h1 = h1*5 + 0x52dce729
+
+		k2 *= c2
+		k2 = bits.RotateLeft64(k2, 33)
+		k2 *= c1
+		h2 ^= k2
+
+		h2 = bits.RotateLeft64(h2, 31)
+		h2 += h1
+		h2 = h2*5 + 0x38495ab5
+	}
+
+	// tail, with each byte sign extended.
+	var k1, k2 uint64
+	for i := len(key) - 1; i >= 0; i-- {
+		b := uint64(int64(int8(key[i])))
+		if i >= 8 {
+			k2 ^= b << (8 * uint(i-8))
+		} else {
+			k1 ^= b << (8 * uint(i))
+		}
+	}
+	if len(key) > 8 {
+		k2 *= c2
+		k2 = bits.RotateLeft64(k2, 33)
+		k2 *= c1
+		h2 ^= k2
+	}
+	if len(key) > 0 {
+		k1 *= c1
+		k1 = bits.RotateLeft64(k1, 31)
+		k1 *= c2
+		h1 ^= k1
+	}
+
+	// finalization.
+	h1 ^= uint64(length)
+	h2 ^= uint64(length)
+	h1 += h2
+	h2 += h1
+	h1 = fmix64(h1)
+	h2 = fmix64(h2)
+	h1 += h2
+
+	token := int64(h1)
+	if token == math.MinInt64 {
+		// the minimum token is reserved by the partitioner.
+		return math.MaxInt64
+	}
+	return token
+}
+
+// fmix64 forces all bits of the provided hash block to avalanche.
+func fmix64(k uint64) uint64 {
+	k ^= k >> 33
+	k *= 0xff51afd7ed558ccd
+	k ^= k >> 33
+	k *= 0xc4ceb9fe1a85ec53
+	k ^= k >> 33
+	return k
+}
@@ -0,0 +1,217 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrRequestBodyFailure represents an error that occurs when obscuring the
+// URLs within the body of an outgoing request.
+var ErrRequestBodyFailure = errors.New("obscurer: unable to obscure URLs within request body")
+
+// textURL matches the absolute URLs within text bodies.
+var textURL = regexp.MustCompile(`https?://[^\s"'<>()]+`)
+
+// TransportOptions represents the configuration options for the transport.
+type TransportOptions struct {
+	// Destinations are the hosts of the outgoing requests whose bodies are
+	// rewritten.
+	Destinations []string
+	// Origins are the hosts of the URLs within bodies that are obscured.
+	Origins []string
+	// TTL is the duration the mappings placed into the store are kept for,
+	// when the store is an ExpiringStore. Zero keeps mappings indefinitely.
+	TTL time.Duration
+}
+
+// TransportOption applies an option to the provided configuration.
+type TransportOption func(*TransportOptions)
+
+// WithDestinations configures the transport to rewrite the bodies of the
+// requests sent to the provided hosts, such as 'hooks.example.com'.
+func WithDestinations(hosts ...string) TransportOption {
+	return func(o *TransportOptions) {
+		o.Destinations = append(o.Destinations, hosts...)
+	}
+}
+
+// WithOrigins configures the transport to obscure the URLs with the provided
+// hosts, which are those of the service the obscured URLs are resolved by.
+func WithOrigins(hosts ...string) TransportOption {
+	return func(o *TransportOptions) {
+		o.Origins = append(o.Origins, hosts...)
+	}
+}
+
+// WithTransportTTL configures the duration the mappings placed into the
+// store are kept for. It has no effect unless the store is an ExpiringStore.
+func WithTransportTTL(ttl time.Duration) TransportOption {
+	return func(o *TransportOptions) {
+		o.TTL = ttl
+	}
+}
+
+// transport is an http.RoundTripper obscuring the URLs within the bodies of
+// outgoing requests.
+type transport struct {
+	base         http.RoundTripper
+	handler      *handler
+	destinations map[string]bool
+	origins      map[string]bool
+}
+
+// NewTransport constructs an http.RoundTripper replacing the URLs within the
+// bodies of outgoing requests, such as notifications containing links, with
+// their obscured forms, placing their mappings into the provided store so
+// that the handler resolves them once followed. Only the absolute URLs with
+// one of the configured origins are obscured, and only within the bodies of
+// requests sent to one of the configured destinations, so that requests are
+// sent as is unless both are configured. JSON bodies have their string
+// values rewritten, and text bodies, such as HTML, have the URLs within
+// their text rewritten. Requests are sent with the provided round tripper,
+// or http.DefaultTransport when nil, and fail without being sent when their
+// URLs fail to be obscured, so that original URLs are never leaked.
+func NewTransport(o Obscurer, s Store, base http.RoundTripper, opts ...TransportOption) http.RoundTripper {
+	var options TransportOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &transport{
+		base:         base,
+		handler:      &handler{obscurer: o, store: s, options: HandlerOptions{TTL: options.TTL}},
+		destinations: make(map[string]bool),
+		origins:      make(map[string]bool),
+	}
+	for _, host := range options.Destinations {
+		t.destinations[strings.ToLower(host)] = true
+	}
+	for _, host := range options.Origins {
+		t.origins[strings.ToLower(host)] = true
+	}
+	return t
+}
+
+// RoundTrip sends the provided request, with the URLs within its body
+// obscured when it is sent to a configured destination.
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Body == nil || r.Body == http.NoBody || !t.destinations[strings.ToLower(r.URL.Hostname())] {
+		return t.base.RoundTrip(r)
+	}
+	rewrite := t.rewriter(r.Header.Get("Content-Type"))
+	if rewrite == nil {
+		return t.base.RoundTrip(r)
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if body, err = rewrite(r.Context(), body); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRequestBodyFailure, err)
+	}
+	// round trippers must not modify the provided request.
+	outgoing := r.Clone(r.Context())
+	outgoing.Body = ioutil.NopCloser(bytes.NewReader(body))
+	outgoing.ContentLength = int64(len(body))
+	outgoing.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	return t.base.RoundTrip(outgoing)
+}
+
+// rewriter provides the function rewriting bodies with the provided media
+// type, which is nil when bodies of the media type are sent as is.
+func (t *transport) rewriter(contentType string) func(context.Context, []byte) ([]byte, error) {
+	if isJSON(contentType) {
+		return t.rewriteJSON
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && strings.HasPrefix(mediaType, "text/") {
+		return t.rewriteText
+	}
+	return nil
+}
+
+// rewriteJSON obscures the URLs held by the string values of the provided
+// JSON document.
+func (t *transport) rewriteJSON(ctx context.Context, data []byte) ([]byte, error) {
+	var failed error
+	rewritten, err := rewriteJSON(data, func(field string, value interface{}) (interface{}, bool) {
+		str, ok := value.(string)
+		if !ok || failed != nil {
+			return nil, false
+		}
+		obscured, err := t.obscure(ctx, str)
+		if err != nil {
+			failed = err
+			return nil, false
+		}
+		return obscured, obscured != str
+	})
+	if err != nil {
+		// bodies that aren't valid JSON are sent as is.
+		return data, nil
+	}
+	return rewritten, failed
+}
+
+// rewriteText obscures the URLs within the provided text. Punctuation
+// trailing URLs, such as the period ending a sentence, is left as is.
+func (t *transport) rewriteText(ctx context.Context, data []byte) ([]byte, error) {
+	var failed error
+	rewritten := textURL.ReplaceAllFunc(data, func(match []byte) []byte {
+		if failed != nil {
+			return match
+		}
+		value := strings.TrimRight(string(match), ".,;:!?")
+		obscured, err := t.obscure(ctx, value)
+		if err != nil {
+			failed = err
+			return match
+		}
+		return []byte(obscured + string(match[len(value):]))
+	})
+	return rewritten, failed
+}
+
+// obscure provides the obscured form of the provided URL when it has one of
+// the configured origins, placing its mapping into the store, and the
+// provided value as is otherwise.
+func (t *transport) obscure(ctx context.Context, value string) (string, error) {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || !t.origins[strings.ToLower(u.Hostname())] {
+		return value, nil
+	}
+	obscured, err := t.handler.obscure(ctx, u)
+	if err != nil {
+		return "", err
+	}
+	return obscured.String(), nil
+}
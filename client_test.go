@@ -0,0 +1,165 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/internal/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// roundTripper records the requests sent through it.
+type roundTripper struct {
+	requests []*http.Request
+	bodies   []string
+}
+
+func (rt *roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	body := ""
+	if r.Body != nil {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = string(b)
+	}
+	rt.requests = append(rt.requests, r)
+	rt.bodies = append(rt.bodies, body)
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+}
+
+// TestTransport tests that the URLs with the configured origins are
+// obscured within the bodies of the requests sent to the configured
+// destinations, and that their mappings are placed into the store.
+func TestTransport(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		expected    func(obscured string) string
+	}{
+		{
+			name:        "JSON",
+			contentType: "application/json",
+			body:        `{"text":"Order shipped","link":"https://api.example.com/orders/42","help":"https://www.example.com/help"}`,
+			expected: func(obscured string) string {
+				return `{"text":"Order shipped","link":"` + obscured + `","help":"https://www.example.com/help"}`
+			},
+		},
+		{
+			name:        "Text",
+			contentType: "text/plain; charset=utf-8",
+			body:        "Your order shipped, see https://api.example.com/orders/42. Need help? https://www.example.com/help",
+			expected: func(obscured string) string {
+				return "Your order shipped, see " + obscured + ". Need help? https://www.example.com/help"
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			ctx := context.Background()
+			base := &roundTripper{}
+			client := &http.Client{Transport: obscurer.NewTransport(
+				obscurer.Default,
+				obscurer.DefaultStore,
+				base,
+				obscurer.WithDestinations("hooks.example.com"),
+				obscurer.WithOrigins("API.example.com"),
+			)}
+			original := mustParse("https://api.example.com/orders/42")
+			obscured := obscurer.Default.Obscure(original)
+			t.Cleanup(func() {
+				obscurer.DefaultStore.Clear(ctx)
+			})
+
+			// action.
+			resp, err := client.Post("https://hooks.example.com/notify", test.contentType, strings.NewReader(test.body))
+
+			// assert.
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Len(t, base.bodies, 1)
+			expected := test.expected(obscured.String())
+			assert.Equal(t, expected, base.bodies[0])
+			assert.Equal(t, int64(len(expected)), base.requests[0].ContentLength)
+			got, ok, err := obscurer.DefaultStore.Get(ctx, obscured)
+			require.NoError(t, err)
+			require.True(t, ok)
+			assert.Equal(t, original.String(), got.String())
+		})
+	}
+}
+
+// TestTransport_OtherDestination tests that the bodies of requests sent to
+// destinations that aren't configured are sent as is.
+func TestTransport_OtherDestination(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	base := &roundTripper{}
+	client := &http.Client{Transport: obscurer.NewTransport(
+		obscurer.Default,
+		obscurer.DefaultStore,
+		base,
+		obscurer.WithDestinations("hooks.example.com"),
+		obscurer.WithOrigins("api.example.com"),
+	)}
+	body := `{"link":"https://api.example.com/orders/42"}`
+
+	// action.
+	resp, err := client.Post("https://partner.example.com/notify", "application/json", strings.NewReader(body))
+
+	// assert.
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, []string{body}, base.bodies)
+	assert.Equal(t, 0, obscurer.DefaultStore.Size(ctx))
+}
+
+// TestTransport_StoreFailure tests that requests aren't sent when the URLs
+// within their bodies fail to be obscured.
+func TestTransport_StoreFailure(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mock.NewStore(ctrl)
+	store.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("whoa"))
+	base := &roundTripper{}
+	client := &http.Client{Transport: obscurer.NewTransport(
+		obscurer.Default,
+		store,
+		base,
+		obscurer.WithDestinations("hooks.example.com"),
+		obscurer.WithOrigins("api.example.com"),
+	)}
+
+	// action.
+	_, err := client.Post("https://hooks.example.com/notify", "application/json",
+		strings.NewReader(`{"link":"https://api.example.com/orders/42"}`))
+
+	// assert.
+	assert.True(t, errors.Is(err, obscurer.ErrRequestBodyFailure))
+	assert.Empty(t, base.requests)
+}
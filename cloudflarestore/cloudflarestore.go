@@ -0,0 +1,370 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package cloudflarestore provides an obscurer.Store backed by a Cloudflare
+// Workers KV namespace, using the Cloudflare REST API. This allows the
+// authoritative mappings to live where edge workers can also resolve them.
+//
+// Workers KV is eventually consistent, so a mapping written by one location
+// may take some time to become visible to others.
+package cloudflarestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/codec"
+)
+
+// DefaultBaseURL represents the base URL of the Cloudflare REST API.
+const DefaultBaseURL = "https://api.cloudflare.com/client/v4"
+
+// bulkLimit represents the maximum number of keys per bulk request.
+const bulkLimit = 10000
+
+// ErrRequestFailed represents an error that occurs when the Cloudflare API
+// responds with an unsuccessful status code.
+var ErrRequestFailed = errors.New("cloudflarestore: request failed")
+
+// Options represents the configuration options for the store.
+type Options struct {
+	// BaseURL is the base URL of the Cloudflare REST API.
+	BaseURL string
+	// Client is the HTTP client used to issue requests.
+	Client *http.Client
+	// Expiration is the duration mappings are kept for. Zero keeps mappings
+	// indefinitely, otherwise it must be at least 60 seconds.
+	Expiration time.Duration
+	// Codec serializes the values written to the namespace. When nil, the
+	// original URL is written as is.
+	Codec codec.Codec
+}
+
+// Option applies an option to the provided configuration.
+type Option func(*Options)
+
+// WithBaseURL configures the base URL of the Cloudflare REST API.
+func WithBaseURL(baseURL string) Option {
+	return func(o *Options) {
+		o.BaseURL = baseURL
+	}
+}
+
+// WithHTTPClient configures the HTTP client used to issue requests.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *Options) {
+		o.Client = client
+	}
+}
+
+// WithExpiration configures the duration mappings are kept for.
+func WithExpiration(expiration time.Duration) Option {
+	return func(o *Options) {
+		o.Expiration = expiration
+	}
+}
+
+// WithCodec configures the codec values are serialized with. Values written
+// with other codecs, or without one, remain readable.
+func WithCodec(c codec.Codec) Option {
+	return func(o *Options) {
+		o.Codec = c
+	}
+}
+
+// Store stores mappings in a Workers KV namespace.
+type Store struct {
+	namespace string
+	token     string
+	options   Options
+}
+
+// New constructs a store backed by the provided Workers KV namespace,
+// authenticating with the provided API token.
+func New(accountID, namespaceID, token string, opts ...Option) *Store {
+	options := Options{BaseURL: DefaultBaseURL, Client: http.DefaultClient}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &Store{
+		namespace: fmt.Sprintf(
+			"%s/accounts/%s/storage/kv/namespaces/%s",
+			options.BaseURL, url.PathEscape(accountID), url.PathEscape(namespaceID)),
+		token:   token,
+		options: options,
+	}
+}
+
+// do issues a request to the namespace, returning the response when it is
+// successful or not found.
+func (s *Store) do(ctx context.Context, method, path string, query url.Values, body io.Reader) (*http.Response, error) {
+	endpoint := s.namespace + path
+	if len(query) > 0 {
+		endpoint = endpoint + "?" + query.Encode()
+	}
+	request, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", "Bearer "+s.token)
+	response, err := s.options.Client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= 300 && response.StatusCode != http.StatusNotFound {
+		response.Body.Close()
+		return nil, fmt.Errorf("%w: %s %s: %s", ErrRequestFailed, method, path, response.Status)
+	}
+	return response, nil
+}
+
+// doJSON issues a request to the namespace with the provided value encoded
+// as JSON.
+func (s *Store) doJSON(ctx context.Context, method, path string, value interface{}) error {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	response, err := s.do(ctx, method, path, nil, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+// valuePath provides the path of the value for the provided obscured URL.
+func valuePath(obscured *url.URL) string {
+	return "/values/" + url.PathEscape(obscured.Path)
+}
+
+// expiration provides the query parameters for the configured expiration.
+func (s *Store) expiration() url.Values {
+	if s.options.Expiration <= 0 {
+		return nil
+	}
+	ttl := strconv.Itoa(int(s.options.Expiration / time.Second))
+	return url.Values{"expiration_ttl": []string{ttl}}
+}
+
+// get retrieves the original form of the provided obscured URL.
+func (s *Store) get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	response, err := s.do(ctx, http.MethodGet, valuePath(obscured), nil, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	value, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	entry, err := codec.Decode(value)
+	if err != nil {
+		return nil, false, err
+	}
+	return entry.Original, true, nil
+}
+
+// value serializes the provided original URL with the configured codec.
+func (s *Store) value(original *url.URL) ([]byte, error) {
+	if s.options.Codec == nil {
+		return []byte(original.String()), nil
+	}
+	entry := codec.Entry{Original: original, CreatedAt: time.Now()}
+	if s.options.Expiration > 0 {
+		entry.ExpiresAt = entry.CreatedAt.Add(s.options.Expiration)
+	}
+	return codec.Encode(s.options.Codec, entry)
+}
+
+// keys lists the names of all keys in the namespace.
+func (s *Store) keys(ctx context.Context) ([]string, error) {
+	var names []string
+	cursor := ""
+	for {
+		query := url.Values{"limit": []string{"1000"}}
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+		response, err := s.do(ctx, http.MethodGet, "/keys", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Result []struct {
+				Name string `json:"name"`
+			} `json:"result"`
+			ResultInfo struct {
+				Cursor string `json:"cursor"`
+			} `json:"result_info"`
+		}
+		err = json.NewDecoder(response.Body).Decode(&page)
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range page.Result {
+			names = append(names, key.Name)
+		}
+		if cursor = page.ResultInfo.Cursor; cursor == "" {
+			return names, nil
+		}
+	}
+}
+
+// Put places the mapping between the provided obscured URL and it's original
+// form into the store. A *obscurer.CollisionError is returned when the
+// obscured URL is already mapped to a URL with a different path.
+func (s *Store) Put(ctx context.Context, obscured, original *url.URL) error {
+	existing, ok, err := s.get(ctx, obscured)
+	if err != nil {
+		return err
+	}
+	if ok {
+		if existing.Path != original.Path {
+			return &obscurer.CollisionError{
+				Obscured: obscured,
+				Existing: existing,
+				Original: original,
+			}
+		}
+		return nil
+	}
+	value, err := s.value(original)
+	if err != nil {
+		return err
+	}
+	response, err := s.do(
+		ctx, http.MethodPut, valuePath(obscured), s.expiration(),
+		bytes.NewReader(value))
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+// Ping verifies the namespace is reachable with the API token of the store,
+// by listing a single page of its keys.
+func (s *Store) Ping(ctx context.Context) error {
+	query := url.Values{"limit": []string{"10"}}
+	response, err := s.do(ctx, http.MethodGet, "/keys", query, nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: namespace not found", ErrRequestFailed)
+	}
+	return nil
+}
+
+// Get retrieves the original form of the provided obscured URL.
+func (s *Store) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	return s.get(ctx, obscured)
+}
+
+// Remove deletes the entry in the store for the provided obscured URL.
+func (s *Store) Remove(ctx context.Context, obscured *url.URL) error {
+	response, err := s.do(ctx, http.MethodDelete, valuePath(obscured), nil, nil)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+// Clear removes all entries in the store.
+func (s *Store) Clear(ctx context.Context) error {
+	names, err := s.keys(ctx)
+	if err != nil {
+		return err
+	}
+	for len(names) > 0 {
+		n := len(names)
+		if n > bulkLimit {
+			n = bulkLimit
+		}
+		if err := s.doJSON(ctx, http.MethodDelete, "/bulk", names[:n]); err != nil {
+			return err
+		}
+		names = names[n:]
+	}
+	return nil
+}
+
+// Size computes the size of the store.
+func (s *Store) Size(ctx context.Context) int {
+	names, err := s.keys(ctx)
+	if err != nil {
+		return 0
+	}
+	return len(names)
+}
+
+// bulkEntry represents a single key written with the bulk API.
+type bulkEntry struct {
+	Key           string `json:"key"`
+	Value         string `json:"value"`
+	ExpirationTTL int    `json:"expiration_ttl,omitempty"`
+	Base64        bool   `json:"base64,omitempty"`
+}
+
+// Load loads the store with the provided mappings. The mappings are written
+// with the bulk API, replacing any existing mappings for the same obscured
+// URLs.
+func (s *Store) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	entries := make([]bulkEntry, 0, mappings.Len())
+	for obscured, original := range mappings.Pointers() {
+		value, err := s.value(original)
+		if err != nil {
+			return err
+		}
+		entry := bulkEntry{
+			Key:           obscured.Path,
+			Value:         string(value),
+			ExpirationTTL: int(s.options.Expiration / time.Second),
+		}
+		// the bulk API only accepts UTF-8 values, so encoded entries are
+		// transferred as base64.
+		if s.options.Codec != nil {
+			entry.Value = base64.StdEncoding.EncodeToString(value)
+			entry.Base64 = true
+		}
+		entries = append(entries, entry)
+	}
+	for len(entries) > 0 {
+		n := len(entries)
+		if n > bulkLimit {
+			n = bulkLimit
+		}
+		if err := s.doJSON(ctx, http.MethodPut, "/bulk", entries[:n]); err != nil {
+			return err
+		}
+		entries = entries[n:]
+	}
+	return nil
+}
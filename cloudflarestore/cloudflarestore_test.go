@@ -0,0 +1,251 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cloudflarestore_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/cloudflarestore"
+	"github.com/freerware/obscurer/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const namespace = "/accounts/account/storage/kv/namespaces/namespace"
+
+// kv is a fake of the Workers KV REST API.
+type kv struct {
+	mutex  sync.Mutex
+	values map[string]string
+	ttls   map[string]string
+}
+
+func newKV() *kv {
+	return &kv{values: make(map[string]string), ttls: make(map[string]string)}
+}
+
+func (k *kv) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, namespace)
+	switch {
+	case strings.HasPrefix(path, "/values/"):
+		key := strings.TrimPrefix(path, "/values/")
+		switch r.Method {
+		case http.MethodGet:
+			value, ok := k.values[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(value))
+		case http.MethodPut:
+			body, _ := ioutil.ReadAll(r.Body)
+			k.values[key] = string(body)
+			k.ttls[key] = r.URL.Query().Get("expiration_ttl")
+		case http.MethodDelete:
+			delete(k.values, key)
+		}
+	case path == "/keys":
+		var result []map[string]string
+		for key := range k.values {
+			result = append(result, map[string]string{"name": key})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"result":      result,
+			"result_info": map[string]string{"cursor": ""},
+		})
+	case path == "/bulk" && r.Method == http.MethodPut:
+		var entries []struct {
+			Key    string `json:"key"`
+			Value  string `json:"value"`
+			Base64 bool   `json:"base64"`
+		}
+		json.NewDecoder(r.Body).Decode(&entries)
+		for _, entry := range entries {
+			if entry.Base64 {
+				value, _ := base64.StdEncoding.DecodeString(entry.Value)
+				entry.Value = string(value)
+			}
+			k.values[entry.Key] = entry.Value
+		}
+	case path == "/bulk" && r.Method == http.MethodDelete:
+		var keys []string
+		json.NewDecoder(r.Body).Decode(&keys)
+		for _, key := range keys {
+			delete(k.values, key)
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func setup(t *testing.T, opts ...cloudflarestore.Option) (*kv, *cloudflarestore.Store) {
+	fake := newKV()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	opts = append(opts, cloudflarestore.WithBaseURL(server.URL))
+	return fake, cloudflarestore.New("account", "namespace", "token", opts...)
+}
+
+// TestStore tests that mappings can be placed, retrieved, and removed.
+func TestStore(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	fake, s := setup(t, cloudflarestore.WithExpiration(time.Hour))
+	obscured, original := mustParse("/abc"), mustParse("http://www.example.com/this/is/the/way")
+
+	// action + assert.
+	require.NoError(t, s.Put(ctx, obscured, original))
+	require.NoError(t, s.Put(ctx, obscured, original))
+	assert.Equal(t, 1, s.Size(ctx))
+	assert.Equal(t, "3600", fake.ttls["/abc"])
+	got, ok, err := s.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, original.String(), got.String())
+	require.NoError(t, s.Remove(ctx, obscured))
+	_, ok, err = s.Get(ctx, obscured)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// TestStore_Put_Collision tests that placing a mapping for an obscured URL
+// that already maps to a different URL results in a collision error.
+func TestStore_Put_Collision(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	_, s := setup(t)
+	require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+
+	// action.
+	err := s.Put(ctx, mustParse("/abc"), mustParse("/hey/der"))
+
+	// assert.
+	assert.True(t, errors.Is(err, obscurer.ErrCollision))
+}
+
+// TestStore_Load tests that the store can be loaded, and cleared.
+func TestStore_Load(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	_, s := setup(t)
+
+	// action.
+	err := obscurer.LoadAll(ctx, s, map[*url.URL]*url.URL{
+		mustParse("/a"): mustParse("/this/is/the/way"),
+		mustParse("/b"): mustParse("/hey/der"),
+	})
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.Size(ctx))
+	got, ok, err := s.Get(ctx, mustParse("/b"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/hey/der", got.String())
+	require.NoError(t, s.Clear(ctx))
+	assert.Equal(t, 0, s.Size(ctx))
+}
+
+// TestStore_Codec tests that values are serialized with the configured
+// codec, including those written with the bulk API.
+func TestStore_Codec(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	fake, s := setup(t,
+		cloudflarestore.WithCodec(codec.MsgPack),
+		cloudflarestore.WithExpiration(time.Hour))
+
+	// action.
+	require.NoError(t, s.Put(ctx, mustParse("/a"), mustParse("/this/is/the/way")))
+	require.NoError(t, obscurer.LoadAll(ctx, s, map[*url.URL]*url.URL{
+		mustParse("/b"): mustParse("/hey/der"),
+	}))
+
+	// assert.
+	for key, original := range map[string]string{"/a": "/this/is/the/way", "/b": "/hey/der"} {
+		entry, err := codec.Decode([]byte(fake.values[key]))
+		require.NoError(t, err)
+		assert.Equal(t, original, entry.Original.String())
+		assert.Equal(t, time.Hour, entry.ExpiresAt.Sub(entry.CreatedAt))
+		got, ok, err := s.Get(ctx, mustParse(key))
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, original, got.String())
+	}
+}
+
+// TestStore_Unauthorized tests that unsuccessful responses result in an
+// error.
+func TestStore_Unauthorized(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	server := httptest.NewServer(newKV())
+	defer server.Close()
+	s := cloudflarestore.New(
+		"account", "namespace", "wrong", cloudflarestore.WithBaseURL(server.URL))
+
+	// action.
+	err := s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way"))
+
+	// assert.
+	assert.True(t, errors.Is(err, cloudflarestore.ErrRequestFailed))
+}
+
+// TestStore_Ping tests that the store is reachable while the namespace can
+// be read with its API token.
+func TestStore_Ping(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	_, s := setup(t)
+	server := httptest.NewServer(newKV())
+	defer server.Close()
+	unauthorized := cloudflarestore.New(
+		"account", "namespace", "wrong", cloudflarestore.WithBaseURL(server.URL))
+
+	// action.
+	err := s.Ping(ctx)
+
+	// assert.
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(unauthorized.Ping(ctx), cloudflarestore.ErrRequestFailed))
+	var _ obscurer.Pinger = s
+}
+
+func mustParse(str string) *url.URL {
+	u, err := url.Parse(str)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
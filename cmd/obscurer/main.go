@@ -0,0 +1,229 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Command obscurer provides tooling for operating the stores of obscured
+// URL mappings.
+//
+// Usage:
+//
+//	obscurer fsck [-repair] [-bucket name] [-timeout duration] path
+//	obscurer apply [-dry-run] [-bucket name] [-timeout duration] mappings path
+//
+// The fsck command scans the bbolt database at the provided path for
+// damaged entries, such as values that can't be decoded, nested buckets,
+// and expired entries, reporting each of them. With -repair, the damaged
+// entries are removed. The command exits with status 1 when damaged entries
+// remain, and 2 when the database can't be checked.
+//
+// The apply command syncs the bbolt database at the provided path with the
+// mapping set in the provided JSON file, an object of original URLs keyed by
+// their obscured URL, such that it holds exactly the mapping set. Each
+// mapping added, changed, or removed is reported, flagging the currently
+// resolving obscured URLs that break. With -dry-run, the changes are
+// reported without being made, such that operators can assess the impact
+// before syncing. The command exits with status 2 when the database can't
+// be synced.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/boltstore"
+)
+
+// usage describes the usage of the command.
+const usage = `usage: obscurer fsck [-repair] [-bucket name] [-timeout duration] path
+       obscurer apply [-dry-run] [-bucket name] [-timeout duration] mappings path`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command with the provided arguments, providing its exit
+// status.
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "fsck":
+			return fsck(args[1:], stdout, stderr)
+		case "apply":
+			return apply(args[1:], stdout, stderr)
+		}
+	}
+	fmt.Fprintln(stderr, usage)
+	return 2
+}
+
+// fsck checks, and optionally repairs, the bbolt database at the path
+// within the provided arguments.
+func fsck(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	repair := flags.Bool("repair", false, "remove the damaged entries")
+	bucket := flags.String("bucket", "obscurer", "the bucket mappings are stored in")
+	timeout := flags.Duration("timeout", time.Second, "the amount of time to wait for the file lock")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(stderr, usage)
+		return 2
+	}
+	path := flags.Arg(0)
+	if _, err := os.Stat(path); err != nil {
+		fmt.Fprintf(stderr, "obscurer: %v\n", err)
+		return 2
+	}
+	s, err := boltstore.Open(path, 0600, boltstore.WithBucket(*bucket), boltstore.WithTimeout(*timeout))
+	if err != nil {
+		fmt.Fprintf(stderr, "obscurer: %v\n", err)
+		return 2
+	}
+	defer s.Close()
+	return check(context.Background(), s, *repair, stdout, stderr)
+}
+
+// checker represents a store that can be checked for damaged entries, such
+// as a *boltstore.Store.
+type checker interface {
+	Check(ctx context.Context, repair bool) (boltstore.Report, error)
+}
+
+// check checks, and optionally repairs, the provided store, reporting each
+// damaged entry, and provides the exit status of the fsck command.
+func check(ctx context.Context, s checker, repair bool, stdout, stderr io.Writer) int {
+	report, err := s.Check(ctx, repair)
+	if err != nil {
+		fmt.Fprintf(stderr, "obscurer: %v\n", err)
+		return 2
+	}
+	for _, p := range report.Problems {
+		fmt.Fprintln(stdout, p)
+	}
+	fmt.Fprintf(stdout, "%d entries scanned, %d damaged", report.Scanned, len(report.Problems))
+	if report.Repaired && len(report.Problems) > 0 {
+		fmt.Fprint(stdout, ", repaired")
+	}
+	fmt.Fprintln(stdout)
+	if len(report.Problems) > 0 && !report.Repaired {
+		return 1
+	}
+	return 0
+}
+
+// apply syncs, or reports the impact of syncing, the bbolt database at the
+// path within the provided arguments with the mapping set in the file
+// within them.
+func apply(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("apply", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dryRun := flags.Bool("dry-run", false, "report the changes without making them")
+	bucket := flags.String("bucket", "obscurer", "the bucket mappings are stored in")
+	timeout := flags.Duration("timeout", time.Second, "the amount of time to wait for the file lock")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		fmt.Fprintln(stderr, usage)
+		return 2
+	}
+	m, err := readMappings(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "obscurer: %v\n", err)
+		return 2
+	}
+	path := flags.Arg(1)
+	if _, err := os.Stat(path); err != nil {
+		fmt.Fprintf(stderr, "obscurer: %v\n", err)
+		return 2
+	}
+	s, err := boltstore.Open(path, 0600, boltstore.WithBucket(*bucket), boltstore.WithTimeout(*timeout))
+	if err != nil {
+		fmt.Fprintf(stderr, "obscurer: %v\n", err)
+		return 2
+	}
+	defer s.Close()
+	return sync(context.Background(), s, m, *dryRun, stdout, stderr)
+}
+
+// sync syncs the provided store with the provided mapping set, or only
+// plans doing so when dryRun is true, reporting each change, and provides
+// the exit status of the apply command.
+func sync(ctx context.Context, s obscurer.RangeStore, m obscurer.Mappings, dryRun bool, stdout, stderr io.Writer) int {
+	plan := obscurer.Apply
+	if dryRun {
+		plan = obscurer.Plan
+	}
+	impact, err := plan(ctx, s, m)
+	if err != nil {
+		fmt.Fprintf(stderr, "obscurer: %v\n", err)
+		return 2
+	}
+	counts := make(map[obscurer.ChangeKind]int)
+	for _, c := range impact {
+		counts[c.Kind]++
+		switch c.Kind {
+		case obscurer.ChangeAdded:
+			fmt.Fprintf(stdout, "added %s -> %s\n", c.Obscured, c.After)
+		case obscurer.ChangeChanged:
+			fmt.Fprintf(stdout, "changed %s -> %s (was %s, breaking)\n", c.Obscured, c.After, c.Before)
+		case obscurer.ChangeRemoved:
+			fmt.Fprintf(stdout, "removed %s (was %s, breaking)\n", c.Obscured, c.Before)
+		}
+	}
+	fmt.Fprintf(stdout, "%d added, %d changed, %d removed, %d breaking",
+		counts[obscurer.ChangeAdded], counts[obscurer.ChangeChanged],
+		counts[obscurer.ChangeRemoved], len(impact.Breaking()))
+	if dryRun {
+		fmt.Fprint(stdout, " (dry run)")
+	}
+	fmt.Fprintln(stdout)
+	return 0
+}
+
+// readMappings reads the mapping set in the JSON file at the provided path,
+// an object of original URLs keyed by their obscured URL.
+func readMappings(path string) (obscurer.Mappings, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return obscurer.Mappings{}, err
+	}
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return obscurer.Mappings{}, fmt.Errorf("%s: %v", path, err)
+	}
+	m := obscurer.Mappings{Entries: make(map[string]*url.URL, len(raw))}
+	for obscured, original := range raw {
+		o, err := url.Parse(obscured)
+		if err != nil {
+			return obscurer.Mappings{}, fmt.Errorf("%s: %v", path, err)
+		}
+		u, err := url.Parse(original)
+		if err != nil {
+			return obscurer.Mappings{}, fmt.Errorf("%s: %v", path, err)
+		}
+		m.Add(o, u)
+	}
+	return m, nil
+}
@@ -0,0 +1,216 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/boltstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore provides a memory store holding the provided mappings.
+func memoryStore(t *testing.T, mappings map[string]string) obscurer.RangeStore {
+	s := obscurer.NewMemoryStore().(obscurer.RangeStore)
+	for obscured, original := range mappings {
+		require.NoError(t, s.Put(context.Background(), mustParse(obscured), mustParse(original)))
+	}
+	return s
+}
+
+// contents provides the mappings of the provided store.
+func contents(t *testing.T, s obscurer.RangeStore) map[string]string {
+	mappings := make(map[string]string)
+	require.NoError(t, s.Range(context.Background(), func(obscured, original *url.URL) bool {
+		mappings[obscured.String()] = original.String()
+		return true
+	}))
+	return mappings
+}
+
+// checked is a memory store reporting the provided outcome when checked.
+type checked struct {
+	obscurer.Store
+	report   boltstore.Report
+	err      error
+	repaired bool
+}
+
+func (c *checked) Check(ctx context.Context, repair bool) (boltstore.Report, error) {
+	c.repaired = repair
+	report := c.report
+	report.Repaired = repair
+	return report, c.err
+}
+
+// TestSync tests that the store is synced with the mapping set, and that
+// each change is reported.
+func TestSync(t *testing.T) {
+	tests := []struct {
+		name     string
+		dryRun   bool
+		expected map[string]string
+		output   string
+	}{
+		{
+			name:     "Apply",
+			expected: map[string]string{"/a": "/this/is/the/way", "/b": "/whoa", "/d": "/new"},
+			output: "changed /b -> /whoa (was /hey/der, breaking)\n" +
+				"removed /c (was /gone, breaking)\n" +
+				"added /d -> /new\n" +
+				"1 added, 1 changed, 1 removed, 2 breaking\n",
+		},
+		{
+			name:     "DryRun",
+			dryRun:   true,
+			expected: map[string]string{"/a": "/this/is/the/way", "/b": "/hey/der", "/c": "/gone"},
+			output: "changed /b -> /whoa (was /hey/der, breaking)\n" +
+				"removed /c (was /gone, breaking)\n" +
+				"added /d -> /new\n" +
+				"1 added, 1 changed, 1 removed, 2 breaking (dry run)\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			s := memoryStore(t, map[string]string{
+				"/a": "/this/is/the/way",
+				"/b": "/hey/der",
+				"/c": "/gone",
+			})
+			m, err := obscurer.NewMappings(obscurer.KeyPath, map[*url.URL]*url.URL{
+				mustParse("/a"): mustParse("/this/is/the/way"),
+				mustParse("/b"): mustParse("/whoa"),
+				mustParse("/d"): mustParse("/new"),
+			})
+			require.NoError(t, err)
+			var stdout, stderr bytes.Buffer
+
+			// action.
+			status := sync(context.Background(), s, m, test.dryRun, &stdout, &stderr)
+
+			// assert.
+			assert.Equal(t, 0, status)
+			assert.Equal(t, test.output, stdout.String())
+			assert.Empty(t, stderr.String())
+			assert.Equal(t, test.expected, contents(t, s))
+		})
+	}
+}
+
+// TestCheck tests that damaged entries are reported, and that the exit
+// status reflects whether damaged entries remain.
+func TestCheck(t *testing.T) {
+	damaged := boltstore.Report{
+		Scanned: 2,
+		Problems: []boltstore.Problem{
+			{Key: "/b", Damage: boltstore.Expired},
+		},
+	}
+	tests := []struct {
+		name   string
+		store  *checked
+		repair bool
+		status int
+		output string
+	}{
+		{
+			name:   "Healthy",
+			store:  &checked{report: boltstore.Report{Scanned: 2}},
+			output: "2 entries scanned, 0 damaged\n",
+		},
+		{
+			name:   "Damaged",
+			store:  &checked{report: damaged},
+			status: 1,
+			output: "\"/b\": expired\n2 entries scanned, 1 damaged\n",
+		},
+		{
+			name:   "Repaired",
+			store:  &checked{report: damaged},
+			repair: true,
+			output: "\"/b\": expired\n2 entries scanned, 1 damaged, repaired\n",
+		},
+		{
+			name:   "Failed",
+			store:  &checked{err: errors.New("whoa")},
+			status: 2,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			test.store.Store = obscurer.NewMemoryStore()
+			var stdout, stderr bytes.Buffer
+
+			// action.
+			status := check(context.Background(), test.store, test.repair, &stdout, &stderr)
+
+			// assert.
+			assert.Equal(t, test.status, status)
+			assert.Equal(t, test.output, stdout.String())
+			assert.Equal(t, test.repair, test.store.repaired)
+			if test.status == 2 {
+				assert.Equal(t, "obscurer: whoa\n", stderr.String())
+			}
+		})
+	}
+}
+
+// TestRun tests that the commands are run against a bbolt database, and
+// that the usage is reported for unknown commands.
+func TestRun(t *testing.T) {
+	// arrange.
+	dir, err := ioutil.TempDir("", "obscurer")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	db := filepath.Join(dir, "mappings.db")
+	s, err := boltstore.Open(db, 0600)
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+	mappings := filepath.Join(dir, "mappings.json")
+	require.NoError(t, ioutil.WriteFile(mappings, []byte(`{"/a":"/this/is/the/way"}`), 0600))
+	var stdout, stderr bytes.Buffer
+
+	// action + assert.
+	assert.Equal(t, 0, run([]string{"apply", mappings, db}, &stdout, &stderr))
+	assert.Equal(t, "added /a -> /this/is/the/way\n1 added, 0 changed, 0 removed, 0 breaking\n", stdout.String())
+	stdout.Reset()
+	assert.Equal(t, 0, run([]string{"fsck", db}, &stdout, &stderr))
+	assert.Equal(t, "1 entries scanned, 0 damaged\n", stdout.String())
+	assert.Empty(t, stderr.String())
+	assert.Equal(t, 2, run([]string{"whoa"}, &stdout, &stderr))
+	assert.Equal(t, usage+"\n", stderr.String())
+}
+
+func mustParse(str string) *url.URL {
+	u, err := url.Parse(str)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
@@ -0,0 +1,161 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package codec provides the serialization of entries written by stores
+// that persist opaque values, allowing the storage format to be chosen and
+// evolved over time.
+//
+// Encoded entries are prefixed with a small header identifying the codec and
+// the version of its schema, so that Decode can read entries written by any
+// registered codec. Values written before codecs were introduced, which are
+// the original URL as is, are also understood by Decode. This allows a store
+// to switch codecs without rewriting existing entries.
+//
+// Codecs are used by the stores whose backends hold values as opaque bytes,
+// which are the bolt, cloudflare, nats, memcache, and groupcache stores.
+// Stores backed by databases with typed fields, which are the sql,
+// cassandra, dynamo, mongo, and firestore stores, write the original URL and
+// expiration to fields of their own instead. Their backends expire and
+// query mappings by those fields, and their text fields can't hold the
+// binary header of an encoded entry.
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// magic represents the first byte of an encoded entry, which distinguishes
+// it from a plain URL since URLs never contain control characters.
+const magic = 0x00
+
+var (
+	// ErrUnknownCodec represents an error that occurs when decoding an entry
+	// written by a codec that isn't registered.
+	ErrUnknownCodec = errors.New("codec: unknown codec")
+	// ErrUnsupportedVersion represents an error that occurs when decoding an
+	// entry written with a schema version the codec doesn't understand.
+	ErrUnsupportedVersion = errors.New("codec: unsupported version")
+	// ErrMalformed represents an error that occurs when decoding an entry
+	// that is corrupt.
+	ErrMalformed = errors.New("codec: malformed entry")
+)
+
+// Entry represents a mapping as it is stored.
+type Entry struct {
+	// Original is the original form of the obscured URL.
+	Original *url.URL
+	// CreatedAt is the time the mapping was created. It is zero when
+	// unknown.
+	CreatedAt time.Time
+	// ExpiresAt is the time the mapping expires. It is zero when the
+	// mapping doesn't expire.
+	ExpiresAt time.Time
+	// Metadata is arbitrary information stored alongside the mapping.
+	Metadata map[string]string
+}
+
+// Codec serializes entries.
+type Codec interface {
+	// ID uniquely identifies the codec within encoded entries.
+	ID() byte
+	// Name provides the human readable name of the codec.
+	Name() string
+	// Version provides the schema version written by Marshal.
+	Version() byte
+	// Marshal serializes the provided entry.
+	Marshal(Entry) ([]byte, error)
+	// Unmarshal deserializes the provided data, which was serialized with
+	// the provided schema version.
+	Unmarshal(version byte, data []byte) (Entry, error)
+}
+
+var (
+	mutex  sync.RWMutex
+	codecs = map[byte]Codec{}
+)
+
+// Register makes the provided codec available to Decode. Register panics
+// when a codec with the same ID is already registered.
+func Register(c Codec) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if _, ok := codecs[c.ID()]; ok {
+		panic(fmt.Sprintf("codec: codec %d (%s) registered twice", c.ID(), c.Name()))
+	}
+	codecs[c.ID()] = c
+}
+
+func init() {
+	Register(JSON)
+	Register(Protobuf)
+	Register(MsgPack)
+}
+
+// Encode serializes the provided entry with the provided codec, prefixing it
+// with the header identifying the codec and schema version.
+func Encode(c Codec, e Entry) ([]byte, error) {
+	payload, err := c.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{magic, c.ID(), c.Version()}, payload...), nil
+}
+
+// Decode deserializes the provided data with the codec it was encoded with.
+// Data without a header is treated as the original URL as is.
+func Decode(data []byte) (Entry, error) {
+	if len(data) == 0 || data[0] != magic {
+		original, err := url.Parse(string(data))
+		if err != nil {
+			return Entry{}, err
+		}
+		return Entry{Original: original}, nil
+	}
+	if len(data) < 3 {
+		return Entry{}, ErrMalformed
+	}
+	mutex.RLock()
+	c, ok := codecs[data[1]]
+	mutex.RUnlock()
+	if !ok {
+		return Entry{}, fmt.Errorf("%w: %d", ErrUnknownCodec, data[1])
+	}
+	if data[2] == 0 || data[2] > c.Version() {
+		return Entry{}, fmt.Errorf("%w: %s version %d", ErrUnsupportedVersion, c.Name(), data[2])
+	}
+	return c.Unmarshal(data[2], data[3:])
+}
+
+// unixNano converts the provided time to nanoseconds since the Unix epoch,
+// keeping the zero time as zero.
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// fromUnixNano converts the provided nanoseconds since the Unix epoch to a
+// time, keeping zero as the zero time.
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n).UTC()
+}
@@ -0,0 +1,192 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package codec_test
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/freerware/obscurer/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCodec tests that entries survive being encoded and decoded with each
+// of the built-in codecs.
+func TestCodec(t *testing.T) {
+	tests := []codec.Codec{codec.JSON, codec.Protobuf, codec.MsgPack}
+	for _, c := range tests {
+		t.Run(c.Name(), func(t *testing.T) {
+			// arrange.
+			entry := codec.Entry{
+				Original:  mustParse("http://www.example.com/this/is/the/way?q=1"),
+				CreatedAt: time.Date(2021, 3, 1, 12, 0, 0, 42, time.UTC),
+				ExpiresAt: time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC),
+				Metadata:  map[string]string{"tenant": "mandalore", "by": "din"},
+			}
+
+			// action.
+			data, err := codec.Encode(c, entry)
+			require.NoError(t, err)
+			got, err := codec.Decode(data)
+
+			// assert.
+			require.NoError(t, err)
+			assert.Equal(t, entry.Original.String(), got.Original.String())
+			assert.True(t, entry.CreatedAt.Equal(got.CreatedAt))
+			assert.True(t, entry.ExpiresAt.Equal(got.ExpiresAt))
+			assert.Equal(t, entry.Metadata, got.Metadata)
+		})
+	}
+}
+
+// TestCodec_ZeroValues tests that unset times and metadata remain unset.
+func TestCodec_ZeroValues(t *testing.T) {
+	tests := []codec.Codec{codec.JSON, codec.Protobuf, codec.MsgPack}
+	for _, c := range tests {
+		t.Run(c.Name(), func(t *testing.T) {
+			// arrange.
+			entry := codec.Entry{Original: mustParse("/this/is/the/way")}
+
+			// action.
+			data, err := codec.Encode(c, entry)
+			require.NoError(t, err)
+			got, err := codec.Decode(data)
+
+			// assert.
+			require.NoError(t, err)
+			assert.Equal(t, "/this/is/the/way", got.Original.String())
+			assert.True(t, got.CreatedAt.IsZero())
+			assert.True(t, got.ExpiresAt.IsZero())
+			assert.Empty(t, got.Metadata)
+		})
+	}
+}
+
+// TestDecode_Plain tests that values without a header are decoded as the
+// original URL.
+func TestDecode_Plain(t *testing.T) {
+	// action.
+	got, err := codec.Decode([]byte("http://www.example.com/this/is/the/way"))
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, "http://www.example.com/this/is/the/way", got.Original.String())
+}
+
+// TestDecode_UnknownCodec tests that decoding an entry written by an
+// unregistered codec fails.
+func TestDecode_UnknownCodec(t *testing.T) {
+	// action.
+	_, err := codec.Decode([]byte{0x00, 0xff, 0x01})
+
+	// assert.
+	assert.True(t, errors.Is(err, codec.ErrUnknownCodec))
+}
+
+// TestDecode_UnsupportedVersion tests that decoding an entry written with a
+// newer schema version fails.
+func TestDecode_UnsupportedVersion(t *testing.T) {
+	// arrange.
+	data, err := codec.Encode(codec.JSON, codec.Entry{Original: mustParse("/this/is/the/way")})
+	require.NoError(t, err)
+	data[2] = codec.JSON.Version() + 1
+
+	// action.
+	_, err = codec.Decode(data)
+
+	// assert.
+	assert.True(t, errors.Is(err, codec.ErrUnsupportedVersion))
+}
+
+// TestDecode_Malformed tests that decoding corrupt entries fails.
+func TestDecode_Malformed(t *testing.T) {
+	tests := []codec.Codec{codec.JSON, codec.Protobuf, codec.MsgPack}
+	for _, c := range tests {
+		t.Run(c.Name(), func(t *testing.T) {
+			// arrange.
+			data, err := codec.Encode(c, codec.Entry{Original: mustParse("/this/is/the/way")})
+			require.NoError(t, err)
+
+			// action.
+			_, err = codec.Decode(data[:len(data)-3])
+
+			// assert.
+			assert.True(t, errors.Is(err, codec.ErrMalformed))
+		})
+	}
+}
+
+// TestProtobuf_Schema tests that entries are encoded as described by the
+// published schema, so that other tooling can decode them.
+func TestProtobuf_Schema(t *testing.T) {
+	// arrange.
+	entry := codec.Entry{
+		Original:  mustParse("/abc"),
+		CreatedAt: time.Unix(0, 1),
+		Metadata:  map[string]string{"k": "v"},
+	}
+
+	// action.
+	data, err := codec.Encode(codec.Protobuf, entry)
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x00, 0x02, 0x01, // header.
+		0x0a, 0x04, '/', 'a', 'b', 'c', // original = 1.
+		0x10, 0x01, // created_at = 2.
+		0x22, 0x06, 0x0a, 0x01, 'k', 0x12, 0x01, 'v', // metadata = 4.
+	}, data)
+}
+
+// TestProtobuf_UnknownFields tests that fields added to the schema are
+// skipped by older readers.
+func TestProtobuf_UnknownFields(t *testing.T) {
+	// arrange.
+	data := []byte{
+		0x00, codec.Protobuf.ID(), 0x01,
+		0x0a, 0x04, '/', 'a', 'b', 'c', // original.
+		0x28, 0x01, // field 5, varint.
+		0x31, 0, 0, 0, 0, 0, 0, 0, 0, // field 6, fixed64.
+		0x3a, 0x01, 'x', // field 7, bytes.
+	}
+
+	// action.
+	got, err := codec.Decode(data)
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, "/abc", got.Original.String())
+}
+
+// TestRegister_Duplicate tests that registering a codec twice panics.
+func TestRegister_Duplicate(t *testing.T) {
+	// action + assert.
+	assert.Panics(t, func() {
+		codec.Register(codec.JSON)
+	})
+}
+
+func mustParse(str string) *url.URL {
+	u, err := url.Parse(str)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
@@ -0,0 +1,73 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// JSON represents the codec serializing entries as JSON, which is the
+// easiest to inspect with other tooling.
+var JSON Codec = jsonCodec{}
+
+// jsonEntry represents the JSON schema of an entry.
+type jsonEntry struct {
+	Original  string            `json:"original"`
+	CreatedAt int64             `json:"created_at,omitempty"`
+	ExpiresAt int64             `json:"expires_at,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+type jsonCodec struct{}
+
+// ID uniquely identifies the codec within encoded entries.
+func (jsonCodec) ID() byte { return 1 }
+
+// Name provides the human readable name of the codec.
+func (jsonCodec) Name() string { return "json" }
+
+// Version provides the schema version written by Marshal.
+func (jsonCodec) Version() byte { return 1 }
+
+// Marshal serializes the provided entry.
+func (jsonCodec) Marshal(e Entry) ([]byte, error) {
+	return json.Marshal(jsonEntry{
+		Original:  e.Original.String(),
+		CreatedAt: unixNano(e.CreatedAt),
+		ExpiresAt: unixNano(e.ExpiresAt),
+		Metadata:  e.Metadata,
+	})
+}
+
+// Unmarshal deserializes the provided data.
+func (jsonCodec) Unmarshal(version byte, data []byte) (Entry, error) {
+	var j jsonEntry
+	if err := json.Unmarshal(data, &j); err != nil {
+		return Entry{}, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	original, err := url.Parse(j.Original)
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{
+		Original:  original,
+		CreatedAt: fromUnixNano(j.CreatedAt),
+		ExpiresAt: fromUnixNano(j.ExpiresAt),
+		Metadata:  j.Metadata,
+	}, nil
+}
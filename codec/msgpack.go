@@ -0,0 +1,279 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package codec
+
+import (
+	"encoding/binary"
+	"math"
+	"net/url"
+	"sort"
+)
+
+// MsgPack represents the codec serializing entries as a MessagePack map
+// with the same keys as the JSON codec, which is more compact than JSON
+// while remaining self describing.
+var MsgPack Codec = msgPackCodec{}
+
+type msgPackCodec struct{}
+
+// ID uniquely identifies the codec within encoded entries.
+func (msgPackCodec) ID() byte { return 3 }
+
+// Name provides the human readable name of the codec.
+func (msgPackCodec) Name() string { return "msgpack" }
+
+// Version provides the schema version written by Marshal.
+func (msgPackCodec) Version() byte { return 1 }
+
+// Marshal serializes the provided entry.
+func (msgPackCodec) Marshal(e Entry) ([]byte, error) {
+	size := 1
+	createdAt, expiresAt := unixNano(e.CreatedAt), unixNano(e.ExpiresAt)
+	if createdAt != 0 {
+		size++
+	}
+	if expiresAt != 0 {
+		size++
+	}
+	if len(e.Metadata) > 0 {
+		size++
+	}
+	b := appendMapHeader(nil, size)
+	b = appendString(b, "original")
+	b = appendString(b, e.Original.String())
+	if createdAt != 0 {
+		b = appendString(b, "created_at")
+		b = appendInt(b, createdAt)
+	}
+	if expiresAt != 0 {
+		b = appendString(b, "expires_at")
+		b = appendInt(b, expiresAt)
+	}
+	if len(e.Metadata) > 0 {
+		keys := make([]string, 0, len(e.Metadata))
+		for key := range e.Metadata {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		b = appendString(b, "metadata")
+		b = appendMapHeader(b, len(keys))
+		for _, key := range keys {
+			b = appendString(b, key)
+			b = appendString(b, e.Metadata[key])
+		}
+	}
+	return b, nil
+}
+
+// Unmarshal deserializes the provided data.
+func (msgPackCodec) Unmarshal(version byte, data []byte) (Entry, error) {
+	value, rest, err := readValue(data)
+	if err != nil {
+		return Entry{}, err
+	}
+	m, ok := value.(map[string]interface{})
+	if !ok || len(rest) > 0 {
+		return Entry{}, ErrMalformed
+	}
+	var e Entry
+	original, _ := m["original"].(string)
+	if e.Original, err = url.Parse(original); err != nil {
+		return Entry{}, err
+	}
+	if n, ok := m["created_at"].(int64); ok {
+		e.CreatedAt = fromUnixNano(n)
+	}
+	if n, ok := m["expires_at"].(int64); ok {
+		e.ExpiresAt = fromUnixNano(n)
+	}
+	if metadata, ok := m["metadata"].(map[string]interface{}); ok {
+		e.Metadata = make(map[string]string, len(metadata))
+		for key, value := range metadata {
+			if s, ok := value.(string); ok {
+				e.Metadata[key] = s
+			}
+		}
+	}
+	return e, nil
+}
+
+// appendMapHeader appends the header of a map with the provided size.
+func appendMapHeader(b []byte, size int) []byte {
+	switch {
+	case size < 16:
+		return append(b, 0x80|byte(size))
+	case size <= math.MaxUint16:
+		return append(b, 0xde, byte(size>>8), byte(size))
+	default:
+		b = append(b, 0xdf, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(b[len(b)-4:], uint32(size))
+		return b
+	}
+}
+
+// appendString appends the provided string.
+func appendString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, 0xda, byte(n>>8), byte(n))
+	default:
+		b = append(b, 0xdb, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(b[len(b)-4:], uint32(n))
+	}
+	return append(b, s...)
+}
+
+// appendInt appends the provided integer.
+func appendInt(b []byte, n int64) []byte {
+	b = append(b, 0xd3, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint64(b[len(b)-8:], uint64(n))
+	return b
+}
+
+// readValue reads a single value from the provided data, returning the
+// remaining data. Integers are read as int64, strings and binaries as
+// string, and maps as map[string]interface{}.
+func readValue(data []byte) (interface{}, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, ErrMalformed
+	}
+	t, data := data[0], data[1:]
+	switch {
+	case t <= 0x7f:
+		return int64(t), data, nil
+	case t >= 0xe0:
+		return int64(int8(t)), data, nil
+	case t&0xf0 == 0x80:
+		return readMap(data, int(t&0x0f))
+	case t&0xf0 == 0x90:
+		return readArray(data, int(t&0x0f))
+	case t&0xe0 == 0xa0:
+		return readString(data, int(t&0x1f))
+	}
+	switch t {
+	case 0xc0:
+		return nil, data, nil
+	case 0xc2, 0xc3:
+		return t == 0xc3, data, nil
+	case 0xc4, 0xd9:
+		return readSized(data, 1, readString)
+	case 0xc5, 0xda:
+		return readSized(data, 2, readString)
+	case 0xc6, 0xdb:
+		return readSized(data, 4, readString)
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		width := 1 << (t - 0xcc)
+		n, rest, err := readUint(data, width)
+		return int64(n), rest, err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		width := 1 << (t - 0xd0)
+		n, rest, err := readUint(data, width)
+		if err != nil {
+			return nil, nil, err
+		}
+		shift := uint(64 - 8*width)
+		return int64(n<<shift) >> shift, rest, nil
+	case 0xca:
+		n, rest, err := readUint(data, 4)
+		return float64(math.Float32frombits(uint32(n))), rest, err
+	case 0xcb:
+		n, rest, err := readUint(data, 8)
+		return math.Float64frombits(n), rest, err
+	case 0xdc:
+		return readSized(data, 2, readArray)
+	case 0xdd:
+		return readSized(data, 4, readArray)
+	case 0xde:
+		return readSized(data, 2, readMap)
+	case 0xdf:
+		return readSized(data, 4, readMap)
+	}
+	return nil, nil, ErrMalformed
+}
+
+// readUint reads a big endian unsigned integer of the provided width.
+func readUint(data []byte, width int) (uint64, []byte, error) {
+	if len(data) < width {
+		return 0, nil, ErrMalformed
+	}
+	var n uint64
+	for _, b := range data[:width] {
+		n = n<<8 | uint64(b)
+	}
+	return n, data[width:], nil
+}
+
+// readSized reads a length of the provided width, and then the value of that
+// length with the provided function.
+func readSized(data []byte, width int, fn func([]byte, int) (interface{}, []byte, error)) (interface{}, []byte, error) {
+	n, data, err := readUint(data, width)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fn(data, int(n))
+}
+
+// readString reads a string of the provided length.
+func readString(data []byte, length int) (interface{}, []byte, error) {
+	if length < 0 || len(data) < length {
+		return nil, nil, ErrMalformed
+	}
+	return string(data[:length]), data[length:], nil
+}
+
+// readArray reads an array of the provided size.
+func readArray(data []byte, size int) (interface{}, []byte, error) {
+	if size < 0 || len(data) < size {
+		return nil, nil, ErrMalformed
+	}
+	values := make([]interface{}, size)
+	for i := range values {
+		value, rest, err := readValue(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		values[i], data = value, rest
+	}
+	return values, data, nil
+}
+
+// readMap reads a map of the provided size. Keys that aren't strings are
+// skipped.
+func readMap(data []byte, size int) (interface{}, []byte, error) {
+	if size < 0 || len(data) < 2*size {
+		return nil, nil, ErrMalformed
+	}
+	m := make(map[string]interface{}, size)
+	for i := 0; i < size; i++ {
+		key, rest, err := readValue(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		value, rest, err := readValue(rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		if k, ok := key.(string); ok {
+			m[k] = value
+		}
+		data = rest
+	}
+	return m, data, nil
+}
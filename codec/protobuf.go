@@ -0,0 +1,94 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package codec
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/freerware/obscurer/internal/protowire"
+)
+
+// Protobuf represents the codec serializing entries in the protocol buffers
+// wire format, using the Entry message of
+// proto/freerware/obscurer/v1/entry.proto:
+//
+//	message Entry {
+//		string original = 1;
+//		int64 created_at = 2; // nanoseconds since the Unix epoch.
+//		int64 expires_at = 3; // nanoseconds since the Unix epoch.
+//		map<string, string> metadata = 4;
+//	}
+//
+// Unknown fields are skipped when decoding, so fields can be added to the
+// schema without bumping the version.
+var Protobuf Codec = protobufCodec{}
+
+type protobufCodec struct{}
+
+// ID uniquely identifies the codec within encoded entries.
+func (protobufCodec) ID() byte { return 2 }
+
+// Name provides the human readable name of the codec.
+func (protobufCodec) Name() string { return "protobuf" }
+
+// Version provides the schema version written by Marshal.
+func (protobufCodec) Version() byte { return 1 }
+
+// Marshal serializes the provided entry.
+func (protobufCodec) Marshal(e Entry) ([]byte, error) {
+	var b []byte
+	b = protowire.AppendBytes(b, 1, []byte(e.Original.String()))
+	b = protowire.AppendVarint(b, 2, uint64(unixNano(e.CreatedAt)))
+	b = protowire.AppendVarint(b, 3, uint64(unixNano(e.ExpiresAt)))
+	return protowire.AppendMap(b, 4, e.Metadata), nil
+}
+
+// Unmarshal deserializes the provided data.
+func (protobufCodec) Unmarshal(version byte, data []byte) (Entry, error) {
+	var e Entry
+	var original string
+	err := protowire.Fields(data, func(field uint64, v uint64, bytes []byte) error {
+		switch field {
+		case 1:
+			original = string(bytes)
+		case 2:
+			e.CreatedAt = fromUnixNano(int64(v))
+		case 3:
+			e.ExpiresAt = fromUnixNano(int64(v))
+		case 4:
+			key, value, err := protowire.MapEntry(bytes)
+			if err != nil {
+				return err
+			}
+			if e.Metadata == nil {
+				e.Metadata = make(map[string]string)
+			}
+			e.Metadata[key] = value
+		}
+		return nil
+	})
+	if errors.Is(err, protowire.ErrMalformed) {
+		return Entry{}, ErrMalformed
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+	if e.Original, err = url.Parse(original); err != nil {
+		return Entry{}, err
+	}
+	return e, nil
+}
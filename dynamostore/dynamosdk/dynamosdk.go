@@ -0,0 +1,326 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package dynamosdk adapts a DynamoDB client of the AWS SDK for Go v2 to the
+// Table of the dynamostore package, which is also a Consumer and a
+// TableCreator:
+//
+//	cfg, err := config.LoadDefaultConfig(ctx)
+//	table := dynamosdk.New(dynamodb.NewFromConfig(cfg), "obscurer")
+//	s := dynamostore.New(table, dynamostore.WithTTL(24*time.Hour))
+//	err = s.CreateTable(ctx, table)
+//
+// Puts are conditional on the obscured path either being unmapped or its
+// mapping having expired, and uses are consumed with conditional updates,
+// so that concurrent writers and readers never race one another.
+//
+// The package is a module of its own, so that applications that don't use
+// DynamoDB don't depend on the AWS SDK.
+package dynamosdk
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/freerware/obscurer/dynamostore"
+)
+
+// CreateTimeout represents the maximum duration CreateTable waits for a new
+// table to become active before enabling its time to live.
+var CreateTimeout = 5 * time.Minute
+
+const (
+	// putCondition represents the condition of puts, which succeed when the
+	// obscured path is unmapped or its mapping expired.
+	putCondition = "attribute_not_exists(obscured) OR expires_at < :now"
+	// consumeUpdate represents the update consuming a use of a mapping.
+	consumeUpdate = "SET uses = if_not_exists(uses, :zero) + :one"
+	// consumeCondition represents the condition of consuming a use of a
+	// mapping, which succeeds when the mapping exists, uses remain, and it
+	// hasn't expired.
+	consumeCondition = "attribute_exists(obscured) AND " +
+		"(attribute_not_exists(uses) OR uses < max_uses) AND " +
+		"(attribute_not_exists(expires_at) OR expires_at >= :now)"
+)
+
+// Table adapts a DynamoDB table to a dynamostore.Table.
+type Table struct {
+	client *dynamodb.Client
+	name   string
+}
+
+// New adapts the table with the provided name to a dynamostore.Table, which
+// it accesses with the provided client.
+func New(client *dynamodb.Client, name string) *Table {
+	return &Table{client: client, name: name}
+}
+
+// key provides the primary key of the item with the provided key.
+func key(k string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		dynamostore.KeyAttribute: &types.AttributeValueMemberS{Value: k},
+	}
+}
+
+// number provides the number attribute holding the provided value.
+func number(n int64) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(n, 10)}
+}
+
+// marshal converts the provided item to its attributes, omitting those
+// holding zero.
+func marshal(item dynamostore.Item) map[string]types.AttributeValue {
+	attrs := key(item.Obscured)
+	attrs["original"] = &types.AttributeValueMemberS{Value: item.Original}
+	if item.ExpiresAt != 0 {
+		attrs[dynamostore.TTLAttribute] = number(item.ExpiresAt)
+	}
+	if item.MaxUses != 0 {
+		attrs["max_uses"] = number(item.MaxUses)
+	}
+	if item.Uses != 0 {
+		attrs["uses"] = number(item.Uses)
+	}
+	return attrs
+}
+
+// unmarshal converts the provided attributes to an item.
+func unmarshal(attrs map[string]types.AttributeValue) (dynamostore.Item, error) {
+	var item dynamostore.Item
+	if s, ok := attrs[dynamostore.KeyAttribute].(*types.AttributeValueMemberS); ok {
+		item.Obscured = s.Value
+	}
+	if s, ok := attrs["original"].(*types.AttributeValueMemberS); ok {
+		item.Original = s.Value
+	}
+	for name, field := range map[string]*int64{
+		dynamostore.TTLAttribute: &item.ExpiresAt,
+		"max_uses":               &item.MaxUses,
+		"uses":                   &item.Uses,
+	} {
+		n, ok := attrs[name].(*types.AttributeValueMemberN)
+		if !ok {
+			continue
+		}
+		value, err := strconv.ParseInt(n.Value, 10, 64)
+		if err != nil {
+			return dynamostore.Item{}, err
+		}
+		*field = value
+	}
+	return item, nil
+}
+
+// PutItem writes the provided item when no item with the same key exists,
+// or the existing item expired before the provided time, and otherwise
+// returns dynamostore.ErrConditionFailed.
+func (t *Table) PutItem(ctx context.Context, item dynamostore.Item, now time.Time) error {
+	_, err := t.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                 aws.String(t.name),
+		Item:                      marshal(item),
+		ConditionExpression:       aws.String(putCondition),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":now": number(now.Unix())},
+	})
+	var failed *types.ConditionalCheckFailedException
+	if errors.As(err, &failed) {
+		return dynamostore.ErrConditionFailed
+	}
+	return err
+}
+
+// GetItem retrieves the item with the provided key, returning
+// dynamostore.ErrNotFound when it doesn't exist.
+func (t *Table) GetItem(ctx context.Context, k string, consistent bool) (dynamostore.Item, error) {
+	out, err := t.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(t.name),
+		Key:            key(k),
+		ConsistentRead: aws.Bool(consistent),
+	})
+	if err != nil {
+		return dynamostore.Item{}, err
+	}
+	if out.Item == nil {
+		return dynamostore.Item{}, dynamostore.ErrNotFound
+	}
+	return unmarshal(out.Item)
+}
+
+// DeleteItem deletes the item with the provided key.
+func (t *Table) DeleteItem(ctx context.Context, k string) error {
+	_, err := t.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(t.name),
+		Key:       key(k),
+	})
+	return err
+}
+
+// BatchWriteItem applies the provided writes, returning the writes that
+// were left unprocessed.
+func (t *Table) BatchWriteItem(ctx context.Context, writes []dynamostore.WriteRequest) ([]dynamostore.WriteRequest, error) {
+	requests := make([]types.WriteRequest, len(writes))
+	for i, w := range writes {
+		if w.Put != nil {
+			requests[i].PutRequest = &types.PutRequest{Item: marshal(*w.Put)}
+			continue
+		}
+		requests[i].DeleteRequest = &types.DeleteRequest{Key: key(w.Delete)}
+	}
+	out, err := t.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+		RequestItems: map[string][]types.WriteRequest{t.name: requests},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var unprocessed []dynamostore.WriteRequest
+	for _, r := range out.UnprocessedItems[t.name] {
+		if r.PutRequest != nil {
+			item, err := unmarshal(r.PutRequest.Item)
+			if err != nil {
+				return nil, err
+			}
+			unprocessed = append(unprocessed, dynamostore.WriteRequest{Put: &item})
+			continue
+		}
+		if r.DeleteRequest != nil {
+			k, _ := r.DeleteRequest.Key[dynamostore.KeyAttribute].(*types.AttributeValueMemberS)
+			if k != nil {
+				unprocessed = append(unprocessed, dynamostore.WriteRequest{Delete: k.Value})
+			}
+		}
+	}
+	return unprocessed, nil
+}
+
+// scan scans every page of the table with the provided input, invoking the
+// provided function for each.
+func (t *Table) scan(ctx context.Context, in *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput)) error {
+	in.TableName = aws.String(t.name)
+	paginator := dynamodb.NewScanPaginator(t.client, in)
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		fn(out)
+	}
+	return nil
+}
+
+// Count counts the items in the table.
+func (t *Table) Count(ctx context.Context) (int, error) {
+	var count int
+	err := t.scan(ctx, &dynamodb.ScanInput{Select: types.SelectCount}, func(out *dynamodb.ScanOutput) {
+		count += int(out.Count)
+	})
+	return count, err
+}
+
+// Keys retrieves the keys of all items in the table.
+func (t *Table) Keys(ctx context.Context) ([]string, error) {
+	var keys []string
+	err := t.scan(ctx, &dynamodb.ScanInput{
+		ProjectionExpression:     aws.String("#key"),
+		ExpressionAttributeNames: map[string]string{"#key": dynamostore.KeyAttribute},
+	}, func(out *dynamodb.ScanOutput) {
+		for _, item := range out.Items {
+			if k, ok := item[dynamostore.KeyAttribute].(*types.AttributeValueMemberS); ok {
+				keys = append(keys, k.Value)
+			}
+		}
+	})
+	return keys, err
+}
+
+// ConsumeItem increments the uses of the item with the provided key when
+// uses remain and it hasn't expired before the provided time, providing the
+// updated item. It returns dynamostore.ErrConditionFailed when no uses
+// remain or the item expired, and dynamostore.ErrNotFound when the item
+// doesn't exist.
+func (t *Table) ConsumeItem(ctx context.Context, k string, now time.Time) (dynamostore.Item, error) {
+	out, err := t.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(t.name),
+		Key:                 key(k),
+		UpdateExpression:    aws.String(consumeUpdate),
+		ConditionExpression: aws.String(consumeCondition),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":zero": number(0),
+			":one":  number(1),
+			":now":  number(now.Unix()),
+		},
+		ReturnValues:                        types.ReturnValueAllNew,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	var failed *types.ConditionalCheckFailedException
+	if errors.As(err, &failed) {
+		if len(failed.Item) == 0 {
+			return dynamostore.Item{}, dynamostore.ErrNotFound
+		}
+		return dynamostore.Item{}, dynamostore.ErrConditionFailed
+	}
+	if err != nil {
+		return dynamostore.Item{}, err
+	}
+	return unmarshal(out.Attributes)
+}
+
+// CreateTable creates the table with the provided definition, and enables
+// time to live once it is active when requested. It returns
+// dynamostore.ErrTableExists when the table already exists.
+func (t *Table) CreateTable(ctx context.Context, definition dynamostore.TableDefinition) error {
+	in := &dynamodb.CreateTableInput{
+		TableName: aws.String(t.name),
+		AttributeDefinitions: []types.AttributeDefinition{{
+			AttributeName: aws.String(definition.KeyAttribute),
+			AttributeType: types.ScalarAttributeTypeS,
+		}},
+		KeySchema: []types.KeySchemaElement{{
+			AttributeName: aws.String(definition.KeyAttribute),
+			KeyType:       types.KeyTypeHash,
+		}},
+		BillingMode: types.BillingModePayPerRequest,
+	}
+	if !definition.OnDemand {
+		in.BillingMode = types.BillingModeProvisioned
+		in.ProvisionedThroughput = &types.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(definition.ReadCapacityUnits),
+			WriteCapacityUnits: aws.Int64(definition.WriteCapacityUnits),
+		}
+	}
+	_, err := t.client.CreateTable(ctx, in)
+	var inUse *types.ResourceInUseException
+	if errors.As(err, &inUse) {
+		return dynamostore.ErrTableExists
+	}
+	if err != nil || definition.TTLAttribute == "" {
+		return err
+	}
+	waiter := dynamodb.NewTableExistsWaiter(t.client)
+	if err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(t.name)}, CreateTimeout); err != nil {
+		return err
+	}
+	_, err = t.client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(t.name),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(definition.TTLAttribute),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	return err
+}
@@ -0,0 +1,245 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynamosdk_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/freerware/obscurer/dynamostore"
+	"github.com/freerware/obscurer/dynamostore/dynamosdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// response represents the response of the fake DynamoDB endpoint to an
+// operation.
+type response struct {
+	status int
+	body   string
+}
+
+// endpoint is a fake DynamoDB endpoint, which records the operations and
+// requests it receives and replies with the queued responses.
+type endpoint struct {
+	t          *testing.T
+	operations []string
+	requests   []map[string]interface{}
+	responses  []response
+}
+
+func (e *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.Header.Get("X-Amz-Target")
+	e.operations = append(e.operations, target[strings.Index(target, ".")+1:])
+	var request map[string]interface{}
+	require.NoError(e.t, json.NewDecoder(r.Body).Decode(&request))
+	e.requests = append(e.requests, request)
+	require.NotEmpty(e.t, e.responses, "unexpected %s", target)
+	next := e.responses[0]
+	e.responses = e.responses[1:]
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.WriteHeader(next.status)
+	w.Write([]byte(next.body))
+}
+
+// table constructs a table accessed through the fake endpoint replying with
+// the provided responses.
+func table(t *testing.T, responses ...response) (*dynamosdk.Table, *endpoint) {
+	e := &endpoint{t: t, responses: responses}
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+	client := dynamodb.New(dynamodb.Options{
+		Region:           "us-east-1",
+		BaseEndpoint:     aws.String(server.URL),
+		Credentials:      aws.AnonymousCredentials{},
+		RetryMaxAttempts: 1,
+	})
+	return dynamosdk.New(client, "mappings"), e
+}
+
+// conditionFailed represents the response to a conditional write whose
+// condition failed, along with the provided existing item if any.
+func conditionFailed(item string) response {
+	body := `{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"The conditional request failed"`
+	if item != "" {
+		body += `,"Item":` + item
+	}
+	return response{status: http.StatusBadRequest, body: body + "}"}
+}
+
+// TestTable_PutItem tests that items are put conditionally, and that failed
+// conditions are mapped to dynamostore.ErrConditionFailed.
+func TestTable_PutItem(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	tbl, e := table(t, response{status: http.StatusOK, body: `{}`}, conditionFailed(""))
+	item := dynamostore.Item{Obscured: "/a", Original: "/this/is/the/way", ExpiresAt: 1700000000}
+	now := time.Unix(1600000000, 0)
+
+	// action.
+	first := tbl.PutItem(ctx, item, now)
+	second := tbl.PutItem(ctx, item, now)
+
+	// assert.
+	assert.NoError(t, first)
+	assert.Equal(t, dynamostore.ErrConditionFailed, second)
+	assert.Equal(t, []string{"PutItem", "PutItem"}, e.operations)
+	request := e.requests[0]
+	assert.Equal(t, "mappings", request["TableName"])
+	assert.Equal(t, "attribute_not_exists(obscured) OR expires_at < :now", request["ConditionExpression"])
+	assert.Equal(t, map[string]interface{}{
+		"obscured":   map[string]interface{}{"S": "/a"},
+		"original":   map[string]interface{}{"S": "/this/is/the/way"},
+		"expires_at": map[string]interface{}{"N": "1700000000"},
+	}, request["Item"])
+	assert.Equal(t, map[string]interface{}{":now": map[string]interface{}{"N": "1600000000"}},
+		request["ExpressionAttributeValues"])
+}
+
+// TestTable_GetItem tests that items are decoded, and that missing items are
+// mapped to dynamostore.ErrNotFound.
+func TestTable_GetItem(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	tbl, e := table(t,
+		response{status: http.StatusOK, body: `{"Item":{"obscured":{"S":"/a"},"original":{"S":"/this/is/the/way"},"max_uses":{"N":"3"},"uses":{"N":"1"}}}`},
+		response{status: http.StatusOK, body: `{}`})
+
+	// action.
+	item, err := tbl.GetItem(ctx, "/a", true)
+	_, missing := tbl.GetItem(ctx, "/b", false)
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, dynamostore.Item{Obscured: "/a", Original: "/this/is/the/way", MaxUses: 3, Uses: 1}, item)
+	assert.Equal(t, dynamostore.ErrNotFound, missing)
+	assert.Equal(t, true, e.requests[0]["ConsistentRead"])
+	assert.Equal(t, map[string]interface{}{"obscured": map[string]interface{}{"S": "/a"}}, e.requests[0]["Key"])
+}
+
+// TestTable_ConsumeItem tests that uses are consumed with a conditional
+// update, and that failed conditions are told apart from missing items.
+func TestTable_ConsumeItem(t *testing.T) {
+	tests := []struct {
+		name     string
+		response response
+		item     dynamostore.Item
+		err      error
+	}{
+		{
+			name:     "Consumed",
+			response: response{status: http.StatusOK, body: `{"Attributes":{"obscured":{"S":"/a"},"original":{"S":"/this/is/the/way"},"max_uses":{"N":"2"},"uses":{"N":"2"}}}`},
+			item:     dynamostore.Item{Obscured: "/a", Original: "/this/is/the/way", MaxUses: 2, Uses: 2},
+		},
+		{
+			name:     "Exhausted",
+			response: conditionFailed(`{"obscured":{"S":"/a"},"max_uses":{"N":"2"},"uses":{"N":"2"}}`),
+			err:      dynamostore.ErrConditionFailed,
+		},
+		{
+			name:     "Missing",
+			response: conditionFailed(""),
+			err:      dynamostore.ErrNotFound,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			tbl, e := table(t, test.response)
+
+			// action.
+			item, err := tbl.ConsumeItem(context.Background(), "/a", time.Unix(1600000000, 0))
+
+			// assert.
+			assert.Equal(t, test.err, err)
+			assert.Equal(t, test.item, item)
+			assert.Equal(t, []string{"UpdateItem"}, e.operations)
+			assert.Equal(t, "ALL_NEW", e.requests[0]["ReturnValues"])
+			assert.Equal(t, "ALL_OLD", e.requests[0]["ReturnValuesOnConditionCheckFailure"])
+		})
+	}
+}
+
+// TestTable_Keys tests that every page of the table is scanned.
+func TestTable_Keys(t *testing.T) {
+	// arrange.
+	tbl, e := table(t,
+		response{status: http.StatusOK, body: `{"Items":[{"obscured":{"S":"/a"}}],"LastEvaluatedKey":{"obscured":{"S":"/a"}}}`},
+		response{status: http.StatusOK, body: `{"Items":[{"obscured":{"S":"/b"}}]}`})
+
+	// action.
+	keys, err := tbl.Keys(context.Background())
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/a", "/b"}, keys)
+	assert.Equal(t, []string{"Scan", "Scan"}, e.operations)
+	assert.Equal(t, map[string]interface{}{"obscured": map[string]interface{}{"S": "/a"}},
+		e.requests[1]["ExclusiveStartKey"])
+}
+
+// TestTable_BatchWriteItem tests that the writes left unprocessed are
+// provided.
+func TestTable_BatchWriteItem(t *testing.T) {
+	// arrange.
+	tbl, _ := table(t, response{status: http.StatusOK, body: `{"UnprocessedItems":{"mappings":[
+		{"PutRequest":{"Item":{"obscured":{"S":"/a"},"original":{"S":"/this/is/the/way"}}}},
+		{"DeleteRequest":{"Key":{"obscured":{"S":"/b"}}}}]}}`})
+	writes := []dynamostore.WriteRequest{
+		{Put: &dynamostore.Item{Obscured: "/a", Original: "/this/is/the/way"}},
+		{Delete: "/b"},
+		{Delete: "/c"},
+	}
+
+	// action.
+	unprocessed, err := tbl.BatchWriteItem(context.Background(), writes)
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, writes[:2], unprocessed)
+}
+
+// TestTable_CreateTable tests that time to live is enabled once the table
+// is active, and that existing tables are reported as such.
+func TestTable_CreateTable(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	tbl, e := table(t,
+		response{status: http.StatusOK, body: `{"TableDescription":{"TableStatus":"CREATING"}}`},
+		response{status: http.StatusOK, body: `{"Table":{"TableStatus":"ACTIVE"}}`},
+		response{status: http.StatusOK, body: `{}`},
+		response{status: http.StatusBadRequest, body: `{"__type":"com.amazonaws.dynamodb.v20120810#ResourceInUseException","message":"Table already exists"}`})
+	s := dynamostore.New(tbl, dynamostore.WithTTL(time.Hour))
+
+	// action.
+	err := s.CreateTable(ctx, tbl)
+	exists := tbl.CreateTable(ctx, s.Definition())
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, dynamostore.ErrTableExists, exists)
+	assert.Equal(t, []string{"CreateTable", "DescribeTable", "UpdateTimeToLive", "CreateTable"}, e.operations)
+	assert.Equal(t, "PAY_PER_REQUEST", e.requests[0]["BillingMode"])
+	assert.Equal(t, map[string]interface{}{"AttributeName": "expires_at", "Enabled": true},
+		e.requests[2]["TimeToLiveSpecification"])
+}
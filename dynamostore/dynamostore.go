@@ -0,0 +1,462 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package dynamostore provides an obscurer.Store backed by an Amazon
+// DynamoDB table.
+//
+// Each mapping is an item keyed by the obscured path, written with a
+// conditional put so that concurrent writers cannot silently overwrite
+// each others mappings. When configured with a TTL, items carry an
+// 'expires_at' attribute holding seconds since the Unix epoch, which the
+// table's time to live setting uses to delete them. As TTL deletion can lag
+// by up to a couple of days, expired items are also treated as missing on
+// read.
+//
+// The store accesses the table through the Table interface, which the
+// dynamosdk module implements with the AWS SDK for Go v2.
+//
+// Mappings placed with a limited number of uses carry a 'max_uses'
+// attribute, and a 'uses' attribute counting the uses consumed. Tables
+// implementing Consumer consume a use with a conditional update, so that
+// concurrent requests can't both consume the last use of a mapping.
+package dynamostore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/freerware/obscurer"
+)
+
+const (
+	// KeyAttribute represents the name of the partition key attribute.
+	KeyAttribute = "obscured"
+	// TTLAttribute represents the name of the attribute holding the
+	// expiration of an item.
+	TTLAttribute = "expires_at"
+)
+
+const (
+	// batchLimit represents the maximum number of writes in a single batch.
+	batchLimit = 25
+	// maxRetries represents the maximum number of times unprocessed writes
+	// are retried.
+	maxRetries = 5
+)
+
+var (
+	// ErrNotFound represents the error returned by a Table when the
+	// requested item doesn't exist.
+	ErrNotFound = errors.New("dynamostore: item not found")
+	// ErrConditionFailed represents the error returned by a Table when a
+	// conditional put fails.
+	ErrConditionFailed = errors.New("dynamostore: conditional check failed")
+	// ErrTableExists represents the error returned by a TableCreator when
+	// the table already exists.
+	ErrTableExists = errors.New("dynamostore: table already exists")
+	// ErrUnprocessed represents an error that occurs when DynamoDB continues
+	// to leave writes of a batch unprocessed after retrying.
+	ErrUnprocessed = errors.New("dynamostore: writes left unprocessed")
+	// ErrNoConsumer represents an error that occurs when consuming a use of
+	// a mapping with a table that isn't a Consumer.
+	ErrNoConsumer = errors.New("dynamostore: table can't consume uses")
+)
+
+// Item represents a stored mapping.
+type Item struct {
+	// Obscured is the path of the obscured URL.
+	Obscured string `dynamodbav:"obscured"`
+	// Original is the original form of the obscured URL.
+	Original string `dynamodbav:"original"`
+	// ExpiresAt is when the mapping expires, in seconds since the Unix
+	// epoch. Zero never expires.
+	ExpiresAt int64 `dynamodbav:"expires_at,omitempty"`
+	// MaxUses is the number of times the mapping resolves. Zero resolves
+	// indefinitely.
+	MaxUses int64 `dynamodbav:"max_uses,omitempty"`
+	// Uses is the number of uses of the mapping consumed.
+	Uses int64 `dynamodbav:"uses,omitempty"`
+}
+
+// exhausted indicates whether the uses of the item have been consumed.
+func (i Item) exhausted() bool {
+	return i.MaxUses > 0 && i.Uses >= i.MaxUses
+}
+
+// WriteRequest represents a single write within a batch, which either puts
+// the provided item or deletes the item with the provided key.
+type WriteRequest struct {
+	Put    *Item
+	Delete string
+}
+
+// Table represents the subset of a DynamoDB table needed by the store.
+type Table interface {
+	// PutItem writes the provided item when no item with the same key
+	// exists, or the existing item expired before the provided time, and
+	// otherwise returns ErrConditionFailed.
+	PutItem(ctx context.Context, item Item, now time.Time) error
+	// GetItem retrieves the item with the provided key, returning
+	// ErrNotFound when it doesn't exist.
+	GetItem(ctx context.Context, key string, consistent bool) (Item, error)
+	// DeleteItem deletes the item with the provided key.
+	DeleteItem(ctx context.Context, key string) error
+	// BatchWriteItem applies the provided writes, returning the writes that
+	// were left unprocessed.
+	BatchWriteItem(ctx context.Context, writes []WriteRequest) ([]WriteRequest, error)
+	// Count counts the items in the table.
+	Count(ctx context.Context) (int, error)
+	// Keys retrieves the keys of all items in the table.
+	Keys(ctx context.Context) ([]string, error)
+}
+
+// Consumer represents the ability to consume the uses of items atomically,
+// which is optional for tables whose mappings resolve indefinitely.
+type Consumer interface {
+	// ConsumeItem increments the uses of the item with the provided key
+	// when uses remain and it hasn't expired before the provided time,
+	// providing the updated item. It returns ErrConditionFailed when no
+	// uses remain or the item expired, and ErrNotFound when the item
+	// doesn't exist.
+	ConsumeItem(ctx context.Context, key string, now time.Time) (Item, error)
+}
+
+// TableDefinition describes the table expected by the store.
+type TableDefinition struct {
+	// KeyAttribute is the name of the string partition key attribute.
+	KeyAttribute string
+	// OnDemand indicates whether the table is billed per request rather
+	// than for provisioned capacity.
+	OnDemand bool
+	// ReadCapacityUnits is the provisioned read capacity, when the table
+	// isn't on-demand.
+	ReadCapacityUnits int64
+	// WriteCapacityUnits is the provisioned write capacity, when the table
+	// isn't on-demand.
+	WriteCapacityUnits int64
+	// TTLAttribute is the name of the attribute time to live is enabled on.
+	// It is empty when mappings don't expire.
+	TTLAttribute string
+}
+
+// TableCreator represents the ability to create a table, which is optional
+// for tables that are provisioned by other means.
+type TableCreator interface {
+	// CreateTable creates the table with the provided definition, and
+	// enables time to live when requested. It returns ErrTableExists when
+	// the table already exists.
+	CreateTable(ctx context.Context, definition TableDefinition) error
+}
+
+// Options represents the configuration options for the store.
+type Options struct {
+	// TTL is the duration mappings are kept for. Zero keeps mappings
+	// indefinitely.
+	TTL time.Duration
+	// ConsistentRead indicates whether reads are strongly consistent.
+	ConsistentRead bool
+	// ReadCapacityUnits is the provisioned read capacity used when creating
+	// the table. Zero creates an on-demand table.
+	ReadCapacityUnits int64
+	// WriteCapacityUnits is the provisioned write capacity used when
+	// creating the table. Zero creates an on-demand table.
+	WriteCapacityUnits int64
+}
+
+// Option applies an option to the provided configuration.
+type Option func(*Options)
+
+// WithTTL configures the duration mappings are kept for.
+func WithTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.TTL = ttl
+	}
+}
+
+// WithConsistentRead configures reads to be strongly consistent, which
+// consumes twice the read capacity of eventually consistent reads.
+func WithConsistentRead() Option {
+	return func(o *Options) {
+		o.ConsistentRead = true
+	}
+}
+
+// WithProvisionedCapacity configures the table to be created with the
+// provided provisioned capacity, rather than on-demand.
+func WithProvisionedCapacity(read, write int64) Option {
+	return func(o *Options) {
+		o.ReadCapacityUnits = read
+		o.WriteCapacityUnits = write
+	}
+}
+
+// Store stores mappings in a DynamoDB table.
+type Store struct {
+	table   Table
+	options Options
+}
+
+// New constructs a store backed by the provided table.
+func New(table Table, opts ...Option) *Store {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &Store{table: table, options: options}
+}
+
+// Definition provides the definition of the table expected by the store.
+func (s *Store) Definition() TableDefinition {
+	d := TableDefinition{
+		KeyAttribute:       KeyAttribute,
+		ReadCapacityUnits:  s.options.ReadCapacityUnits,
+		WriteCapacityUnits: s.options.WriteCapacityUnits,
+	}
+	d.OnDemand = d.ReadCapacityUnits == 0 || d.WriteCapacityUnits == 0
+	if s.options.TTL > 0 {
+		d.TTLAttribute = TTLAttribute
+	}
+	return d
+}
+
+// CreateTable creates the table expected by the store with the provided
+// creator, doing nothing when it already exists.
+func (s *Store) CreateTable(ctx context.Context, creator TableCreator) error {
+	err := creator.CreateTable(ctx, s.Definition())
+	if errors.Is(err, ErrTableExists) {
+		return nil
+	}
+	return err
+}
+
+// item constructs the item for the provided mapping.
+func (s *Store) item(obscured, original *url.URL, now time.Time) Item {
+	item := Item{Obscured: obscured.Path, Original: original.String()}
+	if s.options.TTL > 0 {
+		item.ExpiresAt = now.Add(s.options.TTL).Unix()
+	}
+	return item
+}
+
+// get retrieves the original form of the provided obscured URL.
+func (s *Store) get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	item, err := s.table.GetItem(ctx, obscured.Path, s.options.ConsistentRead)
+	if errors.Is(err, ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if (item.ExpiresAt != 0 && item.ExpiresAt <= time.Now().Unix()) || item.exhausted() {
+		return nil, false, nil
+	}
+	return s.original(item)
+}
+
+// original provides the original form of the URL mapped by the provided
+// item.
+func (s *Store) original(item Item) (*url.URL, bool, error) {
+	original, err := url.Parse(item.Original)
+	if err != nil {
+		return nil, false, err
+	}
+	return original, true, nil
+}
+
+// batchWrite applies the provided writes in batches, retrying unprocessed
+// writes with exponential backoff.
+func (s *Store) batchWrite(ctx context.Context, writes []WriteRequest) error {
+	for len(writes) > 0 {
+		n := len(writes)
+		if n > batchLimit {
+			n = batchLimit
+		}
+		batch := writes[:n]
+		for attempt := 0; len(batch) > 0; attempt++ {
+			if attempt > maxRetries {
+				return fmt.Errorf("%w: %d writes", ErrUnprocessed, len(batch))
+			}
+			if attempt > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After((50 * time.Millisecond) << uint(attempt-1)):
+				}
+			}
+			unprocessed, err := s.table.BatchWriteItem(ctx, batch)
+			if err != nil {
+				return err
+			}
+			batch = unprocessed
+		}
+		writes = writes[n:]
+	}
+	return nil
+}
+
+// Put places the mapping between the provided obscured URL and it's original
+// form into the store. A *obscurer.CollisionError is returned when the
+// obscured URL is already mapped to a URL with a different path.
+func (s *Store) Put(ctx context.Context, obscured, original *url.URL) error {
+	now := time.Now()
+	return s.put(ctx, s.item(obscured, original, now), obscured, original, now)
+}
+
+// PutWithUses places the mapping between the provided obscured URL and it's
+// original form into the store, resolving at most the provided number of
+// times. Consuming its uses requires the table to be a Consumer.
+func (s *Store) PutWithUses(ctx context.Context, obscured, original *url.URL, uses int) error {
+	now := time.Now()
+	item := s.item(obscured, original, now)
+	if uses > 0 {
+		item.MaxUses = int64(uses)
+	}
+	return s.put(ctx, item, obscured, original, now)
+}
+
+// put writes the provided item for the mapping between the provided
+// obscured URL and it's original form.
+func (s *Store) put(ctx context.Context, item Item, obscured, original *url.URL, now time.Time) error {
+	err := s.table.PutItem(ctx, item, now)
+	if !errors.Is(err, ErrConditionFailed) {
+		return err
+	}
+	existing, ok, err := s.get(ctx, obscured)
+	if err != nil || !ok {
+		return err
+	}
+	if existing.Path != original.Path {
+		return &obscurer.CollisionError{
+			Obscured: obscured,
+			Existing: existing,
+			Original: original,
+		}
+	}
+	return nil
+}
+
+// Ping verifies DynamoDB is reachable and the table readable, by getting
+// the item of the root path.
+func (s *Store) Ping(ctx context.Context) error {
+	_, _, err := s.Get(ctx, &url.URL{Path: "/"})
+	return err
+}
+
+// Get retrieves the original form of the provided obscured URL.
+func (s *Store) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	return s.get(ctx, obscured)
+}
+
+// Consume consumes a use of the mapping for the provided obscured URL with a
+// conditional update, deleting the mapping once its last use is consumed.
+// Mappings placed without a limit are retrieved as with Get, and consuming
+// the uses of those placed with one returns ErrNoConsumer unless the table
+// is a Consumer.
+func (s *Store) Consume(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	item, err := s.table.GetItem(ctx, obscured.Path, s.options.ConsistentRead)
+	if errors.Is(err, ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	now := time.Now()
+	if (item.ExpiresAt != 0 && item.ExpiresAt <= now.Unix()) || item.exhausted() {
+		return nil, false, nil
+	}
+	if item.MaxUses == 0 {
+		return s.original(item)
+	}
+	consumer, ok := s.table.(Consumer)
+	if !ok {
+		return nil, false, ErrNoConsumer
+	}
+	item, err = consumer.ConsumeItem(ctx, obscured.Path, now)
+	if errors.Is(err, ErrConditionFailed) || errors.Is(err, ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if item.exhausted() {
+		// the item no longer resolves, so failing to delete it only delays
+		// reclaiming its obscured URL.
+		s.table.DeleteItem(ctx, obscured.Path)
+	}
+	return s.original(item)
+}
+
+// Range invokes the provided function for each unexpired mapping in the
+// store, until the function returns false. Mappings removed while ranging
+// are skipped.
+func (s *Store) Range(ctx context.Context, fn func(obscured, original *url.URL) bool) error {
+	keys, err := s.table.Keys(ctx)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		obscured := &url.URL{Path: key}
+		original, ok, err := s.get(ctx, obscured)
+		if err != nil {
+			return err
+		}
+		if ok && !fn(obscured, original) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Remove deletes the entry in the store for the provided obscured URL.
+func (s *Store) Remove(ctx context.Context, obscured *url.URL) error {
+	return s.table.DeleteItem(ctx, obscured.Path)
+}
+
+// Clear removes all entries in the store.
+func (s *Store) Clear(ctx context.Context) error {
+	keys, err := s.table.Keys(ctx)
+	if err != nil {
+		return err
+	}
+	writes := make([]WriteRequest, 0, len(keys))
+	for _, key := range keys {
+		writes = append(writes, WriteRequest{Delete: key})
+	}
+	return s.batchWrite(ctx, writes)
+}
+
+// Size computes the size of the store.
+func (s *Store) Size(ctx context.Context) int {
+	size, err := s.table.Count(ctx)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// Load loads the store with the provided mappings. The mappings are written
+// in batches, replacing any existing mappings for the same obscured URLs.
+func (s *Store) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	now := time.Now()
+	writes := make([]WriteRequest, 0, mappings.Len())
+	for obscured, original := range mappings.Pointers() {
+		item := s.item(obscured, original, now)
+		writes = append(writes, WriteRequest{Put: &item})
+	}
+	return s.batchWrite(ctx, writes)
+}
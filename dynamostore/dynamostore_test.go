@@ -0,0 +1,394 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dynamostore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/dynamostore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// table is an in-memory table, which leaves the last write of every batch
+// unprocessed when throttled.
+type table struct {
+	mutex     sync.Mutex
+	items     map[string]dynamostore.Item
+	throttled int
+	batches   int
+	created   []dynamostore.TableDefinition
+}
+
+func newTable() *table {
+	return &table{items: make(map[string]dynamostore.Item)}
+}
+
+func (t *table) PutItem(ctx context.Context, item dynamostore.Item, now time.Time) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if existing, ok := t.items[item.Obscured]; ok {
+		if existing.ExpiresAt == 0 || existing.ExpiresAt >= now.Unix() {
+			return dynamostore.ErrConditionFailed
+		}
+	}
+	t.items[item.Obscured] = item
+	return nil
+}
+
+func (t *table) GetItem(ctx context.Context, key string, consistent bool) (dynamostore.Item, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	item, ok := t.items[key]
+	if !ok {
+		return item, dynamostore.ErrNotFound
+	}
+	return item, nil
+}
+
+func (t *table) DeleteItem(ctx context.Context, key string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.items, key)
+	return nil
+}
+
+func (t *table) BatchWriteItem(ctx context.Context, writes []dynamostore.WriteRequest) ([]dynamostore.WriteRequest, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if len(writes) > 25 {
+		return nil, errors.New("too many writes")
+	}
+	t.batches++
+	var unprocessed []dynamostore.WriteRequest
+	if t.throttled > 0 {
+		t.throttled--
+		writes, unprocessed = writes[:len(writes)-1], writes[len(writes)-1:]
+	}
+	for _, w := range writes {
+		if w.Put == nil {
+			delete(t.items, w.Delete)
+			continue
+		}
+		t.items[w.Put.Obscured] = *w.Put
+	}
+	return unprocessed, nil
+}
+
+func (t *table) Count(ctx context.Context) (int, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.items), nil
+}
+
+func (t *table) Keys(ctx context.Context) (keys []string, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for key := range t.items {
+		keys = append(keys, key)
+	}
+	return
+}
+
+func (t *table) ConsumeItem(ctx context.Context, key string, now time.Time) (dynamostore.Item, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	item, ok := t.items[key]
+	if !ok {
+		return item, dynamostore.ErrNotFound
+	}
+	if item.Uses >= item.MaxUses || (item.ExpiresAt != 0 && item.ExpiresAt < now.Unix()) {
+		return item, dynamostore.ErrConditionFailed
+	}
+	item.Uses++
+	t.items[key] = item
+	return item, nil
+}
+
+func (t *table) CreateTable(ctx context.Context, definition dynamostore.TableDefinition) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if len(t.created) > 0 {
+		return dynamostore.ErrTableExists
+	}
+	t.created = append(t.created, definition)
+	return nil
+}
+
+// TestStore tests that mappings can be placed, retrieved, and removed.
+func TestStore(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := dynamostore.New(newTable())
+	obscured, original := mustParse("/abc"), mustParse("http://www.example.com/this/is/the/way")
+
+	// action + assert.
+	require.NoError(t, s.Put(ctx, obscured, original))
+	require.NoError(t, s.Put(ctx, obscured, original))
+	assert.Equal(t, 1, s.Size(ctx))
+	got, ok, err := s.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, original.String(), got.String())
+	require.NoError(t, s.Remove(ctx, obscured))
+	_, ok, err = s.Get(ctx, obscured)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// TestStore_Put_Collision tests that placing a mapping for an obscured URL
+// that already maps to a different URL results in a collision error.
+func TestStore_Put_Collision(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := dynamostore.New(newTable())
+	require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+
+	// action.
+	err := s.Put(ctx, mustParse("/abc"), mustParse("/hey/der"))
+
+	// assert.
+	assert.True(t, errors.Is(err, obscurer.ErrCollision))
+}
+
+// TestStore_TTL tests that mappings carry an expiration, and that expired
+// mappings are treated as missing and can be replaced.
+func TestStore_TTL(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	tbl := newTable()
+	s := dynamostore.New(tbl, dynamostore.WithTTL(time.Hour))
+	require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+	assert.InDelta(t, time.Now().Add(time.Hour).Unix(), tbl.items["/abc"].ExpiresAt, 1)
+	tbl.items["/abc"] = dynamostore.Item{
+		Obscured:  "/abc",
+		Original:  "/this/is/the/way",
+		ExpiresAt: time.Now().Add(-time.Minute).Unix(),
+	}
+
+	// action.
+	_, ok, err := s.Get(ctx, mustParse("/abc"))
+	require.NoError(t, err)
+	err = s.Put(ctx, mustParse("/abc"), mustParse("/hey/der"))
+
+	// assert.
+	assert.False(t, ok)
+	require.NoError(t, err)
+	got, ok, err := s.Get(ctx, mustParse("/abc"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/hey/der", got.String())
+}
+
+// TestStore_Load tests that the store can be loaded in batches, retrying
+// unprocessed writes, and cleared.
+func TestStore_Load(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	tbl := newTable()
+	tbl.throttled = 2
+	s := dynamostore.New(tbl)
+	mappings := make(map[*url.URL]*url.URL)
+	for i := 0; i < 60; i++ {
+		mappings[mustParse(fmt.Sprintf("/%d", i))] = mustParse(fmt.Sprintf("/this/is/the/way/%d", i))
+	}
+
+	// action.
+	err := obscurer.LoadAll(ctx, s, mappings)
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 60, s.Size(ctx))
+	assert.Equal(t, 5, tbl.batches)
+	require.NoError(t, s.Clear(ctx))
+	assert.Equal(t, 0, s.Size(ctx))
+}
+
+// TestStore_Load_Unprocessed tests that writes continually left unprocessed
+// result in an error.
+func TestStore_Load_Unprocessed(t *testing.T) {
+	// arrange.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	tbl := newTable()
+	tbl.throttled = 100
+	s := dynamostore.New(tbl)
+
+	// action.
+	err := obscurer.LoadAll(ctx, s, map[*url.URL]*url.URL{
+		mustParse("/a"): mustParse("/this/is/the/way"),
+	})
+
+	// assert.
+	assert.Error(t, err)
+}
+
+// TestStore_CreateTable tests that the table is created on-demand unless
+// capacity is provisioned, and that existing tables are left alone.
+func TestStore_CreateTable(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []dynamostore.Option
+		expected dynamostore.TableDefinition
+	}{
+		{
+			name: "OnDemand",
+			expected: dynamostore.TableDefinition{
+				KeyAttribute: dynamostore.KeyAttribute,
+				OnDemand:     true,
+			},
+		},
+		{
+			name: "Provisioned",
+			opts: []dynamostore.Option{
+				dynamostore.WithProvisionedCapacity(5, 10),
+				dynamostore.WithTTL(time.Hour),
+			},
+			expected: dynamostore.TableDefinition{
+				KeyAttribute:       dynamostore.KeyAttribute,
+				ReadCapacityUnits:  5,
+				WriteCapacityUnits: 10,
+				TTLAttribute:       dynamostore.TTLAttribute,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			ctx := context.Background()
+			tbl := newTable()
+			s := dynamostore.New(tbl, test.opts...)
+
+			// action.
+			require.NoError(t, s.CreateTable(ctx, tbl))
+			require.NoError(t, s.CreateTable(ctx, tbl))
+
+			// assert.
+			assert.Equal(t, []dynamostore.TableDefinition{test.expected}, tbl.created)
+		})
+	}
+}
+
+// TestStore_Range tests that every mapping is visited until the function
+// returns false.
+func TestStore_Range(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := dynamostore.New(newTable())
+	require.NoError(t, obscurer.LoadAll(ctx, s, map[*url.URL]*url.URL{
+		mustParse("/a"): mustParse("/this/is/the/way"),
+		mustParse("/b"): mustParse("/hey/der"),
+	}))
+	visited := make(map[string]string)
+
+	// action.
+	err := s.Range(ctx, func(obscured, original *url.URL) bool {
+		visited[obscured.Path] = original.String()
+		return true
+	})
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"/a": "/this/is/the/way", "/b": "/hey/der"}, visited)
+	count := 0
+	require.NoError(t, s.Range(ctx, func(obscured, original *url.URL) bool {
+		count++
+		return false
+	}))
+	assert.Equal(t, 1, count, "expected ranging to stop")
+}
+
+// TestStore_Consume tests that concurrent requests consume each use of a
+// mapping at most once, and that exhausted mappings are deleted.
+func TestStore_Consume(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	tbl := newTable()
+	s := dynamostore.New(tbl)
+	require.NoError(t, s.PutWithUses(ctx, mustParse("/abc"), mustParse("/this/is/the/way"), 2))
+	var consumed int64
+	var wg sync.WaitGroup
+
+	// action.
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok, err := s.Consume(ctx, mustParse("/abc")); err == nil && ok {
+				atomic.AddInt64(&consumed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// assert.
+	assert.Equal(t, int64(2), consumed)
+	assert.Empty(t, tbl.items, "expected the exhausted mapping to be deleted")
+}
+
+// TestStore_Consume_Unlimited tests that mappings placed without a limit
+// are retrieved without consuming them, even when the table isn't a
+// Consumer.
+func TestStore_Consume_Unlimited(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := dynamostore.New(struct{ dynamostore.Table }{newTable()})
+	require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+	require.NoError(t, s.PutWithUses(ctx, mustParse("/def"), mustParse("/hey/der"), 1))
+
+	// action.
+	got, ok, err := s.Consume(ctx, mustParse("/abc"))
+	_, _, limitedErr := s.Consume(ctx, mustParse("/def"))
+
+	// assert.
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/this/is/the/way", got.String())
+	assert.Equal(t, dynamostore.ErrNoConsumer, limitedErr)
+}
+
+// TestStore_Get_Exhausted tests that mappings whose uses are consumed are
+// treated as missing.
+func TestStore_Get_Exhausted(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	tbl := newTable()
+	s := dynamostore.New(tbl)
+	tbl.items["/abc"] = dynamostore.Item{Obscured: "/abc", Original: "/this/is/the/way", MaxUses: 1, Uses: 1}
+
+	// action.
+	_, ok, err := s.Get(ctx, mustParse("/abc"))
+
+	// assert.
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func mustParse(str string) *url.URL {
+	u, err := url.Parse(str)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
@@ -0,0 +1,90 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrFailedRequestBody represents an error that occurs when reading the body
+// of a request whose identifiers are decoded.
+var ErrFailedRequestBody = errors.New("obscurer: unable to read request body")
+
+// HandlerError represents a failure of the handler, such as a URL that failed
+// to be looked up in the store, along with the error that caused it.
+type HandlerError struct {
+	// Err describes the failure, such as ErrFailedLookup or
+	// ErrLocationHeaderFailure.
+	Err error
+	// Cause is the error that caused the failure, if any.
+	Cause error
+	// Status is the status code of the response suggested for the failure.
+	Status int
+}
+
+// Error describes the failure and its cause.
+func (e *HandlerError) Error() string {
+	if e.Cause == nil {
+		return e.Err.Error()
+	}
+	return e.Err.Error() + ": " + e.Cause.Error()
+}
+
+// Is indicates if the provided error is the one describing the failure.
+func (e *HandlerError) Is(err error) bool {
+	return err == e.Err
+}
+
+// Unwrap provides the error that caused the failure.
+func (e *HandlerError) Unwrap() error {
+	return e.Cause
+}
+
+// ErrorHandler writes the response for a request the handler failed to
+// handle. The provided error is a *HandlerError.
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// DefaultErrorHandler writes the error describing the failure in plain text,
+// with the status code suggested for it.
+func DefaultErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	var herr *HandlerError
+	if !errors.As(err, &herr) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Error(w, herr.Err.Error(), herr.Status)
+}
+
+// WithErrorHandler configures the handler to write the response for the
+// requests it fails to handle with the provided error handler, such that
+// applications can render their own error format or log failures.
+func WithErrorHandler(eh ErrorHandler) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.ErrorHandler = eh
+	}
+}
+
+// fail writes the response for a request that failed to be handled, which
+// replaces the body of the response, along with its length.
+func (h *handler) fail(w http.ResponseWriter, r *http.Request, err, cause error, status int) {
+	eh := h.options.ErrorHandler
+	if eh == nil {
+		eh = DefaultErrorHandler
+	}
+	w.Header().Del("Content-Length")
+	eh(w, r, &HandlerError{Err: err, Cause: cause, Status: status})
+}
@@ -0,0 +1,106 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/internal/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHandlerError tests that a handler error matches the error describing
+// the failure, and unwraps to its cause.
+func TestHandlerError(t *testing.T) {
+	// arrange.
+	cause := errors.New("whoa")
+	err := error(&obscurer.HandlerError{Err: obscurer.ErrFailedLookup, Cause: cause, Status: http.StatusInternalServerError})
+
+	// action + assert.
+	assert.True(t, errors.Is(err, obscurer.ErrFailedLookup))
+	assert.True(t, errors.Is(err, cause))
+	assert.False(t, errors.Is(err, obscurer.ErrFailedRemoval))
+	assert.Equal(t, obscurer.ErrFailedLookup.Error()+": whoa", err.Error())
+}
+
+// TestHandler_ErrorHandler tests that the response of a request failing to
+// be handled is written by the configured error handler.
+func TestHandler_ErrorHandler(t *testing.T) {
+	// arrange.
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mock.NewStore(ctrl)
+	cause := errors.New("whoa")
+	var handled error
+	eh := func(w http.ResponseWriter, r *http.Request, err error) {
+		handled = err
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusTeapot)
+	}
+	handler := obscurer.NewHandler(obscurer.Default, store, http.NewServeMux(), obscurer.WithErrorHandler(eh))
+	store.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, false, cause)
+	response := httptest.NewRecorder()
+
+	// action.
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/this/is/the/way", nil))
+
+	// assert.
+	assert.Equal(http.StatusTeapot, response.Code)
+	assert.Equal("application/problem+json", response.Header().Get("Content-Type"))
+	assert.True(errors.Is(handled, obscurer.ErrFailedLookup))
+	assert.True(errors.Is(handled, cause))
+	var herr *obscurer.HandlerError
+	if assert.True(errors.As(handled, &herr)) {
+		assert.Equal(http.StatusInternalServerError, herr.Status)
+	}
+}
+
+// TestHandler_ErrorHandler_Header tests that the error handler is provided
+// the failure of a header that fails to be obscured.
+func TestHandler_ErrorHandler_Header(t *testing.T) {
+	// arrange.
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mock.NewStore(ctrl)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/this/is/the/way", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/this/is/another/way")
+		w.WriteHeader(http.StatusFound)
+	})
+	var handled error
+	eh := func(w http.ResponseWriter, r *http.Request, err error) {
+		handled = err
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	handler := obscurer.NewHandler(obscurer.Default, store, mux, obscurer.WithErrorHandler(eh))
+	store.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, false, nil)
+	store.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("whoa"))
+	response := httptest.NewRecorder()
+
+	// action.
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/this/is/the/way", nil))
+
+	// assert.
+	assert.Equal(http.StatusBadGateway, response.Code)
+	assert.True(errors.Is(handled, obscurer.ErrLocationHeaderFailure))
+}
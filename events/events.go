@@ -0,0 +1,251 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package events provides the lifecycle events of mappings, which are
+// emitted by wrapping a store with NewStore and published to a Sink.
+//
+// Events are serialized as the Event message of
+// proto/freerware/obscurer/v1/event.proto, or as its JSON equivalent.
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/freerware/obscurer/internal/protowire"
+)
+
+// ErrMalformed represents an error that occurs when unmarshaling an event
+// that is corrupt.
+var ErrMalformed = errors.New("events: malformed event")
+
+// Type represents the kind of change to the mappings of a store.
+type Type int
+
+const (
+	// TypeUnspecified represents an unknown kind of change.
+	TypeUnspecified Type = iota
+	// TypePut represents a mapping being placed into the store.
+	TypePut
+	// TypeRemoved represents a mapping being removed from the store.
+	TypeRemoved
+	// TypeCleared represents all mappings being removed from the store.
+	TypeCleared
+	// TypeLoaded represents a mapping being loaded into the store.
+	TypeLoaded
+	// TypeCollision represents a mapping being rejected as its obscured URL
+	// is already mapped to a URL with a different path.
+	TypeCollision
+)
+
+// typeNames represents the names of the types, as they appear in the
+// schema.
+var typeNames = map[Type]string{
+	TypeUnspecified: "EVENT_TYPE_UNSPECIFIED",
+	TypePut:         "EVENT_TYPE_PUT",
+	TypeRemoved:     "EVENT_TYPE_REMOVED",
+	TypeCleared:     "EVENT_TYPE_CLEARED",
+	TypeLoaded:      "EVENT_TYPE_LOADED",
+	TypeCollision:   "EVENT_TYPE_COLLISION",
+}
+
+// String provides the name of the type as it appears in the schema.
+func (t Type) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("EVENT_TYPE_%d", int(t))
+}
+
+// Event represents a change to the mappings of a store.
+type Event struct {
+	// ID uniquely identifies the event, allowing consumers to discard
+	// duplicate deliveries.
+	ID string
+	// Type is the kind of change.
+	Type Type
+	// OccurredAt is when the change occurred.
+	OccurredAt time.Time
+	// Obscured is the obscured URL that changed. It is nil for events that
+	// affect the entire store.
+	Obscured *url.URL
+	// Original is the original form of the obscured URL. It is nil for
+	// removals.
+	Original *url.URL
+	// Existing is the original form the obscured URL is already mapped to,
+	// for collisions.
+	Existing *url.URL
+	// Metadata is arbitrary information about the change.
+	Metadata map[string]string
+}
+
+// New constructs an event of the provided type with a random ID, occurring
+// now.
+func New(t Type, obscured, original *url.URL) Event {
+	var id [16]byte
+	rand.Read(id[:])
+	return Event{
+		ID:         hex.EncodeToString(id[:]),
+		Type:       t,
+		OccurredAt: time.Now().UTC(),
+		Obscured:   obscured,
+		Original:   original,
+	}
+}
+
+// Sink receives events.
+type Sink interface {
+	// Publish publishes the provided event.
+	Publish(ctx context.Context, e Event) error
+}
+
+// SinkFunc is an adapter allowing ordinary functions to be used as sinks.
+type SinkFunc func(ctx context.Context, e Event) error
+
+// Publish publishes the provided event.
+func (f SinkFunc) Publish(ctx context.Context, e Event) error {
+	return f(ctx, e)
+}
+
+// urlString provides the string form of the provided URL, which is empty
+// when it is nil.
+func urlString(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	return u.String()
+}
+
+// parseURL parses the provided string, which is nil when it is empty.
+func parseURL(s string) (*url.URL, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return url.Parse(s)
+}
+
+// Marshal serializes the provided event in the protocol buffers wire
+// format.
+func Marshal(e Event) []byte {
+	var occurredAt int64
+	if !e.OccurredAt.IsZero() {
+		occurredAt = e.OccurredAt.UnixNano()
+	}
+	var b []byte
+	b = protowire.AppendString(b, 1, e.ID)
+	b = protowire.AppendVarint(b, 2, uint64(e.Type))
+	b = protowire.AppendVarint(b, 3, uint64(occurredAt))
+	b = protowire.AppendString(b, 4, urlString(e.Obscured))
+	b = protowire.AppendString(b, 5, urlString(e.Original))
+	b = protowire.AppendString(b, 6, urlString(e.Existing))
+	return protowire.AppendMap(b, 7, e.Metadata)
+}
+
+// Unmarshal deserializes the provided event from the protocol buffers wire
+// format.
+func Unmarshal(data []byte) (Event, error) {
+	var e Event
+	var urls [3]string
+	err := protowire.Fields(data, func(field, v uint64, bytes []byte) error {
+		switch field {
+		case 1:
+			e.ID = string(bytes)
+		case 2:
+			e.Type = Type(v)
+		case 3:
+			e.OccurredAt = time.Unix(0, int64(v)).UTC()
+		case 4, 5, 6:
+			urls[field-4] = string(bytes)
+		case 7:
+			key, value, err := protowire.MapEntry(bytes)
+			if err != nil {
+				return err
+			}
+			if e.Metadata == nil {
+				e.Metadata = make(map[string]string)
+			}
+			e.Metadata[key] = value
+		}
+		return nil
+	})
+	if err != nil {
+		return Event{}, ErrMalformed
+	}
+	for i, u := range []**url.URL{&e.Obscured, &e.Original, &e.Existing} {
+		if *u, err = parseURL(urls[i]); err != nil {
+			return Event{}, err
+		}
+	}
+	return e, nil
+}
+
+// jsonEvent represents the JSON form of an event.
+type jsonEvent struct {
+	ID         string            `json:"id"`
+	Type       string            `json:"type"`
+	OccurredAt time.Time         `json:"occurred_at"`
+	Obscured   string            `json:"obscured,omitempty"`
+	Original   string            `json:"original,omitempty"`
+	Existing   string            `json:"existing,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+}
+
+// MarshalJSON serializes the event as JSON.
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonEvent{
+		ID:         e.ID,
+		Type:       e.Type.String(),
+		OccurredAt: e.OccurredAt,
+		Obscured:   urlString(e.Obscured),
+		Original:   urlString(e.Original),
+		Existing:   urlString(e.Existing),
+		Metadata:   e.Metadata,
+	})
+}
+
+// UnmarshalJSON deserializes the event from JSON.
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var j jsonEvent
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	result := Event{ID: j.ID, OccurredAt: j.OccurredAt, Metadata: j.Metadata}
+	for t, name := range typeNames {
+		if name == j.Type {
+			result.Type = t
+		}
+	}
+	var err error
+	for _, u := range []struct {
+		dst **url.URL
+		src string
+	}{
+		{&result.Obscured, j.Obscured},
+		{&result.Original, j.Original},
+		{&result.Existing, j.Existing},
+	} {
+		if *u.dst, err = parseURL(u.src); err != nil {
+			return err
+		}
+	}
+	*e = result
+	return nil
+}
@@ -0,0 +1,104 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package events_test
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/freerware/obscurer/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMarshal tests that events survive being marshaled and unmarshaled.
+func TestMarshal(t *testing.T) {
+	// arrange.
+	e := events.New(events.TypeCollision, mustParse("/abc"), mustParse("/hey/der"))
+	e.Existing = mustParse("/this/is/the/way")
+	e.Metadata = map[string]string{"instance": "mando"}
+
+	// action.
+	got, err := events.Unmarshal(events.Marshal(e))
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, e, got)
+}
+
+// TestMarshal_Schema tests that events are marshaled as described by the
+// published schema.
+func TestMarshal_Schema(t *testing.T) {
+	// arrange.
+	e := events.Event{
+		ID:         "x",
+		Type:       events.TypeRemoved,
+		OccurredAt: time.Unix(0, 1),
+		Obscured:   mustParse("/a"),
+	}
+
+	// action.
+	data := events.Marshal(e)
+
+	// assert.
+	assert.Equal(t, []byte{
+		0x0a, 0x01, 'x', // id = 1.
+		0x10, 0x02, // type = 2.
+		0x18, 0x01, // occurred_at = 3.
+		0x22, 0x02, '/', 'a', // obscured = 4.
+	}, data)
+}
+
+// TestUnmarshal_Malformed tests that unmarshaling corrupt events fails.
+func TestUnmarshal_Malformed(t *testing.T) {
+	// action.
+	_, err := events.Unmarshal([]byte{0x0a, 0x05, 'x'})
+
+	// assert.
+	assert.Equal(t, events.ErrMalformed, err)
+}
+
+// TestEvent_JSON tests that events survive being serialized as JSON.
+func TestEvent_JSON(t *testing.T) {
+	// arrange.
+	e := events.New(events.TypePut, mustParse("/abc"), mustParse("/this/is/the/way"))
+
+	// action.
+	data, err := json.Marshal(e)
+	require.NoError(t, err)
+	var got events.Event
+	err = json.Unmarshal(data, &got)
+
+	// assert.
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"type":"EVENT_TYPE_PUT"`)
+	assert.Equal(t, e.ID, got.ID)
+	assert.Equal(t, e.Type, got.Type)
+	assert.True(t, e.OccurredAt.Equal(got.OccurredAt))
+	assert.Equal(t, e.Obscured, got.Obscured)
+	assert.Equal(t, e.Original, got.Original)
+	assert.Nil(t, got.Existing)
+}
+
+func mustParse(str string) *url.URL {
+	u, err := url.Parse(str)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
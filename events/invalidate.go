@@ -0,0 +1,68 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package events
+
+import (
+	"context"
+	"net/url"
+)
+
+// Cache represents a cache of mappings that can be invalidated, such as a
+// store.Invalidator.
+type Cache interface {
+	// Invalidate removes the mapping for the provided obscured URL from the
+	// cache.
+	Invalidate(obscured *url.URL)
+	// InvalidateAll removes every mapping from the cache.
+	InvalidateAll()
+}
+
+// invalidator invalidates the cached mappings changed by events.
+type invalidator struct {
+	cache Cache
+}
+
+// NewInvalidator constructs a sink invalidating the mappings of the provided
+// cache, such as a store constructed with store.NewTiered, that are removed,
+// cleared, or loaded by the events it receives. Wrapping the store of every
+// instance with NewStore, publishing to a pub/sub topic such as a Redis
+// channel or NATS subject, and publishing the events received from the
+// topic to the invalidator of every instance, ensures that mappings removed
+// by one instance stop being served from the caches of the others:
+//
+//	sub, _ := nc.Subscribe("obscurer.events", func(m *nats.Msg) {
+//		if e, err := events.Unmarshal(m.Data); err == nil {
+//			invalidator.Publish(context.Background(), e)
+//		}
+//	})
+//
+// Events of other types are ignored.
+func NewInvalidator(cache Cache) Sink {
+	return &invalidator{cache: cache}
+}
+
+// Publish invalidates the cached mappings changed by the provided event.
+func (i *invalidator) Publish(ctx context.Context, e Event) error {
+	switch e.Type {
+	case TypeRemoved, TypeLoaded:
+		if e.Obscured != nil {
+			i.cache.Invalidate(e.Obscured)
+		}
+	case TypeCleared:
+		i.cache.InvalidateAll()
+	}
+	return nil
+}
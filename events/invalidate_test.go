@@ -0,0 +1,59 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package events_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/events"
+	"github.com/freerware/obscurer/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNewInvalidator tests that mappings removed or cleared by one instance
+// stop being served from the cache of another.
+func TestNewInvalidator(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	remote := obscurer.DefaultStore
+	t.Cleanup(func() {
+		remote.Clear(ctx)
+	})
+	replica := store.NewTiered(remote).(store.Invalidator)
+	primary := events.NewStore(store.NewTiered(remote), events.NewInvalidator(replica))
+	require.NoError(t, primary.Put(ctx, mustParse("/a"), mustParse("/this/is/the/way")))
+	require.NoError(t, primary.Put(ctx, mustParse("/b"), mustParse("/hey/der")))
+	for _, path := range []string{"/a", "/b"} {
+		_, ok, err := replica.Get(ctx, mustParse(path))
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+
+	// action.
+	require.NoError(t, primary.Remove(ctx, mustParse("/a")))
+	_, removed, err := replica.Get(ctx, mustParse("/a"))
+	require.NoError(t, err)
+	require.NoError(t, primary.Clear(ctx))
+	_, cleared, err := replica.Get(ctx, mustParse("/b"))
+	require.NoError(t, err)
+
+	// assert.
+	assert.False(t, removed)
+	assert.False(t, cleared)
+}
@@ -0,0 +1,144 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package events
+
+import (
+	"context"
+	"errors"
+	"net/url"
+
+	"github.com/freerware/obscurer"
+)
+
+// Options represents the configuration options for the store.
+type Options struct {
+	// Metadata is attached to every event, such as the name of the
+	// instance emitting them.
+	Metadata map[string]string
+	// ErrorHandler is invoked when an event fails to be published. When
+	// nil, such failures are ignored.
+	ErrorHandler func(Event, error)
+}
+
+// Option applies an option to the provided configuration.
+type Option func(*Options)
+
+// WithMetadata configures the provided metadata to be attached to every
+// event.
+func WithMetadata(key, value string) Option {
+	return func(o *Options) {
+		if o.Metadata == nil {
+			o.Metadata = make(map[string]string)
+		}
+		o.Metadata[key] = value
+	}
+}
+
+// WithErrorHandler configures the function invoked when an event fails to
+// be published.
+func WithErrorHandler(fn func(Event, error)) Option {
+	return func(o *Options) {
+		o.ErrorHandler = fn
+	}
+}
+
+// store emits events for the changes made to the underlying store.
+type store struct {
+	obscurer.Store
+
+	sink    Sink
+	options Options
+}
+
+// NewStore constructs a store that publishes an event to the provided sink
+// for every change successfully made to the provided store, and for every
+// collision it rejects.
+//
+// Failing to publish an event doesn't fail the change, as the change has
+// already been made; use WithErrorHandler to be notified of such failures.
+func NewStore(s obscurer.Store, sink Sink, opts ...Option) obscurer.Store {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &store{Store: s, sink: sink, options: options}
+}
+
+// publish publishes the provided event with the configured metadata.
+func (s *store) publish(ctx context.Context, e Event) {
+	if len(s.options.Metadata) > 0 {
+		e.Metadata = make(map[string]string, len(s.options.Metadata))
+		for key, value := range s.options.Metadata {
+			e.Metadata[key] = value
+		}
+	}
+	if err := s.sink.Publish(ctx, e); err != nil && s.options.ErrorHandler != nil {
+		s.options.ErrorHandler(e, err)
+	}
+}
+
+// Put places the mapping into the underlying store, publishing a put event,
+// or a collision event when the mapping is rejected.
+func (s *store) Put(ctx context.Context, obscured, original *url.URL) error {
+	err := s.Store.Put(ctx, obscured, original)
+	var collision *obscurer.CollisionError
+	switch {
+	case err == nil:
+		s.publish(ctx, New(TypePut, obscured, original))
+	case errors.As(err, &collision):
+		e := New(TypeCollision, obscured, original)
+		e.Existing = collision.Existing
+		s.publish(ctx, e)
+	}
+	return err
+}
+
+// Remove deletes the entry from the underlying store, publishing a removed
+// event.
+func (s *store) Remove(ctx context.Context, obscured *url.URL) error {
+	err := s.Store.Remove(ctx, obscured)
+	if err == nil {
+		s.publish(ctx, New(TypeRemoved, obscured, nil))
+	}
+	return err
+}
+
+// Clear removes all entries from the underlying store, publishing a cleared
+// event.
+func (s *store) Clear(ctx context.Context) error {
+	err := s.Store.Clear(ctx)
+	if err == nil {
+		s.publish(ctx, New(TypeCleared, nil, nil))
+	}
+	return err
+}
+
+// Load loads the underlying store, publishing a loaded event for every
+// mapping.
+func (s *store) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	err := s.Store.Load(ctx, mappings)
+	if err == nil {
+		for obscured, original := range mappings.Pointers() {
+			s.publish(ctx, New(TypeLoaded, obscured, original))
+		}
+	}
+	return err
+}
+
+// Ping verifies the backend of the underlying store is reachable.
+func (s *store) Ping(ctx context.Context) error {
+	return obscurer.Ping(ctx, s.Store)
+}
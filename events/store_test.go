@@ -0,0 +1,194 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package events_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/events"
+	"github.com/freerware/obscurer/internal/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder records the events published to it.
+type recorder struct {
+	events []events.Event
+	err    error
+}
+
+func (r *recorder) Publish(ctx context.Context, e events.Event) error {
+	r.events = append(r.events, e)
+	return r.err
+}
+
+// TestStore_Put tests that a put event is published for placed mappings.
+func TestStore_Put(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	underlying, sink := mock.NewStore(ctrl), &recorder{}
+	obscured, original := mustParse("/abc"), mustParse("/this/is/the/way")
+	underlying.EXPECT().Put(ctx, obscured, original).Return(nil)
+	s := events.NewStore(underlying, sink, events.WithMetadata("instance", "mando"))
+
+	// action.
+	err := s.Put(ctx, obscured, original)
+
+	// assert.
+	require.NoError(t, err)
+	require.Len(t, sink.events, 1)
+	assert.Equal(t, events.TypePut, sink.events[0].Type)
+	assert.Equal(t, obscured, sink.events[0].Obscured)
+	assert.Equal(t, original, sink.events[0].Original)
+	assert.Equal(t, map[string]string{"instance": "mando"}, sink.events[0].Metadata)
+	assert.NotEmpty(t, sink.events[0].ID)
+}
+
+// TestStore_Put_Collision tests that a collision event is published for
+// rejected mappings.
+func TestStore_Put_Collision(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	underlying, sink := mock.NewStore(ctrl), &recorder{}
+	obscured, original, existing := mustParse("/abc"), mustParse("/hey/der"), mustParse("/this/is/the/way")
+	collision := &obscurer.CollisionError{Obscured: obscured, Existing: existing, Original: original}
+	underlying.EXPECT().Put(ctx, obscured, original).Return(collision)
+	s := events.NewStore(underlying, sink)
+
+	// action.
+	err := s.Put(ctx, obscured, original)
+
+	// assert.
+	assert.Equal(t, collision, err)
+	require.Len(t, sink.events, 1)
+	assert.Equal(t, events.TypeCollision, sink.events[0].Type)
+	assert.Equal(t, existing, sink.events[0].Existing)
+}
+
+// TestStore_Error tests that no events are published for failed changes.
+func TestStore_Error(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	underlying, sink := mock.NewStore(ctrl), &recorder{}
+	expectedErr := errors.New("whoa")
+	underlying.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(expectedErr)
+	underlying.EXPECT().Remove(gomock.Any(), gomock.Any()).Return(expectedErr)
+	underlying.EXPECT().Clear(gomock.Any()).Return(expectedErr)
+	underlying.EXPECT().Load(gomock.Any(), gomock.Any()).Return(expectedErr)
+	s := events.NewStore(underlying, sink)
+
+	// action.
+	errs := []error{
+		s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")),
+		s.Remove(ctx, mustParse("/abc")),
+		s.Clear(ctx),
+		obscurer.LoadAll(ctx, s, map[*url.URL]*url.URL{mustParse("/abc"): mustParse("/this/is/the/way")}),
+	}
+
+	// assert.
+	assert.Equal(t, []error{expectedErr, expectedErr, expectedErr, expectedErr}, errs)
+	assert.Empty(t, sink.events)
+}
+
+// TestStore_Lifecycle tests that removed, cleared, and loaded events are
+// published.
+func TestStore_Lifecycle(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	underlying, sink := mock.NewStore(ctrl), &recorder{}
+	underlying.EXPECT().Load(gomock.Any(), gomock.Any()).Return(nil)
+	underlying.EXPECT().Remove(gomock.Any(), gomock.Any()).Return(nil)
+	underlying.EXPECT().Clear(gomock.Any()).Return(nil)
+	s := events.NewStore(underlying, sink)
+
+	// action.
+	require.NoError(t, obscurer.LoadAll(ctx, s, map[*url.URL]*url.URL{
+		mustParse("/a"): mustParse("/this/is/the/way"),
+		mustParse("/b"): mustParse("/hey/der"),
+	}))
+	require.NoError(t, s.Remove(ctx, mustParse("/a")))
+	require.NoError(t, s.Clear(ctx))
+
+	// assert.
+	var types []events.Type
+	for _, e := range sink.events {
+		types = append(types, e.Type)
+	}
+	assert.Equal(t, []events.Type{
+		events.TypeLoaded, events.TypeLoaded, events.TypeRemoved, events.TypeCleared,
+	}, types)
+}
+
+// TestStore_PublishError tests that failing to publish an event is reported
+// without failing the change.
+func TestStore_PublishError(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	underlying, sink := mock.NewStore(ctrl), &recorder{err: errors.New("whoa")}
+	underlying.EXPECT().Remove(gomock.Any(), gomock.Any()).Return(nil)
+	var reported error
+	s := events.NewStore(underlying, sink, events.WithErrorHandler(func(e events.Event, err error) {
+		reported = err
+	}))
+
+	// action.
+	err := s.Remove(ctx, mustParse("/abc"))
+
+	// assert.
+	assert.NoError(t, err)
+	assert.Equal(t, sink.err, reported)
+}
+
+// pinging is a store whose backend is reachable unless err is set.
+type pinging struct {
+	obscurer.Store
+	err error
+}
+
+func (s *pinging) Ping(ctx context.Context) error {
+	return s.err
+}
+
+// TestStore_Ping tests that pings are forwarded to the underlying store.
+func TestStore_Ping(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	underlying := &pinging{Store: mock.NewStore(ctrl), err: errors.New("whoa")}
+	s := events.NewStore(underlying, &recorder{})
+
+	// action.
+	err := s.(obscurer.Pinger).Ping(ctx)
+
+	// assert.
+	assert.Equal(t, underlying.err, err)
+}
@@ -47,7 +47,7 @@ func Example_http() {
 	// load the store.
 	ctx := context.Background()
 	store := obscurer.DefaultStore
-	store.Load(ctx, map[*url.URL]*url.URL{obscured: original})
+	obscurer.LoadAll(ctx, store, map[*url.URL]*url.URL{obscured: original})
 
 	// issue the request.
 	http.Get(obscured.String())
@@ -75,7 +75,7 @@ func Example_gorilla() {
 	// load the store.
 	ctx := context.Background()
 	store := obscurer.DefaultStore
-	store.Load(ctx, map[*url.URL]*url.URL{obscured: original})
+	obscurer.LoadAll(ctx, store, map[*url.URL]*url.URL{obscured: original})
 
 	// issue the request.
 	http.Get(obscured.String())
@@ -103,7 +103,7 @@ func Example_gin() {
 	// load the store.
 	ctx := context.Background()
 	store := obscurer.DefaultStore
-	store.Load(ctx, map[*url.URL]*url.URL{obscured: original})
+	obscurer.LoadAll(ctx, store, map[*url.URL]*url.URL{obscured: original})
 
 	// issue the request.
 	http.Get(obscured.String())
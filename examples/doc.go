@@ -0,0 +1,29 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package examples holds runnable programs demonstrating each of the major
+// ways of integrating obscured URLs, each of which is its own command:
+//
+//	handler     wraps an http.ServeMux with obscurer.NewHandler
+//	middleware  composes the handler as middleware within a chain
+//	proxy       obscures the URLs of another service behind a reverse proxy
+//	stateless   resolves route templates without storing mappings
+//	store       persists mappings in bbolt, cached in memory
+//
+// Each program listens on the address provided with -addr, which defaults
+// to ':8080', and logs an obscured URL to request. For example:
+//
+//	go run ./examples/handler -addr :8080
+package examples
@@ -0,0 +1,65 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Command handler serves an http.ServeMux wrapped with obscurer.NewHandler,
+// such that its resources are requested by obscured URLs, and the URLs
+// within its response headers are obscured.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/freerware/obscurer"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "the address to listen on")
+	flag.Parse()
+
+	// create your mux.
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orders", func(w http.ResponseWriter, r *http.Request) {
+		// the 'Location' header is obscured before the response is written.
+		w.Header().Set("Location", "/orders/42")
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("/orders/42", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "order 42")
+	})
+
+	// add obscured URL support.
+	handler := obscurer.NewHandler(
+		obscurer.Default,
+		obscurer.DefaultStore,
+		mux,
+		obscurer.WithOverheadReporting(),
+	)
+
+	// load the store with the mappings of the entry points.
+	original := &url.URL{Path: "/orders"}
+	obscured := obscurer.Default.Obscure(original)
+	var mappings obscurer.Mappings
+	mappings.Add(obscured, original)
+	if err := obscurer.DefaultStore.Load(context.Background(), mappings); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("POST http://localhost%s%s to create an order", *addr, obscured)
+	log.Fatal(http.ListenAndServe(*addr, handler))
+}
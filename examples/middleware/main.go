@@ -0,0 +1,77 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Command middleware composes the obscuring handler as middleware within a
+// chain of other middleware, configured through its options.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/freerware/obscurer"
+)
+
+// middleware decorates a handler.
+type middleware func(http.Handler) http.Handler
+
+// chain applies the provided middleware to the provided handler, such that
+// the first middleware handles requests first.
+func chain(h http.Handler, m ...middleware) http.Handler {
+	for i := len(m) - 1; i >= 0; i-- {
+		h = m[i](h)
+	}
+	return h
+}
+
+// logging logs each request, as it was requested.
+func logging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		log.Printf("%s %s (%s)", r.Method, r.URL, time.Since(start))
+	})
+}
+
+func main() {
+	addr := flag.String("addr", ":8080", "the address to listen on")
+	flag.Parse()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/this/is/the/way", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `</hey/der>; rel="next"`)
+		fmt.Fprintln(w, "this is the way")
+	})
+	observe := func(ctx context.Context, header string, outcome obscurer.HeaderOutcome) {
+		log.Printf("%s header %s", header, outcome)
+	}
+	handler := chain(mux,
+		logging,
+		obscurer.NewMiddleware(obscurer.WithTTL(time.Hour), obscurer.WithHeaderObserver(observe)),
+	)
+
+	original := &url.URL{Path: "/this/is/the/way"}
+	obscured := obscurer.Default.Obscure(original)
+	if err := obscurer.DefaultStore.Put(context.Background(), obscured, original); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("GET http://localhost%s%s", *addr, obscured)
+	log.Fatal(http.ListenAndServe(*addr, handler))
+}
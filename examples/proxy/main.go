@@ -0,0 +1,47 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Command proxy serves another service through a reverse proxy wrapped with
+// obscurer.NewHandler, such that the URLs of the service are obscured
+// without changing it.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/freerware/obscurer"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "the address to listen on")
+	upstream := flag.String("upstream", "http://localhost:9090", "the URL of the proxied service")
+	flag.Parse()
+
+	target, err := url.Parse(*upstream)
+	if err != nil {
+		log.Fatal(err)
+	}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+
+	// requests are resolved before they are proxied, and the URLs within
+	// the response headers of the service are obscured on the way back.
+	handler := obscurer.NewHandler(obscurer.Default, obscurer.DefaultStore, proxy)
+	log.Printf("proxying http://localhost%s to %s", *addr, target)
+	log.Fatal(http.ListenAndServe(*addr, handler))
+}
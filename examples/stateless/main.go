@@ -0,0 +1,48 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Command stateless obscures URLs matching route templates with an
+// obscurer.RouteObscurer, which resolves them without the store, such that
+// no mappings are stored for them and every instance resolves them alike.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/freerware/obscurer"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "the address to listen on")
+	flag.Parse()
+
+	o, err := obscurer.NewRouteObscurer(obscurer.Default, "/users/{id}/orders/{orderID}")
+	if err != nil {
+		log.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "resolved %s\n", r.URL.Path)
+	})
+	handler := obscurer.NewHandler(o, obscurer.NewMemoryStore(), mux)
+
+	obscured := o.Obscure(&url.URL{Path: "/users/7/orders/42"})
+	log.Printf("GET http://localhost%s%s", *addr, obscured)
+	log.Fatal(http.ListenAndServe(*addr, handler))
+}
@@ -0,0 +1,64 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Command store persists mappings in a bbolt database, cached in memory by
+// a tiered store, such that obscured URLs survive restarts. Remote stores,
+// such as sqlstore or dynamostore, are configured likewise.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/boltstore"
+	"github.com/freerware/obscurer/store"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "the address to listen on")
+	path := flag.String("db", "obscurer.db", "the path of the bbolt database")
+	flag.Parse()
+
+	db, err := boltstore.Open(*path, 0600)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	s := store.NewTiered(db, store.WithCacheTTL(time.Minute))
+	if err := obscurer.Validate(obscurer.Default, s); err != nil {
+		log.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/this/is/the/way", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "this is the way")
+	})
+	http.Handle("/", obscurer.NewHandler(obscurer.Default, s, mux))
+	http.Handle("/healthz", obscurer.Healthz(db))
+
+	original := &url.URL{Path: "/this/is/the/way"}
+	obscured := obscurer.Default.Obscure(original)
+	if err := s.Put(context.Background(), obscured, original); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("GET http://localhost%s%s", *addr, obscured)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
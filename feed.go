@@ -0,0 +1,53 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"mime"
+	"strings"
+)
+
+// feedMarkup describes where the URLs of Atom and RSS feeds are held.
+var feedMarkup = markup{
+	attributes: map[string]bool{
+		"href": true,
+	},
+	elements: map[string]bool{
+		"link": true,
+		"id":   true,
+	},
+}
+
+// WithFeedRewriting configures the handler to obscure the URLs held by the
+// 'href' attributes, and the 'link' and 'id' elements, of XML response
+// bodies, such as Atom and RSS feeds, that point at paths of the host of the
+// request, such that syndicated content doesn't leak original URLs. Bodies
+// are buffered until complete.
+func WithFeedRewriting() HandlerOption {
+	return func(o *HandlerOptions) {
+		o.RewriteFeeds = true
+	}
+}
+
+// isXML indicates whether the provided media type is XML, including the
+// structured syntax suffix, such as 'application/atom+xml'. XHTML is HTML.
+func isXML(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "application/xhtml+xml" {
+		return false
+	}
+	return mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml")
+}
@@ -0,0 +1,95 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHandler_FeedRewriting tests that the URLs of Atom and RSS feeds are
+// obscured when they point at the host of the request.
+func TestHandler_FeedRewriting(t *testing.T) {
+	obscure := func(path string) string {
+		return obscurer.Default.Obscure(mustParse(path)).String()
+	}
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		expected    string
+	}{
+		{
+			name:        "Atom",
+			contentType: "application/atom+xml",
+			body: `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <link href="http://example.com/posts" rel="self"/>
+  <id>urn:uuid:60a76c80-d399-11d9-b93C-0003939e0af6</id>
+  <entry>
+    <link href="https://freerware.com/about"/>
+    <id> http://example.com/posts/42 </id>
+    <title>a &lt;b&gt; post</title>
+  </entry>
+</feed>`,
+			expected: `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <link href="` + obscure("http://example.com/posts") + `" rel="self"/>
+  <id>urn:uuid:60a76c80-d399-11d9-b93C-0003939e0af6</id>
+  <entry>
+    <link href="https://freerware.com/about"/>
+    <id> ` + obscure("http://example.com/posts/42") + ` </id>
+    <title>a &lt;b&gt; post</title>
+  </entry>
+</feed>`,
+		},
+		{
+			name:        "RSS",
+			contentType: "application/rss+xml; charset=utf-8",
+			body: `<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel>` +
+				`<atom:link href="/feed" rel="self"/>` +
+				`<link>http://example.com/</link>` +
+				`<item><link><![CDATA[http://example.com/posts/42?a=1&b=2]]></link><description><![CDATA[<a href="/posts/42">]]></description></item>` +
+				`</channel></rss>`,
+			expected: `<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"><channel>` +
+				`<atom:link href="` + obscure("/feed") + `" rel="self"/>` +
+				`<link>` + obscure("http://example.com/") + `</link>` +
+				`<item><link><![CDATA[` + obscure("http://example.com/posts/42?a=1&b=2") + `]]></link><description><![CDATA[<a href="/posts/42">]]></description></item>` +
+				`</channel></rss>`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", test.contentType)
+				w.Write([]byte(test.body))
+			})
+			handler := obscurer.NewHandler(obscurer.Default, obscurer.NewMemoryStore(), h, obscurer.WithFeedRewriting())
+			response := httptest.NewRecorder()
+
+			// action.
+			handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/feed", nil))
+
+			// assert.
+			assert.Equal(t, test.expected, response.Body.String())
+		})
+	}
+}
@@ -0,0 +1,141 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filestore
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// encryptedMagic prefixes the encrypted snapshot files, followed by the
+// nonce and the sealed snapshot.
+var encryptedMagic = []byte("obscurer-aes-gcm-v1\n")
+
+var (
+	// ErrNoKey represents an error that occurs when the key snapshots are
+	// encrypted with isn't available.
+	ErrNoKey = errors.New("filestore: no encryption key")
+	// ErrEncrypted represents an error that occurs when opening a store
+	// whose snapshot is encrypted without configuring the key.
+	ErrEncrypted = errors.New("filestore: snapshot is encrypted")
+	// ErrUnencrypted represents an error that occurs when opening a store
+	// configured with a key whose snapshot isn't encrypted.
+	ErrUnencrypted = errors.New("filestore: snapshot is not encrypted")
+)
+
+// KeyFunc provides the AES key snapshots are encrypted with, which must be
+// 16, 24, or 32 bytes long. It is invoked each time a snapshot is read or
+// written, so that keys fetched from, or unwrapped by, a key management
+// service can be rotated.
+type KeyFunc func(ctx context.Context) ([]byte, error)
+
+// KeyFromEnv provides a KeyFunc reading the base64 encoded key from the
+// environment variable with the provided name.
+func KeyFromEnv(name string) KeyFunc {
+	return func(ctx context.Context) ([]byte, error) {
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%w: %s is not set", ErrNoKey, name)
+		}
+		return base64.StdEncoding.DecodeString(value)
+	}
+}
+
+// WithEncryption configures snapshots to be encrypted at rest with AES-GCM,
+// using the key provided by the provided function. Unencrypted snapshots
+// are rejected, unless WithPlaintextMigration is also configured.
+func WithEncryption(key KeyFunc) Option {
+	return func(o *Options) {
+		o.Key = key
+	}
+}
+
+// WithPlaintextMigration configures unencrypted snapshots to remain readable
+// once encryption is configured, so that they are encrypted once the store is
+// next snapshotted. It should only be configured while migrating, since it
+// allows a tampered snapshot to be swapped for a plaintext one.
+func WithPlaintextMigration() Option {
+	return func(o *Options) {
+		o.PlaintextMigration = true
+	}
+}
+
+// aead provides the AES-GCM cipher with the configured key.
+func (s *Store) aead() (cipher.AEAD, error) {
+	key, err := s.options.Key(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the provided snapshot when encryption is configured.
+func (s *Store) seal(data []byte) ([]byte, error) {
+	if s.options.Key == nil {
+		return data, nil
+	}
+	aead, err := s.aead()
+	if err != nil {
+		return nil, err
+	}
+	sealed := make([]byte, len(encryptedMagic)+aead.NonceSize(), len(encryptedMagic)+aead.NonceSize()+len(data)+aead.Overhead())
+	copy(sealed, encryptedMagic)
+	nonce := sealed[len(encryptedMagic):]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	// the magic is authenticated alongside the snapshot.
+	return aead.Seal(sealed, nonce, data, encryptedMagic), nil
+}
+
+// unseal decrypts the provided snapshot when it is encrypted.
+func (s *Store) unseal(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptedMagic) {
+		if s.options.Key != nil && !s.options.PlaintextMigration {
+			return nil, fmt.Errorf("%w: %q", ErrUnencrypted, s.path)
+		}
+		return data, nil
+	}
+	if s.options.Key == nil {
+		return nil, ErrEncrypted
+	}
+	aead, err := s.aead()
+	if err != nil {
+		return nil, err
+	}
+	data = data[len(encryptedMagic):]
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("filestore: malformed snapshot %q: truncated", s.path)
+	}
+	nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, encryptedMagic)
+	if err != nil {
+		return nil, fmt.Errorf("filestore: unable to decrypt snapshot %q: %w", s.path, err)
+	}
+	return plain, nil
+}
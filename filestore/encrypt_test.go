@@ -0,0 +1,150 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filestore_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/freerware/obscurer/filestore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// key provides the provided key.
+func key(k []byte) filestore.KeyFunc {
+	return func(ctx context.Context) ([]byte, error) {
+		return k, nil
+	}
+}
+
+// TestStore_Encryption tests that snapshots are encrypted at rest, and that
+// mappings survive the store being reopened with the key.
+func TestStore_Encryption(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	p := path(t)
+	k := bytes.Repeat([]byte{7}, 32)
+	s, err := filestore.Open(p, filestore.WithInterval(0), filestore.WithEncryption(key(k)))
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+
+	// action.
+	err = s.Close()
+
+	// assert.
+	require.NoError(t, err)
+	data, err := ioutil.ReadFile(p)
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(data, []byte("/this/is/the/way")))
+	reopened, err := filestore.Open(p, filestore.WithInterval(0), filestore.WithEncryption(key(k)))
+	require.NoError(t, err)
+	defer reopened.Close()
+	got, ok, err := reopened.Get(ctx, mustParse("/abc"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/this/is/the/way", got.String())
+}
+
+// TestStore_Encryption_Key tests that encrypted snapshots fail to be opened
+// without the key they were encrypted with.
+func TestStore_Encryption_Key(t *testing.T) {
+	// arrange.
+	p := path(t)
+	s, err := filestore.Open(p, filestore.WithInterval(0), filestore.WithEncryption(key(bytes.Repeat([]byte{7}, 16))))
+	require.NoError(t, err)
+	require.NoError(t, s.Put(context.Background(), mustParse("/abc"), mustParse("/this/is/the/way")))
+	require.NoError(t, s.Close())
+
+	// action.
+	_, withoutKey := filestore.Open(p, filestore.WithInterval(0))
+	_, wrongKey := filestore.Open(p, filestore.WithInterval(0), filestore.WithEncryption(key(bytes.Repeat([]byte{8}, 16))))
+
+	// assert.
+	assert.True(t, errors.Is(withoutKey, filestore.ErrEncrypted))
+	assert.Error(t, wrongKey)
+}
+
+// TestStore_Encryption_Plain tests that unencrypted snapshots are rejected
+// once encryption is configured.
+func TestStore_Encryption_Plain(t *testing.T) {
+	// arrange.
+	p := path(t)
+	s, err := filestore.Open(p, filestore.WithInterval(0))
+	require.NoError(t, err)
+	require.NoError(t, s.Put(context.Background(), mustParse("/abc"), mustParse("/this/is/the/way")))
+	require.NoError(t, s.Close())
+
+	// action.
+	_, err = filestore.Open(p, filestore.WithInterval(0), filestore.WithEncryption(key(bytes.Repeat([]byte{7}, 32))))
+
+	// assert.
+	assert.True(t, errors.Is(err, filestore.ErrUnencrypted))
+}
+
+// TestStore_Encryption_PlaintextMigration tests that unencrypted snapshots
+// remain readable while migrating, and are encrypted once snapshotted.
+func TestStore_Encryption_PlaintextMigration(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	p := path(t)
+	s, err := filestore.Open(p, filestore.WithInterval(0))
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+	require.NoError(t, s.Close())
+
+	// action.
+	reopened, err := filestore.Open(
+		p,
+		filestore.WithInterval(0),
+		filestore.WithEncryption(key(bytes.Repeat([]byte{7}, 32))),
+		filestore.WithPlaintextMigration(),
+	)
+
+	// assert.
+	require.NoError(t, err)
+	_, ok, err := reopened.Get(ctx, mustParse("/abc"))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	require.NoError(t, reopened.Close())
+	_, err = filestore.Open(p, filestore.WithInterval(0), filestore.WithEncryption(key(bytes.Repeat([]byte{7}, 32))))
+	assert.NoError(t, err)
+}
+
+// TestKeyFromEnv tests that keys are read from the environment.
+func TestKeyFromEnv(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	k := bytes.Repeat([]byte{7}, 32)
+	os.Setenv("OBSCURER_TEST_KEY", base64.StdEncoding.EncodeToString(k))
+	t.Cleanup(func() {
+		os.Unsetenv("OBSCURER_TEST_KEY")
+	})
+
+	// action.
+	got, err := filestore.KeyFromEnv("OBSCURER_TEST_KEY")(ctx)
+	_, missing := filestore.KeyFromEnv("OBSCURER_TEST_MISSING_KEY")(ctx)
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, k, got)
+	assert.True(t, errors.Is(missing, filestore.ErrNoKey))
+}
@@ -0,0 +1,416 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package filestore provides an in-memory obscurer.Store that periodically
+// snapshots its contents to a file and reloads them when opened, so that
+// obscured URLs survive restarts of the process.
+//
+// Snapshots are written to a temporary file in the same directory, which is
+// then renamed over the previous snapshot, so that a crash while writing
+// never leaves a truncated snapshot behind. Mappings made after the latest
+// snapshot are lost when the process exits without closing the store.
+//
+// Snapshots can be encrypted at rest with AES-GCM using WithEncryption, with
+// the key read from the environment with KeyFromEnv, or provided by a key
+// management service.
+package filestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/freerware/obscurer"
+)
+
+// snapshotVersion represents the version of the snapshot layout.
+const snapshotVersion = 1
+
+// ErrClosed represents an error that occurs when snapshotting a store that
+// has been closed.
+var ErrClosed = errors.New("filestore: store is closed")
+
+// Format represents the serialization of snapshots.
+type Format int
+
+const (
+	// FormatJSON serializes snapshots as JSON.
+	FormatJSON Format = iota
+	// FormatGob serializes snapshots with encoding/gob.
+	FormatGob
+)
+
+// snapshot represents the contents of a snapshot file.
+type snapshot struct {
+	Version  int               `json:"version"`
+	Mappings map[string]string `json:"mappings"`
+}
+
+// Options represents the configuration options for the store.
+type Options struct {
+	// Interval is the amount of time between snapshots. Snapshots are only
+	// written when the store has changed since the previous one. Zero
+	// disables periodic snapshots, leaving them to Snapshot and Close.
+	Interval time.Duration
+	// Format is the serialization of snapshots.
+	Format Format
+	// Mode is the permission bits of the snapshot file.
+	Mode os.FileMode
+	// ErrorHandler is invoked when a periodic snapshot fails. When nil, such
+	// failures are ignored, and retried at the next interval.
+	ErrorHandler func(error)
+	// Key provides the key snapshots are encrypted with. When nil,
+	// snapshots are written unencrypted.
+	Key KeyFunc
+	// PlaintextMigration indicates whether unencrypted snapshots are read
+	// when a key is configured, rather than rejected.
+	PlaintextMigration bool
+	// KeyStrategy is the strategy the keys of mappings are derived with
+	// from their obscured URLs.
+	KeyStrategy obscurer.KeyStrategy
+}
+
+// Option applies an option to the provided configuration.
+type Option func(*Options)
+
+// WithKeyStrategy configures the strategy the keys of mappings are derived
+// with from their obscured URLs.
+func WithKeyStrategy(k obscurer.KeyStrategy) Option {
+	return func(o *Options) {
+		o.KeyStrategy = k
+	}
+}
+
+// WithInterval configures the amount of time between snapshots.
+func WithInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.Interval = interval
+	}
+}
+
+// WithFormat configures the serialization of snapshots.
+func WithFormat(format Format) Option {
+	return func(o *Options) {
+		o.Format = format
+	}
+}
+
+// WithMode configures the permission bits of the snapshot file.
+func WithMode(mode os.FileMode) Option {
+	return func(o *Options) {
+		o.Mode = mode
+	}
+}
+
+// WithErrorHandler configures the function invoked when a periodic snapshot
+// fails.
+func WithErrorHandler(fn func(error)) Option {
+	return func(o *Options) {
+		o.ErrorHandler = fn
+	}
+}
+
+// Store stores mappings in memory and snapshots them to a file.
+type Store struct {
+	path    string
+	options Options
+
+	mutex    sync.RWMutex
+	mappings map[string]url.URL
+	dirty    bool
+
+	// snapshotMutex serializes writing snapshots.
+	snapshotMutex sync.Mutex
+	closed        bool
+	done          chan struct{}
+	wg            sync.WaitGroup
+}
+
+// Open opens the store snapshotted to the provided path, loading the
+// mappings of the existing snapshot if any.
+func Open(path string, opts ...Option) (*Store, error) {
+	options := Options{Interval: time.Minute, Mode: 0600}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	s := &Store{
+		path:     path,
+		options:  options,
+		mappings: make(map[string]url.URL),
+		done:     make(chan struct{}),
+	}
+	if err := s.restore(); err != nil {
+		return nil, err
+	}
+	if options.Interval > 0 {
+		s.wg.Add(1)
+		go s.run()
+	}
+	return s, nil
+}
+
+// restore loads the mappings of the snapshot file, if it exists.
+func (s *Store) restore() error {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if data, err = s.unseal(data); err != nil {
+		return err
+	}
+	var snap snapshot
+	switch s.options.Format {
+	case FormatGob:
+		err = gob.NewDecoder(bytes.NewReader(data)).Decode(&snap)
+	default:
+		err = json.Unmarshal(data, &snap)
+	}
+	if err != nil {
+		return fmt.Errorf("filestore: malformed snapshot %q: %w", s.path, err)
+	}
+	if snap.Version != snapshotVersion {
+		return fmt.Errorf("filestore: unsupported snapshot version %d", snap.Version)
+	}
+	for obscured, original := range snap.Mappings {
+		u, err := url.Parse(original)
+		if err != nil {
+			return fmt.Errorf("filestore: malformed snapshot %q: %w", s.path, err)
+		}
+		s.mappings[obscured] = *u
+	}
+	return nil
+}
+
+// run snapshots the store at every interval until the store is closed.
+func (s *Store) run() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.mutex.RLock()
+			dirty := s.dirty
+			s.mutex.RUnlock()
+			if !dirty {
+				continue
+			}
+			if err := s.Snapshot(); err != nil && s.options.ErrorHandler != nil {
+				s.options.ErrorHandler(err)
+			}
+		}
+	}
+}
+
+// encode serializes the current mappings, marking the store as clean.
+func (s *Store) encode() ([]byte, error) {
+	s.mutex.Lock()
+	snap := snapshot{
+		Version:  snapshotVersion,
+		Mappings: make(map[string]string, len(s.mappings)),
+	}
+	for obscured, original := range s.mappings {
+		snap.Mappings[obscured] = original.String()
+	}
+	s.dirty = false
+	s.mutex.Unlock()
+
+	if s.options.Format == FormatGob {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(snap); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return json.Marshal(snap)
+}
+
+// markDirty marks the store as changed since the latest snapshot.
+func (s *Store) markDirty() {
+	s.mutex.Lock()
+	s.dirty = true
+	s.mutex.Unlock()
+}
+
+// Snapshot writes the current mappings to the snapshot file.
+func (s *Store) Snapshot() error {
+	s.snapshotMutex.Lock()
+	defer s.snapshotMutex.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	return s.snapshot()
+}
+
+// snapshot writes the current mappings to a temporary file, and renames it
+// over the snapshot file.
+func (s *Store) snapshot() (err error) {
+	data, err := s.encode()
+	if err == nil {
+		data, err = s.seal(data)
+	}
+	if err != nil {
+		s.markDirty()
+		return err
+	}
+	defer func() {
+		if err != nil {
+			// the mappings failed to be persisted, so retry next time.
+			s.markDirty()
+		}
+	}()
+	dir, base := filepath.Split(s.path)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := ioutil.TempFile(dir, "."+base+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), s.options.Mode); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path)
+}
+
+// Close stops the periodic snapshots and writes a final snapshot. The store
+// remains usable in memory, but is no longer snapshotted.
+func (s *Store) Close() error {
+	s.snapshotMutex.Lock()
+	if s.closed {
+		s.snapshotMutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	s.snapshotMutex.Unlock()
+	s.wg.Wait()
+
+	s.snapshotMutex.Lock()
+	defer s.snapshotMutex.Unlock()
+	return s.snapshot()
+}
+
+// Put places the mapping between the provided obscured URL and it's original
+// form into the store. A *obscurer.CollisionError is returned when the
+// obscured URL is already mapped to a URL with a different path.
+func (s *Store) Put(ctx context.Context, obscured, original *url.URL) error {
+	key := s.options.KeyStrategy.Key(obscured)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if existing, ok := s.mappings[key]; ok {
+		if existing.Path != original.Path {
+			return &obscurer.CollisionError{
+				Obscured: obscured,
+				Existing: &existing,
+				Original: original,
+			}
+		}
+		return nil
+	}
+	s.mappings[key] = *original
+	s.dirty = true
+	return nil
+}
+
+// Get retrieves the original form of the provided obscured URL.
+func (s *Store) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	original, ok := s.mappings[s.options.KeyStrategy.Key(obscured)]
+	if !ok {
+		return nil, false, nil
+	}
+	return &original, true, nil
+}
+
+// Range invokes the provided function for each mapping in the store, until
+// the function returns false. The store is locked while ranging, so the
+// function must not modify it.
+func (s *Store) Range(ctx context.Context, fn func(obscured, original *url.URL) bool) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for obscured, original := range s.mappings {
+		original := original
+		if !fn(s.options.KeyStrategy.URL(obscured), &original) {
+			break
+		}
+	}
+	return nil
+}
+
+// Remove deletes the entry in the store for the provided obscured URL.
+func (s *Store) Remove(ctx context.Context, obscured *url.URL) error {
+	key := s.options.KeyStrategy.Key(obscured)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.mappings[key]; ok {
+		delete(s.mappings, key)
+		s.dirty = true
+	}
+	return nil
+}
+
+// Clear removes all entries in the store.
+func (s *Store) Clear(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.mappings = make(map[string]url.URL)
+	s.dirty = true
+	return nil
+}
+
+// Size computes the size of the store.
+func (s *Store) Size(ctx context.Context) int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.mappings)
+}
+
+// Load loads the store with the provided mappings.
+func (s *Store) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	for obscured, original := range mappings.Pointers() {
+		if err := s.Put(ctx, obscured, original); err != nil {
+			return err
+		}
+	}
+	return nil
+}
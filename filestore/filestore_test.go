@@ -0,0 +1,216 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filestore_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/filestore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func path(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "filestore")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	return filepath.Join(dir, "obscurer.json")
+}
+
+// TestStore tests that mappings can be placed, retrieved, and removed.
+func TestStore(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s, err := filestore.Open(path(t), filestore.WithInterval(0))
+	require.NoError(t, err)
+	defer s.Close()
+	obscured, original := mustParse("/abc"), mustParse("http://www.example.com/this/is/the/way")
+
+	// action + assert.
+	require.NoError(t, s.Put(ctx, obscured, original))
+	require.NoError(t, s.Put(ctx, obscured, original))
+	assert.True(t, errors.Is(s.Put(ctx, obscured, mustParse("/hey/der")), obscurer.ErrCollision))
+	assert.Equal(t, 1, s.Size(ctx))
+	got, ok, err := s.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, original.String(), got.String())
+	require.NoError(t, s.Remove(ctx, obscured))
+	_, ok, err = s.Get(ctx, obscured)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// TestStore_Reopen tests that mappings survive the store being reopened, in
+// every format.
+func TestStore_Reopen(t *testing.T) {
+	formats := map[string]filestore.Format{
+		"JSON": filestore.FormatJSON,
+		"Gob":  filestore.FormatGob,
+	}
+	for name, format := range formats {
+		t.Run(name, func(t *testing.T) {
+			// arrange.
+			ctx := context.Background()
+			p := path(t)
+			s, err := filestore.Open(p, filestore.WithFormat(format))
+			require.NoError(t, err)
+			require.NoError(t, obscurer.LoadAll(ctx, s, map[*url.URL]*url.URL{
+				mustParse("/a"): mustParse("/this/is/the/way"),
+				mustParse("/b"): mustParse("/hey/der?q=1"),
+			}))
+			require.NoError(t, s.Close())
+
+			// action.
+			s, err = filestore.Open(p, filestore.WithFormat(format))
+
+			// assert.
+			require.NoError(t, err)
+			defer s.Close()
+			assert.Equal(t, 2, s.Size(ctx))
+			got, ok, err := s.Get(ctx, mustParse("/b"))
+			require.NoError(t, err)
+			require.True(t, ok)
+			assert.Equal(t, "/hey/der?q=1", got.String())
+		})
+	}
+}
+
+// TestStore_Interval tests that mappings are periodically snapshotted.
+func TestStore_Interval(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	p := path(t)
+	s, err := filestore.Open(p, filestore.WithInterval(5*time.Millisecond))
+	require.NoError(t, err)
+	defer s.Close()
+
+	// action.
+	require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+
+	// assert.
+	assert.Eventually(t, func() bool {
+		data, err := ioutil.ReadFile(p)
+		return err == nil && len(data) > 0
+	}, time.Second, 5*time.Millisecond)
+	reopened, err := filestore.Open(p, filestore.WithInterval(0))
+	require.NoError(t, err)
+	defer reopened.Close()
+	assert.Equal(t, 1, reopened.Size(ctx))
+}
+
+// TestStore_Snapshot_Closed tests that snapshotting a closed store results
+// in an error.
+func TestStore_Snapshot_Closed(t *testing.T) {
+	// arrange.
+	s, err := filestore.Open(path(t))
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	// action.
+	err = s.Snapshot()
+
+	// assert.
+	assert.Equal(t, filestore.ErrClosed, err)
+}
+
+// TestOpen_Malformed tests that opening a store with a malformed snapshot
+// results in an error.
+func TestOpen_Malformed(t *testing.T) {
+	// arrange.
+	p := path(t)
+	require.NoError(t, ioutil.WriteFile(p, []byte("{"), 0600))
+
+	// action.
+	_, err := filestore.Open(p)
+
+	// assert.
+	assert.Error(t, err)
+}
+
+// TestStore_Range tests that every mapping is visited until the function
+// returns false.
+func TestStore_Range(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s, err := filestore.Open(path(t), filestore.WithInterval(0))
+	require.NoError(t, err)
+	defer s.Close()
+	require.NoError(t, obscurer.LoadAll(ctx, s, map[*url.URL]*url.URL{
+		mustParse("/a"): mustParse("/this/is/the/way"),
+		mustParse("/b"): mustParse("/hey/der"),
+	}))
+	visited := make(map[string]string)
+
+	// action.
+	err = s.Range(ctx, func(obscured, original *url.URL) bool {
+		visited[obscured.Path] = original.String()
+		return true
+	})
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"/a": "/this/is/the/way", "/b": "/hey/der"}, visited)
+	count := 0
+	require.NoError(t, s.Range(ctx, func(obscured, original *url.URL) bool {
+		count++
+		return false
+	}))
+	assert.Equal(t, 1, count, "expected ranging to stop")
+}
+
+func mustParse(str string) *url.URL {
+	u, err := url.Parse(str)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// TestStore_KeyStrategy tests that mappings keyed by request URI survive the
+// store being reopened.
+func TestStore_KeyStrategy(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	p := path(t)
+	s, err := filestore.Open(p, filestore.WithInterval(0), filestore.WithKeyStrategy(obscurer.KeyRequestURI))
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, mustParse("/abc?page=1"), mustParse("/users?page=1")))
+	require.NoError(t, s.Put(ctx, mustParse("/abc?page=2"), mustParse("/users?page=2")))
+	require.NoError(t, s.Close())
+
+	// action.
+	reopened, err := filestore.Open(p, filestore.WithInterval(0), filestore.WithKeyStrategy(obscurer.KeyRequestURI))
+
+	// assert.
+	require.NoError(t, err)
+	defer reopened.Close()
+	got, ok, err := reopened.Get(ctx, mustParse("/abc?page=1"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/users?page=1", got.String())
+	assert.Equal(t, 2, reopened.Size(ctx))
+}
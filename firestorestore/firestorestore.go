@@ -0,0 +1,237 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package firestorestore provides an obscurer.Store backed by a Google
+// Cloud Firestore collection.
+//
+// Each mapping is a document whose ID is derived from the obscured URL.
+// When configured with a TTL, documents carry an 'expiresAt' timestamp
+// which a Firestore TTL policy on that field uses to delete them. As TTL
+// deletion can lag, expired documents are also treated as missing on read.
+package firestorestore
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/freerware/obscurer"
+)
+
+// batchLimit represents the maximum number of writes in a single batch.
+const batchLimit = 500
+
+var (
+	// ErrNotFound represents the error returned by a Collection when the
+	// requested document doesn't exist.
+	ErrNotFound = errors.New("firestorestore: document not found")
+	// ErrAlreadyExists represents the error returned by a Collection when
+	// creating a document that already exists.
+	ErrAlreadyExists = errors.New("firestorestore: document already exists")
+)
+
+// Document represents a stored mapping.
+type Document struct {
+	// Original is the original form of the obscured URL.
+	Original string `firestore:"original"`
+	// ExpiresAt is when the mapping expires. The zero value never expires.
+	ExpiresAt time.Time `firestore:"expiresAt,omitempty"`
+}
+
+// Write represents a single write within a batch. A nil Document deletes
+// the document with the provided ID.
+type Write struct {
+	ID       string
+	Document *Document
+}
+
+// Collection represents the subset of a Firestore collection needed by the
+// store.
+type Collection interface {
+	// Create creates the document with the provided ID, returning
+	// ErrAlreadyExists when it already exists.
+	Create(ctx context.Context, id string, doc Document) error
+	// Get retrieves the document with the provided ID, returning
+	// ErrNotFound when it doesn't exist.
+	Get(ctx context.Context, id string) (Document, error)
+	// Delete deletes the document with the provided ID.
+	Delete(ctx context.Context, id string) error
+	// Commit atomically applies the provided writes.
+	Commit(ctx context.Context, writes []Write) error
+	// Count counts the documents in the collection.
+	Count(ctx context.Context) (int, error)
+	// IDs retrieves the IDs of all documents in the collection.
+	IDs(ctx context.Context) ([]string, error)
+}
+
+// Options represents the configuration options for the store.
+type Options struct {
+	// TTL is the duration mappings are kept for. Zero keeps mappings
+	// indefinitely.
+	TTL time.Duration
+}
+
+// Option applies an option to the provided configuration.
+type Option func(*Options)
+
+// WithTTL configures the duration mappings are kept for.
+func WithTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.TTL = ttl
+	}
+}
+
+// Store stores mappings in a Firestore collection.
+type Store struct {
+	collection Collection
+	options    Options
+}
+
+// New constructs a store backed by the provided collection.
+func New(collection Collection, opts ...Option) *Store {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &Store{collection: collection, options: options}
+}
+
+// id derives the document ID for the provided obscured URL, as document IDs
+// cannot contain slashes.
+func id(obscured *url.URL) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(obscured.Path))
+}
+
+// document constructs the document for the provided original URL.
+func (s *Store) document(original *url.URL) Document {
+	doc := Document{Original: original.String()}
+	if s.options.TTL > 0 {
+		doc.ExpiresAt = time.Now().Add(s.options.TTL).UTC()
+	}
+	return doc
+}
+
+// get retrieves the original form of the provided obscured URL.
+func (s *Store) get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	doc, err := s.collection.Get(ctx, id(obscured))
+	if errors.Is(err, ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if !doc.ExpiresAt.IsZero() && !time.Now().Before(doc.ExpiresAt) {
+		return nil, false, nil
+	}
+	original, err := url.Parse(doc.Original)
+	if err != nil {
+		return nil, false, err
+	}
+	return original, true, nil
+}
+
+// commit applies the provided writes in batches.
+func (s *Store) commit(ctx context.Context, writes []Write) error {
+	for len(writes) > 0 {
+		n := len(writes)
+		if n > batchLimit {
+			n = batchLimit
+		}
+		if err := s.collection.Commit(ctx, writes[:n]); err != nil {
+			return err
+		}
+		writes = writes[n:]
+	}
+	return nil
+}
+
+// Put places the mapping between the provided obscured URL and it's original
+// form into the store. A *obscurer.CollisionError is returned when the
+// obscured URL is already mapped to a URL with a different path.
+func (s *Store) Put(ctx context.Context, obscured, original *url.URL) error {
+	err := s.collection.Create(ctx, id(obscured), s.document(original))
+	if !errors.Is(err, ErrAlreadyExists) {
+		return err
+	}
+	existing, ok, err := s.get(ctx, obscured)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// the existing mapping has expired, but has yet to be deleted.
+		doc := s.document(original)
+		return s.commit(ctx, []Write{{ID: id(obscured), Document: &doc}})
+	}
+	if existing.Path != original.Path {
+		return &obscurer.CollisionError{
+			Obscured: obscured,
+			Existing: existing,
+			Original: original,
+		}
+	}
+	return nil
+}
+
+// Ping verifies Firestore is reachable with the credentials of the client,
+// by reading the document of the root path from the collection.
+func (s *Store) Ping(ctx context.Context) error {
+	_, _, err := s.Get(ctx, &url.URL{Path: "/"})
+	return err
+}
+
+// Get retrieves the original form of the provided obscured URL.
+func (s *Store) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	return s.get(ctx, obscured)
+}
+
+// Remove deletes the entry in the store for the provided obscured URL.
+func (s *Store) Remove(ctx context.Context, obscured *url.URL) error {
+	return s.collection.Delete(ctx, id(obscured))
+}
+
+// Clear removes all entries in the store.
+func (s *Store) Clear(ctx context.Context) error {
+	ids, err := s.collection.IDs(ctx)
+	if err != nil {
+		return err
+	}
+	writes := make([]Write, 0, len(ids))
+	for _, id := range ids {
+		writes = append(writes, Write{ID: id})
+	}
+	return s.commit(ctx, writes)
+}
+
+// Size computes the size of the store.
+func (s *Store) Size(ctx context.Context) int {
+	size, err := s.collection.Count(ctx)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// Load loads the store with the provided mappings. The mappings are written
+// in batches, replacing any existing mappings for the same obscured URLs.
+func (s *Store) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	writes := make([]Write, 0, mappings.Len())
+	for obscured, original := range mappings.Pointers() {
+		doc := s.document(original)
+		writes = append(writes, Write{ID: id(obscured), Document: &doc})
+	}
+	return s.commit(ctx, writes)
+}
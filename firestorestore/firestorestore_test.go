@@ -0,0 +1,197 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package firestorestore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/firestorestore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collection is an in-memory collection.
+type collection struct {
+	mutex   sync.Mutex
+	docs    map[string]firestorestore.Document
+	commits int
+}
+
+func newCollection() *collection {
+	return &collection{docs: make(map[string]firestorestore.Document)}
+}
+
+func (c *collection) Create(ctx context.Context, id string, doc firestorestore.Document) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if strings.Contains(id, "/") {
+		return errors.New("invalid document ID")
+	}
+	if _, ok := c.docs[id]; ok {
+		return firestorestore.ErrAlreadyExists
+	}
+	c.docs[id] = doc
+	return nil
+}
+
+func (c *collection) Get(ctx context.Context, id string) (firestorestore.Document, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	doc, ok := c.docs[id]
+	if !ok {
+		return doc, firestorestore.ErrNotFound
+	}
+	return doc, nil
+}
+
+func (c *collection) Delete(ctx context.Context, id string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.docs, id)
+	return nil
+}
+
+func (c *collection) Commit(ctx context.Context, writes []firestorestore.Write) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(writes) > 500 {
+		return errors.New("too many writes")
+	}
+	c.commits++
+	for _, w := range writes {
+		if w.Document == nil {
+			delete(c.docs, w.ID)
+			continue
+		}
+		c.docs[w.ID] = *w.Document
+	}
+	return nil
+}
+
+func (c *collection) Count(ctx context.Context) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.docs), nil
+}
+
+func (c *collection) IDs(ctx context.Context) (ids []string, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for id := range c.docs {
+		ids = append(ids, id)
+	}
+	return
+}
+
+// TestStore tests that mappings can be placed, retrieved, and removed.
+func TestStore(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := firestorestore.New(newCollection())
+	obscured, original := mustParse("/abc"), mustParse("http://www.example.com/this/is/the/way")
+
+	// action + assert.
+	require.NoError(t, s.Put(ctx, obscured, original))
+	require.NoError(t, s.Put(ctx, obscured, original))
+	assert.Equal(t, 1, s.Size(ctx))
+	got, ok, err := s.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, original.String(), got.String())
+	require.NoError(t, s.Remove(ctx, obscured))
+	_, ok, err = s.Get(ctx, obscured)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// TestStore_TTL tests that mappings carry an expiration, and that expired
+// mappings are treated as missing.
+func TestStore_TTL(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	c := newCollection()
+	s := firestorestore.New(c, firestorestore.WithTTL(time.Hour))
+	obscured := mustParse("/abc")
+	require.NoError(t, s.Put(ctx, obscured, mustParse("/this/is/the/way")))
+	for id, doc := range c.docs {
+		assert.WithinDuration(t, time.Now().Add(time.Hour), doc.ExpiresAt, time.Minute)
+		doc.ExpiresAt = time.Now().Add(-time.Second)
+		c.docs[id] = doc
+	}
+
+	// action + assert.
+	_, ok, err := s.Get(ctx, obscured)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	require.NoError(t, s.Put(ctx, obscured, mustParse("/hey/der")))
+	got, ok, err := s.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/hey/der", got.String())
+}
+
+// TestStore_Put_Collision tests that placing a mapping for an obscured URL
+// that already maps to a different URL results in a collision error.
+func TestStore_Put_Collision(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := firestorestore.New(newCollection())
+	require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+
+	// action.
+	err := s.Put(ctx, mustParse("/abc"), mustParse("/hey/der"))
+
+	// assert.
+	assert.True(t, errors.Is(err, obscurer.ErrCollision))
+}
+
+// TestStore_Load tests that the store is loaded, and cleared, in batches.
+func TestStore_Load(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	c := newCollection()
+	s := firestorestore.New(c)
+	mappings := make(map[*url.URL]*url.URL)
+	for i := 0; i < 501; i++ {
+		mappings[mustParse(fmt.Sprintf("/%d", i))] = mustParse(fmt.Sprintf("/products/%d", i))
+	}
+
+	// action.
+	err := obscurer.LoadAll(ctx, s, mappings)
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 501, s.Size(ctx))
+	assert.Equal(t, 2, c.commits)
+	require.NoError(t, s.Clear(ctx))
+	assert.Equal(t, 0, s.Size(ctx))
+}
+
+func mustParse(str string) *url.URL {
+	u, err := url.Parse(str)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
@@ -0,0 +1,131 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package groupcachestore provides an obscurer.Store that caches the
+// mappings of another store in groupcache.
+//
+// Groupcache distributes its cache across a set of peers, each owning a
+// share of the keys, and fills a missing key once on its owner rather than
+// once per process. Lookups therefore reach the backing store at most once
+// per mapping across the deployment, instead of once per process. Writes go
+// straight to the backing store.
+//
+// Groupcache never removes nor replaces cached keys, so a mapping that is
+// removed or replaced in the backing store may keep resolving to its former
+// original until it is evicted from the cache. Groupcache doesn't cache
+// failed fills either, so lookups of unmapped URLs always reach the backing
+// store.
+package groupcachestore
+
+import (
+	"context"
+	"errors"
+	"net/url"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/codec"
+)
+
+// ErrNotFound represents the error returned by a FillFunc, and in turn by a
+// Group, when the requested mapping doesn't exist.
+var ErrNotFound = errors.New("groupcachestore: mapping not found")
+
+// Group represents the subset of a groupcache group needed by the store.
+type Group interface {
+	// Get retrieves the value of the provided key, filling it with the
+	// FillFunc of the group when it isn't cached. It returns ErrNotFound
+	// when the fill reports the mapping missing, including fills by peers,
+	// which report their errors opaquely.
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// FillFunc retrieves the value of the provided key from the backing store,
+// returning ErrNotFound when it doesn't exist.
+type FillFunc func(ctx context.Context, key string) ([]byte, error)
+
+// Options represents the configuration options for the store.
+type Options struct {
+	// Codec serializes the cached values.
+	Codec codec.Codec
+}
+
+// Option applies an option to the provided configuration.
+type Option func(*Options)
+
+// WithCodec configures the codec the cached values are serialized with.
+func WithCodec(c codec.Codec) Option {
+	return func(o *Options) {
+		o.Codec = c
+	}
+}
+
+// options constructs the configuration from the provided options.
+func options(opts []Option) Options {
+	options := Options{Codec: codec.Protobuf}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// Fill constructs the function filling the values of a group from the
+// provided backing store, where the keys are the paths of obscured URLs.
+func Fill(base obscurer.Store, opts ...Option) FillFunc {
+	options := options(opts)
+	return func(ctx context.Context, key string) ([]byte, error) {
+		original, ok, err := base.Get(ctx, &url.URL{Path: key})
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrNotFound
+		}
+		return codec.Encode(options.Codec, codec.Entry{Original: original})
+	}
+}
+
+// Store caches the mappings of a backing store in groupcache.
+type Store struct {
+	obscurer.Store
+	group Group
+}
+
+// New constructs a store retrieving mappings through the provided group,
+// which is filled from the provided backing store with Fill. Every other
+// operation is performed on the backing store.
+func New(group Group, base obscurer.Store) *Store {
+	return &Store{Store: base, group: group}
+}
+
+// Get retrieves the original form of the provided obscured URL.
+func (s *Store) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	value, err := s.group.Get(ctx, obscured.Path)
+	if errors.Is(err, ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	entry, err := codec.Decode(value)
+	if err != nil {
+		return nil, false, err
+	}
+	return entry.Original, true, nil
+}
+
+// Ping verifies the backend of the underlying store is reachable.
+func (s *Store) Ping(ctx context.Context) error {
+	return obscurer.Ping(ctx, s.Store)
+}
@@ -0,0 +1,156 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package groupcachestore_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/groupcachestore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// group is an in-memory groupcache group.
+type group struct {
+	mutex  sync.Mutex
+	fill   groupcachestore.FillFunc
+	values map[string][]byte
+	fills  int
+}
+
+func newGroup(fill groupcachestore.FillFunc) *group {
+	return &group{fill: fill, values: make(map[string][]byte)}
+}
+
+func (g *group) Get(ctx context.Context, key string) ([]byte, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if value, ok := g.values[key]; ok {
+		return value, nil
+	}
+	g.fills++
+	value, err := g.fill(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	g.values[key] = value
+	return value, nil
+}
+
+// TestStore_Get tests that mappings are filled from the backing store once,
+// and retrieved from the group thereafter.
+func TestStore_Get(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	base := obscurer.NewMemoryStore()
+	g := newGroup(groupcachestore.Fill(base))
+	s := groupcachestore.New(g, base)
+	obscured, original := mustParse("/abc"), mustParse("http://www.example.com/this/is/the/way")
+	require.NoError(t, s.Put(ctx, obscured, original))
+
+	// action.
+	first, ok, err := s.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.True(t, ok)
+	second, ok, err := s.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// assert.
+	assert.Equal(t, original.String(), first.String())
+	assert.Equal(t, original.String(), second.String())
+	assert.Equal(t, 1, g.fills)
+	assert.Equal(t, 1, s.Size(ctx))
+}
+
+// TestStore_Get_Missing tests that lookups of unmapped URLs are misses, and
+// are not cached.
+func TestStore_Get_Missing(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	base := obscurer.NewMemoryStore()
+	g := newGroup(groupcachestore.Fill(base))
+	s := groupcachestore.New(g, base)
+	obscured := mustParse("/abc")
+
+	// action.
+	_, ok, err := s.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.NoError(t, s.Put(ctx, obscured, mustParse("/this/is/the/way")))
+	got, ok, err := s.Get(ctx, obscured)
+
+	// assert.
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/this/is/the/way", got.String())
+	assert.Equal(t, 2, g.fills)
+}
+
+// TestStore_Get_Error tests that errors filling the group are returned.
+func TestStore_Get_Error(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	whoa := errors.New("whoa")
+	g := newGroup(func(ctx context.Context, key string) ([]byte, error) {
+		return nil, whoa
+	})
+	s := groupcachestore.New(g, obscurer.NewMemoryStore())
+
+	// action.
+	_, ok, err := s.Get(ctx, mustParse("/abc"))
+
+	// assert.
+	assert.False(t, ok)
+	assert.True(t, errors.Is(err, whoa))
+}
+
+func mustParse(raw string) *url.URL {
+	u, err := url.Parse(raw)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// pinging is a store whose backend is reachable unless err is set.
+type pinging struct {
+	obscurer.Store
+	err error
+}
+
+func (s *pinging) Ping(ctx context.Context) error {
+	return s.err
+}
+
+// TestStore_Ping tests that pings are forwarded to the underlying store.
+func TestStore_Ping(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	underlying := &pinging{Store: obscurer.NewMemoryStore(), err: errors.New("whoa")}
+	s := groupcachestore.New(newGroup(groupcachestore.Fill(underlying)), underlying)
+
+	// action.
+	err := s.Ping(ctx)
+
+	// assert.
+	assert.Equal(t, underlying.err, err)
+}
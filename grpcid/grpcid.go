@@ -0,0 +1,195 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package grpcid provides gRPC server interceptors that apply an
+// obscurer.IDCodec to the identifiers within messages, so that services
+// exposing both HTTP and gRPC show clients the same opaque identifiers on
+// both protocols.
+//
+// Identifiers within requests are decoded before the service handles them,
+// and identifiers within responses are encoded. Identifiers are the string
+// fields of messages with the configured names, which are the names of the
+// fields within the .proto file, matched at any depth, including within
+// repeated fields and oneofs. Identifiers that fail to be decoded are left
+// as is, as are fields of other types, since an encoded identifier can't be
+// held by a numeric field. Messages are rewritten in place, so services
+// must not retain the responses they return.
+//
+// The interceptors depend on small function and interface types rather
+// than the gRPC module directly, and are installed as follows:
+//
+//	i := grpcid.New(codec, "user_id", "order_id")
+//	server := grpc.NewServer(
+//		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+//			return i.Unary(ctx, req, handler)
+//		}),
+//		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+//			return handler(srv, stream{ss, i.Stream(ss)})
+//		}),
+//	)
+//
+// where stream forwards the messages of the grpc.ServerStream through the
+// wrapped stream:
+//
+//	type stream struct {
+//		grpc.ServerStream
+//		wrapped grpcid.ServerStream
+//	}
+//
+//	func (s stream) SendMsg(m interface{}) error { return s.wrapped.SendMsg(m) }
+//	func (s stream) RecvMsg(m interface{}) error { return s.wrapped.RecvMsg(m) }
+package grpcid
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/freerware/obscurer"
+)
+
+// ServerStream represents the subset of a grpc.ServerStream carrying the
+// messages of a streaming call.
+type ServerStream interface {
+	// SendMsg sends the provided message to the client.
+	SendMsg(m interface{}) error
+	// RecvMsg receives the next message from the client into the provided
+	// message.
+	RecvMsg(m interface{}) error
+}
+
+// Interceptor applies an ID codec to the identifiers within messages.
+type Interceptor struct {
+	codec  obscurer.IDCodec
+	fields map[string]bool
+}
+
+// New constructs an interceptor encoding and decoding the string fields with
+// the provided names using the provided codec. Codecs implementing
+// obscurer.ParameterIDCodec select the codec of each field by its name.
+func New(codec obscurer.IDCodec, fields ...string) *Interceptor {
+	i := &Interceptor{codec: codec, fields: make(map[string]bool, len(fields))}
+	for _, field := range fields {
+		i.fields[field] = true
+	}
+	return i
+}
+
+// Unary decodes the identifiers within the provided request, handles it
+// with the provided handler, and encodes the identifiers within the
+// response.
+func (i *Interceptor) Unary(ctx context.Context, req interface{}, handler func(context.Context, interface{}) (interface{}, error)) (interface{}, error) {
+	i.Decode(req)
+	resp, err := handler(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	i.Encode(resp)
+	return resp, nil
+}
+
+// Stream wraps the provided stream, such that the identifiers within the
+// messages received are decoded, and those within the messages sent are
+// encoded.
+func (i *Interceptor) Stream(ss ServerStream) ServerStream {
+	return &stream{ServerStream: ss, interceptor: i}
+}
+
+// Encode encodes the identifiers within the provided message in place.
+func (i *Interceptor) Encode(message interface{}) {
+	i.walk(reflect.ValueOf(message), "", obscurer.IDCodec.Encode)
+}
+
+// Decode decodes the identifiers within the provided message in place.
+func (i *Interceptor) Decode(message interface{}) {
+	i.walk(reflect.ValueOf(message), "", obscurer.IDCodec.Decode)
+}
+
+// codecFor provides the codec of the provided field, which is nil when the
+// field does not hold an identifier.
+func (i *Interceptor) codecFor(field string) obscurer.IDCodec {
+	if !i.fields[field] {
+		return nil
+	}
+	if selector, ok := i.codec.(obscurer.ParameterIDCodec); ok {
+		return selector.Codec(field)
+	}
+	return i.codec
+}
+
+// walk applies the provided function with the codec of each identifier to
+// the identifiers within the provided value, which is held by the field with
+// the provided name. Identifiers the function fails for are left as is.
+func (i *Interceptor) walk(v reflect.Value, field string, fn func(obscurer.IDCodec, string) (string, error)) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			i.walk(v.Elem(), field, fn)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for n := 0; n < t.NumField(); n++ {
+			if f := t.Field(n); f.PkgPath == "" {
+				i.walk(v.Field(n), name(f), fn)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for n := 0; n < v.Len(); n++ {
+			i.walk(v.Index(n), field, fn)
+		}
+	case reflect.String:
+		codec := i.codecFor(field)
+		if codec == nil || !v.CanSet() {
+			return
+		}
+		if transcoded, err := fn(codec, v.String()); err == nil {
+			v.SetString(transcoded)
+		}
+	}
+}
+
+// name provides the name of the provided struct field within the .proto
+// file, as recorded by the protobuf struct tag of generated messages,
+// falling back to the name of the Go field.
+func name(f reflect.StructField) string {
+	for _, part := range strings.Split(f.Tag.Get("protobuf"), ",") {
+		if strings.HasPrefix(part, "name=") {
+			return strings.TrimPrefix(part, "name=")
+		}
+	}
+	return f.Name
+}
+
+// stream applies the interceptor to the messages of a stream.
+type stream struct {
+	ServerStream
+	interceptor *Interceptor
+}
+
+// SendMsg encodes the identifiers within the provided message, and sends
+// it.
+func (s *stream) SendMsg(m interface{}) error {
+	s.interceptor.Encode(m)
+	return s.ServerStream.SendMsg(m)
+}
+
+// RecvMsg receives the next message, and decodes the identifiers within it.
+func (s *stream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	s.interceptor.Decode(m)
+	return nil
+}
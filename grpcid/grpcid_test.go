@@ -0,0 +1,149 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpcid_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/grpcid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// prefixCodec encodes identifiers by prefixing them.
+type prefixCodec struct{}
+
+func (prefixCodec) Encode(value string) (string, error) { return "x" + value, nil }
+
+func (prefixCodec) Decode(encoded string) (string, error) {
+	if !strings.HasPrefix(encoded, "x") {
+		return "", obscurer.ErrInvalidID
+	}
+	return strings.TrimPrefix(encoded, "x"), nil
+}
+
+// Item mirrors a generated message nested within another.
+type Item struct {
+	Id       string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Quantity int64  `protobuf:"varint,2,opt,name=quantity,proto3" json:"quantity,omitempty"`
+}
+
+// Order mirrors a generated message.
+type Order struct {
+	OrderId    string   `protobuf:"bytes,1,opt,name=order_id,json=orderId,proto3" json:"order_id,omitempty"`
+	Name       string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Items      []*Item  `protobuf:"bytes,3,rep,name=items,proto3" json:"items,omitempty"`
+	RelatedIds []string `protobuf:"bytes,4,rep,name=related_ids,json=relatedIds,proto3" json:"related_ids,omitempty"`
+
+	unexported string
+}
+
+// TestInterceptor_Unary tests that identifiers are decoded within requests
+// and encoded within responses.
+func TestInterceptor_Unary(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	i := grpcid.New(prefixCodec{}, "order_id", "id", "related_ids")
+	req := &Order{OrderId: "x42", Name: "x42", RelatedIds: []string{"x1", "2"}}
+	var received Order
+
+	// action.
+	resp, err := i.Unary(ctx, req, func(ctx context.Context, req interface{}) (interface{}, error) {
+		received = *req.(*Order)
+		return &Order{
+			OrderId:    "42",
+			Name:       "42",
+			Items:      []*Item{{Id: "7", Quantity: 1}, nil},
+			RelatedIds: []string{"1"},
+			unexported: "1",
+		}, nil
+	})
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, "42", received.OrderId)
+	assert.Equal(t, "x42", received.Name)
+	assert.Equal(t, []string{"1", "2"}, received.RelatedIds)
+	order := resp.(*Order)
+	assert.Equal(t, "x42", order.OrderId)
+	assert.Equal(t, "42", order.Name)
+	assert.Equal(t, "x7", order.Items[0].Id)
+	assert.Equal(t, int64(1), order.Items[0].Quantity)
+	assert.Equal(t, []string{"x1"}, order.RelatedIds)
+	assert.Equal(t, "1", order.unexported)
+}
+
+// TestInterceptor_Unary_Error tests that responses aren't encoded when the
+// request fails.
+func TestInterceptor_Unary_Error(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	i := grpcid.New(prefixCodec{}, "order_id")
+	expectedErr := errors.New("whoa")
+
+	// action.
+	resp, err := i.Unary(ctx, &Order{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &Order{OrderId: "42"}, expectedErr
+	})
+
+	// assert.
+	assert.Equal(t, expectedErr, err)
+	assert.Equal(t, "42", resp.(*Order).OrderId)
+}
+
+// serverStream is an in-memory stream.
+type serverStream struct {
+	sent     []interface{}
+	received []*Order
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	s.sent = append(s.sent, m)
+	return nil
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	if len(s.received) == 0 {
+		return errors.New("EOF")
+	}
+	*m.(*Order), s.received = *s.received[0], s.received[1:]
+	return nil
+}
+
+// TestInterceptor_Stream tests that identifiers are decoded within the
+// messages received, and encoded within the messages sent.
+func TestInterceptor_Stream(t *testing.T) {
+	// arrange.
+	ss := &serverStream{received: []*Order{{OrderId: "x42"}}}
+	s := grpcid.New(prefixCodec{}, "order_id").Stream(ss)
+	var received Order
+
+	// action.
+	recvErr := s.RecvMsg(&received)
+	sendErr := s.SendMsg(&Order{OrderId: "43"})
+
+	// assert.
+	require.NoError(t, recvErr)
+	require.NoError(t, sendErr)
+	assert.Equal(t, "42", received.OrderId)
+	require.Len(t, ss.sent, 1)
+	assert.Equal(t, "x43", ss.sent[0].(*Order).OrderId)
+	assert.Error(t, s.RecvMsg(&received))
+}
@@ -20,34 +20,99 @@ import (
 	"errors"
 	"net/http"
 	"net/url"
-	"regexp"
-	"strings"
+	"strconv"
+	"time"
 )
 
-// headerParser parses the URL portion of a particular header value.
-type headerParser func(string) string
+// headerParser parses the URLs of a particular header value, replacing each
+// of them with the result of the provided function.
+type headerParser func(header string, replace func(string) (string, error)) (string, error)
 
 var (
 	// defaultParseHeader represents the default header parser, which
 	// takes the header value as is.
-	defaultParseHeader headerParser = func(header string) string { return header }
-
-	// parseLinkHeader represents the header parser for the Link header.
-	parseLinkHeader headerParser = func(header string) string {
-		r := regexp.MustCompile("^<(.+)>.*")
-		if !r.MatchString(header) {
-			return ""
-		}
+	defaultParseHeader headerParser = func(header string, replace func(string) (string, error)) (string, error) {
+		return replace(header)
+	}
 
-		matches := r.FindStringSubmatch(header)
-		return matches[1]
+	// parseLinkHeader represents the header parser for the Link header,
+	// which takes the target of each of its link-values. Values that don't
+	// conform to RFC 8288 fail with an error matching ErrMalformedLink.
+	parseLinkHeader headerParser = func(header string, replace func(string) (string, error)) (string, error) {
+		return rewriteLinks(header, replace)
 	}
 )
 
+// obscuredHeader represents a response header whose URLs are obscured.
+type obscuredHeader struct {
+	key   string
+	parse headerParser
+	// failure is the error the response is replaced with when the header
+	// fails to be obscured.
+	failure error
+}
+
+// obscuredHeaders represents the response headers whose URLs are obscured,
+// in the order they are obscured.
+var obscuredHeaders = []obscuredHeader{
+	// see: https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Location
+	{key: "Location", parse: defaultParseHeader, failure: ErrLocationHeaderFailure},
+	// see: https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Content-Location
+	{key: "Content-Location", parse: defaultParseHeader, failure: ErrContentLocationHeaderFailure},
+	// see: https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Link
+	{key: "Link", parse: parseLinkHeader, failure: ErrLinkHeaderFailure},
+}
+
+// StatusClass represents a class of HTTP status codes, such as 3xx.
+type StatusClass int
+
+const (
+	// StatusInformational represents the 1xx status codes.
+	StatusInformational StatusClass = 1
+	// StatusSuccessful represents the 2xx status codes.
+	StatusSuccessful StatusClass = 2
+	// StatusRedirection represents the 3xx status codes.
+	StatusRedirection StatusClass = 3
+	// StatusClientError represents the 4xx status codes.
+	StatusClientError StatusClass = 4
+	// StatusServerError represents the 5xx status codes.
+	StatusServerError StatusClass = 5
+)
+
+// Contains indicates whether the provided status code is of the class.
+func (c StatusClass) Contains(status int) bool {
+	return status/100 == int(c)
+}
+
+// OverheadHeader represents the response header reporting the time spent
+// resolving and rewriting URLs for the request, in milliseconds.
+const OverheadHeader = "X-Obscurer-Overhead-Ms"
+
+// maxRerolls represents the maximum number of times a URL is re-obscured
+// when its obscured form collides with an existing mapping.
+const maxRerolls = 3
+
 var (
+	// ErrFailedLookup represents an error that occurs when looking up the
+	// URL of a request in the store. The response is an HTTP 503 when the
+	// store timed out or reported a temporary error, and an HTTP 500
+	// otherwise.
+	ErrFailedLookup = errors.New("obscurer: unable to look up URL in store")
 	// ErrFailedRemoval represents an error that occurs when removing a URL
 	// mapping from the store.
 	ErrFailedRemoval = errors.New("obscurer: unable to remove URL form store")
+	// ErrNotObscured represents an error that occurs when the URL of a
+	// request isn't a known obscured URL, when the handler is strict. The
+	// response is an HTTP 404.
+	ErrNotObscured = errors.New("obscurer: URL isn't obscured")
+	// ErrUnknownURL represents an error that occurs when the URL of a
+	// request is of the format of obscured URLs configured with
+	// WithObscuredPaths, but isn't known. The response is an HTTP 404.
+	ErrUnknownURL = errors.New("obscurer: unknown obscured URL")
+	// ErrGone represents an error that occurs when the URL of a request
+	// no longer resolves, as its mapping was removed from a TombstoneStore.
+	// The response is an HTTP 410.
+	ErrGone = errors.New("obscurer: URL no longer resolves")
 	// ErrLocationHeaderFailure represents an error that occurs when obscuring
 	// the 'Location' header.
 	ErrLocationHeaderFailure = errors.New("obscurer: unable to obscure 'Location' header")
@@ -59,80 +124,877 @@ var (
 	ErrLinkHeaderFailure = errors.New("obscurer: unable to obscure 'Link' header")
 )
 
+// HeaderOutcome represents the outcome of obscuring a header of a response.
+type HeaderOutcome int
+
+const (
+	// HeaderRewritten represents a header whose URL was obscured.
+	HeaderRewritten HeaderOutcome = iota
+	// HeaderSkipped represents a header that was present, but didn't carry
+	// a URL to obscure.
+	HeaderSkipped
+	// HeaderFailed represents a header that failed to be obscured.
+	HeaderFailed
+)
+
+// String provides the name of the outcome.
+func (o HeaderOutcome) String() string {
+	switch o {
+	case HeaderRewritten:
+		return "rewritten"
+	case HeaderSkipped:
+		return "skipped"
+	case HeaderFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// HeaderObserver is notified of the outcome of obscuring a header of a
+// response. Headers absent from the response are not observed.
+type HeaderObserver func(ctx context.Context, header string, outcome HeaderOutcome)
+
+// ResolutionOutcome represents the outcome of resolving the URL of a
+// request.
+type ResolutionOutcome int
+
+const (
+	// ResolutionResolved represents a URL resolved to its original form.
+	ResolutionResolved ResolutionOutcome = iota
+	// ResolutionUnresolved represents a URL that isn't a known obscured
+	// URL.
+	ResolutionUnresolved
+	// ResolutionFailed represents a URL that failed to be looked up.
+	ResolutionFailed
+)
+
+// String provides the string representation of the outcome.
+func (o ResolutionOutcome) String() string {
+	switch o {
+	case ResolutionResolved:
+		return "resolved"
+	case ResolutionUnresolved:
+		return "unresolved"
+	case ResolutionFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// ResolutionObserver is notified of the outcome of resolving the URL of a
+// request. Requests excluded from obscuring are not observed.
+type ResolutionObserver func(ctx context.Context, outcome ResolutionOutcome)
+
+// HandlerOptions represents the configuration options for the handler.
+type HandlerOptions struct {
+	// Obscurer obscures the URLs of responses, overriding the obscurer
+	// the handler is constructed with. When nil, Default is used by Wrap.
+	Obscurer Obscurer
+	// Store holds the mappings of obscured URLs, overriding the store the
+	// handler is constructed with. When nil, DefaultStore is used by Wrap.
+	Store Store
+	// Headers are the keys of the response headers whose URLs are
+	// obscured, among 'Location', 'Content-Location' and 'Link'. When
+	// empty, each of them is obscured.
+	Headers []string
+	// HeaderObserver is notified of the outcome of obscuring each header.
+	HeaderObserver HeaderObserver
+	// ResolutionObserver is notified of the outcome of resolving the URL
+	// of each request.
+	ResolutionObserver ResolutionObserver
+	// Sampling is the configuration of the sampling of the requests whose
+	// bodies are rewritten.
+	Sampling Sampling
+	// Recover indicates whether the panics of the wrapped handler are
+	// recovered from, responding with 500 Internal Server Error.
+	Recover bool
+	// Logger is logged to about the issues the handler recovers from. When
+	// nil, nothing is logged.
+	Logger Logger
+	// ReportOverhead indicates whether responses carry the OverheadHeader.
+	ReportOverhead bool
+	// TTL is the duration the mappings placed into the store are kept for,
+	// when the store is an ExpiringStore. Zero keeps mappings indefinitely.
+	TTL time.Duration
+	// IDCodec encodes the identifiers held by the IDFields of JSON bodies.
+	IDCodec IDCodec
+	// IDFields are the names of the members of JSON bodies holding
+	// identifiers, which are encoded within responses and decoded within
+	// requests.
+	IDFields []string
+	// Namespacer provides the namespace of each request, which is carried
+	// by the context of the request for namespaced stores.
+	Namespacer Namespacer
+	// Uses is the number of times the mappings placed into the store
+	// resolve, when the store is a ConsumingStore. Zero resolves mappings
+	// indefinitely.
+	Uses int
+	// Policies provides the policies of the namespaces of requests, which
+	// override the TTL and obscurer of the handler.
+	Policies Policies
+	// WellKnown is the document served at WellKnownPath. When nil, no
+	// document is served.
+	WellKnown *WellKnown
+	// Toggles holds the flags toggling the behaviors of the handler at
+	// runtime. When nil, the handler behaves as configured.
+	Toggles *Toggles
+	// Limits are the maximum lengths of the URLs obscured. URLs exceeding
+	// them fail to be obscured.
+	Limits Limits
+	// LocationStatuses are the classes of status codes of the responses
+	// whose 'Location' header is obscured. When empty, it is obscured
+	// regardless of the status code.
+	LocationStatuses []StatusClass
+	// Exclusions are the patterns of the paths of requests that are passed
+	// to the wrapped handler as is, without being looked up nor having
+	// their responses obscured.
+	Exclusions []PathPattern
+	// Inclusions are the patterns of the paths of the URLs that are
+	// obscured. When empty, every URL is obscured.
+	Inclusions []PathPattern
+	// Removal configures the removal of the mappings of the resources the
+	// wrapped handler responds to with 404 Not Found.
+	Removal Removal
+	// LinkFields are the names of the members of JSON response bodies
+	// holding URLs, which are obscured.
+	LinkFields []string
+	// LinkPatterns are the patterns of the paths of the URLs held by any
+	// member of JSON response bodies, which are obscured.
+	LinkPatterns []PathPattern
+	// Hypermedia indicates whether the URLs of the links of JSON response
+	// bodies in the HAL and JSON:API formats are obscured.
+	Hypermedia bool
+	// RewriteHTML indicates whether the URLs held by the attributes of HTML
+	// response bodies are obscured.
+	RewriteHTML bool
+	// RewriteFeeds indicates whether the URLs of XML response bodies, such
+	// as Atom and RSS feeds, are obscured.
+	RewriteFeeds bool
+	// Sitemap are the routes listed by the sitemap served at SitemapPath.
+	// When empty, no sitemap is served.
+	Sitemap []string
+	// RewriteSitemaps indicates whether the URLs of the sitemap served by
+	// the wrapped handler at SitemapPath are obscured.
+	RewriteSitemaps bool
+	// MaxBufferedBody is the maximum length of the response bodies that are
+	// buffered to be rewritten. Longer bodies are passed through as is.
+	// Zero buffers bodies regardless of their length. Bodies with a
+	// 'Content-Encoding' other than identity are never buffered.
+	MaxBufferedBody int
+	// ResolveRequestBodies indicates whether the obscured URLs within JSON
+	// request bodies are resolved to their original form.
+	ResolveRequestBodies bool
+	// RequestBodyFields are the names of the members of JSON request bodies
+	// holding the obscured URLs resolved. When empty, any member is.
+	RequestBodyFields []string
+	// MaxRequestBody is the maximum length of the request bodies that are
+	// read to be rewritten. Zero uses DefaultMaxRequestBody.
+	MaxRequestBody int
+	// ResolveReferer indicates whether the 'Referer' header of requests is
+	// resolved to its original form.
+	ResolveReferer bool
+	// ObscuredPaths are the patterns of the paths of obscured URLs. Requests
+	// whose path matches them, but isn't known, are rejected rather than
+	// handled as is.
+	ObscuredPaths []PathPattern
+	// Strict indicates whether requests whose URL isn't a known obscured
+	// URL are rejected, rather than handled as is.
+	Strict bool
+	// ErrorHandler writes the response for the requests that fail to be
+	// handled. When nil, DefaultErrorHandler is used.
+	ErrorHandler ErrorHandler
+}
+
+// HandlerOption applies an option to the provided configuration.
+type HandlerOption func(*HandlerOptions)
+
+// WithHeaderObserver configures the handler to notify the provided observer
+// of the outcome of obscuring each header.
+func WithHeaderObserver(observer HeaderObserver) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.HeaderObserver = observer
+	}
+}
+
 type handler struct {
 	handler  http.Handler
 	obscurer Obscurer
 	store    Store
+	options  HandlerOptions
+	ids      *idFields
+	links    *bodyLinks
+	// obscurers caches the obscurers selected by the policies of
+	// namespaces.
+	obscurers *policyObscurers
+	// notFounds counts the consecutive 404 Not Found responses of
+	// resources.
+	notFounds *notFounds
+}
+
+// WithResolutionObserver configures the handler to notify the provided
+// observer of the outcome of resolving the URL of each request.
+func WithResolutionObserver(observer ResolutionObserver) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.ResolutionObserver = observer
+	}
+}
+
+// WithLocationStatuses configures the handler to obscure the 'Location'
+// header only for responses whose status code is of the provided classes,
+// such as StatusRedirection, leaving it as is otherwise, such as for the
+// 'Location' of a resource created with 201 Created.
+func WithLocationStatuses(classes ...StatusClass) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.LocationStatuses = append(o.LocationStatuses, classes...)
+	}
 }
 
 // NewHandler constructs an HTTP handler capable of handling requests with obscured URLs.
-func NewHandler(o Obscurer, s Store, h http.Handler) http.Handler {
-	return &handler{handler: h, obscurer: o, store: s}
+func NewHandler(o Obscurer, s Store, h http.Handler, opts ...HandlerOption) http.Handler {
+	hdlr := &handler{handler: h, obscurer: o, store: s, obscurers: &policyObscurers{}, notFounds: &notFounds{}}
+	for _, opt := range opts {
+		opt(&hdlr.options)
+	}
+	if hdlr.options.Obscurer != nil {
+		hdlr.obscurer = hdlr.options.Obscurer
+	}
+	if hdlr.options.Store != nil {
+		hdlr.store = hdlr.options.Store
+	}
+	hdlr.links = newBodyLinks(hdlr.options)
+	if hdlr.options.IDCodec != nil && len(hdlr.options.IDFields) > 0 {
+		hdlr.ids = &idFields{codec: hdlr.options.IDCodec, fields: make(map[string]bool)}
+		for _, field := range hdlr.options.IDFields {
+			hdlr.ids.fields[field] = true
+		}
+	}
+	return hdlr
+}
+
+// WithStrict configures the handler to respond with 404 Not Found to the
+// requests whose URL isn't a known obscured URL, rather than handling them
+// as is, such that only obscured URLs are routable. Requests excluded by
+// WithExclusions, and the documents the handler serves itself, are handled
+// regardless. Requests whose URL fails to be looked up fail even when the
+// handler fails open.
+func WithStrict() HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Strict = true
+	}
+}
+
+// WithOverheadReporting configures the handler to report the time spent
+// resolving and rewriting URLs for each request in the OverheadHeader of the
+// response. It is intended for performance investigations.
+func WithOverheadReporting() HandlerOption {
+	return func(o *HandlerOptions) {
+		o.ReportOverhead = true
+	}
+}
+
+// WithMaxBufferedBody configures the maximum length of the response bodies
+// that are buffered to be rewritten, such that the memory held by a request
+// is bounded. Longer bodies are passed through as is, without their URLs
+// being obscured.
+func WithMaxBufferedBody(n int) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.MaxBufferedBody = n
+	}
+}
+
+// WithTTL configures the duration the mappings placed into the store are kept
+// for. It has no effect unless the store is an ExpiringStore.
+func WithTTL(ttl time.Duration) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.TTL = ttl
+	}
+}
+
+// WithIDFields configures the handler to encode the identifiers held by the
+// members of JSON bodies with the provided names using the provided codec,
+// so that clients see the same opaque identifiers in bodies as in URLs.
+// Identifiers are encoded within responses and decoded within requests, and
+// are matched at any depth, including within arrays. Codecs implementing
+// ParameterIDCodec select the codec of each member by its name. Bodies are
+// identified by their Content-Type, and those that aren't valid JSON are
+// left as is.
+func WithIDFields(codec IDCodec, fields ...string) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.IDCodec = codec
+		o.IDFields = fields
+	}
+}
+
+// WithUses configures the number of times the mappings placed into the
+// store resolve, such that obscured URLs become one-time or N-use links.
+// Each request resolved with the store consumes a use of its mapping. It
+// has no effect unless the store is a ConsumingStore, takes precedence
+// over WithTTL, and doesn't apply to URLs that the obscurer resolves
+// without the store.
+func WithUses(uses int) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Uses = uses
+	}
+}
+
+// WithNamespacer configures the handler to carry the namespace of each
+// request, as provided by the provided namespacer, within the context of
+// the request. Namespaced stores partition mappings by the namespace, so
+// that tenants can't resolve the obscured URLs of one another. Obscurers
+// that are Resolvers resolve URLs without the store, and therefore without
+// regard for namespaces.
+func WithNamespacer(n Namespacer) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Namespacer = n
+	}
+}
+
+// WithPolicies configures the handler to apply the policies of the
+// namespaces of requests, as provided by the provided policies, overriding
+// the TTL and obscurer of the handler for the namespace. The namespace of a
+// request is carried by its context, such as with WithNamespacer.
+// Obscurers are selected by the names they are registered under, and
+// constructed with their default options.
+func WithPolicies(p Policies) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Policies = p
+	}
+}
+
+// withPolicy provides a copy of the handler with the provided policy
+// applied.
+func (h *handler) withPolicy(p Policy) (*handler, error) {
+	applied := *h
+	if p.TTL > 0 {
+		applied.options.TTL = p.TTL
+	}
+	if p.Obscurer != "" {
+		o, err := h.obscurers.get(p.Obscurer)
+		if err != nil {
+			return nil, err
+		}
+		applied.obscurer = o
+	}
+	return &applied, nil
+}
+
+// policy provides the handler with the policy of the namespace of the
+// provided request applied, if any.
+func (h *handler) policy(r *http.Request) (*handler, error) {
+	if h.options.Policies == nil {
+		return h, nil
+	}
+	namespace, ok := NamespaceFromContext(r.Context())
+	if !ok {
+		return h, nil
+	}
+	p, ok, err := h.options.Policies.Policy(r.Context(), namespace)
+	if err != nil || !ok {
+		return h, err
+	}
+	return h.withPolicy(p)
 }
 
 // ServeHTTP handles the HTTP request.
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flags := h.flags()
+	if flags.Bypass || h.excluded(r) {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+	if h.options.WellKnown != nil && r.URL.Path == WellKnownPath {
+		wellKnown(w, r, *h.options.WellKnown, h.obscurer)
+		return
+	}
+	if h.options.Namespacer != nil {
+		if namespace := h.options.Namespacer(r); namespace != "" {
+			r = r.WithContext(ContextWithNamespace(r.Context(), namespace))
+		}
+	}
+	p, err := h.policy(r)
+	if err != nil {
+		h.fail(w, r, ErrFailedPolicy, err, lookupStatus(err))
+		return
+	}
+	h = p
+	if len(h.options.Sitemap) > 0 && r.URL.Path == SitemapPath {
+		h.sitemap(w, r)
+		return
+	}
 	ctx := r.Context()
+	start := time.Now()
 	// assume incoming request is obscured.
-	if unobscured, ok := h.store.Get(ctx, r.URL); ok {
-		r.URL = unobscured
+	requested := r.URL
+	unobscured, ok, err := h.resolve(ctx, r.URL)
+	h.observeResolution(ctx, ok, err)
+	switch {
+	case err != nil:
+		h.logger().Warnf("obscurer: failed to look up %s: %v", r.URL, err)
+	case !ok:
+		h.logger().Debugf("obscurer: no mapping for %s", r.URL)
+	}
+	if err == nil && !ok {
+		var gone bool
+		gone, err = h.buried(ctx, r.URL)
+		if err == nil && gone {
+			h.fail(w, r, ErrGone, nil, http.StatusGone)
+			return
+		}
+	}
+	strict := h.options.Strict || flags.Strict
+	if err != nil && (!flags.FailOpen || strict) {
+		h.fail(w, r, ErrFailedLookup, err, lookupStatus(err))
+		return
+	}
+	if !ok && strict {
+		h.fail(w, r, ErrNotObscured, nil, http.StatusNotFound)
+		return
+	}
+	if !ok && matchAny(h.options.ObscuredPaths, r.URL.Path) {
+		h.fail(w, r, ErrUnknownURL, nil, http.StatusNotFound)
+		return
+	}
+	if ok {
+		resolveURL(r, unobscured)
+	}
+	if h.options.ResolveReferer {
+		h.resolveReferer(ctx, r)
 	}
 
-	// handle the request.
-	rw := &responseWriter{ResponseWriter: w}
-	defer func() {
-		if _, err := rw.Do(); err != nil {
-			http.Error(rw, err.Error(), 500)
+	// decode the identifiers and resolve the URLs within the request body.
+	// bodies are only rewritten for the requests sampled.
+	bodies := !flags.DisableBodyRewriting && h.rewritesBodies()
+	sampled := bodies && h.sample()
+	rewriteIDs := h.ids != nil && sampled
+	resolveBody := h.options.ResolveRequestBodies && sampled
+	rewriteLinks := h.links != nil && sampled
+	rewriteHTML := h.options.RewriteHTML && sampled
+	rewriteFeeds := h.options.RewriteFeeds && sampled
+	rewriteSitemap := h.options.RewriteSitemaps && r.URL.Path == SitemapPath && sampled
+	if (rewriteIDs || resolveBody) && r.Body != nil && isJSON(r.Header.Get("Content-Type")) && !isEncoded(r.Header.Get("Content-Encoding")) {
+		var lookup *lookupError
+		err := h.decodeBody(w, r, rewriteIDs, resolveBody)
+		switch {
+		case err == ErrRequestBodyTooLarge:
+			h.fail(w, r, ErrRequestBodyTooLarge, nil, http.StatusRequestEntityTooLarge)
+			return
+		case errors.As(err, &lookup) && !flags.FailOpen:
+			h.fail(w, r, ErrFailedLookup, lookup.err, lookupStatus(lookup.err))
+			return
+		case err != nil && lookup == nil:
+			h.fail(w, r, ErrFailedRequestBody, err, http.StatusBadRequest)
+			return
 		}
-	}()
-	h.handler.ServeHTTP(rw, r)
+	}
+	overhead := time.Since(start)
 
+	// handle the request, committing the headers of the response before
+	// its body is written.
+	rw := &responseWriter{ResponseWriter: w, limit: h.options.MaxBufferedBody}
+	rw.commit = func() error {
+		start := time.Now()
+		if err := h.commit(ctx, r, requested, ok, rw); err != nil {
+			return err
+		}
+		// identifiers and URLs are rewritten once the entire body is
+		// available, while server-sent events are flushed as soon as they
+		// are written. encoded bodies, such as gzipped ones, are passed
+		// through as is rather than buffered.
+		contentType := rw.Header().Get("Content-Type")
+		rw.buffer = ((rewriteIDs || rewriteLinks) && isJSON(contentType)) ||
+			(rewriteHTML && isHTML(contentType)) ||
+			((rewriteFeeds || rewriteSitemap) && isXML(contentType))
+		if rw.buffer && isEncoded(rw.Header().Get("Content-Encoding")) {
+			h.logger().Debugf("obscurer: the body of %s is encoded, so it was passed through as is", requested)
+			rw.buffer = false
+		}
+		rw.stream = isEventStream(contentType)
+		overhead += time.Since(start)
+		if !rw.buffer {
+			h.reportOverhead(rw.Header(), overhead)
+		}
+		return nil
+	}
+	if h.serve(rw, r) {
+		return
+	}
+	if rw.overflowed {
+		h.logger().Debugf("obscurer: the body of %s exceeds %d bytes, so it was passed through as is", requested, rw.limit)
+	}
+
+	// rewrite the identifiers and URLs within the response body.
+	if rw.start() == nil && rw.buffer {
+		start := time.Now()
+		if len(rw.body) > 0 {
+			var body []byte
+			var err error
+			switch contentType := rw.Header().Get("Content-Type"); {
+			case isHTML(contentType):
+				body, err = h.rewriteMarkupBody(ctx, htmlMarkup, r.Host, rw.body)
+			case isXML(contentType) && rewriteSitemap:
+				body, err = h.rewriteMarkupBody(ctx, sitemapMarkup, r.Host, rw.body)
+			case isXML(contentType):
+				body, err = h.rewriteMarkupBody(ctx, feedMarkup, r.Host, rw.body)
+			default:
+				body, err = h.rewriteBody(ctx, rw.body, rewriteIDs, rewriteLinks)
+			}
+			if err != nil {
+				h.fail(rw.ResponseWriter, r, ErrBodyFailure, err, http.StatusInternalServerError)
+				return
+			}
+			if body != nil {
+				rw.body = body
+			}
+		}
+		overhead += time.Since(start)
+		h.reportOverhead(rw.Header(), overhead)
+	}
+	rw.Close()
+	if bodies {
+		h.observeSampling(ctx, sampled, overhead)
+	}
+}
+
+// commit finalizes the headers of the provided response before it is
+// written, removing the entry of the provided requested URL, when it
+// resolved, for a resource that doesn't exist, and obscuring the URLs within
+// its headers. Upon failure, the response is replaced with the response of
+// the error handler.
+func (h *handler) commit(ctx context.Context, r *http.Request, requested *url.URL, resolved bool, rw *responseWriter) error {
 	// remove entries for resources that don't exist.
-	if rw.status == 404 {
-		if err := h.store.Remove(ctx, r.URL); err != nil {
-			http.Error(rw, ErrFailedRemoval.Error(), 500)
+	remove, err := h.removable(ctx, resolved, requested, r.URL, rw.status)
+	if err == nil && remove {
+		err = h.store.Remove(ctx, requested)
+	}
+	if err != nil {
+		h.logger().Warnf("obscurer: failed to remove the mapping of %s: %v", requested, err)
+		h.fail(rw.ResponseWriter, r, ErrFailedRemoval, err, http.StatusInternalServerError)
+		return ErrFailedRemoval
+	}
+
+	status := rw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	headers := h.headers(status)
+
+	// place the mappings of every header at once when possible.
+	placed := h.putHeaders(ctx, rw.Header(), headers)
+
+	for _, header := range headers {
+		if err := h.obscureHeader(ctx, rw, header.key, header.parse, placed); err != nil {
+			h.logger().Warnf("obscurer: failed to obscure the %s header: %v", header.key, err)
+			h.fail(rw.ResponseWriter, r, header.failure, err, http.StatusInternalServerError)
+			return header.failure
 		}
 	}
+	return nil
+}
 
-	// obscure 'Location'.
-	// see: https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Location
-	if err := h.obscureHeader(ctx, rw, "Location", defaultParseHeader); err != nil {
-		http.Error(rw, ErrLocationHeaderFailure.Error(), 500)
+// headers provides the headers obscured for a response with the provided
+// status code.
+func (h *handler) headers(status int) []obscuredHeader {
+	headers := make([]obscuredHeader, 0, len(obscuredHeaders))
+	for _, header := range obscuredHeaders {
+		if header.key == "Location" && !h.locationStatus(status) {
+			continue
+		}
+		if !h.obscuresHeader(header.key) {
+			continue
+		}
+		headers = append(headers, header)
 	}
+	return headers
+}
 
-	// obscure 'Content-Location'.
-	// see: https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Content-Location
-	if err := h.obscureHeader(ctx, rw, "Content-Location", defaultParseHeader); err != nil {
-		http.Error(rw, ErrContentLocationHeaderFailure.Error(), 500)
+// obscuresHeader indicates whether the header with the provided key is
+// obscured.
+func (h *handler) obscuresHeader(key string) bool {
+	if len(h.options.Headers) == 0 {
+		return true
+	}
+	for _, header := range h.options.Headers {
+		if http.CanonicalHeaderKey(header) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// locationStatus indicates whether the 'Location' header is obscured for a
+// response with the provided status code.
+func (h *handler) locationStatus(status int) bool {
+	if len(h.options.LocationStatuses) == 0 {
+		return true
+	}
+	for _, class := range h.options.LocationStatuses {
+		if class.Contains(status) {
+			return true
+		}
 	}
+	return false
+}
 
-	// obscure 'Link'.
-	// see: https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Link
-	if err := h.obscureHeader(ctx, rw, "Link", parseLinkHeader); err != nil {
-		http.Error(rw, ErrLinkHeaderFailure.Error(), 500)
+// reportOverhead reports the provided overhead, excluding the time spent in
+// the wrapped handler, within the provided headers when configured to.
+func (h *handler) reportOverhead(headers http.Header, overhead time.Duration) {
+	if h.options.ReportOverhead {
+		ms := float64(overhead) / float64(time.Millisecond)
+		headers.Set(OverheadHeader, strconv.FormatFloat(ms, 'f', 3, 64))
 	}
 }
 
+// putHeaders places the mappings for the URLs of the obscured headers into
+// the store at once when the store is a BatchStore, providing the obscured
+// URLs keyed by their originals. Nil is provided when the mappings are to be
+// placed one by one, including when placing them at once fails, such that
+// collisions are resolved and errors reported for each header.
+func (h *handler) putHeaders(ctx context.Context, headers http.Header, obscured []obscuredHeader) map[string]*url.URL {
+	batch, ok := h.store.(BatchStore)
+	if !ok || h.options.TTL > 0 || h.options.Uses > 0 {
+		return nil
+	}
+	placed := make(map[string]*url.URL)
+	mappings := make(map[*url.URL]*url.URL)
+	var exceeded bool
+	collect := func(target string) (string, error) {
+		u, err := url.Parse(target)
+		if target == "" || err != nil {
+			return target, nil
+		}
+		if _, ok := placed[u.String()]; ok || !h.included(u) {
+			return target, nil
+		}
+		o := h.obscurer.Obscure(u)
+		if h.options.Limits.Check(o, u) != nil {
+			exceeded = true
+		}
+		placed[u.String()] = o
+		if !h.resolvable(o, u) {
+			mappings[o] = normalized(h.obscurer, u)
+		}
+		return target, nil
+	}
+	for _, o := range obscured {
+		for _, header := range headers.Values(o.key) {
+			o.parse(header, collect)
+		}
+	}
+	if exceeded {
+		// place the mappings one by one, reporting the error.
+		return nil
+	}
+	if len(mappings) < 2 {
+		return nil
+	}
+	if err := batch.PutAll(ctx, mappings); err != nil {
+		h.logger().Debugf("obscurer: failed to place %d mappings at once: %v", len(mappings), err)
+		return nil
+	}
+	return placed
+}
+
 // obscureHeader obscures the header with the provided key using the provided
-// header parser.
-func (h *handler) obscureHeader(ctx context.Context, w http.ResponseWriter, key string, parse headerParser) error {
-	// grab the header value.
+// header parser. URLs found in the provided placed URLs are not placed into
+// the store again.
+func (h *handler) obscureHeader(ctx context.Context, w http.ResponseWriter, key string, parse headerParser, placed map[string]*url.URL) error {
+	// grab the header values.
 	headers := w.Header()
-	header := headers.Get(key)
-	// parse the URL data from the header.
-	parsedHeader := parse(header)
-	if header == "" {
+	if headers.Get(key) == "" {
 		return nil
 	}
-	url, err := url.Parse(parsedHeader)
+	outcome, err := h.rewriteHeader(ctx, headers, key, parse, placed)
+	if h.options.HeaderObserver != nil {
+		h.options.HeaderObserver(ctx, key, outcome)
+	}
+	return err
+}
+
+// rewriteHeader replaces the URLs within the values of the provided header
+// with their obscured form.
+func (h *handler) rewriteHeader(ctx context.Context, headers http.Header, key string, parse headerParser, placed map[string]*url.URL) (HeaderOutcome, error) {
+	outcome := HeaderSkipped
+	replace := func(target string) (string, error) {
+		if target == "" {
+			return target, nil
+		}
+		u, err := url.Parse(target)
+		if err != nil {
+			return "", err
+		}
+		// obscure the URL.
+		obscured, ok := placed[u.String()]
+		if !ok {
+			obscured, err = h.obscure(ctx, u)
+		}
+		if err != nil {
+			return "", err
+		}
+		if obscured == nil {
+			return target, nil
+		}
+		outcome = HeaderRewritten
+		return obscured.String(), nil
+	}
+	values := headers.Values(key)
+	rewritten := make([]string, len(values))
+	for i, header := range values {
+		var err error
+		rewritten[i], err = parse(header, replace)
+		if errors.Is(err, ErrMalformedLink) {
+			// values that don't conform are left as is.
+			h.logger().Warnf("obscurer: left the %s header %q as is: %v", key, header, err)
+			rewritten[i], err = header, nil
+		}
+		if err != nil {
+			return HeaderFailed, err
+		}
+	}
+	headers.Del(key)
+	for _, header := range rewritten {
+		headers.Add(key, header)
+	}
+	return outcome, nil
+}
+
+// obscure obscures the provided URL and places the mapping into the store.
+// collisions are resolved by rerolling the obscured URL when the obscurer
+// supports it. Nil is provided for URLs that aren't included.
+func (h *handler) obscure(ctx context.Context, u *url.URL) (*url.URL, error) {
+	if !h.included(u) {
+		return nil, nil
+	}
+	obscured := h.obscurer.Obscure(u)
+	err := h.put(ctx, obscured, u)
+	reroller, ok := h.obscurer.(Reroller)
+	for attempt := 1; ok && errors.Is(err, ErrCollision) && attempt <= maxRerolls; attempt++ {
+		obscured = reroller.Reroll(u, attempt)
+		err = h.put(ctx, obscured, u)
+	}
+	return obscured, err
+}
+
+// resolve resolves the provided obscured URL to its original form, with the
+// obscurer when it is a Resolver, and with the store otherwise, consuming a
+// use of the mapping when the store is a ConsumingStore.
+func (h *handler) resolve(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	if resolver, ok := h.obscurer.(Resolver); ok {
+		if original, ok := resolver.Resolve(obscured); ok {
+			return original, true, nil
+		}
+	}
+	if consuming, ok := h.store.(ConsumingStore); ok {
+		return consuming.Consume(ctx, obscured)
+	}
+	return h.store.Get(ctx, obscured)
+}
+
+// observeResolution notifies the resolution observer, if any, of the outcome
+// of resolving the URL of a request.
+func (h *handler) observeResolution(ctx context.Context, ok bool, err error) {
+	if h.options.ResolutionObserver == nil {
+		return
+	}
+	outcome := ResolutionUnresolved
+	switch {
+	case err != nil:
+		outcome = ResolutionFailed
+	case ok:
+		outcome = ResolutionResolved
+	}
+	h.options.ResolutionObserver(ctx, outcome)
+}
+
+// resolveURL replaces the URL of the provided request with the provided
+// original, keeping its raw path and the request URI of the request, which
+// frameworks and access logs read, consistent with it. The query of the
+// request is merged into that of the original, such that the parameters of
+// the request, such as for pagination, override those of the original.
+func resolveURL(r *http.Request, original *url.URL) {
+	resolved := *original
+	if resolved.RawPath != "" && resolved.EscapedPath() != resolved.RawPath {
+		resolved.RawPath = ""
+	}
+	resolved.RawQuery = mergeQuery(resolved.RawQuery, r.URL.RawQuery)
+	r.URL = &resolved
+	if r.RequestURI != "" {
+		r.RequestURI = resolved.RequestURI()
+	}
+}
+
+// mergeQuery merges the provided incoming query into the provided original
+// query, the parameters of the incoming query replacing those of the
+// original with the same name. Either is kept as is when the other is
+// empty or they are equal.
+func mergeQuery(original, incoming string) string {
+	if incoming == "" || incoming == original {
+		return original
+	}
+	if original == "" {
+		return incoming
+	}
+	merged, err := url.ParseQuery(original)
 	if err != nil {
+		return incoming
+	}
+	params, err := url.ParseQuery(incoming)
+	if err != nil {
+		return original
+	}
+	for name, values := range params {
+		merged[name] = values
+	}
+	return merged.Encode()
+}
+
+// buried indicates whether the mapping of the provided obscured URL was
+// removed, when the store is a TombstoneStore.
+func (h *handler) buried(ctx context.Context, obscured *url.URL) (bool, error) {
+	tombstones, ok := h.store.(TombstoneStore)
+	if !ok {
+		return false, nil
+	}
+	return tombstones.Buried(ctx, obscured)
+}
+
+// lookupStatus provides the status code of the response for a request whose
+// URL failed to be looked up in the store with the provided error.
+func lookupStatus(err error) int {
+	var temporary interface{ Temporary() bool }
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &temporary) && temporary.Temporary()) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// resolvable indicates whether the provided obscured URL resolves to the
+// provided original without the store.
+func (h *handler) resolvable(obscured, original *url.URL) bool {
+	resolver, ok := h.obscurer.(Resolver)
+	if !ok {
+		return false
+	}
+	resolved, ok := resolver.Resolve(obscured)
+	return ok && resolved.Path == original.Path
+}
+
+// put places the mapping into the store, expiring it after the configured
+// TTL when the store supports it. The original form is normalized as the
+// obscurer normalizes it, so that equivalent URLs share their mapping.
+func (h *handler) put(ctx context.Context, obscured, original *url.URL) error {
+	original = normalized(h.obscurer, original)
+	if err := h.options.Limits.Check(obscured, original); err != nil {
 		return err
 	}
-	// obscure the URL.
-	obscured := h.obscurer.Obscure(url)
-	if obscured != nil {
-		obscuredHeader := strings.ReplaceAll(header, url.String(), obscured.String())
-		headers.Set(key, obscuredHeader)
+	if h.resolvable(obscured, original) {
+		// the obscured URL resolves without the store.
+		return nil
+	}
+	if consuming, ok := h.store.(ConsumingStore); ok && h.options.Uses > 0 {
+		return consuming.PutWithUses(ctx, obscured, original, h.options.Uses)
+	}
+	if expiring, ok := h.store.(ExpiringStore); ok && h.options.TTL > 0 {
+		return expiring.PutWithTTL(ctx, obscured, original, h.options.TTL)
 	}
-	return h.store.Put(ctx, obscured, url)
+	return h.store.Put(ctx, obscured, original)
 }
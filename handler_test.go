@@ -19,11 +19,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/freerware/obscurer"
 	"github.com/freerware/obscurer/internal/mock"
@@ -81,7 +86,7 @@ func TestHandler_ObscuredRequestURL(t *testing.T) {
 
 	u := mustParse(fmt.Sprintf("%s/this/is/the/way", server.URL))
 	obscuredURL := obscurer.Default.Obscure(u)
-	err := store.Load(ctx, map[*url.URL]*url.URL{
+	err := obscurer.LoadAll(ctx, store, map[*url.URL]*url.URL{
 		obscuredURL: u,
 	})
 	if err != nil {
@@ -95,7 +100,8 @@ func TestHandler_ObscuredRequestURL(t *testing.T) {
 	assert.Equalf(http.StatusOK, response.StatusCode, "expected status code 200, got status code %d", response.StatusCode)
 	assert.True(handled, "expected for the request to be handled")
 	assert.Equalf(1, store.Size(ctx), "expected the store to have one entry")
-	_, ok := store.Get(ctx, obscuredURL)
+	_, ok, err := store.Get(ctx, obscuredURL)
+	require.NoError(err)
 	assert.True(ok, "expected the store to have entry for the obscured URL")
 
 	// cleanup.
@@ -157,7 +163,7 @@ func TestHandler_404Request_RemovalError(t *testing.T) {
 
 	u := mustParse(fmt.Sprintf("%s/this/is/not/the/way", server.URL))
 	obscuredURL := obscurer.Default.Obscure(u)
-	store.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, false)
+	store.EXPECT().Get(gomock.Any(), gomock.Any()).Return(mustParse("/this/is/not/the/way"), true, nil)
 	store.EXPECT().Remove(gomock.Any(), gomock.Any()).Return(expectedErr)
 
 	// action + assert.
@@ -213,7 +219,7 @@ func TestHandler_LocationHeader_InvalidURL(t *testing.T) {
 	ctx := context.Background()
 	mux := http.NewServeMux()
 	mux.HandleFunc("/this/is/the/way", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Add("Location", "example.com\foo")
+		w.Header().Add("Location", ":foo")
 		w.WriteHeader(http.StatusOK)
 	})
 	store := obscurer.DefaultStore
@@ -257,7 +263,7 @@ func TestHandler_LocationHeader_PutError(t *testing.T) {
 	server := httptest.NewServer(handler)
 	defer server.Close()
 
-	store.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, false)
+	store.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, false, nil)
 	store.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(expectedErr)
 
 	// action + assert.
@@ -271,6 +277,102 @@ func TestHandler_LocationHeader_PutError(t *testing.T) {
 	assert.Equal(want, responseBody, "expected body to be %q, got %q", want, responseBody)
 }
 
+// TestHandler_LocationHeader_Collision tests that the 'Location' header is
+// obscured using a rerolled URL when the obscured URL collides with an
+// existing mapping.
+func TestHandler_LocationHeader_Collision(t *testing.T) {
+	// arrange.
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+	location := mustParse("/hey/der")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/this/is/the/way", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Location", location.String())
+		w.WriteHeader(http.StatusOK)
+	})
+	store := obscurer.DefaultStore
+	err := store.Put(ctx, obscurer.Default.Obscure(location), mustParse("/bye/der"))
+	require.NoError(err)
+	rerolledLocation := obscurer.Default.Reroll(location, 1)
+	handler := obscurer.NewHandler(obscurer.Default, store, mux)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// action + assert.
+	response, err := http.Get(fmt.Sprintf("%s/this/is/the/way", server.URL))
+	require.NoError(err)
+	assert.Equalf(http.StatusOK, response.StatusCode, "expected status code 200, got status code %d", response.StatusCode)
+	assert.Equalf(2, store.Size(ctx), "expected the store to have two entries")
+	got := response.Header.Get("Location")
+	want := rerolledLocation.String()
+	assert.Equal(want, got, "expected 'Location' header to be %q, not %q", want, got)
+	original, ok, err := store.Get(ctx, rerolledLocation)
+	require.NoError(err)
+	require.True(ok)
+	assert.Equal(location.String(), original.String())
+
+	// cleanup.
+	t.Cleanup(func() {
+		store.Clear(ctx)
+	})
+}
+
+// TestHandler_LocationStatuses tests that the 'Location' header is only
+// obscured for responses whose status code is of the configured classes.
+func TestHandler_LocationStatuses(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		obscured bool
+	}{
+		{name: "Found", status: http.StatusFound, obscured: true},
+		{name: "Created", status: http.StatusCreated, obscured: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx := context.Background()
+			t.Cleanup(func() { obscurer.DefaultStore.Clear(ctx) })
+			mux := http.NewServeMux()
+			mux.HandleFunc("/this/is/the/way", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Location", "/hey/der")
+				w.WriteHeader(test.status)
+			})
+			handler := obscurer.NewHandler(
+				obscurer.Default,
+				obscurer.DefaultStore,
+				mux,
+				obscurer.WithLocationStatuses(obscurer.StatusRedirection),
+			)
+			server := httptest.NewServer(handler)
+			defer server.Close()
+			client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			}}
+
+			// action.
+			response, err := client.Get(fmt.Sprintf("%s/this/is/the/way", server.URL))
+
+			// assert.
+			require.NoError(err)
+			assert.Equal(test.status, response.StatusCode)
+			expected := "/hey/der"
+			if test.obscured {
+				expected = obscurer.Default.Obscure(mustParse("/hey/der")).String()
+			}
+			assert.Equal(expected, response.Header.Get("Location"))
+			size := 0
+			if test.obscured {
+				size = 1
+			}
+			assert.Equal(size, obscurer.DefaultStore.Size(ctx))
+		})
+	}
+}
+
 // TestHandler_ContentLocationHeader tests that the 'Content-Location'
 // header is obscured.
 func TestHandler_ContentLocationHeader(t *testing.T) {
@@ -314,7 +416,7 @@ func TestHandler_ContentLocationHeader_InvalidURL(t *testing.T) {
 	ctx := context.Background()
 	mux := http.NewServeMux()
 	mux.HandleFunc("/this/is/the/way", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Add("Content-Location", "example.com\foo")
+		w.Header().Add("Content-Location", ":foo")
 		w.WriteHeader(http.StatusOK)
 	})
 	store := obscurer.DefaultStore
@@ -358,7 +460,7 @@ func TestHandler_ContentLocationHeader_PutError(t *testing.T) {
 	server := httptest.NewServer(handler)
 	defer server.Close()
 
-	store.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, false)
+	store.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, false, nil)
 	store.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(expectedErr)
 
 	// action + assert.
@@ -405,6 +507,39 @@ func TestHandler_LinkHeader(t *testing.T) {
 	})
 }
 
+// TestHandler_LinkHeader_MultipleLinks tests that every link of the 'Link'
+// header is obscured, whether on separate lines or separated by commas.
+func TestHandler_LinkHeader_MultipleLinks(t *testing.T) {
+	// arrange.
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+	t.Cleanup(func() { obscurer.DefaultStore.Clear(ctx) })
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orders", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Link", `</orders?page=1>; rel="first", </orders?page=3>; rel="next"`)
+		w.Header().Add("Link", `</orders?page=9>; rel="last"`)
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := obscurer.NewHandler(obscurer.Default, obscurer.DefaultStore, mux)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	obscure := func(link string) string {
+		return obscurer.Default.Obscure(mustParse(link)).String()
+	}
+
+	// action.
+	response, err := http.Get(fmt.Sprintf("%s/orders", server.URL))
+
+	// assert.
+	require.NoError(err)
+	assert.Equal(http.StatusOK, response.StatusCode)
+	assert.Equal([]string{
+		fmt.Sprintf(`<%s>; rel="first", <%s>; rel="next"`, obscure("/orders?page=1"), obscure("/orders?page=3")),
+		fmt.Sprintf(`<%s>; rel="last"`, obscure("/orders?page=9")),
+	}, response.Header.Values("Link"))
+}
+
 // TestHandler_LinkHeader_InvalidURL tests that an HTTP 500 is returned
 // when an invalid URL is provided for the 'Link' header.
 func TestHandler_LinkHeader_InvalidURL(t *testing.T) {
@@ -414,7 +549,7 @@ func TestHandler_LinkHeader_InvalidURL(t *testing.T) {
 	ctx := context.Background()
 	mux := http.NewServeMux()
 	mux.HandleFunc("/this/is/the/way", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Add("Link", "<example.com\foo>; rel='next'")
+		w.Header().Add("Link", "<:foo>; rel='next'")
 		w.WriteHeader(http.StatusOK)
 	})
 	store := obscurer.DefaultStore
@@ -458,7 +593,7 @@ func TestHandler_LinkHeader_PutError(t *testing.T) {
 	server := httptest.NewServer(handler)
 	defer server.Close()
 
-	store.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, false)
+	store.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, false, nil)
 	store.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(expectedErr)
 
 	// action + assert.
@@ -472,6 +607,794 @@ func TestHandler_LinkHeader_PutError(t *testing.T) {
 	assert.Equal(want, responseBody, "expected body to be %q, got %q", want, responseBody)
 }
 
+// TestHandler_HeaderObserver tests that the header observer is notified of
+// the outcome of obscuring each header present in the response.
+func TestHandler_HeaderObserver(t *testing.T) {
+	// arrange.
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/this/is/the/way", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Location", "/hey/der")
+		w.Header().Add("Link", "no url here")
+		w.WriteHeader(http.StatusOK)
+	})
+	store := obscurer.DefaultStore
+	var mu sync.Mutex
+	outcomes := make(map[string]obscurer.HeaderOutcome)
+	observer := func(ctx context.Context, header string, outcome obscurer.HeaderOutcome) {
+		mu.Lock()
+		defer mu.Unlock()
+		outcomes[header] = outcome
+	}
+	handler := obscurer.NewHandler(
+		obscurer.Default, store, mux, obscurer.WithHeaderObserver(observer))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// action.
+	response, err := http.Get(fmt.Sprintf("%s/this/is/the/way", server.URL))
+
+	// assert.
+	require.NoError(err)
+	assert.Equal(http.StatusOK, response.StatusCode)
+	assert.Equal("no url here", response.Header.Get("Link"))
+	mu.Lock()
+	defer mu.Unlock()
+	want := map[string]obscurer.HeaderOutcome{
+		"Location": obscurer.HeaderRewritten,
+		"Link":     obscurer.HeaderSkipped,
+	}
+	assert.Equal(want, outcomes)
+
+	// cleanup.
+	t.Cleanup(func() {
+		store.Clear(ctx)
+	})
+}
+
+// TestHandler_HeaderObserver_Failed tests that the header observer is
+// notified when a header fails to be obscured.
+func TestHandler_HeaderObserver_Failed(t *testing.T) {
+	// arrange.
+	assert := assert.New(t)
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/this/is/the/way", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Location", "/hey/der")
+		w.WriteHeader(http.StatusOK)
+	})
+	store := mock.NewStore(ctrl)
+	var mu sync.Mutex
+	outcomes := make(map[string]obscurer.HeaderOutcome)
+	observer := func(ctx context.Context, header string, outcome obscurer.HeaderOutcome) {
+		mu.Lock()
+		defer mu.Unlock()
+		outcomes[header] = outcome
+	}
+	handler := obscurer.NewHandler(
+		obscurer.Default, store, mux, obscurer.WithHeaderObserver(observer))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	store.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, false, nil)
+	store.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("whoa"))
+
+	// action.
+	response, err := http.Get(fmt.Sprintf("%s/this/is/the/way", server.URL))
+
+	// assert.
+	require.NoError(err)
+	assert.Equal(http.StatusInternalServerError, response.StatusCode)
+	mu.Lock()
+	defer mu.Unlock()
+	want := map[string]obscurer.HeaderOutcome{
+		"Content-Location": obscurer.HeaderFailed,
+	}
+	assert.Equal(want, outcomes)
+}
+
+// TestHandler_ResolutionObserver tests that the resolution observer is
+// notified of the outcome of resolving the URL of each request.
+func TestHandler_ResolutionObserver(t *testing.T) {
+	original := mustParse("/this/is/the/way")
+	obscured := obscurer.Default.Obscure(original)
+	tests := []struct {
+		name    string
+		target  string
+		err     error
+		outcome obscurer.ResolutionOutcome
+	}{
+		{name: "Resolved", target: obscured.String(), outcome: obscurer.ResolutionResolved},
+		{name: "Unresolved", target: original.String(), outcome: obscurer.ResolutionUnresolved},
+		{name: "Failed", target: obscured.String(), err: errors.New("whoa"), outcome: obscurer.ResolutionFailed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mock.NewStore(ctrl)
+			store.EXPECT().Get(gomock.Any(), obscured).Return(original, true, test.err).AnyTimes()
+			store.EXPECT().Get(gomock.Any(), original).Return(nil, false, nil).AnyTimes()
+			store.EXPECT().Remove(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			outcomes := []obscurer.ResolutionOutcome{}
+			observer := func(ctx context.Context, outcome obscurer.ResolutionOutcome) {
+				outcomes = append(outcomes, outcome)
+			}
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+			handler := obscurer.NewHandler(
+				obscurer.Default, store, h, obscurer.WithResolutionObserver(observer))
+
+			// action.
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, test.target, nil))
+
+			// assert.
+			assert.Equal(t, []obscurer.ResolutionOutcome{test.outcome}, outcomes)
+		})
+	}
+}
+
+// TestHandler_OverheadReporting tests that the overhead header is only
+// present when overhead reporting is enabled.
+func TestHandler_OverheadReporting(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []obscurer.HandlerOption
+		present bool
+	}{
+		{name: "Enabled", opts: []obscurer.HandlerOption{obscurer.WithOverheadReporting()}, present: true},
+		{name: "Disabled", present: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx := context.Background()
+			mux := http.NewServeMux()
+			mux.HandleFunc("/this/is/the/way", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("Location", "/hey/der")
+				w.WriteHeader(http.StatusCreated)
+			})
+			store := obscurer.DefaultStore
+			handler := obscurer.NewHandler(obscurer.Default, store, mux, test.opts...)
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			// action.
+			response, err := http.Get(fmt.Sprintf("%s/this/is/the/way", server.URL))
+
+			// assert.
+			require.NoError(err)
+			assert.Equal(http.StatusCreated, response.StatusCode)
+			overhead := response.Header.Get(obscurer.OverheadHeader)
+			if test.present {
+				ms, err := strconv.ParseFloat(overhead, 64)
+				require.NoError(err)
+				assert.True(ms >= 0, "expected a non-negative overhead, got %q", overhead)
+			} else {
+				assert.Empty(overhead)
+			}
+
+			// cleanup.
+			t.Cleanup(func() {
+				store.Clear(ctx)
+			})
+		})
+	}
+}
+
+// TestHandler_TTL tests that mappings are placed into expiring stores with
+// the configured TTL.
+func TestHandler_TTL(t *testing.T) {
+	// arrange.
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/this/is/the/way", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Location", "/hey/der")
+		w.WriteHeader(http.StatusCreated)
+	})
+	store := obscurer.DefaultStore
+	handler := obscurer.NewHandler(
+		obscurer.Default, store, mux, obscurer.WithTTL(20*time.Millisecond))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// action.
+	response, err := http.Get(fmt.Sprintf("%s/this/is/the/way", server.URL))
+
+	// assert.
+	require.NoError(err)
+	assert.Equal(http.StatusCreated, response.StatusCode)
+	assert.Equal(1, store.Size(ctx))
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(0, store.Size(ctx), "expected the mapping to have expired")
+
+	// cleanup.
+	t.Cleanup(func() {
+		store.Clear(ctx)
+	})
+}
+
+// TestHandler_Uses tests that the mappings placed with a limited number of
+// uses stop resolving once their uses are consumed.
+func TestHandler_Uses(t *testing.T) {
+	// arrange.
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/downloads", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Location", "/downloads/42")
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("/downloads/42", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	store := obscurer.DefaultStore
+	handler := obscurer.NewHandler(obscurer.Default, store, mux, obscurer.WithUses(1))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	response, err := http.Post(fmt.Sprintf("%s/downloads", server.URL), "text/plain", nil)
+	require.NoError(err)
+	location := response.Header.Get("Location")
+
+	// action.
+	first, err := http.Get(server.URL + location)
+	require.NoError(err)
+	second, err := http.Get(server.URL + location)
+	require.NoError(err)
+
+	// assert.
+	assert.Equal(http.StatusOK, first.StatusCode)
+	assert.Equal(http.StatusNotFound, second.StatusCode)
+
+	// cleanup.
+	t.Cleanup(func() {
+		store.Clear(ctx)
+	})
+}
+
+// TestHandler_RequestURI tests that the request URI and raw path of requests
+// are consistent with their resolved URL.
+func TestHandler_RequestURI(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		path     string
+		rawPath  string
+	}{
+		{name: "Path", original: "/this/is/the/way?x=1", path: "/this/is/the/way"},
+		{name: "Escaped", original: "/files/a%2Fb?x=1", path: "/files/a/b", rawPath: "/files/a%2Fb"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			ctx := context.Background()
+			store := obscurer.NewMemoryStore()
+			original := mustParse(test.original)
+			obscured := obscurer.Default.Obscure(original)
+			require.NoError(t, store.Put(ctx, obscured, original))
+			var received *http.Request
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = r
+			})
+			handler := obscurer.NewHandler(obscurer.Default, store, h)
+
+			// action.
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, obscured.String(), nil))
+
+			// assert.
+			require.NotNil(t, received)
+			assert.Equal(t, test.original, received.RequestURI)
+			assert.Equal(t, test.path, received.URL.Path)
+			assert.Equal(t, test.rawPath, received.URL.RawPath)
+		})
+	}
+}
+
+// TestHandler_Query tests that the query of requests is merged into that of
+// their resolved URL.
+func TestHandler_Query(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		query    string
+		expected string
+	}{
+		{name: "Incoming", original: "/orders", query: "page=2", expected: "page=2"},
+		{name: "Original", original: "/orders?sort=date", expected: "sort=date"},
+		{name: "Merged", original: "/orders?page=1&sort=date", query: "page=2&size=10", expected: "page=2&size=10&sort=date"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			ctx := context.Background()
+			store := obscurer.NewMemoryStore()
+			original := mustParse(test.original)
+			obscured := obscurer.Default.Obscure(original)
+			require.NoError(t, store.Put(ctx, obscured, original))
+			var received *http.Request
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = r
+			})
+			handler := obscurer.NewHandler(obscurer.Default, store, h)
+			target := *obscured
+			target.RawQuery = test.query
+
+			// action.
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target.String(), nil))
+
+			// assert.
+			require.NotNil(t, received)
+			assert.Equal(t, "/orders", received.URL.Path)
+			assert.Equal(t, test.expected, received.URL.RawQuery)
+			assert.Equal(t, received.URL.RequestURI(), received.RequestURI)
+		})
+	}
+}
+
+// TestHandler_Strict tests that only requests by known obscured URLs, or
+// excluded from obscuring, are handled when the handler is strict.
+func TestHandler_Strict(t *testing.T) {
+	original := mustParse("/this/is/the/way")
+	obscured := obscurer.Default.Obscure(original)
+	tests := []struct {
+		name    string
+		target  string
+		status  int
+		handled bool
+	}{
+		{name: "Obscured", target: obscured.String(), status: http.StatusOK, handled: true},
+		{name: "Original", target: original.String(), status: http.StatusNotFound},
+		{name: "Excluded", target: "/healthz", status: http.StatusOK, handled: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			ctx := context.Background()
+			store := obscurer.NewMemoryStore()
+			require.NoError(t, store.Put(ctx, obscured, original))
+			handled := false
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handled = true
+			})
+			handler := obscurer.NewHandler(
+				obscurer.Default, store, h, obscurer.WithStrict(), obscurer.WithExclusions(obscurer.Glob("/healthz")))
+			response := httptest.NewRecorder()
+
+			// action.
+			handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, test.target, nil))
+
+			// assert.
+			assert.Equal(t, test.status, response.Code)
+			assert.Equal(t, test.handled, handled)
+		})
+	}
+}
+
+// TestHandler_LookupError tests that the request isn't handled when its URL
+// fails to be looked up in the store.
+func TestHandler_LookupError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{name: "Error", err: errors.New("whoa"), status: http.StatusInternalServerError},
+		{name: "Timeout", err: context.DeadlineExceeded, status: http.StatusServiceUnavailable},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			assert := assert.New(t)
+			require := require.New(t)
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			handled := false
+			mux := http.NewServeMux()
+			mux.HandleFunc("/this/is/the/way", func(w http.ResponseWriter, r *http.Request) {
+				handled = true
+			})
+			store := mock.NewStore(ctrl)
+			handler := obscurer.NewHandler(obscurer.Default, store, mux)
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			store.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, false, test.err)
+
+			// action.
+			response, err := http.Get(fmt.Sprintf("%s/this/is/the/way", server.URL))
+
+			// assert.
+			require.NoError(err)
+			assert.Equal(test.status, response.StatusCode)
+			assert.False(handled, "expected the request not to be handled")
+			responseBytes, err := ioutil.ReadAll(response.Body)
+			require.NoError(err)
+			assert.Equal(obscurer.ErrFailedLookup.Error()+"\n", string(responseBytes))
+		})
+	}
+}
+
+// batchStore is a store recording the mappings placed at once.
+type batchStore struct {
+	obscurer.Store
+
+	batches []map[*url.URL]*url.URL
+}
+
+func (s *batchStore) PutAll(ctx context.Context, mappings map[*url.URL]*url.URL) error {
+	s.batches = append(s.batches, mappings)
+	return obscurer.PutAll(ctx, s.Store, mappings)
+}
+
+func (s *batchStore) RemoveAll(ctx context.Context, urls []*url.URL) error {
+	return obscurer.RemoveAll(ctx, s.Store, urls)
+}
+
+// TestHandler_BatchStore tests that the mappings for every header are
+// placed into batch stores at once.
+func TestHandler_BatchStore(t *testing.T) {
+	// arrange.
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/this/is/the/way", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Location", "/hey/der")
+		w.Header().Add("Content-Location", "/hey/der")
+		w.Header().Add("Link", "</this/is/the/way>; rel=\"self\"")
+		w.WriteHeader(http.StatusCreated)
+	})
+	store := &batchStore{Store: obscurer.DefaultStore}
+	handler := obscurer.NewHandler(obscurer.Default, store, mux)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// action.
+	response, err := http.Get(fmt.Sprintf("%s/this/is/the/way", server.URL))
+
+	// assert.
+	require.NoError(err)
+	assert.Equal(http.StatusCreated, response.StatusCode)
+	require.Len(store.batches, 1)
+	assert.Len(store.batches[0], 2)
+	assert.Equal(2, store.Size(ctx))
+	expected := obscurer.Default.Obscure(mustParse("/hey/der"))
+	assert.Equal(expected.String(), response.Header.Get("Location"))
+	assert.Equal(expected.String(), response.Header.Get("Content-Location"))
+
+	// cleanup.
+	t.Cleanup(func() {
+		store.Clear(ctx)
+	})
+}
+
+// TestHandler_IDFields tests that the identifiers within JSON bodies are
+// decoded within requests and encoded within responses.
+func TestHandler_IDFields(t *testing.T) {
+	// arrange.
+	assert := assert.New(t)
+	require := require.New(t)
+	var received string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orders", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received = string(body)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Content-Length", "1")
+		w.Write([]byte(`{"id":42,"name":"<7>","user_id":"u1","items":[{"id":7}],"related":[1,2]}`))
+	})
+	codec := prefixCodec{prefix: "x"}
+	handler := obscurer.NewHandler(
+		obscurer.Default, obscurer.DefaultStore, mux, obscurer.WithIDFields(codec, "id", "user_id", "related"))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// action.
+	response, err := http.Post(
+		fmt.Sprintf("%s/orders", server.URL),
+		"application/json",
+		strings.NewReader(`{"id": "x42", "user_id": "xu1", "name": "x42", "related": ["x1", "2"]}`))
+
+	// assert.
+	require.NoError(err)
+	assert.Equal(`{"id":42,"user_id":"u1","name":"x42","related":[1,"2"]}`, received)
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	require.NoError(err)
+	assert.Equal(
+		`{"id":"x42","name":"<7>","user_id":"xu1","items":[{"id":"x7"}],"related":["x1","x2"]}`,
+		string(responseBytes))
+}
+
+// TestHandler_IDFields_NotJSON tests that bodies that aren't JSON are left
+// as is.
+func TestHandler_IDFields_NotJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{name: "Text", contentType: "text/plain", body: `{"id":42}`},
+		{name: "Malformed", contentType: "application/json", body: `{"id":42`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			assert := assert.New(t)
+			require := require.New(t)
+			mux := http.NewServeMux()
+			mux.HandleFunc("/orders", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", test.contentType)
+				w.Write([]byte(test.body))
+			})
+			handler := obscurer.NewHandler(
+				obscurer.Default, obscurer.DefaultStore, mux, obscurer.WithIDFields(prefixCodec{prefix: "x"}, "id"))
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			// action.
+			response, err := http.Get(fmt.Sprintf("%s/orders", server.URL))
+
+			// assert.
+			require.NoError(err)
+			responseBytes, err := ioutil.ReadAll(response.Body)
+			require.NoError(err)
+			assert.Equal(test.body, string(responseBytes))
+		})
+	}
+}
+
+// TestHandler_ContentLength tests that the 'Content-Length' header of
+// buffered responses is the length of the body written, and that it is
+// dropped from responses replaced with an error.
+func TestHandler_ContentLength(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		body     string
+		length   string
+	}{
+		{name: "Rewritten", body: `{"id":42}`, length: "12"},
+		{name: "Malformed", body: `{"id":42`, length: "8"},
+		{name: "Failed", location: "/hey/der", body: `{"id":42}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mock.NewStore(ctrl)
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Length", "1")
+				if test.location != "" {
+					w.Header().Set("Location", test.location)
+				}
+				w.Write([]byte(test.body))
+			})
+			eh := func(w http.ResponseWriter, r *http.Request, err error) {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte("whoa"))
+			}
+			handler := obscurer.NewHandler(
+				obscurer.Default, store, h,
+				obscurer.WithIDFields(prefixCodec{prefix: "x"}, "id"),
+				obscurer.WithErrorHandler(eh))
+			store.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, false, nil)
+			store.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("whoa")).AnyTimes()
+			response := httptest.NewRecorder()
+
+			// action.
+			handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+			// assert.
+			assert.Equal(t, test.length, response.Header().Get("Content-Length"))
+			if test.length != "" {
+				assert.Equal(t, test.length, strconv.Itoa(response.Body.Len()))
+			}
+		})
+	}
+}
+
+// TestHandler_Trailers tests that the trailers declared by the wrapped
+// handler, and those set with the trailer prefix, are written after the
+// body, whether it is buffered or streamed.
+func TestHandler_Trailers(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+	}{
+		{name: "Buffered", contentType: "application/json"},
+		{name: "Streamed", contentType: "text/plain"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			require := require.New(t)
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", test.contentType)
+				w.Header().Set("Trailer", "Grpc-Status")
+				w.Write([]byte(`{"id":42}`))
+				w.Header().Set("Grpc-Status", "0")
+				w.Header().Set(http.TrailerPrefix+"Grpc-Message", "ok")
+			})
+			handler := obscurer.NewHandler(
+				obscurer.Default, obscurer.NewMemoryStore(), h,
+				obscurer.WithIDFields(prefixCodec{prefix: "x"}, "id"))
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			// action.
+			response, err := http.Get(fmt.Sprintf("%s/orders", server.URL))
+			require.NoError(err)
+			defer response.Body.Close()
+			body, err := ioutil.ReadAll(response.Body)
+
+			// assert.
+			require.NoError(err)
+			assert.NotEmpty(t, body)
+			assert.Empty(t, response.Header.Get("Grpc-Status"))
+			assert.Equal(t, "0", response.Trailer.Get("Grpc-Status"))
+			assert.Equal(t, "ok", response.Trailer.Get("Grpc-Message"))
+		})
+	}
+}
+
+// TestHandler_Streaming tests that response bodies written in several
+// chunks are streamed through in full, with their headers obscured before
+// the first chunk.
+func TestHandler_Streaming(t *testing.T) {
+	// arrange.
+	assert := assert.New(t)
+	require := require.New(t)
+	t.Cleanup(func() { obscurer.DefaultStore.Clear(context.Background()) })
+	flushed := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Location", "/this/is/the/way")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("first\n"))
+		w.(http.Flusher).Flush()
+		<-flushed
+		w.Write([]byte("second\n"))
+	})
+	handler := obscurer.NewHandler(obscurer.Default, obscurer.DefaultStore, mux)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// action.
+	response, err := http.Get(fmt.Sprintf("%s/events", server.URL))
+	require.NoError(err)
+	defer response.Body.Close()
+	first := make([]byte, len("first\n"))
+	_, err = io.ReadFull(response.Body, first)
+	require.NoError(err)
+	close(flushed)
+	rest, err := ioutil.ReadAll(response.Body)
+	require.NoError(err)
+
+	// assert.
+	assert.Equal(http.StatusOK, response.StatusCode)
+	assert.Equal(obscurer.Default.Obscure(mustParse("/this/is/the/way")).String(), response.Header.Get("Content-Location"))
+	assert.Equal("first\n", string(first))
+	assert.Equal("second\n", string(rest))
+}
+
+// TestHandler_Streaming_HeaderFailure tests that the response is replaced
+// with an error when its headers fail to be obscured, discarding its body.
+func TestHandler_Streaming_HeaderFailure(t *testing.T) {
+	// arrange.
+	assert := assert.New(t)
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	var writeErr error
+	mux := http.NewServeMux()
+	mux.HandleFunc("/this/is/the/way", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/hey/der")
+		w.WriteHeader(http.StatusCreated)
+		_, writeErr = w.Write([]byte("first"))
+		w.Write([]byte("second"))
+	})
+	store := mock.NewStore(ctrl)
+	store.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, false, nil)
+	store.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("whoa"))
+	handler := obscurer.NewHandler(obscurer.Default, store, mux)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// action.
+	response, err := http.Get(fmt.Sprintf("%s/this/is/the/way", server.URL))
+
+	// assert.
+	require.NoError(err)
+	assert.Equal(http.StatusInternalServerError, response.StatusCode)
+	body, err := ioutil.ReadAll(response.Body)
+	require.NoError(err)
+	assert.Equal(obscurer.ErrLocationHeaderFailure.Error()+"\n", string(body))
+	assert.Equal(obscurer.ErrLocationHeaderFailure, writeErr)
+}
+
+// TestHandler_Hijack tests that the wrapped handler can take over the
+// connection, such as to upgrade it to a WebSocket.
+func TestHandler_Hijack(t *testing.T) {
+	// arrange.
+	assert := assert.New(t)
+	require := require.New(t)
+	t.Cleanup(func() { obscurer.DefaultStore.Clear(context.Background()) })
+	var hijackErr error
+	mux := http.NewServeMux()
+	mux.HandleFunc("/socket", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/this/is/the/way")
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if hijackErr = err; err != nil {
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 5\r\nConnection: close\r\n\r\nhello")
+		buf.Flush()
+	})
+	handler := obscurer.NewHandler(obscurer.Default, obscurer.DefaultStore, mux)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// action.
+	response, err := http.Get(fmt.Sprintf("%s/socket", server.URL))
+
+	// assert.
+	require.NoError(err)
+	require.NoError(hijackErr)
+	body, err := ioutil.ReadAll(response.Body)
+	require.NoError(err)
+	assert.Equal("hello", string(body))
+	assert.Empty(response.Header.Get("Location"))
+	assert.Equal(0, obscurer.DefaultStore.Size(context.Background()), "expected the hijacked response not to be obscured")
+}
+
+// TestHandler_ServerSentEvents tests that server-sent events are flushed to
+// the client as soon as they are written, without the handler flushing.
+func TestHandler_ServerSentEvents(t *testing.T) {
+	// arrange.
+	assert := assert.New(t)
+	require := require.New(t)
+	t.Cleanup(func() { obscurer.DefaultStore.Clear(context.Background()) })
+	received := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: first\n\n"))
+		<-received
+		w.Write([]byte("data: second\n\n"))
+	})
+	handler := obscurer.NewHandler(obscurer.Default, obscurer.DefaultStore, mux)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// action.
+	response, err := http.Get(fmt.Sprintf("%s/events", server.URL))
+	require.NoError(err)
+	defer response.Body.Close()
+	first := make([]byte, len("data: first\n\n"))
+	_, err = io.ReadFull(response.Body, first)
+	require.NoError(err)
+	close(received)
+	rest, err := ioutil.ReadAll(response.Body)
+	require.NoError(err)
+
+	// assert.
+	assert.Equal("text/event-stream", response.Header.Get("Content-Type"))
+	assert.Equal("data: first\n\n", string(first))
+	assert.Equal("data: second\n\n", string(rest))
+}
+
 func mustParse(str string) *url.URL {
 	u, err := url.Parse(str)
 	if err != nil {
@@ -0,0 +1,68 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"context"
+	"net/http"
+)
+
+// Pinger represents a store backed by a remote backend, which can verify
+// that the backend is reachable.
+type Pinger interface {
+	// Ping verifies the backend of the store is reachable, returning an
+	// error when it isn't.
+	Ping(ctx context.Context) error
+}
+
+// Ping verifies the backend of the provided store is reachable when the
+// store implements Pinger. Stores that don't implement it have no backend
+// to verify, so nil is returned for them. Decorators forward their Ping to
+// the store they decorate with it.
+func Ping(ctx context.Context, s Store) error {
+	if pinger, ok := s.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// healthz reports the health of a store.
+type healthz struct {
+	store Store
+}
+
+// Healthz constructs a handler reporting whether the provided store is able
+// to serve mappings, such as for the readiness probes of Kubernetes. The
+// handler responds with 200 OK when the store is healthy, and 503 Service
+// Unavailable when it implements Pinger and its backend is unreachable.
+// Stores that don't implement Pinger are always healthy. Pings are bound
+// by the context of the request.
+func Healthz(store Store) http.Handler {
+	return &healthz{store: store}
+}
+
+// ServeHTTP reports the health of the store.
+func (h *healthz) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := Ping(r.Context(), h.store); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("unavailable\n"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok\n"))
+}
@@ -0,0 +1,68 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/internal/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// pingingStore is a store whose backend is reachable unless err is set.
+type pingingStore struct {
+	obscurer.Store
+	err error
+}
+
+func (s *pingingStore) Ping(ctx context.Context) error {
+	return s.err
+}
+
+// TestHealthz tests that the health of the store is reported.
+func TestHealthz(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	tests := []struct {
+		name     string
+		store    obscurer.Store
+		expected int
+	}{
+		{"Reachable", &pingingStore{Store: mock.NewStore(ctrl)}, http.StatusOK},
+		{"Unreachable", &pingingStore{Store: mock.NewStore(ctrl), err: errors.New("whoa")}, http.StatusServiceUnavailable},
+		{"NoPinger", mock.NewStore(ctrl), http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			response := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+
+			// action.
+			obscurer.Healthz(test.store).ServeHTTP(response, request)
+
+			// assert.
+			assert.Equal(t, test.expected, response.Code)
+			assert.Equal(t, "no-store", response.Header().Get("Cache-Control"))
+		})
+	}
+}
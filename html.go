@@ -0,0 +1,94 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"context"
+	"mime"
+	"net/url"
+	"strings"
+)
+
+// htmlMarkup describes where the URLs of HTML documents are held.
+var htmlMarkup = markup{
+	attributes: map[string]bool{
+		"href":   true,
+		"src":    true,
+		"action": true,
+		"srcset": true,
+	},
+	rawText: map[string]bool{
+		"script":   true,
+		"style":    true,
+		"textarea": true,
+		"title":    true,
+	},
+}
+
+// WithHTMLRewriting configures the handler to obscure the URLs held by the
+// 'href', 'src', 'action' and 'srcset' attributes of HTML response bodies
+// that point at paths of the host of the request, such that server-rendered
+// pages work behind the handler. Bodies are buffered until complete.
+func WithHTMLRewriting() HandlerOption {
+	return func(o *HandlerOptions) {
+		o.RewriteHTML = true
+	}
+}
+
+// isHTML indicates whether the provided media type is HTML.
+func isHTML(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml")
+}
+
+// rewriteMarkupBody obscures the URLs of the provided document, held where
+// the provided markup describes, that point at paths of the provided host.
+func (h *handler) rewriteMarkupBody(ctx context.Context, m markup, host string, body []byte) ([]byte, error) {
+	obscure := func(target string) (string, bool, error) {
+		u, err := url.Parse(target)
+		if err != nil || !sameHost(u, host) {
+			return target, false, nil
+		}
+		obscured, err := h.obscure(ctx, u)
+		if err != nil || obscured == nil {
+			return target, false, err
+		}
+		return obscured.String(), true, nil
+	}
+	return m.rewrite(body, func(name, value string) (string, bool, error) {
+		if name != "srcset" {
+			return obscure(strings.TrimSpace(value))
+		}
+		candidates := strings.Split(value, ",")
+		rewritten := false
+		for i, candidate := range candidates {
+			fields := strings.Fields(candidate)
+			if len(fields) == 0 {
+				continue
+			}
+			obscured, ok, err := obscure(fields[0])
+			if err != nil {
+				return "", false, err
+			}
+			if ok {
+				fields[0] = obscured
+				rewritten = true
+			}
+			candidates[i] = strings.Join(fields, " ")
+		}
+		return strings.Join(candidates, ", "), rewritten, nil
+	})
+}
@@ -0,0 +1,119 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHandler_HTMLRewriting tests that the URLs held by the attributes of
+// HTML response bodies are obscured when they point at the host of the
+// request.
+func TestHandler_HTMLRewriting(t *testing.T) {
+	obscure := func(path string) string {
+		return obscurer.Default.Obscure(mustParse(path)).String()
+	}
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name:     "Href",
+			body:     `<a href="/users/42" class=link>me</a>`,
+			expected: `<a href="` + obscure("/users/42") + `" class=link>me</a>`,
+		},
+		{
+			name:     "Unquoted",
+			body:     `<IMG SRC=/img/a.png alt='a'>`,
+			expected: `<IMG SRC="` + obscure("/img/a.png") + `" alt='a'>`,
+		},
+		{
+			name:     "Action",
+			body:     `<form method="post" action='/orders?x=1&amp;y=2'></form>`,
+			expected: `<form method="post" action="` + strings.Replace(obscure("/orders?x=1&y=2"), "&", "&amp;", -1) + `"></form>`,
+		},
+		{
+			name:     "Srcset",
+			body:     `<img srcset="/img/a.png 1x, /img/b.png 2x">`,
+			expected: `<img srcset="` + obscure("/img/a.png") + ` 1x, ` + obscure("/img/b.png") + ` 2x">`,
+		},
+		{
+			name:     "SameHost",
+			body:     `<a href="http://example.com/users/42">me</a>`,
+			expected: `<a href="` + obscure("http://example.com/users/42") + `">me</a>`,
+		},
+		{
+			name:     "OtherHost",
+			body:     `<a href="https://freerware.com/users/42">them</a><a href="#top">top</a><a href="mailto:x@y.z">mail</a>`,
+			expected: `<a href="https://freerware.com/users/42">them</a><a href="#top">top</a><a href="mailto:x@y.z">mail</a>`,
+		},
+		{
+			name:     "Unterminated",
+			body:     `<a =x href=/a`,
+			expected: `<a =x href="` + obscure("/a") + `"`,
+		},
+		{
+			name:     "RawText",
+			body:     `<!-- <a href="/a"> --><script>var s = '<a href="/a">';</script><a href="/a">`,
+			expected: `<!-- <a href="/a"> --><script>var s = '<a href="/a">';</script><a href="` + obscure("/a") + `">`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.Write([]byte(test.body))
+			})
+			handler := obscurer.NewHandler(obscurer.Default, obscurer.NewMemoryStore(), h, obscurer.WithHTMLRewriting())
+			response := httptest.NewRecorder()
+
+			// action.
+			handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			// assert.
+			assert.Equal(t, test.expected, response.Body.String())
+			assert.Equal(t, strconv.Itoa(len(test.expected)), response.Header().Get("Content-Length"))
+		})
+	}
+}
+
+// TestHandler_HTMLRewriting_NotHTML tests that bodies that aren't HTML are
+// left as is.
+func TestHandler_HTMLRewriting_NotHTML(t *testing.T) {
+	// arrange.
+	body := `<a href="/users/42">me</a>`
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(body))
+	})
+	handler := obscurer.NewHandler(obscurer.Default, obscurer.NewMemoryStore(), h, obscurer.WithHTMLRewriting())
+	response := httptest.NewRecorder()
+
+	// action.
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// assert.
+	assert.Equal(t, body, response.Body.String())
+}
@@ -0,0 +1,45 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import "errors"
+
+// ErrInvalidID represents an error that occurs when a route parameter value
+// cannot be encoded or decoded.
+var ErrInvalidID = errors.New("obscurer: invalid ID")
+
+// IDCodec represents a reversible encoding of route parameter values, such
+// as the identifiers of resources, so that they can be obscured without a
+// mapping in the store.
+type IDCodec interface {
+	// Encode encodes the provided parameter value.
+	Encode(value string) (string, error)
+	// Decode decodes the provided encoded parameter value, returning an
+	// error when the value wasn't encoded by the codec.
+	Decode(encoded string) (string, error)
+}
+
+// ParameterIDCodec represents an IDCodec that selects the codec used for
+// each route parameter, such that identifiers of different resources can be
+// encoded differently. The values of parameters without a codec are left as
+// is.
+type ParameterIDCodec interface {
+	IDCodec
+
+	// Codec provides the codec for the route parameter with the provided
+	// name, which is nil when the parameter is not encoded.
+	Codec(parameter string) IDCodec
+}
@@ -0,0 +1,157 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package idcodec
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/freerware/obscurer"
+)
+
+// ErrUnknownCodec represents an error that occurs when constructing a codec
+// with a name that has not been registered.
+var ErrUnknownCodec = errors.New("idcodec: unknown codec")
+
+// Spec represents the configuration of a single codec, such that codecs can
+// be selected by configuration files.
+type Spec struct {
+	// Codec is the name the codec is registered under.
+	Codec string `json:"codec"`
+	// Salt is the salt of Hashids.
+	Salt string `json:"salt,omitempty"`
+	// Alphabet is the alphabet of Hashids and Sqids, which default to their
+	// own alphabets when empty.
+	Alphabet string `json:"alphabet,omitempty"`
+	// MinLength is the minimum length of the encodings of Hashids and Sqids.
+	MinLength int `json:"min_length,omitempty"`
+	// Key is the hex encoded key of AES-SIV.
+	Key string `json:"key,omitempty"`
+	// AssociatedData is the associated data of AES-SIV.
+	AssociatedData string `json:"associated_data,omitempty"`
+}
+
+// options provides the options of the codecs encoding numeric identifiers
+// described by the spec.
+func (s Spec) options() (opts []Option) {
+	if s.Alphabet != "" {
+		opts = append(opts, WithAlphabet(s.Alphabet))
+	}
+	return append(opts, WithMinLength(s.MinLength))
+}
+
+// Config represents the configuration of the codecs selected per route
+// parameter, such that the conventions of several teams can coexist.
+type Config struct {
+	// Default is the codec of the parameters absent from Parameters. When
+	// nil, such parameters are left as is.
+	Default *Spec `json:"default,omitempty"`
+	// Parameters are the codecs of the parameters, keyed by their names.
+	Parameters map[string]Spec `json:"parameters,omitempty"`
+}
+
+// FromConfig constructs a codec selecting the codec for each route
+// parameter as described by the provided configuration.
+func FromConfig(config Config) (obscurer.ParameterIDCodec, error) {
+	var fallback obscurer.IDCodec
+	if config.Default != nil {
+		codec, err := New(*config.Default)
+		if err != nil {
+			return nil, fmt.Errorf("idcodec: default codec: %w", err)
+		}
+		fallback = codec
+	}
+	codecs := make(map[string]obscurer.IDCodec, len(config.Parameters))
+	for parameter, spec := range config.Parameters {
+		codec, err := New(spec)
+		if err != nil {
+			return nil, fmt.Errorf("idcodec: codec of parameter %q: %w", parameter, err)
+		}
+		codecs[parameter] = codec
+	}
+	return ByParameter(fallback, codecs), nil
+}
+
+// Factory constructs a codec as described by the provided spec.
+type Factory func(Spec) (obscurer.IDCodec, error)
+
+var (
+	registryMutex sync.RWMutex
+	registry      = map[string]Factory{}
+)
+
+func init() {
+	Register("hashids", func(s Spec) (obscurer.IDCodec, error) {
+		return NewHashids(s.Salt, s.options()...)
+	})
+	Register("sqids", func(s Spec) (obscurer.IDCodec, error) {
+		return NewSqids(s.options()...)
+	})
+	Register("aes-siv", func(s Spec) (obscurer.IDCodec, error) {
+		key, err := hex.DecodeString(s.Key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: AES-SIV key must be hex encoded", obscurer.ErrInvalidKey)
+		}
+		var ad [][]byte
+		if s.AssociatedData != "" {
+			ad = append(ad, []byte(s.AssociatedData))
+		}
+		return NewAESSIV(key, ad...)
+	})
+}
+
+// Register makes a codec factory available under the provided name,
+// allowing the codec to be selected by configuration with New. Codecs
+// requiring dependencies, such as the table of UUID, are registered by the
+// application. Register panics when the factory is nil, or when a factory
+// has already been registered under the provided name.
+func Register(name string, factory Factory) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	if factory == nil {
+		panic("idcodec: Register factory is nil")
+	}
+	if _, exists := registry[name]; exists {
+		panic("idcodec: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// New constructs the codec described by the provided spec.
+func New(spec Spec) (obscurer.IDCodec, error) {
+	registryMutex.RLock()
+	factory, ok := registry[spec.Codec]
+	registryMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCodec, spec.Codec)
+	}
+	return factory(spec)
+}
+
+// Names provides the sorted names of all registered codecs.
+func Names() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,112 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package idcodec_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/idcodec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFromConfig tests that each route parameter is encoded with the codec
+// configured for it.
+func TestFromConfig(t *testing.T) {
+	// arrange.
+	var config idcodec.Config
+	require.NoError(t, json.NewDecoder(strings.NewReader(`{
+		"default": {"codec": "sqids"},
+		"parameters": {
+			"user_id": {"codec": "hashids", "salt": "this is my salt"},
+			"order_id": {"codec": "aes-siv", "key": "`+strings.Repeat("00", 32)+`", "associated_data": "orders"}
+		}
+	}`)).Decode(&config))
+
+	// action.
+	codec, err := idcodec.FromConfig(config)
+
+	// assert.
+	require.NoError(t, err)
+	users, err := codec.Codec("user_id").Encode("12345")
+	require.NoError(t, err)
+	assert.Equal(t, "NkK9", users)
+	other, err := codec.Codec("page").Encode("1")
+	require.NoError(t, err)
+	assert.Equal(t, "Uk", other)
+	orders, err := codec.Codec("order_id").Encode("7")
+	require.NoError(t, err)
+	decoded, err := codec.Codec("order_id").Decode(orders)
+	require.NoError(t, err)
+	assert.Equal(t, "7", decoded)
+}
+
+// TestFromConfig_Invalid tests that configurations with unknown codecs or
+// invalid settings result in an error.
+func TestFromConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config idcodec.Config
+		err    error
+	}{
+		{
+			name:   "UnknownCodec",
+			config: idcodec.Config{Default: &idcodec.Spec{Codec: "rot13"}},
+			err:    idcodec.ErrUnknownCodec,
+		},
+		{
+			name: "InvalidKey",
+			config: idcodec.Config{Parameters: map[string]idcodec.Spec{
+				"order_id": {Codec: "aes-siv", Key: "abc"},
+			}},
+			err: obscurer.ErrInvalidKey,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// action.
+			codec, err := idcodec.FromConfig(test.config)
+
+			// assert.
+			assert.Nil(t, codec)
+			assert.True(t, errors.Is(err, test.err))
+		})
+	}
+}
+
+// TestRegister tests that registered codecs can be constructed by name.
+func TestRegister(t *testing.T) {
+	// arrange.
+	table := idcodec.NewMemoryTable()
+	idcodec.Register("uuid-test", func(idcodec.Spec) (obscurer.IDCodec, error) {
+		return idcodec.NewUUID(table), nil
+	})
+
+	// action.
+	codec, err := idcodec.New(idcodec.Spec{Codec: "uuid-test"})
+
+	// assert.
+	require.NoError(t, err)
+	assert.IsType(t, &idcodec.UUID{}, codec)
+	assert.Contains(t, idcodec.Names(), "uuid-test")
+	assert.Panics(t, func() {
+		idcodec.Register("uuid-test", func(idcodec.Spec) (obscurer.IDCodec, error) { return nil, nil })
+	})
+}
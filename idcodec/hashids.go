@@ -0,0 +1,222 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package idcodec
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/freerware/obscurer"
+)
+
+const (
+	// HashidsAlphabet is the default alphabet of Hashids.
+	HashidsAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890"
+
+	// hashidsSeparators are the characters separating numbers in Hashids,
+	// which are chosen to avoid producing common words.
+	hashidsSeparators = "cfhistuCFHISTU"
+	// hashidsMinAlphabet is the minimum length of the Hashids alphabet.
+	hashidsMinAlphabet = 16
+	// hashidsSeparatorDiv is the ratio of alphabet to separator characters.
+	hashidsSeparatorDiv = 3.5
+	// hashidsGuardDiv is the ratio of alphabet to guard characters.
+	hashidsGuardDiv = 12
+)
+
+// Hashids encodes non-negative integer identifiers with the Hashids
+// algorithm, producing the same encodings as other implementations of
+// Hashids given the same salt, alphabet, and minimum length.
+type Hashids struct {
+	salt      []byte
+	alphabet  []byte
+	separator []byte
+	guards    []byte
+	minLength int
+}
+
+var _ obscurer.IDCodec = (*Hashids)(nil)
+
+// NewHashids constructs a Hashids codec with the provided salt and options.
+// The alphabet must have at least 16 characters.
+func NewHashids(salt string, opts ...Option) (*Hashids, error) {
+	o, err := options(Options{Alphabet: HashidsAlphabet}, hashidsMinAlphabet, opts)
+	if err != nil {
+		return nil, err
+	}
+	h := &Hashids{salt: []byte(salt), minLength: o.MinLength}
+
+	// separate the separators from the alphabet.
+	var alphabet, separators []byte
+	for i := 0; i < len(o.Alphabet); i++ {
+		if strings.IndexByte(hashidsSeparators, o.Alphabet[i]) >= 0 {
+			separators = append(separators, o.Alphabet[i])
+			continue
+		}
+		alphabet = append(alphabet, o.Alphabet[i])
+	}
+	separators = shuffle(separators, h.salt)
+
+	// balance the separators with the alphabet.
+	if len(separators) == 0 || float64(len(alphabet))/float64(len(separators)) > hashidsSeparatorDiv {
+		length := int(math.Ceil(float64(len(alphabet)) / hashidsSeparatorDiv))
+		if length == 1 {
+			length++
+		}
+		if length > len(separators) {
+			diff := length - len(separators)
+			separators = append(separators, alphabet[:diff]...)
+			alphabet = alphabet[diff:]
+		} else {
+			separators = separators[:length]
+		}
+	}
+	alphabet = shuffle(alphabet, h.salt)
+
+	// take the guards from the alphabet, or the separators when the
+	// alphabet is too short.
+	guards := int(math.Ceil(float64(len(alphabet)) / hashidsGuardDiv))
+	if len(alphabet) < 3 {
+		h.guards, h.separator = separators[:guards], separators[guards:]
+	} else {
+		h.guards, alphabet = alphabet[:guards], alphabet[guards:]
+		h.separator = separators
+	}
+	h.alphabet = alphabet
+	return h, nil
+}
+
+// Encode encodes the provided non-negative integer.
+func (h *Hashids) Encode(value string) (string, error) {
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return "", invalid(value, "is not a non-negative integer")
+	}
+	return h.encode(n), nil
+}
+
+// Decode decodes the provided encoding to the integer it encodes.
+func (h *Hashids) Decode(encoded string) (string, error) {
+	// drop the guards padding the encoding.
+	parts := strings.Split(strings.Map(func(r rune) rune {
+		if strings.ContainsRune(string(h.guards), r) {
+			return ' '
+		}
+		return r
+	}, encoded), " ")
+	breakdown := ""
+	switch len(parts) {
+	case 1:
+		breakdown = parts[0]
+	case 2, 3:
+		breakdown = parts[1]
+	}
+	if len(breakdown) < 2 || strings.ContainsAny(breakdown, string(h.separator)) {
+		return "", invalid(encoded, "is not a Hashids encoding of a single integer")
+	}
+
+	// reverse the encoding.
+	lottery := breakdown[0]
+	alphabet := h.shuffled(lottery, append([]byte(nil), h.alphabet...))
+	n, ok := unhash(breakdown[1:], alphabet)
+	if !ok || h.encode(n) != encoded {
+		return "", invalid(encoded, "is not a Hashids encoding of a single integer")
+	}
+	return strconv.FormatUint(n, 10), nil
+}
+
+// encode encodes the provided integer.
+func (h *Hashids) encode(n uint64) string {
+	hash := int(n % 100)
+	alphabet := append([]byte(nil), h.alphabet...)
+	lottery := alphabet[hash%len(alphabet)]
+	alphabet = h.shuffled(lottery, alphabet)
+	result := append([]byte{lottery}, toDigits(n, alphabet)...)
+
+	// pad the encoding with guards.
+	if len(result) < h.minLength {
+		result = append([]byte{h.guards[(hash+int(result[0]))%len(h.guards)]}, result...)
+		if len(result) < h.minLength {
+			result = append(result, h.guards[(hash+int(result[2]))%len(h.guards)])
+		}
+	}
+
+	// pad the encoding with the alphabet.
+	half := len(alphabet) / 2
+	for len(result) < h.minLength {
+		alphabet = shuffle(alphabet, append([]byte(nil), alphabet...))
+		padded := append(append(append([]byte(nil), alphabet[half:]...), result...), alphabet[:half]...)
+		if excess := len(padded) - h.minLength; excess > 0 {
+			padded = padded[excess/2 : excess/2+h.minLength]
+		}
+		result = padded
+	}
+	return string(result)
+}
+
+// shuffled shuffles the provided alphabet for the provided lottery
+// character.
+func (h *Hashids) shuffled(lottery byte, alphabet []byte) []byte {
+	buffer := append(append([]byte{lottery}, h.salt...), alphabet...)
+	return shuffle(alphabet, buffer[:len(alphabet)])
+}
+
+// shuffle shuffles the provided alphabet in place with the provided salt,
+// such that the same salt always produces the same order.
+func shuffle(alphabet, salt []byte) []byte {
+	if len(salt) == 0 {
+		return alphabet
+	}
+	for i, v, p := len(alphabet)-1, 0, 0; i > 0; i, v = i-1, v+1 {
+		v %= len(salt)
+		c := int(salt[v])
+		p += c
+		j := (c + v + p) % i
+		alphabet[i], alphabet[j] = alphabet[j], alphabet[i]
+	}
+	return alphabet
+}
+
+// toDigits provides the provided integer in the base of the provided
+// alphabet, whose characters are the digits.
+func toDigits(n uint64, alphabet []byte) []byte {
+	base := uint64(len(alphabet))
+	var digits []byte
+	for {
+		digits = append([]byte{alphabet[n%base]}, digits...)
+		n /= base
+		if n == 0 {
+			return digits
+		}
+	}
+}
+
+// unhash provides the integer represented by the provided digits in the
+// base of the provided alphabet, failing when a digit is outside of the
+// alphabet or the integer overflows.
+func unhash(digits string, alphabet []byte) (uint64, bool) {
+	base := uint64(len(alphabet))
+	var n uint64
+	for i := 0; i < len(digits); i++ {
+		d := indexOf(alphabet, digits[i])
+		if d < 0 || n > (math.MaxUint64-uint64(d))/base {
+			return 0, false
+		}
+		n = n*base + uint64(d)
+	}
+	return n, true
+}
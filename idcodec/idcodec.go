@@ -0,0 +1,151 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package idcodec provides implementations of obscurer.IDCodec, which
+// encode the values of route parameters for obscurer.NewHybridObscurer.
+//
+// Hashids and Sqids encode numeric identifiers as short strings, and are
+// suited to hiding sequential identifiers rather than to protecting them,
+// as their encodings can be reversed by anyone who learns the alphabet and
+// salt. AESSIV encrypts values deterministically with AES-SIV, such that
+// their encodings cannot be forged or reversed without the key. UUID maps
+// values to random UUIDs recorded in a table, which reveal nothing about
+// the values at the cost of a lookup.
+//
+// Codecs are selected per route parameter with ByParameter, or by
+// configuration with FromConfig, such that each parameter can be encoded
+// with a different codec:
+//
+//	{
+//	  "default": {"codec": "sqids"},
+//	  "parameters": {
+//	    "user_id": {"codec": "hashids", "salt": "users"},
+//	    "order_id": {"codec": "aes-siv", "key": "<hex encoded key>"}
+//	  }
+//	}
+package idcodec
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/freerware/obscurer"
+)
+
+// Options represents the configuration options for the codecs encoding
+// numeric identifiers.
+type Options struct {
+	// Alphabet is the characters used within encodings, which must be
+	// unique.
+	Alphabet string
+	// MinLength is the minimum length of encodings, which are padded to
+	// reach it.
+	MinLength int
+}
+
+// Option applies an option to the provided configuration.
+type Option func(*Options)
+
+// WithAlphabet configures the characters used within encodings.
+func WithAlphabet(alphabet string) Option {
+	return func(o *Options) {
+		o.Alphabet = alphabet
+	}
+}
+
+// WithMinLength configures the minimum length of encodings.
+func WithMinLength(length int) Option {
+	return func(o *Options) {
+		o.MinLength = length
+	}
+}
+
+// options applies the provided options to the provided defaults, ensuring
+// the alphabet is made of at least the provided number of unique bytes.
+func options(defaults Options, minAlphabet int, opts []Option) (Options, error) {
+	for _, opt := range opts {
+		opt(&defaults)
+	}
+	if defaults.MinLength < 0 {
+		return defaults, fmt.Errorf("idcodec: negative minimum length %d", defaults.MinLength)
+	}
+	seen := make(map[byte]bool, len(defaults.Alphabet))
+	for i := 0; i < len(defaults.Alphabet); i++ {
+		c := defaults.Alphabet[i]
+		if c >= 0x80 || c == ' ' || c == '/' || seen[c] {
+			return defaults, fmt.Errorf("idcodec: alphabet %q must have unique ASCII characters other than ' ' and '/'", defaults.Alphabet)
+		}
+		seen[c] = true
+	}
+	if len(defaults.Alphabet) < minAlphabet {
+		return defaults, fmt.Errorf("idcodec: alphabet %q must have at least %d characters", defaults.Alphabet, minAlphabet)
+	}
+	return defaults, nil
+}
+
+// invalid provides an error indicating the provided value cannot be
+// encoded or decoded for the provided reason.
+func invalid(value, reason string) error {
+	return fmt.Errorf("%w: %q %s", obscurer.ErrInvalidID, value, reason)
+}
+
+// byParameter selects the codec for each route parameter by its name.
+type byParameter struct {
+	fallback obscurer.IDCodec
+	codecs   map[string]obscurer.IDCodec
+}
+
+// ByParameter constructs a codec that encodes the values of the route
+// parameters named in the provided map with their corresponding codecs, and
+// the values of all other parameters with the provided fallback. Parameters
+// mapped to a nil codec, or without a codec when the fallback is nil, are
+// left as is.
+func ByParameter(fallback obscurer.IDCodec, codecs map[string]obscurer.IDCodec) obscurer.ParameterIDCodec {
+	c := &byParameter{fallback: fallback, codecs: make(map[string]obscurer.IDCodec, len(codecs))}
+	for name, codec := range codecs {
+		c.codecs[name] = codec
+	}
+	return c
+}
+
+// Codec provides the codec for the route parameter with the provided name.
+func (c *byParameter) Codec(parameter string) obscurer.IDCodec {
+	if codec, ok := c.codecs[parameter]; ok {
+		return codec
+	}
+	return c.fallback
+}
+
+// Encode encodes the provided value with the fallback codec.
+func (c *byParameter) Encode(value string) (string, error) {
+	if c.fallback == nil {
+		return value, nil
+	}
+	return c.fallback.Encode(value)
+}
+
+// Decode decodes the provided value with the fallback codec.
+func (c *byParameter) Decode(encoded string) (string, error) {
+	if c.fallback == nil {
+		return encoded, nil
+	}
+	return c.fallback.Decode(encoded)
+}
+
+// indexOf provides the position of the provided character within the
+// provided alphabet, or -1 when it is absent.
+func indexOf(alphabet []byte, c byte) int {
+	return strings.IndexByte(string(alphabet), c)
+}
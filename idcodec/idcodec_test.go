@@ -0,0 +1,255 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package idcodec_test
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/idcodec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHashids tests that integers are encoded as other implementations of
+// Hashids encode them, and decode back.
+func TestHashids(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []idcodec.Option
+		value    string
+		expected string
+	}{
+		{name: "Default", value: "12345", expected: "NkK9"},
+		{name: "MinLength", opts: []idcodec.Option{idcodec.WithMinLength(8)}, value: "1", expected: "gB0NV05e"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			c, err := idcodec.NewHashids("this is my salt", test.opts...)
+			require.NoError(t, err)
+
+			// action.
+			encoded, err := c.Encode(test.value)
+
+			// assert.
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, encoded)
+			decoded, err := c.Decode(encoded)
+			require.NoError(t, err)
+			assert.Equal(t, test.value, decoded)
+		})
+	}
+}
+
+// TestSqids tests that integers are encoded as other implementations of
+// Sqids encode them, and decode back.
+func TestSqids(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []idcodec.Option
+		value    string
+		expected string
+	}{
+		{name: "Zero", value: "0", expected: "bM"},
+		{name: "One", value: "1", expected: "Uk"},
+		{name: "MinLength", opts: []idcodec.Option{idcodec.WithMinLength(10)}, value: "1", expected: "UkLWZg9DAJ"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			c, err := idcodec.NewSqids(test.opts...)
+			require.NoError(t, err)
+
+			// action.
+			encoded, err := c.Encode(test.value)
+
+			// assert.
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, encoded)
+			decoded, err := c.Decode(encoded)
+			require.NoError(t, err)
+			assert.Equal(t, test.value, decoded)
+		})
+	}
+}
+
+// TestAESSIV tests that values are encrypted as specified by RFC 5297, and
+// decrypt back.
+func TestAESSIV(t *testing.T) {
+	// arrange.
+	key := mustDecodeHex("fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	ad := mustDecodeHex("101112131415161718191a1b1c1d1e1f2021222324252627")
+	plaintext := mustDecodeHex("112233445566778899aabbccddee")
+	c, err := idcodec.NewAESSIV(key, ad)
+	require.NoError(t, err)
+
+	// action.
+	encoded, err := c.Encode(string(plaintext))
+
+	// assert.
+	require.NoError(t, err)
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, "85632d07c6e8f37f950acd320a2ecc9340c02b9690c4dc04daef7f6afe5c", hex.EncodeToString(sealed))
+	decoded, err := c.Decode(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, string(plaintext), decoded)
+}
+
+// TestAESSIV_Tampered tests that encodings not produced with the key, or
+// with different associated data, fail to decode.
+func TestAESSIV_Tampered(t *testing.T) {
+	// arrange.
+	key := make([]byte, 32)
+	c, err := idcodec.NewAESSIV(key, []byte("users"))
+	require.NoError(t, err)
+	other, err := idcodec.NewAESSIV(key, []byte("orders"))
+	require.NoError(t, err)
+	encoded, err := c.Encode("12345678901234567890")
+	require.NoError(t, err)
+	tampered := []byte(encoded)
+	tampered[len(tampered)-1] ^= 1
+
+	// action.
+	_, tamperedErr := c.Decode(string(tampered))
+	_, otherErr := other.Decode(encoded)
+
+	// assert.
+	assert.True(t, errors.Is(tamperedErr, obscurer.ErrInvalidID))
+	assert.True(t, errors.Is(otherErr, obscurer.ErrInvalidID))
+}
+
+// TestNewAESSIV_InvalidKey tests that keys of the wrong size result in an
+// error.
+func TestNewAESSIV_InvalidKey(t *testing.T) {
+	// action.
+	_, err := idcodec.NewAESSIV(make([]byte, 16))
+
+	// assert.
+	assert.True(t, errors.Is(err, obscurer.ErrInvalidKey))
+}
+
+// TestUUID tests that identifiers are assigned a single UUID, which decodes
+// back to the identifier.
+func TestUUID(t *testing.T) {
+	// arrange.
+	c := idcodec.NewUUID(idcodec.NewMemoryTable())
+
+	// action.
+	encoded, err := c.Encode("42")
+
+	// assert.
+	require.NoError(t, err)
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, encoded)
+	again, err := c.Encode("42")
+	require.NoError(t, err)
+	assert.Equal(t, encoded, again)
+	decoded, err := c.Decode(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, "42", decoded)
+	_, err = c.Decode("00000000-0000-4000-8000-000000000000")
+	assert.True(t, errors.Is(err, obscurer.ErrInvalidID))
+}
+
+// TestCodecs_Invalid tests that values the codecs cannot encode or decode
+// result in an error.
+func TestCodecs_Invalid(t *testing.T) {
+	hashids, err := idcodec.NewHashids("salt")
+	require.NoError(t, err)
+	sqids, err := idcodec.NewSqids()
+	require.NoError(t, err)
+	tests := []struct {
+		name    string
+		codec   obscurer.IDCodec
+		value   string
+		encoded string
+	}{
+		{name: "Hashids", codec: hashids, value: "abc", encoded: "!!"},
+		{name: "Sqids", codec: sqids, value: "-1", encoded: "!!"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// action.
+			_, encodeErr := test.codec.Encode(test.value)
+			_, decodeErr := test.codec.Decode(test.encoded)
+
+			// assert.
+			assert.True(t, errors.Is(encodeErr, obscurer.ErrInvalidID))
+			assert.True(t, errors.Is(decodeErr, obscurer.ErrInvalidID))
+		})
+	}
+}
+
+// TestNewHashids_InvalidAlphabet tests that alphabets that are too short or
+// have duplicate characters result in an error.
+func TestNewHashids_InvalidAlphabet(t *testing.T) {
+	for _, alphabet := range []string{"abc", "aabcdefghijklmnopqrstuvwxyz"} {
+		// action.
+		_, err := idcodec.NewHashids("salt", idcodec.WithAlphabet(alphabet))
+
+		// assert.
+		assert.Error(t, err, "expected alphabet %q to be rejected", alphabet)
+	}
+}
+
+// TestByParameter tests that route parameters are encoded with the codec
+// selected for them.
+func TestByParameter(t *testing.T) {
+	// arrange.
+	hashids, err := idcodec.NewHashids("this is my salt")
+	require.NoError(t, err)
+	sqids, err := idcodec.NewSqids()
+	require.NoError(t, err)
+	codec := idcodec.ByParameter(hashids, map[string]obscurer.IDCodec{
+		"orderID": sqids,
+		"page":    nil,
+	})
+	o, err := obscurer.NewHybridObscurer(obscurer.Default, codec, "/users/{id}/orders/{orderID}/{page}")
+	require.NoError(t, err)
+	original := mustParse("/users/12345/orders/1/2")
+
+	// action.
+	obscured := o.Obscure(original)
+
+	// assert.
+	values, ok := o.Templates()[0].Match(obscured.Path)
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"id": "NkK9", "orderID": "Uk", "page": "2"}, values)
+	resolved, ok := o.Resolve(obscured)
+	require.True(t, ok)
+	assert.Equal(t, original.String(), resolved.String())
+}
+
+func mustDecodeHex(str string) []byte {
+	b, err := hex.DecodeString(str)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func mustParse(str string) *url.URL {
+	u, err := url.Parse(str)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
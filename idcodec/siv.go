@@ -0,0 +1,178 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package idcodec
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/freerware/obscurer"
+)
+
+// sivSize is the size of the synthetic initialization vector.
+const sivSize = aes.BlockSize
+
+// AESSIV encrypts identifiers deterministically with AES-SIV, as specified
+// by RFC 5297, such that the same identifier always encrypts to the same
+// encoding. Encodings are the synthetic initialization vector followed by
+// the ciphertext, encoded with unpadded URL-safe base64, and are
+// authenticated, so that encodings not produced with the key fail to decode.
+type AESSIV struct {
+	mac            cipher.Block
+	ctr            cipher.Block
+	associatedData [][]byte
+}
+
+var _ obscurer.IDCodec = (*AESSIV)(nil)
+
+// NewAESSIV constructs an AES-SIV codec with the provided key, which must be
+// 32, 48, or 64 bytes to select AES-128, AES-192, or AES-256 respectively.
+// The provided associated data is authenticated with every identifier, such
+// that encodings produced with different associated data, such as for
+// different kinds of resources, fail to decode.
+func NewAESSIV(key []byte, associatedData ...[]byte) (*AESSIV, error) {
+	switch len(key) {
+	case 32, 48, 64:
+	default:
+		return nil, fmt.Errorf("%w: AES-SIV requires a 32, 48, or 64 byte key", obscurer.ErrInvalidKey)
+	}
+	mac, err := aes.NewCipher(key[:len(key)/2])
+	if err != nil {
+		return nil, err
+	}
+	ctr, err := aes.NewCipher(key[len(key)/2:])
+	if err != nil {
+		return nil, err
+	}
+	return &AESSIV{mac: mac, ctr: ctr, associatedData: associatedData}, nil
+}
+
+// Encode encrypts the provided identifier.
+func (c *AESSIV) Encode(value string) (string, error) {
+	plaintext := []byte(value)
+	v := c.s2v(plaintext)
+	sealed := make([]byte, sivSize+len(plaintext))
+	copy(sealed, v)
+	c.xor(v, sealed[sivSize:], plaintext)
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decode decrypts the provided encoding, failing when it was not produced
+// by the codec.
+func (c *AESSIV) Decode(encoded string) (string, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < sivSize {
+		return "", invalid(encoded, "is not an AES-SIV encoding")
+	}
+	v, ciphertext := sealed[:sivSize], sealed[sivSize:]
+	plaintext := make([]byte, len(ciphertext))
+	c.xor(v, plaintext, ciphertext)
+	if subtle.ConstantTimeCompare(c.s2v(plaintext), v) != 1 {
+		return "", invalid(encoded, "fails to authenticate")
+	}
+	return string(plaintext), nil
+}
+
+// xor applies the keystream for the provided synthetic initialization
+// vector to the provided source, placing the result into the provided
+// destination.
+func (c *AESSIV) xor(v, dst, src []byte) {
+	q := make([]byte, sivSize)
+	copy(q, v)
+	q[8] &= 0x7f
+	q[12] &= 0x7f
+	cipher.NewCTR(c.ctr, q).XORKeyStream(dst, src)
+}
+
+// s2v derives the synthetic initialization vector for the provided
+// plaintext and the associated data of the codec.
+func (c *AESSIV) s2v(plaintext []byte) []byte {
+	d := c.cmac(make([]byte, sivSize))
+	for _, ad := range c.associatedData {
+		d = dbl(d)
+		xorInto(d, c.cmac(ad))
+	}
+	var t []byte
+	if len(plaintext) >= sivSize {
+		t = append([]byte(nil), plaintext...)
+		xorInto(t[len(t)-sivSize:], d)
+	} else {
+		t = dbl(d)
+		padded := make([]byte, sivSize)
+		copy(padded, plaintext)
+		padded[len(plaintext)] = 0x80
+		xorInto(t, padded)
+	}
+	return c.cmac(t)
+}
+
+// cmac provides the CMAC of the provided message, as specified by RFC 4493.
+func (c *AESSIV) cmac(message []byte) []byte {
+	k1 := make([]byte, sivSize)
+	c.mac.Encrypt(k1, k1)
+	k1 = dbl(k1)
+
+	// complete the last block, and mask it with the subkey.
+	n := (len(message) + sivSize - 1) / sivSize
+	last := make([]byte, sivSize)
+	if n > 0 && len(message)%sivSize == 0 {
+		copy(last, message[(n-1)*sivSize:])
+		xorInto(last, k1)
+	} else {
+		if n == 0 {
+			n = 1
+		}
+		rest := message[(n-1)*sivSize:]
+		copy(last, rest)
+		last[len(rest)] = 0x80
+		xorInto(last, dbl(k1))
+	}
+
+	// chain the blocks.
+	x := make([]byte, sivSize)
+	for i := 0; i < n-1; i++ {
+		xorInto(x, message[i*sivSize:(i+1)*sivSize])
+		c.mac.Encrypt(x, x)
+	}
+	xorInto(x, last)
+	c.mac.Encrypt(x, x)
+	return x
+}
+
+// dbl provides the provided block multiplied by x in GF(2^128).
+func dbl(block []byte) []byte {
+	result := make([]byte, len(block))
+	var carry byte
+	for i := len(block) - 1; i >= 0; i-- {
+		result[i] = block[i]<<1 | carry
+		carry = block[i] >> 7
+	}
+	if carry == 1 {
+		result[len(result)-1] ^= 0x87
+	}
+	return result
+}
+
+// xorInto applies the provided source to the provided destination with
+// exclusive or.
+func xorInto(dst, src []byte) {
+	for i := range src {
+		dst[i] ^= src[i]
+	}
+}
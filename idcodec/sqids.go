@@ -0,0 +1,159 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package idcodec
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/freerware/obscurer"
+)
+
+const (
+	// SqidsAlphabet is the default alphabet of Sqids.
+	SqidsAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+	// sqidsMinAlphabet is the minimum length of the Sqids alphabet.
+	sqidsMinAlphabet = 3
+)
+
+// Sqids encodes non-negative integer identifiers with the Sqids algorithm,
+// producing the same encodings as other implementations of Sqids given the
+// same alphabet and minimum length, and an empty blocklist.
+type Sqids struct {
+	alphabet  []byte
+	minLength int
+}
+
+var _ obscurer.IDCodec = (*Sqids)(nil)
+
+// NewSqids constructs a Sqids codec with the provided options. The alphabet
+// must have at least 3 characters, and the minimum length can't exceed 255.
+func NewSqids(opts ...Option) (*Sqids, error) {
+	o, err := options(Options{Alphabet: SqidsAlphabet}, sqidsMinAlphabet, opts)
+	if err != nil {
+		return nil, err
+	}
+	if o.MinLength > 255 {
+		return nil, invalid(strconv.Itoa(o.MinLength), "exceeds the maximum minimum length of 255")
+	}
+	return &Sqids{alphabet: sqidsShuffle([]byte(o.Alphabet)), minLength: o.MinLength}, nil
+}
+
+// Encode encodes the provided non-negative integer.
+func (s *Sqids) Encode(value string) (string, error) {
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return "", invalid(value, "is not a non-negative integer")
+	}
+	return s.encode([]uint64{n}), nil
+}
+
+// Decode decodes the provided encoding to the integer it encodes.
+func (s *Sqids) Decode(encoded string) (string, error) {
+	numbers, ok := s.decode(encoded)
+	if !ok || len(numbers) != 1 || s.encode(numbers) != encoded {
+		return "", invalid(encoded, "is not a Sqids encoding of a single integer")
+	}
+	return strconv.FormatUint(numbers[0], 10), nil
+}
+
+// encode encodes the provided integers.
+func (s *Sqids) encode(numbers []uint64) string {
+	// rotate the alphabet by an offset derived from the numbers.
+	size := uint64(len(s.alphabet))
+	offset := uint64(len(numbers))
+	for i, n := range numbers {
+		offset += uint64(s.alphabet[n%size]) + uint64(i)
+	}
+	offset %= size
+	alphabet := append(append([]byte(nil), s.alphabet[offset:]...), s.alphabet[:offset]...)
+	prefix := alphabet[0]
+	reverse(alphabet)
+
+	// encode the numbers, separated by the first character of the
+	// alphabet.
+	result := []byte{prefix}
+	for i, n := range numbers {
+		result = append(result, toDigits(n, alphabet[1:])...)
+		if i < len(numbers)-1 {
+			result = append(result, alphabet[0])
+			alphabet = sqidsShuffle(alphabet)
+		}
+	}
+
+	// pad the encoding to the minimum length.
+	if len(result) < s.minLength {
+		result = append(result, alphabet[0])
+		for len(result) < s.minLength {
+			alphabet = sqidsShuffle(alphabet)
+			n := s.minLength - len(result)
+			if n > len(alphabet) {
+				n = len(alphabet)
+			}
+			result = append(result, alphabet[:n]...)
+		}
+	}
+	return string(result)
+}
+
+// decode decodes the provided encoding to the integers it encodes.
+func (s *Sqids) decode(encoded string) ([]uint64, bool) {
+	if encoded == "" {
+		return nil, false
+	}
+	offset := indexOf(s.alphabet, encoded[0])
+	if offset < 0 {
+		return nil, false
+	}
+	alphabet := append(append([]byte(nil), s.alphabet[offset:]...), s.alphabet[:offset]...)
+	reverse(alphabet)
+	var numbers []uint64
+	for rest := encoded[1:]; rest != ""; {
+		chunks := strings.SplitN(rest, string(alphabet[0]), 2)
+		if chunks[0] == "" {
+			break
+		}
+		n, ok := unhash(chunks[0], alphabet[1:])
+		if !ok {
+			return nil, false
+		}
+		numbers = append(numbers, n)
+		rest = ""
+		if len(chunks) > 1 {
+			alphabet = sqidsShuffle(alphabet)
+			rest = chunks[1]
+		}
+	}
+	return numbers, true
+}
+
+// sqidsShuffle shuffles the provided alphabet in place, such that the same
+// alphabet always produces the same order.
+func sqidsShuffle(alphabet []byte) []byte {
+	for i, j := 0, len(alphabet)-1; j > 0; i, j = i+1, j-1 {
+		r := (i*j + int(alphabet[i]) + int(alphabet[j])) % len(alphabet)
+		alphabet[i], alphabet[r] = alphabet[r], alphabet[i]
+	}
+	return alphabet
+}
+
+// reverse reverses the provided alphabet in place.
+func reverse(alphabet []byte) {
+	for i, j := 0, len(alphabet)-1; i < j; i, j = i+1, j-1 {
+		alphabet[i], alphabet[j] = alphabet[j], alphabet[i]
+	}
+}
@@ -0,0 +1,121 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package idcodec
+
+import (
+	"crypto/rand"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/freerware/obscurer"
+)
+
+// uuidPattern matches UUIDs in their canonical, lowercase form.
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// Table represents a table recording the UUIDs assigned to identifiers,
+// which must be shared by every instance of the application.
+type Table interface {
+	// Assign assigns the provided UUID to the provided identifier, unless a
+	// UUID is already assigned to it, providing the UUID assigned.
+	Assign(id, uuid string) (string, error)
+	// Lookup provides the identifier assigned the provided UUID.
+	Lookup(uuid string) (string, bool, error)
+}
+
+// UUID maps identifiers to random UUIDs recorded in a table, such that
+// encodings reveal nothing about the identifiers they encode.
+type UUID struct {
+	table Table
+}
+
+var _ obscurer.IDCodec = (*UUID)(nil)
+
+// NewUUID constructs a UUID codec recording UUIDs in the provided table.
+func NewUUID(table Table) *UUID {
+	return &UUID{table: table}
+}
+
+// Encode provides the UUID assigned to the provided identifier, assigning a
+// random one when it has none.
+func (c *UUID) Encode(value string) (string, error) {
+	uuid, err := newUUID()
+	if err != nil {
+		return "", err
+	}
+	return c.table.Assign(value, uuid)
+}
+
+// Decode provides the identifier assigned the provided UUID.
+func (c *UUID) Decode(encoded string) (string, error) {
+	if !uuidPattern.MatchString(encoded) {
+		return "", invalid(encoded, "is not a UUID")
+	}
+	id, ok, err := c.table.Lookup(encoded)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", invalid(encoded, "is not assigned to an identifier")
+	}
+	return id, nil
+}
+
+// newUUID provides a random, version 4 UUID.
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// memoryTable records UUIDs in memory.
+type memoryTable struct {
+	mutex sync.RWMutex
+	uuids map[string]string
+	ids   map[string]string
+}
+
+// NewMemoryTable constructs a table recording UUIDs in memory, which is
+// suited to tests and to applications running as a single instance that
+// never restarts.
+func NewMemoryTable() Table {
+	return &memoryTable{uuids: make(map[string]string), ids: make(map[string]string)}
+}
+
+// Assign assigns the provided UUID to the provided identifier, unless a
+// UUID is already assigned to it, providing the UUID assigned.
+func (t *memoryTable) Assign(id, uuid string) (string, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if assigned, ok := t.uuids[id]; ok {
+		return assigned, nil
+	}
+	t.uuids[id], t.ids[uuid] = uuid, id
+	return uuid, nil
+}
+
+// Lookup provides the identifier assigned the provided UUID.
+func (t *memoryTable) Lookup(uuid string) (string, bool, error) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	id, ok := t.ids[uuid]
+	return id, ok, nil
+}
@@ -9,6 +9,7 @@ import (
 	url "net/url"
 	reflect "reflect"
 
+	obscurer "github.com/freerware/obscurer"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -50,12 +51,13 @@ func (mr *StoreMockRecorder) Clear(arg0 interface{}) *gomock.Call {
 }
 
 // Get mocks base method.
-func (m *Store) Get(arg0 context.Context, arg1 *url.URL) (*url.URL, bool) {
+func (m *Store) Get(arg0 context.Context, arg1 *url.URL) (*url.URL, bool, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Get", arg0, arg1)
 	ret0, _ := ret[0].(*url.URL)
 	ret1, _ := ret[1].(bool)
-	return ret0, ret1
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // Get indicates an expected call of Get.
@@ -65,7 +67,7 @@ func (mr *StoreMockRecorder) Get(arg0, arg1 interface{}) *gomock.Call {
 }
 
 // Load mocks base method.
-func (m *Store) Load(arg0 context.Context, arg1 map[*url.URL]*url.URL) error {
+func (m *Store) Load(arg0 context.Context, arg1 obscurer.Mappings) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Load", arg0, arg1)
 	ret0, _ := ret[0].(error)
@@ -0,0 +1,145 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package protowire encodes and decodes the protocol buffers wire format,
+// for the messages defined in proto/freerware/obscurer/v1.
+package protowire
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+// ErrMalformed represents an error that occurs when decoding a message that
+// is corrupt.
+var ErrMalformed = errors.New("protowire: malformed message")
+
+// protocol buffer wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// AppendUvarint appends the provided unsigned varint.
+func AppendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+// AppendVarint appends the provided varint field, omitting it when zero.
+func AppendVarint(b []byte, field, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = AppendUvarint(b, field<<3|wireVarint)
+	return AppendUvarint(b, v)
+}
+
+// AppendBytes appends the provided length delimited field.
+func AppendBytes(b []byte, field uint64, v []byte) []byte {
+	b = AppendUvarint(b, field<<3|wireBytes)
+	b = AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// AppendString appends the provided string field, omitting it when empty.
+func AppendString(b []byte, field uint64, v string) []byte {
+	if v == "" {
+		return b
+	}
+	return AppendBytes(b, field, []byte(v))
+}
+
+// AppendMap appends the provided map<string, string> field, with the
+// entries ordered by key so that the output is deterministic.
+func AppendMap(b []byte, field uint64, m map[string]string) []byte {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var entry []byte
+		entry = AppendBytes(entry, 1, []byte(key))
+		entry = AppendBytes(entry, 2, []byte(m[key]))
+		b = AppendBytes(b, field, entry)
+	}
+	return b
+}
+
+// MapEntry decodes an entry of a map<string, string> field.
+func MapEntry(data []byte) (key, value string, err error) {
+	err = Fields(data, func(field, _ uint64, bytes []byte) error {
+		switch field {
+		case 1:
+			key = string(bytes)
+		case 2:
+			value = string(bytes)
+		}
+		return nil
+	})
+	return
+}
+
+// Fields invokes the provided function for each field of the provided
+// message, with the value of varint fields or the contents of length
+// delimited fields. Fixed width fields are skipped.
+func Fields(data []byte, fn func(field, v uint64, bytes []byte) error) error {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return ErrMalformed
+		}
+		data = data[n:]
+		field := tag >> 3
+		switch tag & 7 {
+		case wireVarint:
+			v, n := binary.Uvarint(data)
+			if n <= 0 {
+				return ErrMalformed
+			}
+			data = data[n:]
+			if err := fn(field, v, nil); err != nil {
+				return err
+			}
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return ErrMalformed
+			}
+			data = data[n:]
+			if err := fn(field, 0, data[:length]); err != nil {
+				return err
+			}
+			data = data[length:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return ErrMalformed
+			}
+			data = data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return ErrMalformed
+			}
+			data = data[4:]
+		default:
+			return ErrMalformed
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// isJSON indicates whether the provided media type is JSON, including the
+// structured syntax suffix, such as 'application/problem+json'.
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// jsonTransform provides the replacement for the provided scalar value of
+// the object member with the provided name, or false to leave it as is.
+// Values are strings, json.Number, bool, or nil, as provided by
+// json.Decoder.Token.
+type jsonTransform func(field string, value interface{}) (interface{}, bool)
+
+// jsonPathTransform provides the replacement for the provided scalar value
+// of the object member with the provided path, which holds the names of the
+// members enclosing it, outermost first, or false to leave it as is.
+type jsonPathTransform func(path []string, value interface{}) (interface{}, bool)
+
+// rewriteJSON rewrites the scalar values of the provided JSON document with
+// the provided transform, preserving the order of object members. Elements
+// of arrays are provided with the name of the member holding the array.
+func rewriteJSON(data []byte, transform jsonTransform) ([]byte, error) {
+	return rewriteJSONPath(data, func(path []string, value interface{}) (interface{}, bool) {
+		return transform(path[len(path)-1], value)
+	})
+}
+
+// rewriteJSONPath rewrites the scalar values of the provided JSON document
+// as rewriteJSON does, providing the transform with the path of the member
+// holding each value. Elements of arrays are provided with the path of the
+// member holding the array.
+func rewriteJSONPath(data []byte, transform jsonPathTransform) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	r := &jsonRewriter{dec: dec, transform: transform}
+	if err := r.value(nil); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("obscurer: unexpected data after JSON value")
+	}
+	return r.buf.Bytes(), nil
+}
+
+// jsonRewriter re-encodes a stream of JSON tokens, transforming its scalar
+// values.
+type jsonRewriter struct {
+	dec       *json.Decoder
+	buf       bytes.Buffer
+	transform jsonPathTransform
+}
+
+// value re-encodes the next value, which is held by the member with the
+// provided path.
+func (r *jsonRewriter) value(path []string) error {
+	tok, err := r.dec.Token()
+	if err != nil {
+		return err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		if len(path) > 0 {
+			if replaced, ok := r.transform(path, tok); ok {
+				tok = replaced
+			}
+		}
+		return r.scalar(tok)
+	}
+	switch delim {
+	case '{':
+		r.buf.WriteByte('{')
+		for i := 0; r.dec.More(); i++ {
+			key, err := r.dec.Token()
+			if err != nil {
+				return err
+			}
+			if i > 0 {
+				r.buf.WriteByte(',')
+			}
+			if err := r.scalar(key); err != nil {
+				return err
+			}
+			r.buf.WriteByte(':')
+			// copy the path, such that the paths of siblings don't share
+			// their backing array.
+			member := append(path[:len(path):len(path)], key.(string))
+			if err := r.value(member); err != nil {
+				return err
+			}
+		}
+		r.buf.WriteByte('}')
+	case '[':
+		r.buf.WriteByte('[')
+		for i := 0; r.dec.More(); i++ {
+			if i > 0 {
+				r.buf.WriteByte(',')
+			}
+			if err := r.value(path); err != nil {
+				return err
+			}
+		}
+		r.buf.WriteByte(']')
+	}
+	// consume the closing delimiter.
+	_, err = r.dec.Token()
+	return err
+}
+
+// scalar encodes the provided scalar value, leaving HTML characters as is.
+func (r *jsonRewriter) scalar(value interface{}) error {
+	if n, ok := value.(json.Number); ok {
+		r.buf.WriteString(n.String())
+		return nil
+	}
+	enc := json.NewEncoder(&r.buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return err
+	}
+	// drop the newline terminating the encoded value.
+	r.buf.Truncate(r.buf.Len() - 1)
+	return nil
+}
+
+// idFields encodes and decodes the identifiers held by configured members
+// of JSON documents.
+type idFields struct {
+	codec  IDCodec
+	fields map[string]bool
+}
+
+// codecFor provides the codec of the provided member, which is nil when the
+// member does not hold an identifier.
+func (f *idFields) codecFor(field string) IDCodec {
+	if !f.fields[field] {
+		return nil
+	}
+	if selector, ok := f.codec.(ParameterIDCodec); ok {
+		return selector.Codec(field)
+	}
+	return f.codec
+}
+
+// encodeValue encodes the provided value of the member with the provided
+// name when it holds an identifier, such that numeric and string
+// identifiers both become encoded strings. Identifiers that fail to be
+// encoded are left as is.
+func (f *idFields) encodeValue(field string, value interface{}) (interface{}, bool) {
+	codec := f.codecFor(field)
+	if codec == nil {
+		return nil, false
+	}
+	var id string
+	switch v := value.(type) {
+	case json.Number:
+		id = v.String()
+	case string:
+		id = v
+	default:
+		return nil, false
+	}
+	encoded, err := codec.Encode(id)
+	return encoded, err == nil
+}
+
+// decodeValue decodes the provided value of the member with the provided
+// name when it holds an identifier. Identifiers decoding to integers become
+// numbers, reversing encodeValue. Identifiers that fail to be decoded are
+// left as is.
+func (f *idFields) decodeValue(field string, value interface{}) (interface{}, bool) {
+	codec := f.codecFor(field)
+	encoded, ok := value.(string)
+	if codec == nil || !ok {
+		return nil, false
+	}
+	decoded, err := codec.Decode(encoded)
+	if err != nil {
+		return nil, false
+	}
+	if n, err := strconv.ParseInt(decoded, 10, 64); err == nil && strconv.FormatInt(n, 10) == decoded {
+		return json.Number(decoded), true
+	}
+	return decoded, true
+}
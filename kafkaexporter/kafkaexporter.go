@@ -0,0 +1,132 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package kafkaexporter provides an events.Sink that publishes mapping
+// lifecycle events to a Kafka topic, for audit and analytics pipelines built
+// on the event bus.
+//
+// Events are keyed by the obscured path, so that the events of a mapping
+// land on the same partition and are consumed in order. Events affecting the
+// entire store have no key.
+package kafkaexporter
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/freerware/obscurer/events"
+)
+
+// DefaultTopic represents the topic events are published to by default.
+const DefaultTopic = "obscurer.events"
+
+// Format represents the serialization of published events.
+type Format int
+
+const (
+	// FormatProtobuf serializes events as the Event message of
+	// proto/freerware/obscurer/v1/event.proto.
+	FormatProtobuf Format = iota
+	// FormatJSON serializes events as JSON.
+	FormatJSON
+)
+
+// contentTypes represents the content type of each format.
+var contentTypes = map[Format]string{
+	FormatProtobuf: "application/x-protobuf",
+	FormatJSON:     "application/json",
+}
+
+// Message represents a Kafka message.
+type Message struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers map[string]string
+}
+
+// Producer represents the subset of a Kafka producer needed by the
+// exporter.
+type Producer interface {
+	// Produce writes the provided message, returning once it has been
+	// acknowledged.
+	Produce(ctx context.Context, m Message) error
+}
+
+// Options represents the configuration options for the exporter.
+type Options struct {
+	// Topic is the topic events are published to.
+	Topic string
+	// Format is the serialization of published events.
+	Format Format
+}
+
+// Option applies an option to the provided configuration.
+type Option func(*Options)
+
+// WithTopic configures the topic events are published to.
+func WithTopic(topic string) Option {
+	return func(o *Options) {
+		o.Topic = topic
+	}
+}
+
+// WithFormat configures the serialization of published events.
+func WithFormat(format Format) Option {
+	return func(o *Options) {
+		o.Format = format
+	}
+}
+
+// Exporter publishes events to Kafka.
+type Exporter struct {
+	producer Producer
+	options  Options
+}
+
+// New constructs an exporter publishing events with the provided producer.
+func New(producer Producer, opts ...Option) *Exporter {
+	options := Options{Topic: DefaultTopic}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &Exporter{producer: producer, options: options}
+}
+
+// Publish publishes the provided event.
+func (e *Exporter) Publish(ctx context.Context, event events.Event) error {
+	m := Message{
+		Topic: e.options.Topic,
+		Headers: map[string]string{
+			"content-type": contentTypes[e.options.Format],
+			"event-id":     event.ID,
+			"event-type":   event.Type.String(),
+		},
+	}
+	if event.Obscured != nil {
+		m.Key = []byte(event.Obscured.Path)
+	}
+	switch e.options.Format {
+	case FormatJSON:
+		value, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		m.Value = value
+	default:
+		m.Value = events.Marshal(event)
+	}
+	return e.producer.Produce(ctx, m)
+}
@@ -0,0 +1,112 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafkaexporter_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/freerware/obscurer/events"
+	"github.com/freerware/obscurer/kafkaexporter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// producer records the messages produced with it.
+type producer struct {
+	messages []kafkaexporter.Message
+	err      error
+}
+
+func (p *producer) Produce(ctx context.Context, m kafkaexporter.Message) error {
+	p.messages = append(p.messages, m)
+	return p.err
+}
+
+// TestExporter_Publish tests that events are published as protocol buffers
+// keyed by the obscured path.
+func TestExporter_Publish(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	p := &producer{}
+	e := events.New(events.TypePut, mustParse("/abc"), mustParse("/this/is/the/way"))
+	exporter := kafkaexporter.New(p)
+
+	// action.
+	err := exporter.Publish(ctx, e)
+
+	// assert.
+	require.NoError(t, err)
+	require.Len(t, p.messages, 1)
+	m := p.messages[0]
+	assert.Equal(t, kafkaexporter.DefaultTopic, m.Topic)
+	assert.Equal(t, []byte("/abc"), m.Key)
+	assert.Equal(t, "application/x-protobuf", m.Headers["content-type"])
+	assert.Equal(t, "EVENT_TYPE_PUT", m.Headers["event-type"])
+	assert.Equal(t, e.ID, m.Headers["event-id"])
+	got, err := events.Unmarshal(m.Value)
+	require.NoError(t, err)
+	assert.Equal(t, e, got)
+}
+
+// TestExporter_Publish_JSON tests that events can be published as JSON to
+// the configured topic.
+func TestExporter_Publish_JSON(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	p := &producer{}
+	exporter := kafkaexporter.New(p,
+		kafkaexporter.WithTopic("audit"),
+		kafkaexporter.WithFormat(kafkaexporter.FormatJSON))
+
+	// action.
+	err := exporter.Publish(ctx, events.New(events.TypeCleared, nil, nil))
+
+	// assert.
+	require.NoError(t, err)
+	require.Len(t, p.messages, 1)
+	m := p.messages[0]
+	assert.Equal(t, "audit", m.Topic)
+	assert.Nil(t, m.Key)
+	assert.Equal(t, "application/json", m.Headers["content-type"])
+	var got events.Event
+	require.NoError(t, json.Unmarshal(m.Value, &got))
+	assert.Equal(t, events.TypeCleared, got.Type)
+}
+
+// TestExporter_Publish_Error tests that producer failures are returned.
+func TestExporter_Publish_Error(t *testing.T) {
+	// arrange.
+	p := &producer{err: errors.New("whoa")}
+	exporter := kafkaexporter.New(p)
+
+	// action.
+	err := exporter.Publish(context.Background(), events.New(events.TypeCleared, nil, nil))
+
+	// assert.
+	assert.Equal(t, p.err, err)
+}
+
+func mustParse(str string) *url.URL {
+	u, err := url.Parse(str)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
@@ -0,0 +1,94 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"context"
+	"net/url"
+)
+
+// LegacyStore represents a store written against the original form of the
+// Store interface, whose operations don't accept a context.
+type LegacyStore interface {
+	Put(obscured, original *url.URL) error
+	Get(*url.URL) (*url.URL, bool)
+	Remove(*url.URL) error
+	Clear() error
+	Size() int
+	Load(map[*url.URL]*url.URL) error
+}
+
+// legacyStore adapts a LegacyStore to the Store interface.
+type legacyStore struct {
+	store LegacyStore
+}
+
+// FromLegacy adapts the provided legacy store to the Store interface. As the
+// legacy store cannot observe the context, operations are not started once
+// the context is done, failing with the error of the context instead.
+func FromLegacy(s LegacyStore) Store {
+	return &legacyStore{store: s}
+}
+
+// Put places the mapping into the legacy store.
+func (s *legacyStore) Put(ctx context.Context, obscured, original *url.URL) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.store.Put(obscured, original)
+}
+
+// Get retrieves the original form of the provided obscured URL from the
+// legacy store.
+func (s *legacyStore) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	original, ok := s.store.Get(obscured)
+	return original, ok, nil
+}
+
+// Remove deletes the entry from the legacy store.
+func (s *legacyStore) Remove(ctx context.Context, obscured *url.URL) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.store.Remove(obscured)
+}
+
+// Clear removes all entries from the legacy store.
+func (s *legacyStore) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.store.Clear()
+}
+
+// Size computes the size of the legacy store.
+func (s *legacyStore) Size(ctx context.Context) int {
+	if ctx.Err() != nil {
+		return 0
+	}
+	return s.store.Size()
+}
+
+// Load loads the legacy store.
+func (s *legacyStore) Load(ctx context.Context, mappings Mappings) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.store.Load(mappings.Pointers())
+}
@@ -0,0 +1,101 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// legacyStore is a store without context parameters.
+type legacyStore struct {
+	mappings map[string]*url.URL
+}
+
+func (s *legacyStore) Put(obscured, original *url.URL) error {
+	s.mappings[obscured.Path] = original
+	return nil
+}
+
+func (s *legacyStore) Get(obscured *url.URL) (*url.URL, bool) {
+	original, ok := s.mappings[obscured.Path]
+	return original, ok
+}
+
+func (s *legacyStore) Remove(obscured *url.URL) error {
+	delete(s.mappings, obscured.Path)
+	return nil
+}
+
+func (s *legacyStore) Clear() error {
+	s.mappings = make(map[string]*url.URL)
+	return nil
+}
+
+func (s *legacyStore) Size() int {
+	return len(s.mappings)
+}
+
+func (s *legacyStore) Load(mappings map[*url.URL]*url.URL) error {
+	for obscured, original := range mappings {
+		s.mappings[obscured.Path] = original
+	}
+	return nil
+}
+
+// TestFromLegacy tests that legacy stores are adapted to the Store
+// interface.
+func TestFromLegacy(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	store := obscurer.FromLegacy(&legacyStore{mappings: make(map[string]*url.URL)})
+	obscured, original := mustParse("/abc"), mustParse("/this/is/the/way")
+
+	// action + assert.
+	require.NoError(t, store.Put(ctx, obscured, original))
+	got, ok, err := store.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, original, got)
+	assert.Equal(t, 1, store.Size(ctx))
+	require.NoError(t, store.Remove(ctx, obscured))
+	assert.Equal(t, 0, store.Size(ctx))
+}
+
+// TestFromLegacy_ContextDone tests that operations aren't started once the
+// context is done.
+func TestFromLegacy_ContextDone(t *testing.T) {
+	// arrange.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	legacy := &legacyStore{mappings: make(map[string]*url.URL)}
+	store := obscurer.FromLegacy(legacy)
+
+	// action.
+	err := store.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way"))
+
+	// assert.
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, legacy.Size())
+	_, ok, err := store.Get(ctx, mustParse("/abc"))
+	assert.Equal(t, context.Canceled, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,93 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// ErrTooLong represents an error that occurs when a URL exceeds a
+// configured length limit.
+var ErrTooLong = errors.New("obscurer: URL exceeds the length limit")
+
+// LengthError describes a URL exceeding a configured length limit.
+// LengthError matches ErrTooLong when using errors.Is.
+type LengthError struct {
+	// URL is the URL exceeding the limit.
+	URL *url.URL
+	// Limit names the limit exceeded, either "path" or "token".
+	Limit string
+	// Length is the length of the URL, in bytes.
+	Length int
+	// Max is the maximum length allowed by the limit.
+	Max int
+}
+
+// Error describes the limit exceeded.
+func (e *LengthError) Error() string {
+	return fmt.Sprintf(
+		"%s: %s of %q is %d bytes long, beyond %d",
+		ErrTooLong.Error(), e.Limit, e.URL, e.Length, e.Max)
+}
+
+// Is indicates if the provided error is ErrTooLong.
+func (e *LengthError) Is(err error) bool {
+	return err == ErrTooLong
+}
+
+// Limits represents the maximum lengths of the URLs that are obscured, such
+// that URLs some proxies or browsers would truncate silently are rejected
+// instead. Lengths are those of escaped paths, in bytes, and zero leaves a
+// length unlimited.
+type Limits struct {
+	// MaxPathLength is the maximum length of the path of original URLs.
+	MaxPathLength int
+	// MaxTokenLength is the maximum length of the path of obscured URLs.
+	MaxTokenLength int
+}
+
+// Check checks the provided mapping against the limits, returning a
+// *LengthError for the first limit exceeded.
+func (l Limits) Check(obscured, original *url.URL) error {
+	if length := len(original.EscapedPath()); l.MaxPathLength > 0 && length > l.MaxPathLength {
+		return &LengthError{URL: original, Limit: "path", Length: length, Max: l.MaxPathLength}
+	}
+	if length := len(obscured.EscapedPath()); l.MaxTokenLength > 0 && length > l.MaxTokenLength {
+		return &LengthError{URL: obscured, Limit: "token", Length: length, Max: l.MaxTokenLength}
+	}
+	return nil
+}
+
+// Obscure obscures the provided URL with the provided obscurer, returning a
+// *LengthError rather than the obscured URL when either exceeds the limits.
+func (l Limits) Obscure(o Obscurer, u *url.URL) (*url.URL, error) {
+	obscured := o.Obscure(u)
+	if err := l.Check(obscured, u); err != nil {
+		return nil, err
+	}
+	return obscured, nil
+}
+
+// WithLimits configures the handler to reject URLs exceeding the provided
+// limits, failing to obscure the headers carrying them rather than placing
+// their mappings into the store.
+func WithLimits(limits Limits) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Limits = limits
+	}
+}
@@ -0,0 +1,93 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLimits_Obscure tests that URLs exceeding the limits fail to be
+// obscured.
+func TestLimits_Obscure(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits obscurer.Limits
+		url    string
+		limit  string
+	}{
+		{name: "Unlimited", url: "/" + strings.Repeat("a", 4096)},
+		{name: "WithinLimits", limits: obscurer.Limits{MaxPathLength: 16, MaxTokenLength: 33}, url: "/this/is/the/way"},
+		{name: "PathTooLong", limits: obscurer.Limits{MaxPathLength: 15}, url: "/this/is/the/way", limit: "path"},
+		{name: "TokenTooLong", limits: obscurer.Limits{MaxTokenLength: 32}, url: "/this/is/the/way", limit: "token"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// action.
+			obscured, err := test.limits.Obscure(obscurer.Default, mustParse(test.url))
+
+			// assert.
+			if test.limit == "" {
+				require.NoError(t, err)
+				assert.Equal(t, obscurer.Default.Obscure(mustParse(test.url)).String(), obscured.String())
+				return
+			}
+			assert.Nil(t, obscured)
+			assert.True(t, errors.Is(err, obscurer.ErrTooLong))
+			var length *obscurer.LengthError
+			require.True(t, errors.As(err, &length))
+			assert.Equal(t, test.limit, length.Limit)
+		})
+	}
+}
+
+// TestHandler_Limits tests that headers carrying URLs exceeding the limits
+// fail to be obscured, without their mappings being placed into the store.
+func TestHandler_Limits(t *testing.T) {
+	// arrange.
+	assert := assert.New(t)
+	require := require.New(t)
+	s := obscurer.NewMemoryStore()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/"+strings.Repeat("a", 100))
+		w.WriteHeader(http.StatusCreated)
+	})
+	handler := obscurer.NewHandler(
+		obscurer.Default, s, mux, obscurer.WithLimits(obscurer.Limits{MaxPathLength: 64}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// action.
+	response, err := http.Post(server.URL+"/", "text/plain", nil)
+
+	// assert.
+	require.NoError(err)
+	assert.Equal(http.StatusInternalServerError, response.StatusCode)
+	body, err := ioutil.ReadAll(response.Body)
+	require.NoError(err)
+	assert.Equal(obscurer.ErrLocationHeaderFailure.Error()+"\n", string(body))
+	assert.Equal(0, s.Size(context.Background()))
+}
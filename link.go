@@ -0,0 +1,289 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrMalformedLink represents an error that occurs when a Link header
+// doesn't conform to RFC 8288.
+var ErrMalformedLink = errors.New("obscurer: malformed Link header")
+
+// LinkParam represents a parameter of a link, such as its relation type.
+type LinkParam struct {
+	// Name is the name of the parameter, such as 'rel'.
+	Name string
+	// Value is the value of the parameter, unquoted. It is empty for
+	// parameters without a value.
+	Value string
+}
+
+// Link represents a link-value of a Link header, as defined by RFC 8288.
+type Link struct {
+	// Target is the target URI-reference of the link.
+	Target string
+	// Params are the parameters of the link, in the order they appear.
+	Params []LinkParam
+
+	// start and end are the offsets of the target within the header.
+	start, end int
+}
+
+// Param provides the value of the first parameter of the link with the
+// provided name, which is matched case-insensitively.
+func (l Link) Param(name string) (string, bool) {
+	for _, p := range l.Params {
+		if strings.EqualFold(p.Name, name) {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// String formats the link as a link-value, quoting the values of its
+// parameters when they aren't tokens.
+func (l Link) String() string {
+	var b strings.Builder
+	b.WriteString("<" + l.Target + ">")
+	for _, p := range l.Params {
+		b.WriteString("; " + p.Name)
+		if p.Value == "" {
+			continue
+		}
+		b.WriteByte('=')
+		if isToken(p.Value) {
+			b.WriteString(p.Value)
+			continue
+		}
+		b.WriteByte('"')
+		for _, c := range []byte(p.Value) {
+			if c == '"' || c == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(c)
+		}
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+// ParseLinks parses the link-values of the provided Link header value, as
+// defined by RFC 8288, including quoted parameter values containing
+// characters such as '>', ';', or ','. Unquoted parameter values that
+// aren't tokens, such as media types, are tolerated. An error matching
+// ErrMalformedLink is returned when the value doesn't conform.
+func ParseLinks(header string) ([]Link, error) {
+	p := linkParser{header: header}
+	var links []Link
+	for {
+		// empty list elements are permitted.
+		p.skip(" \t,")
+		if p.done() {
+			return links, nil
+		}
+		link, err := p.link()
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+		p.skip(" \t")
+		if !p.done() && !p.consume(',') {
+			return nil, p.malformed("expected ',' after link")
+		}
+	}
+}
+
+// rewriteLinks replaces the target of each link-value of the provided Link
+// header value with the result of the provided function, leaving the rest
+// of the value as is.
+func rewriteLinks(header string, replace func(string) (string, error)) (string, error) {
+	links, err := ParseLinks(header)
+	if err != nil {
+		return "", err
+	}
+	var (
+		b    strings.Builder
+		last int
+	)
+	for _, link := range links {
+		target, err := replace(link.Target)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(header[last:link.start])
+		b.WriteString(target)
+		last = link.end
+	}
+	b.WriteString(header[last:])
+	return b.String(), nil
+}
+
+// linkParser parses the link-values of a Link header value.
+type linkParser struct {
+	header string
+	pos    int
+}
+
+// malformed constructs an error describing the provided problem at the
+// current position.
+func (p *linkParser) malformed(problem string) error {
+	return &linkError{problem: problem, pos: p.pos}
+}
+
+// done indicates whether the entire value has been parsed.
+func (p *linkParser) done() bool {
+	return p.pos >= len(p.header)
+}
+
+// skip skips the provided characters.
+func (p *linkParser) skip(chars string) {
+	for !p.done() && strings.IndexByte(chars, p.header[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// consume consumes the provided character when it is next.
+func (p *linkParser) consume(c byte) bool {
+	if p.done() || p.header[p.pos] != c {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+// link parses a link-value.
+func (p *linkParser) link() (Link, error) {
+	if !p.consume('<') {
+		return Link{}, p.malformed("expected '<'")
+	}
+	end := strings.IndexByte(p.header[p.pos:], '>')
+	if end < 0 {
+		return Link{}, p.malformed("unterminated target")
+	}
+	link := Link{start: p.pos, end: p.pos + end}
+	link.Target = p.header[link.start:link.end]
+	p.pos = link.end + 1
+	for {
+		p.skip(" \t")
+		if !p.consume(';') {
+			return link, nil
+		}
+		p.skip(" \t")
+		if p.done() || p.header[p.pos] == ',' {
+			// a trailing ';' is tolerated.
+			return link, nil
+		}
+		param, err := p.param()
+		if err != nil {
+			return Link{}, err
+		}
+		link.Params = append(link.Params, param)
+	}
+}
+
+// param parses a link-param.
+func (p *linkParser) param() (LinkParam, error) {
+	name := p.token()
+	if name == "" {
+		return LinkParam{}, p.malformed("expected parameter name")
+	}
+	param := LinkParam{Name: name}
+	p.skip(" \t")
+	if !p.consume('=') {
+		return param, nil
+	}
+	p.skip(" \t")
+	if !p.consume('"') {
+		// unquoted values are taken up to the next delimiter, as in
+		// appendix B.3 of RFC 8288, tolerating values such as media types.
+		start := p.pos
+		for !p.done() && strings.IndexByte(";, \t", p.header[p.pos]) < 0 {
+			p.pos++
+		}
+		if param.Value = p.header[start:p.pos]; param.Value == "" {
+			return LinkParam{}, p.malformed("expected parameter value")
+		}
+		return param, nil
+	}
+	var b strings.Builder
+	for !p.done() {
+		c := p.header[p.pos]
+		p.pos++
+		switch {
+		case c == '"':
+			param.Value = b.String()
+			return param, nil
+		case c == '\\' && !p.done():
+			b.WriteByte(p.header[p.pos])
+			p.pos++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return LinkParam{}, p.malformed("unterminated quoted string")
+}
+
+// token parses a token, which is empty when there is none.
+func (p *linkParser) token() string {
+	start := p.pos
+	for !p.done() && isTokenChar(p.header[p.pos]) {
+		p.pos++
+	}
+	return p.header[start:p.pos]
+}
+
+// linkError describes a Link header that doesn't conform to RFC 8288.
+// linkError matches ErrMalformedLink when using errors.Is.
+type linkError struct {
+	problem string
+	pos     int
+}
+
+// Error describes the problem.
+func (e *linkError) Error() string {
+	return ErrMalformedLink.Error() + ": " + e.problem + " at offset " + strconv.Itoa(e.pos)
+}
+
+// Is indicates if the provided error is ErrMalformedLink.
+func (e *linkError) Is(err error) bool {
+	return err == ErrMalformedLink
+}
+
+// isToken indicates whether the provided value is a non-empty token.
+func isToken(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, c := range []byte(value) {
+		if !isTokenChar(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// isTokenChar indicates whether the provided character is a tchar, as
+// defined by RFC 7230.
+func isTokenChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
@@ -0,0 +1,136 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseLinks tests that link-values are parsed along with their
+// parameters, including quoted values containing delimiters.
+func TestParseLinks(t *testing.T) {
+	// action.
+	links, err := obscurer.ParseLinks(
+		`<http://example.com/TheBook/chapter2>; rel="previous"; title="a > b, c; d", ` +
+			`</next>;rel=next;type=text/html , <>; rel="self \"quoted\"" ; hreflang=en; crossorigin`)
+
+	// assert.
+	require.NoError(t, err)
+	require.Len(t, links, 3)
+	assert.Equal(t, "http://example.com/TheBook/chapter2", links[0].Target)
+	assert.Equal(t, []obscurer.LinkParam{
+		{Name: "rel", Value: "previous"},
+		{Name: "title", Value: "a > b, c; d"},
+	}, links[0].Params)
+	assert.Equal(t, "/next", links[1].Target)
+	rel, ok := links[1].Param("REL")
+	assert.True(t, ok)
+	assert.Equal(t, "next", rel)
+	value, ok := links[1].Param("type")
+	assert.True(t, ok)
+	assert.Equal(t, "text/html", value)
+	assert.Equal(t, "", links[2].Target)
+	assert.Equal(t, []obscurer.LinkParam{
+		{Name: "rel", Value: `self "quoted"`},
+		{Name: "hreflang", Value: "en"},
+		{Name: "crossorigin"},
+	}, links[2].Params)
+}
+
+// TestParseLinks_Malformed tests that values not conforming to RFC 8288
+// result in an error.
+func TestParseLinks_Malformed(t *testing.T) {
+	tests := []string{
+		"no url here",
+		"</a",
+		`</a>; title="unterminated`,
+		"</a> </b>",
+		"</a>; =value",
+		"</a>; rel=",
+	}
+	for _, test := range tests {
+		t.Run(test, func(t *testing.T) {
+			// action.
+			_, err := obscurer.ParseLinks(test)
+
+			// assert.
+			assert.True(t, errors.Is(err, obscurer.ErrMalformedLink), "expected %q to be malformed, got %v", test, err)
+		})
+	}
+}
+
+// TestLink_String tests that links are formatted as link-values, quoting
+// parameter values that aren't tokens.
+func TestLink_String(t *testing.T) {
+	// arrange.
+	link := obscurer.Link{
+		Target: "/next",
+		Params: []obscurer.LinkParam{
+			{Name: "rel", Value: "next"},
+			{Name: "title", Value: `say "hi", > bye`},
+			{Name: "crossorigin"},
+		},
+	}
+
+	// action.
+	formatted := link.String()
+
+	// assert.
+	assert.Equal(t, `</next>; rel=next; title="say \"hi\", > bye"; crossorigin`, formatted)
+	parsed, err := obscurer.ParseLinks(formatted)
+	require.NoError(t, err)
+	require.Len(t, parsed, 1)
+	assert.Equal(t, link.Target, parsed[0].Target)
+	assert.Equal(t, link.Params, parsed[0].Params)
+}
+
+// TestHandler_LinkHeader_RoundTrip tests that the 'Link' header is left as
+// is after obscuring, besides the targets of its links.
+func TestHandler_LinkHeader_RoundTrip(t *testing.T) {
+	// arrange.
+	assert := assert.New(t)
+	require := require.New(t)
+	t.Cleanup(func() { obscurer.DefaultStore.Clear(context.Background()) })
+	header := `</orders?page=3> ;rel="next";  title="page 3, of > 2", </orders?page=1>; rel=first`
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orders", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", header)
+	})
+	handler := obscurer.NewHandler(obscurer.Default, obscurer.DefaultStore, mux)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	next := obscurer.Default.Obscure(mustParse("/orders?page=3"))
+	first := obscurer.Default.Obscure(mustParse("/orders?page=1"))
+
+	// action.
+	response, err := http.Get(fmt.Sprintf("%s/orders", server.URL))
+
+	// assert.
+	require.NoError(err)
+	assert.Equal(http.StatusOK, response.StatusCode)
+	assert.Equal(
+		fmt.Sprintf(`<%s> ;rel="next";  title="page 3, of > 2", <%s>; rel=first`, next, first),
+		response.Header.Get("Link"))
+}
@@ -0,0 +1,133 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"context"
+	"net/url"
+)
+
+// Mapping represents the mapping between an obscured URL and it's original
+// form.
+type Mapping struct {
+	// Obscured is the obscured URL.
+	Obscured *url.URL
+	// Original is the original form of the obscured URL.
+	Original *url.URL
+}
+
+// Overwrite represents how mappings sharing an obscured URL, but differing
+// in their original form, are resolved when loaded in order.
+type Overwrite int
+
+const (
+	// LastWins keeps the last of the mappings sharing an obscured URL.
+	LastWins Overwrite = iota
+	// FirstWins keeps the first of the mappings sharing an obscured URL.
+	FirstWins
+	// ErrorOnConflict fails with a *CollisionError, loading none of the
+	// mappings.
+	ErrorOnConflict
+)
+
+// LoadOptions represents the configuration options for loading mappings in
+// order.
+type LoadOptions struct {
+	// Overwrite is how mappings sharing an obscured URL are resolved.
+	Overwrite Overwrite
+	// KeyStrategy is how obscured URLs are compared, which should match
+	// the key strategy of the store.
+	KeyStrategy KeyStrategy
+}
+
+// LoadOption applies an option to the provided configuration.
+type LoadOption func(*LoadOptions)
+
+// WithOverwrite configures how mappings sharing an obscured URL are
+// resolved.
+func WithOverwrite(overwrite Overwrite) LoadOption {
+	return func(o *LoadOptions) {
+		o.Overwrite = overwrite
+	}
+}
+
+// WithLoadKeyStrategy configures how obscured URLs are compared.
+func WithLoadKeyStrategy(k KeyStrategy) LoadOption {
+	return func(o *LoadOptions) {
+		o.KeyStrategy = k
+	}
+}
+
+// LoadOrdered loads the provided mappings into the provided store, as
+// Store.Load does, resolving mappings that share an obscured URL in the
+// order provided, such that bulk seeding is reproducible. Mappings already
+// in the store precede the provided ones, so FirstWins keeps them, LastWins
+// replaces them, and ErrorOnConflict fails on them. Mappings sharing both
+// their obscured URL and the path of their original form never conflict.
+// By default the last of the conflicting mappings is kept.
+func LoadOrdered(ctx context.Context, s Store, mappings []Mapping, opts ...LoadOption) error {
+	var options LoadOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	kept := make(map[string]Mapping, len(mappings))
+	for _, m := range mappings {
+		key := options.KeyStrategy.Key(m.Obscured)
+		existing, ok := kept[key]
+		if ok && existing.Original.Path != m.Original.Path {
+			switch options.Overwrite {
+			case FirstWins:
+				continue
+			case ErrorOnConflict:
+				return &CollisionError{
+					Obscured: m.Obscured,
+					Existing: existing.Original,
+					Original: m.Original,
+				}
+			}
+		}
+		kept[key] = m
+	}
+	var replaced []*url.URL
+	loaded := Mappings{KeyStrategy: options.KeyStrategy, Entries: make(map[string]*url.URL, len(kept))}
+	for key, m := range kept {
+		existing, ok, err := s.Get(ctx, m.Obscured)
+		if err != nil {
+			return err
+		}
+		if ok && existing.Path != m.Original.Path {
+			switch options.Overwrite {
+			case FirstWins:
+				continue
+			case ErrorOnConflict:
+				return &CollisionError{
+					Obscured: m.Obscured,
+					Existing: existing,
+					Original: m.Original,
+				}
+			default:
+				replaced = append(replaced, m.Obscured)
+			}
+		}
+		loaded.Entries[key] = m.Original
+	}
+	if len(replaced) > 0 {
+		if err := RemoveAll(ctx, s, replaced); err != nil {
+			return err
+		}
+	}
+	return s.Load(ctx, loaded)
+}
@@ -0,0 +1,121 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLoadOrdered tests that mappings sharing an obscured URL are resolved
+// in the order provided.
+func TestLoadOrdered(t *testing.T) {
+	mappings := []obscurer.Mapping{
+		{Obscured: mustParse("/abc"), Original: mustParse("/this/is/the/way")},
+		{Obscured: mustParse("/def"), Original: mustParse("/products/1")},
+		{Obscured: mustParse("/abc?page=2"), Original: mustParse("/hey/der")},
+	}
+	tests := []struct {
+		name     string
+		opts     []obscurer.LoadOption
+		keys     obscurer.KeyStrategy
+		expected string
+		size     int
+		err      error
+	}{
+		{name: "LastWins", expected: "/hey/der", size: 2},
+		{name: "FirstWins", opts: []obscurer.LoadOption{obscurer.WithOverwrite(obscurer.FirstWins)}, expected: "/this/is/the/way", size: 2},
+		{name: "ErrorOnConflict", opts: []obscurer.LoadOption{obscurer.WithOverwrite(obscurer.ErrorOnConflict)}, err: obscurer.ErrCollision},
+		{
+			name:     "RequestURI",
+			opts:     []obscurer.LoadOption{obscurer.WithOverwrite(obscurer.ErrorOnConflict), obscurer.WithLoadKeyStrategy(obscurer.KeyRequestURI)},
+			keys:     obscurer.KeyRequestURI,
+			expected: "/this/is/the/way",
+			size:     3,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			ctx := context.Background()
+			store := obscurer.NewMemoryStore(obscurer.WithKeyStrategy(test.keys))
+
+			// action.
+			err := obscurer.LoadOrdered(ctx, store, mappings, test.opts...)
+
+			// assert.
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+				assert.Equal(t, 0, store.Size(ctx))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.size, store.Size(ctx))
+			got, ok, err := store.Get(ctx, mustParse("/abc"))
+			require.NoError(t, err)
+			require.True(t, ok)
+			assert.Equal(t, test.expected, got.Path)
+		})
+	}
+}
+
+// TestLoadOrdered_Existing tests that mappings already in the store are
+// resolved as preceding the provided mappings.
+func TestLoadOrdered_Existing(t *testing.T) {
+	mappings := []obscurer.Mapping{
+		{Obscured: mustParse("/abc"), Original: mustParse("/hey/der")},
+		{Obscured: mustParse("/def"), Original: mustParse("/products/1")},
+	}
+	tests := []struct {
+		name     string
+		opts     []obscurer.LoadOption
+		expected string
+		size     int
+		err      error
+	}{
+		{name: "LastWins", expected: "/hey/der", size: 2},
+		{name: "FirstWins", opts: []obscurer.LoadOption{obscurer.WithOverwrite(obscurer.FirstWins)}, expected: "/this/is/the/way", size: 2},
+		{name: "ErrorOnConflict", opts: []obscurer.LoadOption{obscurer.WithOverwrite(obscurer.ErrorOnConflict)}, expected: "/this/is/the/way", size: 1, err: obscurer.ErrCollision},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			ctx := context.Background()
+			store := obscurer.NewMemoryStore()
+			require.NoError(t, store.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+
+			// action.
+			err := obscurer.LoadOrdered(ctx, store, mappings, test.opts...)
+
+			// assert.
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, test.size, store.Size(ctx))
+			got, ok, err := store.Get(ctx, mustParse("/abc"))
+			require.NoError(t, err)
+			require.True(t, ok)
+			assert.Equal(t, test.expected, got.Path)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"log"
+)
+
+// Logger represents a leveled logger, to which the handler logs the issues
+// it otherwise recovers from silently, such as lookups missing the store,
+// mappings failing to be removed, and headers failing to be obscured.
+type Logger interface {
+	// Debugf logs the provided diagnostic message, formatted as with
+	// fmt.Printf.
+	Debugf(format string, args ...interface{})
+	// Warnf logs the provided message describing an issue, formatted as
+	// with fmt.Printf.
+	Warnf(format string, args ...interface{})
+}
+
+// WithLogger configures the handler to log to the provided logger.
+func WithLogger(logger Logger) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Logger = logger
+	}
+}
+
+// stdLogger logs to a *log.Logger, prefixing messages with their level.
+type stdLogger struct {
+	logger *log.Logger
+	debug  bool
+}
+
+// NewStdLogger constructs a logger logging to the provided *log.Logger.
+// Debug messages are discarded unless debug is true.
+func NewStdLogger(logger *log.Logger, debug bool) Logger {
+	return &stdLogger{logger: logger, debug: debug}
+}
+
+// Debugf logs the provided diagnostic message when debugging.
+func (l *stdLogger) Debugf(format string, args ...interface{}) {
+	if l.debug {
+		l.logger.Printf("DEBUG "+format, args...)
+	}
+}
+
+// Warnf logs the provided message describing an issue.
+func (l *stdLogger) Warnf(format string, args ...interface{}) {
+	l.logger.Printf("WARN "+format, args...)
+}
+
+// nopLogger discards every message.
+type nopLogger struct{}
+
+// Debugf discards the provided message.
+func (nopLogger) Debugf(format string, args ...interface{}) {}
+
+// Warnf discards the provided message.
+func (nopLogger) Warnf(format string, args ...interface{}) {}
+
+// logger provides the logger of the handler, which discards every message
+// when none is configured.
+func (h *handler) logger() Logger {
+	if h.options.Logger == nil {
+		return nopLogger{}
+	}
+	return h.options.Logger
+}
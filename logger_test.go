@@ -0,0 +1,189 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/internal/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// logger is an in-memory logger.
+type logger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *logger) Debugf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, "debug: "+fmt.Sprintf(format, args...))
+}
+
+func (l *logger) Warnf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, "warn: "+fmt.Sprintf(format, args...))
+}
+
+// TestWithLogger tests that the issues the handler recovers from are
+// logged.
+func TestWithLogger(t *testing.T) {
+	original := mustParse("/this/is/the/way")
+	obscured := obscurer.Default.Obscure(original)
+	whoa := errors.New("whoa")
+	tests := []struct {
+		name     string
+		target   string
+		body     string
+		opts     []obscurer.HandlerOption
+		expect   func(s *mock.Store)
+		handler  http.HandlerFunc
+		messages []string
+	}{
+		{
+			name:   "Miss",
+			target: "/hey/der",
+			expect: func(s *mock.Store) {
+				s.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, false, nil)
+			},
+			handler:  func(w http.ResponseWriter, r *http.Request) {},
+			messages: []string{"debug: obscurer: no mapping for /hey/der"},
+		},
+		{
+			name:   "LookupError",
+			target: obscured.String(),
+			expect: func(s *mock.Store) {
+				s.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, false, whoa)
+			},
+			handler:  func(w http.ResponseWriter, r *http.Request) {},
+			messages: []string{fmt.Sprintf("warn: obscurer: failed to look up %s: whoa", obscured)},
+		},
+		{
+			name:   "RemovalError",
+			target: obscured.String(),
+			expect: func(s *mock.Store) {
+				s.EXPECT().Get(gomock.Any(), gomock.Any()).Return(original, true, nil)
+				s.EXPECT().Remove(gomock.Any(), obscured).Return(whoa)
+			},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			messages: []string{fmt.Sprintf("warn: obscurer: failed to remove the mapping of %s: whoa", obscured)},
+		},
+		{
+			name:   "HeaderError",
+			target: obscured.String(),
+			expect: func(s *mock.Store) {
+				s.EXPECT().Get(gomock.Any(), gomock.Any()).Return(original, true, nil)
+			},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Location", "%zz")
+			},
+			messages: []string{`warn: obscurer: failed to obscure the Location header: parse "%zz": invalid URL escape "%zz"`},
+		},
+		{
+			name:   "MalformedLink",
+			target: obscured.String(),
+			expect: func(s *mock.Store) {
+				s.EXPECT().Get(gomock.Any(), gomock.Any()).Return(original, true, nil)
+			},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Link", "no url here")
+			},
+			messages: []string{`warn: obscurer: left the Link header "no url here" as is: obscurer: malformed Link header: expected '<' at offset 0`},
+		},
+		{
+			name:   "MalformedResponseBody",
+			target: obscured.String(),
+			opts:   []obscurer.HandlerOption{obscurer.WithLinkFields("href")},
+			expect: func(s *mock.Store) {
+				s.EXPECT().Get(gomock.Any(), gomock.Any()).Return(original, true, nil)
+			},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"href":`))
+			},
+			messages: []string{"warn: obscurer: left the response body as is, as it isn't valid JSON: EOF"},
+		},
+		{
+			name:   "MalformedRequestBody",
+			target: obscured.String(),
+			body:   `{"parent":`,
+			opts:   []obscurer.HandlerOption{obscurer.WithRequestBodyResolution()},
+			expect: func(s *mock.Store) {
+				s.EXPECT().Get(gomock.Any(), gomock.Any()).Return(original, true, nil)
+			},
+			handler:  func(w http.ResponseWriter, r *http.Request) {},
+			messages: []string{"debug: obscurer: left the body of /this/is/the/way as is, as it isn't valid JSON: EOF"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mock.NewStore(ctrl)
+			test.expect(store)
+			l := &logger{}
+			opts := append([]obscurer.HandlerOption{obscurer.WithLogger(l)}, test.opts...)
+			handler := obscurer.NewHandler(obscurer.Default, store, test.handler, opts...)
+			request := httptest.NewRequest(http.MethodGet, test.target, nil)
+			if test.body != "" {
+				request = httptest.NewRequest(http.MethodPost, test.target, strings.NewReader(test.body))
+				request.Header.Set("Content-Type", "application/json")
+			}
+
+			// action.
+			handler.ServeHTTP(httptest.NewRecorder(), request)
+
+			// assert.
+			assert.Equal(t, test.messages, l.messages)
+		})
+	}
+}
+
+// TestNewStdLogger tests that messages are prefixed with their level, and
+// that debug messages are discarded unless debugging.
+func TestNewStdLogger(t *testing.T) {
+	// arrange.
+	var quiet, verbose bytes.Buffer
+	loggers := []obscurer.Logger{
+		obscurer.NewStdLogger(log.New(&quiet, "", 0), false),
+		obscurer.NewStdLogger(log.New(&verbose, "", 0), true),
+	}
+
+	// action.
+	for _, l := range loggers {
+		l.Debugf("hey %s", "der")
+		l.Warnf("whoa %d", 1)
+	}
+
+	// assert.
+	assert.Equal(t, "WARN whoa 1\n", quiet.String())
+	assert.Equal(t, "DEBUG hey der\nWARN whoa 1\n", verbose.String())
+}
@@ -0,0 +1,103 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"context"
+	"net/url"
+)
+
+// Mappings represents mappings keyed by the key their obscured URL has in a
+// store, rather than by pointer, such that obscured URLs sharing a mapping
+// in the store share an entry.
+type Mappings struct {
+	// KeyStrategy is the strategy the keys of the entries are derived with
+	// from their obscured URLs, which should match the key strategy of the
+	// store the mappings are loaded into.
+	KeyStrategy KeyStrategy
+	// Entries are the original URLs, keyed by the key of their obscured
+	// URL.
+	Entries map[string]*url.URL
+}
+
+// NewMappings converts the provided mappings keyed by pointer, such as
+// those provided by ObscureAll, into mappings keyed by the provided key
+// strategy. A *CollisionError is returned when obscured URLs sharing a key
+// map to URLs with different paths.
+func NewMappings(k KeyStrategy, mappings map[*url.URL]*url.URL) (Mappings, error) {
+	m := Mappings{KeyStrategy: k, Entries: make(map[string]*url.URL, len(mappings))}
+	for obscured, original := range mappings {
+		key := k.Key(obscured)
+		if existing, ok := m.Entries[key]; ok && existing.Path != original.Path {
+			return Mappings{}, &CollisionError{Obscured: obscured, Existing: existing, Original: original}
+		}
+		m.Entries[key] = original
+	}
+	return m, nil
+}
+
+// Add adds the mapping between the provided obscured URL and it's original
+// form, replacing any mapping for an obscured URL sharing its key.
+func (m *Mappings) Add(obscured, original *url.URL) {
+	if m.Entries == nil {
+		m.Entries = make(map[string]*url.URL)
+	}
+	m.Entries[m.KeyStrategy.Key(obscured)] = original
+}
+
+// Len provides the number of mappings.
+func (m Mappings) Len() int {
+	return len(m.Entries)
+}
+
+// Range invokes the provided function for each of the mappings, with the
+// obscured URL derived from its key, until the function returns false.
+func (m Mappings) Range(fn func(obscured, original *url.URL) bool) {
+	for key, original := range m.Entries {
+		if !fn(m.KeyStrategy.URL(key), original) {
+			return
+		}
+	}
+}
+
+// Pointers converts the mappings into mappings keyed by pointer, as accepted
+// by PutAll.
+func (m Mappings) Pointers() map[*url.URL]*url.URL {
+	mappings := make(map[*url.URL]*url.URL, len(m.Entries))
+	m.Range(func(obscured, original *url.URL) bool {
+		mappings[obscured] = original
+		return true
+	})
+	return mappings
+}
+
+// LoadAll loads the provided mappings keyed by pointer into the provided
+// store, as Store.Load does, adapting callers of Store.Load from before it
+// accepted Mappings. The mappings are keyed by request URI, retaining their
+// queries for stores that key by them.
+func LoadAll(ctx context.Context, s Store, mappings map[*url.URL]*url.URL) error {
+	m, err := NewMappings(KeyRequestURI, mappings)
+	if err != nil {
+		return err
+	}
+	return s.Load(ctx, m)
+}
+
+// PutMappings places the provided mappings into the provided store, as
+// PutAll does.
+func PutMappings(ctx context.Context, s Store, m Mappings) error {
+	return PutAll(ctx, s, m.Pointers())
+}
@@ -0,0 +1,125 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNewMappings tests that distinct pointers to obscured URLs sharing a
+// key share an entry.
+func TestNewMappings(t *testing.T) {
+	// action.
+	m, err := obscurer.NewMappings(obscurer.KeyPath, map[*url.URL]*url.URL{
+		mustParse("/abc"):        mustParse("/this/is/the/way"),
+		mustParse("/abc?page=2"): mustParse("/this/is/the/way"),
+		mustParse("/def"):        mustParse("/hey/der"),
+	})
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 2, m.Len())
+	assert.Equal(t, "/this/is/the/way", m.Entries["/abc"].String())
+}
+
+// TestNewMappings_RequestURI tests that obscured URLs differing only by
+// their query have entries of their own when keyed by request URI.
+func TestNewMappings_RequestURI(t *testing.T) {
+	// action.
+	m, err := obscurer.NewMappings(obscurer.KeyRequestURI, map[*url.URL]*url.URL{
+		mustParse("/abc"):        mustParse("/this/is/the/way"),
+		mustParse("/abc?page=2"): mustParse("/hey/der"),
+	})
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 2, m.Len())
+	assert.Equal(t, "/hey/der", m.Entries["/abc?page=2"].String())
+	pointers := m.Pointers()
+	assert.Len(t, pointers, 2)
+	for obscured, original := range pointers {
+		if obscured.RawQuery != "" {
+			assert.Equal(t, "/abc?page=2", obscured.String())
+			assert.Equal(t, "/hey/der", original.String())
+		}
+	}
+}
+
+// TestNewMappings_Collision tests that obscured URLs sharing a key mapping
+// to different URLs result in a collision error.
+func TestNewMappings_Collision(t *testing.T) {
+	// action.
+	_, err := obscurer.NewMappings(obscurer.KeyPath, map[*url.URL]*url.URL{
+		mustParse("/abc"):        mustParse("/this/is/the/way"),
+		mustParse("/abc?page=2"): mustParse("/hey/der"),
+	})
+
+	// assert.
+	assert.True(t, errors.Is(err, obscurer.ErrCollision))
+}
+
+// TestMappings_Load tests that mappings keyed by value can be loaded into
+// and placed into a store.
+func TestMappings_Load(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := obscurer.NewMemoryStore()
+	var m obscurer.Mappings
+	m.Add(mustParse("/abc"), mustParse("/this/is/the/way"))
+	m.Add(mustParse("/abc?page=2"), mustParse("/hey/der"))
+	other := obscurer.Mappings{Entries: map[string]*url.URL{"/def": mustParse("/products/1")}}
+
+	// action.
+	require.NoError(t, s.Load(ctx, m))
+	require.NoError(t, obscurer.PutMappings(ctx, s, other))
+
+	// assert.
+	assert.Equal(t, 1, m.Len())
+	assert.Equal(t, 2, s.Size(ctx))
+	got, ok, err := s.Get(ctx, mustParse("/abc"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/hey/der", got.String())
+}
+
+// TestLoadAll tests that mappings keyed by pointer are loaded into a store,
+// retaining their queries for stores keying by them.
+func TestLoadAll(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := obscurer.NewMemoryStore(obscurer.WithKeyStrategy(obscurer.KeyRequestURI))
+
+	// action.
+	err := obscurer.LoadAll(ctx, s, map[*url.URL]*url.URL{
+		mustParse("/abc"):        mustParse("/this/is/the/way"),
+		mustParse("/abc?page=2"): mustParse("/hey/der"),
+	})
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.Size(ctx))
+	got, ok, err := s.Get(ctx, mustParse("/abc?page=2"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/hey/der", got.String())
+}
@@ -0,0 +1,291 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"bytes"
+	"html"
+	"net/url"
+	"strings"
+)
+
+// markupReplace provides the replacement for the provided URL held by the
+// attribute or element with the provided lowercase local name, or false to
+// leave it as is.
+type markupReplace func(name, value string) (string, bool, error)
+
+// markup describes where the URLs of HTML and XML documents are held.
+type markup struct {
+	// attributes are the names of the attributes holding URLs.
+	attributes map[string]bool
+	// elements are the names of the elements whose text holds a URL.
+	elements map[string]bool
+	// rawText are the names of the elements whose content is raw text,
+	// within which tags aren't recognized.
+	rawText map[string]bool
+}
+
+// rewrite rewrites the URLs of the provided document with the provided
+// function, leaving everything else byte for byte, including comments and
+// the content of raw text elements such as scripts.
+//
+// The document is scanned here rather than with the tokenizer of
+// golang.org/x/net/html, which doesn't expose where each attribute lies
+// within the raw bytes of a tag. Rewriting an attribute with it means
+// serializing the whole tag again, changing the quoting, case, and
+// entities of the attributes left as is, and it tokenizes XML feeds and
+// sitemaps by the rules of HTML.
+func (m markup) rewrite(doc []byte, replace markupReplace) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(doc))
+	for i := 0; i < len(doc); {
+		lt := bytes.IndexByte(doc[i:], '<')
+		if lt < 0 {
+			buf.Write(doc[i:])
+			break
+		}
+		buf.Write(doc[i : i+lt])
+		i += lt
+		rest := doc[i:]
+		switch {
+		case bytes.HasPrefix(rest, []byte("<!--")):
+			n := skipPast(rest, "-->", 4)
+			buf.Write(rest[:n])
+			i += n
+		case bytes.HasPrefix(rest, []byte("<![CDATA[")):
+			n := skipPast(rest, "]]>", 9)
+			buf.Write(rest[:n])
+			i += n
+		case len(rest) > 1 && (rest[1] == '!' || rest[1] == '?' || rest[1] == '/'):
+			n := skipPast(rest, ">", 1)
+			buf.Write(rest[:n])
+			i += n
+		case len(rest) > 1 && isASCIILetter(rest[1]):
+			n, name, err := m.tag(&buf, rest, replace)
+			if err != nil {
+				return nil, err
+			}
+			empty := bytes.HasSuffix(rest[:n], []byte("/>"))
+			i += n
+			switch {
+			case empty:
+			case m.rawText[name]:
+				n := rawTextEnd(doc[i:], name)
+				buf.Write(doc[i : i+n])
+				i += n
+			case m.elements[name]:
+				n, err := m.text(&buf, doc[i:], name, replace)
+				if err != nil {
+					return nil, err
+				}
+				i += n
+			}
+		default:
+			buf.WriteByte('<')
+			i++
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// skipPast provides the length of the prefix of the provided data ending
+// with the provided terminator, searched for from the provided offset, or
+// the length of the data when it is unterminated.
+func skipPast(data []byte, terminator string, from int) int {
+	if from > len(data) {
+		return len(data)
+	}
+	end := bytes.Index(data[from:], []byte(terminator))
+	if end < 0 {
+		return len(data)
+	}
+	return from + end + len(terminator)
+}
+
+// rawTextEnd provides the length of the content of the raw text element
+// with the provided name at the start of the provided data.
+func rawTextEnd(data []byte, name string) int {
+	closing := []byte("</" + name)
+	for i := 0; i < len(data); {
+		lt := bytes.IndexByte(data[i:], '<')
+		if lt < 0 {
+			break
+		}
+		i += lt
+		if len(data)-i >= len(closing) && bytes.EqualFold(data[i:i+len(closing)], closing) {
+			return i
+		}
+		i++
+	}
+	return len(data)
+}
+
+// localName provides the lowercase local name of the provided qualified
+// name, such that 'atom:link' is 'link'.
+func localName(name []byte) string {
+	if colon := bytes.LastIndexByte(name, ':'); colon >= 0 {
+		name = name[colon+1:]
+	}
+	return strings.ToLower(string(name))
+}
+
+// tag rewrites the start tag at the start of the provided data into the
+// provided buffer, providing its length and lowercase local name.
+func (m markup) tag(buf *bytes.Buffer, data []byte, replace markupReplace) (int, string, error) {
+	i := 1
+	for i < len(data) && !isSpace(data[i]) && data[i] != '>' && data[i] != '/' {
+		i++
+	}
+	name := localName(data[1:i])
+	buf.Write(data[:i])
+	for i < len(data) {
+		// copy the whitespace and solidus preceding the attribute.
+		start := i
+		for i < len(data) && (isSpace(data[i]) || data[i] == '/') {
+			i++
+		}
+		buf.Write(data[start:i])
+		if i >= len(data) {
+			break
+		}
+		if data[i] == '>' {
+			buf.WriteByte('>')
+			return i + 1, name, nil
+		}
+		// the name of the attribute, whose first character belongs to it
+		// even when it is '='.
+		start = i
+		i++
+		for i < len(data) && !isSpace(data[i]) && data[i] != '=' && data[i] != '>' && data[i] != '/' {
+			i++
+		}
+		attr := data[start:i]
+		// the value of the attribute, if any.
+		j := i
+		for j < len(data) && isSpace(data[j]) {
+			j++
+		}
+		if j >= len(data) || data[j] != '=' {
+			buf.Write(attr)
+			continue
+		}
+		j++
+		for j < len(data) && isSpace(data[j]) {
+			j++
+		}
+		var raw []byte
+		valueStart := j
+		if j < len(data) && (data[j] == '"' || data[j] == '\'') {
+			quote := data[j]
+			end := bytes.IndexByte(data[j+1:], quote)
+			if end < 0 {
+				buf.Write(data[start:])
+				return len(data), name, nil
+			}
+			raw = data[j+1 : j+1+end]
+			j += end + 2
+		} else {
+			for j < len(data) && !isSpace(data[j]) && data[j] != '>' {
+				j++
+			}
+			raw = data[valueStart:j]
+		}
+		key := localName(attr)
+		if !m.attributes[key] {
+			buf.Write(data[start:j])
+			i = j
+			continue
+		}
+		value, ok, err := replace(key, html.UnescapeString(string(raw)))
+		if err != nil {
+			return 0, "", err
+		}
+		if !ok {
+			buf.Write(data[start:j])
+			i = j
+			continue
+		}
+		buf.Write(attr)
+		buf.WriteString(`="`)
+		buf.WriteString(html.EscapeString(value))
+		buf.WriteByte('"')
+		i = j
+	}
+	return len(data), name, nil
+}
+
+// text rewrites the text of the element with the provided name at the start
+// of the provided data into the provided buffer, which is either character
+// data or a CDATA section, providing its length. The whitespace surrounding
+// the text is kept, and elements holding anything else are left as is.
+func (m markup) text(buf *bytes.Buffer, data []byte, name string, replace markupReplace) (int, error) {
+	end := bytes.IndexByte(data, '<')
+	if end < 0 {
+		return 0, nil
+	}
+	lead, raw, trail := data[:0], data[:end], data[:0]
+	cdata := bytes.HasPrefix(data[end:], []byte("<![CDATA[")) && len(bytes.TrimSpace(raw)) == 0
+	if cdata {
+		terminator := bytes.Index(data[end+9:], []byte("]]>"))
+		if terminator < 0 {
+			return 0, nil
+		}
+		lead, raw = data[:end], data[end+9:end+9+terminator]
+		end += 9 + terminator + 3
+	} else {
+		trimmed := bytes.TrimSpace(raw)
+		start := bytes.Index(raw, trimmed)
+		lead, raw, trail = raw[:start], trimmed, raw[start+len(trimmed):]
+	}
+	if !bytes.HasPrefix(data[end:], []byte("</")) {
+		return 0, nil
+	}
+	value := string(raw)
+	if !cdata {
+		value = html.UnescapeString(value)
+	}
+	replaced, ok, err := replace(name, value)
+	if err != nil || !ok {
+		return 0, err
+	}
+	buf.Write(lead)
+	if cdata {
+		buf.WriteString("<![CDATA[" + replaced + "]]>")
+	} else {
+		buf.WriteString(html.EscapeString(replaced))
+	}
+	buf.Write(trail)
+	return end, nil
+}
+
+// isSpace indicates whether the provided byte is HTML whitespace.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r'
+}
+
+// isASCIILetter indicates whether the provided byte is an ASCII letter.
+func isASCIILetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// sameHost indicates whether the provided URL points at a path of the
+// provided host, either as an absolute path or as an absolute URL.
+func sameHost(u *url.URL, host string) bool {
+	if u.Scheme == "" && u.Host == "" {
+		return strings.HasPrefix(u.Path, "/")
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && strings.EqualFold(u.Host, host)
+}
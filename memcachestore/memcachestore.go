@@ -0,0 +1,264 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package memcachestore provides an obscurer.Store backed by memcached.
+//
+// Memcached is a cache, so mappings may be evicted under memory pressure
+// and the store is best suited to deployments that can tolerate obscured
+// URLs occasionally resolving to nothing. Memcached also cannot enumerate
+// its keys, so Clear flushes every server and Size counts every item, not
+// just those written by the store; dedicate the servers to the store when
+// either matters.
+package memcachestore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/codec"
+)
+
+const (
+	// maxKeyLength represents the maximum length of a memcached key.
+	maxKeyLength = 250
+	// maxRelativeExpiration represents the longest expiration memcached
+	// treats as relative, beyond which it is treated as a Unix timestamp.
+	maxRelativeExpiration = 30 * 24 * time.Hour
+)
+
+var (
+	// ErrCacheMiss represents the error returned by a Client when the
+	// requested item doesn't exist.
+	ErrCacheMiss = errors.New("memcachestore: cache miss")
+	// ErrNotStored represents the error returned by a Client when adding an
+	// item that already exists.
+	ErrNotStored = errors.New("memcachestore: item not stored")
+)
+
+// Item represents a memcached item.
+type Item struct {
+	// Key is the key of the item.
+	Key string
+	// Value is the value of the item.
+	Value []byte
+	// Expiration is when the item expires, as either a relative number of
+	// seconds of up to 30 days or a Unix timestamp. Zero never expires.
+	Expiration int32
+}
+
+// Client represents the subset of a memcached client needed by the store.
+type Client interface {
+	// Get retrieves the value of the item with the provided key, returning
+	// ErrCacheMiss when it doesn't exist.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Add writes the provided item, returning ErrNotStored when an item
+	// with the same key already exists.
+	Add(ctx context.Context, item Item) error
+	// Set writes the provided item, replacing any existing item.
+	Set(ctx context.Context, item Item) error
+	// Delete deletes the item with the provided key, returning ErrCacheMiss
+	// when it doesn't exist.
+	Delete(ctx context.Context, key string) error
+	// FlushAll deletes every item of every server.
+	FlushAll(ctx context.Context) error
+	// ItemCount counts the items of every server, such as by summing their
+	// 'curr_items' statistic.
+	ItemCount(ctx context.Context) (int, error)
+}
+
+// Options represents the configuration options for the store.
+type Options struct {
+	// Prefix is prepended to the keys of items written by the store.
+	Prefix string
+	// Expiration is the duration mappings are kept for. Zero keeps mappings
+	// until they are evicted.
+	Expiration time.Duration
+	// Codec serializes the values of items.
+	Codec codec.Codec
+}
+
+// Option applies an option to the provided configuration.
+type Option func(*Options)
+
+// WithPrefix configures the prefix prepended to the keys of items written by
+// the store.
+func WithPrefix(prefix string) Option {
+	return func(o *Options) {
+		o.Prefix = prefix
+	}
+}
+
+// WithExpiration configures the duration mappings are kept for.
+func WithExpiration(expiration time.Duration) Option {
+	return func(o *Options) {
+		o.Expiration = expiration
+	}
+}
+
+// WithCodec configures the codec the values of items are serialized with.
+func WithCodec(c codec.Codec) Option {
+	return func(o *Options) {
+		o.Codec = c
+	}
+}
+
+// Store stores mappings in memcached.
+type Store struct {
+	client  Client
+	options Options
+}
+
+// New constructs a store backed by the provided memcached client.
+func New(client Client, opts ...Option) *Store {
+	options := Options{Prefix: "obscurer:", Codec: codec.Protobuf}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &Store{client: client, options: options}
+}
+
+// key derives the item key for the provided obscured URL. Paths are encoded,
+// as keys cannot contain whitespace or control characters, and hashed when
+// the encoded form would exceed the maximum key length.
+func (s *Store) key(obscured *url.URL) string {
+	key := s.options.Prefix + base64.RawURLEncoding.EncodeToString([]byte(obscured.Path))
+	if len(key) <= maxKeyLength {
+		return key
+	}
+	sum := sha256.Sum256([]byte(obscured.Path))
+	return s.options.Prefix + "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// item constructs the item for the provided mapping.
+func (s *Store) item(obscured, original *url.URL) (Item, error) {
+	now := time.Now()
+	entry := codec.Entry{Original: original, CreatedAt: now}
+	item := Item{Key: s.key(obscured)}
+	if expiration := s.options.Expiration; expiration > 0 {
+		entry.ExpiresAt = now.Add(expiration)
+		item.Expiration = int32(expiration / time.Second)
+		if expiration > maxRelativeExpiration {
+			item.Expiration = int32(entry.ExpiresAt.Unix())
+		}
+	}
+	value, err := codec.Encode(s.options.Codec, entry)
+	if err != nil {
+		return Item{}, err
+	}
+	item.Value = value
+	return item, nil
+}
+
+// get retrieves the original form of the provided obscured URL.
+func (s *Store) get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	value, err := s.client.Get(ctx, s.key(obscured))
+	if errors.Is(err, ErrCacheMiss) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	entry, err := codec.Decode(value)
+	if err != nil {
+		return nil, false, err
+	}
+	return entry.Original, true, nil
+}
+
+// Put places the mapping between the provided obscured URL and it's original
+// form into the store. A *obscurer.CollisionError is returned when the
+// obscured URL is already mapped to a URL with a different path.
+func (s *Store) Put(ctx context.Context, obscured, original *url.URL) error {
+	item, err := s.item(obscured, original)
+	if err != nil {
+		return err
+	}
+	err = s.client.Add(ctx, item)
+	if !errors.Is(err, ErrNotStored) {
+		return err
+	}
+	existing, ok, err := s.get(ctx, obscured)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// the existing mapping was evicted in the meantime.
+		return s.client.Add(ctx, item)
+	}
+	if existing.Path != original.Path {
+		return &obscurer.CollisionError{
+			Obscured: obscured,
+			Existing: existing,
+			Original: original,
+		}
+	}
+	return nil
+}
+
+// Ping verifies a memcached server is reachable by getting the item of the
+// root path. A miss is as good as a hit.
+func (s *Store) Ping(ctx context.Context) error {
+	_, _, err := s.Get(ctx, &url.URL{Path: "/"})
+	return err
+}
+
+// Get retrieves the original form of the provided obscured URL.
+func (s *Store) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	return s.get(ctx, obscured)
+}
+
+// Remove deletes the entry in the store for the provided obscured URL.
+func (s *Store) Remove(ctx context.Context, obscured *url.URL) error {
+	err := s.client.Delete(ctx, s.key(obscured))
+	if errors.Is(err, ErrCacheMiss) {
+		return nil
+	}
+	return err
+}
+
+// Clear removes all entries in the store, by flushing every server.
+func (s *Store) Clear(ctx context.Context) error {
+	return s.client.FlushAll(ctx)
+}
+
+// Size computes the number of items of every server.
+func (s *Store) Size(ctx context.Context) int {
+	size, err := s.client.ItemCount(ctx)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// Load loads the store with the provided mappings. Existing mappings for the
+// same obscured URLs are replaced.
+func (s *Store) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	for obscured, original := range mappings.Pointers() {
+		item, err := s.item(obscured, original)
+		if err != nil {
+			return err
+		}
+		if err := s.client.Set(ctx, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
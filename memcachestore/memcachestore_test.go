@@ -0,0 +1,240 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package memcachestore_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/codec"
+	"github.com/freerware/obscurer/memcachestore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// client is an in-memory memcached client.
+type client struct {
+	mutex sync.Mutex
+	items map[string]memcachestore.Item
+}
+
+func newClient() *client {
+	return &client{items: make(map[string]memcachestore.Item)}
+}
+
+func (c *client) check(key string) error {
+	if len(key) > 250 || strings.ContainsAny(key, " \t\r\n") {
+		return errors.New("malformed key")
+	}
+	return nil
+}
+
+func (c *client) Get(ctx context.Context, key string) ([]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	item, ok := c.items[key]
+	if !ok {
+		return nil, memcachestore.ErrCacheMiss
+	}
+	return item.Value, nil
+}
+
+func (c *client) Add(ctx context.Context, item memcachestore.Item) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.check(item.Key); err != nil {
+		return err
+	}
+	if _, ok := c.items[item.Key]; ok {
+		return memcachestore.ErrNotStored
+	}
+	c.items[item.Key] = item
+	return nil
+}
+
+func (c *client) Set(ctx context.Context, item memcachestore.Item) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := c.check(item.Key); err != nil {
+		return err
+	}
+	c.items[item.Key] = item
+	return nil
+}
+
+func (c *client) Delete(ctx context.Context, key string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.items[key]; !ok {
+		return memcachestore.ErrCacheMiss
+	}
+	delete(c.items, key)
+	return nil
+}
+
+func (c *client) FlushAll(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.items = make(map[string]memcachestore.Item)
+	return nil
+}
+
+func (c *client) ItemCount(ctx context.Context) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.items), nil
+}
+
+// TestStore tests that mappings can be placed, retrieved, and removed.
+func TestStore(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := memcachestore.New(newClient())
+	obscured, original := mustParse("/abc"), mustParse("http://www.example.com/this/is/the/way")
+
+	// action + assert.
+	require.NoError(t, s.Put(ctx, obscured, original))
+	require.NoError(t, s.Put(ctx, obscured, original))
+	assert.Equal(t, 1, s.Size(ctx))
+	got, ok, err := s.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, original.String(), got.String())
+	require.NoError(t, s.Remove(ctx, obscured))
+	require.NoError(t, s.Remove(ctx, obscured))
+	_, ok, err = s.Get(ctx, obscured)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// TestStore_Put_Collision tests that placing a mapping for an obscured URL
+// that already maps to a different URL results in a collision error.
+func TestStore_Put_Collision(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := memcachestore.New(newClient())
+	require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+
+	// action.
+	err := s.Put(ctx, mustParse("/abc"), mustParse("/hey/der"))
+
+	// assert.
+	assert.True(t, errors.Is(err, obscurer.ErrCollision))
+}
+
+// TestStore_Put_LongPath tests that mappings for long paths are stored
+// under valid keys.
+func TestStore_Put_LongPath(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	c := newClient()
+	s := memcachestore.New(c, memcachestore.WithPrefix("app:"))
+	obscured := mustParse("/" + strings.Repeat("a b", 200))
+
+	// action.
+	err := s.Put(ctx, obscured, mustParse("/this/is/the/way"))
+
+	// assert.
+	require.NoError(t, err)
+	for key := range c.items {
+		assert.True(t, strings.HasPrefix(key, "app:sha256:"))
+	}
+	got, ok, err := s.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/this/is/the/way", got.String())
+}
+
+// TestStore_Expiration tests that items carry a relative expiration of up
+// to 30 days, and an absolute expiration beyond that.
+func TestStore_Expiration(t *testing.T) {
+	tests := []struct {
+		name       string
+		expiration time.Duration
+		expected   func() int32
+	}{
+		{
+			name:       "None",
+			expiration: 0,
+			expected:   func() int32 { return 0 },
+		},
+		{
+			name:       "Relative",
+			expiration: time.Hour,
+			expected:   func() int32 { return 3600 },
+		},
+		{
+			name:       "Absolute",
+			expiration: 60 * 24 * time.Hour,
+			expected:   func() int32 { return int32(time.Now().Add(60 * 24 * time.Hour).Unix()) },
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			ctx := context.Background()
+			c := newClient()
+			s := memcachestore.New(c, memcachestore.WithExpiration(test.expiration))
+
+			// action.
+			require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+
+			// assert.
+			require.Len(t, c.items, 1)
+			for _, item := range c.items {
+				assert.InDelta(t, test.expected(), item.Expiration, 1)
+			}
+		})
+	}
+}
+
+// TestStore_Load tests that the store can be loaded with the configured
+// codec, and cleared.
+func TestStore_Load(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	c := newClient()
+	s := memcachestore.New(c, memcachestore.WithCodec(codec.JSON))
+
+	// action.
+	err := obscurer.LoadAll(ctx, s, map[*url.URL]*url.URL{
+		mustParse("/a"): mustParse("/this/is/the/way"),
+		mustParse("/b"): mustParse("/hey/der"),
+	})
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.Size(ctx))
+	for _, item := range c.items {
+		assert.Equal(t, codec.JSON.ID(), item.Value[1])
+	}
+	require.NoError(t, s.Clear(ctx))
+	assert.Equal(t, 0, s.Size(ctx))
+}
+
+func mustParse(str string) *url.URL {
+	u, err := url.Parse(str)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
@@ -0,0 +1,60 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import "net/http"
+
+// Wrap constructs an HTTP handler capable of handling requests with obscured
+// URLs, configured entirely by the provided options, such that new options
+// can be added without changing its signature. The handler obscures URLs
+// with Default and keeps their mappings in DefaultStore, unless configured
+// otherwise with WithObscurer and WithStore.
+func Wrap(h http.Handler, opts ...HandlerOption) http.Handler {
+	return NewHandler(Default, DefaultStore, h, opts...)
+}
+
+// NewMiddleware constructs a middleware wrapping handlers as Wrap does with
+// the provided options.
+func NewMiddleware(opts ...HandlerOption) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return Wrap(h, opts...)
+	}
+}
+
+// WithObscurer configures the handler to obscure URLs with the provided
+// obscurer.
+func WithObscurer(o Obscurer) HandlerOption {
+	return func(opts *HandlerOptions) {
+		opts.Obscurer = o
+	}
+}
+
+// WithStore configures the handler to keep the mappings of obscured URLs in
+// the provided store.
+func WithStore(s Store) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Store = s
+	}
+}
+
+// WithHeaders configures the handler to obscure only the URLs of the
+// response headers with the provided keys, among 'Location',
+// 'Content-Location' and 'Link'.
+func WithHeaders(keys ...string) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Headers = append(o.Headers, keys...)
+	}
+}
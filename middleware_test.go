@@ -0,0 +1,74 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWrap tests that the handler uses the obscurer and store it is
+// configured with.
+func TestWrap(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	store := obscurer.NewMemoryStore()
+	o := obscurer.NewSHA256()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/this/is/the/way", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Location", "/hey/der")
+	})
+	handler := obscurer.Wrap(mux, obscurer.WithObscurer(o), obscurer.WithStore(store))
+	response := httptest.NewRecorder()
+
+	// action.
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/this/is/the/way", nil))
+
+	// assert.
+	obscured := o.Obscure(mustParse("/hey/der"))
+	assert.Equal(t, obscured.String(), response.Header().Get("Content-Location"))
+	original, ok, err := store.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/hey/der", original.String())
+}
+
+// TestNewMiddleware tests that the middleware obscures only the headers it
+// is configured with.
+func TestNewMiddleware(t *testing.T) {
+	// arrange.
+	store := obscurer.NewMemoryStore()
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Location", "/hey/der")
+		w.Header().Set("Link", `</hey/der>; rel="next"`)
+	})
+	middleware := obscurer.NewMiddleware(obscurer.WithStore(store), obscurer.WithHeaders("content-location"))
+	response := httptest.NewRecorder()
+
+	// action.
+	middleware(h).ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/this/is/the/way", nil))
+
+	// assert.
+	obscured := obscurer.Default.Obscure(mustParse("/hey/der"))
+	assert.Equal(t, obscured.String(), response.Header().Get("Content-Location"))
+	assert.Equal(t, `</hey/der>; rel="next"`, response.Header().Get("Link"))
+}
@@ -0,0 +1,142 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package mongodriver adapts a collection of the official MongoDB driver to
+// the Collection of the mongostore package:
+//
+//	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+//	collection := mongodriver.New(client.Database("obscurer").Collection("mappings"))
+//	s := mongostore.New(collection, mongostore.WithTTL(24*time.Hour))
+//	err = s.CreateIndexes(ctx)
+//
+// Documents are looked up by their 'obscured' field, which the unique index
+// created by the store covers.
+//
+// The package is a module of its own, so that applications that don't use
+// MongoDB don't depend on its driver.
+package mongodriver
+
+import (
+	"context"
+	"errors"
+
+	"github.com/freerware/obscurer/mongostore"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// collection adapts a collection of the driver to a mongostore.Collection.
+type collection struct {
+	collection *mongo.Collection
+}
+
+// New adapts the provided collection to a mongostore.Collection.
+func New(c *mongo.Collection) mongostore.Collection {
+	return &collection{collection: c}
+}
+
+// filter provides the filter matching the document with the provided
+// obscured key.
+func filter(key string) bson.D {
+	return bson.D{{Key: "obscured", Value: key}}
+}
+
+// InsertOne inserts the provided document, returning
+// mongostore.ErrDuplicateKey when a document with the same obscured key
+// exists.
+func (c *collection) InsertOne(ctx context.Context, doc mongostore.Document) error {
+	_, err := c.collection.InsertOne(ctx, doc)
+	if mongo.IsDuplicateKeyError(err) {
+		return mongostore.ErrDuplicateKey
+	}
+	return err
+}
+
+// FindOne retrieves the document with the provided obscured key, returning
+// mongostore.ErrNotFound when it doesn't exist.
+func (c *collection) FindOne(ctx context.Context, key string) (mongostore.Document, error) {
+	var doc mongostore.Document
+	err := c.collection.FindOne(ctx, filter(key)).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return mongostore.Document{}, mongostore.ErrNotFound
+	}
+	return doc, err
+}
+
+// ReplaceOne replaces the document with the same obscured key as the
+// provided document, inserting it when it doesn't exist.
+func (c *collection) ReplaceOne(ctx context.Context, doc mongostore.Document) error {
+	_, err := c.collection.ReplaceOne(ctx, filter(doc.Obscured), doc, options.Replace().SetUpsert(true))
+	return err
+}
+
+// DeleteOne deletes the document with the provided obscured key.
+func (c *collection) DeleteOne(ctx context.Context, key string) error {
+	_, err := c.collection.DeleteOne(ctx, filter(key))
+	return err
+}
+
+// DeleteMany deletes every document.
+func (c *collection) DeleteMany(ctx context.Context) error {
+	_, err := c.collection.DeleteMany(ctx, bson.D{})
+	return err
+}
+
+// Count counts the documents.
+func (c *collection) Count(ctx context.Context) (int, error) {
+	n, err := c.collection.CountDocuments(ctx, bson.D{})
+	return int(n), err
+}
+
+// BulkReplace replaces the documents with the same obscured keys as the
+// provided documents with an unordered bulk write, inserting those that
+// don't exist.
+func (c *collection) BulkReplace(ctx context.Context, docs []mongostore.Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	models := make([]mongo.WriteModel, len(docs))
+	for i, doc := range docs {
+		models[i] = mongo.NewReplaceOneModel().
+			SetFilter(filter(doc.Obscured)).
+			SetReplacement(doc).
+			SetUpsert(true)
+	}
+	_, err := c.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// CreateIndexes creates the provided indexes, doing nothing for those that
+// already exist. TTL indexes delete documents as soon as the date held by
+// their field has passed.
+func (c *collection) CreateIndexes(ctx context.Context, indexes []mongostore.Index) error {
+	if len(indexes) == 0 {
+		return nil
+	}
+	models := make([]mongo.IndexModel, len(indexes))
+	for i, index := range indexes {
+		opts := options.Index().SetName(index.Name)
+		if index.Unique {
+			opts.SetUnique(true)
+		}
+		if index.TTL {
+			opts.SetExpireAfterSeconds(0)
+		}
+		models[i] = mongo.IndexModel{Keys: bson.D{{Key: index.Field, Value: 1}}, Options: opts}
+	}
+	_, err := c.collection.Indexes().CreateMany(ctx, models)
+	return err
+}
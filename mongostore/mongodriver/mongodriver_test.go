@@ -0,0 +1,154 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mongodriver_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/freerware/obscurer/mongostore"
+	"github.com/freerware/obscurer/mongostore/mongodriver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestCollection_InsertOne tests that duplicate key errors are mapped to
+// mongostore.ErrDuplicateKey.
+func TestCollection_InsertOne(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("Inserted", func(mt *mtest.T) {
+		// arrange.
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		c := mongodriver.New(mt.Coll)
+
+		// action.
+		err := c.InsertOne(context.Background(), mongostore.Document{Obscured: "/a", Original: "/this/is/the/way"})
+
+		// assert.
+		assert.NoError(mt, err)
+	})
+	mt.Run("Duplicate", func(mt *mtest.T) {
+		// arrange.
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{
+			Code:    11000,
+			Message: "duplicate key error",
+		}))
+		c := mongodriver.New(mt.Coll)
+
+		// action.
+		err := c.InsertOne(context.Background(), mongostore.Document{Obscured: "/a", Original: "/this/is/the/way"})
+
+		// assert.
+		assert.Equal(mt, mongostore.ErrDuplicateKey, err)
+	})
+}
+
+// TestCollection_FindOne tests that documents are decoded, and that missing
+// documents are mapped to mongostore.ErrNotFound.
+func TestCollection_FindOne(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("Found", func(mt *mtest.T) {
+		// arrange.
+		namespace := mt.Coll.Database().Name() + "." + mt.Coll.Name()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, namespace, mtest.FirstBatch, bson.D{
+			{Key: "obscured", Value: "/a"},
+			{Key: "original", Value: "/this/is/the/way"},
+		}))
+		c := mongodriver.New(mt.Coll)
+
+		// action.
+		doc, err := c.FindOne(context.Background(), "/a")
+
+		// assert.
+		require.NoError(mt, err)
+		assert.Equal(mt, mongostore.Document{Obscured: "/a", Original: "/this/is/the/way"}, doc)
+		assert.Equal(mt, bson.Raw(mustMarshal(bson.D{{Key: "obscured", Value: "/a"}})),
+			mt.GetStartedEvent().Command.Lookup("filter").Document())
+	})
+	mt.Run("Missing", func(mt *mtest.T) {
+		// arrange.
+		namespace := mt.Coll.Database().Name() + "." + mt.Coll.Name()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, namespace, mtest.FirstBatch))
+		c := mongodriver.New(mt.Coll)
+
+		// action.
+		_, err := c.FindOne(context.Background(), "/a")
+
+		// assert.
+		assert.Equal(mt, mongostore.ErrNotFound, err)
+	})
+}
+
+// TestCollection_BulkReplace tests that documents are upserted with an
+// unordered bulk write.
+func TestCollection_BulkReplace(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("Upserted", func(mt *mtest.T) {
+		// arrange.
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}))
+		c := mongodriver.New(mt.Coll)
+
+		// action.
+		err := c.BulkReplace(context.Background(), []mongostore.Document{
+			{Obscured: "/a", Original: "/this/is/the/way"},
+			{Obscured: "/b", Original: "/hey/der"},
+		})
+
+		// assert.
+		require.NoError(mt, err)
+		started := mt.GetStartedEvent()
+		command := started.Command
+		assert.Equal(mt, "update", started.CommandName)
+		assert.False(mt, command.Lookup("ordered").Boolean())
+		updates, err := command.Lookup("updates").Array().Values()
+		require.NoError(mt, err)
+		require.Len(mt, updates, 2)
+		assert.True(mt, updates[0].Document().Lookup("upsert").Boolean())
+	})
+}
+
+// TestCollection_CreateIndexes tests that TTL indexes expire documents once
+// the date held by their field has passed.
+func TestCollection_CreateIndexes(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("TTL", func(mt *mtest.T) {
+		// arrange.
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		c := mongodriver.New(mt.Coll)
+		s := mongostore.New(c, mongostore.WithTTL(1))
+
+		// action.
+		err := s.CreateIndexes(context.Background())
+
+		// assert.
+		require.NoError(mt, err)
+		indexes, err := mt.GetStartedEvent().Command.Lookup("indexes").Array().Values()
+		require.NoError(mt, err)
+		require.Len(mt, indexes, 2)
+		assert.True(mt, indexes[0].Document().Lookup("unique").Boolean())
+		assert.Equal(mt, int32(0), indexes[1].Document().Lookup("expireAfterSeconds").Int32())
+	})
+}
+
+func mustMarshal(v interface{}) []byte {
+	b, err := bson.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,253 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package mongostore provides an obscurer.Store backed by a MongoDB
+// collection.
+//
+// Each mapping is a document with a unique index on its 'obscured' field,
+// which guarantees that concurrent writers cannot map the same obscured URL
+// twice. When configured with a TTL, documents carry an 'expiresAt' date
+// covered by a TTL index, which MongoDB uses to delete them. As the TTL
+// monitor only runs periodically, expired documents are also treated as
+// missing on read.
+//
+// The store accesses the collection through the Collection interface, which
+// the mongodriver module implements with the official MongoDB driver.
+package mongostore
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/freerware/obscurer"
+)
+
+// batchLimit represents the maximum number of documents in a single bulk
+// write.
+const batchLimit = 1000
+
+var (
+	// ErrNotFound represents the error returned by a Collection when the
+	// requested document doesn't exist.
+	ErrNotFound = errors.New("mongostore: document not found")
+	// ErrDuplicateKey represents the error returned by a Collection when
+	// inserting a document whose obscured key already exists.
+	ErrDuplicateKey = errors.New("mongostore: duplicate key")
+)
+
+// Document represents a stored mapping.
+type Document struct {
+	// Obscured is the path of the obscured URL.
+	Obscured string `bson:"obscured"`
+	// Original is the original form of the obscured URL.
+	Original string `bson:"original"`
+	// ExpiresAt is when the mapping expires. The zero value never expires.
+	ExpiresAt time.Time `bson:"expiresAt,omitempty"`
+}
+
+// Index represents an index on a single field of the collection.
+type Index struct {
+	// Name is the name of the index.
+	Name string
+	// Field is the indexed field.
+	Field string
+	// Unique indicates whether the values of the field must be unique.
+	Unique bool
+	// TTL indicates whether documents are deleted once the date held by
+	// the field has passed.
+	TTL bool
+}
+
+// Collection represents the subset of a MongoDB collection needed by the
+// store.
+type Collection interface {
+	// InsertOne inserts the provided document, returning ErrDuplicateKey
+	// when a document with the same obscured key exists.
+	InsertOne(ctx context.Context, doc Document) error
+	// FindOne retrieves the document with the provided obscured key,
+	// returning ErrNotFound when it doesn't exist.
+	FindOne(ctx context.Context, key string) (Document, error)
+	// ReplaceOne replaces the document with the same obscured key as the
+	// provided document, inserting it when it doesn't exist.
+	ReplaceOne(ctx context.Context, doc Document) error
+	// DeleteOne deletes the document with the provided obscured key.
+	DeleteOne(ctx context.Context, key string) error
+	// DeleteMany deletes every document.
+	DeleteMany(ctx context.Context) error
+	// Count counts the documents.
+	Count(ctx context.Context) (int, error)
+	// BulkReplace replaces the documents with the same obscured keys as the
+	// provided documents, inserting those that don't exist.
+	BulkReplace(ctx context.Context, docs []Document) error
+	// CreateIndexes creates the provided indexes, doing nothing for those
+	// that already exist.
+	CreateIndexes(ctx context.Context, indexes []Index) error
+}
+
+// Options represents the configuration options for the store.
+type Options struct {
+	// TTL is the duration mappings are kept for. Zero keeps mappings
+	// indefinitely.
+	TTL time.Duration
+}
+
+// Option applies an option to the provided configuration.
+type Option func(*Options)
+
+// WithTTL configures the duration mappings are kept for.
+func WithTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.TTL = ttl
+	}
+}
+
+// Store stores mappings in a MongoDB collection.
+type Store struct {
+	collection Collection
+	options    Options
+}
+
+// New constructs a store backed by the provided collection. CreateIndexes
+// must be called before the store is used with a new collection.
+func New(collection Collection, opts ...Option) *Store {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &Store{collection: collection, options: options}
+}
+
+// Indexes provides the indexes expected by the store.
+func (s *Store) Indexes() []Index {
+	indexes := []Index{{Name: "obscured_unique", Field: "obscured", Unique: true}}
+	if s.options.TTL > 0 {
+		indexes = append(indexes, Index{Name: "expiresAt_ttl", Field: "expiresAt", TTL: true})
+	}
+	return indexes
+}
+
+// CreateIndexes creates the indexes expected by the store.
+func (s *Store) CreateIndexes(ctx context.Context) error {
+	return s.collection.CreateIndexes(ctx, s.Indexes())
+}
+
+// document constructs the document for the provided mapping.
+func (s *Store) document(obscured, original *url.URL) Document {
+	doc := Document{Obscured: obscured.Path, Original: original.String()}
+	if s.options.TTL > 0 {
+		doc.ExpiresAt = time.Now().Add(s.options.TTL).UTC()
+	}
+	return doc
+}
+
+// get retrieves the original form of the provided obscured URL.
+func (s *Store) get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	doc, err := s.collection.FindOne(ctx, obscured.Path)
+	if errors.Is(err, ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if !doc.ExpiresAt.IsZero() && !time.Now().Before(doc.ExpiresAt) {
+		return nil, false, nil
+	}
+	original, err := url.Parse(doc.Original)
+	if err != nil {
+		return nil, false, err
+	}
+	return original, true, nil
+}
+
+// Put places the mapping between the provided obscured URL and it's original
+// form into the store. A *obscurer.CollisionError is returned when the
+// obscured URL is already mapped to a URL with a different path.
+func (s *Store) Put(ctx context.Context, obscured, original *url.URL) error {
+	doc := s.document(obscured, original)
+	err := s.collection.InsertOne(ctx, doc)
+	if !errors.Is(err, ErrDuplicateKey) {
+		return err
+	}
+	existing, ok, err := s.get(ctx, obscured)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// the existing mapping has expired, but has yet to be deleted.
+		return s.collection.ReplaceOne(ctx, doc)
+	}
+	if existing.Path != original.Path {
+		return &obscurer.CollisionError{
+			Obscured: obscured,
+			Existing: existing,
+			Original: original,
+		}
+	}
+	return nil
+}
+
+// Ping verifies the deployment is reachable and the collection readable, by
+// finding the document of the root path, which is rarely mapped.
+func (s *Store) Ping(ctx context.Context) error {
+	_, _, err := s.Get(ctx, &url.URL{Path: "/"})
+	return err
+}
+
+// Get retrieves the original form of the provided obscured URL.
+func (s *Store) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	return s.get(ctx, obscured)
+}
+
+// Remove deletes the entry in the store for the provided obscured URL.
+func (s *Store) Remove(ctx context.Context, obscured *url.URL) error {
+	return s.collection.DeleteOne(ctx, obscured.Path)
+}
+
+// Clear removes all entries in the store.
+func (s *Store) Clear(ctx context.Context) error {
+	return s.collection.DeleteMany(ctx)
+}
+
+// Size computes the size of the store.
+func (s *Store) Size(ctx context.Context) int {
+	size, err := s.collection.Count(ctx)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// Load loads the store with the provided mappings. The mappings are written
+// with bulk writes, replacing any existing mappings for the same obscured
+// URLs.
+func (s *Store) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	docs := make([]Document, 0, mappings.Len())
+	for obscured, original := range mappings.Pointers() {
+		docs = append(docs, s.document(obscured, original))
+	}
+	for len(docs) > 0 {
+		n := len(docs)
+		if n > batchLimit {
+			n = batchLimit
+		}
+		if err := s.collection.BulkReplace(ctx, docs[:n]); err != nil {
+			return err
+		}
+		docs = docs[n:]
+	}
+	return nil
+}
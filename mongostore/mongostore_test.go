@@ -0,0 +1,244 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mongostore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/mongostore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collection is an in-memory collection.
+type collection struct {
+	mutex   sync.Mutex
+	docs    map[string]mongostore.Document
+	indexes []mongostore.Index
+	bulks   int
+}
+
+func newCollection() *collection {
+	return &collection{docs: make(map[string]mongostore.Document)}
+}
+
+func (c *collection) InsertOne(ctx context.Context, doc mongostore.Document) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.docs[doc.Obscured]; ok {
+		return mongostore.ErrDuplicateKey
+	}
+	c.docs[doc.Obscured] = doc
+	return nil
+}
+
+func (c *collection) FindOne(ctx context.Context, key string) (mongostore.Document, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	doc, ok := c.docs[key]
+	if !ok {
+		return doc, mongostore.ErrNotFound
+	}
+	return doc, nil
+}
+
+func (c *collection) ReplaceOne(ctx context.Context, doc mongostore.Document) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.docs[doc.Obscured] = doc
+	return nil
+}
+
+func (c *collection) DeleteOne(ctx context.Context, key string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.docs, key)
+	return nil
+}
+
+func (c *collection) DeleteMany(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.docs = make(map[string]mongostore.Document)
+	return nil
+}
+
+func (c *collection) Count(ctx context.Context) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.docs), nil
+}
+
+func (c *collection) BulkReplace(ctx context.Context, docs []mongostore.Document) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(docs) > 1000 {
+		return errors.New("too many documents")
+	}
+	c.bulks++
+	for _, doc := range docs {
+		c.docs[doc.Obscured] = doc
+	}
+	return nil
+}
+
+func (c *collection) CreateIndexes(ctx context.Context, indexes []mongostore.Index) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.indexes = indexes
+	return nil
+}
+
+// TestStore tests that mappings can be placed, retrieved, and removed.
+func TestStore(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := mongostore.New(newCollection())
+	obscured, original := mustParse("/abc"), mustParse("http://www.example.com/this/is/the/way")
+
+	// action + assert.
+	require.NoError(t, s.Put(ctx, obscured, original))
+	require.NoError(t, s.Put(ctx, obscured, original))
+	assert.Equal(t, 1, s.Size(ctx))
+	got, ok, err := s.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, original.String(), got.String())
+	require.NoError(t, s.Remove(ctx, obscured))
+	_, ok, err = s.Get(ctx, obscured)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// TestStore_Put_Collision tests that placing a mapping for an obscured URL
+// that already maps to a different URL results in a collision error.
+func TestStore_Put_Collision(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := mongostore.New(newCollection())
+	require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+
+	// action.
+	err := s.Put(ctx, mustParse("/abc"), mustParse("/hey/der"))
+
+	// assert.
+	assert.True(t, errors.Is(err, obscurer.ErrCollision))
+}
+
+// TestStore_TTL tests that documents carry an expiration, and that expired
+// documents are treated as missing and can be replaced.
+func TestStore_TTL(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	c := newCollection()
+	s := mongostore.New(c, mongostore.WithTTL(time.Hour))
+	require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+	assert.WithinDuration(t, time.Now().Add(time.Hour), c.docs["/abc"].ExpiresAt, time.Second)
+	c.docs["/abc"] = mongostore.Document{
+		Obscured:  "/abc",
+		Original:  "/this/is/the/way",
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}
+
+	// action.
+	_, ok, err := s.Get(ctx, mustParse("/abc"))
+	require.NoError(t, err)
+	err = s.Put(ctx, mustParse("/abc"), mustParse("/hey/der"))
+
+	// assert.
+	assert.False(t, ok)
+	require.NoError(t, err)
+	got, ok, err := s.Get(ctx, mustParse("/abc"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/hey/der", got.String())
+}
+
+// TestStore_CreateIndexes tests that a unique index is created on the
+// obscured key, along with a TTL index when mappings expire.
+func TestStore_CreateIndexes(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []mongostore.Option
+		expected []mongostore.Index
+	}{
+		{
+			name: "Unique",
+			expected: []mongostore.Index{
+				{Name: "obscured_unique", Field: "obscured", Unique: true},
+			},
+		},
+		{
+			name: "TTL",
+			opts: []mongostore.Option{mongostore.WithTTL(time.Hour)},
+			expected: []mongostore.Index{
+				{Name: "obscured_unique", Field: "obscured", Unique: true},
+				{Name: "expiresAt_ttl", Field: "expiresAt", TTL: true},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			c := newCollection()
+			s := mongostore.New(c, test.opts...)
+
+			// action.
+			err := s.CreateIndexes(context.Background())
+
+			// assert.
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, c.indexes)
+		})
+	}
+}
+
+// TestStore_Load tests that the store can be loaded in bulk, and cleared.
+func TestStore_Load(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	c := newCollection()
+	s := mongostore.New(c)
+	mappings := make(map[*url.URL]*url.URL)
+	for i := 0; i < 2500; i++ {
+		mappings[mustParse(fmt.Sprintf("/%d", i))] = mustParse(fmt.Sprintf("/this/is/the/way/%d", i))
+	}
+
+	// action.
+	err := obscurer.LoadAll(ctx, s, mappings)
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 2500, s.Size(ctx))
+	assert.Equal(t, 3, c.bulks)
+	require.NoError(t, s.Clear(ctx))
+	assert.Equal(t, 0, s.Size(ctx))
+}
+
+func mustParse(str string) *url.URL {
+	u, err := url.Parse(str)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
@@ -0,0 +1,41 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"context"
+	"net/http"
+)
+
+// namespaceKey represents the context key of the namespace of a request.
+type namespaceKey struct{}
+
+// Namespacer provides the namespace of the provided request, such as the
+// tenant it was issued by, which is empty when the request has none.
+type Namespacer func(r *http.Request) string
+
+// ContextWithNamespace provides a copy of the provided context carrying the
+// provided namespace, which namespaced stores partition mappings by.
+func ContextWithNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, namespaceKey{}, namespace)
+}
+
+// NamespaceFromContext provides the namespace carried by the provided
+// context, if any.
+func NamespaceFromContext(ctx context.Context) (string, bool) {
+	namespace, ok := ctx.Value(namespaceKey{}).(string)
+	return namespace, ok && namespace != ""
+}
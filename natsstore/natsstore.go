@@ -0,0 +1,222 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package natsstore provides an obscurer.Store backed by a NATS JetStream
+// key-value bucket. Expiration, replication, and history are configured on
+// the bucket itself.
+package natsstore
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/codec"
+)
+
+var (
+	// ErrKeyNotFound represents the error returned by a KeyValue when the
+	// requested key doesn't exist.
+	ErrKeyNotFound = errors.New("natsstore: key not found")
+	// ErrKeyExists represents the error returned by a KeyValue when creating
+	// a key that already exists.
+	ErrKeyExists = errors.New("natsstore: key exists")
+)
+
+// KeyValue represents the subset of a JetStream key-value bucket needed by
+// the store.
+type KeyValue interface {
+	// Get retrieves the value for the provided key, returning
+	// ErrKeyNotFound when it doesn't exist.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Create places the value for the provided key, returning ErrKeyExists
+	// when it already exists.
+	Create(ctx context.Context, key string, value []byte) error
+	// Delete removes the provided key along with its history, as purging it
+	// does, so that it can be created again.
+	Delete(ctx context.Context, key string) error
+	// Keys retrieves all of the keys in the bucket, which are none rather
+	// than an error when the bucket is empty.
+	Keys(ctx context.Context) ([]string, error)
+}
+
+// Options represents the configuration options for the store.
+type Options struct {
+	// Codec serializes the values written to the bucket. When nil, the
+	// original URL is written as is.
+	Codec codec.Codec
+}
+
+// Option applies an option to the provided configuration.
+type Option func(*Options)
+
+// WithCodec configures the codec values are serialized with. Values written
+// with other codecs, or without one, remain readable.
+func WithCodec(c codec.Codec) Option {
+	return func(o *Options) {
+		o.Codec = c
+	}
+}
+
+// Store stores mappings in a JetStream key-value bucket.
+type Store struct {
+	kv      KeyValue
+	options Options
+}
+
+// New constructs a store backed by the provided key-value bucket.
+func New(kv KeyValue, opts ...Option) *Store {
+	s := &Store{kv: kv}
+	for _, opt := range opts {
+		opt(&s.options)
+	}
+	return s
+}
+
+// key derives the bucket key for the provided obscured URL. Paths are
+// encoded, as bucket keys are restricted to a small set of characters.
+func key(obscured *url.URL) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(obscured.Path))
+}
+
+// get retrieves the original form of the provided obscured URL.
+func (s *Store) get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	value, err := s.kv.Get(ctx, key(obscured))
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	entry, err := codec.Decode(value)
+	if err != nil {
+		return nil, false, err
+	}
+	return entry.Original, true, nil
+}
+
+// value serializes the provided original URL with the configured codec.
+func (s *Store) value(original *url.URL) ([]byte, error) {
+	if s.options.Codec == nil {
+		return []byte(original.String()), nil
+	}
+	return codec.Encode(s.options.Codec, codec.Entry{
+		Original:  original,
+		CreatedAt: time.Now(),
+	})
+}
+
+// Put places the mapping between the provided obscured URL and it's original
+// form into the store. A *obscurer.CollisionError is returned when the
+// obscured URL is already mapped to a URL with a different path.
+func (s *Store) Put(ctx context.Context, obscured, original *url.URL) error {
+	value, err := s.value(original)
+	if err != nil {
+		return err
+	}
+	err = s.kv.Create(ctx, key(obscured), value)
+	if !errors.Is(err, ErrKeyExists) {
+		return err
+	}
+	existing, ok, err := s.get(ctx, obscured)
+	if err != nil || !ok {
+		return err
+	}
+	if existing.Path != original.Path {
+		return &obscurer.CollisionError{
+			Obscured: obscured,
+			Existing: existing,
+			Original: original,
+		}
+	}
+	return nil
+}
+
+// Ping verifies the NATS server is reachable and the bucket exists, by
+// getting the entry of the root path.
+func (s *Store) Ping(ctx context.Context) error {
+	_, _, err := s.Get(ctx, &url.URL{Path: "/"})
+	return err
+}
+
+// Get retrieves the original form of the provided obscured URL.
+func (s *Store) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	return s.get(ctx, obscured)
+}
+
+// Range invokes the provided function for each mapping in the store, until
+// the function returns false. Mappings removed while ranging are skipped.
+func (s *Store) Range(ctx context.Context, fn func(obscured, original *url.URL) bool) error {
+	keys, err := s.kv.Keys(ctx)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		path, err := base64.RawURLEncoding.DecodeString(k)
+		if err != nil {
+			return err
+		}
+		obscured := &url.URL{Path: string(path)}
+		original, ok, err := s.get(ctx, obscured)
+		if err != nil {
+			return err
+		}
+		if ok && !fn(obscured, original) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Remove deletes the entry in the store for the provided obscured URL.
+func (s *Store) Remove(ctx context.Context, obscured *url.URL) error {
+	return s.kv.Delete(ctx, key(obscured))
+}
+
+// Clear removes all entries in the store.
+func (s *Store) Clear(ctx context.Context) error {
+	keys, err := s.kv.Keys(ctx)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := s.kv.Delete(ctx, k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Size computes the size of the store.
+func (s *Store) Size(ctx context.Context) int {
+	keys, err := s.kv.Keys(ctx)
+	if err != nil {
+		return 0
+	}
+	return len(keys)
+}
+
+// Load loads the store with the provided mappings.
+func (s *Store) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	for obscured, original := range mappings.Pointers() {
+		if err := s.Put(ctx, obscured, original); err != nil {
+			return err
+		}
+	}
+	return nil
+}
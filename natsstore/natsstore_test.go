@@ -0,0 +1,239 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package natsstore_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/codec"
+	"github.com/freerware/obscurer/natsstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// validKey matches the keys permitted by JetStream key-value buckets.
+var validKey = regexp.MustCompile(`^[-/_=\.a-zA-Z0-9]+$`)
+
+// kv is an in-memory key-value bucket.
+type kv struct {
+	mutex  sync.Mutex
+	values map[string][]byte
+	err    error
+}
+
+func newKV() *kv {
+	return &kv{values: make(map[string][]byte)}
+}
+
+func (b *kv) Get(ctx context.Context, key string) ([]byte, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.err != nil {
+		return nil, b.err
+	}
+	value, ok := b.values[key]
+	if !ok {
+		return nil, natsstore.ErrKeyNotFound
+	}
+	return value, nil
+}
+
+func (b *kv) Create(ctx context.Context, key string, value []byte) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if !validKey.MatchString(key) {
+		return errors.New("invalid key")
+	}
+	if _, ok := b.values[key]; ok {
+		return natsstore.ErrKeyExists
+	}
+	b.values[key] = value
+	return nil
+}
+
+func (b *kv) Delete(ctx context.Context, key string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.values, key)
+	return nil
+}
+
+func (b *kv) Keys(ctx context.Context) (keys []string, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for key := range b.values {
+		keys = append(keys, key)
+	}
+	return
+}
+
+// TestStore tests that mappings can be placed, retrieved, and removed.
+func TestStore(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := natsstore.New(newKV())
+	obscured, original := mustParse("/abc"), mustParse("http://www.example.com/this/is/the/way?x=1")
+
+	// action + assert.
+	require.NoError(t, s.Put(ctx, obscured, original))
+	require.NoError(t, s.Put(ctx, obscured, original))
+	assert.Equal(t, 1, s.Size(ctx))
+	got, ok, err := s.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, original.String(), got.String())
+	require.NoError(t, s.Remove(ctx, obscured))
+	_, ok, err = s.Get(ctx, obscured)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// TestStore_Put_Collision tests that placing a mapping for an obscured URL
+// that already maps to a different URL results in a collision error.
+func TestStore_Put_Collision(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := natsstore.New(newKV())
+	require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+
+	// action.
+	err := s.Put(ctx, mustParse("/abc"), mustParse("/hey/der"))
+
+	// assert.
+	assert.True(t, errors.Is(err, obscurer.ErrCollision))
+}
+
+// TestStore_Get_Error tests that failures reading from the bucket result in
+// an error.
+func TestStore_Get_Error(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	b := newKV()
+	s := natsstore.New(b)
+	require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+	b.err = errors.New("whoa")
+
+	// action.
+	got, ok, err := s.Get(ctx, mustParse("/abc"))
+
+	// assert.
+	assert.Equal(t, b.err, err)
+	assert.False(t, ok)
+	assert.Nil(t, got)
+}
+
+// TestStore_Load tests that the store can be loaded, and cleared.
+func TestStore_Load(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := natsstore.New(newKV())
+
+	// action.
+	err := obscurer.LoadAll(ctx, s, map[*url.URL]*url.URL{
+		mustParse("/a"): mustParse("/this/is/the/way"),
+		mustParse("/b"): mustParse("/hey/der"),
+	})
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.Size(ctx))
+	require.NoError(t, s.Clear(ctx))
+	assert.Equal(t, 0, s.Size(ctx))
+}
+
+// TestStore_Codec tests that values are serialized with the configured
+// codec, and that values written without one remain readable.
+func TestStore_Codec(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	b := newKV()
+	require.NoError(t, natsstore.New(b).Put(ctx, mustParse("/a"), mustParse("/this/is/the/way")))
+	s := natsstore.New(b, natsstore.WithCodec(codec.Protobuf))
+
+	// action.
+	err := s.Put(ctx, mustParse("/b"), mustParse("/hey/der"))
+
+	// assert.
+	require.NoError(t, err)
+	entry, err := codec.Decode(b.values["L2I"])
+	require.NoError(t, err)
+	assert.Equal(t, "/hey/der", entry.Original.String())
+	assert.False(t, entry.CreatedAt.IsZero())
+	got, ok, err := s.Get(ctx, mustParse("/a"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/this/is/the/way", got.String())
+}
+
+// TestStore_Range tests that every mapping is visited until the function
+// returns false.
+func TestStore_Range(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := natsstore.New(newKV())
+	require.NoError(t, obscurer.LoadAll(ctx, s, map[*url.URL]*url.URL{
+		mustParse("/a"): mustParse("/this/is/the/way"),
+		mustParse("/b"): mustParse("/hey/der"),
+	}))
+	visited := make(map[string]string)
+
+	// action.
+	err := s.Range(ctx, func(obscured, original *url.URL) bool {
+		visited[obscured.Path] = original.String()
+		return true
+	})
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"/a": "/this/is/the/way", "/b": "/hey/der"}, visited)
+	count := 0
+	require.NoError(t, s.Range(ctx, func(obscured, original *url.URL) bool {
+		count++
+		return false
+	}))
+	assert.Equal(t, 1, count, "expected ranging to stop")
+}
+
+func mustParse(str string) *url.URL {
+	u, err := url.Parse(str)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// TestStore_Ping tests that the store is unreachable while the bucket fails
+// to be read.
+func TestStore_Ping(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	b := newKV()
+	s := natsstore.New(b)
+
+	// action.
+	err := s.Ping(ctx)
+
+	// assert.
+	assert.NoError(t, err)
+	b.err = errors.New("whoa")
+	assert.Equal(t, b.err, s.Ping(ctx))
+}
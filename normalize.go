@@ -0,0 +1,99 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Normalizer normalizes a URL in place before it is obscured, so that
+// equivalent URLs obscure to the same value.
+type Normalizer func(*url.URL)
+
+var (
+	// LowercaseHost represents the normalizer that lower cases the scheme
+	// and host of the URL, as both are case insensitive.
+	LowercaseHost Normalizer = func(u *url.URL) {
+		u.Scheme = strings.ToLower(u.Scheme)
+		u.Host = strings.ToLower(u.Host)
+	}
+
+	// CleanPath represents the normalizer that removes dot segments and
+	// duplicate slashes from the path.
+	CleanPath Normalizer = func(u *url.URL) {
+		if u.Path == "" {
+			return
+		}
+		cleaned := path.Clean(u.Path)
+		if strings.HasSuffix(u.Path, "/") && cleaned != "/" {
+			cleaned = cleaned + "/"
+		}
+		u.Path = cleaned
+	}
+
+	// TrimTrailingSlash represents the normalizer that removes trailing
+	// slashes from the path, such that '/foo/bar/' and '/foo/bar' are
+	// treated the same.
+	TrimTrailingSlash Normalizer = func(u *url.URL) {
+		if len(u.Path) > 1 {
+			u.Path = strings.TrimRight(u.Path, "/")
+			if u.Path == "" {
+				u.Path = "/"
+			}
+		}
+	}
+
+	// DefaultNormalizer represents the default normalizer, which applies all
+	// of the provided normalizers.
+	DefaultNormalizer = Normalizers(
+		LowercaseHost,
+		CleanPath,
+		TrimTrailingSlash,
+	)
+)
+
+// NormalizingObscurer represents an obscurer normalizing URLs before
+// obscuring them, such that equivalent URLs share an obscured form. The
+// handler places the normalized URL into the store as the original form,
+// so that equivalent URLs don't collide with one another.
+type NormalizingObscurer interface {
+	Obscurer
+
+	// Normalize provides the normalized copy of the provided URL, which is
+	// obscured in its stead.
+	Normalize(*url.URL) *url.URL
+}
+
+// normalized provides the normalized copy of the provided URL when the
+// provided obscurer normalizes URLs, and the URL as is otherwise.
+func normalized(o Obscurer, u *url.URL) *url.URL {
+	if n, ok := o.(NormalizingObscurer); ok {
+		return n.Normalize(u)
+	}
+	return u
+}
+
+// Normalizers combines the provided normalizers into a single normalizer
+// that applies each of them in order.
+func Normalizers(normalizers ...Normalizer) Normalizer {
+	return func(u *url.URL) {
+		for _, normalize := range normalizers {
+			normalize(u)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNormalizers tests that each of the provided normalizers produce the
+// expected URL.
+func TestNormalizers(t *testing.T) {
+	tests := []struct {
+		name       string
+		normalizer obscurer.Normalizer
+		in         string
+		want       string
+	}{
+		{
+			name:       "LowercaseHost",
+			normalizer: obscurer.LowercaseHost,
+			in:         "HTTP://WWW.Example.COM/Foo",
+			want:       "http://www.example.com/Foo",
+		},
+		{
+			name:       "CleanPath",
+			normalizer: obscurer.CleanPath,
+			in:         "http://www.example.com/foo//baz/../bar/",
+			want:       "http://www.example.com/foo/bar/",
+		},
+		{
+			name:       "CleanPath_Root",
+			normalizer: obscurer.CleanPath,
+			in:         "http://www.example.com/",
+			want:       "http://www.example.com/",
+		},
+		{
+			name:       "TrimTrailingSlash",
+			normalizer: obscurer.TrimTrailingSlash,
+			in:         "http://www.example.com/foo/bar//",
+			want:       "http://www.example.com/foo/bar",
+		},
+		{
+			name:       "TrimTrailingSlash_Root",
+			normalizer: obscurer.TrimTrailingSlash,
+			in:         "http://www.example.com/",
+			want:       "http://www.example.com/",
+		},
+		{
+			name:       "DefaultNormalizer",
+			normalizer: obscurer.DefaultNormalizer,
+			in:         "http://WWW.EXAMPLE.COM/foo%2F./bar/",
+			want:       "http://www.example.com/foo/bar",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			u := mustParse(test.in)
+
+			// action.
+			test.normalizer(u)
+
+			// assert.
+			assert.Equal(t, test.want, u.String())
+		})
+	}
+}
+
+// TestWithNormalizer tests that equivalent URLs obscure to the same value
+// when the obscurer is configured with a normalizer.
+func TestWithNormalizer(t *testing.T) {
+	obscurers := map[string]obscurer.Obscurer{
+		"MD5":     obscurer.NewMD5(obscurer.WithNormalizer(obscurer.DefaultNormalizer)),
+		"SipHash": obscurer.NewSipHash(sipHashKey, obscurer.WithNormalizer(obscurer.DefaultNormalizer)),
+	}
+	for name, o := range obscurers {
+		t.Run(name, func(t *testing.T) {
+			// arrange.
+			want := o.Obscure(mustParse("http://www.example.com/foo/bar"))
+
+			// action + assert.
+			for _, in := range []string{
+				"http://www.example.com/foo/bar/",
+				"http://WWW.EXAMPLE.COM/foo/bar",
+			} {
+				got := o.Obscure(mustParse(in))
+				assert.Equal(t, want.String(), got.String(), "expected %q to obscure to %q", in, want)
+			}
+		})
+	}
+}
+
+// TestWithNormalizer_OriginalUntouched tests that normalization does not
+// modify the URL provided to the obscurer.
+func TestWithNormalizer_OriginalUntouched(t *testing.T) {
+	// arrange.
+	o := obscurer.NewMD5(obscurer.WithNormalizer(obscurer.DefaultNormalizer))
+	u := mustParse("http://WWW.EXAMPLE.COM/foo/bar/")
+
+	// action.
+	o.Obscure(u)
+
+	// assert.
+	assert.Equal(t, "http://WWW.EXAMPLE.COM/foo/bar/", u.String())
+}
+
+// TestHandler_Normalizer tests that equivalent URLs share the mapping of
+// their normalized form rather than colliding with one another.
+func TestHandler_Normalizer(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	o := obscurer.NewMD5(obscurer.WithNormalizer(obscurer.DefaultNormalizer))
+	s := obscurer.NewMemoryStore()
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", r.URL.Query().Get("next"))
+		w.WriteHeader(http.StatusFound)
+	})
+	handler := obscurer.NewHandler(o, s, h)
+	var locations []string
+
+	// action.
+	for _, next := range []string{"/foo/bar/", "/foo/bar", "/foo//bar"} {
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/?next="+next, nil))
+		require.Equal(t, http.StatusFound, response.Code)
+		locations = append(locations, response.Header().Get("Location"))
+	}
+
+	// assert.
+	want := o.Obscure(mustParse("/foo/bar")).String()
+	assert.Equal(t, []string{want, want, want}, locations)
+	assert.Equal(t, 1, s.Size(ctx))
+	original, ok, err := s.Get(ctx, mustParse(want))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/foo/bar", original.String())
+}
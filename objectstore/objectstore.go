@@ -0,0 +1,326 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package objectstore provides an obscurer.Store that persists mappings to
+// object storage, such as Amazon S3 or Google Cloud Storage.
+//
+// Mappings are served from a local in-memory index, while changes are
+// appended to the bucket in batched segments. When the store is opened, the
+// segments are replayed to rebuild the index.
+//
+// A change is visible as soon as it is recorded, but is only durable once
+// the segment holding it is written, so up to BatchSize-1 changes buffered
+// since the last segment are lost when the process crashes before Flush or
+// Close. A change whose Put or Remove fails to write its segment is rolled
+// back, while the changes buffered before it remain pending, and are
+// written along with the next segment.
+package objectstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/freerware/obscurer"
+)
+
+// Bucket represents the subset of an object storage bucket needed by the
+// store. Adapting the S3 or GCS clients to this interface is a matter of a
+// few lines.
+type Bucket interface {
+	// Put writes the object with the provided key.
+	Put(ctx context.Context, key string, data []byte) error
+	// Get reads the object with the provided key.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// List lists the keys of all objects starting with the provided prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Delete deletes the object with the provided key.
+	Delete(ctx context.Context, key string) error
+}
+
+// Options represents the configuration options for the store.
+type Options struct {
+	// Prefix is prepended to the key of every segment written.
+	Prefix string
+	// BatchSize is the number of changes buffered before a segment is
+	// written to the bucket.
+	BatchSize int
+}
+
+// Option applies an option to the provided configuration.
+type Option func(*Options)
+
+// WithPrefix configures the prefix of the segment keys.
+func WithPrefix(prefix string) Option {
+	return func(o *Options) {
+		o.Prefix = prefix
+	}
+}
+
+// WithBatchSize configures the number of changes buffered before a segment
+// is written to the bucket.
+func WithBatchSize(size int) Option {
+	return func(o *Options) {
+		o.BatchSize = size
+	}
+}
+
+// entry represents a single change recorded in a segment.
+type entry struct {
+	Obscured string `json:"obscured"`
+	Original string `json:"original,omitempty"`
+	Removed  bool   `json:"removed,omitempty"`
+}
+
+// Store stores mappings in a local index and persists them to a bucket.
+type Store struct {
+	bucket  Bucket
+	options Options
+
+	mutex   sync.RWMutex
+	index   map[string]url.URL
+	pending []entry
+	next    uint64
+}
+
+// New constructs a store backed by the provided bucket, rebuilding the
+// index from the segments already in the bucket.
+func New(ctx context.Context, bucket Bucket, opts ...Option) (*Store, error) {
+	options := Options{Prefix: "obscurer/", BatchSize: 100}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	s := &Store{
+		bucket:  bucket,
+		options: options,
+		index:   make(map[string]url.URL),
+	}
+	if err := s.restore(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// segments retrieves the keys of all segments in the bucket, in the order
+// they were written.
+func (s *Store) segments(ctx context.Context) ([]string, error) {
+	keys, err := s.bucket.List(ctx, s.options.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// restore replays all of the segments in the bucket into the index.
+func (s *Store) restore(ctx context.Context) error {
+	keys, err := s.segments(ctx)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		data, err := s.bucket.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		var entries []entry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return fmt.Errorf("objectstore: malformed segment %q: %w", key, err)
+		}
+		for _, e := range entries {
+			if err := s.apply(e); err != nil {
+				return err
+			}
+		}
+		seq, err := strconv.ParseUint(strings.TrimPrefix(key, s.options.Prefix), 10, 64)
+		if err == nil && seq >= s.next {
+			s.next = seq + 1
+		}
+	}
+	return nil
+}
+
+// apply applies the provided change to the index.
+func (s *Store) apply(e entry) error {
+	if e.Removed {
+		delete(s.index, e.Obscured)
+		return nil
+	}
+	original, err := url.Parse(e.Original)
+	if err != nil {
+		return err
+	}
+	s.index[e.Obscured] = *original
+	return nil
+}
+
+// record applies the provided change to the index and buffers it, writing
+// a segment to the bucket when the batch is full. The change is rolled back
+// when the segment fails to be written.
+func (s *Store) record(ctx context.Context, e entry) error {
+	previous, existed := s.index[e.Obscured]
+	if err := s.apply(e); err != nil {
+		return err
+	}
+	s.pending = append(s.pending, e)
+	if len(s.pending) < s.options.BatchSize {
+		return nil
+	}
+	if err := s.flush(ctx); err != nil {
+		s.pending = s.pending[:len(s.pending)-1]
+		if existed {
+			s.index[e.Obscured] = previous
+		} else {
+			delete(s.index, e.Obscured)
+		}
+		return err
+	}
+	return nil
+}
+
+// flush writes all buffered changes to the bucket as a new segment.
+func (s *Store) flush(ctx context.Context) error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(s.pending)
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s%020d", s.options.Prefix, s.next)
+	if err := s.bucket.Put(ctx, key, data); err != nil {
+		return err
+	}
+	s.next++
+	s.pending = nil
+	return nil
+}
+
+// Flush writes all buffered changes to the bucket.
+func (s *Store) Flush(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.flush(ctx)
+}
+
+// Close writes all buffered changes to the bucket.
+func (s *Store) Close(ctx context.Context) error {
+	return s.Flush(ctx)
+}
+
+// Compact rewrites the contents of the index as a single segment, and
+// deletes all of the segments that preceded it.
+func (s *Store) Compact(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	keys, err := s.segments(ctx)
+	if err != nil {
+		return err
+	}
+	s.pending = s.pending[:0]
+	for obscured, original := range s.index {
+		s.pending = append(s.pending, entry{Obscured: obscured, Original: original.String()})
+	}
+	if err := s.flush(ctx); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := s.bucket.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Put places the mapping between the provided obscured URL and it's original
+// form into the store. A *obscurer.CollisionError is returned when the
+// obscured URL is already mapped to a URL with a different path.
+func (s *Store) Put(ctx context.Context, obscured, original *url.URL) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if existing, ok := s.index[obscured.Path]; ok {
+		if existing.Path != original.Path {
+			return &obscurer.CollisionError{
+				Obscured: obscured,
+				Existing: &existing,
+				Original: original,
+			}
+		}
+		return nil
+	}
+	return s.record(ctx, entry{Obscured: obscured.Path, Original: original.String()})
+}
+
+// Get retrieves the original form of the provided obscured URL.
+func (s *Store) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	original, ok := s.index[obscured.Path]
+	if !ok {
+		return nil, false, nil
+	}
+	return &original, true, nil
+}
+
+// Remove deletes the entry in the store for the provided obscured URL.
+func (s *Store) Remove(ctx context.Context, obscured *url.URL) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.index[obscured.Path]; !ok {
+		return nil
+	}
+	return s.record(ctx, entry{Obscured: obscured.Path, Removed: true})
+}
+
+// Clear removes all entries in the store, deleting every segment from the
+// bucket.
+func (s *Store) Clear(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	keys, err := s.segments(ctx)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := s.bucket.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	s.index = make(map[string]url.URL)
+	s.pending = nil
+	return nil
+}
+
+// Size computes the size of the store.
+func (s *Store) Size(ctx context.Context) int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.index)
+}
+
+// Load loads the store with the provided mappings.
+func (s *Store) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	for obscured, original := range mappings.Pointers() {
+		if err := s.Put(ctx, obscured, original); err != nil {
+			return err
+		}
+	}
+	return s.Flush(ctx)
+}
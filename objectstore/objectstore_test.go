@@ -0,0 +1,256 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package objectstore_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/objectstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bucket is an in-memory bucket.
+type bucket struct {
+	mutex   sync.Mutex
+	objects map[string][]byte
+	err     error
+}
+
+func newBucket() *bucket {
+	return &bucket{objects: make(map[string][]byte)}
+}
+
+func (b *bucket) Put(ctx context.Context, key string, data []byte) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.err != nil {
+		return b.err
+	}
+	b.objects[key] = data
+	return nil
+}
+
+func (b *bucket) Get(ctx context.Context, key string) ([]byte, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	data, ok := b.objects[key]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return data, nil
+}
+
+func (b *bucket) List(ctx context.Context, prefix string) (keys []string, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for key := range b.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return
+}
+
+func (b *bucket) Delete(ctx context.Context, key string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.objects, key)
+	return nil
+}
+
+// TestStore_Batching tests that changes are written to the bucket once the
+// batch is full.
+func TestStore_Batching(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	b := newBucket()
+	s, err := objectstore.New(ctx, b, objectstore.WithBatchSize(2))
+	require.NoError(t, err)
+
+	// action + assert.
+	require.NoError(t, s.Put(ctx, mustParse("/a"), mustParse("/this/is/the/way")))
+	assert.Len(t, b.objects, 0, "expected the change to be buffered")
+	require.NoError(t, s.Put(ctx, mustParse("/b"), mustParse("/hey/der")))
+	assert.Len(t, b.objects, 1, "expected a segment to be written")
+	assert.Equal(t, 2, s.Size(ctx))
+}
+
+// TestStore_Restore tests that a new store rebuilds its index from the
+// segments in the bucket.
+func TestStore_Restore(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	b := newBucket()
+	s, err := objectstore.New(ctx, b, objectstore.WithBatchSize(1))
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, mustParse("/a"), mustParse("/this/is/the/way")))
+	require.NoError(t, s.Put(ctx, mustParse("/b"), mustParse("/hey/der")))
+	require.NoError(t, s.Remove(ctx, mustParse("/b")))
+
+	// action.
+	restored, err := objectstore.New(ctx, b, objectstore.WithBatchSize(1))
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 1, restored.Size(ctx))
+	original, ok, err := restored.Get(ctx, mustParse("/a"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/this/is/the/way", original.String())
+	_, ok, err = restored.Get(ctx, mustParse("/b"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	require.NoError(t, restored.Put(ctx, mustParse("/c"), mustParse("/c")))
+	assert.Len(t, b.objects, 4, "expected new segments not to overwrite existing ones")
+}
+
+// TestStore_Compact tests that compaction replaces all segments with a
+// single segment.
+func TestStore_Compact(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	b := newBucket()
+	s, err := objectstore.New(ctx, b, objectstore.WithBatchSize(1))
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, mustParse("/a"), mustParse("/this/is/the/way")))
+	require.NoError(t, s.Put(ctx, mustParse("/b"), mustParse("/hey/der")))
+	require.NoError(t, s.Remove(ctx, mustParse("/b")))
+
+	// action.
+	err = s.Compact(ctx)
+
+	// assert.
+	require.NoError(t, err)
+	assert.Len(t, b.objects, 1)
+	restored, err := objectstore.New(ctx, b)
+	require.NoError(t, err)
+	assert.Equal(t, 1, restored.Size(ctx))
+}
+
+// TestStore_Put_Collision tests that placing a mapping for an obscured URL
+// that already maps to a different URL results in a collision error.
+func TestStore_Put_Collision(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s, err := objectstore.New(ctx, newBucket())
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, mustParse("/a"), mustParse("/this/is/the/way")))
+
+	// action.
+	err = s.Put(ctx, mustParse("/a"), mustParse("/hey/der"))
+
+	// assert.
+	assert.True(t, errors.Is(err, obscurer.ErrCollision))
+}
+
+// TestStore_Load tests that loading the store writes the mappings to the
+// bucket.
+func TestStore_Load(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	b := newBucket()
+	s, err := objectstore.New(ctx, b)
+	require.NoError(t, err)
+
+	// action.
+	err = obscurer.LoadAll(ctx, s, map[*url.URL]*url.URL{
+		mustParse("/a"): mustParse("/this/is/the/way"),
+		mustParse("/b"): mustParse("/hey/der"),
+	})
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.Size(ctx))
+	assert.Len(t, b.objects, 1)
+}
+
+// TestStore_Clear tests that clearing the store deletes every segment.
+func TestStore_Clear(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	b := newBucket()
+	s, err := objectstore.New(ctx, b, objectstore.WithBatchSize(1))
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, mustParse("/a"), mustParse("/this/is/the/way")))
+
+	// action.
+	err = s.Clear(ctx)
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 0, s.Size(ctx))
+	assert.Len(t, b.objects, 0)
+}
+
+// TestStore_Flush_Error tests that failures writing to the bucket are
+// returned.
+func TestStore_Flush_Error(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	b := newBucket()
+	s, err := objectstore.New(ctx, b)
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, mustParse("/a"), mustParse("/this/is/the/way")))
+	b.err = errors.New("whoa")
+
+	// action + assert.
+	assert.Equal(t, b.err, s.Close(ctx))
+}
+
+// TestStore_Put_FlushError tests that a change failing to be written is
+// rolled back, while the changes buffered before it are written with the
+// next segment.
+func TestStore_Put_FlushError(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	b := newBucket()
+	s, err := objectstore.New(ctx, b, objectstore.WithBatchSize(2))
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, mustParse("/a"), mustParse("/this/is/the/way")))
+	b.err = errors.New("whoa")
+
+	// action.
+	err = s.Put(ctx, mustParse("/b"), mustParse("/hey/der"))
+
+	// assert.
+	assert.Equal(t, b.err, err)
+	_, ok, err := s.Get(ctx, mustParse("/b"))
+	require.NoError(t, err)
+	assert.False(t, ok, "expected the change to be rolled back")
+	b.err = nil
+	require.NoError(t, s.Put(ctx, mustParse("/c"), mustParse("/whoa")))
+	restored, err := objectstore.New(ctx, b)
+	require.NoError(t, err)
+	assert.Equal(t, 2, restored.Size(ctx))
+	_, ok, err = restored.Get(ctx, mustParse("/a"))
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func mustParse(str string) *url.URL {
+	u, err := url.Parse(str)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
@@ -16,9 +16,10 @@
 package obscurer
 
 import (
+	"context"
 	"crypto/md5"
+	"encoding/hex"
 	"fmt"
-	"hash"
 	"net/url"
 	"strings"
 )
@@ -34,20 +35,104 @@ type Obscurer interface {
 	Obscure(*url.URL) *url.URL
 }
 
+// Reroller represents an obscurer capable of producing alternative obscured
+// forms of a URL, which is used to resolve collisions.
+type Reroller interface {
+	Obscurer
+
+	// Reroll obscures the provided URL for the provided attempt, which
+	// starts at 1. The same URL and attempt always produce the same result.
+	Reroll(url *url.URL, attempt int) *url.URL
+}
+
+// ObscurerOptions represents the configuration options for an obscurer.
+type ObscurerOptions struct {
+	// Normalizer normalizes URLs before they are obscured. When nil, URLs
+	// are obscured as is.
+	Normalizer Normalizer
+	// Key is the secret key used by keyed obscurers.
+	Key []byte
+}
+
+// ObscurerOption applies an option to the provided configuration.
+type ObscurerOption func(*ObscurerOptions)
+
+// WithNormalizer configures the obscurer to normalize URLs with the provided
+// normalizer before obscuring them.
+func WithNormalizer(n Normalizer) ObscurerOption {
+	return func(o *ObscurerOptions) {
+		o.Normalizer = n
+	}
+}
+
+// WithKey configures the secret key used by keyed obscurers.
+func WithKey(key []byte) ObscurerOption {
+	return func(o *ObscurerOptions) {
+		o.Key = key
+	}
+}
+
+// normalize produces a normalized copy of the provided URL.
+func (o ObscurerOptions) normalize(u *url.URL) url.URL {
+	result := *u
+	if o.Normalizer != nil {
+		o.Normalizer(&result)
+	}
+	return result
+}
+
 // md5Obscurer obscures URLs using the MD5 hashing algorithm.
+//
+// md5Obscurer is stateless, and therefore safe for concurrent use.
 type md5Obscurer struct {
-	hash hash.Hash
+	options ObscurerOptions
+}
+
+// NewMD5 constructs an obscurer that obscures URLs using the MD5 hashing
+// algorithm.
+func NewMD5(opts ...ObscurerOption) Obscurer {
+	o := &md5Obscurer{}
+	for _, opt := range opts {
+		opt(&o.options)
+	}
+	return o
 }
 
 // Obscure obscures the provided URL.
 func (o *md5Obscurer) Obscure(url *url.URL) *url.URL {
-	var empty hash.Hash
-	if o.hash == empty {
-		o.hash = md5.New()
-	}
-	obscuredPathBytes := o.hash.Sum([]byte(strings.TrimLeft(url.Path, "/")))
-	obscuredPath := fmt.Sprintf("%x", obscuredPathBytes)
-	result := *url
-	result.Path = "/" + obscuredPath
+	return o.Reroll(url, 0)
+}
+
+// ObscureAll obscures the provided URLs, reusing a single hasher for them.
+func (o *md5Obscurer) ObscureAll(ctx context.Context, urls []*url.URL) (map[*url.URL]*url.URL, error) {
+	h := md5.New()
+	return obscureDigests(ctx, o.options, urls, func(dst, message []byte) []byte {
+		h.Reset()
+		h.Write(message)
+		return h.Sum(dst)
+	})
+}
+
+// Normalize provides the normalized copy of the provided URL.
+func (o *md5Obscurer) Normalize(u *url.URL) *url.URL {
+	result := o.options.normalize(u)
+	return &result
+}
+
+// Reroll obscures the provided URL for the provided attempt.
+func (o *md5Obscurer) Reroll(url *url.URL, attempt int) *url.URL {
+	result := o.options.normalize(url)
+	sum := md5.Sum([]byte(salt(strings.TrimLeft(result.Path, "/"), attempt)))
+	result.Path = "/" + hex.EncodeToString(sum[:])
+	result.RawPath = ""
 	return &result
 }
+
+// salt salts the provided path for the provided attempt. The path is left
+// untouched for the first attempt so that obscured URLs remain stable.
+func salt(path string, attempt int) string {
+	if attempt == 0 {
+		return path
+	}
+	return fmt.Sprintf("%s\x00%d", path, attempt)
+}
@@ -19,6 +19,7 @@ import (
 	"crypto/md5"
 	"fmt"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/freerware/obscurer"
@@ -30,7 +31,7 @@ func TestObscure(t *testing.T) {
 	obscurer := obscurer.Default
 	u := mustParse("http://www.example.com/this/is/the/way/")
 	want := *u
-	obscuredPathBytes := md5.New().Sum([]byte(strings.TrimLeft(u.Path, "/")))
+	obscuredPathBytes := md5.Sum([]byte(strings.TrimLeft(u.Path, "/")))
 	obscuredPath := fmt.Sprintf("%x", obscuredPathBytes)
 	want.Path = "/" + obscuredPath
 
@@ -38,3 +39,81 @@ func TestObscure(t *testing.T) {
 	got := obscurer.Obscure(u)
 	assert.Equal(t, want, *got, "wanted: %s, got: %s", &want, got)
 }
+
+// TestObscure_Concurrent tests that the default obscurer produces the same
+// result when used by many goroutines at once.
+func TestObscure_Concurrent(t *testing.T) {
+	// arrange.
+	obscurer := obscurer.Default
+	u := mustParse("http://www.example.com/this/is/the/way/")
+	want := obscurer.Obscure(u).String()
+	var wg sync.WaitGroup
+	results := make(chan string, 100)
+
+	// action.
+	for i := 0; i < cap(results); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- obscurer.Obscure(u).String()
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	// assert.
+	for got := range results {
+		assert.Equal(t, want, got)
+	}
+}
+
+func BenchmarkObscure(b *testing.B) {
+	obscurer := obscurer.Default
+	u := mustParse("http://www.example.com/this/is/the/way/")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		obscurer.Obscure(u)
+	}
+}
+
+// TestReroll tests that rerolling produces stable results that differ from
+// the obscured URL and from each other.
+func TestReroll(t *testing.T) {
+	obscurers := map[string]obscurer.Obscurer{
+		"MD5":     obscurer.NewMD5(),
+		"SipHash": obscurer.NewSipHash(sipHashKey),
+	}
+	for name, o := range obscurers {
+		t.Run(name, func(t *testing.T) {
+			// arrange.
+			reroller, ok := o.(obscurer.Reroller)
+			if !assert.True(t, ok, "expected obscurer to support rerolling") {
+				return
+			}
+			u := mustParse("http://www.example.com/this/is/the/way")
+
+			// action.
+			obscured := reroller.Obscure(u)
+			first := reroller.Reroll(u, 1)
+			second := reroller.Reroll(u, 2)
+
+			// assert.
+			assert.NotEqual(t, obscured.String(), first.String())
+			assert.NotEqual(t, first.String(), second.String())
+			assert.Equal(t, first.String(), reroller.Reroll(u, 1).String())
+		})
+	}
+}
+
+func BenchmarkObscure_Parallel(b *testing.B) {
+	obscurer := obscurer.Default
+	u := mustParse("http://www.example.com/this/is/the/way/")
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			obscurer.Obscure(u)
+		}
+	})
+}
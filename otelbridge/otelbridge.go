@@ -0,0 +1,150 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package otelbridge adapts the meters and tracers of the OpenTelemetry API
+// to the Meter of the otelmetric package and the Tracer of the oteltrace
+// package, so that stores and observers can be instrumented with the
+// OpenTelemetry SDK of the application:
+//
+//	meter := otelbridge.Meter(otel.Meter("github.com/freerware/obscurer"))
+//	tracer := otelbridge.Tracer(otel.Tracer("github.com/freerware/obscurer"))
+//	s, err := otelmetric.NewStore(oteltrace.NewStore(obscurer.DefaultStore, tracer), meter)
+//
+// The package is a module of its own, so that applications that don't use
+// OpenTelemetry don't depend on its API.
+package otelbridge
+
+import (
+	"context"
+
+	"github.com/freerware/obscurer/otelmetric"
+	"github.com/freerware/obscurer/oteltrace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// meter adapts an OpenTelemetry meter to an otelmetric.Meter.
+type meter struct {
+	meter metric.Meter
+}
+
+// Meter adapts the provided OpenTelemetry meter to an otelmetric.Meter,
+// which creates its counters as Int64Counters and its histograms as
+// Float64Histograms.
+func Meter(m metric.Meter) otelmetric.Meter {
+	return meter{meter: m}
+}
+
+// Counter creates an Int64Counter with the provided name, description, and
+// unit.
+func (m meter) Counter(name, description, unit string) (otelmetric.Counter, error) {
+	c, err := m.meter.Int64Counter(name, metric.WithDescription(description), metric.WithUnit(unit))
+	if err != nil {
+		return nil, err
+	}
+	return counter{counter: c}, nil
+}
+
+// Histogram creates a Float64Histogram with the provided name, description,
+// and unit.
+func (m meter) Histogram(name, description, unit string) (otelmetric.Histogram, error) {
+	h, err := m.meter.Float64Histogram(name, metric.WithDescription(description), metric.WithUnit(unit))
+	if err != nil {
+		return nil, err
+	}
+	return histogram{histogram: h}, nil
+}
+
+// counter adapts an Int64Counter to an otelmetric.Counter.
+type counter struct {
+	counter metric.Int64Counter
+}
+
+// Add records the provided increment.
+func (c counter) Add(ctx context.Context, n int64, attrs ...otelmetric.Attribute) {
+	c.counter.Add(ctx, n, metric.WithAttributes(metricAttributes(attrs)...))
+}
+
+// histogram adapts a Float64Histogram to an otelmetric.Histogram.
+type histogram struct {
+	histogram metric.Float64Histogram
+}
+
+// Record records the provided value.
+func (h histogram) Record(ctx context.Context, value float64, attrs ...otelmetric.Attribute) {
+	h.histogram.Record(ctx, value, metric.WithAttributes(metricAttributes(attrs)...))
+}
+
+// metricAttributes converts the provided attributes to string attributes.
+func metricAttributes(attrs []otelmetric.Attribute) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, len(attrs))
+	for i, attr := range attrs {
+		kvs[i] = attribute.String(attr.Key, attr.Value)
+	}
+	return kvs
+}
+
+// tracer adapts an OpenTelemetry tracer to an oteltrace.Tracer.
+type tracer struct {
+	tracer trace.Tracer
+}
+
+// Tracer adapts the provided OpenTelemetry tracer to an oteltrace.Tracer,
+// which starts the spans of store operations as client spans.
+func Tracer(t trace.Tracer) oteltrace.Tracer {
+	return tracer{tracer: t}
+}
+
+// Start starts a client span with the provided name and attributes as a
+// child of the span within the provided context.
+func (t tracer) Start(ctx context.Context, name string, attrs ...oteltrace.Attribute) (context.Context, oteltrace.Span) {
+	ctx, s := t.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(traceAttributes(attrs)...))
+	return ctx, span{span: s}
+}
+
+// span adapts an OpenTelemetry span to an oteltrace.Span.
+type span struct {
+	span trace.Span
+}
+
+// SetAttributes attaches the provided attributes to the span.
+func (s span) SetAttributes(attrs ...oteltrace.Attribute) {
+	s.span.SetAttributes(traceAttributes(attrs)...)
+}
+
+// RecordError records the provided error on the span, and marks the span
+// as failed.
+func (s span) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+// End completes the span.
+func (s span) End() {
+	s.span.End()
+}
+
+// traceAttributes converts the provided attributes to string attributes.
+func traceAttributes(attrs []oteltrace.Attribute) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, len(attrs))
+	for i, attr := range attrs {
+		kvs[i] = attribute.String(attr.Key, attr.Value)
+	}
+	return kvs
+}
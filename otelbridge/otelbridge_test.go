@@ -0,0 +1,127 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otelbridge_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/otelbridge"
+	"github.com/freerware/obscurer/otelmetric"
+	"github.com/freerware/obscurer/oteltrace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func mustParse(str string) *url.URL {
+	u, err := url.Parse(str)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// failing is a store whose removals fail.
+type failing struct {
+	obscurer.Store
+}
+
+func (s failing) Remove(ctx context.Context, obscured *url.URL) error {
+	return errors.New("whoa")
+}
+
+// TestMeter tests that the instruments of the store are recorded with the
+// meter of the OpenTelemetry SDK.
+func TestMeter(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	meter := otelbridge.Meter(provider.Meter("test"))
+	s, err := otelmetric.NewStore(obscurer.NewMemoryStore(), meter, otelmetric.WithAttribute("store", "memory"))
+	require.NoError(t, err)
+
+	// action.
+	s.Get(ctx, mustParse("/a"))
+	s.Get(ctx, mustParse("/a"))
+
+	// assert.
+	var data metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(ctx, &data))
+	require.Len(t, data.ScopeMetrics, 1)
+	instruments := map[string]metricdata.Metrics{}
+	for _, m := range data.ScopeMetrics[0].Metrics {
+		instruments[m.Name] = m
+	}
+	lookups, ok := instruments["obscurer.store.lookups"].Data.(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Len(t, lookups.DataPoints, 1)
+	assert.Equal(t, int64(2), lookups.DataPoints[0].Value)
+	assert.Equal(t, attribute.NewSet(
+		attribute.String("store", "memory"),
+		attribute.String("obscurer.result", "miss"),
+	), lookups.DataPoints[0].Attributes)
+	assert.Equal(t, "{lookup}", instruments["obscurer.store.lookups"].Unit)
+	duration, ok := instruments["obscurer.store.duration"].Data.(metricdata.Histogram[float64])
+	require.True(t, ok)
+	require.Len(t, duration.DataPoints, 1)
+	assert.Equal(t, uint64(2), duration.DataPoints[0].Count)
+	assert.Equal(t, "s", instruments["obscurer.store.duration"].Unit)
+}
+
+// TestTracer tests that the spans of the store are recorded with the tracer
+// of the OpenTelemetry SDK, nested beneath the span of the context, and
+// that failures are recorded on them.
+func TestTracer(t *testing.T) {
+	// arrange.
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "request")
+	tracer := otelbridge.Tracer(provider.Tracer("test"))
+	s := oteltrace.NewStore(failing{Store: obscurer.NewMemoryStore()}, tracer)
+
+	// action.
+	s.Get(ctx, mustParse("/a"))
+	err := s.Remove(ctx, mustParse("/a"))
+	parent.End()
+
+	// assert.
+	require.Error(t, err)
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	get, remove := spans[0], spans[1]
+	assert.Equal(t, "obscurer.store.get", get.Name())
+	assert.Equal(t, trace.SpanKindClient, get.SpanKind())
+	assert.Equal(t, parent.SpanContext().SpanID(), get.Parent().SpanID())
+	assert.Contains(t, get.Attributes(), attribute.String("obscurer.operation", "get"))
+	assert.Contains(t, get.Attributes(), attribute.String("obscurer.result", "miss"))
+	assert.Equal(t, codes.Unset, get.Status().Code)
+	assert.Equal(t, "obscurer.store.remove", remove.Name())
+	assert.Equal(t, codes.Error, remove.Status().Code)
+	assert.Equal(t, "whoa", remove.Status().Description)
+	require.Len(t, remove.Events(), 1)
+	assert.Equal(t, "exception", remove.Events()[0].Name)
+}
@@ -0,0 +1,305 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package otelmetric provides an obscurer.Store that records OpenTelemetry
+// metrics for the operations of another store, so that deployments
+// standardized on OTLP can monitor the hit ratio, latency, and errors of
+// their store, along with an obscurer.HeaderObserver recording the outcome
+// of obscuring response headers, an obscurer.CanaryObserver recording the
+// outcome of resolutions routed through the candidate of a canary, and an
+// obscurer.SamplingObserver recording the overhead of the requests whose
+// bodies are sampled for rewriting, and of those that aren't.
+//
+// The following instruments are recorded:
+//
+//	obscurer.store.lookups   counter    lookups, by 'obscurer.result' (hit or miss)
+//	obscurer.store.duration  histogram  seconds, by 'obscurer.operation'
+//	obscurer.store.errors    counter    errors, by 'obscurer.operation' and 'error.type'
+//	obscurer.headers         counter    headers, by 'obscurer.header' and 'obscurer.outcome'
+//	                                    (rewritten, skipped, or failed)
+//	obscurer.canary          counter    resolutions, by 'obscurer.outcome'
+//	                                    (match, mismatch, or miss)
+//	obscurer.overhead        histogram  seconds resolving and rewriting URLs,
+//	                                    by 'obscurer.sampled' (true or false)
+//
+// The hit ratio is the rate of lookups with a 'hit' result over the rate of
+// all lookups.
+//
+// The store depends on the small Meter interface rather than the
+// OpenTelemetry API, so that this module doesn't depend on it; the
+// otelbridge module adapts a metric.Meter to it.
+package otelmetric
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/freerware/obscurer"
+)
+
+// Attribute represents a key-value pair describing a measurement.
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// Counter represents an instrument recording monotonically increasing
+// values.
+type Counter interface {
+	// Add records the provided increment.
+	Add(ctx context.Context, n int64, attrs ...Attribute)
+}
+
+// Histogram represents an instrument recording a distribution of values.
+type Histogram interface {
+	// Record records the provided value.
+	Record(ctx context.Context, value float64, attrs ...Attribute)
+}
+
+// Meter represents the subset of an OpenTelemetry meter needed by the
+// store.
+type Meter interface {
+	// Counter creates a counter with the provided name, description, and
+	// unit.
+	Counter(name, description, unit string) (Counter, error)
+	// Histogram creates a histogram with the provided name, description,
+	// and unit.
+	Histogram(name, description, unit string) (Histogram, error)
+}
+
+// Options represents the configuration options for the store.
+type Options struct {
+	// Attributes are attached to every measurement, such as the name of
+	// the underlying store.
+	Attributes []Attribute
+}
+
+// Option applies an option to the provided configuration.
+type Option func(*Options)
+
+// WithAttribute configures the provided attribute to be attached to every
+// measurement.
+func WithAttribute(key, value string) Option {
+	return func(o *Options) {
+		o.Attributes = append(o.Attributes, Attribute{Key: key, Value: value})
+	}
+}
+
+// store records metrics for the operations of the underlying store.
+type store struct {
+	store    obscurer.Store
+	lookups  Counter
+	duration Histogram
+	errors   Counter
+	options  Options
+}
+
+// NewStore constructs a store recording metrics with the provided meter for
+// the operations of the provided store.
+func NewStore(s obscurer.Store, meter Meter, opts ...Option) (obscurer.Store, error) {
+	decorate, err := NewDecorator(meter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return decorate(s), nil
+}
+
+// NewDecorator constructs a function decorating stores to record metrics
+// with the provided meter for their operations, creating the instruments
+// once for every decorated store.
+func NewDecorator(meter Meter, opts ...Option) (func(obscurer.Store) obscurer.Store, error) {
+	var m store
+	for _, opt := range opts {
+		opt(&m.options)
+	}
+	var err error
+	if m.lookups, err = meter.Counter(
+		"obscurer.store.lookups",
+		"The number of lookups of obscured URLs.",
+		"{lookup}"); err != nil {
+		return nil, err
+	}
+	if m.duration, err = meter.Histogram(
+		"obscurer.store.duration",
+		"The duration of store operations.",
+		"s"); err != nil {
+		return nil, err
+	}
+	if m.errors, err = meter.Counter(
+		"obscurer.store.errors",
+		"The number of failed store operations.",
+		"{error}"); err != nil {
+		return nil, err
+	}
+	return func(s obscurer.Store) obscurer.Store {
+		decorated := m
+		decorated.store = s
+		return &decorated
+	}, nil
+}
+
+// NewHeaderObserver constructs a header observer counting the outcomes of
+// obscuring each header with the provided meter.
+func NewHeaderObserver(meter Meter, opts ...Option) (obscurer.HeaderObserver, error) {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	headers, err := meter.Counter(
+		"obscurer.headers",
+		"The number of response headers obscured, by outcome.",
+		"{header}")
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, header string, outcome obscurer.HeaderOutcome) {
+		attrs := append([]Attribute{}, options.Attributes...)
+		attrs = append(attrs,
+			Attribute{Key: "obscurer.header", Value: header},
+			Attribute{Key: "obscurer.outcome", Value: outcome.String()})
+		headers.Add(ctx, 1, attrs...)
+	}, nil
+}
+
+// NewCanaryObserver constructs a canary observer counting the outcomes of
+// the resolutions routed through the candidate of a canary with the
+// provided meter.
+func NewCanaryObserver(meter Meter, opts ...Option) (obscurer.CanaryObserver, error) {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	resolutions, err := meter.Counter(
+		"obscurer.canary",
+		"The number of resolutions routed through the candidate of a canary, by outcome.",
+		"{resolution}")
+	if err != nil {
+		return nil, err
+	}
+	return func(obscured *url.URL, outcome obscurer.CanaryOutcome) {
+		attrs := append([]Attribute{}, options.Attributes...)
+		attrs = append(attrs, Attribute{Key: "obscurer.outcome", Value: outcome.String()})
+		resolutions.Add(context.Background(), 1, attrs...)
+	}, nil
+}
+
+// NewSamplingObserver constructs a sampling observer recording the overhead
+// of each request whose body would be rewritten with the provided meter, by
+// whether it was sampled.
+func NewSamplingObserver(meter Meter, opts ...Option) (obscurer.SamplingObserver, error) {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	overhead, err := meter.Histogram(
+		"obscurer.overhead",
+		"The time spent resolving and rewriting URLs for requests whose body would be rewritten, by whether they were sampled.",
+		"s")
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, sampled bool, d time.Duration) {
+		attrs := append([]Attribute{}, options.Attributes...)
+		attrs = append(attrs, Attribute{Key: "obscurer.sampled", Value: strconv.FormatBool(sampled)})
+		overhead.Record(ctx, d.Seconds(), attrs...)
+	}, nil
+}
+
+// attributes provides the configured attributes along with the provided
+// attributes.
+func (s *store) attributes(attrs ...Attribute) []Attribute {
+	return append(append([]Attribute{}, s.options.Attributes...), attrs...)
+}
+
+// record records the duration of the provided operation, which started at
+// the provided time, and its error if any.
+func (s *store) record(ctx context.Context, operation string, start time.Time, err error) {
+	op := Attribute{Key: "obscurer.operation", Value: operation}
+	s.duration.Record(ctx, time.Since(start).Seconds(), s.attributes(op)...)
+	if err == nil {
+		return
+	}
+	errorType := "error"
+	if errors.Is(err, obscurer.ErrCollision) {
+		errorType = "collision"
+	}
+	s.errors.Add(ctx, 1, s.attributes(op, Attribute{Key: "error.type", Value: errorType})...)
+}
+
+// Put places the mapping into the underlying store.
+func (s *store) Put(ctx context.Context, obscured, original *url.URL) error {
+	start := time.Now()
+	err := s.store.Put(ctx, obscured, original)
+	s.record(ctx, "put", start, err)
+	return err
+}
+
+// Get retrieves the original form of the provided obscured URL from the
+// underlying store, recording whether it was a hit or a miss. Failed lookups
+// are recorded as errors instead.
+func (s *store) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	start := time.Now()
+	original, ok, err := s.store.Get(ctx, obscured)
+	s.record(ctx, "get", start, err)
+	if err != nil {
+		return original, ok, err
+	}
+	result := "miss"
+	if ok {
+		result = "hit"
+	}
+	s.lookups.Add(ctx, 1, s.attributes(Attribute{Key: "obscurer.result", Value: result})...)
+	return original, ok, nil
+}
+
+// Remove deletes the entry from the underlying store.
+func (s *store) Remove(ctx context.Context, obscured *url.URL) error {
+	start := time.Now()
+	err := s.store.Remove(ctx, obscured)
+	s.record(ctx, "remove", start, err)
+	return err
+}
+
+// Clear removes all entries from the underlying store.
+func (s *store) Clear(ctx context.Context) error {
+	start := time.Now()
+	err := s.store.Clear(ctx)
+	s.record(ctx, "clear", start, err)
+	return err
+}
+
+// Size computes the size of the underlying store.
+func (s *store) Size(ctx context.Context) int {
+	start := time.Now()
+	size := s.store.Size(ctx)
+	s.record(ctx, "size", start, nil)
+	return size
+}
+
+// Load loads the underlying store.
+func (s *store) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	start := time.Now()
+	err := s.store.Load(ctx, mappings)
+	s.record(ctx, "load", start, err)
+	return err
+}
+
+// Ping verifies the backend of the measured store is reachable.
+func (s *store) Ping(ctx context.Context) error {
+	return obscurer.Ping(ctx, s.store)
+}
@@ -0,0 +1,310 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otelmetric_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/internal/mock"
+	"github.com/freerware/obscurer/otelmetric"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// meter is an in-memory meter, which records measurements keyed by the
+// instrument name and attributes.
+type meter struct {
+	mutex        sync.Mutex
+	counters     map[string]int64
+	observations map[string]int
+	err          error
+}
+
+func newMeter() *meter {
+	return &meter{counters: make(map[string]int64), observations: make(map[string]int)}
+}
+
+func key(name string, attrs []otelmetric.Attribute) string {
+	parts := []string{}
+	for _, attr := range attrs {
+		parts = append(parts, attr.Key+"="+attr.Value)
+	}
+	sort.Strings(parts)
+	return name + "{" + strings.Join(parts, ",") + "}"
+}
+
+type instrument struct {
+	name  string
+	meter *meter
+}
+
+func (i instrument) Add(ctx context.Context, n int64, attrs ...otelmetric.Attribute) {
+	i.meter.mutex.Lock()
+	defer i.meter.mutex.Unlock()
+	i.meter.counters[key(i.name, attrs)] += n
+}
+
+func (i instrument) Record(ctx context.Context, value float64, attrs ...otelmetric.Attribute) {
+	i.meter.mutex.Lock()
+	defer i.meter.mutex.Unlock()
+	i.meter.observations[key(i.name, attrs)]++
+}
+
+func (m *meter) Counter(name, description, unit string) (otelmetric.Counter, error) {
+	return instrument{name: name, meter: m}, m.err
+}
+
+func (m *meter) Histogram(name, description, unit string) (otelmetric.Histogram, error) {
+	return instrument{name: name, meter: m}, m.err
+}
+
+// TestStore_Get tests that lookups are counted by result.
+func TestStore_Get(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	underlying, m := mock.NewStore(ctrl), newMeter()
+	underlying.EXPECT().Get(ctx, mustParse("/a")).Return(mustParse("/this/is/the/way"), true, nil).Times(3)
+	underlying.EXPECT().Get(ctx, mustParse("/b")).Return(nil, false, nil)
+	s, err := otelmetric.NewStore(underlying, m, otelmetric.WithAttribute("obscurer.store", "memory"))
+	require.NoError(t, err)
+
+	// action.
+	for i := 0; i < 3; i++ {
+		s.Get(ctx, mustParse("/a"))
+	}
+	s.Get(ctx, mustParse("/b"))
+
+	// assert.
+	assert.Equal(t, map[string]int64{
+		"obscurer.store.lookups{obscurer.result=hit,obscurer.store=memory}":  3,
+		"obscurer.store.lookups{obscurer.result=miss,obscurer.store=memory}": 1,
+	}, m.counters)
+	assert.Equal(t, map[string]int{
+		"obscurer.store.duration{obscurer.operation=get,obscurer.store=memory}": 4,
+	}, m.observations)
+}
+
+// TestStore_Errors tests that failed operations are counted by operation
+// and error type.
+func TestStore_Errors(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	underlying, m := mock.NewStore(ctrl), newMeter()
+	underlying.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(&obscurer.CollisionError{})
+	underlying.EXPECT().Remove(gomock.Any(), gomock.Any()).Return(errors.New("whoa"))
+	underlying.EXPECT().Clear(gomock.Any()).Return(nil)
+	underlying.EXPECT().Load(gomock.Any(), gomock.Any()).Return(nil)
+	underlying.EXPECT().Size(gomock.Any()).Return(0)
+	underlying.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, false, errors.New("whoa"))
+	s, err := otelmetric.NewStore(underlying, m)
+	require.NoError(t, err)
+
+	// action.
+	s.Put(ctx, mustParse("/a"), mustParse("/this/is/the/way"))
+	s.Remove(ctx, mustParse("/a"))
+	s.Clear(ctx)
+	s.Load(ctx, obscurer.Mappings{})
+	s.Size(ctx)
+	s.Get(ctx, mustParse("/a"))
+
+	// assert.
+	assert.Equal(t, map[string]int64{
+		"obscurer.store.errors{error.type=collision,obscurer.operation=put}": 1,
+		"obscurer.store.errors{error.type=error,obscurer.operation=remove}":  1,
+		"obscurer.store.errors{error.type=error,obscurer.operation=get}":     1,
+	}, m.counters)
+	assert.Len(t, m.observations, 6)
+}
+
+// TestNewStore_Error tests that failing to create instruments results in an
+// error.
+func TestNewStore_Error(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m := newMeter()
+	m.err = errors.New("whoa")
+
+	// action.
+	_, err := otelmetric.NewStore(mock.NewStore(ctrl), m)
+
+	// assert.
+	assert.Equal(t, m.err, err)
+}
+
+// TestNewHeaderObserver tests that header outcomes are counted by header
+// and outcome.
+func TestNewHeaderObserver(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	m := newMeter()
+	observe, err := otelmetric.NewHeaderObserver(m, otelmetric.WithAttribute("service", "api"))
+	require.NoError(t, err)
+
+	// action.
+	observe(ctx, "Location", obscurer.HeaderRewritten)
+	observe(ctx, "Location", obscurer.HeaderRewritten)
+	observe(ctx, "Link", obscurer.HeaderSkipped)
+	observe(ctx, "Content-Location", obscurer.HeaderFailed)
+
+	// assert.
+	assert.Equal(t, map[string]int64{
+		"obscurer.headers{obscurer.header=Location,obscurer.outcome=rewritten,service=api}":      2,
+		"obscurer.headers{obscurer.header=Link,obscurer.outcome=skipped,service=api}":            1,
+		"obscurer.headers{obscurer.header=Content-Location,obscurer.outcome=failed,service=api}": 1,
+	}, m.counters)
+}
+
+// TestNewHeaderObserver_Error tests that failing to create the instrument
+// results in an error.
+func TestNewHeaderObserver_Error(t *testing.T) {
+	// arrange.
+	m := newMeter()
+	m.err = errors.New("whoa")
+
+	// action.
+	_, err := otelmetric.NewHeaderObserver(m)
+
+	// assert.
+	assert.Equal(t, m.err, err)
+}
+
+// TestNewCanaryObserver tests that canary outcomes are counted by outcome.
+func TestNewCanaryObserver(t *testing.T) {
+	// arrange.
+	m := newMeter()
+	observe, err := otelmetric.NewCanaryObserver(m)
+	require.NoError(t, err)
+
+	// action.
+	observe(mustParse("/a"), obscurer.CanaryMatch)
+	observe(mustParse("/b"), obscurer.CanaryMatch)
+	observe(mustParse("/c"), obscurer.CanaryMiss)
+
+	// assert.
+	assert.Equal(t, map[string]int64{
+		"obscurer.canary{obscurer.outcome=match}": 2,
+		"obscurer.canary{obscurer.outcome=miss}":  1,
+	}, m.counters)
+}
+
+// TestNewSamplingObserver tests that the overhead of requests is recorded
+// by whether they were sampled.
+func TestNewSamplingObserver(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	m := newMeter()
+	observe, err := otelmetric.NewSamplingObserver(m, otelmetric.WithAttribute("service", "mando"))
+	require.NoError(t, err)
+
+	// action.
+	observe(ctx, true, time.Millisecond)
+	observe(ctx, true, time.Millisecond)
+	observe(ctx, false, time.Microsecond)
+
+	// assert.
+	assert.Equal(t, map[string]int{
+		"obscurer.overhead{obscurer.sampled=true,service=mando}":  2,
+		"obscurer.overhead{obscurer.sampled=false,service=mando}": 1,
+	}, m.observations)
+}
+
+// TestNewDecorator tests that every decorated store records its operations
+// with the same instruments.
+func TestNewDecorator(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	first, second, m := mock.NewStore(ctrl), mock.NewStore(ctrl), newMeter()
+	first.EXPECT().Get(ctx, gomock.Any()).Return(mustParse("/this/is/the/way"), true, nil)
+	second.EXPECT().Get(ctx, gomock.Any()).Return(nil, false, nil)
+	decorate, err := otelmetric.NewDecorator(m)
+	require.NoError(t, err)
+
+	// action.
+	decorate(first).Get(ctx, mustParse("/a"))
+	decorate(second).Get(ctx, mustParse("/b"))
+
+	// assert.
+	assert.Equal(t, map[string]int64{
+		"obscurer.store.lookups{obscurer.result=hit}":  1,
+		"obscurer.store.lookups{obscurer.result=miss}": 1,
+	}, m.counters)
+}
+
+// TestNewDecorator_Error tests that failures to create the instruments
+// result in an error.
+func TestNewDecorator_Error(t *testing.T) {
+	// arrange.
+	m := newMeter()
+	m.err = errors.New("whoa")
+
+	// action.
+	decorate, err := otelmetric.NewDecorator(m)
+
+	// assert.
+	assert.Equal(t, m.err, err)
+	assert.Nil(t, decorate)
+}
+
+func mustParse(str string) *url.URL {
+	u, err := url.Parse(str)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// pinging is a store whose backend is reachable unless err is set.
+type pinging struct {
+	obscurer.Store
+	err error
+}
+
+func (s *pinging) Ping(ctx context.Context) error {
+	return s.err
+}
+
+// TestStore_Ping tests that pings are forwarded to the underlying store.
+func TestStore_Ping(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	underlying := &pinging{Store: mock.NewStore(ctrl), err: errors.New("whoa")}
+	s, err := otelmetric.NewStore(underlying, newMeter())
+	require.NoError(t, err)
+
+	// action.
+	err = s.(obscurer.Pinger).Ping(ctx)
+
+	// assert.
+	assert.Equal(t, underlying.err, err)
+}
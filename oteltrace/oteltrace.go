@@ -0,0 +1,178 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package oteltrace provides an obscurer.Store that records an OpenTelemetry
+// span for every operation of another store, so that store latency appears
+// nested under the trace of the request being handled.
+//
+// The handler passes the context of each request to its store, and every
+// store of this module passes that context on to its client. The span of
+// each operation is started from that context, and the context carrying the
+// span is handed to the underlying store, so the spans of instrumented
+// Redis, SQL, or DynamoDB clients nest beneath it in turn.
+//
+// Spans are named 'obscurer.store.<operation>', carry an
+// 'obscurer.operation' attribute, and lookups additionally carry an
+// 'obscurer.result' attribute (hit or miss). Failed operations record their
+// error on the span.
+//
+// The store depends on the small Tracer interface rather than the
+// OpenTelemetry API, so that this module doesn't depend on it; the
+// otelbridge module adapts a trace.Tracer to it.
+package oteltrace
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/freerware/obscurer"
+)
+
+// Attribute represents a key-value pair describing a span.
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// Span represents a single traced operation.
+type Span interface {
+	// SetAttributes attaches the provided attributes to the span.
+	SetAttributes(attrs ...Attribute)
+	// RecordError records the provided error as the cause of the failure of
+	// the operation.
+	RecordError(err error)
+	// End completes the span.
+	End()
+}
+
+// Tracer represents the subset of an OpenTelemetry tracer needed by the
+// store.
+type Tracer interface {
+	// Start starts a span with the provided name and attributes as a child
+	// of the span within the provided context, providing a context carrying
+	// the new span.
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Options represents the configuration options for the store.
+type Options struct {
+	// Attributes are attached to every span, such as the name of the
+	// underlying store.
+	Attributes []Attribute
+}
+
+// Option applies an option to the provided configuration.
+type Option func(*Options)
+
+// WithAttribute configures the provided attribute to be attached to every
+// span.
+func WithAttribute(key, value string) Option {
+	return func(o *Options) {
+		o.Attributes = append(o.Attributes, Attribute{Key: key, Value: value})
+	}
+}
+
+// store records spans for the operations of the underlying store.
+type store struct {
+	store   obscurer.Store
+	tracer  Tracer
+	options Options
+}
+
+// NewStore constructs a store recording spans with the provided tracer for
+// the operations of the provided store.
+func NewStore(s obscurer.Store, tracer Tracer, opts ...Option) obscurer.Store {
+	t := &store{store: s, tracer: tracer}
+	for _, opt := range opts {
+		opt(&t.options)
+	}
+	return t
+}
+
+// start starts the span of the provided operation.
+func (s *store) start(ctx context.Context, operation string) (context.Context, Span) {
+	attrs := append([]Attribute{}, s.options.Attributes...)
+	attrs = append(attrs, Attribute{Key: "obscurer.operation", Value: operation})
+	return s.tracer.Start(ctx, "obscurer.store."+operation, attrs...)
+}
+
+// end records the error of the operation if any, and ends its span.
+func end(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// Put places the mapping into the underlying store.
+func (s *store) Put(ctx context.Context, obscured, original *url.URL) error {
+	ctx, span := s.start(ctx, "put")
+	err := s.store.Put(ctx, obscured, original)
+	end(span, err)
+	return err
+}
+
+// Get retrieves the original form of the provided obscured URL from the
+// underlying store.
+func (s *store) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	ctx, span := s.start(ctx, "get")
+	original, ok, err := s.store.Get(ctx, obscured)
+	if err == nil {
+		result := "miss"
+		if ok {
+			result = "hit"
+		}
+		span.SetAttributes(Attribute{Key: "obscurer.result", Value: result})
+	}
+	end(span, err)
+	return original, ok, err
+}
+
+// Remove deletes the entry from the underlying store.
+func (s *store) Remove(ctx context.Context, obscured *url.URL) error {
+	ctx, span := s.start(ctx, "remove")
+	err := s.store.Remove(ctx, obscured)
+	end(span, err)
+	return err
+}
+
+// Clear removes all entries from the underlying store.
+func (s *store) Clear(ctx context.Context) error {
+	ctx, span := s.start(ctx, "clear")
+	err := s.store.Clear(ctx)
+	end(span, err)
+	return err
+}
+
+// Size computes the size of the underlying store.
+func (s *store) Size(ctx context.Context) int {
+	ctx, span := s.start(ctx, "size")
+	size := s.store.Size(ctx)
+	end(span, nil)
+	return size
+}
+
+// Load loads the underlying store.
+func (s *store) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	ctx, span := s.start(ctx, "load")
+	err := s.store.Load(ctx, mappings)
+	end(span, err)
+	return err
+}
+
+// Ping verifies the backend of the traced store is reachable.
+func (s *store) Ping(ctx context.Context) error {
+	return obscurer.Ping(ctx, s.store)
+}
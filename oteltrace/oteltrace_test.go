@@ -0,0 +1,206 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package oteltrace_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/internal/mock"
+	"github.com/freerware/obscurer/oteltrace"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type spanKey struct{}
+
+// span is an in-memory span.
+type span struct {
+	name   string
+	parent *span
+	attrs  map[string]string
+	err    error
+	ended  bool
+}
+
+func (s *span) SetAttributes(attrs ...oteltrace.Attribute) {
+	for _, attr := range attrs {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *span) RecordError(err error) {
+	s.err = err
+}
+
+func (s *span) End() {
+	s.ended = true
+}
+
+// tracer is an in-memory tracer, which records every span started.
+type tracer struct {
+	mutex sync.Mutex
+	spans []*span
+}
+
+func (t *tracer) Start(ctx context.Context, name string, attrs ...oteltrace.Attribute) (context.Context, oteltrace.Span) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	parent, _ := ctx.Value(spanKey{}).(*span)
+	s := &span{name: name, parent: parent, attrs: make(map[string]string)}
+	s.SetAttributes(attrs...)
+	t.spans = append(t.spans, s)
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// spanOf provides the span within the provided context.
+func spanOf(ctx context.Context) *span {
+	s, _ := ctx.Value(spanKey{}).(*span)
+	return s
+}
+
+// TestStore_Get tests that lookups are traced with their result, and that
+// the underlying store receives the context carrying the span.
+func TestStore_Get(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	tr := &tracer{}
+	parent := &span{name: "request", attrs: make(map[string]string)}
+	ctx := context.WithValue(context.Background(), spanKey{}, parent)
+	underlying := mock.NewStore(ctrl)
+	var received context.Context
+	underlying.EXPECT().Get(gomock.Any(), mustParse("/a")).DoAndReturn(
+		func(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+			received = ctx
+			return mustParse("/this/is/the/way"), true, nil
+		})
+	s := oteltrace.NewStore(underlying, tr, oteltrace.WithAttribute("obscurer.store", "memory"))
+
+	// action.
+	s.Get(ctx, mustParse("/a"))
+
+	// assert.
+	require.Len(t, tr.spans, 1)
+	got := tr.spans[0]
+	assert.Equal(t, "obscurer.store.get", got.name)
+	assert.Equal(t, parent, got.parent)
+	assert.Equal(t, got, spanOf(received))
+	assert.True(t, got.ended)
+	assert.Equal(t, map[string]string{
+		"obscurer.operation": "get",
+		"obscurer.result":    "hit",
+		"obscurer.store":     "memory",
+	}, got.attrs)
+}
+
+// TestStore_Errors tests that failed operations record their error.
+func TestStore_Errors(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	tr := &tracer{}
+	underlying := mock.NewStore(ctrl)
+	expectedErr := errors.New("whoa")
+	underlying.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(expectedErr)
+	underlying.EXPECT().Remove(gomock.Any(), gomock.Any()).Return(nil)
+	s := oteltrace.NewStore(underlying, tr)
+
+	// action.
+	s.Put(ctx, mustParse("/a"), mustParse("/this/is/the/way"))
+	s.Remove(ctx, mustParse("/a"))
+
+	// assert.
+	require.Len(t, tr.spans, 2)
+	assert.Equal(t, "obscurer.store.put", tr.spans[0].name)
+	assert.Equal(t, expectedErr, tr.spans[0].err)
+	assert.Equal(t, "obscurer.store.remove", tr.spans[1].name)
+	assert.NoError(t, tr.spans[1].err)
+}
+
+// TestStore_Handler tests that the spans of store operations performed by
+// the handler nest beneath the span of the request.
+func TestStore_Handler(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	tr := &tracer{}
+	parent := &span{name: "request", attrs: make(map[string]string)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/this/is/the/way", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Location", "/hey/der")
+		w.WriteHeader(http.StatusCreated)
+	})
+	underlying := obscurer.DefaultStore
+	handler := obscurer.NewHandler(obscurer.Default, oteltrace.NewStore(underlying, tr), mux)
+	request := httptest.NewRequest(http.MethodGet, "/this/is/the/way", nil)
+	request = request.WithContext(context.WithValue(request.Context(), spanKey{}, parent))
+
+	// action.
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	// assert.
+	require.Len(t, tr.spans, 2)
+	for _, s := range tr.spans {
+		assert.Equal(t, parent, s.parent, "expected span %q to nest beneath the request", s.name)
+	}
+
+	// cleanup.
+	t.Cleanup(func() {
+		underlying.Clear(ctx)
+	})
+}
+
+func mustParse(str string) *url.URL {
+	u, err := url.Parse(str)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// pinging is a store whose backend is reachable unless err is set.
+type pinging struct {
+	obscurer.Store
+	err error
+}
+
+func (s *pinging) Ping(ctx context.Context) error {
+	return s.err
+}
+
+// TestStore_Ping tests that pings are forwarded to the underlying store.
+func TestStore_Ping(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	underlying := &pinging{Store: mock.NewStore(ctrl), err: errors.New("whoa")}
+	s := oteltrace.NewStore(underlying, &tracer{})
+
+	// action.
+	err := s.(obscurer.Pinger).Ping(ctx)
+
+	// assert.
+	assert.Equal(t, underlying.err, err)
+}
@@ -0,0 +1,131 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// PathPattern represents a pattern matching the paths of requests.
+type PathPattern interface {
+	// Match indicates whether the provided path matches the pattern.
+	Match(path string) bool
+}
+
+// globPattern matches paths with a glob.
+type globPattern string
+
+// Glob constructs a pattern matching paths with the provided glob, with the
+// syntax of path.Match, such that '/static/*' matches '/static/app.js', but
+// not '/static/js/app.js'. A trailing '/**' matches every path beneath the
+// preceding path, such that '/static/**' matches both.
+func Glob(pattern string) PathPattern {
+	return globPattern(pattern)
+}
+
+// Match indicates whether the provided path matches the glob. Malformed
+// globs match nothing.
+func (g globPattern) Match(p string) bool {
+	pattern := string(g)
+	if prefix := strings.TrimSuffix(pattern, "/**"); prefix != pattern {
+		if strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+		pattern = prefix
+	}
+	matched, _ := path.Match(pattern, p)
+	return matched
+}
+
+// valid indicates whether the glob is well-formed.
+func (g globPattern) valid() bool {
+	_, err := path.Match(strings.TrimSuffix(string(g), "/**"), "")
+	return err == nil
+}
+
+// regexpPattern matches paths with a regular expression.
+type regexpPattern struct {
+	re *regexp.Regexp
+}
+
+// Regexp constructs a pattern matching paths with the provided regular
+// expression, which is unanchored unless it anchors itself.
+func Regexp(re *regexp.Regexp) PathPattern {
+	return regexpPattern{re: re}
+}
+
+// Match indicates whether the provided path matches the regular expression.
+func (r regexpPattern) Match(p string) bool {
+	return r.re.MatchString(p)
+}
+
+// matchAny indicates whether the provided path matches any of the provided
+// patterns.
+func matchAny(patterns []PathPattern, p string) bool {
+	for _, pattern := range patterns {
+		if pattern.Match(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithExclusions configures the handler to pass requests whose path matches
+// any of the provided patterns, such as Glob("/healthz") or
+// Glob("/static/**"), straight to the wrapped handler, without looking them
+// up in the store nor obscuring their responses.
+func WithExclusions(patterns ...PathPattern) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Exclusions = append(o.Exclusions, patterns...)
+	}
+}
+
+// excluded indicates whether the provided request is excluded from
+// obscuring.
+func (h *handler) excluded(r *http.Request) bool {
+	return matchAny(h.options.Exclusions, r.URL.Path)
+}
+
+// WithInclusions configures the handler to obscure only the URLs whose path
+// matches any of the provided patterns, such as Glob("/orders/**"), leaving
+// every other URL as is, such that obscuring can be rolled out gradually
+// per route. Requests by obscured URLs are resolved regardless.
+func WithInclusions(patterns ...PathPattern) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Inclusions = append(o.Inclusions, patterns...)
+	}
+}
+
+// included indicates whether the provided URL is to be obscured.
+func (h *handler) included(u *url.URL) bool {
+	return len(h.options.Inclusions) == 0 || matchAny(h.options.Inclusions, u.Path)
+}
+
+// WithObscuredPaths configures the handler to respond with 404 Not Found to
+// the requests whose path matches any of the provided patterns, describing
+// the format of obscured URLs, such as Regexp(regexp.MustCompile(
+// `^/[0-9a-f]{32}$`)) for Default, but which aren't known, rather than
+// handling them as is, such that enumeration traffic doesn't reach the
+// wrapped handler.
+func WithObscuredPaths(patterns ...PathPattern) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.ObscuredPaths = append(o.ObscuredPaths, patterns...)
+	}
+}
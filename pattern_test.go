@@ -0,0 +1,164 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/internal/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPathPattern_Match tests that paths are matched by globs and regular
+// expressions.
+func TestPathPattern_Match(t *testing.T) {
+	tests := []struct {
+		pattern obscurer.PathPattern
+		path    string
+		matched bool
+	}{
+		{pattern: obscurer.Glob("/healthz"), path: "/healthz", matched: true},
+		{pattern: obscurer.Glob("/healthz"), path: "/healthz/live", matched: false},
+		{pattern: obscurer.Glob("/static/*"), path: "/static/app.js", matched: true},
+		{pattern: obscurer.Glob("/static/*"), path: "/static/js/app.js", matched: false},
+		{pattern: obscurer.Glob("/static/**"), path: "/static/js/app.js", matched: true},
+		{pattern: obscurer.Glob("/static/**"), path: "/static", matched: true},
+		{pattern: obscurer.Glob("/static/**"), path: "/statics/app.js", matched: false},
+		{pattern: obscurer.Glob("/[a-"), path: "/a", matched: false},
+		{pattern: obscurer.Regexp(regexp.MustCompile(`^/metrics(/|$)`)), path: "/metrics", matched: true},
+		{pattern: obscurer.Regexp(regexp.MustCompile(`^/metrics(/|$)`)), path: "/metricsz", matched: false},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%v/%s", test.pattern, test.path), func(t *testing.T) {
+			// action + assert.
+			assert.Equal(t, test.matched, test.pattern.Match(test.path))
+		})
+	}
+}
+
+// TestHandler_Exclusions tests that requests whose path is excluded are
+// handled as is, without being looked up nor obscured.
+func TestHandler_Exclusions(t *testing.T) {
+	// arrange.
+	assert := assert.New(t)
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/static/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Location", "/static/app.js")
+	})
+	store := mock.NewStore(ctrl)
+	handler := obscurer.NewHandler(
+		obscurer.Default,
+		store,
+		mux,
+		obscurer.WithExclusions(obscurer.Glob("/healthz"), obscurer.Glob("/static/**")),
+	)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// action.
+	response, err := http.Get(fmt.Sprintf("%s/static/js/app.js", server.URL))
+
+	// assert.
+	require.NoError(err)
+	assert.Equal(http.StatusOK, response.StatusCode)
+	assert.Equal("/static/app.js", response.Header.Get("Content-Location"))
+}
+
+// TestHandler_Inclusions tests that only the URLs whose path is included
+// are obscured.
+func TestHandler_Inclusions(t *testing.T) {
+	// arrange.
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+	t.Cleanup(func() { obscurer.DefaultStore.Clear(ctx) })
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orders", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/orders/42")
+		w.Header().Set("Link", `</legacy/orders/42>; rel="alternate"`)
+		w.WriteHeader(http.StatusCreated)
+	})
+	handler := obscurer.NewHandler(
+		obscurer.Default,
+		obscurer.DefaultStore,
+		mux,
+		obscurer.WithInclusions(obscurer.Glob("/orders/**")),
+	)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// action.
+	response, err := http.Post(fmt.Sprintf("%s/orders", server.URL), "text/plain", nil)
+
+	// assert.
+	require.NoError(err)
+	assert.Equal(http.StatusCreated, response.StatusCode)
+	assert.Equal(obscurer.Default.Obscure(mustParse("/orders/42")).String(), response.Header.Get("Location"))
+	assert.Equal(`</legacy/orders/42>; rel="alternate"`, response.Header.Get("Link"))
+	assert.Equal(1, obscurer.DefaultStore.Size(ctx))
+}
+
+// TestHandler_ObscuredPaths tests that requests whose path is of the format
+// of obscured URLs, but isn't known, aren't handled.
+func TestHandler_ObscuredPaths(t *testing.T) {
+	original := mustParse("/this/is/the/way")
+	obscured := obscurer.Default.Obscure(original)
+	tests := []struct {
+		name    string
+		target  string
+		handled bool
+	}{
+		{name: "Known", target: obscured.String(), handled: true},
+		{name: "Unknown", target: obscurer.Default.Obscure(mustParse("/hey/der")).String()},
+		{name: "Other", target: "/about", handled: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			ctx := context.Background()
+			store := obscurer.NewMemoryStore()
+			require.NoError(t, store.Put(ctx, obscured, original))
+			handled := false
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handled = true
+			})
+			format := obscurer.Regexp(regexp.MustCompile(`^/[0-9a-f]{32}$`))
+			handler := obscurer.NewHandler(obscurer.Default, store, h, obscurer.WithObscuredPaths(format))
+			response := httptest.NewRecorder()
+
+			// action.
+			handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, test.target, nil))
+
+			// assert.
+			assert.Equal(t, test.handled, handled)
+			if !test.handled {
+				assert.Equal(t, http.StatusNotFound, response.Code)
+				assert.Equal(t, obscurer.ErrUnknownURL.Error()+"\n", response.Body.String())
+			}
+		})
+	}
+}
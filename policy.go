@@ -0,0 +1,178 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrFailedPolicy represents an error that occurs when the policy of the
+// namespace of a request fails to be looked up or applied.
+var ErrFailedPolicy = errors.New("obscurer: unable to apply the policy of the namespace")
+
+// Quota represents a limit on the mappings of a namespace. Zero values are
+// unlimited.
+type Quota struct {
+	// Count is the maximum number of mappings.
+	Count int
+	// Bytes is the maximum size of the mappings, in bytes, counting the
+	// path of the obscured URL and the original URL of each mapping.
+	Bytes int64
+}
+
+// Policy represents the configuration of a namespace, such as a tenant,
+// overriding the configuration of the handlers and stores serving it. Zero
+// values keep the configuration as is.
+type Policy struct {
+	// TTL is the duration the mappings placed within the namespace are
+	// kept for.
+	TTL time.Duration
+	// Obscurer is the name of the registered obscurer URLs are obscured
+	// with within the namespace.
+	Obscurer string
+	// SoftQuota is the quota at which an alert is raised for the namespace.
+	SoftQuota Quota
+	// HardQuota is the quota the mappings of the namespace are never placed
+	// beyond.
+	HardQuota Quota
+}
+
+// Policies provides the policies of namespaces.
+type Policies interface {
+	// Policy provides the policy of the provided namespace, if any.
+	Policy(ctx context.Context, namespace string) (Policy, bool, error)
+}
+
+// StorePolicies keeps the policies of namespaces within a store, alongside
+// the mappings of the namespaces, so that operators can change the policy
+// of a namespace without redeploying.
+type StorePolicies struct {
+	store Store
+	// mutex serializes changes to policies, which replace the mapping of
+	// the policy.
+	mutex sync.Mutex
+}
+
+// NewStorePolicies constructs policies kept within the provided store, such
+// as a dedicated table or bucket of the backend holding the mappings. Each
+// policy is kept as a mapping of its own, whose obscured path is prefixed
+// by '/.policies/', so the store shouldn't be one the handler resolves URLs
+// with. Wrapping the store with store.NewTiered avoids a round trip to the
+// backend for each request.
+func NewStorePolicies(s Store) *StorePolicies {
+	return &StorePolicies{store: s}
+}
+
+// policyKey provides the obscured URL the policy of the provided namespace
+// is kept under.
+func policyKey(namespace string) *url.URL {
+	return &url.URL{Path: "/.policies/" + url.PathEscape(namespace)}
+}
+
+// Policy provides the policy of the provided namespace, if any.
+func (p *StorePolicies) Policy(ctx context.Context, namespace string) (Policy, bool, error) {
+	u, ok, err := p.store.Get(ctx, policyKey(namespace))
+	if !ok || err != nil {
+		return Policy{}, false, err
+	}
+	values := u.Query()
+	var policy Policy
+	if ttl := values.Get("ttl"); ttl != "" {
+		if policy.TTL, err = time.ParseDuration(ttl); err != nil {
+			return Policy{}, false, err
+		}
+	}
+	policy.Obscurer = values.Get("obscurer")
+	for key, n := range map[string]*int{
+		"soft_count": &policy.SoftQuota.Count,
+		"hard_count": &policy.HardQuota.Count,
+	} {
+		if value := values.Get(key); value != "" {
+			if *n, err = strconv.Atoi(value); err != nil {
+				return Policy{}, false, err
+			}
+		}
+	}
+	for key, n := range map[string]*int64{
+		"soft_bytes": &policy.SoftQuota.Bytes,
+		"hard_bytes": &policy.HardQuota.Bytes,
+	} {
+		if value := values.Get(key); value != "" {
+			if *n, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return Policy{}, false, err
+			}
+		}
+	}
+	return policy, true, nil
+}
+
+// Set replaces the policy of the provided namespace.
+func (p *StorePolicies) Set(ctx context.Context, namespace string, policy Policy) error {
+	values := url.Values{}
+	if policy.TTL > 0 {
+		values.Set("ttl", policy.TTL.String())
+	}
+	if policy.Obscurer != "" {
+		values.Set("obscurer", policy.Obscurer)
+	}
+	for key, n := range map[string]int64{
+		"soft_count": int64(policy.SoftQuota.Count),
+		"soft_bytes": policy.SoftQuota.Bytes,
+		"hard_count": int64(policy.HardQuota.Count),
+		"hard_bytes": policy.HardQuota.Bytes,
+	} {
+		if n > 0 {
+			values.Set(key, strconv.FormatInt(n, 10))
+		}
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	key := policyKey(namespace)
+	if err := p.store.Remove(ctx, key); err != nil {
+		return err
+	}
+	return p.store.Put(ctx, key, &url.URL{Path: "/", RawQuery: values.Encode()})
+}
+
+// Delete removes the policy of the provided namespace.
+func (p *StorePolicies) Delete(ctx context.Context, namespace string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.store.Remove(ctx, policyKey(namespace))
+}
+
+// policyObscurers caches the obscurers selected by policies by name.
+type policyObscurers struct {
+	obscurers sync.Map
+}
+
+// get provides the registered obscurer with the provided name.
+func (c *policyObscurers) get(name string) (Obscurer, error) {
+	if o, ok := c.obscurers.Load(name); ok {
+		return o.(Obscurer), nil
+	}
+	o, err := New(name)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := c.obscurers.LoadOrStore(name, o)
+	return actual.(Obscurer), nil
+}
@@ -0,0 +1,150 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// policyTable provides the policies of namespaces from a map.
+type policyTable map[string]obscurer.Policy
+
+func (t policyTable) Policy(ctx context.Context, namespace string) (obscurer.Policy, bool, error) {
+	p, ok := t[namespace]
+	return p, ok, nil
+}
+
+// TestStorePolicies tests that policies can be set, retrieved, replaced,
+// and deleted.
+func TestStorePolicies(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	store := obscurer.DefaultStore
+	t.Cleanup(func() {
+		store.Clear(ctx)
+	})
+	policies := obscurer.NewStorePolicies(store)
+	expected := obscurer.Policy{
+		TTL:       time.Hour,
+		Obscurer:  "sha256",
+		SoftQuota: obscurer.Quota{Count: 10},
+		HardQuota: obscurer.Quota{Count: 20, Bytes: 4096},
+	}
+
+	// action + assert.
+	require.NoError(t, policies.Set(ctx, "acme/eu", obscurer.Policy{TTL: time.Minute}))
+	require.NoError(t, policies.Set(ctx, "acme/eu", expected))
+	got, ok, err := policies.Policy(ctx, "acme/eu")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, expected, got)
+	_, ok, err = policies.Policy(ctx, "globex")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	require.NoError(t, policies.Delete(ctx, "acme/eu"))
+	_, ok, err = policies.Policy(ctx, "acme/eu")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// TestHandler_Policies tests that URLs are obscured with the obscurer
+// selected by the policy of the namespace of the request.
+func TestHandler_Policies(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	store := obscurer.DefaultStore
+	t.Cleanup(func() {
+		store.Clear(ctx)
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/this/is/the/way", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Location", "/hey/der")
+		w.WriteHeader(http.StatusCreated)
+	})
+	handler := obscurer.NewHandler(obscurer.Default, store, mux,
+		obscurer.WithNamespacer(func(r *http.Request) string {
+			return r.Header.Get("X-Tenant")
+		}),
+		obscurer.WithPolicies(policyTable{
+			"acme":    {Obscurer: "sha256"},
+			"initech": {Obscurer: "whoa"},
+		}))
+	tests := []struct {
+		tenant   string
+		status   int
+		location string
+	}{
+		{"acme", http.StatusCreated, obscurer.NewSHA256().Obscure(mustParse("/hey/der")).String()},
+		{"globex", http.StatusCreated, obscurer.Default.Obscure(mustParse("/hey/der")).String()},
+		{"initech", http.StatusInternalServerError, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.tenant, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/this/is/the/way", nil)
+			request.Header.Set("X-Tenant", test.tenant)
+			response := httptest.NewRecorder()
+
+			// action.
+			handler.ServeHTTP(response, request)
+
+			// assert.
+			assert.Equal(t, test.status, response.Code)
+			assert.Equal(t, test.location, response.Header().Get("Location"))
+		})
+	}
+}
+
+// TestHandler_Policies_Error tests that requests aren't handled when the
+// policy of their namespace fails to be looked up.
+func TestHandler_Policies_Error(t *testing.T) {
+	// arrange.
+	handled := false
+	handler := obscurer.NewHandler(obscurer.Default, obscurer.DefaultStore,
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handled = true
+		}),
+		obscurer.WithNamespacer(func(r *http.Request) string {
+			return "acme"
+		}),
+		obscurer.WithPolicies(failingPolicies{errors.New("whoa")}))
+	response := httptest.NewRecorder()
+
+	// action.
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/abc", nil))
+
+	// assert.
+	assert.False(t, handled)
+	assert.Equal(t, http.StatusInternalServerError, response.Code)
+	assert.Contains(t, response.Body.String(), obscurer.ErrFailedPolicy.Error())
+}
+
+// failingPolicies fails to provide the policies of namespaces.
+type failingPolicies struct {
+	err error
+}
+
+func (p failingPolicies) Policy(ctx context.Context, namespace string) (obscurer.Policy, bool, error) {
+	return obscurer.Policy{}, false, p.err
+}
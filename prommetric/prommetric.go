@@ -0,0 +1,321 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package prommetric provides a Prometheus collector of the metrics of the
+// handler and its store, such that deployments scraped by Prometheus can
+// monitor the resolution of requests, the hit ratio, latency, and errors of
+// their store, the outcome of obscuring response headers, and the overhead
+// of the requests sampled for body rewriting.
+//
+// The following metrics are collected:
+//
+//	obscurer_requests_total                    counter    requests, by 'outcome'
+//	                                                      (resolved, unresolved, or failed)
+//	obscurer_store_lookups_total               counter    lookups, by 'result' (hit or miss)
+//	obscurer_store_operation_duration_seconds  histogram  store operations, by 'operation'
+//	obscurer_store_errors_total                counter    errors, by 'operation' and 'type'
+//	obscurer_store_removals_total              counter    mappings removed
+//	obscurer_headers_total                     counter    headers, by 'header' and 'outcome'
+//	                                                      (rewritten, skipped, or failed)
+//	obscurer_overhead_seconds                  histogram  requests whose body would be
+//	                                                      rewritten, by 'sampled'
+//
+// The collector is a prometheus.Collector, and so is registered alongside
+// the other collectors of the application:
+//
+//	c := prommetric.New()
+//	prometheus.MustRegister(c)
+//	s := c.Store(obscurer.DefaultStore)
+//	http.Handle("/metrics", promhttp.Handler())
+//	http.Handle("/", obscurer.NewHandler(obscurer.Default, s, mux, c.HandlerOptions()...))
+//
+// Applications that don't otherwise expose Prometheus metrics can mount the
+// collector instead, as it is also an http.Handler serving only its own
+// metrics.
+//
+// The package is a module of its own, so that applications that don't use
+// Prometheus don't depend on its client library.
+package prommetric
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultBuckets represents the default upper bounds of the buckets of the
+// histograms, in seconds.
+var DefaultBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}
+
+// Options represents the configuration options for the collector.
+type Options struct {
+	// Buckets are the upper bounds of the buckets of the histograms of the
+	// duration of store operations and of the overhead of requests, in
+	// seconds. When empty, DefaultBuckets are used.
+	Buckets []float64
+	// Labels are attached to every metric, such as the name of the
+	// deployment.
+	Labels map[string]string
+}
+
+// Option applies an option to the provided configuration.
+type Option func(*Options)
+
+// WithBuckets configures the upper bounds of the buckets of the histograms,
+// in seconds.
+func WithBuckets(buckets ...float64) Option {
+	return func(o *Options) {
+		o.Buckets = append([]float64{}, buckets...)
+	}
+}
+
+// WithLabel configures the provided label to be attached to every metric.
+func WithLabel(name, value string) Option {
+	return func(o *Options) {
+		if o.Labels == nil {
+			o.Labels = make(map[string]string)
+		}
+		o.Labels[name] = value
+	}
+}
+
+// Collector collects the metrics of the handler and its store.
+type Collector struct {
+	requests    *prometheus.CounterVec
+	lookups     *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	storeErrors *prometheus.CounterVec
+	removals    prometheus.Counter
+	headers     *prometheus.CounterVec
+	overhead    *prometheus.HistogramVec
+	handler     http.Handler
+}
+
+// New constructs a collector.
+func New(opts ...Option) *Collector {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if len(options.Buckets) == 0 {
+		options.Buckets = DefaultBuckets
+	}
+	sort.Float64s(options.Buckets)
+	labels := prometheus.Labels(options.Labels)
+	c := &Collector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "obscurer_requests_total",
+			Help:        "The number of requests whose URL was resolved, by outcome.",
+			ConstLabels: labels,
+		}, []string{"outcome"}),
+		lookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "obscurer_store_lookups_total",
+			Help:        "The number of lookups of obscured URLs in the store, by result.",
+			ConstLabels: labels,
+		}, []string{"result"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        "obscurer_store_operation_duration_seconds",
+			Help:        "The duration of store operations, in seconds.",
+			ConstLabels: labels,
+			Buckets:     options.Buckets,
+		}, []string{"operation"}),
+		storeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "obscurer_store_errors_total",
+			Help:        "The number of failed store operations, by operation and type.",
+			ConstLabels: labels,
+		}, []string{"operation", "type"}),
+		removals: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "obscurer_store_removals_total",
+			Help:        "The number of mappings removed from the store.",
+			ConstLabels: labels,
+		}),
+		headers: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "obscurer_headers_total",
+			Help:        "The number of response headers obscured, by header and outcome.",
+			ConstLabels: labels,
+		}, []string{"header", "outcome"}),
+		overhead: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        "obscurer_overhead_seconds",
+			Help:        "The time spent resolving and rewriting URLs for requests whose body would be rewritten, in seconds.",
+			ConstLabels: labels,
+			Buckets:     options.Buckets,
+		}, []string{"sampled"}),
+	}
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(c)
+	c.handler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	return c
+}
+
+// collectors provides the collectors of the metrics, in the order they are
+// described and collected.
+func (c *Collector) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.requests, c.lookups, c.duration, c.storeErrors, c.removals, c.headers, c.overhead,
+	}
+}
+
+// Describe sends the descriptors of the metrics collected to the provided
+// channel. It implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range c.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+// Collect sends the metrics collected to the provided channel. It
+// implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range c.collectors() {
+		collector.Collect(ch)
+	}
+}
+
+// HandlerOptions provides the options configuring the handler to report
+// the resolution of requests, the outcome of obscuring headers, and the
+// sampling of body rewriting to the collector.
+func (c *Collector) HandlerOptions() []obscurer.HandlerOption {
+	return []obscurer.HandlerOption{
+		obscurer.WithResolutionObserver(c.ObserveResolution),
+		obscurer.WithHeaderObserver(c.ObserveHeader),
+		obscurer.WithSamplingObserver(c.ObserveSampling),
+	}
+}
+
+// ObserveResolution counts the provided outcome of resolving the URL of a
+// request. It is an obscurer.ResolutionObserver.
+func (c *Collector) ObserveResolution(ctx context.Context, outcome obscurer.ResolutionOutcome) {
+	c.requests.WithLabelValues(outcome.String()).Inc()
+}
+
+// ObserveHeader counts the provided outcome of obscuring the provided
+// header. It is an obscurer.HeaderObserver.
+func (c *Collector) ObserveHeader(ctx context.Context, header string, outcome obscurer.HeaderOutcome) {
+	c.headers.WithLabelValues(header, outcome.String()).Inc()
+}
+
+// ObserveSampling records the provided overhead of a request whose body
+// would be rewritten, by whether it was sampled. It is an
+// obscurer.SamplingObserver.
+func (c *Collector) ObserveSampling(ctx context.Context, sampled bool, d time.Duration) {
+	c.overhead.WithLabelValues(strconv.FormatBool(sampled)).Observe(d.Seconds())
+}
+
+// ServeHTTP serves the metrics collected, and only those, in the format
+// negotiated with the scraper.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.handler.ServeHTTP(w, r)
+}
+
+// store records metrics for the operations of the underlying store.
+type store struct {
+	store     obscurer.Store
+	collector *Collector
+}
+
+// Store decorates the provided store to record metrics for its operations
+// with the collector.
+func (c *Collector) Store(s obscurer.Store) obscurer.Store {
+	return &store{store: s, collector: c}
+}
+
+// record records the duration of the provided operation, which started at
+// the provided time, and its error if any.
+func (s *store) record(operation string, start time.Time, err error) {
+	s.collector.duration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err == nil {
+		return
+	}
+	errorType := "error"
+	if errors.Is(err, obscurer.ErrCollision) {
+		errorType = "collision"
+	}
+	s.collector.storeErrors.WithLabelValues(operation, errorType).Inc()
+}
+
+// Put places the mapping into the underlying store.
+func (s *store) Put(ctx context.Context, obscured, original *url.URL) error {
+	start := time.Now()
+	err := s.store.Put(ctx, obscured, original)
+	s.record("put", start, err)
+	return err
+}
+
+// Get retrieves the original form of the provided obscured URL from the
+// underlying store, recording whether it was a hit or a miss. Failed lookups
+// are recorded as errors instead.
+func (s *store) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	start := time.Now()
+	original, ok, err := s.store.Get(ctx, obscured)
+	s.record("get", start, err)
+	if err != nil {
+		return original, ok, err
+	}
+	result := "miss"
+	if ok {
+		result = "hit"
+	}
+	s.collector.lookups.WithLabelValues(result).Inc()
+	return original, ok, nil
+}
+
+// Remove deletes the entry from the underlying store, recording the
+// removal.
+func (s *store) Remove(ctx context.Context, obscured *url.URL) error {
+	start := time.Now()
+	err := s.store.Remove(ctx, obscured)
+	s.record("remove", start, err)
+	if err == nil {
+		s.collector.removals.Inc()
+	}
+	return err
+}
+
+// Clear removes all entries from the underlying store.
+func (s *store) Clear(ctx context.Context) error {
+	start := time.Now()
+	err := s.store.Clear(ctx)
+	s.record("clear", start, err)
+	return err
+}
+
+// Size computes the size of the underlying store.
+func (s *store) Size(ctx context.Context) int {
+	start := time.Now()
+	size := s.store.Size(ctx)
+	s.record("size", start, nil)
+	return size
+}
+
+// Load loads the underlying store.
+func (s *store) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	start := time.Now()
+	err := s.store.Load(ctx, mappings)
+	s.record("load", start, err)
+	return err
+}
+
+// Ping verifies the backend of the measured store is reachable.
+func (s *store) Ping(ctx context.Context) error {
+	return obscurer.Ping(ctx, s.store)
+}
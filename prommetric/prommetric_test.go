@@ -0,0 +1,193 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prommetric_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/internal/mock"
+	"github.com/freerware/obscurer/prommetric"
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustParse(str string) *url.URL {
+	u, err := url.Parse(str)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// scrape provides the lines of the metrics exposed by the collector,
+// without the comments describing them.
+func scrape(t *testing.T, c *prommetric.Collector) []string {
+	response := httptest.NewRecorder()
+	c.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, response.Code)
+	assert.True(t, strings.HasPrefix(response.Header().Get("Content-Type"), "text/plain; version=0.0.4"))
+	lines := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(response.Body.String()), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// TestCollector_Store tests that lookups are counted by result, and that
+// removals and failed operations are counted.
+func TestCollector_Store(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	underlying := mock.NewStore(ctrl)
+	underlying.EXPECT().Get(ctx, mustParse("/a")).Return(mustParse("/this/is/the/way"), true, nil).Times(2)
+	underlying.EXPECT().Get(ctx, mustParse("/b")).Return(nil, false, nil)
+	underlying.EXPECT().Put(ctx, gomock.Any(), gomock.Any()).Return(&obscurer.CollisionError{})
+	underlying.EXPECT().Remove(ctx, mustParse("/a")).Return(nil)
+	underlying.EXPECT().Remove(ctx, mustParse("/b")).Return(errors.New("whoa"))
+	c := prommetric.New(prommetric.WithBuckets(10))
+	s := c.Store(underlying)
+
+	// action.
+	s.Get(ctx, mustParse("/a"))
+	s.Get(ctx, mustParse("/a"))
+	s.Get(ctx, mustParse("/b"))
+	s.Put(ctx, mustParse("/a"), mustParse("/this/is/the/way"))
+	s.Remove(ctx, mustParse("/a"))
+	s.Remove(ctx, mustParse("/b"))
+
+	// assert.
+	lines := scrape(t, c)
+	assert.Contains(t, lines, `obscurer_store_lookups_total{result="hit"} 2`)
+	assert.Contains(t, lines, `obscurer_store_lookups_total{result="miss"} 1`)
+	assert.Contains(t, lines, `obscurer_store_errors_total{operation="put",type="collision"} 1`)
+	assert.Contains(t, lines, `obscurer_store_errors_total{operation="remove",type="error"} 1`)
+	assert.Contains(t, lines, `obscurer_store_removals_total 1`)
+	assert.Contains(t, lines, `obscurer_store_operation_duration_seconds_bucket{operation="get",le="10"} 3`)
+	assert.Contains(t, lines, `obscurer_store_operation_duration_seconds_bucket{operation="get",le="+Inf"} 3`)
+	assert.Contains(t, lines, `obscurer_store_operation_duration_seconds_count{operation="get"} 3`)
+	assert.Contains(t, lines, `obscurer_store_operation_duration_seconds_count{operation="remove"} 2`)
+}
+
+// TestCollector_Handler tests that the resolution of requests and the
+// outcome of obscuring headers are counted when the collector observes the
+// handler.
+func TestCollector_Handler(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	original := mustParse("/this/is/the/way")
+	obscured := obscurer.Default.Obscure(original)
+	c := prommetric.New(prommetric.WithLabel("service", `mando"rian`))
+	store := c.Store(obscurer.NewMemoryStore())
+	require.NoError(t, store.Put(ctx, obscured, original))
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/hey/der")
+	})
+	handler := obscurer.NewHandler(obscurer.Default, store, h, c.HandlerOptions()...)
+
+	// action.
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, obscured.String(), nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hey/der", nil))
+
+	// assert.
+	lines := scrape(t, c)
+	assert.Contains(t, lines, `obscurer_requests_total{outcome="resolved",service="mando\"rian"} 1`)
+	assert.Contains(t, lines, `obscurer_requests_total{outcome="unresolved",service="mando\"rian"} 1`)
+	assert.Contains(t, lines, `obscurer_headers_total{header="Location",outcome="rewritten",service="mando\"rian"} 2`)
+	assert.Contains(t, lines, `obscurer_store_lookups_total{result="hit",service="mando\"rian"} 1`)
+}
+
+// TestCollector_ObserveSampling tests that the overhead of requests is
+// recorded by whether they were sampled.
+func TestCollector_ObserveSampling(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	c := prommetric.New(prommetric.WithBuckets(0.001))
+
+	// action.
+	c.ObserveSampling(ctx, true, 2*time.Millisecond)
+	c.ObserveSampling(ctx, false, time.Microsecond)
+
+	// assert.
+	lines := scrape(t, c)
+	assert.Contains(t, lines, `obscurer_overhead_seconds_bucket{sampled="true",le="0.001"} 0`)
+	assert.Contains(t, lines, `obscurer_overhead_seconds_count{sampled="true"} 1`)
+	assert.Contains(t, lines, `obscurer_overhead_seconds_bucket{sampled="false",le="0.001"} 1`)
+	assert.Contains(t, lines, `obscurer_overhead_seconds_sum{sampled="true"} 0.002`)
+}
+
+// TestCollector_Register tests that the collector can be registered with a
+// Prometheus registry, and that its metrics are gathered from it.
+func TestCollector_Register(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	registry := prometheus.NewPedanticRegistry()
+	c := prommetric.New(prommetric.WithLabel("service", "mandalorian"))
+	registry.MustRegister(c)
+
+	// action.
+	c.ObserveResolution(ctx, obscurer.ResolutionResolved)
+	families, err := registry.Gather()
+
+	// assert.
+	require.NoError(t, err)
+	names := []string{}
+	for _, family := range families {
+		names = append(names, family.GetName())
+	}
+	assert.Contains(t, names, "obscurer_requests_total")
+	assert.Contains(t, names, "obscurer_store_removals_total")
+	assert.Panics(t, func() { registry.MustRegister(prommetric.New()) })
+}
+
+// pinging is a store whose backend is reachable unless err is set.
+type pinging struct {
+	obscurer.Store
+	err error
+}
+
+func (s *pinging) Ping(ctx context.Context) error {
+	return s.err
+}
+
+// TestCollector_Store_Ping tests that pings are forwarded to the underlying store.
+func TestCollector_Store_Ping(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	underlying := &pinging{Store: mock.NewStore(ctrl), err: errors.New("whoa")}
+	s := prommetric.New().Store(underlying)
+
+	// action.
+	err := s.(obscurer.Pinger).Ping(ctx)
+
+	// assert.
+	assert.Equal(t, underlying.err, err)
+}
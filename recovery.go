@@ -0,0 +1,75 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// ErrPanic represents an error that occurs when the wrapped handler panics.
+var ErrPanic = errors.New("obscurer: handler panicked")
+
+// WithRecovery configures the handler to recover from the panics of the
+// wrapped handler, logging them and responding with 500 Internal Server
+// Error through the error handler, rather than leaving the client with an
+// empty reply. A panic after the response has begun to be written to the
+// client aborts the response instead, as it can't be replaced.
+func WithRecovery() HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Recover = true
+	}
+}
+
+// serve serves the provided request with the wrapped handler, recovering
+// from its panics when configured to. It indicates whether the response
+// was completed upon a panic, in which case the response writer must not be
+// closed.
+func (h *handler) serve(rw *responseWriter, r *http.Request) (recovered bool) {
+	if !h.options.Recover {
+		h.handler.ServeHTTP(rw, r)
+		return false
+	}
+	defer func() {
+		p := recover()
+		if p == nil {
+			return
+		}
+		if p == http.ErrAbortHandler {
+			panic(p)
+		}
+		h.logger().Warnf("obscurer: recovered from panic serving %s: %v\n%s", r.URL, p, debug.Stack())
+		recovered = true
+		switch {
+		case rw.committed && rw.err != nil:
+			// the response was already replaced by an error, or hijacked.
+		case rw.committed && !rw.buffer:
+			// the response has begun to be written to the client.
+			panic(http.ErrAbortHandler)
+		default:
+			rw.committed, rw.err = true, ErrPanic
+			header := rw.ResponseWriter.Header()
+			for key := range header {
+				header.Del(key)
+			}
+			h.fail(rw.ResponseWriter, r, ErrPanic, fmt.Errorf("%v", p), http.StatusInternalServerError)
+		}
+	}()
+	h.handler.ServeHTTP(rw, r)
+	return false
+}
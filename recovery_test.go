@@ -0,0 +1,117 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWithRecovery tests that the panics of the wrapped handler are
+// recovered from with a clean 500 Internal Server Error, unless the
+// response has begun to be written.
+func TestWithRecovery(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		status  int
+		body    string
+		aborted bool
+	}{
+		{
+			name: "BeforeWrite",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Location", "/hey/der")
+				panic("whoa")
+			},
+			status: http.StatusInternalServerError,
+			body:   obscurer.ErrPanic.Error() + "\n",
+		},
+		{
+			name: "Buffered",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				w.Write([]byte("<p>hey der</p>"))
+				panic("whoa")
+			},
+			status: http.StatusInternalServerError,
+			body:   obscurer.ErrPanic.Error() + "\n",
+		},
+		{
+			name: "Streamed",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("hey der"))
+				panic("whoa")
+			},
+			aborted: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			l := &logger{}
+			handler := obscurer.NewHandler(
+				obscurer.Default,
+				obscurer.NewMemoryStore(),
+				test.handler,
+				obscurer.WithRecovery(),
+				obscurer.WithHTMLRewriting(),
+				obscurer.WithLogger(l),
+			)
+			response := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "/this/is/the/way", nil)
+
+			// action.
+			serve := func() { handler.ServeHTTP(response, request) }
+
+			// assert.
+			if test.aborted {
+				assert.PanicsWithValue(t, http.ErrAbortHandler, serve)
+				return
+			}
+			assert.NotPanics(t, serve)
+			assert.Equal(t, test.status, response.Code)
+			assert.Equal(t, test.body, response.Body.String())
+			assert.Empty(t, response.Header().Get("Location"))
+			if assert.NotEmpty(t, l.messages) {
+				assert.Contains(t, l.messages[len(l.messages)-1], "warn: obscurer: recovered from panic serving /this/is/the/way: whoa")
+			}
+		})
+	}
+}
+
+// TestWithRecovery_Disabled tests that the panics of the wrapped handler
+// aren't recovered from unless configured to.
+func TestWithRecovery_Disabled(t *testing.T) {
+	// arrange.
+	handler := obscurer.NewHandler(
+		obscurer.Default,
+		obscurer.NewMemoryStore(),
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("whoa") }),
+	)
+
+	// action.
+	serve := func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/this/is/the/way", nil))
+	}
+
+	// assert.
+	assert.PanicsWithValue(t, "whoa", serve)
+}
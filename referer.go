@@ -0,0 +1,75 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// WithRefererResolution configures the handler to resolve the 'Referer'
+// header of requests, when it is an obscured URL of the host of the request,
+// to its original form before handling them, such that the wrapped handler
+// and downstream analytics see original URLs. Referers failing to resolve
+// are left as is.
+func WithRefererResolution() HandlerOption {
+	return func(o *HandlerOptions) {
+		o.ResolveReferer = true
+	}
+}
+
+// resolveReferer replaces the 'Referer' header of the provided request with
+// its original form, when it resolves.
+func (h *handler) resolveReferer(ctx context.Context, r *http.Request) {
+	referer := r.Header.Get("Referer")
+	if u, err := url.Parse(referer); err != nil || u.Host == "" {
+		return
+	}
+	if resolved, ok, err := h.resolveLink(ctx, r.Host, referer); err == nil && ok {
+		r.Header.Set("Referer", resolved)
+	}
+}
+
+// resolveLink resolves the provided link to its original form, keeping its
+// scheme and host, when it is an obscured URL of the provided host, either
+// as an absolute path or as an absolute URL. Links that aren't obscured are
+// left as is.
+func (h *handler) resolveLink(ctx context.Context, host, link string) (string, bool, error) {
+	u, err := url.Parse(link)
+	if err != nil || !sameHost(u, host) {
+		return link, false, nil
+	}
+	obscured := &url.URL{Path: u.Path, RawPath: u.RawPath, RawQuery: u.RawQuery}
+	original, ok, err := h.peek(ctx, obscured)
+	if err != nil || !ok {
+		return link, false, err
+	}
+	resolved := *original
+	resolved.Scheme, resolved.Host = u.Scheme, u.Host
+	return resolved.String(), true, nil
+}
+
+// peek resolves the provided obscured URL to its original form as resolve
+// does, without consuming a use of the mapping.
+func (h *handler) peek(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	if resolver, ok := h.obscurer.(Resolver); ok {
+		if original, ok := resolver.Resolve(obscured); ok {
+			return original, true, nil
+		}
+	}
+	return h.store.Get(ctx, obscured)
+}
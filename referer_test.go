@@ -0,0 +1,68 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHandler_RefererResolution tests that the 'Referer' header of requests
+// is resolved to its original form when it is an obscured URL of the host of
+// the request.
+func TestHandler_RefererResolution(t *testing.T) {
+	original := mustParse("/users/42?tab=orders")
+	obscured := obscurer.Default.Obscure(original)
+	tests := []struct {
+		name     string
+		referer  string
+		expected string
+	}{
+		{name: "Obscured", referer: "https://example.com" + obscured.String(), expected: "https://example.com/users/42?tab=orders"},
+		{name: "Unknown", referer: "https://example.com/about", expected: "https://example.com/about"},
+		{name: "OtherHost", referer: "https://freerware.com" + obscured.String(), expected: "https://freerware.com" + obscured.String()},
+		{name: "Missing"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			ctx := context.Background()
+			store := obscurer.NewMemoryStore()
+			require.NoError(t, store.Put(ctx, obscured, original))
+			var received string
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = r.Header.Get("Referer")
+			})
+			handler := obscurer.NewHandler(obscurer.Default, store, h, obscurer.WithRefererResolution())
+			request := httptest.NewRequest(http.MethodGet, "http://example.com/about", nil)
+			if test.referer != "" {
+				request.Header.Set("Referer", test.referer)
+			}
+
+			// action.
+			handler.ServeHTTP(httptest.NewRecorder(), request)
+
+			// assert.
+			assert.Equal(t, test.expected, received)
+		})
+	}
+}
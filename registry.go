@@ -0,0 +1,100 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrUnknownObscurer represents an error that occurs when constructing
+	// an obscurer with a name that has not been registered.
+	ErrUnknownObscurer = errors.New("obscurer: unknown obscurer")
+	// ErrInvalidKey represents an error that occurs when constructing an
+	// obscurer with a key it cannot use.
+	ErrInvalidKey = errors.New("obscurer: invalid key")
+)
+
+// Factory constructs an obscurer with the provided options.
+type Factory func(...ObscurerOption) (Obscurer, error)
+
+var (
+	registryMutex sync.RWMutex
+	registry      = map[string]Factory{}
+)
+
+func init() {
+	Register("md5", func(opts ...ObscurerOption) (Obscurer, error) {
+		return NewMD5(opts...), nil
+	})
+	Register("sha256", func(opts ...ObscurerOption) (Obscurer, error) {
+		return NewSHA256(opts...), nil
+	})
+	Register("siphash", func(opts ...ObscurerOption) (Obscurer, error) {
+		var options ObscurerOptions
+		for _, opt := range opts {
+			opt(&options)
+		}
+		if len(options.Key) != SipHashKeySize {
+			return nil, fmt.Errorf("%w: siphash requires a %d byte key", ErrInvalidKey, SipHashKeySize)
+		}
+		var key [SipHashKeySize]byte
+		copy(key[:], options.Key)
+		return NewSipHash(key, opts...), nil
+	})
+}
+
+// Register makes an obscurer factory available under the provided name,
+// allowing the obscurer to be selected by configuration with New. Register
+// panics when the factory is nil, or when a factory has already been
+// registered under the provided name.
+func Register(name string, factory Factory) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	if factory == nil {
+		panic("obscurer: Register factory is nil")
+	}
+	if _, exists := registry[name]; exists {
+		panic("obscurer: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// New constructs the obscurer registered under the provided name.
+func New(name string, opts ...ObscurerOption) (Obscurer, error) {
+	registryMutex.RLock()
+	factory, ok := registry[name]
+	registryMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownObscurer, name)
+	}
+	return factory(opts...)
+}
+
+// Names provides the sorted names of all registered obscurers.
+func Names() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,103 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNew tests that the built-in obscurers can be constructed by name.
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []obscurer.ObscurerOption
+		want obscurer.Obscurer
+	}{
+		{name: "md5", want: obscurer.NewMD5()},
+		{name: "sha256", want: obscurer.NewSHA256()},
+		{
+			name: "siphash",
+			opts: []obscurer.ObscurerOption{obscurer.WithKey(sipHashKey[:])},
+			want: obscurer.NewSipHash(sipHashKey),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			u := mustParse("http://www.example.com/this/is/the/way")
+
+			// action.
+			o, err := obscurer.New(test.name, test.opts...)
+
+			// assert.
+			require.NoError(t, err)
+			assert.Equal(t, test.want.Obscure(u).String(), o.Obscure(u).String())
+		})
+	}
+}
+
+// TestNew_Unknown tests that constructing an unregistered obscurer results
+// in an error.
+func TestNew_Unknown(t *testing.T) {
+	// action.
+	o, err := obscurer.New("rot13")
+
+	// assert.
+	assert.Nil(t, o)
+	assert.True(t, errors.Is(err, obscurer.ErrUnknownObscurer))
+}
+
+// TestNew_SipHash_InvalidKey tests that constructing the SipHash obscurer
+// without a valid key results in an error.
+func TestNew_SipHash_InvalidKey(t *testing.T) {
+	// action.
+	o, err := obscurer.New("siphash", obscurer.WithKey([]byte("short")))
+
+	// assert.
+	assert.Nil(t, o)
+	assert.True(t, errors.Is(err, obscurer.ErrInvalidKey))
+}
+
+// TestRegister tests that registered obscurers can be constructed by name.
+func TestRegister(t *testing.T) {
+	// arrange.
+	obscurer.Register("test", func(opts ...obscurer.ObscurerOption) (obscurer.Obscurer, error) {
+		return obscurerFunc(func(u *url.URL) *url.URL { return u }), nil
+	})
+
+	// action.
+	o, err := obscurer.New("test")
+
+	// assert.
+	require.NoError(t, err)
+	assert.NotNil(t, o)
+	assert.Contains(t, obscurer.Names(), "test")
+}
+
+// TestRegister_Duplicate tests that registering a name twice panics.
+func TestRegister_Duplicate(t *testing.T) {
+	assert.Panics(t, func() {
+		obscurer.Register("md5", func(opts ...obscurer.ObscurerOption) (obscurer.Obscurer, error) {
+			return obscurer.NewMD5(opts...), nil
+		})
+	})
+}
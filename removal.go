@@ -0,0 +1,169 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"container/list"
+	"context"
+	"net/url"
+	"sync"
+)
+
+// maxNotFounds is the maximum number of resources whose consecutive 404 Not
+// Found responses are counted at once, beyond which the least recently
+// counted resources are forgotten.
+const maxNotFounds = 10000
+
+// RemovalConfirmation confirms that the resource at the provided original
+// URL doesn't exist, such as by looking it up in the system of record, before
+// its mapping is removed from the store.
+type RemovalConfirmation func(ctx context.Context, original *url.URL) (bool, error)
+
+// Removal represents the configuration of the removal of the mappings of the
+// resources the wrapped handler responds to with 404 Not Found.
+type Removal struct {
+	// Disabled indicates whether mappings are kept regardless of the
+	// responses of the wrapped handler.
+	Disabled bool
+	// Threshold is the number of consecutive 404 Not Found responses for a
+	// resource before its mapping is removed. Zero removes it upon the
+	// first one.
+	Threshold int
+	// Confirm confirms that the resource doesn't exist before its mapping
+	// is removed. When nil, the mapping is removed without confirmation.
+	Confirm RemovalConfirmation
+}
+
+// WithoutRemoval configures the handler to keep the mappings of the
+// resources the wrapped handler responds to with 404 Not Found, such that
+// a temporarily misrouted backend doesn't remove valid mappings.
+func WithoutRemoval() HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Removal.Disabled = true
+	}
+}
+
+// WithRemovalThreshold configures the handler to remove the mapping of a
+// resource only once the wrapped handler responded to the provided number of
+// consecutive requests for it with 404 Not Found.
+func WithRemovalThreshold(threshold int) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Removal.Threshold = threshold
+	}
+}
+
+// WithRemovalConfirmation configures the handler to remove the mapping of a
+// resource the wrapped handler responds to with 404 Not Found only once the
+// provided confirmation confirms that it doesn't exist.
+func WithRemovalConfirmation(confirm RemovalConfirmation) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Removal.Confirm = confirm
+	}
+}
+
+// notFounds counts the consecutive 404 Not Found responses for each
+// resource whose mapping is yet to be removed, forgetting the least recently
+// counted resources once maxNotFounds are counted.
+type notFounds struct {
+	mu      sync.Mutex
+	counts  map[string]*list.Element
+	recency *list.List
+}
+
+// notFound represents the count of consecutive 404 Not Found responses for
+// a resource.
+type notFound struct {
+	key   string
+	count int
+}
+
+// key provides the key of the provided URL within the provided context,
+// such that resources of distinct namespaces are counted separately.
+func (n *notFounds) key(ctx context.Context, u *url.URL) string {
+	namespace, _ := NamespaceFromContext(ctx)
+	return namespace + " " + u.String()
+}
+
+// add counts a 404 Not Found response for the provided URL, indicating
+// whether the provided threshold is reached, in which case the count is
+// reset.
+func (n *notFounds) add(ctx context.Context, u *url.URL, threshold int) bool {
+	key := n.key(ctx, u)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.counts == nil {
+		n.counts = make(map[string]*list.Element)
+		n.recency = list.New()
+	}
+	element, ok := n.counts[key]
+	if ok {
+		n.recency.MoveToFront(element)
+	} else {
+		if len(n.counts) >= maxNotFounds {
+			n.remove(n.recency.Back())
+		}
+		element = n.recency.PushFront(&notFound{key: key})
+		n.counts[key] = element
+	}
+	entry := element.Value.(*notFound)
+	entry.count++
+	if entry.count < threshold {
+		return false
+	}
+	n.remove(element)
+	return true
+}
+
+// reset clears the count of the provided URL.
+func (n *notFounds) reset(ctx context.Context, u *url.URL) {
+	key := n.key(ctx, u)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if element, ok := n.counts[key]; ok {
+		n.remove(element)
+	}
+}
+
+// remove forgets the count of the provided element. The caller must hold
+// the mutex.
+func (n *notFounds) remove(element *list.Element) {
+	n.recency.Remove(element)
+	delete(n.counts, element.Value.(*notFound).key)
+}
+
+// removable indicates whether the mapping of the provided obscured URL,
+// whose resource the wrapped handler responded to with the provided status
+// code, is to be removed. Only the mappings of obscured URLs that resolved
+// are counted, as there is nothing to remove otherwise.
+func (h *handler) removable(ctx context.Context, resolved bool, obscured, original *url.URL, status int) (bool, error) {
+	removal := h.options.Removal
+	if removal.Disabled || !resolved {
+		return false, nil
+	}
+	if status != 404 {
+		if removal.Threshold > 1 {
+			h.notFounds.reset(ctx, obscured)
+		}
+		return false, nil
+	}
+	if removal.Threshold > 1 && !h.notFounds.add(ctx, obscured, removal.Threshold) {
+		return false, nil
+	}
+	if removal.Confirm != nil {
+		return removal.Confirm(ctx, original)
+	}
+	return true, nil
+}
@@ -0,0 +1,152 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHandler_Removal tests that the mapping of a resource the wrapped
+// handler responds to with 404 Not Found is removed as configured.
+func TestHandler_Removal(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []obscurer.HandlerOption
+		statuses []int
+		removed  bool
+	}{
+		{name: "Default", statuses: []int{404}, removed: true},
+		{name: "Disabled", opts: []obscurer.HandlerOption{obscurer.WithoutRemoval()}, statuses: []int{404, 404, 404}},
+		{name: "BelowThreshold", opts: []obscurer.HandlerOption{obscurer.WithRemovalThreshold(3)}, statuses: []int{404, 404}},
+		{name: "Threshold", opts: []obscurer.HandlerOption{obscurer.WithRemovalThreshold(3)}, statuses: []int{404, 404, 404}, removed: true},
+		{name: "NotConsecutive", opts: []obscurer.HandlerOption{obscurer.WithRemovalThreshold(2)}, statuses: []int{404, 200, 404}},
+		{
+			name: "Confirmed",
+			opts: []obscurer.HandlerOption{obscurer.WithRemovalConfirmation(func(ctx context.Context, u *url.URL) (bool, error) {
+				return true, nil
+			})},
+			statuses: []int{404},
+			removed:  true,
+		},
+		{
+			name: "Unconfirmed",
+			opts: []obscurer.HandlerOption{obscurer.WithRemovalConfirmation(func(ctx context.Context, u *url.URL) (bool, error) {
+				return false, nil
+			})},
+			statuses: []int{404},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			ctx := context.Background()
+			store := obscurer.NewMemoryStore()
+			original := mustParse("/this/is/the/way")
+			obscured := obscurer.Default.Obscure(original)
+			assert.NoError(t, store.Put(ctx, obscured, original))
+			status := 0
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(status)
+			})
+			handler := obscurer.NewHandler(obscurer.Default, store, h, test.opts...)
+
+			// action.
+			for _, status = range test.statuses {
+				response := httptest.NewRecorder()
+				handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, obscured.String(), nil))
+			}
+
+			// assert.
+			_, ok, err := store.Get(ctx, obscured)
+			assert.NoError(t, err)
+			assert.Equal(t, test.removed, !ok)
+		})
+	}
+}
+
+// TestHandler_Removal_ConfirmationError tests that the response is replaced
+// with an error when the removal fails to be confirmed.
+func TestHandler_Removal_ConfirmationError(t *testing.T) {
+	// arrange.
+	store := obscurer.NewMemoryStore()
+	original := mustParse("/this/is/not/the/way")
+	obscured := obscurer.Default.Obscure(original)
+	assert.NoError(t, store.Put(context.Background(), obscured, original))
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	confirm := func(ctx context.Context, u *url.URL) (bool, error) {
+		return false, errors.New("whoa")
+	}
+	handler := obscurer.NewHandler(obscurer.Default, store, h, obscurer.WithRemovalConfirmation(confirm))
+	response := httptest.NewRecorder()
+
+	// action.
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, obscured.String(), nil))
+
+	// assert.
+	assert.Equal(t, http.StatusInternalServerError, response.Code)
+	assert.Equal(t, obscurer.ErrFailedRemoval.Error()+"\n", response.Body.String())
+}
+
+// TestHandler_Removal_Unresolved tests that the 404 Not Found responses for
+// obscured URLs that don't resolve are neither confirmed nor counted toward
+// the removal of a mapping placed afterwards.
+func TestHandler_Removal_Unresolved(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	store := obscurer.NewMemoryStore()
+	original := mustParse("/this/is/the/way")
+	obscured := obscurer.Default.Obscure(original)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	confirmations := 0
+	confirm := func(ctx context.Context, u *url.URL) (bool, error) {
+		confirmations++
+		return true, nil
+	}
+	handler := obscurer.NewHandler(
+		obscurer.Default,
+		store,
+		h,
+		obscurer.WithRemovalThreshold(2),
+		obscurer.WithRemovalConfirmation(confirm),
+	)
+	request := func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, obscured.String(), nil))
+	}
+
+	// action.
+	request()
+	request()
+	assert.NoError(t, store.Put(ctx, obscured, original))
+	request()
+
+	// assert.
+	assert.Equal(t, 0, confirmations)
+	_, ok, err := store.Get(ctx, obscured)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
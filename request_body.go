@@ -0,0 +1,142 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// DefaultMaxRequestBody is the maximum length of the request bodies read to
+// be rewritten when none is configured.
+const DefaultMaxRequestBody = 1 << 20
+
+// ErrRequestBodyTooLarge represents an error that occurs when the body of a
+// request to be rewritten exceeds the maximum length.
+var ErrRequestBodyTooLarge = errors.New("obscurer: request body too large")
+
+// WithRequestBodyResolution configures the handler to resolve the obscured
+// URLs of the host of the request held by JSON request bodies, such as those
+// clients echo back in PATCH and POST requests, to their original form
+// before handling them. Only the members with the provided names are
+// resolved, or any member when none are provided, and only the URLs whose
+// path matches the ObscuredPaths when configured, such that the store isn't
+// looked up for every string. Values that aren't obscured URLs are left as
+// is.
+func WithRequestBodyResolution(fields ...string) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.ResolveRequestBodies = true
+		o.RequestBodyFields = fields
+	}
+}
+
+// WithMaxRequestBody configures the maximum length of the request bodies
+// read to be rewritten. Requests with longer bodies are rejected with 413
+// Request Entity Too Large.
+func WithMaxRequestBody(n int) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.MaxRequestBody = n
+	}
+}
+
+// lookupError represents an error that occurs when looking up an obscured
+// URL within the body of a request.
+type lookupError struct {
+	err error
+}
+
+// Error describes the error that occurred looking up the URL.
+func (e *lookupError) Error() string {
+	return e.err.Error()
+}
+
+// decodeBody replaces the body of the provided request with one whose
+// identifiers are decoded and whose obscured URLs are resolved, as
+// requested. Bodies that aren't valid JSON are left as is, as are bodies
+// whose URLs fail to be looked up, which is reported as a *lookupError.
+// Bodies exceeding the maximum length fail with ErrRequestBodyTooLarge.
+func (h *handler) decodeBody(w http.ResponseWriter, r *http.Request, ids, urls bool) error {
+	limit := int64(h.options.MaxRequestBody)
+	if limit == 0 {
+		limit = DefaultMaxRequestBody
+	}
+	if r.ContentLength > limit {
+		r.Body.Close()
+		return ErrRequestBodyTooLarge
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	r.Body.Close()
+	if err != nil && int64(len(body)) >= limit {
+		return ErrRequestBodyTooLarge
+	}
+	if err != nil {
+		return err
+	}
+	var failure error
+	decoded, err := rewriteJSON(body, func(field string, value interface{}) (interface{}, bool) {
+		if ids {
+			if decoded, ok := h.ids.decodeValue(field, value); ok {
+				return decoded, true
+			}
+		}
+		link, ok := value.(string)
+		if !urls || !ok || failure != nil || !h.resolvableField(field, link) {
+			return nil, false
+		}
+		resolved, ok, err := h.resolveLink(r.Context(), r.Host, link)
+		if err != nil {
+			failure = &lookupError{err: err}
+		}
+		return resolved, ok && err == nil
+	})
+	if err != nil {
+		h.logger().Debugf("obscurer: left the body of %s as is, as it isn't valid JSON: %v", r.URL, err)
+	}
+	if err == nil && failure == nil {
+		body = decoded
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	if r.Header.Get("Content-Length") != "" {
+		r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+	return failure
+}
+
+// resolvableField indicates whether the provided value of the member of a
+// request body with the provided name is to be looked up, as it's held by
+// one of the configured members, and its path matches the patterns of
+// obscured URLs.
+func (h *handler) resolvableField(field, value string) bool {
+	if fields := h.options.RequestBodyFields; len(fields) > 0 {
+		held := false
+		for _, f := range fields {
+			held = held || f == field
+		}
+		if !held {
+			return false
+		}
+	}
+	if len(h.options.ObscuredPaths) == 0 {
+		return true
+	}
+	u, err := url.Parse(value)
+	return err == nil && matchAny(h.options.ObscuredPaths, u.Path)
+}
@@ -0,0 +1,174 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/internal/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHandler_RequestBodyResolution tests that the obscured URLs within JSON
+// request bodies are resolved to their original form.
+func TestHandler_RequestBodyResolution(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	store := obscurer.NewMemoryStore()
+	parent := mustParse("/folders/42")
+	obscured := obscurer.Default.Obscure(parent)
+	require.NoError(t, store.Put(ctx, obscured, parent))
+	var received string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received = string(body)
+		assert.Equal(t, int64(len(body)), r.ContentLength)
+	})
+	handler := obscurer.NewHandler(obscurer.Default, store, h, obscurer.WithRequestBodyResolution())
+	body := `{"parent":"` + obscured.String() + `","links":["http://example.com` + obscured.String() + `","https://freerware.com` + obscured.String() + `"],"name":"/about","size":7}`
+	request := httptest.NewRequest(http.MethodPost, "http://example.com/folders", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+
+	// action.
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	// assert.
+	assert.Equal(t, `{"parent":"/folders/42","links":["http://example.com/folders/42","https://freerware.com`+obscured.String()+`"],"name":"/about","size":7}`, received)
+}
+
+// TestHandler_RequestBodyResolution_LookupError tests that the request isn't
+// handled when the URLs within its body fail to be looked up.
+func TestHandler_RequestBodyResolution_LookupError(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mock.NewStore(ctrl)
+	handled := false
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handled = true
+	})
+	handler := obscurer.NewHandler(obscurer.Default, store, h, obscurer.WithRequestBodyResolution())
+	store.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, false, nil)
+	store.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, false, errors.New("whoa"))
+	request := httptest.NewRequest(http.MethodPost, "/folders", strings.NewReader(`{"parent":"/abc"}`))
+	request.Header.Set("Content-Type", "application/json")
+	response := httptest.NewRecorder()
+
+	// action.
+	handler.ServeHTTP(response, request)
+
+	// assert.
+	assert.False(t, handled)
+	assert.Equal(t, http.StatusInternalServerError, response.Code)
+	assert.Equal(t, obscurer.ErrFailedLookup.Error()+"\n", response.Body.String())
+}
+
+// TestHandler_RequestBodyResolution_Restricted tests that only the values of
+// the configured members, whose path matches the patterns of obscured URLs,
+// are looked up.
+func TestHandler_RequestBodyResolution_Restricted(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []obscurer.HandlerOption
+		body string
+	}{
+		{
+			name: "Fields",
+			opts: []obscurer.HandlerOption{obscurer.WithRequestBodyResolution("parent")},
+			body: `{"parent":"/abc","name":"/about","title":"hello"}`,
+		},
+		{
+			name: "ObscuredPaths",
+			opts: []obscurer.HandlerOption{
+				obscurer.WithRequestBodyResolution(),
+				obscurer.WithObscuredPaths(obscurer.Glob("/abc")),
+			},
+			body: `{"parent":"/abc","name":"/about","title":"hello"}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			store := mock.NewStore(ctrl)
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+			handler := obscurer.NewHandler(obscurer.Default, store, h, test.opts...)
+			store.EXPECT().Get(gomock.Any(), gomock.Any()).Return(mustParse("/folders/42"), true, nil)
+			store.EXPECT().Get(gomock.Any(), mustParse("/abc")).Return(nil, false, nil)
+			request := httptest.NewRequest(http.MethodPost, "/folders", strings.NewReader(test.body))
+			request.Header.Set("Content-Type", "application/json")
+			response := httptest.NewRecorder()
+
+			// action.
+			handler.ServeHTTP(response, request)
+
+			// assert.
+			assert.Equal(t, http.StatusOK, response.Code)
+		})
+	}
+}
+
+// TestHandler_RequestBodyResolution_TooLarge tests that requests whose body
+// exceeds the maximum length are rejected without being handled.
+func TestHandler_RequestBodyResolution_TooLarge(t *testing.T) {
+	tests := []struct {
+		name          string
+		contentLength bool
+	}{
+		{name: "ContentLength", contentLength: true},
+		{name: "Chunked"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			handled := false
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handled = true
+			})
+			handler := obscurer.NewHandler(
+				obscurer.Default,
+				obscurer.NewMemoryStore(),
+				h,
+				obscurer.WithRequestBodyResolution(),
+				obscurer.WithMaxRequestBody(8),
+			)
+			request := httptest.NewRequest(http.MethodPost, "/folders", strings.NewReader(`{"parent":"/abc"}`))
+			if !test.contentLength {
+				request.ContentLength = -1
+			}
+			request.Header.Set("Content-Type", "application/json")
+			response := httptest.NewRecorder()
+
+			// action.
+			handler.ServeHTTP(response, request)
+
+			// assert.
+			assert.False(t, handled)
+			assert.Equal(t, http.StatusRequestEntityTooLarge, response.Code)
+			assert.Equal(t, obscurer.ErrRequestBodyTooLarge.Error()+"\n", response.Body.String())
+		})
+	}
+}
@@ -0,0 +1,60 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"context"
+	"net/url"
+)
+
+// routeTable resolves the obscured forms of the literal routes of a route
+// table.
+type routeTable struct {
+	Obscurer
+	// originals holds the paths of the routes keyed by their obscured form.
+	originals map[string]string
+}
+
+// NewRouteTableResolver constructs a resolver for the routes of the provided
+// source without parameters, such as '/about', as obscured by the provided
+// deterministic obscurer, which the resolver obscures URLs with. Routes with
+// parameters can't be enumerated, and are therefore skipped, as are the
+// obscured forms rerolled to resolve collisions.
+func NewRouteTableResolver(ctx context.Context, o Obscurer, source RouteSource) (Resolver, error) {
+	templates, err := source.Routes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r := &routeTable{Obscurer: o, originals: make(map[string]string)}
+	for _, t := range templates {
+		if len(t.Parameters()) > 0 {
+			continue
+		}
+		original := t.expand(nil)
+		r.originals[o.Obscure(&url.URL{Path: original}).Path] = original
+	}
+	return r, nil
+}
+
+// Resolve resolves the provided obscured URL to the route it was obscured
+// from.
+func (r *routeTable) Resolve(obscured *url.URL) (*url.URL, bool) {
+	original, ok := r.originals[obscured.Path]
+	if !ok {
+		return nil, false
+	}
+	return withPath(obscured, original), true
+}
@@ -0,0 +1,70 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNewRouteTableResolver tests that the obscured forms of the routes
+// without parameters resolve to their route.
+func TestNewRouteTableResolver(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	o := obscurer.NewMD5()
+	source := obscurer.RouteSourceFunc(func(ctx context.Context) ([]obscurer.RouteTemplate, error) {
+		return []obscurer.RouteTemplate{
+			obscurer.MustParseRouteTemplate("/about"),
+			obscurer.MustParseRouteTemplate("/users/{id}"),
+		}, nil
+	})
+
+	// action.
+	r, err := obscurer.NewRouteTableResolver(ctx, o, source)
+
+	// assert.
+	require.NoError(t, err)
+	obscured := o.Obscure(mustParse("/about?x=1"))
+	got, ok := r.Resolve(obscured)
+	require.True(t, ok)
+	assert.Equal(t, "/about?x=1", got.String())
+	_, ok = r.Resolve(o.Obscure(mustParse("/users/42")))
+	assert.False(t, ok)
+	assert.Equal(t, obscured, r.Obscure(mustParse("/about?x=1")))
+}
+
+// TestNewRouteTableResolver_Error tests that failures to provide the routes
+// result in an error.
+func TestNewRouteTableResolver_Error(t *testing.T) {
+	// arrange.
+	expectedErr := errors.New("whoa")
+	source := obscurer.RouteSourceFunc(func(ctx context.Context) ([]obscurer.RouteTemplate, error) {
+		return nil, expectedErr
+	})
+
+	// action.
+	r, err := obscurer.NewRouteTableResolver(context.Background(), obscurer.NewMD5(), source)
+
+	// assert.
+	assert.Equal(t, expectedErr, err)
+	assert.Nil(t, r)
+}
@@ -15,39 +15,235 @@
 
 package obscurer
 
-import "net/http"
+import (
+	"bufio"
+	"errors"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// errNotHijacker represents an error that occurs when hijacking a response
+// whose underlying http.ResponseWriter doesn't support it.
+var errNotHijacker = errors.New("obscurer: response writer doesn't support hijacking")
 
 // responseWriter is a decorator around the original http.ResponseWriter.
-// this allows for our handler to determine the status code that is going
-// to be returned to the client so we can act on it.
+// this allows for our handler to act on the status code and headers of the
+// response before any of it reaches the client, after which the body is
+// streamed straight through, unless it is to be buffered.
 type responseWriter struct {
 	http.ResponseWriter
 
+	// commit finalizes the headers of the response before any of it is
+	// written, deciding whether the body is to be buffered. When it fails,
+	// the response has been replaced with an error, and the remainder of
+	// the response is discarded.
+	commit func() error
+	// buffer indicates whether the body is buffered until the response is
+	// closed, rather than streamed.
+	buffer bool
+	// stream indicates whether each write is flushed to the client as soon
+	// as it is written, such as for server-sent events.
+	stream bool
+	// limit is the maximum length of the buffered body, beyond which the
+	// body is passed through as is. Zero buffers it regardless.
+	limit int
+	// overflowed indicates whether the body was passed through as is, as
+	// it exceeded the limit.
+	overflowed bool
+	committed  bool
+	err        error
+
 	body   []byte
 	status int
 }
 
+// WriteHeader captures the status code being set for the response, which is
+// written to the underlying http.ResponseWriter once the response is
+// committed.
+func (rw *responseWriter) WriteHeader(code int) {
+	if !rw.committed {
+		rw.status = code
+	}
+}
+
+// Write commits the response upon the first write, and then writes the
+// provided body to the underlying http.ResponseWriter, or to the buffer
+// when the body is buffered. Once the buffered body would exceed the limit,
+// it is written as is, and the remainder of the body passed through.
 func (rw *responseWriter) Write(body []byte) (int, error) {
-	rw.body = body
-	return len(body), nil
+	if err := rw.start(); err != nil {
+		return 0, err
+	}
+	if rw.buffer {
+		if rw.limit <= 0 || len(rw.body)+len(body) <= rw.limit {
+			rw.body = append(rw.body, body...)
+			return len(body), nil
+		}
+		if err := rw.overflow(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rw.ResponseWriter.Write(body)
+	if err == nil && rw.stream {
+		rw.Flush()
+	}
+	return n, err
 }
 
-// WriterHeader captures the status code being set for the response,
-// and delegates to the underlying http.ResponseWriter.
-func (rw *responseWriter) WriteHeader(code int) {
-	rw.status = code
+// Flush commits the response, and then flushes the underlying
+// http.ResponseWriter when it supports it. Buffered bodies are not flushed
+// until the response is closed.
+func (rw *responseWriter) Flush() {
+	if rw.start() != nil || rw.buffer {
+		return
+	}
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack hands the connection of the response over to the caller, such as
+// to upgrade it to a WebSocket, when the underlying http.ResponseWriter
+// supports it. The response is considered complete once hijacked, so its
+// headers are neither obscured nor written.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errNotHijacker
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	rw.committed = true
+	rw.err = http.ErrHijacked
+	return conn, buf, nil
 }
 
-// Flush writes the status code to the underlying http.ResponseWriter.
-func (rw *responseWriter) Do() (written int, err error) {
-	// write the HTTP status code to the underlying http.ResponseWriter.
+// CloseNotify notifies when the connection of the response goes away, when
+// the underlying http.ResponseWriter supports it. Otherwise, the returned
+// channel never receives.
+func (rw *responseWriter) CloseNotify() <-chan bool {
+	if notifier, ok := rw.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return make(chan bool)
+}
+
+// overflow stops buffering the body, writing the status code and the body
+// buffered so far to the underlying http.ResponseWriter.
+func (rw *responseWriter) overflow() error {
+	rw.buffer = false
+	rw.overflowed = true
 	if rw.status != 0 {
 		rw.ResponseWriter.WriteHeader(rw.status)
 	}
-	// if we have content in the body, write that to the underlying
-	// http.ResponseWriter.
+	body := rw.body
+	rw.body = nil
+	if len(body) == 0 {
+		return nil
+	}
+	_, err := rw.ResponseWriter.Write(body)
+	return err
+}
+
+// start commits the response once, writing the status code to the
+// underlying http.ResponseWriter unless the body is buffered.
+func (rw *responseWriter) start() error {
+	if rw.committed {
+		return rw.err
+	}
+	rw.committed = true
+	if rw.err = rw.commit(); rw.err != nil {
+		return rw.err
+	}
+	if !rw.buffer && rw.status != 0 {
+		rw.ResponseWriter.WriteHeader(rw.status)
+	}
+	return nil
+}
+
+// Close completes the response, committing it when nothing was written,
+// and writing the status code and body to the underlying
+// http.ResponseWriter when the body is buffered, whose 'Content-Length'
+// header is set to the length of the body as rewritten. Responses with
+// trailers are left without a 'Content-Length' header instead, such that
+// they are chunked and their trailers written.
+func (rw *responseWriter) Close() error {
+	if err := rw.start(); err != nil || !rw.buffer {
+		return err
+	}
+	// the values of the declared trailers are set by now, so they are
+	// withheld until the headers are written.
+	trailers := rw.detachTrailers()
+	if trailers != nil {
+		rw.Header().Del("Content-Length")
+	} else if len(rw.body) > 0 {
+		rw.Header().Set("Content-Length", strconv.Itoa(len(rw.body)))
+	}
+	if rw.status != 0 {
+		rw.ResponseWriter.WriteHeader(rw.status)
+	}
+	var err error
 	if len(rw.body) > 0 {
-		written, err = rw.ResponseWriter.Write(rw.body)
+		_, err = rw.ResponseWriter.Write(rw.body)
+	}
+	for key, values := range trailers {
+		rw.Header()[key] = values
+	}
+	return err
+}
+
+// detachTrailers removes the values of the trailers declared by the
+// 'Trailer' header from the headers of the response, providing them. A
+// non-nil, possibly empty, set of trailers is provided when the response
+// has trailers, including those set with the http.TrailerPrefix.
+func (rw *responseWriter) detachTrailers() http.Header {
+	var trailers http.Header
+	header := rw.Header()
+	for key := range header {
+		if strings.HasPrefix(key, http.TrailerPrefix) {
+			trailers = make(http.Header)
+			break
+		}
+	}
+	for _, declared := range header.Values("Trailer") {
+		for _, key := range strings.Split(declared, ",") {
+			key = http.CanonicalHeaderKey(strings.TrimSpace(key))
+			if key == "" {
+				continue
+			}
+			if trailers == nil {
+				trailers = make(http.Header)
+			}
+			if values, ok := header[key]; ok {
+				trailers[key] = values
+				delete(header, key)
+			}
+		}
+	}
+	return trailers
+}
+
+// isEventStream indicates whether the provided content type is that of
+// server-sent events.
+func isEventStream(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "text/event-stream"
+}
+
+// isEncoded indicates whether the provided 'Content-Encoding' header denotes
+// a body with a content coding other than identity applied, such as gzip,
+// whose content can't be rewritten without decoding it.
+func isEncoded(contentEncoding string) bool {
+	for _, coding := range strings.Split(contentEncoding, ",") {
+		coding = strings.TrimSpace(coding)
+		if coding != "" && !strings.EqualFold(coding, "identity") {
+			return true
+		}
 	}
-	return
+	return false
 }
@@ -0,0 +1,227 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Resolver represents an obscurer capable of resolving the obscured URLs it
+// produces without the store. The handler neither places mappings for such
+// URLs into the store, nor looks them up in it.
+type Resolver interface {
+	Obscurer
+
+	// Resolve resolves the provided obscured URL to its original form,
+	// returning false when the URL wasn't obscured by the resolver.
+	Resolve(obscured *url.URL) (*url.URL, bool)
+}
+
+// ObscureRouteTemplate obscures the literal segments of the provided route
+// template with the provided obscurer, preserving its parameters, such that
+// '/users/{id}/orders' is obscured to '/<obscured>/{id}/<obscured>'. An error
+// is returned when an obscured literal segment cannot be part of a route
+// template.
+func ObscureRouteTemplate(o Obscurer, t RouteTemplate) (RouteTemplate, error) {
+	parts := make([]string, len(t.segments))
+	for i, segment := range t.segments {
+		switch {
+		case segment.parameter:
+			parts[i] = "{" + segment.value + "}"
+		case segment.value != "":
+			obscured := strings.TrimPrefix(o.Obscure(&url.URL{Path: "/" + segment.value}).Path, "/")
+			if obscured == "" || strings.ContainsAny(obscured, "/{}") {
+				return RouteTemplate{}, fmt.Errorf(
+					"%w: literal %q of %q obscures to %q", ErrInvalidTemplate, segment.value, t, obscured)
+			}
+			parts[i] = obscured
+		}
+	}
+	return ParseRouteTemplate("/" + strings.Join(parts, "/"))
+}
+
+// expand substitutes the provided parameter values into the route template,
+// providing the resulting path.
+func (t RouteTemplate) expand(values map[string]string) string {
+	parts := make([]string, len(t.segments))
+	for i, segment := range t.segments {
+		parts[i] = segment.value
+		if segment.parameter {
+			parts[i] = values[segment.value]
+		}
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// obscuredRoute represents a route template along with its obscured form.
+type obscuredRoute struct {
+	original RouteTemplate
+	obscured RouteTemplate
+}
+
+// RouteObscurer obscures URLs matching its route templates by obscuring
+// only the literal segments of the templates, leaving the parameter values
+// as is. As such URLs resolve by matching the obscured templates, dynamic
+// routes don't require a mapping in the store for every concrete URL.
+type RouteObscurer struct {
+	base   Obscurer
+	codec  IDCodec
+	routes []obscuredRoute
+}
+
+// NewRouteObscurer constructs an obscurer for the provided route templates,
+// whose literal segments are obscured with the provided obscurer. URLs that
+// match none of the route templates are obscured entirely with the provided
+// obscurer. Templates are matched in the order provided, and must have at
+// least one literal segment.
+func NewRouteObscurer(base Obscurer, templates ...string) (*RouteObscurer, error) {
+	return NewHybridObscurer(base, nil, templates...)
+}
+
+// NewHybridObscurer constructs an obscurer for the provided route templates
+// like NewRouteObscurer, additionally encoding the parameter values with the
+// provided codec, such that '/users/42/orders/7' matching
+// '/users/{id}/orders/{order}' is obscured to
+// '/<obscured>/<encoded>/<obscured>/<encoded>'. Obscured URLs resolve in two
+// stages, matching the obscured templates and then decoding the parameter
+// values, so neither the routes nor the resources require mappings in the
+// store. URLs whose parameter values fail to be encoded are obscured
+// entirely with the provided obscurer. Codecs implementing ParameterIDCodec
+// select the codec of each parameter, and a nil codec leaves the parameter
+// values as is. See the idcodec package for codecs.
+func NewHybridObscurer(base Obscurer, codec IDCodec, templates ...string) (*RouteObscurer, error) {
+	o := &RouteObscurer{base: base, codec: codec}
+	for _, template := range templates {
+		t, err := ParseRouteTemplate(template)
+		if err != nil {
+			return nil, err
+		}
+		if len(t.Parameters()) == len(t.segments) {
+			return nil, fmt.Errorf("%w: %q has no literal segments", ErrInvalidTemplate, template)
+		}
+		obscured, err := ObscureRouteTemplate(base, t)
+		if err != nil {
+			return nil, err
+		}
+		o.routes = append(o.routes, obscuredRoute{original: t, obscured: obscured})
+	}
+	return o, nil
+}
+
+// Templates provides the obscured route templates, in the order provided,
+// which can be registered with a router to route obscured URLs directly.
+func (o *RouteObscurer) Templates() []RouteTemplate {
+	templates := make([]RouteTemplate, len(o.routes))
+	for i, r := range o.routes {
+		templates[i] = r.obscured
+	}
+	return templates
+}
+
+// Obscure obscures the provided URL.
+func (o *RouteObscurer) Obscure(u *url.URL) *url.URL {
+	return o.Reroll(u, 0)
+}
+
+// Reroll obscures the provided URL for the provided attempt. URLs matching a
+// route template always obscure to the same URL, as they never collide with
+// mappings in the store.
+func (o *RouteObscurer) Reroll(u *url.URL, attempt int) *url.URL {
+	for _, r := range o.routes {
+		values, ok := r.original.Match(u.Path)
+		if !ok {
+			continue
+		}
+		if encoded, err := o.encode(values); err == nil {
+			return withPath(u, r.obscured.expand(encoded))
+		}
+		break
+	}
+	if reroller, ok := o.base.(Reroller); ok && attempt > 0 {
+		return reroller.Reroll(u, attempt)
+	}
+	return o.base.Obscure(u)
+}
+
+// Resolve resolves the provided obscured URL to its original form by
+// matching it against the obscured route templates.
+func (o *RouteObscurer) Resolve(obscured *url.URL) (*url.URL, bool) {
+	for _, r := range o.routes {
+		values, ok := r.obscured.Match(obscured.Path)
+		if !ok {
+			continue
+		}
+		if decoded, err := o.decode(values); err == nil {
+			return withPath(obscured, r.original.expand(decoded)), true
+		}
+	}
+	return nil, false
+}
+
+// encode encodes the provided parameter values with the codec of the
+// obscurer.
+func (o *RouteObscurer) encode(values map[string]string) (map[string]string, error) {
+	return o.transcode(values, IDCodec.Encode)
+}
+
+// decode decodes the provided parameter values with the codec of the
+// obscurer.
+func (o *RouteObscurer) decode(values map[string]string) (map[string]string, error) {
+	return o.transcode(values, IDCodec.Decode)
+}
+
+// transcode applies the provided function with the codec of each parameter
+// to the provided parameter values, ensuring the results remain valid path
+// segments. Values of parameters without a codec are provided as is.
+func (o *RouteObscurer) transcode(values map[string]string, fn func(IDCodec, string) (string, error)) (map[string]string, error) {
+	if o.codec == nil {
+		return values, nil
+	}
+	result := make(map[string]string, len(values))
+	for name, value := range values {
+		codec := o.codec
+		if selector, ok := codec.(ParameterIDCodec); ok {
+			codec = selector.Codec(name)
+		}
+		if codec == nil {
+			result[name] = value
+			continue
+		}
+		transcoded, err := fn(codec, value)
+		if err != nil {
+			return nil, err
+		}
+		if transcoded == "" || strings.Contains(transcoded, "/") {
+			return nil, fmt.Errorf("%w: %q of parameter %q", ErrInvalidID, value, name)
+		}
+		result[name] = transcoded
+	}
+	return result, nil
+}
+
+// withPath provides a copy of the provided URL with the provided path,
+// preserving its trailing slash.
+func withPath(u *url.URL, path string) *url.URL {
+	result := *u
+	result.Path = path
+	if strings.HasSuffix(u.Path, "/") && !strings.HasSuffix(path, "/") {
+		result.Path = result.Path + "/"
+	}
+	result.RawPath = ""
+	return &result
+}
@@ -0,0 +1,165 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestObscureRouteTemplate tests that only the literal segments of route
+// templates are obscured.
+func TestObscureRouteTemplate(t *testing.T) {
+	// arrange.
+	template := obscurer.MustParseRouteTemplate("/users/{id}/orders")
+	users := strings.TrimPrefix(obscurer.Default.Obscure(&url.URL{Path: "/users"}).Path, "/")
+	orders := strings.TrimPrefix(obscurer.Default.Obscure(&url.URL{Path: "/orders"}).Path, "/")
+
+	// action.
+	obscured, err := obscurer.ObscureRouteTemplate(obscurer.Default, template)
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("/%s/{id}/%s", users, orders), obscured.String())
+	assert.Equal(t, []string{"id"}, obscured.Parameters())
+}
+
+// TestRouteObscurer tests that URLs matching a route template are obscured
+// with the obscured template, and resolve back to their original form.
+func TestRouteObscurer(t *testing.T) {
+	// arrange.
+	o, err := obscurer.NewRouteObscurer(obscurer.Default, "/users/{id}/orders/{orderID}")
+	require.NoError(t, err)
+	original := mustParse("/users/42/orders/7")
+
+	// action.
+	obscured := o.Obscure(original)
+
+	// assert.
+	values, ok := o.Templates()[0].Match(obscured.Path)
+	require.True(t, ok, "expected %q to match the obscured template", obscured)
+	assert.Equal(t, map[string]string{"id": "42", "orderID": "7"}, values)
+	resolved, ok := o.Resolve(obscured)
+	require.True(t, ok)
+	assert.Equal(t, original.String(), resolved.String())
+	_, ok = o.Resolve(mustParse("/users/42/orders/7"))
+	assert.False(t, ok, "expected the original URL not to resolve")
+	assert.Equal(t, obscurer.Default.Obscure(mustParse("/other")), o.Obscure(mustParse("/other")))
+}
+
+// prefixCodec encodes parameter values by prefixing them, failing to encode
+// the values it rejects.
+type prefixCodec struct {
+	prefix string
+	reject string
+}
+
+func (c prefixCodec) Encode(value string) (string, error) {
+	if value == c.reject {
+		return "", obscurer.ErrInvalidID
+	}
+	return c.prefix + value, nil
+}
+
+func (c prefixCodec) Decode(encoded string) (string, error) {
+	if !strings.HasPrefix(encoded, c.prefix) {
+		return "", obscurer.ErrInvalidID
+	}
+	return strings.TrimPrefix(encoded, c.prefix), nil
+}
+
+// TestHybridObscurer tests that the parameter values of URLs matching a
+// route template are encoded, and decoded when resolving.
+func TestHybridObscurer(t *testing.T) {
+	// arrange.
+	codec := prefixCodec{prefix: "x", reject: "0"}
+	o, err := obscurer.NewHybridObscurer(obscurer.Default, codec, "/users/{id}/orders/{orderID}")
+	require.NoError(t, err)
+	original := mustParse("/users/42/orders/7")
+
+	// action.
+	obscured := o.Obscure(original)
+
+	// assert.
+	values, ok := o.Templates()[0].Match(obscured.Path)
+	require.True(t, ok, "expected %q to match the obscured template", obscured)
+	assert.Equal(t, map[string]string{"id": "x42", "orderID": "x7"}, values)
+	resolved, ok := o.Resolve(obscured)
+	require.True(t, ok)
+	assert.Equal(t, original.String(), resolved.String())
+	unencoded := strings.Replace(obscured.Path, "x42", "42", 1)
+	_, ok = o.Resolve(mustParse(unencoded))
+	assert.False(t, ok, "expected values that fail to decode not to resolve")
+	rejected := mustParse("/users/0/orders/7")
+	assert.Equal(t, obscurer.Default.Obscure(rejected), o.Obscure(rejected))
+}
+
+// TestNewRouteObscurer_Invalid tests that route templates without literal
+// segments result in an error.
+func TestNewRouteObscurer_Invalid(t *testing.T) {
+	// action.
+	_, err := obscurer.NewRouteObscurer(obscurer.Default, "/{id}")
+
+	// assert.
+	assert.True(t, errors.Is(err, obscurer.ErrInvalidTemplate))
+}
+
+// TestHandler_RouteObscurer tests that URLs obscured with route templates
+// are resolved without mappings in the store.
+func TestHandler_RouteObscurer(t *testing.T) {
+	// arrange.
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+	o, err := obscurer.NewRouteObscurer(obscurer.Default, "/users/{id}")
+	require.NoError(err)
+	var handled string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/", func(w http.ResponseWriter, r *http.Request) {
+		handled = r.URL.Path
+		w.Header().Add("Location", "/users/43")
+		w.WriteHeader(http.StatusCreated)
+	})
+	store := obscurer.DefaultStore
+	handler := obscurer.NewHandler(o, store, mux)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// action.
+	response, err := http.Get(server.URL + o.Obscure(mustParse("/users/42")).Path)
+
+	// assert.
+	require.NoError(err)
+	assert.Equal(http.StatusCreated, response.StatusCode)
+	assert.Equal("/users/42", handled)
+	assert.Equal(o.Obscure(mustParse("/users/43")).Path, response.Header.Get("Location"))
+	assert.Equal(0, store.Size(ctx), "expected no mappings in the store")
+
+	// cleanup.
+	t.Cleanup(func() {
+		store.Clear(ctx)
+	})
+}
@@ -0,0 +1,244 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package routes provides obscurer.RouteSource adapters for common routers,
+// translating the route paths of each router into route templates.
+//
+// Routes that cannot be expressed as a route template, such as prefix or
+// catch-all routes, and segments mixing literals with parameters, are
+// skipped. Duplicate templates, such as the same path registered for several
+// methods, are provided once.
+//
+// Adapters are provided for gorilla/mux, gin, and the patterns of the
+// net/http ServeMux. Other routers are adapted with New by enumerating their
+// route paths; for example, chi:
+//
+//	routes.New(routes.Braces, func(ctx context.Context) (paths []string, err error) {
+//		err = chi.Walk(r, func(method, route string, h http.Handler, m ...func(http.Handler) http.Handler) error {
+//			paths = append(paths, route)
+//			return nil
+//		})
+//		return
+//	})
+//
+// and echo:
+//
+//	routes.New(routes.Colons, func(ctx context.Context) (paths []string, err error) {
+//		for _, route := range e.Routes() {
+//			paths = append(paths, route.Path)
+//		}
+//		return
+//	})
+package routes
+
+import (
+	"context"
+	"strings"
+
+	"github.com/freerware/obscurer"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/mux"
+)
+
+// Syntax represents the syntax of the parameters of route paths.
+type Syntax int
+
+const (
+	// Braces represents parameters enclosed in braces, such as
+	// '/users/{id}', optionally followed by a pattern, such as
+	// '/users/{id:[0-9]+}', as used by gorilla/mux, chi, and the net/http
+	// ServeMux.
+	Braces Syntax = iota
+	// Colons represents parameters prefixed with a colon, such as
+	// '/users/:id', and catch-all parameters prefixed with an asterisk, as
+	// used by gin and echo.
+	Colons
+)
+
+// PathsFunc enumerates the route paths of a router.
+type PathsFunc func(ctx context.Context) ([]string, error)
+
+// source provides the route templates of the route paths it enumerates.
+type source struct {
+	syntax Syntax
+	paths  PathsFunc
+}
+
+// New constructs a route source translating the route paths enumerated by
+// the provided function, written in the provided syntax.
+func New(syntax Syntax, paths PathsFunc) obscurer.RouteSource {
+	return &source{syntax: syntax, paths: paths}
+}
+
+// Routes provides the route templates of the router.
+func (s *source) Routes(ctx context.Context) ([]obscurer.RouteTemplate, error) {
+	paths, err := s.paths(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(paths))
+	var templates []obscurer.RouteTemplate
+	for _, path := range paths {
+		template, ok := Translate(s.syntax, path)
+		if !ok || seen[template.String()] {
+			continue
+		}
+		seen[template.String()] = true
+		templates = append(templates, template)
+	}
+	return templates, nil
+}
+
+// Translate translates the provided route path, written in the provided
+// syntax, into a route template. False is returned when the route path cannot
+// be expressed as a route template.
+func Translate(syntax Syntax, path string) (obscurer.RouteTemplate, bool) {
+	if !strings.HasPrefix(path, "/") {
+		return obscurer.RouteTemplate{}, false
+	}
+	segments := splitSegments(path)
+	translated := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		var ok bool
+		if syntax == Colons {
+			segment, ok = translateColon(segment)
+		} else {
+			segment, ok = translateBrace(segment, i == len(segments)-1)
+		}
+		if !ok {
+			return obscurer.RouteTemplate{}, false
+		}
+		if segment != "" {
+			translated = append(translated, segment)
+		}
+	}
+	template, err := obscurer.ParseRouteTemplate("/" + strings.Join(translated, "/"))
+	if err != nil {
+		return obscurer.RouteTemplate{}, false
+	}
+	return template, true
+}
+
+// splitSegments splits the provided path into its segments, ignoring
+// slashes within braces, which may appear in parameter patterns.
+func splitSegments(path string) (segments []string) {
+	depth, start := 0, 1
+	for i := 1; i < len(path); i++ {
+		switch path[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '/':
+			if depth == 0 {
+				segments = append(segments, path[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if start < len(path) {
+		segments = append(segments, path[start:])
+	}
+	return
+}
+
+// translateColon translates a segment of a route path with parameters
+// prefixed with a colon.
+func translateColon(segment string) (string, bool) {
+	switch {
+	case strings.HasPrefix(segment, "*"):
+		return "", false
+	case strings.HasPrefix(segment, ":"):
+		return "{" + segment[1:] + "}", true
+	}
+	return segment, !strings.ContainsAny(segment, "{}")
+}
+
+// translateBrace translates a segment of a route path with parameters
+// enclosed in braces, dropping parameter patterns.
+func translateBrace(segment string, last bool) (string, bool) {
+	if !strings.HasPrefix(segment, "{") {
+		return segment, !strings.ContainsAny(segment, "{}")
+	}
+	if !strings.HasSuffix(segment, "}") {
+		return "", false
+	}
+	name := segment[1 : len(segment)-1]
+	if i := strings.Index(name, ":"); i >= 0 {
+		name = name[:i]
+	}
+	if strings.HasSuffix(name, "...") || (name == "$" && !last) || name == "" {
+		return "", false
+	}
+	if name == "$" {
+		// '{$}' only matches the trailing slash, which templates ignore.
+		return "", true
+	}
+	return "{" + name + "}", true
+}
+
+// Gorilla constructs a route source for the provided gorilla/mux router,
+// including the routes of its subrouters.
+func Gorilla(r *mux.Router) obscurer.RouteSource {
+	return New(Braces, func(ctx context.Context) (paths []string, err error) {
+		err = r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+			template, err := route.GetPathTemplate()
+			if err != nil {
+				// the route doesn't match on its path.
+				return nil
+			}
+			if pattern, err := route.GetPathRegexp(); err == nil && !strings.HasSuffix(pattern, "$") {
+				// the route matches on a path prefix.
+				return nil
+			}
+			paths = append(paths, template)
+			return nil
+		})
+		return
+	})
+}
+
+// Gin constructs a route source for the provided gin router.
+func Gin(e *gin.Engine) obscurer.RouteSource {
+	return New(Colons, func(ctx context.Context) (paths []string, err error) {
+		for _, route := range e.Routes() {
+			paths = append(paths, route.Path)
+		}
+		return
+	})
+}
+
+// ServeMux constructs a route source for the provided patterns registered
+// with a net/http ServeMux, which cannot enumerate its own patterns. Methods
+// and hosts of patterns, such as 'GET example.com/users/{id}', are ignored.
+func ServeMux(patterns ...string) obscurer.RouteSource {
+	return New(Braces, func(ctx context.Context) ([]string, error) {
+		paths := make([]string, 0, len(patterns))
+		for _, pattern := range patterns {
+			if i := strings.IndexAny(pattern, " \t"); i >= 0 {
+				pattern = strings.TrimLeft(pattern[i:], " \t")
+			}
+			if i := strings.Index(pattern, "/"); i > 0 {
+				pattern = pattern[i:]
+			}
+			if strings.HasSuffix(pattern, "/") {
+				// patterns ending in a slash match every path beneath them.
+				continue
+			}
+			paths = append(paths, pattern)
+		}
+		return paths, nil
+	})
+}
@@ -0,0 +1,140 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package routes_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/routes"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func strs(templates []obscurer.RouteTemplate) (s []string) {
+	for _, t := range templates {
+		s = append(s, t.String())
+	}
+	return
+}
+
+// TestTranslate tests that route paths are translated into route templates.
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		syntax routes.Syntax
+		path   string
+		want   string
+		ok     bool
+	}{
+		{routes.Braces, "/users/{id}/orders", "/users/{id}/orders", true},
+		{routes.Braces, "/users/{id:[0-9]{1,3}}", "/users/{id}", true},
+		{routes.Braces, "/files/{path...}", "", false},
+		{routes.Braces, "/users/{$}", "/users", true},
+		{routes.Braces, "/files/{name}.txt", "", false},
+		{routes.Colons, "/users/:id/orders", "/users/{id}/orders", true},
+		{routes.Colons, "/static/*filepath", "", false},
+		{routes.Colons, "users", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			// action.
+			got, ok := routes.Translate(test.syntax, test.path)
+
+			// assert.
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.want, got.String())
+		})
+	}
+}
+
+// TestGorilla tests that the routes of a gorilla/mux router and its
+// subrouters are provided, excluding prefix routes.
+func TestGorilla(t *testing.T) {
+	// arrange.
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	r := mux.NewRouter()
+	r.HandleFunc("/users/{id:[0-9]+}", handler).Methods(http.MethodGet)
+	r.HandleFunc("/users/{id:[0-9]+}", handler).Methods(http.MethodDelete)
+	r.PathPrefix("/static/").Handler(http.NotFoundHandler())
+	api := r.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/orders/{orderID}", handler)
+
+	// action.
+	templates, err := routes.Gorilla(r).Routes(context.Background())
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/users/{id}", "/api/orders/{orderID}"}, strs(templates))
+}
+
+// TestGin tests that the routes of a gin router are provided, excluding
+// catch-all routes.
+func TestGin(t *testing.T) {
+	// arrange.
+	gin.SetMode(gin.ReleaseMode)
+	handler := func(c *gin.Context) {}
+	e := gin.New()
+	e.GET("/users/:id", handler)
+	e.GET("/users/:id/orders", handler)
+	e.GET("/static/*filepath", handler)
+
+	// action.
+	templates, err := routes.Gin(e).Routes(context.Background())
+
+	// assert.
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"/users/{id}", "/users/{id}/orders"}, strs(templates))
+}
+
+// TestServeMux tests that the patterns of a net/http ServeMux are provided,
+// excluding subtree patterns.
+func TestServeMux(t *testing.T) {
+	// arrange.
+	source := routes.ServeMux(
+		"GET /users/{id}",
+		"example.com/users/{id}/orders",
+		"/static/",
+		"/{$}",
+		"POST /files/{path...}")
+
+	// action.
+	templates, err := source.Routes(context.Background())
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/users/{id}", "/users/{id}/orders", "/"}, strs(templates))
+}
+
+// TestNew_Error tests that failing to enumerate the route paths results in
+// an error.
+func TestNew_Error(t *testing.T) {
+	// arrange.
+	expectedErr := errors.New("whoa")
+	source := routes.New(routes.Braces, func(ctx context.Context) ([]string, error) {
+		return nil, expectedErr
+	})
+
+	// action.
+	_, err := source.Routes(context.Background())
+
+	// assert.
+	assert.Equal(t, expectedErr, err)
+}
@@ -0,0 +1,208 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package rpchttp integrates the obscurer with RPC-over-HTTP frameworks,
+// such as connect-go and Twirp, so that services built upon them obscure
+// the URLs within the headers of their responses and apply an
+// obscurer.IDCodec to the identifiers within their messages without custom
+// glue.
+//
+// NewHandler obscures the 'Location', 'Content-Location', and 'Link'
+// headers of unary calls, while streaming calls are passed to the service
+// as is, since the obscurer buffers responses until they are complete.
+//
+// The Interceptor applies an ID codec to the messages of calls, after they
+// have been unmarshaled, so that identifiers are transcoded regardless of
+// whether calls are encoded as JSON or as protobuf. It shares the semantics
+// of the grpcid package, and therefore must not be combined with the
+// obscurer.WithIDFields option of the handler, which would transcode the
+// identifiers of JSON calls twice.
+//
+// The interceptors depend on small function and interface types rather
+// than the frameworks directly. Twirp interceptors are installed as follows:
+//
+//	i := rpchttp.New(codec, "user_id", "order_id")
+//	server := NewOrdersServer(service, twirp.WithServerInterceptors(
+//		func(next twirp.Method) twirp.Method {
+//			return twirp.Method(i.Twirp(rpchttp.Method(next)))
+//		},
+//	))
+//	http.ListenAndServe(":8080", rpchttp.NewHandler(obscurer.Default, obscurer.DefaultStore, server))
+//
+// while connect-go interceptors are installed as follows:
+//
+//	path, h := ordersv1connect.NewOrdersHandler(service, connect.WithInterceptors(interceptor{i}))
+//
+// where interceptor adapts the Interceptor to connect.Interceptor:
+//
+//	type interceptor struct{ *rpchttp.Interceptor }
+//
+//	func (i interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+//		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+//			i.Decode(req)
+//			resp, err := next(ctx, req)
+//			if err == nil {
+//				i.Encode(resp)
+//			}
+//			return resp, err
+//		}
+//	}
+//
+//	func (i interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+//		return next
+//	}
+//
+//	func (i interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+//		return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+//			return next(ctx, streamingConn{conn, i.Conn(conn)})
+//		}
+//	}
+//
+// and streamingConn forwards the messages of the connection through the
+// wrapped connection:
+//
+//	type streamingConn struct {
+//		connect.StreamingHandlerConn
+//		wrapped rpchttp.StreamingConn
+//	}
+//
+//	func (c streamingConn) Send(m any) error    { return c.wrapped.Send(m) }
+//	func (c streamingConn) Receive(m any) error { return c.wrapped.Receive(m) }
+package rpchttp
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/grpcid"
+)
+
+// NewHandler constructs an HTTP handler obscuring the URLs within the
+// headers of the responses of the unary calls of the provided handler.
+// Streaming calls, identified by the media types of the connect and gRPC
+// protocols, are passed to the provided handler as is.
+func NewHandler(o obscurer.Obscurer, s obscurer.Store, h http.Handler, opts ...obscurer.HandlerOption) http.Handler {
+	obscured := obscurer.NewHandler(o, s, h, opts...)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if streaming(r.Header.Get("Content-Type")) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		obscured.ServeHTTP(w, r)
+	})
+}
+
+// streaming indicates whether the provided media type is that of a
+// streaming call, including every call of the gRPC and gRPC-Web protocols.
+func streaming(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "application/connect+") ||
+		strings.HasPrefix(mediaType, "application/grpc")
+}
+
+// Method represents a Twirp method, invoked with the unmarshaled request of
+// a call.
+type Method func(ctx context.Context, request interface{}) (interface{}, error)
+
+// Message represents a connect-go request or response, carrying a message.
+type Message interface {
+	// Any provides the message.
+	Any() interface{}
+}
+
+// StreamingConn represents the subset of a connect-go streaming handler
+// connection carrying the messages of a streaming call.
+type StreamingConn interface {
+	// Send sends the provided message to the client.
+	Send(m interface{}) error
+	// Receive receives the next message from the client into the provided
+	// message.
+	Receive(m interface{}) error
+}
+
+// Interceptor applies an ID codec to the identifiers within messages.
+type Interceptor struct {
+	ids *grpcid.Interceptor
+}
+
+// New constructs an interceptor encoding and decoding the string fields with
+// the provided names using the provided codec. Codecs implementing
+// obscurer.ParameterIDCodec select the codec of each field by its name.
+func New(codec obscurer.IDCodec, fields ...string) *Interceptor {
+	return &Interceptor{ids: grpcid.New(codec, fields...)}
+}
+
+// Twirp wraps the provided method, such that the identifiers within the
+// requests are decoded, and those within the responses are encoded.
+func (i *Interceptor) Twirp(next Method) Method {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		return i.ids.Unary(ctx, request, next)
+	}
+}
+
+// Conn wraps the provided connection, such that the identifiers within the
+// messages received are decoded, and those within the messages sent are
+// encoded.
+func (i *Interceptor) Conn(conn StreamingConn) StreamingConn {
+	return &streamingConn{StreamingConn: conn, interceptor: i}
+}
+
+// Encode encodes the identifiers within the provided message in place,
+// including the message carried by a connect-go response.
+func (i *Interceptor) Encode(message interface{}) {
+	i.ids.Encode(unwrap(message))
+}
+
+// Decode decodes the identifiers within the provided message in place,
+// including the message carried by a connect-go request.
+func (i *Interceptor) Decode(message interface{}) {
+	i.ids.Decode(unwrap(message))
+}
+
+// unwrap provides the message carried by the provided connect-go request or
+// response, or the provided message otherwise.
+func unwrap(message interface{}) interface{} {
+	if m, ok := message.(Message); ok {
+		return m.Any()
+	}
+	return message
+}
+
+// streamingConn applies the interceptor to the messages of a connection.
+type streamingConn struct {
+	StreamingConn
+	interceptor *Interceptor
+}
+
+// Send encodes the identifiers within the provided message, and sends it.
+func (c *streamingConn) Send(m interface{}) error {
+	c.interceptor.Encode(m)
+	return c.StreamingConn.Send(m)
+}
+
+// Receive receives the next message, and decodes the identifiers within it.
+func (c *streamingConn) Receive(m interface{}) error {
+	if err := c.StreamingConn.Receive(m); err != nil {
+		return err
+	}
+	c.interceptor.Decode(m)
+	return nil
+}
@@ -0,0 +1,219 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rpchttp_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/rpchttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// prefixCodec encodes identifiers by prefixing them.
+type prefixCodec struct{}
+
+func (prefixCodec) Encode(value string) (string, error) { return "x" + value, nil }
+
+func (prefixCodec) Decode(encoded string) (string, error) {
+	if !strings.HasPrefix(encoded, "x") {
+		return "", obscurer.ErrInvalidID
+	}
+	return strings.TrimPrefix(encoded, "x"), nil
+}
+
+// Order mirrors a generated message.
+type Order struct {
+	OrderId string `protobuf:"bytes,1,opt,name=order_id,json=orderId,proto3" json:"order_id,omitempty"`
+	Name    string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+}
+
+// envelope mirrors a connect-go request or response.
+type envelope struct {
+	msg interface{}
+}
+
+func (e *envelope) Any() interface{} { return e.msg }
+
+// conn is an in-memory streaming connection.
+type conn struct {
+	received []*Order
+	sent     []*Order
+	err      error
+}
+
+func (c *conn) Send(m interface{}) error {
+	c.sent = append(c.sent, m.(*Order))
+	return nil
+}
+
+func (c *conn) Receive(m interface{}) error {
+	if c.err != nil {
+		return c.err
+	}
+	*m.(*Order) = *c.received[0]
+	c.received = c.received[1:]
+	return nil
+}
+
+// TestInterceptor_Twirp tests that identifiers are decoded within requests
+// and encoded within responses of Twirp methods.
+func TestInterceptor_Twirp(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	i := rpchttp.New(prefixCodec{}, "order_id")
+	var received Order
+	method := i.Twirp(func(ctx context.Context, request interface{}) (interface{}, error) {
+		received = *request.(*Order)
+		return &Order{OrderId: "42", Name: "42"}, nil
+	})
+
+	// action.
+	resp, err := method(ctx, &Order{OrderId: "x42", Name: "x42"})
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, Order{OrderId: "42", Name: "x42"}, received)
+	assert.Equal(t, &Order{OrderId: "x42", Name: "42"}, resp)
+}
+
+// TestInterceptor_Twirp_Error tests that the responses of failed methods
+// are left as is.
+func TestInterceptor_Twirp_Error(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	i := rpchttp.New(prefixCodec{}, "order_id")
+	expectedErr := errors.New("whoa")
+	method := i.Twirp(func(ctx context.Context, request interface{}) (interface{}, error) {
+		return &Order{OrderId: "42"}, expectedErr
+	})
+
+	// action.
+	resp, err := method(ctx, &Order{OrderId: "x42"})
+
+	// assert.
+	assert.Equal(t, expectedErr, err)
+	assert.Equal(t, &Order{OrderId: "42"}, resp)
+}
+
+// TestInterceptor_Connect tests that the messages carried by connect-go
+// requests and responses are transcoded.
+func TestInterceptor_Connect(t *testing.T) {
+	// arrange.
+	i := rpchttp.New(prefixCodec{}, "order_id")
+	req := &envelope{msg: &Order{OrderId: "x42"}}
+	resp := &envelope{msg: &Order{OrderId: "7"}}
+
+	// action.
+	i.Decode(req)
+	i.Encode(resp)
+
+	// assert.
+	assert.Equal(t, &Order{OrderId: "42"}, req.msg)
+	assert.Equal(t, &Order{OrderId: "x7"}, resp.msg)
+}
+
+// TestInterceptor_Conn tests that identifiers are decoded within the
+// messages received and encoded within the messages sent on a connection.
+func TestInterceptor_Conn(t *testing.T) {
+	// arrange.
+	i := rpchttp.New(prefixCodec{}, "order_id")
+	underlying := &conn{received: []*Order{{OrderId: "x42"}}}
+	c := i.Conn(underlying)
+	var received Order
+
+	// action.
+	require.NoError(t, c.Receive(&received))
+	require.NoError(t, c.Send(&Order{OrderId: "7"}))
+
+	// assert.
+	assert.Equal(t, Order{OrderId: "42"}, received)
+	assert.Equal(t, []*Order{{OrderId: "x7"}}, underlying.sent)
+}
+
+// TestInterceptor_Conn_Error tests that failures to receive messages are
+// returned.
+func TestInterceptor_Conn_Error(t *testing.T) {
+	// arrange.
+	i := rpchttp.New(prefixCodec{}, "order_id")
+	underlying := &conn{err: errors.New("whoa")}
+
+	// action.
+	err := i.Conn(underlying).Receive(&Order{})
+
+	// assert.
+	assert.Equal(t, underlying.err, err)
+}
+
+// TestNewHandler tests that the 'Location' header is obscured for unary
+// calls, and left as is for streaming calls.
+func TestNewHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		obscured    bool
+	}{
+		{name: "ConnectUnary", contentType: "application/proto", obscured: true},
+		{name: "TwirpJSON", contentType: "application/json", obscured: true},
+		{name: "ConnectStreaming", contentType: "application/connect+proto"},
+		{name: "GRPC", contentType: "application/grpc+proto"},
+		{name: "GRPCWeb", contentType: "application/grpc-web"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			ctx := context.Background()
+			location := mustParse("/orders/42")
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Location", location.String())
+				w.WriteHeader(http.StatusOK)
+			})
+			handler := rpchttp.NewHandler(obscurer.Default, obscurer.DefaultStore, h)
+			request := httptest.NewRequest(http.MethodPost, "/orders.v1.Orders/CreateOrder", nil)
+			request.Header.Set("Content-Type", test.contentType)
+			recorder := httptest.NewRecorder()
+			t.Cleanup(func() {
+				obscurer.DefaultStore.Clear(ctx)
+			})
+
+			// action.
+			handler.ServeHTTP(recorder, request)
+
+			// assert.
+			want := location.String()
+			if test.obscured {
+				want = obscurer.Default.Obscure(location).String()
+			}
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, want, recorder.Header().Get("Location"))
+		})
+	}
+}
+
+func mustParse(str string) *url.URL {
+	u, err := url.Parse(str)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
@@ -0,0 +1,87 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// SamplingObserver is notified, for each request whose body would be
+// rewritten, whether it was sampled, along with the time spent resolving
+// and rewriting URLs for it, such that the cost of body rewriting can be
+// compared between sampled and unsampled requests.
+type SamplingObserver func(ctx context.Context, sampled bool, overhead time.Duration)
+
+// Sampling represents the configuration of the sampling of the requests
+// whose request and response bodies are rewritten.
+type Sampling struct {
+	// Enabled indicates whether body rewriting is sampled. When false,
+	// every request is sampled.
+	Enabled bool
+	// Percent is the percentage of requests whose bodies are rewritten,
+	// between 0 and 100.
+	Percent float64
+	// Observer is notified whether each request whose body would be
+	// rewritten was sampled.
+	Observer SamplingObserver
+}
+
+// WithBodySampling configures the handler to rewrite the request and
+// response bodies of the provided percentage of requests only, between 0
+// and 100, such that body rewriting, the most expensive of the
+// transformations, can be rolled out gradually. The headers of every
+// request are obscured regardless.
+func WithBodySampling(percent float64) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Sampling.Enabled = true
+		o.Sampling.Percent = percent
+	}
+}
+
+// WithSamplingObserver configures the handler to notify the provided
+// observer whether each request whose body would be rewritten was sampled.
+func WithSamplingObserver(observer SamplingObserver) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Sampling.Observer = observer
+	}
+}
+
+// rewritesBodies indicates whether the handler is configured to rewrite
+// the body of any request or response.
+func (h *handler) rewritesBodies() bool {
+	return h.ids != nil ||
+		h.links != nil ||
+		h.options.ResolveRequestBodies ||
+		h.options.RewriteHTML ||
+		h.options.RewriteFeeds ||
+		h.options.RewriteSitemaps
+}
+
+// sample indicates whether the bodies of a request are to be rewritten.
+func (h *handler) sample() bool {
+	sampling := h.options.Sampling
+	return !sampling.Enabled || sampling.Percent > 0 && rand.Float64()*100 < sampling.Percent
+}
+
+// observeSampling notifies the sampling observer, if any, whether a request
+// was sampled, along with the provided overhead.
+func (h *handler) observeSampling(ctx context.Context, sampled bool, overhead time.Duration) {
+	if h.options.Sampling.Observer != nil {
+		h.options.Sampling.Observer(ctx, sampled, overhead)
+	}
+}
@@ -0,0 +1,75 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWithBodySampling tests that the bodies of the requests sampled only
+// are rewritten, and that the sampling observer is notified whether each
+// request was sampled.
+func TestWithBodySampling(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []obscurer.HandlerOption
+		body    string
+		sampled []bool
+	}{
+		{name: "Unsampled", opts: []obscurer.HandlerOption{obscurer.WithBodySampling(0)}, body: `{"id":42}`, sampled: []bool{false}},
+		{name: "Sampled", opts: []obscurer.HandlerOption{obscurer.WithBodySampling(100)}, body: `{"id":"x42"}`, sampled: []bool{true}},
+		{name: "Disabled", body: `{"id":"x42"}`, sampled: []bool{true}},
+		{
+			name:    "BodyRewritingDisabled",
+			opts:    []obscurer.HandlerOption{obscurer.WithToggles(obscurer.NewToggles(obscurer.Flags{DisableBodyRewriting: true}))},
+			body:    `{"id":42}`,
+			sampled: []bool{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"id":42}`))
+			})
+			sampled := []bool{}
+			observer := func(ctx context.Context, s bool, overhead time.Duration) {
+				sampled = append(sampled, s)
+			}
+			opts := append([]obscurer.HandlerOption{
+				obscurer.WithIDFields(prefixCodec{prefix: "x"}, "id"),
+				obscurer.WithSamplingObserver(observer),
+			}, test.opts...)
+			handler := obscurer.NewHandler(obscurer.Default, obscurer.NewMemoryStore(), h, opts...)
+			response := httptest.NewRecorder()
+
+			// action.
+			handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+			// assert.
+			assert.Equal(t, test.body, response.Body.String())
+			assert.Equal(t, test.sampled, sampled)
+		})
+	}
+}
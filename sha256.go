@@ -0,0 +1,84 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strings"
+)
+
+// sha256Obscurer obscures URLs using the SHA-256 hashing algorithm, or
+// HMAC-SHA-256 when configured with a key.
+type sha256Obscurer struct {
+	options ObscurerOptions
+}
+
+// NewSHA256 constructs an obscurer that obscures URLs using the SHA-256
+// hashing algorithm. When a key is provided with WithKey, HMAC-SHA-256 is
+// used instead, making the obscured URLs unpredictable to clients.
+func NewSHA256(opts ...ObscurerOption) Obscurer {
+	o := &sha256Obscurer{}
+	for _, opt := range opts {
+		opt(&o.options)
+	}
+	return o
+}
+
+// Obscure obscures the provided URL.
+func (o *sha256Obscurer) Obscure(url *url.URL) *url.URL {
+	return o.Reroll(url, 0)
+}
+
+// ObscureAll obscures the provided URLs, reusing a single hasher for them.
+func (o *sha256Obscurer) ObscureAll(ctx context.Context, urls []*url.URL) (map[*url.URL]*url.URL, error) {
+	h := sha256.New()
+	if len(o.options.Key) > 0 {
+		h = hmac.New(sha256.New, o.options.Key)
+	}
+	return obscureDigests(ctx, o.options, urls, func(dst, message []byte) []byte {
+		h.Reset()
+		h.Write(message)
+		return h.Sum(dst)
+	})
+}
+
+// Normalize provides the normalized copy of the provided URL.
+func (o *sha256Obscurer) Normalize(u *url.URL) *url.URL {
+	result := o.options.normalize(u)
+	return &result
+}
+
+// Reroll obscures the provided URL for the provided attempt.
+func (o *sha256Obscurer) Reroll(url *url.URL, attempt int) *url.URL {
+	result := o.options.normalize(url)
+	message := []byte(salt(strings.TrimLeft(result.Path, "/"), attempt))
+	var sum []byte
+	if len(o.options.Key) > 0 {
+		mac := hmac.New(sha256.New, o.options.Key)
+		mac.Write(message)
+		sum = mac.Sum(nil)
+	} else {
+		digest := sha256.Sum256(message)
+		sum = digest[:]
+	}
+	result.Path = "/" + hex.EncodeToString(sum)
+	result.RawPath = ""
+	return &result
+}
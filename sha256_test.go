@@ -0,0 +1,57 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSHA256_Obscure tests that the SHA-256 obscurer hashes the path.
+func TestSHA256_Obscure(t *testing.T) {
+	// arrange.
+	u := mustParse("http://www.example.com/this/is/the/way")
+	want := fmt.Sprintf("/%x", sha256.Sum256([]byte("this/is/the/way")))
+
+	// action.
+	got := obscurer.NewSHA256().Obscure(u)
+
+	// assert.
+	assert.Equal(t, want, got.Path)
+	assert.Equal(t, u.Host, got.Host)
+}
+
+// TestSHA256_Obscure_Key tests that the SHA-256 obscurer uses HMAC when
+// configured with a key.
+func TestSHA256_Obscure_Key(t *testing.T) {
+	// arrange.
+	key := []byte("secret")
+	u := mustParse("http://www.example.com/this/is/the/way")
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("this/is/the/way"))
+	want := fmt.Sprintf("/%x", mac.Sum(nil))
+
+	// action.
+	got := obscurer.NewSHA256(obscurer.WithKey(key)).Obscure(u)
+
+	// assert.
+	assert.Equal(t, want, got.Path)
+}
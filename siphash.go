@@ -0,0 +1,137 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"math/bits"
+	"net/url"
+	"strings"
+)
+
+// SipHashKeySize represents the size of the key, in bytes, used by the
+// SipHash obscurer.
+const SipHashKeySize = 16
+
+// sipHashObscurer obscures URLs using the keyed SipHash-2-4 algorithm.
+//
+// SipHash is considerably cheaper to compute than MD5, and since it is keyed
+// the obscured URLs remain unpredictable to clients that don't know the key.
+type sipHashObscurer struct {
+	k0, k1  uint64
+	options ObscurerOptions
+}
+
+// NewSipHash constructs an obscurer that obscures URLs using SipHash-2-4
+// with the provided key.
+func NewSipHash(key [SipHashKeySize]byte, opts ...ObscurerOption) Obscurer {
+	o := &sipHashObscurer{
+		k0: binary.LittleEndian.Uint64(key[:8]),
+		k1: binary.LittleEndian.Uint64(key[8:]),
+	}
+	for _, opt := range opts {
+		opt(&o.options)
+	}
+	return o
+}
+
+// Obscure obscures the provided URL.
+func (o *sipHashObscurer) Obscure(url *url.URL) *url.URL {
+	return o.Reroll(url, 0)
+}
+
+// ObscureAll obscures the provided URLs, reusing the buffers holding their
+// paths and digests.
+func (o *sipHashObscurer) ObscureAll(ctx context.Context, urls []*url.URL) (map[*url.URL]*url.URL, error) {
+	var digest [8]byte
+	return obscureDigests(ctx, o.options, urls, func(dst, message []byte) []byte {
+		binary.BigEndian.PutUint64(digest[:], sipHash24(o.k0, o.k1, message))
+		return append(dst, digest[:]...)
+	})
+}
+
+// Normalize provides the normalized copy of the provided URL.
+func (o *sipHashObscurer) Normalize(u *url.URL) *url.URL {
+	result := o.options.normalize(u)
+	return &result
+}
+
+// Reroll obscures the provided URL for the provided attempt.
+func (o *sipHashObscurer) Reroll(url *url.URL, attempt int) *url.URL {
+	result := o.options.normalize(url)
+	sum := sipHash24(o.k0, o.k1, []byte(salt(strings.TrimLeft(result.Path, "/"), attempt)))
+	var digest [8]byte
+	binary.BigEndian.PutUint64(digest[:], sum)
+	result.Path = "/" + hex.EncodeToString(digest[:])
+	result.RawPath = ""
+	return &result
+}
+
+// sipHash24 computes the SipHash-2-4 of the provided message.
+// see: https://www.aumasson.jp/siphash/siphash.pdf
+func sipHash24(k0, k1 uint64, msg []byte) uint64 {
+	v0 := k0 ^ 0x736f6d6570736575
+	v1 := k1 ^ 0x646f72616e646f6d
+	v2 := k0 ^ 0x6c7967656e657261
+	v3 := k1 ^ 0x7465646279746573
+
+	round := func() {
+		v0 += v1
+		v1 = bits.RotateLeft64(v1, 13)
+		v1 ^= v0
+		v0 = bits.RotateLeft64(v0, 32)
+		v2 += v3
+		v3 = bits.RotateLeft64(v3, 16)
+		v3 ^= v2
+		v0 += v3
+		v3 = bits.RotateLeft64(v3, 21)
+		v3 ^= v0
+		v2 += v1
+		v1 = bits.RotateLeft64(v1, 17)
+		v1 ^= v2
+		v2 = bits.RotateLeft64(v2, 32)
+	}
+
+	// compression.
+	length := len(msg)
+	for ; len(msg) >= 8; msg = msg[8:] {
+		m := binary.LittleEndian.Uint64(msg)
+		v3 ^= m
+		round()
+		round()
+		v0 ^= m
+	}
+
+	// last block, which carries the message length in the top byte.
+	last := uint64(length) << 56
+	for i, b := range msg {
+		last |= uint64(b) << (8 * uint(i))
+	}
+	v3 ^= last
+	round()
+	round()
+	v0 ^= last
+
+	// finalization.
+	v2 ^= 0xff
+	round()
+	round()
+	round()
+	round()
+	return v0 ^ v1 ^ v2 ^ v3
+}
@@ -0,0 +1,99 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+)
+
+// sipHashKey represents the key used by the SipHash reference test vectors.
+var sipHashKey = [obscurer.SipHashKeySize]byte{
+	0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07,
+	0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+}
+
+// TestSipHash_Obscure tests that the SipHash obscurer produces the digests
+// defined by the reference test vectors.
+func TestSipHash_Obscure(t *testing.T) {
+	tests := []struct {
+		name   string
+		length int
+		want   string
+	}{
+		{name: "Empty", length: 0, want: "/726fdb47dd0e0e31"},
+		{name: "PartialBlock", length: 7, want: "/ab0200f58b01d137"},
+		{name: "FullBlock", length: 8, want: "/93f5f5799a932462"},
+		{name: "MultipleBlocks", length: 15, want: "/a129ca6149be45e5"},
+	}
+	o := obscurer.NewSipHash(sipHashKey)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			message := make([]byte, test.length)
+			for i := range message {
+				message[i] = byte(i)
+			}
+			u := mustParse("http://www.example.com/")
+			u.Path = "/" + string(message)
+
+			// action + assert.
+			got := o.Obscure(u)
+			assert.Equal(t, test.want, got.Path)
+			assert.Equal(t, u.Host, got.Host)
+		})
+	}
+}
+
+// TestSipHash_Obscure_Key tests that different keys produce different
+// obscured URLs.
+func TestSipHash_Obscure_Key(t *testing.T) {
+	// arrange.
+	u := mustParse("http://www.example.com/this/is/the/way")
+	otherKey := sipHashKey
+	otherKey[0] = 0xff
+
+	// action.
+	first := obscurer.NewSipHash(sipHashKey).Obscure(u)
+	second := obscurer.NewSipHash(otherKey).Obscure(u)
+
+	// assert.
+	assert.NotEqual(t, first.Path, second.Path)
+}
+
+func BenchmarkSipHash_Obscure(b *testing.B) {
+	o := obscurer.NewSipHash(sipHashKey)
+	u := mustParse("http://www.example.com/this/is/the/way/")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		o.Obscure(u)
+	}
+}
+
+func BenchmarkSipHash_Obscure_Parallel(b *testing.B) {
+	o := obscurer.NewSipHash(sipHashKey)
+	u := mustParse("http://www.example.com/this/is/the/way/")
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			o.Obscure(u)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// SitemapPath represents the path the handler serves or rewrites the
+// sitemap at, when configured with WithSitemap or WithSitemapRewriting.
+const SitemapPath = "/sitemap.xml"
+
+// ErrSitemapFailure represents an error that occurs when obscuring the URLs
+// of the sitemap served by the handler.
+var ErrSitemapFailure = errors.New("obscurer: unable to obscure sitemap URLs")
+
+// sitemapMarkup describes where the URLs of sitemaps and sitemap indexes
+// are held, including those of alternate language versions.
+var sitemapMarkup = markup{
+	attributes: map[string]bool{
+		"href": true,
+	},
+	elements: map[string]bool{
+		"loc": true,
+	},
+}
+
+// sitemapURLSet represents a sitemap, as described by
+// https://www.sitemaps.org/protocol.html.
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+// sitemapURL represents an entry of a sitemap.
+type sitemapURL struct {
+	Loc string `xml:"loc"`
+}
+
+// WithSitemap configures the handler to serve a sitemap at SitemapPath
+// listing the obscured URLs of the provided routes, such as '/about', on the
+// host of the request, such that the URLs search engines index are those the
+// handler serves. Requests for the sitemap aren't resolved, nor handled by
+// the wrapped handler.
+func WithSitemap(routes ...string) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Sitemap = append(o.Sitemap, routes...)
+	}
+}
+
+// WithSitemapRewriting configures the handler to obscure the URLs of the
+// sitemap served by the wrapped handler at SitemapPath, held by its 'loc'
+// elements and the 'href' attributes of its alternate links, that point at
+// paths of the host of the request.
+func WithSitemapRewriting() HandlerOption {
+	return func(o *HandlerOptions) {
+		o.RewriteSitemaps = true
+	}
+}
+
+// sitemap serves the sitemap listing the obscured URLs of the configured
+// routes.
+func (h *handler) sitemap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	set := sitemapURLSet{URLs: make([]sitemapURL, 0, len(h.options.Sitemap))}
+	for _, route := range h.options.Sitemap {
+		u, err := url.Parse(route)
+		if err != nil {
+			h.fail(w, r, ErrSitemapFailure, err, http.StatusInternalServerError)
+			return
+		}
+		obscured, err := h.obscure(r.Context(), u)
+		if err != nil {
+			h.fail(w, r, ErrSitemapFailure, err, http.StatusInternalServerError)
+			return
+		}
+		if obscured == nil {
+			obscured = u
+		}
+		loc := *obscured
+		loc.Scheme, loc.Host = scheme, r.Host
+		set.URLs = append(set.URLs, sitemapURL{Loc: loc.String()})
+	}
+	body, err := xml.Marshal(set)
+	if err != nil {
+		h.fail(w, r, ErrSitemapFailure, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		w.Write([]byte(xml.Header))
+		w.Write(body)
+	}
+}
@@ -0,0 +1,99 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHandler_Sitemap tests that the sitemap lists the obscured URLs of the
+// configured routes, which resolve to their originals.
+func TestHandler_Sitemap(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	store := obscurer.NewMemoryStore()
+	handled := false
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handled = true
+	})
+	handler := obscurer.NewHandler(obscurer.Default, store, h, obscurer.WithSitemap("/about", "/users/42"))
+	response := httptest.NewRecorder()
+
+	// action.
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "http://example.com/sitemap.xml", nil))
+
+	// assert.
+	about := obscurer.Default.Obscure(mustParse("/about"))
+	user := obscurer.Default.Obscure(mustParse("/users/42"))
+	assert.False(t, handled)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "application/xml; charset=utf-8", response.Header().Get("Content-Type"))
+	assert.Equal(t,
+		`<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+			`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`+
+			`<url><loc>http://example.com`+about.String()+`</loc></url>`+
+			`<url><loc>http://example.com`+user.String()+`</loc></url>`+
+			`</urlset>`,
+		response.Body.String())
+	original, ok, err := store.Get(ctx, user)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/users/42", original.String())
+}
+
+// TestHandler_SitemapRewriting tests that the URLs of the sitemap served by
+// the wrapped handler are obscured.
+func TestHandler_SitemapRewriting(t *testing.T) {
+	// arrange.
+	body := `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:xhtml="http://www.w3.org/1999/xhtml">
+  <url>
+    <loc>http://example.com/about</loc>
+    <lastmod>2021-01-01</lastmod>
+    <xhtml:link rel="alternate" hreflang="de" href="http://example.com/de/about"/>
+  </url>
+</urlset>`
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write([]byte(body))
+	})
+	handler := obscurer.NewHandler(obscurer.Default, obscurer.NewMemoryStore(), mux, obscurer.WithSitemapRewriting())
+	response := httptest.NewRecorder()
+
+	// action.
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "http://example.com/sitemap.xml", nil))
+
+	// assert.
+	obscure := func(u string) string {
+		return obscurer.Default.Obscure(mustParse(u)).String()
+	}
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:xhtml="http://www.w3.org/1999/xhtml">
+  <url>
+    <loc>`+obscure("http://example.com/about")+`</loc>
+    <lastmod>2021-01-01</lastmod>
+    <xhtml:link rel="alternate" hreflang="de" href="`+obscure("http://example.com/de/about")+`"/>
+  </url>
+</urlset>`, response.Body.String())
+}
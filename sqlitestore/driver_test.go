@@ -0,0 +1,76 @@
+//go:build !sqlite_modernc && !sqlite_mattn
+// +build !sqlite_modernc,!sqlite_mattn
+
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sqlitestore_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/freerware/obscurer/sqlitestore"
+	"github.com/stretchr/testify/assert"
+)
+
+// unreachable is a SQLite driver whose connections fail with err.
+type unreachable struct {
+	err error
+}
+
+func (d unreachable) Open(name string) (driver.Conn, error) {
+	return nil, d.err
+}
+
+var (
+	errModernc = errors.New("modernc")
+	errMattn   = errors.New("mattn")
+	errCustom  = errors.New("custom")
+)
+
+func init() {
+	sql.Register("sqlite", unreachable{err: errModernc})
+	sql.Register("sqlite3", unreachable{err: errMattn})
+	sql.Register("sqlite_custom", unreachable{err: errCustom})
+}
+
+// TestOpen_Driver tests that databases are opened with the configured
+// driver, and otherwise with the preferred of the common drivers registered.
+func TestOpen_Driver(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []sqlitestore.Option
+		err  error
+	}{
+		{name: "Preferred", err: errModernc},
+		{name: "Configured", opts: []sqlitestore.Option{sqlitestore.WithDriver("sqlite3")}, err: errMattn},
+		{name: "Custom", opts: []sqlitestore.Option{sqlitestore.WithDriver("sqlite_custom")}, err: errCustom},
+		{name: "Unregistered", opts: []sqlitestore.Option{sqlitestore.WithDriver("sqlcipher")}, err: sqlitestore.ErrNoDriver},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// action.
+			s, err := sqlitestore.Open(context.Background(), ":memory:", test.opts...)
+
+			// assert.
+			assert.Nil(t, s)
+			assert.True(t, errors.Is(err, test.err), "%v", err)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sqlitestore provides an obscurer.Store that persists mappings in
+// an embedded SQLite database, for single node deployments that want
+// durable, queryable mappings without running a database server.
+//
+// The store doesn't depend on a SQLite driver, so that applications choose
+// between the pure Go and cgo based drivers. Open uses the driver the
+// application registers by importing it, such as modernc.org/sqlite, which
+// registers as 'sqlite', or github.com/mattn/go-sqlite3, which registers as
+// 'sqlite3':
+//
+//	import _ "modernc.org/sqlite"
+//
+//	s, err := sqlitestore.Open(ctx, "obscurer.db")
+//
+// Drivers registering under other names are selected with WithDriver.
+// Alternatively, New accepts a *sql.DB opened with any SQLite driver.
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/freerware/obscurer/sqlstore"
+)
+
+// ErrNoDriver represents an error that occurs when opening a database
+// without a SQLite driver registered.
+var ErrNoDriver = errors.New("sqlitestore: no SQLite driver registered, import one such as modernc.org/sqlite")
+
+// drivers represents the names the common SQLite drivers register under,
+// in the order they are preferred when several are registered.
+var drivers = []string{"sqlite", "sqlite3"}
+
+// Options represents the configuration options for the store.
+type Options struct {
+	// Table is the name of the table mappings are stored in.
+	Table string
+	// Driver is the name of the SQLite driver Open uses. When empty, the
+	// first of 'sqlite' and 'sqlite3' that is registered is used.
+	Driver string
+}
+
+// Option applies an option to the provided configuration.
+type Option func(*Options)
+
+// WithTable configures the name of the table mappings are stored in.
+func WithTable(table string) Option {
+	return func(o *Options) {
+		o.Table = table
+	}
+}
+
+// WithDriver configures the name of the SQLite driver Open uses.
+func WithDriver(driver string) Option {
+	return func(o *Options) {
+		o.Driver = driver
+	}
+}
+
+// driver provides the name of the registered SQLite driver to open
+// databases with, or ErrNoDriver when it isn't registered.
+func driver(name string) (string, error) {
+	candidates := drivers
+	if name != "" {
+		candidates = []string{name}
+	}
+	registered := make(map[string]bool)
+	for _, d := range sql.Drivers() {
+		registered[d] = true
+	}
+	for _, candidate := range candidates {
+		if registered[candidate] {
+			return candidate, nil
+		}
+	}
+	return "", ErrNoDriver
+}
+
+// Store stores mappings in a SQLite database.
+type Store struct {
+	*sqlstore.Store
+}
+
+// Open opens the SQLite database at the provided path using the registered
+// SQLite driver, and constructs a store on top of it.
+func Open(ctx context.Context, path string, opts ...Option) (*Store, error) {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	driverName, err := driver(options.Driver)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(driverName, path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite only supports a single writer.
+	db.SetMaxOpenConns(1)
+	s, err := New(ctx, db, opts...)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// New constructs a store on top of the provided SQLite database, migrating
+// the schema when it is out of date.
+func New(ctx context.Context, db *sql.DB, opts ...Option) (*Store, error) {
+	options := Options{Table: "obscurer_mappings"}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	s := sqlstore.New(db,
+		sqlstore.WithDialect(sqlstore.SQLite),
+		sqlstore.WithTable(options.Table))
+	if err := s.Migrate(ctx); err != nil {
+		return nil, err
+	}
+	return &Store{Store: s}, nil
+}
+
+// Close closes the underlying database.
+func (s *Store) Close() error {
+	s.Store.Close()
+	return s.DB().Close()
+}
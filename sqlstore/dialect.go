@@ -0,0 +1,65 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sqlstore
+
+import "fmt"
+
+// Dialect describes the SQL differences between databases.
+type Dialect struct {
+	// Name is the name of the dialect.
+	Name string
+	// Placeholder provides the bind parameter placeholder for the
+	// parameter at the provided position, starting at 1.
+	Placeholder func(position int) string
+	// InsertIgnore is the format of the statement inserting a row unless
+	// one with the same primary key exists, given the table name.
+	InsertIgnore string
+	// Migrations are the statements bringing the schema up to date, in
+	// order, each formatted with the table name.
+	Migrations []string
+}
+
+var (
+	// Postgres represents the dialect of PostgreSQL.
+	Postgres = Dialect{
+		Name:         "postgres",
+		Placeholder:  func(position int) string { return fmt.Sprintf("$%d", position) },
+		InsertIgnore: "INSERT INTO %s (obscured, original) VALUES ($1, $2) ON CONFLICT (obscured) DO NOTHING",
+		Migrations: []string{
+			"CREATE TABLE IF NOT EXISTS %s (obscured TEXT PRIMARY KEY, original TEXT NOT NULL)",
+		},
+	}
+
+	// MySQL represents the dialect of MySQL and MariaDB.
+	MySQL = Dialect{
+		Name:         "mysql",
+		Placeholder:  func(int) string { return "?" },
+		InsertIgnore: "INSERT IGNORE INTO %s (obscured, original) VALUES (?, ?)",
+		Migrations: []string{
+			"CREATE TABLE IF NOT EXISTS %s (obscured VARCHAR(512) PRIMARY KEY, original TEXT NOT NULL)",
+		},
+	}
+
+	// SQLite represents the dialect of SQLite.
+	SQLite = Dialect{
+		Name:         "sqlite",
+		Placeholder:  func(int) string { return "?" },
+		InsertIgnore: "INSERT OR IGNORE INTO %s (obscured, original) VALUES (?, ?)",
+		Migrations: []string{
+			"CREATE TABLE IF NOT EXISTS %s (obscured TEXT PRIMARY KEY, original TEXT NOT NULL)",
+		},
+	}
+)
@@ -0,0 +1,210 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sqlstore_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"sync/atomic"
+)
+
+// database is an in-memory database understanding the statements issued
+// by the store.
+type database struct {
+	mutex      sync.Mutex
+	tables     map[string]map[string]string
+	versions   map[string][]int64
+	prepared   int64
+	statements []string
+}
+
+var (
+	createMappings   = regexp.MustCompile(`^CREATE TABLE IF NOT EXISTS (\w+) \(obscured`)
+	createMigrations = regexp.MustCompile(`^CREATE TABLE IF NOT EXISTS (\w+) \(version`)
+	selectVersion    = regexp.MustCompile(`^SELECT MAX\(version\) FROM (\w+)$`)
+	insertVersion    = regexp.MustCompile(`^INSERT INTO (\w+) \(version\) VALUES`)
+	insertMapping    = regexp.MustCompile(`^INSERT (?:OR IGNORE |IGNORE )?INTO (\w+) \(obscured, original\)`)
+	selectMapping    = regexp.MustCompile(`^SELECT original FROM (\w+) WHERE obscured = `)
+	selectCount      = regexp.MustCompile(`^SELECT COUNT\(\*\) FROM (\w+)$`)
+	selectMappings   = regexp.MustCompile(`^SELECT obscured, original FROM (\w+)$`)
+	deleteMapping    = regexp.MustCompile(`^DELETE FROM (\w+) WHERE obscured = `)
+	deleteMappings   = regexp.MustCompile(`^DELETE FROM (\w+)$`)
+)
+
+var databaseCount int64
+
+// newDatabase registers a new in-memory database, and opens it.
+func newDatabase() (*database, *sql.DB) {
+	d := &database{
+		tables:   make(map[string]map[string]string),
+		versions: make(map[string][]int64),
+	}
+	name := fmt.Sprintf("sqlstore-%d", atomic.AddInt64(&databaseCount, 1))
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	if err != nil {
+		panic(err)
+	}
+	return d, db
+}
+
+func (d *database) Open(string) (driver.Conn, error) { return &conn{d: d}, nil }
+
+// exec executes the provided statement, providing the resulting rows.
+func (d *database) exec(query string, args []driver.Value) ([][]driver.Value, int64, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.statements = append(d.statements, query)
+	table := func(re *regexp.Regexp) (map[string]string, error) {
+		t, ok := d.tables[re.FindStringSubmatch(query)[1]]
+		if !ok {
+			return nil, errors.New("no such table")
+		}
+		return t, nil
+	}
+	switch {
+	case createMappings.MatchString(query):
+		name := createMappings.FindStringSubmatch(query)[1]
+		if _, ok := d.tables[name]; !ok {
+			d.tables[name] = make(map[string]string)
+		}
+	case createMigrations.MatchString(query):
+		name := createMigrations.FindStringSubmatch(query)[1]
+		if _, ok := d.versions[name]; !ok {
+			d.versions[name] = nil
+		}
+	case selectVersion.MatchString(query):
+		var max interface{}
+		for _, v := range d.versions[selectVersion.FindStringSubmatch(query)[1]] {
+			if max == nil || v > max.(int64) {
+				max = v
+			}
+		}
+		return [][]driver.Value{{max}}, 0, nil
+	case insertVersion.MatchString(query):
+		name := insertVersion.FindStringSubmatch(query)[1]
+		d.versions[name] = append(d.versions[name], args[0].(int64))
+		return nil, 1, nil
+	case insertMapping.MatchString(query):
+		t, err := table(insertMapping)
+		if err != nil {
+			return nil, 0, err
+		}
+		if _, ok := t[args[0].(string)]; ok {
+			return nil, 0, nil
+		}
+		t[args[0].(string)] = args[1].(string)
+		return nil, 1, nil
+	case selectMapping.MatchString(query):
+		t, err := table(selectMapping)
+		if err != nil {
+			return nil, 0, err
+		}
+		if original, ok := t[args[0].(string)]; ok {
+			return [][]driver.Value{{original}}, 0, nil
+		}
+		return nil, 0, nil
+	case selectCount.MatchString(query):
+		t, err := table(selectCount)
+		if err != nil {
+			return nil, 0, err
+		}
+		return [][]driver.Value{{int64(len(t))}}, 0, nil
+	case selectMappings.MatchString(query):
+		t, err := table(selectMappings)
+		if err != nil {
+			return nil, 0, err
+		}
+		var values [][]driver.Value
+		for obscured, original := range t {
+			values = append(values, []driver.Value{obscured, original})
+		}
+		return values, 0, nil
+	case deleteMapping.MatchString(query):
+		t, err := table(deleteMapping)
+		if err != nil {
+			return nil, 0, err
+		}
+		delete(t, args[0].(string))
+	case deleteMappings.MatchString(query):
+		name := deleteMappings.FindStringSubmatch(query)[1]
+		d.tables[name] = make(map[string]string)
+	default:
+		return nil, 0, fmt.Errorf("unsupported statement: %s", query)
+	}
+	return nil, 0, nil
+}
+
+type conn struct{ d *database }
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	atomic.AddInt64(&c.d.prepared, 1)
+	return &stmt{d: c.d, query: query}, nil
+}
+func (c *conn) Close() error              { return nil }
+func (c *conn) Begin() (driver.Tx, error) { return tx{}, nil }
+
+type tx struct{}
+
+func (tx) Commit() error   { return nil }
+func (tx) Rollback() error { return nil }
+
+type stmt struct {
+	d     *database
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	_, affected, err := s.d.exec(s.query, args)
+	return driver.RowsAffected(affected), err
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	values, _, err := s.d.exec(s.query, args)
+	return &rows{values: values}, err
+}
+
+type rows struct{ values [][]driver.Value }
+
+func (r *rows) Columns() []string {
+	if len(r.values) == 0 {
+		return []string{"value"}
+	}
+	columns := make([]string, len(r.values[0]))
+	for i := range columns {
+		columns[i] = fmt.Sprintf("column%d", i)
+	}
+	return columns
+}
+
+func (r *rows) Close() error { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
@@ -0,0 +1,390 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package sqlstore provides an obscurer.Store that persists mappings in a
+// SQL database using database/sql, such that mappings survive restarts and
+// are shared across instances.
+//
+// The store works with any database/sql driver, with the SQL differences
+// between databases described by a Dialect. PostgreSQL, MySQL, and SQLite
+// dialects are provided.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/freerware/obscurer"
+)
+
+// Options represents the configuration options for the store.
+type Options struct {
+	// Dialect describes the SQL differences of the database.
+	Dialect Dialect
+	// Table is the name of the table mappings are stored in. The name of
+	// the table tracking schema migrations is derived from it.
+	Table string
+	// Key is the strategy the keys of mappings are derived with from their
+	// obscured URLs.
+	Key obscurer.KeyStrategy
+}
+
+// Option applies an option to the provided configuration.
+type Option func(*Options)
+
+// WithKeyStrategy configures the strategy the keys of mappings are derived
+// with from their obscured URLs.
+func WithKeyStrategy(k obscurer.KeyStrategy) Option {
+	return func(o *Options) {
+		o.Key = k
+	}
+}
+
+// WithDialect configures the dialect of the database.
+func WithDialect(dialect Dialect) Option {
+	return func(o *Options) {
+		o.Dialect = dialect
+	}
+}
+
+// WithTable configures the name of the table mappings are stored in.
+func WithTable(table string) Option {
+	return func(o *Options) {
+		o.Table = table
+	}
+}
+
+// Store stores mappings in a SQL database.
+type Store struct {
+	db      *sql.DB
+	options Options
+
+	mutex      sync.Mutex
+	statements map[string]*sql.Stmt
+}
+
+// New constructs a store on top of the provided database. Migrate must be
+// called before the store is used with a new database.
+func New(db *sql.DB, opts ...Option) *Store {
+	options := Options{Dialect: Postgres, Table: "obscurer_mappings"}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &Store{
+		db:         db,
+		options:    options,
+		statements: make(map[string]*sql.Stmt),
+	}
+}
+
+// DB provides the underlying database, allowing mappings to be queried.
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// Ping verifies the underlying database is reachable.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close closes the cached prepared statements. The underlying database is
+// left open.
+func (s *Store) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var err error
+	for query, stmt := range s.statements {
+		if e := stmt.Close(); e != nil && err == nil {
+			err = e
+		}
+		delete(s.statements, query)
+	}
+	return err
+}
+
+// migrationsTable provides the name of the table tracking migrations.
+func (s *Store) migrationsTable() string {
+	return s.options.Table + "_migrations"
+}
+
+// Version provides the schema version of the database, which is zero
+// when no migrations have been applied.
+func (s *Store) Version(ctx context.Context) (int, error) {
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (version INTEGER NOT NULL)",
+		s.migrationsTable())); err != nil {
+		return 0, err
+	}
+	var version sql.NullInt64
+	err := s.db.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT MAX(version) FROM %s", s.migrationsTable())).Scan(&version)
+	return int(version.Int64), err
+}
+
+// Migrate brings the schema of the database up to date, applying each
+// pending migration within its own transaction.
+func (s *Store) Migrate(ctx context.Context) error {
+	version, err := s.Version(ctx)
+	if err != nil {
+		return err
+	}
+	for i := version; i < len(s.options.Dialect.Migrations); i++ {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		migration := fmt.Sprintf(s.options.Dialect.Migrations[i], s.options.Table)
+		if _, err := tx.ExecContext(ctx, migration); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("sqlstore: migration %d failed: %w", i+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(
+			"INSERT INTO %s (version) VALUES (%s)",
+			s.migrationsTable(), s.options.Dialect.Placeholder(1)), i+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stmt provides the prepared statement for the provided query, preparing
+// and caching it on first use.
+func (s *Store) stmt(ctx context.Context, query string) (*sql.Stmt, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if stmt, ok := s.statements[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := s.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	s.statements[query] = stmt
+	return stmt, nil
+}
+
+// query formats the provided query with the table name and placeholders.
+func (s *Store) query(format string, placeholders int) string {
+	args := []interface{}{s.options.Table}
+	for i := 1; i <= placeholders; i++ {
+		args = append(args, s.options.Dialect.Placeholder(i))
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// insertQuery provides the query placing a mapping unless one exists.
+func (s *Store) insertQuery() string {
+	return fmt.Sprintf(s.options.Dialect.InsertIgnore, s.options.Table)
+}
+
+// selectQuery provides the query retrieving the original form of an
+// obscured URL.
+func (s *Store) selectQuery() string {
+	return s.query("SELECT original FROM %s WHERE obscured = %s", 1)
+}
+
+// deleteQuery provides the query deleting the mapping of an obscured URL.
+func (s *Store) deleteQuery() string {
+	return s.query("DELETE FROM %s WHERE obscured = %s", 1)
+}
+
+// put places the provided mapping, within the provided transaction when
+// it's not nil.
+func (s *Store) put(ctx context.Context, tx *sql.Tx, obscured, original *url.URL) error {
+	stmt, err := s.stmt(ctx, s.insertQuery())
+	if err != nil {
+		return err
+	}
+	if tx != nil {
+		stmt = tx.StmtContext(ctx, stmt)
+	}
+	result, err := stmt.ExecContext(ctx, s.options.Key.Key(obscured), original.String())
+	if err != nil {
+		return err
+	}
+	if inserted, err := result.RowsAffected(); err != nil || inserted > 0 {
+		return err
+	}
+	existing, ok, err := s.get(ctx, tx, obscured)
+	if err != nil || !ok {
+		return err
+	}
+	if existing.Path != original.Path {
+		return &obscurer.CollisionError{
+			Obscured: obscured,
+			Existing: existing,
+			Original: original,
+		}
+	}
+	return nil
+}
+
+// get retrieves the original form of the provided obscured URL, within the
+// provided transaction when it's not nil.
+func (s *Store) get(ctx context.Context, tx *sql.Tx, obscured *url.URL) (*url.URL, bool, error) {
+	stmt, err := s.stmt(ctx, s.selectQuery())
+	if err != nil {
+		return nil, false, err
+	}
+	if tx != nil {
+		stmt = tx.StmtContext(ctx, stmt)
+	}
+	var original string
+	err = stmt.QueryRowContext(ctx, s.options.Key.Key(obscured)).Scan(&original)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	u, err := url.Parse(original)
+	if err != nil {
+		return nil, false, err
+	}
+	return u, true, nil
+}
+
+// Put places the mapping between the provided obscured URL and it's original
+// form into the store. A *obscurer.CollisionError is returned when the
+// obscured URL is already mapped to a URL with a different path.
+func (s *Store) Put(ctx context.Context, obscured, original *url.URL) error {
+	return s.put(ctx, nil, obscured, original)
+}
+
+// Get retrieves the original form of the provided obscured URL.
+func (s *Store) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	return s.get(ctx, nil, obscured)
+}
+
+// Range invokes the provided function for each mapping in the store, until
+// the function returns false.
+func (s *Store) Range(ctx context.Context, fn func(obscured, original *url.URL) bool) error {
+	stmt, err := s.stmt(ctx, s.query("SELECT obscured, original FROM %s", 0))
+	if err != nil {
+		return err
+	}
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var obscured, original string
+		if err := rows.Scan(&obscured, &original); err != nil {
+			return err
+		}
+		u, err := url.Parse(original)
+		if err != nil {
+			return err
+		}
+		if !fn(s.options.Key.URL(obscured), u) {
+			return nil
+		}
+	}
+	return rows.Err()
+}
+
+// Remove deletes the entry in the store for the provided obscured URL.
+func (s *Store) Remove(ctx context.Context, obscured *url.URL) error {
+	stmt, err := s.stmt(ctx, s.deleteQuery())
+	if err != nil {
+		return err
+	}
+	_, err = stmt.ExecContext(ctx, s.options.Key.Key(obscured))
+	return err
+}
+
+// Clear removes all entries in the store.
+func (s *Store) Clear(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, s.query("DELETE FROM %s", 0))
+	return err
+}
+
+// Size computes the size of the store.
+func (s *Store) Size(ctx context.Context) (size int) {
+	stmt, err := s.stmt(ctx, s.query("SELECT COUNT(*) FROM %s", 0))
+	if err != nil {
+		return 0
+	}
+	stmt.QueryRowContext(ctx).Scan(&size)
+	return
+}
+
+// Load loads the store with the provided mappings. The mappings are loaded
+// within a single transaction.
+func (s *Store) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	return s.PutAll(ctx, mappings.Pointers())
+}
+
+// transact invokes the provided function within a transaction, which is
+// committed when the function succeeds and rolled back otherwise. The
+// provided queries are prepared up front, as preparing them once the
+// transaction holds the only connection of the pool would deadlock.
+func (s *Store) transact(ctx context.Context, queries []string, fn func(*sql.Tx) error) error {
+	for _, query := range queries {
+		if _, err := s.stmt(ctx, query); err != nil {
+			return err
+		}
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// PutAll places the provided mappings into the store within a single
+// transaction, such that either every mapping is placed or none are.
+func (s *Store) PutAll(ctx context.Context, mappings map[*url.URL]*url.URL) error {
+	queries := []string{s.insertQuery(), s.selectQuery()}
+	return s.transact(ctx, queries, func(tx *sql.Tx) error {
+		for obscured, original := range mappings {
+			if err := s.put(ctx, tx, obscured, original); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RemoveAll deletes the entries in the store for the provided obscured URLs
+// within a single transaction.
+func (s *Store) RemoveAll(ctx context.Context, urls []*url.URL) error {
+	query := s.deleteQuery()
+	return s.transact(ctx, []string{query}, func(tx *sql.Tx) error {
+		stmt, err := s.stmt(ctx, query)
+		if err != nil {
+			return err
+		}
+		stmt = tx.StmtContext(ctx, stmt)
+		for _, u := range urls {
+			if _, err := stmt.ExecContext(ctx, s.options.Key.Key(u)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
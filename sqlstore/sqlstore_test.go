@@ -0,0 +1,252 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sqlstore_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/sqlstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func open(t *testing.T, opts ...sqlstore.Option) (*database, *sqlstore.Store) {
+	d, db := newDatabase()
+	s := sqlstore.New(db, opts...)
+	require.NoError(t, s.Migrate(context.Background()))
+	t.Cleanup(func() {
+		s.Close()
+		db.Close()
+	})
+	return d, s
+}
+
+// TestStore_Migrate tests that migrations are applied once.
+func TestStore_Migrate(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	d, s := open(t, sqlstore.WithTable("mappings"))
+
+	// action.
+	err := s.Migrate(ctx)
+
+	// assert.
+	require.NoError(t, err)
+	version, err := s.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, len(sqlstore.Postgres.Migrations), version)
+	assert.Len(t, d.versions["mappings_migrations"], len(sqlstore.Postgres.Migrations))
+	assert.Contains(t, d.tables, "mappings")
+}
+
+// TestStore tests that mappings can be placed, retrieved, and removed.
+func TestStore(t *testing.T) {
+	dialects := []sqlstore.Dialect{sqlstore.Postgres, sqlstore.MySQL, sqlstore.SQLite}
+	for _, dialect := range dialects {
+		t.Run(dialect.Name, func(t *testing.T) {
+			// arrange.
+			ctx := context.Background()
+			_, s := open(t, sqlstore.WithDialect(dialect))
+			obscured, original := mustParse("/abc"), mustParse("http://www.example.com/this/is/the/way")
+
+			// action + assert.
+			require.NoError(t, s.Put(ctx, obscured, original))
+			require.NoError(t, s.Put(ctx, obscured, original))
+			assert.Equal(t, 1, s.Size(ctx))
+			got, ok, err := s.Get(ctx, obscured)
+			require.NoError(t, err)
+			require.True(t, ok)
+			assert.Equal(t, original.String(), got.String())
+			require.NoError(t, s.Remove(ctx, obscured))
+			_, ok, err = s.Get(ctx, obscured)
+			require.NoError(t, err)
+			assert.False(t, ok)
+		})
+	}
+}
+
+// TestStore_PreparedStatements tests that prepared statements are reused.
+func TestStore_PreparedStatements(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	d, s := open(t)
+	obscured := mustParse("/abc")
+	s.Get(ctx, obscured)
+	prepared := d.prepared
+
+	// action.
+	for i := 0; i < 10; i++ {
+		s.Get(ctx, obscured)
+	}
+
+	// assert.
+	assert.Equal(t, prepared, d.prepared)
+}
+
+// TestStore_Put_Collision tests that placing a mapping for an obscured URL
+// that already maps to a different URL results in a collision error.
+func TestStore_Put_Collision(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	_, s := open(t)
+	require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+
+	// action.
+	err := s.Put(ctx, mustParse("/abc"), mustParse("/hey/der"))
+
+	// assert.
+	assert.True(t, errors.Is(err, obscurer.ErrCollision))
+}
+
+// TestStore_Load tests that the store can be loaded, and cleared.
+func TestStore_Load(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	_, s := open(t)
+
+	// action.
+	err := obscurer.LoadAll(ctx, s, map[*url.URL]*url.URL{
+		mustParse("/a"): mustParse("/this/is/the/way"),
+		mustParse("/b"): mustParse("/hey/der"),
+	})
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.Size(ctx))
+	require.NoError(t, s.Clear(ctx))
+	assert.Equal(t, 0, s.Size(ctx))
+}
+
+// TestStore_PutAll tests that many mappings can be placed and removed at
+// once.
+func TestStore_PutAll(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	_, s := open(t)
+	a, b := mustParse("/a"), mustParse("/b")
+
+	// action.
+	err := s.PutAll(ctx, map[*url.URL]*url.URL{
+		a: mustParse("/this/is/the/way"),
+		b: mustParse("/hey/der"),
+	})
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.Size(ctx))
+	require.NoError(t, s.RemoveAll(ctx, []*url.URL{a, b}))
+	assert.Equal(t, 0, s.Size(ctx))
+}
+
+// TestStore_RemoveAll_KeyRequestURI tests that removing many mappings
+// removes those keyed by request URI.
+func TestStore_RemoveAll_KeyRequestURI(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	_, s := open(t, sqlstore.WithKeyStrategy(obscurer.KeyRequestURI))
+	a, b := mustParse("/a?page=1"), mustParse("/a?page=2")
+	require.NoError(t, s.PutAll(ctx, map[*url.URL]*url.URL{
+		a: mustParse("/this/is/the/way?page=1"),
+		b: mustParse("/this/is/the/way?page=2"),
+	}))
+
+	// action.
+	err := s.RemoveAll(ctx, []*url.URL{a})
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 1, s.Size(ctx))
+	_, ok, err := s.Get(ctx, a)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	_, ok, err = s.Get(ctx, b)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+// TestStore_PutAll_Collision tests that placing many mappings fails when
+// one of them collides with an existing mapping.
+func TestStore_PutAll_Collision(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	_, s := open(t)
+	require.NoError(t, s.Put(ctx, mustParse("/a"), mustParse("/this/is/the/way")))
+
+	// action.
+	err := s.PutAll(ctx, map[*url.URL]*url.URL{
+		mustParse("/a"): mustParse("/hey/der"),
+	})
+
+	// assert.
+	assert.True(t, errors.Is(err, obscurer.ErrCollision))
+}
+
+// TestStore_Range tests that every mapping is visited until the function
+// returns false.
+func TestStore_Range(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	_, s := open(t)
+	require.NoError(t, obscurer.LoadAll(ctx, s, map[*url.URL]*url.URL{
+		mustParse("/a"): mustParse("/this/is/the/way"),
+		mustParse("/b"): mustParse("/hey/der"),
+	}))
+	visited := make(map[string]string)
+
+	// action.
+	err := s.Range(ctx, func(obscured, original *url.URL) bool {
+		visited[obscured.Path] = original.String()
+		return true
+	})
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"/a": "/this/is/the/way", "/b": "/hey/der"}, visited)
+	count := 0
+	require.NoError(t, s.Range(ctx, func(obscured, original *url.URL) bool {
+		count++
+		return false
+	}))
+	assert.Equal(t, 1, count, "expected ranging to stop")
+}
+
+func mustParse(str string) *url.URL {
+	u, err := url.Parse(str)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// TestStore_Ping tests that the store is reachable while the database is
+// open.
+func TestStore_Ping(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	_, s := open(t)
+
+	// action.
+	err := s.Ping(ctx)
+
+	// assert.
+	assert.NoError(t, err)
+	require.NoError(t, s.DB().Close())
+	assert.Error(t, s.Ping(ctx))
+}
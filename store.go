@@ -17,74 +17,690 @@ package obscurer
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/url"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // DefaultStore represents the default store.
 var DefaultStore = &memoryStore{}
 
+// ErrCollision represents an error that occurs when placing a mapping into
+// the store for an obscured URL that already maps to a different URL.
+var ErrCollision = errors.New("obscurer: obscured URL collides with an existing mapping")
+
+// CollisionError describes a collision between the obscured forms of two
+// different URLs. CollisionError matches ErrCollision when using errors.Is.
+type CollisionError struct {
+	// Obscured is the obscured URL both mappings share.
+	Obscured *url.URL
+	// Existing is the original URL already mapped to the obscured URL.
+	Existing *url.URL
+	// Original is the original URL that failed to be placed into the store.
+	Original *url.URL
+}
+
+// Error describes the collision.
+func (e *CollisionError) Error() string {
+	return fmt.Sprintf(
+		"%s: %q is already mapped to %q, not %q",
+		ErrCollision.Error(), e.Obscured, e.Existing, e.Original)
+}
+
+// Is indicates if the provided error is ErrCollision.
+func (e *CollisionError) Is(err error) bool {
+	return err == ErrCollision
+}
+
+// KeyStrategy represents how a store derives the key of a mapping from its
+// obscured URL.
+type KeyStrategy int
+
+const (
+	// KeyPath keys mappings by the path of their obscured URL, such that
+	// obscured URLs differing only by their query share a mapping.
+	KeyPath KeyStrategy = iota
+	// KeyRequestURI keys mappings by the path and query of their obscured
+	// URL, as in the request URI, such that obscured URLs differing only by
+	// their query have mappings of their own, each retaining the query of
+	// its original URL.
+	KeyRequestURI
+)
+
+// Key provides the key of the mapping for the provided obscured URL.
+func (k KeyStrategy) Key(obscured *url.URL) string {
+	if k == KeyRequestURI {
+		return obscured.RequestURI()
+	}
+	return obscured.Path
+}
+
+// URL provides the obscured URL of the mapping with the provided key.
+func (k KeyStrategy) URL(key string) *url.URL {
+	if k == KeyRequestURI {
+		if u, err := url.ParseRequestURI(key); err == nil {
+			return u
+		}
+	}
+	return &url.URL{Path: key}
+}
+
 // Store stores mappings between obscured URLs and their original form.
 type Store interface {
 	Put(ctx context.Context, obscured, original *url.URL) error
-	Get(context.Context, *url.URL) (*url.URL, bool)
+	Get(context.Context, *url.URL) (*url.URL, bool, error)
 	Remove(context.Context, *url.URL) error
 	Clear(context.Context) error
 	Size(context.Context) int
-	Load(context.Context, map[*url.URL]*url.URL) error
+	Load(context.Context, Mappings) error
+}
+
+// ExpiringStore represents a store capable of expiring mappings, so that
+// stale mappings age out instead of living forever.
+type ExpiringStore interface {
+	Store
+
+	// PutWithTTL places the mapping between the provided obscured URL and
+	// it's original form into the store, expiring it once the provided
+	// duration has elapsed. Expired mappings are treated as missing.
+	PutWithTTL(ctx context.Context, obscured, original *url.URL, ttl time.Duration) error
+}
+
+// ReverseStore represents a store capable of looking up the obscured form
+// of an original URL, such that applications generating links can reuse the
+// obscured URL already stored instead of obscuring the URL again.
+type ReverseStore interface {
+	Store
+
+	// GetObscured retrieves the obscured form of the provided original URL.
+	// When the original URL has been mapped more than once, the most recent
+	// mapping is retrieved.
+	GetObscured(ctx context.Context, original *url.URL) (*url.URL, bool, error)
+}
+
+// RangeStore represents a store capable of enumerating its mappings, so
+// that operators can audit or migrate them.
+type RangeStore interface {
+	Store
+
+	// Range invokes the provided function for each mapping in the store, in
+	// no particular order, until the function returns false. Mappings
+	// placed or removed while ranging may or may not be visited. An error
+	// is returned when the mappings fail to be enumerated.
+	Range(ctx context.Context, fn func(obscured, original *url.URL) bool) error
+}
+
+// ConsumingStore represents a store capable of placing mappings that
+// resolve a limited number of times, such as one-time links, whose uses are
+// consumed atomically so that concurrent requests can't both consume the
+// last use of a mapping.
+type ConsumingStore interface {
+	Store
+
+	// PutWithUses places the mapping between the provided obscured URL and
+	// it's original form into the store, resolving at most the provided
+	// number of times. Placing a mapping that already exists leaves its
+	// remaining uses as is.
+	PutWithUses(ctx context.Context, obscured, original *url.URL, uses int) error
+	// Consume consumes a use of the mapping for the provided obscured URL,
+	// providing its original form when a use remained. Mappings placed
+	// without a limit are retrieved as with Get. Mappings whose uses are
+	// exhausted are treated as missing.
+	Consume(ctx context.Context, obscured *url.URL) (*url.URL, bool, error)
+}
+
+// TombstoneStore represents a store remembering the obscured URLs whose
+// mappings were removed, such that requests for resources that were deleted
+// can be told apart from requests for URLs that never resolved.
+type TombstoneStore interface {
+	Store
+
+	// Buried indicates whether the mapping for the provided obscured URL
+	// was removed, and wasn't placed again since.
+	Buried(ctx context.Context, obscured *url.URL) (bool, error)
+}
+
+// Reason describes why a mapping was evicted from a store.
+type Reason int
+
+const (
+	// ReasonExpired indicates the mapping was evicted once it expired.
+	ReasonExpired Reason = iota + 1
+	// ReasonExhausted indicates the mapping was evicted once its last use
+	// was consumed.
+	ReasonExhausted
+)
+
+// String provides the name of the reason.
+func (r Reason) String() string {
+	switch r {
+	case ReasonExpired:
+		return "expired"
+	case ReasonExhausted:
+		return "exhausted"
+	default:
+		return fmt.Sprintf("Reason(%d)", int(r))
+	}
 }
 
-// memoryStore stores all obscured URL mappings in memory.
+// EvictingStore represents a store that evicts mappings on its own, such as
+// once they expire, and notifies the application of each eviction so that
+// it can log, alert, or re-warm the mappings.
+type EvictingStore interface {
+	Store
+
+	// OnEvict configures the function invoked for each mapping evicted from
+	// the store, replacing any function configured previously. A nil
+	// function disables notifications. The function is invoked
+	// synchronously by the goroutine evicting the mapping, without the
+	// store's locks held. Mappings removed by Remove or Clear are not
+	// evicted.
+	OnEvict(fn func(obscured, original *url.URL, reason Reason))
+}
+
+// ShardedStore represents a store partitioning its mappings into shards,
+// such that operators can locate the shard owning a mapping when debugging.
+type ShardedStore interface {
+	Store
+
+	// Shards provides the number of shards of the store.
+	Shards() int
+	// Shard provides the index of the shard owning the mapping for the
+	// provided obscured URL, whether or not the mapping exists.
+	Shard(obscured *url.URL) int
+}
+
+// expirySweepInterval represents the amount of time between sweeps of the
+// memory store for expired mappings.
+const expirySweepInterval = time.Minute
+
+// memoryShards represents the number of shards of the memory store, which
+// must be a power of two.
+const memoryShards = 32
+
+// memoryEntry represents a mapping held by the memory store.
+type memoryEntry struct {
+	original  url.URL
+	expiresAt time.Time
+	// uses is the number of times the mapping resolves before it is
+	// removed. Zero resolves indefinitely.
+	uses int
+}
+
+// expired indicates whether the entry has expired at the provided time.
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// memoryShard holds the mappings of the memory store whose obscured paths
+// hash to the shard.
+type memoryShard struct {
+	mutex   sync.RWMutex
+	entries map[string]memoryEntry
+}
+
+// reverseShard holds the reverse index entries of the memory store whose
+// original paths hash to the shard.
+type reverseShard struct {
+	mutex    sync.RWMutex
+	obscured map[string]url.URL
+}
+
+// memoryStore stores all obscured URL mappings in memory, spread across
+// shards guarded by their own locks so that concurrent requests rarely
+// contend. The lock of a mapping's shard is always acquired before the lock
+// of its reverse index shard.
 type memoryStore struct {
-	store sync.Map
+	// size counts the mappings held, including those that have expired but
+	// have yet to be swept. The counters come first to keep them aligned
+	// for atomic access on 32-bit platforms.
+	size int64
+	// expiring counts the mappings held with an expiration.
+	expiring int64
+	shards   [memoryShards]memoryShard
+	reverse  [memoryShards]reverseShard
+	// sweepMutex guards the state of the background sweep of expired
+	// mappings, which only runs while mappings are held with an
+	// expiration, until the store is closed.
+	sweepMutex sync.Mutex
+	sweeping   bool
+	closed     bool
+	stop       chan struct{}
+	// sweepInterval is the amount of time between sweeps. Zero sweeps
+	// every expirySweepInterval.
+	sweepInterval time.Duration
+	// onEvict holds the function invoked for each evicted mapping.
+	onEvict atomic.Value
+	// key derives the keys of mappings from their obscured URLs.
+	key KeyStrategy
+}
+
+// MemoryStoreOptions represents the configuration options for the memory
+// store.
+type MemoryStoreOptions struct {
+	// Key is the strategy the keys of mappings are derived with.
+	Key KeyStrategy
+	// SweepInterval is the amount of time between the sweeps of expired
+	// mappings. When zero, mappings are swept every minute.
+	SweepInterval time.Duration
+}
+
+// MemoryStoreOption applies an option to the provided configuration.
+type MemoryStoreOption func(*MemoryStoreOptions)
+
+// WithKeyStrategy configures the strategy the keys of mappings are derived
+// with.
+func WithKeyStrategy(k KeyStrategy) MemoryStoreOption {
+	return func(o *MemoryStoreOptions) {
+		o.Key = k
+	}
+}
+
+// WithSweepInterval configures the amount of time between the sweeps of
+// expired mappings.
+func WithSweepInterval(interval time.Duration) MemoryStoreOption {
+	return func(o *MemoryStoreOptions) {
+		o.SweepInterval = interval
+	}
+}
+
+// NewMemoryStore constructs a store holding mappings in memory, as
+// DefaultStore does, which keys mappings by the path of their obscured URL.
+// Expired mappings are swept in the background while mappings are held with
+// an expiration, until the store is closed with Close, whose store
+// implements io.Closer.
+func NewMemoryStore(opts ...MemoryStoreOption) Store {
+	var options MemoryStoreOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &memoryStore{key: options.Key, sweepInterval: options.SweepInterval}
+}
+
+// OnEvict configures the function invoked for each mapping evicted from the
+// store, either by the background sweep or upon being replaced, once
+// expired, or upon its last use being consumed.
+func (s *memoryStore) OnEvict(fn func(obscured, original *url.URL, reason Reason)) {
+	s.onEvict.Store(fn)
+}
+
+// evicted notifies the application of the eviction of the provided mapping.
+// The caller must not hold the locks of the store.
+func (s *memoryStore) evicted(obscured string, original url.URL, reason Reason) {
+	fn, _ := s.onEvict.Load().(func(obscured, original *url.URL, reason Reason))
+	if fn == nil {
+		return
+	}
+	fn(s.key.URL(obscured), &original, reason)
+}
+
+// shardIndex provides the index of the shard for the provided path, using
+// the 32-bit FNV-1a hash.
+func shardIndex(path string) uint32 {
+	hash := uint32(2166136261)
+	for i := 0; i < len(path); i++ {
+		hash ^= uint32(path[i])
+		hash *= 16777619
+	}
+	return hash & (memoryShards - 1)
+}
+
+// shard provides the shard holding the mapping for the provided obscured
+// path.
+func (s *memoryStore) shard(obscured string) *memoryShard {
+	return &s.shards[shardIndex(obscured)]
+}
+
+// reverseShard provides the reverse index shard for the provided original
+// path.
+func (s *memoryStore) reverseShard(original string) *reverseShard {
+	return &s.reverse[shardIndex(original)]
+}
+
+// Shards provides the number of shards of the store.
+func (s *memoryStore) Shards() int {
+	return memoryShards
+}
+
+// Shard provides the index of the shard owning the mapping for the provided
+// obscured URL. Mappings are sharded by their key alone, so vanity aliases
+// and obscured tokens share the same shard topology.
+func (s *memoryStore) Shard(obscured *url.URL) int {
+	return int(shardIndex(s.key.Key(obscured)))
 }
 
 // Put places the mapping between the provided obscured URL and it's original
-// form into the store.
+// form into the store. A *CollisionError is returned when the obscured URL
+// is already mapped to a URL with a different path.
 func (s *memoryStore) Put(ctx context.Context, obscured, original *url.URL) error {
-	if _, ok := s.store.Load(obscured.Path); !ok {
-		s.store.Store(obscured.Path, *original)
+	return s.put(obscured, memoryEntry{original: *original})
+}
+
+// PutWithTTL places the mapping between the provided obscured URL and it's
+// original form into the store, expiring it once the provided duration has
+// elapsed. Expired mappings are removed from the store in the background.
+func (s *memoryStore) PutWithTTL(ctx context.Context, obscured, original *url.URL, ttl time.Duration) error {
+	if ttl <= 0 {
+		return s.Put(ctx, obscured, original)
+	}
+	err := s.put(obscured, memoryEntry{original: *original, expiresAt: time.Now().Add(ttl)})
+	s.startSweep()
+	return err
+}
+
+// startSweep starts the background sweep of expired mappings, unless it is
+// running or the store is closed.
+func (s *memoryStore) startSweep() {
+	s.sweepMutex.Lock()
+	defer s.sweepMutex.Unlock()
+	if s.sweeping || s.closed {
+		return
+	}
+	interval := s.sweepInterval
+	if interval <= 0 {
+		interval = expirySweepInterval
+	}
+	if s.stop == nil {
+		s.stop = make(chan struct{})
+	}
+	s.sweeping = true
+	go s.sweeper(interval, s.stop)
+}
+
+// Close stops the background sweep of expired mappings, such that the
+// store no longer holds a goroutine. The store remains usable, treating
+// expired mappings as missing, though they are no longer swept.
+func (s *memoryStore) Close() error {
+	s.sweepMutex.Lock()
+	defer s.sweepMutex.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if s.stop != nil {
+		close(s.stop)
 	}
 	return nil
 }
 
+// put places the provided entry into the store, replacing any expired entry
+// for the same obscured URL.
+func (s *memoryStore) put(obscured *url.URL, entry memoryEntry) error {
+	key := s.key.Key(obscured)
+	shard := s.shard(key)
+	shard.mutex.Lock()
+	expired, replaced, err := s.insert(shard, obscured, entry)
+	shard.mutex.Unlock()
+	if replaced {
+		s.evicted(key, expired.original, ReasonExpired)
+	}
+	return err
+}
+
+// insert places the provided entry into the provided shard, providing the
+// expired entry it replaced, if any. The caller must hold the lock of the
+// shard.
+func (s *memoryStore) insert(shard *memoryShard, obscured *url.URL, entry memoryEntry) (expired memoryEntry, replaced bool, err error) {
+	key := s.key.Key(obscured)
+	if existing, ok := shard.entries[key]; ok {
+		if !existing.expired(time.Now()) {
+			if existing.original.Path != entry.original.Path {
+				existingOriginal, original := existing.original, entry.original
+				err = &CollisionError{
+					Obscured: obscured,
+					Existing: &existingOriginal,
+					Original: &original,
+				}
+			}
+			return
+		}
+		s.delete(shard, key)
+		expired, replaced = existing, true
+	}
+	if shard.entries == nil {
+		shard.entries = make(map[string]memoryEntry)
+	}
+	shard.entries[key] = entry
+	atomic.AddInt64(&s.size, 1)
+	if !entry.expiresAt.IsZero() {
+		atomic.AddInt64(&s.expiring, 1)
+	}
+	reverse := s.reverseShard(entry.original.Path)
+	reverse.mutex.Lock()
+	if reverse.obscured == nil {
+		reverse.obscured = make(map[string]url.URL)
+	}
+	reverse.obscured[entry.original.Path] = *obscured
+	reverse.mutex.Unlock()
+	return
+}
+
+// delete removes the entry for the provided obscured path from the provided
+// shard, along with its reverse index entry when it still refers to the
+// obscured path. The caller must hold the lock of the shard.
+func (s *memoryStore) delete(shard *memoryShard, obscured string) {
+	entry, ok := shard.entries[obscured]
+	if !ok {
+		return
+	}
+	delete(shard.entries, obscured)
+	atomic.AddInt64(&s.size, -1)
+	if !entry.expiresAt.IsZero() {
+		atomic.AddInt64(&s.expiring, -1)
+	}
+	reverse := s.reverseShard(entry.original.Path)
+	reverse.mutex.Lock()
+	if indexed, ok := reverse.obscured[entry.original.Path]; ok && s.key.Key(&indexed) == obscured {
+		delete(reverse.obscured, entry.original.Path)
+	}
+	reverse.mutex.Unlock()
+}
+
+// sweeper removes expired mappings at the provided interval, until the
+// provided channel is closed, or no mappings are held with an expiration.
+func (s *memoryStore) sweeper(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			s.removeExpired(now)
+		}
+		s.sweepMutex.Lock()
+		if atomic.LoadInt64(&s.expiring) == 0 {
+			s.sweeping = false
+			s.sweepMutex.Unlock()
+			return
+		}
+		s.sweepMutex.Unlock()
+	}
+}
+
+// removeExpired removes the mappings that have expired at the provided
+// time.
+func (s *memoryStore) removeExpired(now time.Time) {
+	notify := s.onEvict.Load() != nil
+	var expired []memoryEntry
+	var obscured []string
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mutex.Lock()
+		for path, entry := range shard.entries {
+			if entry.expired(now) {
+				s.delete(shard, path)
+				if notify {
+					obscured, expired = append(obscured, path), append(expired, entry)
+				}
+			}
+		}
+		shard.mutex.Unlock()
+		for j := range expired {
+			s.evicted(obscured[j], expired[j].original, ReasonExpired)
+		}
+		obscured, expired = obscured[:0], expired[:0]
+	}
+}
+
 // Get retrieves the original form of the provided obscured URL.
-func (s *memoryStore) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool) {
-	original, ok := s.store.Load(obscured.Path)
-	if ok {
-		originalURL := original.(url.URL)
-		return &originalURL, ok
+func (s *memoryStore) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	key := s.key.Key(obscured)
+	shard := s.shard(key)
+	shard.mutex.RLock()
+	entry, ok := shard.entries[key]
+	shard.mutex.RUnlock()
+	if !ok || !entry.expiresAt.IsZero() && entry.expired(time.Now()) {
+		return nil, false, nil
+	}
+	original := entry.original
+	return &original, true, nil
+}
+
+// GetObscured retrieves the obscured form of the provided original URL.
+func (s *memoryStore) GetObscured(ctx context.Context, original *url.URL) (*url.URL, bool, error) {
+	reverse := s.reverseShard(original.Path)
+	reverse.mutex.RLock()
+	obscured, ok := reverse.obscured[original.Path]
+	reverse.mutex.RUnlock()
+	if !ok {
+		return nil, false, nil
+	}
+	if mapped, ok, _ := s.Get(ctx, &obscured); !ok || mapped.Path != original.Path {
+		// the mapping has expired, or was replaced once expired.
+		return nil, false, nil
 	}
-	return nil, ok
+	return &obscured, true, nil
+}
+
+// PutWithUses places the mapping between the provided obscured URL and it's
+// original form into the store, removing it once it has been consumed the
+// provided number of times.
+func (s *memoryStore) PutWithUses(ctx context.Context, obscured, original *url.URL, uses int) error {
+	if uses <= 0 {
+		return s.Put(ctx, obscured, original)
+	}
+	return s.put(obscured, memoryEntry{original: *original, uses: uses})
+}
+
+// Consume consumes a use of the mapping for the provided obscured URL,
+// removing the mapping once its last use is consumed.
+func (s *memoryStore) Consume(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	key := s.key.Key(obscured)
+	shard := s.shard(key)
+	shard.mutex.RLock()
+	entry, ok := shard.entries[key]
+	shard.mutex.RUnlock()
+	if !ok {
+		return nil, false, nil
+	}
+	if entry.uses == 0 {
+		// mappings without a limit are retrieved without contention.
+		return s.Get(ctx, obscured)
+	}
+	shard.mutex.Lock()
+	entry, ok = shard.entries[key]
+	if !ok || entry.expired(time.Now()) {
+		shard.mutex.Unlock()
+		return nil, false, nil
+	}
+	deleted := false
+	switch entry.uses {
+	case 0:
+	case 1:
+		s.delete(shard, key)
+		deleted = true
+	default:
+		entry.uses--
+		shard.entries[key] = entry
+	}
+	shard.mutex.Unlock()
+	if deleted {
+		s.evicted(key, entry.original, ReasonExhausted)
+	}
+	original := entry.original
+	return &original, true, nil
+}
+
+// Range invokes the provided function for each unexpired mapping in the
+// store, until the function returns false. Each shard is locked while its
+// mappings are visited, so the function must not modify the store.
+func (s *memoryStore) Range(ctx context.Context, fn func(obscured, original *url.URL) bool) error {
+	now := time.Now()
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mutex.RLock()
+		for obscured, entry := range shard.entries {
+			if entry.expired(now) {
+				continue
+			}
+			original := entry.original
+			if !fn(s.key.URL(obscured), &original) {
+				shard.mutex.RUnlock()
+				return nil
+			}
+		}
+		shard.mutex.RUnlock()
+	}
+	return nil
 }
 
 // Remove deletes the entry in the store for the provided obscured URL.
 func (s *memoryStore) Remove(ctx context.Context, obscured *url.URL) error {
-	s.store.Delete(obscured.Path)
+	key := s.key.Key(obscured)
+	shard := s.shard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	s.delete(shard, key)
 	return nil
 }
 
 // Clear removes all entries in the store.
 func (s *memoryStore) Clear(ctx context.Context) error {
-	s.store.Range(func(key, value interface{}) bool {
-		s.store.Delete(key)
-		return true
-	})
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mutex.Lock()
+		for obscured := range shard.entries {
+			s.delete(shard, obscured)
+		}
+		shard.mutex.Unlock()
+	}
 	return nil
 }
 
-// Size computes the size of the store.
-func (s *memoryStore) Size(ctx context.Context) (size int) {
-	s.store.Range(func(key, value interface{}) bool {
-		size = size + 1
-		return true
-	})
-	return
+// Size computes the size of the store, excluding expired mappings. The size
+// is kept as the store changes, so computing it only requires visiting the
+// mappings while some are held with an expiration.
+func (s *memoryStore) Size(ctx context.Context) int {
+	size := atomic.LoadInt64(&s.size)
+	if atomic.LoadInt64(&s.expiring) == 0 {
+		return int(size)
+	}
+	now := time.Now()
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mutex.RLock()
+		for _, entry := range shard.entries {
+			if entry.expired(now) {
+				size--
+			}
+		}
+		shard.mutex.RUnlock()
+	}
+	return int(size)
+}
+
+// Load loads the store with the provided mappings.
+func (s *memoryStore) Load(ctx context.Context, mappings Mappings) error {
+	return s.PutAll(ctx, mappings.Pointers())
 }
 
-// Load loads the store with the provided map, where the keys are
+// PutAll places the provided mappings into the store, where the keys are
 // obscured URLs and the values are their corresponding originals.
-func (s *memoryStore) Load(ctx context.Context, mappings map[*url.URL]*url.URL) error {
+func (s *memoryStore) PutAll(ctx context.Context, mappings map[*url.URL]*url.URL) error {
 	for obscured, unobscured := range mappings {
 		if err := s.Put(ctx, obscured, unobscured); err != nil {
 			return err
@@ -92,3 +708,12 @@ func (s *memoryStore) Load(ctx context.Context, mappings map[*url.URL]*url.URL)
 	}
 	return nil
 }
+
+// RemoveAll deletes the entries in the store for the provided obscured
+// URLs.
+func (s *memoryStore) RemoveAll(ctx context.Context, urls []*url.URL) error {
+	for _, u := range urls {
+		s.Remove(ctx, u)
+	}
+	return nil
+}
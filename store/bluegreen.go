@@ -0,0 +1,179 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/freerware/obscurer"
+)
+
+const (
+	// Blue represents the label of the blue mapping set.
+	Blue = "blue"
+	// Green represents the label of the green mapping set.
+	Green = "green"
+)
+
+// ErrUnknownSet represents an error that occurs when a mapping set other
+// than Blue or Green is requested.
+var ErrUnknownSet = errors.New("store: unknown mapping set")
+
+// activeKey represents the obscured URL under which the label of the active
+// mapping set is kept, which lies outside of either set.
+var activeKey = &url.URL{Path: "/.active"}
+
+// BlueGreen maintains two labeled mapping sets within a store, one of which
+// is active and serves every operation, while the other can be seeded
+// offline, such as for a new release, and then switched to instantly.
+// Switching back rolls the release back, as the formerly active set is kept
+// as is until it is seeded again.
+type BlueGreen struct {
+	base   obscurer.Store
+	sets   map[string]obscurer.Store
+	active atomic.Value
+}
+
+// NewBlueGreen constructs blue/green mapping sets within the provided store,
+// which must be an obscurer.RangeStore for the sets to be cleared and
+// sized. The mappings of each set are kept as WithNamespace keeps them,
+// under the label of the set, and the label of the active set is kept in
+// the provided store, such that every instance sharing it can agree upon
+// it. The blue set is active until switched.
+func NewBlueGreen(ctx context.Context, base obscurer.Store) (*BlueGreen, error) {
+	s := &BlueGreen{
+		base: base,
+		sets: map[string]obscurer.Store{
+			Blue:  WithNamespace(base, Blue),
+			Green: WithNamespace(base, Green),
+		},
+	}
+	s.active.Store(Blue)
+	if err := s.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Active provides the label of the active mapping set.
+func (s *BlueGreen) Active() string {
+	return s.active.Load().(string)
+}
+
+// Set provides the mapping set with the provided label.
+func (s *BlueGreen) Set(label string) (obscurer.Store, error) {
+	set, ok := s.sets[label]
+	if !ok {
+		return nil, ErrUnknownSet
+	}
+	return set, nil
+}
+
+// Staging provides the inactive mapping set, which can be cleared and
+// seeded without affecting the active one.
+func (s *BlueGreen) Staging() obscurer.Store {
+	return s.sets[s.inactive()]
+}
+
+// inactive provides the label of the inactive mapping set.
+func (s *BlueGreen) inactive() string {
+	if s.Active() == Blue {
+		return Green
+	}
+	return Blue
+}
+
+// Switch makes the mapping set with the provided label active, keeping its
+// label in the underlying store before switching to it.
+func (s *BlueGreen) Switch(ctx context.Context, label string) error {
+	if _, ok := s.sets[label]; !ok {
+		return ErrUnknownSet
+	}
+	if err := s.base.Remove(ctx, activeKey); err != nil {
+		return err
+	}
+	if err := s.base.Put(ctx, activeKey, &url.URL{Path: "/" + label}); err != nil {
+		return err
+	}
+	s.active.Store(label)
+	return nil
+}
+
+// Rollback makes the inactive mapping set active again.
+func (s *BlueGreen) Rollback(ctx context.Context) error {
+	return s.Switch(ctx, s.inactive())
+}
+
+// Refresh switches to the mapping set whose label is kept in the underlying
+// store, such as after another instance switched sets.
+func (s *BlueGreen) Refresh(ctx context.Context) error {
+	u, ok, err := s.base.Get(ctx, activeKey)
+	if err != nil || !ok {
+		return err
+	}
+	label := strings.TrimPrefix(u.Path, "/")
+	if _, ok := s.sets[label]; !ok {
+		return ErrUnknownSet
+	}
+	s.active.Store(label)
+	return nil
+}
+
+// current provides the active mapping set.
+func (s *BlueGreen) current() obscurer.Store {
+	return s.sets[s.Active()]
+}
+
+// Put places the mapping into the active mapping set.
+func (s *BlueGreen) Put(ctx context.Context, obscured, original *url.URL) error {
+	return s.current().Put(ctx, obscured, original)
+}
+
+// Get retrieves the original form of the provided obscured URL from the
+// active mapping set.
+func (s *BlueGreen) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	return s.current().Get(ctx, obscured)
+}
+
+// Remove deletes the entry from the active mapping set.
+func (s *BlueGreen) Remove(ctx context.Context, obscured *url.URL) error {
+	return s.current().Remove(ctx, obscured)
+}
+
+// Clear removes all entries from the active mapping set.
+func (s *BlueGreen) Clear(ctx context.Context) error {
+	return s.current().Clear(ctx)
+}
+
+// Size computes the number of mappings within the active mapping set.
+func (s *BlueGreen) Size(ctx context.Context) int {
+	return s.current().Size(ctx)
+}
+
+// Load loads the provided mappings into the active mapping set.
+func (s *BlueGreen) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	return s.current().Load(ctx, mappings)
+}
+
+// Ping verifies the backend of the store keeping the mapping sets is
+// reachable.
+func (s *BlueGreen) Ping(ctx context.Context) error {
+	return obscurer.Ping(ctx, s.base)
+}
@@ -0,0 +1,104 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store_test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBlueGreen_Switch tests that the staging mapping set can be seeded
+// without affecting the active one, switched to, and rolled back.
+func TestBlueGreen_Switch(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	base := obscurer.NewMemoryStore()
+	s, err := store.NewBlueGreen(ctx, base)
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+
+	// action.
+	require.Equal(t, store.Blue, s.Active())
+	staging := s.Staging()
+	require.NoError(t, obscurer.LoadAll(ctx, staging, map[*url.URL]*url.URL{
+		mustParse("/abc"): mustParse("/hey/der"),
+	}))
+	got, ok, err := s.Get(ctx, mustParse("/abc"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/this/is/the/way", got.String(), "expected the active set to be unaffected by seeding")
+	require.NoError(t, s.Switch(ctx, store.Green))
+
+	// assert.
+	assert.Equal(t, store.Green, s.Active())
+	got, ok, err = s.Get(ctx, mustParse("/abc"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/hey/der", got.String())
+	assert.Equal(t, 1, s.Size(ctx))
+	require.NoError(t, s.Rollback(ctx))
+	assert.Equal(t, store.Blue, s.Active())
+	got, ok, err = s.Get(ctx, mustParse("/abc"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/this/is/the/way", got.String())
+}
+
+// TestBlueGreen_Refresh tests that instances sharing a store agree upon the
+// active mapping set.
+func TestBlueGreen_Refresh(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	base := obscurer.NewMemoryStore()
+	first, err := store.NewBlueGreen(ctx, base)
+	require.NoError(t, err)
+	second, err := store.NewBlueGreen(ctx, base)
+	require.NoError(t, err)
+
+	// action.
+	require.NoError(t, first.Switch(ctx, store.Green))
+	third, err := store.NewBlueGreen(ctx, base)
+	require.NoError(t, err)
+	require.NoError(t, second.Refresh(ctx))
+
+	// assert.
+	assert.Equal(t, store.Green, second.Active())
+	assert.Equal(t, store.Green, third.Active())
+}
+
+// TestBlueGreen_UnknownSet tests that only the blue and green mapping sets
+// can be switched to.
+func TestBlueGreen_UnknownSet(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s, err := store.NewBlueGreen(ctx, obscurer.NewMemoryStore())
+	require.NoError(t, err)
+
+	// action.
+	err = s.Switch(ctx, "red")
+
+	// assert.
+	assert.Equal(t, store.ErrUnknownSet, err)
+	assert.Equal(t, store.Blue, s.Active())
+	_, err = s.Set("red")
+	assert.Equal(t, store.ErrUnknownSet, err)
+}
@@ -0,0 +1,107 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package store provides composable obscurer.Store implementations that
+// build on top of other stores.
+package store
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/freerware/obscurer"
+)
+
+// fanOut writes to multiple stores simultaneously, and reads from the
+// first store that has the requested mapping.
+type fanOut struct {
+	stores []obscurer.Store
+}
+
+// NewFanOut constructs a store that writes to all of the provided stores,
+// and reads from them in the order provided. This is useful when migrating
+// between stores, or when mappings must also be archived elsewhere.
+func NewFanOut(primary obscurer.Store, others ...obscurer.Store) obscurer.Store {
+	return &fanOut{stores: append([]obscurer.Store{primary}, others...)}
+}
+
+// each invokes the provided function for every store, returning the first
+// error encountered after every store has been visited.
+func (s *fanOut) each(fn func(obscurer.Store) error) (err error) {
+	for _, store := range s.stores {
+		if e := fn(store); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// Put places the mapping into every store.
+func (s *fanOut) Put(ctx context.Context, obscured, original *url.URL) error {
+	return s.each(func(store obscurer.Store) error {
+		return store.Put(ctx, obscured, original)
+	})
+}
+
+// Get retrieves the original form of the provided obscured URL from the
+// first store that has it. Stores failing to be read are skipped, and the
+// first error is returned when no store has the mapping.
+func (s *fanOut) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	var err error
+	for _, store := range s.stores {
+		original, ok, e := store.Get(ctx, obscured)
+		if ok {
+			return original, ok, nil
+		}
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+	return nil, false, err
+}
+
+// Remove deletes the entry for the provided obscured URL from every store.
+func (s *fanOut) Remove(ctx context.Context, obscured *url.URL) error {
+	return s.each(func(store obscurer.Store) error {
+		return store.Remove(ctx, obscured)
+	})
+}
+
+// Clear removes all entries from every store.
+func (s *fanOut) Clear(ctx context.Context) error {
+	return s.each(func(store obscurer.Store) error {
+		return store.Clear(ctx)
+	})
+}
+
+// Size computes the size of the primary store.
+func (s *fanOut) Size(ctx context.Context) int {
+	return s.stores[0].Size(ctx)
+}
+
+// Load loads every store with the provided mappings.
+func (s *fanOut) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	return s.each(func(store obscurer.Store) error {
+		return store.Load(ctx, mappings)
+	})
+}
+
+// Ping verifies the backend of every store is reachable, returning the
+// first error encountered.
+func (s *fanOut) Ping(ctx context.Context) error {
+	return s.each(func(store obscurer.Store) error {
+		return obscurer.Ping(ctx, store)
+	})
+}
@@ -0,0 +1,169 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/freerware/obscurer/internal/mock"
+	"github.com/freerware/obscurer/store"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFanOut_Put tests that mappings are written to every store.
+func TestFanOut_Put(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	primary, secondary := mock.NewStore(ctrl), mock.NewStore(ctrl)
+	obscured, original := mustParse("/abc"), mustParse("/this/is/the/way")
+	primary.EXPECT().Put(ctx, obscured, original).Return(nil)
+	secondary.EXPECT().Put(ctx, obscured, original).Return(nil)
+	s := store.NewFanOut(primary, secondary)
+
+	// action + assert.
+	assert.NoError(t, s.Put(ctx, obscured, original))
+}
+
+// TestFanOut_Put_Error tests that every store is written to even when one
+// of them fails, and that the failure is returned.
+func TestFanOut_Put_Error(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	primary, secondary := mock.NewStore(ctrl), mock.NewStore(ctrl)
+	expectedErr := errors.New("whoa")
+	primary.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(expectedErr)
+	secondary.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	s := store.NewFanOut(primary, secondary)
+
+	// action + assert.
+	assert.Equal(t, expectedErr, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+}
+
+// TestFanOut_Get tests that mappings are read from the first store that
+// has them.
+func TestFanOut_Get(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	primary, secondary := mock.NewStore(ctrl), mock.NewStore(ctrl)
+	obscured, original := mustParse("/abc"), mustParse("/this/is/the/way")
+	primary.EXPECT().Get(ctx, obscured).Return(nil, false, nil)
+	secondary.EXPECT().Get(ctx, obscured).Return(original, true, nil)
+	s := store.NewFanOut(primary, secondary)
+
+	// action.
+	got, ok, err := s.Get(ctx, obscured)
+
+	// assert.
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, original, got)
+}
+
+// TestFanOut_Get_Primary tests that secondary stores are not consulted
+// when the primary store has the mapping.
+func TestFanOut_Get_Primary(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	primary, secondary := mock.NewStore(ctrl), mock.NewStore(ctrl)
+	obscured, original := mustParse("/abc"), mustParse("/this/is/the/way")
+	primary.EXPECT().Get(ctx, obscured).Return(original, true, nil)
+	s := store.NewFanOut(primary, secondary)
+
+	// action.
+	got, ok, err := s.Get(ctx, obscured)
+
+	// assert.
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, original, got)
+}
+
+// TestFanOut_Remove tests that mappings are removed from every store.
+func TestFanOut_Remove(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	primary, secondary := mock.NewStore(ctrl), mock.NewStore(ctrl)
+	obscured := mustParse("/abc")
+	primary.EXPECT().Remove(ctx, obscured).Return(nil)
+	secondary.EXPECT().Remove(ctx, obscured).Return(nil)
+	s := store.NewFanOut(primary, secondary)
+
+	// action + assert.
+	assert.NoError(t, s.Remove(ctx, obscured))
+}
+
+// TestFanOut_Size tests that the size of the primary store is reported.
+func TestFanOut_Size(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	primary, secondary := mock.NewStore(ctrl), mock.NewStore(ctrl)
+	primary.EXPECT().Size(ctx).Return(3)
+	s := store.NewFanOut(primary, secondary)
+
+	// action + assert.
+	assert.Equal(t, 3, s.Size(ctx))
+}
+
+// TestFanOut_Get_Error tests that stores failing to be read are skipped,
+// and their error returned when no store has the mapping.
+func TestFanOut_Get_Error(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	primary, secondary := mock.NewStore(ctrl), mock.NewStore(ctrl)
+	obscured, original := mustParse("/abc"), mustParse("/this/is/the/way")
+	expectedErr := errors.New("whoa")
+	primary.EXPECT().Get(ctx, obscured).Return(nil, false, expectedErr).Times(2)
+	secondary.EXPECT().Get(ctx, obscured).Return(original, true, nil)
+	secondary.EXPECT().Get(ctx, obscured).Return(nil, false, nil)
+	s := store.NewFanOut(primary, secondary)
+
+	// action.
+	got, ok, err := s.Get(ctx, obscured)
+	_, missed, missErr := s.Get(ctx, obscured)
+
+	// assert.
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, original, got)
+	assert.Equal(t, expectedErr, missErr)
+	assert.False(t, missed)
+}
+
+func mustParse(str string) *url.URL {
+	u, err := url.Parse(str)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
@@ -0,0 +1,127 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/freerware/obscurer"
+)
+
+// Logger represents a logger, such as a *log.Logger.
+type Logger interface {
+	// Printf logs the provided message, formatted as with fmt.Printf.
+	Printf(format string, args ...interface{})
+}
+
+// logging logs the operations of the underlying store.
+type logging struct {
+	store  obscurer.Store
+	logger Logger
+}
+
+// NewLogging constructs a store logging the operations of the provided
+// store with the provided logger, along with their duration and outcome.
+func NewLogging(s obscurer.Store, logger Logger) obscurer.Store {
+	return &logging{store: s, logger: logger}
+}
+
+// Logging provides a decorator logging the operations of the decorated
+// store with the provided logger.
+func Logging(logger Logger) Decorator {
+	return func(s obscurer.Store) obscurer.Store {
+		return NewLogging(s, logger)
+	}
+}
+
+// log logs the provided operation, which started at the provided time, and
+// its outcome.
+func (s *logging) log(operation string, start time.Time, outcome interface{}) {
+	s.logger.Printf("obscurer: store %s: %v (%s)", operation, outcome, time.Since(start))
+}
+
+// outcome provides the outcome of an operation that failed with the
+// provided error, if any.
+func outcome(err error) interface{} {
+	if err != nil {
+		return err
+	}
+	return "ok"
+}
+
+// Put places the mapping into the underlying store.
+func (s *logging) Put(ctx context.Context, obscured, original *url.URL) error {
+	start := time.Now()
+	err := s.store.Put(ctx, obscured, original)
+	s.log("put "+obscured.String()+" -> "+original.String(), start, outcome(err))
+	return err
+}
+
+// Get retrieves the original form of the provided obscured URL from the
+// underlying store.
+func (s *logging) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	start := time.Now()
+	original, ok, err := s.store.Get(ctx, obscured)
+	var result interface{} = "miss"
+	switch {
+	case err != nil:
+		result = err
+	case ok:
+		result = "hit"
+	}
+	s.log("get "+obscured.String(), start, result)
+	return original, ok, err
+}
+
+// Remove deletes the entry from the underlying store.
+func (s *logging) Remove(ctx context.Context, obscured *url.URL) error {
+	start := time.Now()
+	err := s.store.Remove(ctx, obscured)
+	s.log("remove "+obscured.String(), start, outcome(err))
+	return err
+}
+
+// Clear removes all entries from the underlying store.
+func (s *logging) Clear(ctx context.Context) error {
+	start := time.Now()
+	err := s.store.Clear(ctx)
+	s.log("clear", start, outcome(err))
+	return err
+}
+
+// Size computes the size of the underlying store.
+func (s *logging) Size(ctx context.Context) int {
+	start := time.Now()
+	size := s.store.Size(ctx)
+	s.log("size", start, size)
+	return size
+}
+
+// Load loads the underlying store.
+func (s *logging) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	start := time.Now()
+	err := s.store.Load(ctx, mappings)
+	s.log("load "+strconv.Itoa(mappings.Len())+" mappings", start, outcome(err))
+	return err
+}
+
+// Ping verifies the backend of the decorated store is reachable.
+func (s *logging) Ping(ctx context.Context) error {
+	return obscurer.Ping(ctx, s.store)
+}
@@ -0,0 +1,59 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/freerware/obscurer/internal/mock"
+	"github.com/freerware/obscurer/store"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLogging tests that operations are logged with their outcome.
+func TestLogging(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	base := mock.NewStore(ctrl)
+	expectedErr := errors.New("whoa")
+	base.EXPECT().Put(ctx, gomock.Any(), gomock.Any()).Return(expectedErr)
+	base.EXPECT().Get(ctx, gomock.Any()).Return(mustParse("/this/is/the/way"), true, nil)
+	base.EXPECT().Size(ctx).Return(1)
+	var buf bytes.Buffer
+	s := store.NewLogging(base, log.New(&buf, "", 0))
+
+	// action.
+	err := s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way"))
+	s.Get(ctx, mustParse("/abc"))
+	s.Size(ctx)
+
+	// assert.
+	assert.Equal(t, expectedErr, err)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "obscurer: store put /abc -> /this/is/the/way: whoa ("), lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "obscurer: store get /abc: hit ("), lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], "obscurer: store size: 1 ("), lines[2])
+}
@@ -0,0 +1,197 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/freerware/obscurer"
+)
+
+var (
+	// ErrNoNamespace represents an error that occurs when the context of an
+	// operation of a store namespaced by context carries no namespace.
+	ErrNoNamespace = errors.New("store: no namespace in context")
+	// ErrUnsupported represents an error that occurs when an operation
+	// requires a capability the underlying store lacks.
+	ErrUnsupported = errors.New("store: operation unsupported by the underlying store")
+)
+
+// namespaced partitions the mappings of the underlying store by namespace,
+// prefixing the paths of obscured URLs with their namespace.
+type namespaced struct {
+	store     obscurer.Store
+	namespace func(context.Context) (string, error)
+	// contextual indicates whether the namespace is carried by the context
+	// of each operation.
+	contextual bool
+}
+
+// WithNamespace constructs a store partitioning the mappings of the provided
+// store by the provided namespace, such as a tenant, so that the mappings
+// of one namespace can't be resolved within another. Mappings are kept
+// under obscured URLs whose paths are prefixed by the escaped namespace.
+// Clearing and sizing the store visit only the mappings of the namespace,
+// and require the provided store to be an obscurer.RangeStore.
+func WithNamespace(s obscurer.Store, namespace string) obscurer.Store {
+	return &namespaced{store: s, namespace: func(context.Context) (string, error) {
+		return namespace, nil
+	}}
+}
+
+// WithContextNamespace constructs a store partitioning the mappings of the
+// provided store as WithNamespace does, by the namespace carried by the
+// context of each operation, such as that provided by the handler when
+// configured with obscurer.WithNamespacer. Operations whose context carries
+// no namespace fail with ErrNoNamespace, and the store is empty to them.
+func WithContextNamespace(s obscurer.Store) obscurer.Store {
+	return &namespaced{store: s, contextual: true, namespace: func(ctx context.Context) (string, error) {
+		namespace, ok := obscurer.NamespaceFromContext(ctx)
+		if !ok {
+			return "", ErrNoNamespace
+		}
+		return namespace, nil
+	}}
+}
+
+// prefix provides the prefix of the paths of the obscured URLs within the
+// namespace of the provided context.
+func (s *namespaced) prefix(ctx context.Context) (string, error) {
+	namespace, err := s.namespace(ctx)
+	if err != nil {
+		return "", err
+	}
+	return "/" + url.PathEscape(namespace), nil
+}
+
+// key provides the obscured URL under which the mapping of the provided
+// obscured URL is kept.
+func (s *namespaced) key(ctx context.Context, obscured *url.URL) (*url.URL, error) {
+	prefix, err := s.prefix(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key := *obscured
+	key.Path = prefix + obscured.Path
+	key.RawPath = ""
+	return &key, nil
+}
+
+// Put places the mapping into the underlying store, within the namespace.
+func (s *namespaced) Put(ctx context.Context, obscured, original *url.URL) error {
+	key, err := s.key(ctx, obscured)
+	if err != nil {
+		return err
+	}
+	return s.store.Put(ctx, key, original)
+}
+
+// Get retrieves the original form of the provided obscured URL from the
+// underlying store, within the namespace.
+func (s *namespaced) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	key, err := s.key(ctx, obscured)
+	if err != nil {
+		return nil, false, err
+	}
+	return s.store.Get(ctx, key)
+}
+
+// Remove deletes the entry from the underlying store, within the namespace.
+func (s *namespaced) Remove(ctx context.Context, obscured *url.URL) error {
+	key, err := s.key(ctx, obscured)
+	if err != nil {
+		return err
+	}
+	return s.store.Remove(ctx, key)
+}
+
+// Range invokes the provided function for each mapping within the
+// namespace, with the obscured URL as it was placed, until the function
+// returns false.
+func (s *namespaced) Range(ctx context.Context, fn func(obscured, original *url.URL) bool) error {
+	ranger, ok := s.store.(obscurer.RangeStore)
+	if !ok {
+		return ErrUnsupported
+	}
+	prefix, err := s.prefix(ctx)
+	if err != nil {
+		return err
+	}
+	return ranger.Range(ctx, func(key, original *url.URL) bool {
+		if !strings.HasPrefix(key.Path, prefix+"/") {
+			return true
+		}
+		obscured := *key
+		obscured.Path = strings.TrimPrefix(key.Path, prefix)
+		obscured.RawPath = ""
+		return fn(&obscured, original)
+	})
+}
+
+// Clear removes all entries within the namespace from the underlying store.
+func (s *namespaced) Clear(ctx context.Context) error {
+	ranger, ok := s.store.(obscurer.RangeStore)
+	if !ok {
+		return ErrUnsupported
+	}
+	prefix, err := s.prefix(ctx)
+	if err != nil {
+		return err
+	}
+	var keys []*url.URL
+	if err := ranger.Range(ctx, func(key, original *url.URL) bool {
+		if strings.HasPrefix(key.Path, prefix+"/") {
+			keys = append(keys, key)
+		}
+		return true
+	}); err != nil {
+		return err
+	}
+	return obscurer.RemoveAll(ctx, s.store, keys)
+}
+
+// Size computes the number of mappings within the namespace, which is zero
+// when they can't be ranged over.
+func (s *namespaced) Size(ctx context.Context) (size int) {
+	s.Range(ctx, func(obscured, original *url.URL) bool {
+		size++
+		return true
+	})
+	return
+}
+
+// Load loads the provided mappings into the underlying store, within the
+// namespace.
+func (s *namespaced) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	keyed := obscurer.Mappings{KeyStrategy: mappings.KeyStrategy}
+	for obscured, original := range mappings.Pointers() {
+		key, err := s.key(ctx, obscured)
+		if err != nil {
+			return err
+		}
+		keyed.Add(key, original)
+	}
+	return s.store.Load(ctx, keyed)
+}
+
+// Ping verifies the backend of the underlying store is reachable, which is
+// shared by every namespace.
+func (s *namespaced) Ping(ctx context.Context) error {
+	return obscurer.Ping(ctx, s.store)
+}
@@ -0,0 +1,202 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/internal/mock"
+	"github.com/freerware/obscurer/store"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWithNamespace tests that the mappings of each namespace are isolated
+// from one another.
+func TestWithNamespace(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	t.Cleanup(func() {
+		obscurer.DefaultStore.Clear(ctx)
+	})
+	tenant42 := store.WithNamespace(obscurer.DefaultStore, "tenant-42")
+	tenant7 := store.WithNamespace(obscurer.DefaultStore, "tenant-7")
+
+	// action.
+	require.NoError(t, tenant42.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+	require.NoError(t, tenant42.Put(ctx, mustParse("/def"), mustParse("/hey/der")))
+	require.NoError(t, tenant7.Put(ctx, mustParse("/abc"), mustParse("/hey/der")))
+
+	// assert.
+	got, ok, err := tenant42.Get(ctx, mustParse("/abc"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/this/is/the/way", got.String())
+	got, ok, err = tenant7.Get(ctx, mustParse("/abc"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/hey/der", got.String())
+	_, ok, err = tenant7.Get(ctx, mustParse("/def"))
+	require.NoError(t, err)
+	assert.False(t, ok, "expected the mappings of another namespace to be unresolvable")
+	_, ok, err = obscurer.DefaultStore.Get(ctx, mustParse("/tenant-42/abc"))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 2, tenant42.Size(ctx))
+	assert.Equal(t, 1, tenant7.Size(ctx))
+}
+
+// TestWithNamespace_Clear tests that clearing a namespace leaves the
+// mappings of other namespaces intact.
+func TestWithNamespace_Clear(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	t.Cleanup(func() {
+		obscurer.DefaultStore.Clear(ctx)
+	})
+	tenant42 := store.WithNamespace(obscurer.DefaultStore, "tenant-42")
+	tenant4 := store.WithNamespace(obscurer.DefaultStore, "tenant-4")
+	require.NoError(t, obscurer.LoadAll(ctx, tenant42, map[*url.URL]*url.URL{
+		mustParse("/a"): mustParse("/this/is/the/way"),
+		mustParse("/b"): mustParse("/hey/der"),
+	}))
+	require.NoError(t, tenant4.Put(ctx, mustParse("/a"), mustParse("/this/is/the/way")))
+
+	// action.
+	err := tenant4.Clear(ctx)
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 0, tenant4.Size(ctx))
+	assert.Equal(t, 2, tenant42.Size(ctx))
+	visited := make(map[string]string)
+	require.NoError(t, tenant42.(obscurer.RangeStore).Range(ctx, func(obscured, original *url.URL) bool {
+		visited[obscured.Path] = original.String()
+		return true
+	}))
+	assert.Equal(t, map[string]string{"/a": "/this/is/the/way", "/b": "/hey/der"}, visited)
+}
+
+// TestWithNamespace_Escaped tests that namespaces are escaped within the
+// paths of the obscured URLs the mappings are kept under.
+func TestWithNamespace_Escaped(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	t.Cleanup(func() {
+		obscurer.DefaultStore.Clear(ctx)
+	})
+	s := store.WithNamespace(obscurer.DefaultStore, "acme/eu")
+
+	// action.
+	err := s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way"))
+
+	// assert.
+	require.NoError(t, err)
+	_, ok, err := obscurer.DefaultStore.Get(ctx, &url.URL{Path: "/acme%2Feu/abc"})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	_, ok, err = store.WithNamespace(obscurer.DefaultStore, "acme").Get(ctx, mustParse("/eu/abc"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// TestWithNamespace_Unsupported tests that clearing a namespace requires
+// the underlying store to be a RangeStore.
+func TestWithNamespace_Unsupported(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	s := store.WithNamespace(mock.NewStore(ctrl), "tenant-42")
+
+	// action + assert.
+	assert.Equal(t, store.ErrUnsupported, s.Clear(ctx))
+	assert.Equal(t, 0, s.Size(ctx))
+}
+
+// TestWithContextNamespace tests that mappings are partitioned by the
+// namespace carried by the context, and that operations fail without one.
+func TestWithContextNamespace(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	t.Cleanup(func() {
+		obscurer.DefaultStore.Clear(ctx)
+	})
+	s := store.WithContextNamespace(obscurer.DefaultStore)
+	tenant42 := obscurer.ContextWithNamespace(ctx, "tenant-42")
+
+	// action.
+	require.NoError(t, s.Put(tenant42, mustParse("/abc"), mustParse("/this/is/the/way")))
+	_, ok, err := s.Get(ctx, mustParse("/abc"))
+
+	// assert.
+	assert.Equal(t, store.ErrNoNamespace, err)
+	assert.False(t, ok)
+	assert.Equal(t, store.ErrNoNamespace, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+	got, ok, err := store.WithNamespace(obscurer.DefaultStore, "tenant-42").Get(ctx, mustParse("/abc"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/this/is/the/way", got.String())
+}
+
+// TestWithContextNamespace_Handler tests that the obscured URLs placed by
+// the handler for one tenant can't be resolved by another.
+func TestWithContextNamespace_Handler(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	t.Cleanup(func() {
+		obscurer.DefaultStore.Clear(ctx)
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orders", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/orders/42")
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("/orders/42", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := obscurer.NewHandler(
+		obscurer.Default,
+		store.WithContextNamespace(obscurer.DefaultStore),
+		mux,
+		obscurer.WithNamespacer(func(r *http.Request) string {
+			return r.Header.Get("X-Tenant")
+		}),
+	)
+	serve := func(tenant, target string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, target, nil)
+		request.Header.Set("X-Tenant", tenant)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+	location := serve("tenant-42", "/orders").Header().Get("Location")
+	require.NotEqual(t, "/orders/42", location)
+
+	// action.
+	own := serve("tenant-42", location)
+	other := serve("tenant-7", location)
+
+	// assert.
+	assert.Equal(t, http.StatusOK, own.Code)
+	assert.Equal(t, http.StatusNotFound, other.Code)
+}
@@ -0,0 +1,235 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/freerware/obscurer"
+)
+
+// DefaultRelayInterval represents the default amount of time between relays
+// of the pending mappings of the outbox.
+const DefaultRelayInterval = time.Second
+
+// OutboxOptions represents the configuration options for the outbox.
+type OutboxOptions struct {
+	// Interval is the amount of time between relays of pending mappings to
+	// the remote store. Zero disables periodic relays, leaving them to
+	// Relay and Close.
+	Interval time.Duration
+	// ErrorHandler is invoked when a pending mapping fails to be relayed.
+	// When nil, such failures are ignored, and retried at the next
+	// interval.
+	ErrorHandler func(error)
+}
+
+// OutboxOption applies an option to the provided configuration.
+type OutboxOption func(*OutboxOptions)
+
+// WithRelayInterval configures the amount of time between relays.
+func WithRelayInterval(interval time.Duration) OutboxOption {
+	return func(o *OutboxOptions) {
+		o.Interval = interval
+	}
+}
+
+// WithRelayErrorHandler configures the function invoked when a pending
+// mapping fails to be relayed.
+func WithRelayErrorHandler(fn func(error)) OutboxOption {
+	return func(o *OutboxOptions) {
+		o.ErrorHandler = fn
+	}
+}
+
+// Outbox places mappings into a durable local store, the outbox, and relays
+// them to a remote store in the background, so that placing a mapping
+// doesn't wait on the remote store, and a crash before the mapping reaches
+// the remote store doesn't lose it.
+type Outbox struct {
+	remote  obscurer.Store
+	outbox  obscurer.Store
+	pending obscurer.RangeStore
+	options OutboxOptions
+
+	// relayMutex serializes relays.
+	relayMutex sync.Mutex
+	closeMutex sync.Mutex
+	closed     bool
+	done       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewOutbox constructs a store placing mappings into the provided outbox,
+// which must be a durable obscurer.RangeStore such as a bbolt or file
+// store, and relaying them to the provided remote store at every interval.
+// Mappings left pending by a previous process are relayed once the first
+// interval elapses. Lookups consult the outbox before the remote store, so
+// pending mappings resolve immediately within the process. Collisions with
+// the mappings of the remote store are only detected once relayed, upon
+// which the pending mapping is discarded and the *obscurer.CollisionError
+// provided to the error handler.
+func NewOutbox(remote, outbox obscurer.Store, opts ...OutboxOption) (*Outbox, error) {
+	pending, ok := outbox.(obscurer.RangeStore)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	options := OutboxOptions{Interval: DefaultRelayInterval}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	o := &Outbox{
+		remote:  remote,
+		outbox:  outbox,
+		pending: pending,
+		options: options,
+		done:    make(chan struct{}),
+	}
+	if options.Interval > 0 {
+		o.wg.Add(1)
+		go o.run()
+	}
+	return o, nil
+}
+
+// run relays the pending mappings at every interval until the outbox is
+// closed.
+func (o *Outbox) run() {
+	defer o.wg.Done()
+	ticker := time.NewTicker(o.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-o.done:
+			return
+		case <-ticker.C:
+			o.Relay(context.Background())
+		}
+	}
+}
+
+// Relay places the pending mappings into the remote store, removing them
+// from the outbox once placed. Mappings failing to be placed are left
+// pending, except for those colliding with the mappings of the remote
+// store, which are discarded. The first failure is returned once every
+// pending mapping has been attempted.
+func (o *Outbox) Relay(ctx context.Context) error {
+	o.relayMutex.Lock()
+	defer o.relayMutex.Unlock()
+	mappings := make(map[*url.URL]*url.URL)
+	if err := o.pending.Range(ctx, func(obscured, original *url.URL) bool {
+		mappings[obscured] = original
+		return true
+	}); err != nil {
+		return o.report(err)
+	}
+	var first error
+	for obscured, original := range mappings {
+		err := o.remote.Put(ctx, obscured, original)
+		if err == nil || errors.Is(err, obscurer.ErrCollision) {
+			if removeErr := o.outbox.Remove(ctx, obscured); removeErr != nil && err == nil {
+				err = removeErr
+			}
+		}
+		if err != nil && first == nil {
+			first = err
+		}
+		o.report(err)
+	}
+	return first
+}
+
+// report provides the provided error, if any, to the error handler.
+func (o *Outbox) report(err error) error {
+	if err != nil && o.options.ErrorHandler != nil {
+		o.options.ErrorHandler(err)
+	}
+	return err
+}
+
+// Close stops the periodic relays and relays the pending mappings a final
+// time. Mappings failing to be relayed remain in the outbox, and are
+// relayed by the next outbox constructed upon it.
+func (o *Outbox) Close() error {
+	o.closeMutex.Lock()
+	if o.closed {
+		o.closeMutex.Unlock()
+		return nil
+	}
+	o.closed = true
+	close(o.done)
+	o.closeMutex.Unlock()
+	o.wg.Wait()
+	return o.Relay(context.Background())
+}
+
+// Put places the mapping into the outbox, to be relayed to the remote
+// store. A *obscurer.CollisionError is returned when the obscured URL is
+// already mapped to a URL with a different path within the outbox.
+func (o *Outbox) Put(ctx context.Context, obscured, original *url.URL) error {
+	return o.outbox.Put(ctx, obscured, original)
+}
+
+// Get retrieves the original form of the provided obscured URL from the
+// outbox, or from the remote store when it isn't pending.
+func (o *Outbox) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	original, ok, err := o.outbox.Get(ctx, obscured)
+	if err != nil || ok {
+		return original, ok, err
+	}
+	return o.remote.Get(ctx, obscured)
+}
+
+// Remove deletes the entry from the outbox and the remote store.
+func (o *Outbox) Remove(ctx context.Context, obscured *url.URL) error {
+	if err := o.outbox.Remove(ctx, obscured); err != nil {
+		return err
+	}
+	return o.remote.Remove(ctx, obscured)
+}
+
+// Clear removes all entries from the outbox and the remote store.
+func (o *Outbox) Clear(ctx context.Context) error {
+	if err := o.outbox.Clear(ctx); err != nil {
+		return err
+	}
+	return o.remote.Clear(ctx)
+}
+
+// Size computes the size of the remote store along with the number of
+// pending mappings.
+func (o *Outbox) Size(ctx context.Context) int {
+	return o.remote.Size(ctx) + o.outbox.Size(ctx)
+}
+
+// Load loads the remote store directly, bypassing the outbox.
+func (o *Outbox) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	return o.remote.Load(ctx, mappings)
+}
+
+// Ping verifies the backends of the outbox and of the remote store are
+// reachable, as lookups of mappings already relayed are served by the
+// remote store.
+func (o *Outbox) Ping(ctx context.Context) error {
+	if err := obscurer.Ping(ctx, o.outbox); err != nil {
+		return err
+	}
+	return obscurer.Ping(ctx, o.remote)
+}
@@ -0,0 +1,221 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/filestore"
+	"github.com/freerware/obscurer/internal/mock"
+	"github.com/freerware/obscurer/store"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// outboxPath provides a temporary path for the file store serving as an
+// outbox.
+func outboxPath(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "outbox")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	return filepath.Join(dir, "outbox.json")
+}
+
+// openOutbox opens the file store at the provided path to serve as an
+// outbox.
+func openOutbox(t *testing.T, path string) *filestore.Store {
+	f, err := filestore.Open(path, filestore.WithInterval(0))
+	require.NoError(t, err)
+	return f
+}
+
+// errorRecorder records the errors provided to it.
+type errorRecorder struct {
+	mutex  sync.Mutex
+	errors []error
+}
+
+func (r *errorRecorder) record(err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.errors = append(r.errors, err)
+}
+
+// TestOutbox tests that mappings are placed into the outbox, resolve while
+// pending, and are moved to the remote store once relayed.
+func TestOutbox(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	t.Cleanup(func() {
+		obscurer.DefaultStore.Clear(ctx)
+	})
+	outbox := openOutbox(t, outboxPath(t))
+	s, err := store.NewOutbox(obscurer.DefaultStore, outbox, store.WithRelayInterval(0))
+	require.NoError(t, err)
+	obscured, original := mustParse("/abc"), mustParse("/this/is/the/way")
+	require.NoError(t, s.Put(ctx, obscured, original))
+	_, ok, err := obscurer.DefaultStore.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.False(t, ok, "expected the mapping to be pending")
+	got, ok, err := s.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.True(t, ok, "expected the pending mapping to resolve")
+	assert.Equal(t, original.String(), got.String())
+
+	// action.
+	err = s.Relay(ctx)
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 0, outbox.Size(ctx))
+	got, ok, err = obscurer.DefaultStore.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, original.String(), got.String())
+	assert.Equal(t, 1, s.Size(ctx))
+}
+
+// TestOutbox_Relay_Failure tests that mappings failing to be relayed are
+// left pending, and reported to the error handler.
+func TestOutbox_Relay_Failure(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	remote := mock.NewStore(ctrl)
+	expectedErr := errors.New("whoa")
+	gomock.InOrder(
+		remote.EXPECT().Put(ctx, gomock.Any(), gomock.Any()).Return(expectedErr),
+		remote.EXPECT().Put(ctx, gomock.Any(), gomock.Any()).Return(nil),
+	)
+	outbox := openOutbox(t, outboxPath(t))
+	errs := &errorRecorder{}
+	s, err := store.NewOutbox(remote, outbox, store.WithRelayInterval(0), store.WithRelayErrorHandler(errs.record))
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+
+	// action.
+	failed := s.Relay(ctx)
+	pending := outbox.Size(ctx)
+	relayed := s.Relay(ctx)
+
+	// assert.
+	assert.Equal(t, expectedErr, failed)
+	assert.Equal(t, 1, pending)
+	assert.NoError(t, relayed)
+	assert.Equal(t, 0, outbox.Size(ctx))
+	assert.Equal(t, []error{expectedErr}, errs.errors)
+}
+
+// TestOutbox_Relay_Collision tests that pending mappings colliding with the
+// mappings of the remote store are discarded and reported.
+func TestOutbox_Relay_Collision(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	t.Cleanup(func() {
+		obscurer.DefaultStore.Clear(ctx)
+	})
+	require.NoError(t, obscurer.DefaultStore.Put(ctx, mustParse("/abc"), mustParse("/hey/der")))
+	outbox := openOutbox(t, outboxPath(t))
+	errs := &errorRecorder{}
+	s, err := store.NewOutbox(obscurer.DefaultStore, outbox, store.WithRelayInterval(0), store.WithRelayErrorHandler(errs.record))
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+
+	// action.
+	err = s.Relay(ctx)
+
+	// assert.
+	assert.True(t, errors.Is(err, obscurer.ErrCollision))
+	assert.Equal(t, 0, outbox.Size(ctx))
+	require.Len(t, errs.errors, 1)
+	assert.True(t, errors.Is(errs.errors[0], obscurer.ErrCollision))
+}
+
+// TestOutbox_Crash tests that mappings left pending by a process that
+// crashed are relayed by the next outbox constructed upon the same outbox.
+func TestOutbox_Crash(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	t.Cleanup(func() {
+		obscurer.DefaultStore.Clear(ctx)
+	})
+	path := outboxPath(t)
+	outbox := openOutbox(t, path)
+	crashed, err := store.NewOutbox(obscurer.DefaultStore, outbox, store.WithRelayInterval(0))
+	require.NoError(t, err)
+	require.NoError(t, crashed.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+	require.NoError(t, outbox.Snapshot())
+
+	// action.
+	s, err := store.NewOutbox(obscurer.DefaultStore, openOutbox(t, path), store.WithRelayInterval(time.Millisecond))
+	require.NoError(t, err)
+	defer s.Close()
+
+	// assert.
+	assert.Eventually(t, func() bool {
+		_, ok, err := obscurer.DefaultStore.Get(ctx, mustParse("/abc"))
+		return err == nil && ok
+	}, time.Second, time.Millisecond)
+}
+
+// TestOutbox_Close tests that the pending mappings are relayed upon closing
+// the outbox.
+func TestOutbox_Close(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	t.Cleanup(func() {
+		obscurer.DefaultStore.Clear(ctx)
+	})
+	s, err := store.NewOutbox(obscurer.DefaultStore, openOutbox(t, outboxPath(t)), store.WithRelayInterval(time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+
+	// action.
+	err = s.Close()
+
+	// assert.
+	require.NoError(t, err)
+	assert.NoError(t, s.Close())
+	_, ok, err := obscurer.DefaultStore.Get(ctx, mustParse("/abc"))
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+// TestNewOutbox_Unsupported tests that outboxes must be RangeStores.
+func TestNewOutbox_Unsupported(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// action.
+	s, err := store.NewOutbox(mock.NewStore(ctrl), mock.NewStore(ctrl))
+
+	// assert.
+	assert.Equal(t, store.ErrUnsupported, err)
+	assert.Nil(t, s)
+}
@@ -0,0 +1,364 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/freerware/obscurer"
+)
+
+// ErrQuotaExceeded represents an error that occurs when placing a mapping
+// would exceed the hard quota of its namespace.
+var ErrQuotaExceeded = errors.New("store: quota exceeded")
+
+// QuotaLevel describes the quota a namespace has reached.
+type QuotaLevel int
+
+const (
+	// QuotaSoft indicates the namespace has reached its soft quota.
+	QuotaSoft QuotaLevel = iota + 1
+	// QuotaHard indicates the namespace has reached its hard quota.
+	QuotaHard
+)
+
+// String provides the name of the level.
+func (l QuotaLevel) String() string {
+	switch l {
+	case QuotaSoft:
+		return "soft"
+	case QuotaHard:
+		return "hard"
+	default:
+		return fmt.Sprintf("QuotaLevel(%d)", int(l))
+	}
+}
+
+// QuotaAlert describes a namespace reaching one of its quotas.
+type QuotaAlert struct {
+	// Namespace is the namespace that reached the quota, which is empty for
+	// operations whose context carries no namespace.
+	Namespace string
+	// Level is the quota reached.
+	Level QuotaLevel
+	// Count is the number of mappings of the namespace.
+	Count int
+	// Bytes is the size of the mappings of the namespace, in bytes.
+	Bytes int64
+}
+
+// Quota represents a limit on the mappings of a namespace. Zero values are
+// unlimited.
+type Quota = obscurer.Quota
+
+// reached indicates whether the provided usage reaches the provided quota.
+func reached(q Quota, count int, bytes int64) bool {
+	return q.Count > 0 && count >= q.Count || q.Bytes > 0 && bytes >= q.Bytes
+}
+
+// exceeded indicates whether the provided usage exceeds the provided quota.
+func exceeded(q Quota, count int, bytes int64) bool {
+	return q.Count > 0 && count > q.Count || q.Bytes > 0 && bytes > q.Bytes
+}
+
+// QuotaOptions represents the configuration options for the quota store.
+type QuotaOptions struct {
+	// Soft is the quota at which an alert is raised.
+	Soft Quota
+	// Hard is the quota mappings are never placed beyond.
+	Hard Quota
+	// Evict indicates whether the oldest mappings of a namespace are
+	// removed to make room for new mappings at the hard quota, rather than
+	// new mappings being rejected with ErrQuotaExceeded.
+	Evict bool
+	// Alert is invoked when a namespace reaches a quota it was beneath,
+	// such as to record a metric or notify a webhook. When nil, quotas are
+	// reached silently.
+	Alert func(QuotaAlert)
+	// Policies provides the policies of namespaces, whose quotas override
+	// the Soft and Hard quotas for the namespace.
+	Policies obscurer.Policies
+}
+
+// QuotaOption applies an option to the provided configuration.
+type QuotaOption func(*QuotaOptions)
+
+// WithSoftQuota configures the quota at which an alert is raised.
+func WithSoftQuota(count int, bytes int64) QuotaOption {
+	return func(o *QuotaOptions) {
+		o.Soft = Quota{Count: count, Bytes: bytes}
+	}
+}
+
+// WithHardQuota configures the quota mappings are never placed beyond.
+func WithHardQuota(count int, bytes int64) QuotaOption {
+	return func(o *QuotaOptions) {
+		o.Hard = Quota{Count: count, Bytes: bytes}
+	}
+}
+
+// WithEviction configures the oldest mappings of a namespace to be removed
+// to make room for new mappings at the hard quota.
+func WithEviction() QuotaOption {
+	return func(o *QuotaOptions) {
+		o.Evict = true
+	}
+}
+
+// WithQuotaAlert configures the function invoked when a namespace reaches a
+// quota.
+func WithQuotaAlert(fn func(QuotaAlert)) QuotaOption {
+	return func(o *QuotaOptions) {
+		o.Alert = fn
+	}
+}
+
+// WithQuotaPolicies configures the policies of namespaces, whose quotas
+// override the configured quotas for the namespace.
+func WithQuotaPolicies(p obscurer.Policies) QuotaOption {
+	return func(o *QuotaOptions) {
+		o.Policies = p
+	}
+}
+
+// quotaMapping represents a mapping counted against the quota of its
+// namespace.
+type quotaMapping struct {
+	obscured string
+	bytes    int64
+}
+
+// usage tracks the mappings of a namespace, in the order they were placed.
+type usage struct {
+	mappings map[string]*list.Element
+	order    *list.List
+	bytes    int64
+	level    QuotaLevel
+}
+
+// quota limits the mappings of each namespace of the underlying store.
+type quota struct {
+	store   obscurer.Store
+	options QuotaOptions
+
+	mutex  sync.Mutex
+	usages map[string]*usage
+}
+
+// NewQuota constructs a store limiting the mappings placed into the
+// provided store within each namespace, as carried by the context of each
+// operation, to the configured quotas. Namespaces are otherwise left as is,
+// so the provided store is typically partitioned with WithContextNamespace.
+// Only the mappings placed through the constructed store are counted,
+// which it keeps track of in memory.
+func NewQuota(s obscurer.Store, opts ...QuotaOption) obscurer.Store {
+	var options QuotaOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &quota{store: s, options: options, usages: make(map[string]*usage)}
+}
+
+// usage provides the usage of the provided namespace. The caller must hold
+// the lock of the store.
+func (s *quota) usage(namespace string) *usage {
+	u, ok := s.usages[namespace]
+	if !ok {
+		u = &usage{mappings: make(map[string]*list.Element), order: list.New()}
+		s.usages[namespace] = u
+	}
+	return u
+}
+
+// add counts the provided mapping against the provided usage. The caller
+// must hold the lock of the store.
+func (u *usage) add(m quotaMapping) {
+	u.mappings[m.obscured] = u.order.PushBack(m)
+	u.bytes += m.bytes
+}
+
+// remove stops counting the mapping for the provided obscured path against
+// the provided usage. The caller must hold the lock of the store.
+func (u *usage) remove(obscured string) {
+	e, ok := u.mappings[obscured]
+	if !ok {
+		return
+	}
+	u.order.Remove(e)
+	delete(u.mappings, obscured)
+	u.bytes -= e.Value.(quotaMapping).bytes
+}
+
+// alert raises an alert when the provided usage reached a quota it was
+// beneath. The caller must hold the lock of the store, and invoke the
+// returned function once released.
+func (s *quota) alert(namespace string, u *usage, soft, hard Quota) func() {
+	count := len(u.mappings)
+	var level QuotaLevel
+	switch {
+	case reached(hard, count, u.bytes):
+		level = QuotaHard
+	case reached(soft, count, u.bytes):
+		level = QuotaSoft
+	}
+	raised := level > u.level
+	u.level = level
+	if !raised || s.options.Alert == nil {
+		return func() {}
+	}
+	a := QuotaAlert{Namespace: namespace, Level: level, Count: count, Bytes: u.bytes}
+	return func() {
+		s.options.Alert(a)
+	}
+}
+
+// quotas provides the soft and hard quotas of the provided namespace.
+func (s *quota) quotas(ctx context.Context, namespace string) (soft, hard Quota, err error) {
+	soft, hard = s.options.Soft, s.options.Hard
+	if s.options.Policies == nil {
+		return
+	}
+	p, ok, err := s.options.Policies.Policy(ctx, namespace)
+	if err != nil || !ok {
+		return
+	}
+	if p.SoftQuota != (Quota{}) {
+		soft = p.SoftQuota
+	}
+	if p.HardQuota != (Quota{}) {
+		hard = p.HardQuota
+	}
+	return
+}
+
+// Put places the mapping between the provided obscured URL and it's original
+// form into the underlying store, unless it would exceed the hard quota of
+// the namespace. At the hard quota, the oldest mappings of the namespace
+// are removed to make room when eviction is configured, and
+// ErrQuotaExceeded is returned otherwise.
+func (s *quota) Put(ctx context.Context, obscured, original *url.URL) error {
+	namespace, _ := obscurer.NamespaceFromContext(ctx)
+	soft, hard, err := s.quotas(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	m := quotaMapping{
+		obscured: obscured.Path,
+		bytes:    int64(len(obscured.Path) + len(original.String())),
+	}
+	s.mutex.Lock()
+	u := s.usage(namespace)
+	if _, ok := u.mappings[m.obscured]; ok {
+		s.mutex.Unlock()
+		return s.store.Put(ctx, obscured, original)
+	}
+	var evicted []string
+	for exceeded(hard, len(u.mappings)+1, u.bytes+m.bytes) {
+		oldest := u.order.Front()
+		if !s.options.Evict || oldest == nil {
+			s.mutex.Unlock()
+			return fmt.Errorf("%w: namespace %q", ErrQuotaExceeded, namespace)
+		}
+		obscured := oldest.Value.(quotaMapping).obscured
+		u.remove(obscured)
+		evicted = append(evicted, obscured)
+	}
+	// the mapping is counted before being placed, so that concurrent
+	// placements can't exceed the quota together.
+	u.add(m)
+	alert := s.alert(namespace, u, soft, hard)
+	s.mutex.Unlock()
+	for _, path := range evicted {
+		if err := s.store.Remove(ctx, &url.URL{Path: path}); err != nil {
+			return err
+		}
+	}
+	if err := s.store.Put(ctx, obscured, original); err != nil {
+		s.mutex.Lock()
+		u.remove(m.obscured)
+		s.alert(namespace, u, soft, hard)
+		s.mutex.Unlock()
+		return err
+	}
+	alert()
+	return nil
+}
+
+// Get retrieves the original form of the provided obscured URL from the
+// underlying store.
+func (s *quota) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	return s.store.Get(ctx, obscured)
+}
+
+// Remove removes the mapping for the provided obscured URL from the
+// underlying store, no longer counting it against its namespace.
+func (s *quota) Remove(ctx context.Context, obscured *url.URL) error {
+	if err := s.store.Remove(ctx, obscured); err != nil {
+		return err
+	}
+	namespace, _ := obscurer.NamespaceFromContext(ctx)
+	soft, hard, err := s.quotas(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	u := s.usage(namespace)
+	u.remove(obscured.Path)
+	s.alert(namespace, u, soft, hard)
+	s.mutex.Unlock()
+	return nil
+}
+
+// Clear clears the underlying store, no longer counting the mappings it
+// held. When the underlying store is partitioned by the namespace carried
+// by the context, as with WithContextNamespace, only the mappings of that
+// namespace are cleared, so only they are no longer counted. Otherwise, no
+// mapping of any namespace is counted anymore.
+func (s *quota) Clear(ctx context.Context) error {
+	if err := s.store.Clear(ctx); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if n, ok := s.store.(*namespaced); ok && n.contextual {
+		namespace, _ := obscurer.NamespaceFromContext(ctx)
+		delete(s.usages, namespace)
+		return nil
+	}
+	s.usages = make(map[string]*usage)
+	return nil
+}
+
+// Size computes the size of the underlying store.
+func (s *quota) Size(ctx context.Context) int {
+	return s.store.Size(ctx)
+}
+
+// Load loads the provided mappings into the underlying store, bypassing the
+// quotas.
+func (s *quota) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	return s.store.Load(ctx, mappings)
+}
+
+// Ping verifies the backend of the underlying store is reachable.
+func (s *quota) Ping(ctx context.Context) error {
+	return obscurer.Ping(ctx, s.store)
+}
@@ -0,0 +1,207 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestQuota_Put tests that mappings beyond the hard quota of a namespace are
+// rejected, without affecting other namespaces.
+func TestQuota_Put(t *testing.T) {
+	// arrange.
+	acme := obscurer.ContextWithNamespace(context.Background(), "acme")
+	globex := obscurer.ContextWithNamespace(context.Background(), "globex")
+	t.Cleanup(func() {
+		obscurer.DefaultStore.Clear(context.Background())
+	})
+	s := store.NewQuota(store.WithContextNamespace(obscurer.DefaultStore), store.WithHardQuota(2, 0))
+	require.NoError(t, s.Put(acme, mustParse("/a"), mustParse("/this/is/the/way")))
+	require.NoError(t, s.Put(acme, mustParse("/b"), mustParse("/hey/der")))
+
+	// action.
+	err := s.Put(acme, mustParse("/c"), mustParse("/whoa"))
+
+	// assert.
+	assert.True(t, errors.Is(err, store.ErrQuotaExceeded))
+	assert.NoError(t, s.Put(acme, mustParse("/a"), mustParse("/this/is/the/way")))
+	assert.NoError(t, s.Put(globex, mustParse("/c"), mustParse("/whoa")))
+	require.NoError(t, s.Remove(acme, mustParse("/a")))
+	assert.NoError(t, s.Put(acme, mustParse("/c"), mustParse("/whoa")))
+}
+
+// TestQuota_Put_Evict tests that the oldest mappings of a namespace are
+// removed to make room at the hard quota when eviction is configured.
+func TestQuota_Put_Evict(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	t.Cleanup(func() {
+		obscurer.DefaultStore.Clear(ctx)
+	})
+	s := store.NewQuota(obscurer.DefaultStore, store.WithHardQuota(2, 0), store.WithEviction())
+	require.NoError(t, s.Put(ctx, mustParse("/a"), mustParse("/this/is/the/way")))
+	require.NoError(t, s.Put(ctx, mustParse("/b"), mustParse("/hey/der")))
+
+	// action.
+	err := s.Put(ctx, mustParse("/c"), mustParse("/whoa"))
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.Size(ctx))
+	_, ok, err := s.Get(ctx, mustParse("/a"))
+	require.NoError(t, err)
+	assert.False(t, ok, "expected the oldest mapping to be evicted")
+}
+
+// TestQuota_Put_Bytes tests that mappings beyond the size quota of a
+// namespace are rejected.
+func TestQuota_Put_Bytes(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	t.Cleanup(func() {
+		obscurer.DefaultStore.Clear(ctx)
+	})
+	s := store.NewQuota(obscurer.DefaultStore, store.WithHardQuota(0, 20))
+
+	// action.
+	err := s.Put(ctx, mustParse("/a"), mustParse("/this/is/the/way"))
+	exceeded := s.Put(ctx, mustParse("/b"), mustParse("/hey/der"))
+
+	// assert.
+	require.NoError(t, err)
+	assert.True(t, errors.Is(exceeded, store.ErrQuotaExceeded))
+}
+
+// TestQuota_Alert tests that alerts are raised once as a namespace reaches
+// each quota, and again once it drops beneath and reaches it anew.
+func TestQuota_Alert(t *testing.T) {
+	// arrange.
+	ctx := obscurer.ContextWithNamespace(context.Background(), "acme")
+	t.Cleanup(func() {
+		obscurer.DefaultStore.Clear(context.Background())
+	})
+	var alerts []store.QuotaAlert
+	s := store.NewQuota(
+		store.WithContextNamespace(obscurer.DefaultStore),
+		store.WithSoftQuota(1, 0),
+		store.WithHardQuota(2, 0),
+		store.WithQuotaAlert(func(a store.QuotaAlert) {
+			alerts = append(alerts, a)
+		}))
+
+	// action.
+	require.NoError(t, s.Put(ctx, mustParse("/a"), mustParse("/a")))
+	require.NoError(t, s.Put(ctx, mustParse("/a"), mustParse("/a")))
+	require.NoError(t, s.Put(ctx, mustParse("/b"), mustParse("/b")))
+	require.Error(t, s.Put(ctx, mustParse("/c"), mustParse("/c")))
+	require.NoError(t, s.Remove(ctx, mustParse("/b")))
+	require.NoError(t, s.Put(ctx, mustParse("/c"), mustParse("/c")))
+
+	// assert.
+	assert.Equal(t, []store.QuotaAlert{
+		{Namespace: "acme", Level: store.QuotaSoft, Count: 1, Bytes: 4},
+		{Namespace: "acme", Level: store.QuotaHard, Count: 2, Bytes: 8},
+		{Namespace: "acme", Level: store.QuotaHard, Count: 2, Bytes: 8},
+	}, alerts)
+}
+
+// TestQuota_Policies tests that the quotas of the policy of a namespace
+// override the configured quotas.
+func TestQuota_Policies(t *testing.T) {
+	// arrange.
+	acme := obscurer.ContextWithNamespace(context.Background(), "acme")
+	globex := obscurer.ContextWithNamespace(context.Background(), "globex")
+	t.Cleanup(func() {
+		obscurer.DefaultStore.Clear(context.Background())
+	})
+	s := store.NewQuota(
+		store.WithContextNamespace(obscurer.DefaultStore),
+		store.WithHardQuota(1, 0),
+		store.WithQuotaPolicies(policyTable{"acme": {HardQuota: store.Quota{Count: 2}}}))
+
+	// action.
+	errs := []error{
+		s.Put(acme, mustParse("/a"), mustParse("/a")),
+		s.Put(acme, mustParse("/b"), mustParse("/b")),
+		s.Put(globex, mustParse("/a"), mustParse("/a")),
+		s.Put(globex, mustParse("/b"), mustParse("/b")),
+	}
+
+	// assert.
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+	assert.NoError(t, errs[2])
+	assert.True(t, errors.Is(errs[3], store.ErrQuotaExceeded))
+}
+
+// policyTable provides the policies of namespaces from a map.
+type policyTable map[string]obscurer.Policy
+
+func (t policyTable) Policy(ctx context.Context, namespace string) (obscurer.Policy, bool, error) {
+	p, ok := t[namespace]
+	return p, ok, nil
+}
+
+// TestQuota_Clear tests that clearing the underlying store stops counting
+// the mappings of every namespace it held, and only those.
+func TestQuota_Clear(t *testing.T) {
+	tests := []struct {
+		name       string
+		namespaced bool
+		// remaining is the number of mappings globex can still place.
+		remaining int
+	}{
+		{name: "Shared", remaining: 2},
+		{name: "Namespaced", namespaced: true, remaining: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			acme := obscurer.ContextWithNamespace(context.Background(), "acme")
+			globex := obscurer.ContextWithNamespace(context.Background(), "globex")
+			t.Cleanup(func() {
+				obscurer.DefaultStore.Clear(context.Background())
+			})
+			var underlying obscurer.Store = obscurer.DefaultStore
+			if test.namespaced {
+				underlying = store.WithContextNamespace(underlying)
+			}
+			s := store.NewQuota(underlying, store.WithHardQuota(2, 0))
+			require.NoError(t, s.Put(acme, mustParse("/a"), mustParse("/this/is/the/way")))
+			require.NoError(t, s.Put(globex, mustParse("/b"), mustParse("/hey/der")))
+
+			// action.
+			err := s.Clear(acme)
+
+			// assert.
+			require.NoError(t, err)
+			for i := 0; i < test.remaining; i++ {
+				assert.NoError(t, s.Put(globex, mustParse(fmt.Sprintf("/c%d", i)), mustParse("/whoa")))
+			}
+			assert.True(t, errors.Is(s.Put(globex, mustParse("/d"), mustParse("/whoa")), store.ErrQuotaExceeded))
+			assert.NoError(t, s.Put(acme, mustParse("/e"), mustParse("/whoa")))
+			assert.NoError(t, s.Put(acme, mustParse("/f"), mustParse("/whoa")))
+		})
+	}
+}
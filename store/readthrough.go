@@ -0,0 +1,63 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/freerware/obscurer"
+)
+
+// readThrough reconstructs the mappings missing from the underlying store.
+type readThrough struct {
+	obscurer.Store
+	resolver obscurer.Resolver
+}
+
+// NewReadThrough constructs a store that reconstructs the original form of
+// obscured URLs missing from the provided store with the provided resolver,
+// such as an obscurer.RouteObscurer or the resolver constructed by
+// obscurer.NewRouteTableResolver, and backfills the provided store with
+// them. Lookups failing with an error are also resolved with the resolver,
+// without backfilling, so that deterministic mappings resolve while the
+// provided store is unavailable. Failures to backfill are ignored, as the
+// mapping is reconstructed again upon the next lookup.
+func NewReadThrough(base obscurer.Store, resolver obscurer.Resolver) obscurer.Store {
+	return &readThrough{Store: base, resolver: resolver}
+}
+
+// Get retrieves the original form of the provided obscured URL from the
+// underlying store, or reconstructs it with the resolver when missing.
+func (s *readThrough) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	original, ok, err := s.Store.Get(ctx, obscured)
+	if ok {
+		return original, ok, err
+	}
+	resolved, resolvable := s.resolver.Resolve(obscured)
+	if !resolvable {
+		return nil, false, err
+	}
+	if err == nil {
+		s.Store.Put(ctx, obscured, resolved)
+	}
+	return resolved, true, nil
+}
+
+// Ping verifies the backend of the underlying store is reachable.
+func (s *readThrough) Ping(ctx context.Context) error {
+	return obscurer.Ping(ctx, s.Store)
+}
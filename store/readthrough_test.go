@@ -0,0 +1,103 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/internal/mock"
+	"github.com/freerware/obscurer/store"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestReadThrough_Get tests that mappings missing from the store are
+// reconstructed with the resolver, and backfilled.
+func TestReadThrough_Get(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	underlying := obscurer.DefaultStore
+	t.Cleanup(func() {
+		underlying.Clear(ctx)
+	})
+	r, err := obscurer.NewRouteObscurer(obscurer.Default, "/users/{id}")
+	require.NoError(t, err)
+	obscured := r.Obscure(mustParse("/users/42"))
+	s := store.NewReadThrough(underlying, r)
+
+	// action.
+	got, ok, err := s.Get(ctx, obscured)
+
+	// assert.
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/users/42", got.String())
+	backfilled, ok, err := underlying.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/users/42", backfilled.String())
+}
+
+// TestReadThrough_Get_Miss tests that mappings neither in the store nor
+// resolvable remain missing.
+func TestReadThrough_Get_Miss(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	r, err := obscurer.NewRouteObscurer(obscurer.Default, "/users/{id}")
+	require.NoError(t, err)
+	s := store.NewReadThrough(obscurer.DefaultStore, r)
+
+	// action.
+	got, ok, err := s.Get(ctx, mustParse("/abc"))
+
+	// assert.
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, got)
+	assert.Equal(t, 0, obscurer.DefaultStore.Size(ctx))
+}
+
+// TestReadThrough_Get_Error tests that mappings are reconstructed without
+// being backfilled when the store fails to be read, and that the failure is
+// returned when they can't be.
+func TestReadThrough_Get_Error(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	r, err := obscurer.NewRouteObscurer(obscurer.Default, "/users/{id}")
+	require.NoError(t, err)
+	obscured := r.Obscure(mustParse("/users/42"))
+	underlying := mock.NewStore(ctrl)
+	expectedErr := errors.New("whoa")
+	underlying.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, false, expectedErr).Times(2)
+	s := store.NewReadThrough(underlying, r)
+
+	// action.
+	got, ok, err := s.Get(ctx, obscured)
+	_, missed, missErr := s.Get(ctx, mustParse("/abc"))
+
+	// assert.
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "/users/42", got.String())
+	assert.Equal(t, expectedErr, missErr)
+	assert.False(t, missed)
+}
@@ -0,0 +1,181 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/freerware/obscurer"
+)
+
+const (
+	// DefaultAttempts represents the default number of times the operations
+	// of the retrying store are attempted.
+	DefaultAttempts = 3
+	// DefaultBackoff represents the default duration waited before the
+	// first retry of the retrying store, which doubles for each retry.
+	DefaultBackoff = 50 * time.Millisecond
+)
+
+// RetryOptions represents the configuration options for the retrying store.
+type RetryOptions struct {
+	// Attempts is the maximum number of times each operation is attempted.
+	Attempts int
+	// Backoff is the duration waited before the first retry, which doubles
+	// for each subsequent retry.
+	Backoff time.Duration
+	// Retryable indicates whether operations failing with the provided
+	// error are retried. When nil, every error is retried except
+	// collisions and the errors of done contexts.
+	Retryable func(error) bool
+}
+
+// RetryOption applies an option to the provided configuration.
+type RetryOption func(*RetryOptions)
+
+// WithAttempts configures the maximum number of times each operation is
+// attempted.
+func WithAttempts(attempts int) RetryOption {
+	return func(o *RetryOptions) {
+		o.Attempts = attempts
+	}
+}
+
+// WithBackoff configures the duration waited before the first retry.
+func WithBackoff(backoff time.Duration) RetryOption {
+	return func(o *RetryOptions) {
+		o.Backoff = backoff
+	}
+}
+
+// WithRetryable configures the function indicating whether operations
+// failing with an error are retried.
+func WithRetryable(retryable func(error) bool) RetryOption {
+	return func(o *RetryOptions) {
+		o.Retryable = retryable
+	}
+}
+
+// retryable indicates whether operations failing with the provided error
+// are retried by default.
+func retryable(err error) bool {
+	return !errors.Is(err, obscurer.ErrCollision) &&
+		!errors.Is(err, context.Canceled) &&
+		!errors.Is(err, context.DeadlineExceeded)
+}
+
+// retrying retries the failed operations of the underlying store.
+type retrying struct {
+	store   obscurer.Store
+	options RetryOptions
+}
+
+// NewRetrying constructs a store retrying the failed operations of the
+// provided store with exponential backoff, such that transient failures of
+// remote stores don't fail requests. Operations are retried until they
+// succeed, fail with an error that isn't retryable, are attempted the
+// configured number of times, or their context is done.
+func NewRetrying(s obscurer.Store, opts ...RetryOption) obscurer.Store {
+	r := &retrying{store: s}
+	for _, opt := range opts {
+		opt(&r.options)
+	}
+	if r.options.Attempts <= 0 {
+		r.options.Attempts = DefaultAttempts
+	}
+	if r.options.Backoff <= 0 {
+		r.options.Backoff = DefaultBackoff
+	}
+	if r.options.Retryable == nil {
+		r.options.Retryable = retryable
+	}
+	return r
+}
+
+// Retrying provides a decorator retrying the failed operations of the
+// decorated store.
+func Retrying(opts ...RetryOption) Decorator {
+	return func(s obscurer.Store) obscurer.Store {
+		return NewRetrying(s, opts...)
+	}
+}
+
+// retry invokes the provided operation until it succeeds or is no longer
+// retried, providing the error of its last attempt.
+func (s *retrying) retry(ctx context.Context, operation func() error) error {
+	err := operation()
+	for attempt := 1; err != nil && attempt < s.options.Attempts && s.options.Retryable(err); attempt++ {
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(s.options.Backoff << uint(attempt-1)):
+		}
+		err = operation()
+	}
+	return err
+}
+
+// Put places the mapping into the underlying store.
+func (s *retrying) Put(ctx context.Context, obscured, original *url.URL) error {
+	return s.retry(ctx, func() error {
+		return s.store.Put(ctx, obscured, original)
+	})
+}
+
+// Get retrieves the original form of the provided obscured URL from the
+// underlying store.
+func (s *retrying) Get(ctx context.Context, obscured *url.URL) (original *url.URL, ok bool, err error) {
+	err = s.retry(ctx, func() (err error) {
+		original, ok, err = s.store.Get(ctx, obscured)
+		return
+	})
+	return
+}
+
+// Remove deletes the entry from the underlying store.
+func (s *retrying) Remove(ctx context.Context, obscured *url.URL) error {
+	return s.retry(ctx, func() error {
+		return s.store.Remove(ctx, obscured)
+	})
+}
+
+// Clear removes all entries from the underlying store.
+func (s *retrying) Clear(ctx context.Context) error {
+	return s.retry(ctx, func() error {
+		return s.store.Clear(ctx)
+	})
+}
+
+// Size computes the size of the underlying store.
+func (s *retrying) Size(ctx context.Context) int {
+	return s.store.Size(ctx)
+}
+
+// Load loads the underlying store.
+func (s *retrying) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	return s.retry(ctx, func() error {
+		return s.store.Load(ctx, mappings)
+	})
+}
+
+// Ping verifies the backend of the underlying store is reachable. Pings
+// aren't retried, as a probe should report the backend as it is.
+func (s *retrying) Ping(ctx context.Context) error {
+	return obscurer.Ping(ctx, s.store)
+}
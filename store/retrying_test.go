@@ -0,0 +1,115 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/internal/mock"
+	"github.com/freerware/obscurer/store"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRetrying tests that failed operations are retried until they succeed.
+func TestRetrying(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	base := mock.NewStore(ctrl)
+	original := mustParse("/this/is/the/way")
+	gomock.InOrder(
+		base.EXPECT().Get(ctx, gomock.Any()).Return(nil, false, errors.New("whoa")),
+		base.EXPECT().Get(ctx, gomock.Any()).Return(original, true, nil),
+	)
+	s := store.NewRetrying(base, store.WithBackoff(time.Millisecond))
+
+	// action.
+	got, ok, err := s.Get(ctx, mustParse("/abc"))
+
+	// assert.
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, original, got)
+}
+
+// TestRetrying_Attempts tests that operations are attempted the configured
+// number of times, and the error of the last attempt returned.
+func TestRetrying_Attempts(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	base := mock.NewStore(ctrl)
+	expectedErr := errors.New("whoa")
+	base.EXPECT().Remove(ctx, gomock.Any()).Return(expectedErr).Times(4)
+	s := store.NewRetrying(base, store.WithAttempts(4), store.WithBackoff(time.Millisecond))
+
+	// action + assert.
+	assert.Equal(t, expectedErr, s.Remove(ctx, mustParse("/abc")))
+}
+
+// TestRetrying_NotRetryable tests that collisions, and the errors deemed
+// not retryable, aren't retried.
+func TestRetrying_NotRetryable(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	base := mock.NewStore(ctrl)
+	collision := &obscurer.CollisionError{}
+	permanent := errors.New("permanent")
+	base.EXPECT().Put(ctx, gomock.Any(), gomock.Any()).Return(collision)
+	base.EXPECT().Clear(ctx).Return(permanent)
+	s := store.NewRetrying(base, store.WithBackoff(time.Millisecond))
+	custom := store.NewRetrying(base, store.WithRetryable(func(err error) bool {
+		return err != permanent
+	}))
+
+	// action.
+	putErr := s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way"))
+	clearErr := custom.Clear(ctx)
+
+	// assert.
+	assert.Equal(t, collision, putErr)
+	assert.Equal(t, permanent, clearErr)
+}
+
+// TestRetrying_ContextDone tests that operations aren't retried once their
+// context is done.
+func TestRetrying_ContextDone(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx, cancel := context.WithCancel(context.Background())
+	base := mock.NewStore(ctrl)
+	expectedErr := errors.New("whoa")
+	base.EXPECT().Load(ctx, gomock.Any()).DoAndReturn(
+		func(ctx context.Context, mappings obscurer.Mappings) error {
+			cancel()
+			return expectedErr
+		})
+	s := store.NewRetrying(base, store.WithBackoff(time.Hour))
+
+	// action + assert.
+	assert.Equal(t, expectedErr, s.Load(ctx, obscurer.Mappings{}))
+}
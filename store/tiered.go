@@ -0,0 +1,278 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"container/list"
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/freerware/obscurer"
+)
+
+// DefaultCacheSize represents the default number of mappings cached by the
+// tiered store.
+const DefaultCacheSize = 10000
+
+// TieredOptions represents the configuration options for the tiered store.
+type TieredOptions struct {
+	// CacheSize is the maximum number of mappings cached in memory, beyond
+	// which the least recently used mappings are evicted.
+	CacheSize int
+	// CacheTTL is the duration mappings are cached for, after which they
+	// are read from the remote store again. Zero caches mappings until they
+	// are evicted.
+	CacheTTL time.Duration
+	// KeyStrategy is how the keys of cached mappings are derived from
+	// their obscured URLs, which must match the key strategy of the remote
+	// store.
+	KeyStrategy obscurer.KeyStrategy
+}
+
+// TieredOption applies an option to the provided configuration.
+type TieredOption func(*TieredOptions)
+
+// WithCacheSize configures the maximum number of mappings cached in memory.
+func WithCacheSize(size int) TieredOption {
+	return func(o *TieredOptions) {
+		o.CacheSize = size
+	}
+}
+
+// WithCacheTTL configures the duration mappings are cached for.
+func WithCacheTTL(ttl time.Duration) TieredOption {
+	return func(o *TieredOptions) {
+		o.CacheTTL = ttl
+	}
+}
+
+// WithCacheKeyStrategy configures how the keys of cached mappings are
+// derived from their obscured URLs, which must match the key strategy of
+// the remote store.
+func WithCacheKeyStrategy(k obscurer.KeyStrategy) TieredOption {
+	return func(o *TieredOptions) {
+		o.KeyStrategy = k
+	}
+}
+
+// Invalidator represents a store caching mappings locally, whose cached
+// mappings can be invalidated when they change elsewhere, such as when
+// another instance removes them.
+type Invalidator interface {
+	obscurer.Store
+
+	// Invalidate removes the mapping for the provided obscured URL from the
+	// cache, leaving the underlying store as is.
+	Invalidate(obscured *url.URL)
+	// InvalidateAll removes every mapping from the cache, leaving the
+	// underlying store as is.
+	InvalidateAll()
+}
+
+// cacheEntry represents a mapping cached by the tiered store.
+type cacheEntry struct {
+	obscured  string
+	original  url.URL
+	expiresAt time.Time
+}
+
+// tiered serves reads from an in-memory cache in front of a remote store.
+type tiered struct {
+	remote  obscurer.Store
+	options TieredOptions
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	recency *list.List
+	// generation is incremented whenever mappings are invalidated, such
+	// that mappings read from the remote store before being invalidated
+	// aren't cached afterwards.
+	generation uint64
+}
+
+// NewTiered constructs a store that caches the mappings of the provided
+// remote store in memory, such that lookups of recently used mappings don't
+// incur a round trip. Lookups missing the cache fall through to the remote
+// store, and writes are made to the remote store, invalidating the cache,
+// which keeps the remote store authoritative for collisions and for the
+// mappings it keeps. Mappings removed from the remote store by other
+// processes remain cached until they expire or are evicted, so a TTL should
+// be configured when that's a concern, or the cache invalidated through the
+// Invalidator interface, such as with events.NewInvalidator.
+func NewTiered(remote obscurer.Store, opts ...TieredOption) obscurer.Store {
+	s := &tiered{
+		remote:  remote,
+		options: TieredOptions{CacheSize: DefaultCacheSize},
+		entries: make(map[string]*list.Element),
+		recency: list.New(),
+	}
+	for _, opt := range opts {
+		opt(&s.options)
+	}
+	return s
+}
+
+// key provides the key of the cached mapping for the provided obscured URL.
+func (s *tiered) key(obscured *url.URL) string {
+	return s.options.KeyStrategy.Key(obscured)
+}
+
+// cached retrieves the cached original form of the provided obscured URL,
+// along with the generation of the cache, with which the mapping read from
+// the remote store upon a miss is cached.
+func (s *tiered) cached(obscured *url.URL) (*url.URL, uint64, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	element, ok := s.entries[s.key(obscured)]
+	if !ok {
+		return nil, s.generation, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if !entry.expiresAt.IsZero() && !time.Now().Before(entry.expiresAt) {
+		s.evict(element)
+		return nil, s.generation, false
+	}
+	s.recency.MoveToFront(element)
+	original := entry.original
+	return &original, s.generation, true
+}
+
+// cache caches the mapping between the provided obscured URL and it's
+// original form, as read from the remote store at the provided generation,
+// evicting the least recently used mapping when full. The mapping isn't
+// cached when mappings were invalidated since, as it may be stale.
+func (s *tiered) cache(obscured, original *url.URL, generation uint64) {
+	if s.options.CacheSize <= 0 {
+		return
+	}
+	key := s.key(obscured)
+	entry := &cacheEntry{obscured: key, original: *original}
+	if s.options.CacheTTL > 0 {
+		entry.expiresAt = time.Now().Add(s.options.CacheTTL)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.generation != generation {
+		return
+	}
+	if element, ok := s.entries[key]; ok {
+		element.Value = entry
+		s.recency.MoveToFront(element)
+		return
+	}
+	s.entries[key] = s.recency.PushFront(entry)
+	for s.recency.Len() > s.options.CacheSize {
+		s.evict(s.recency.Back())
+	}
+}
+
+// uncache removes the mappings for the provided obscured URLs from the
+// cache, invalidating the mappings being read from the remote store.
+func (s *tiered) uncache(obscured ...*url.URL) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.generation++
+	for _, u := range obscured {
+		if element, ok := s.entries[s.key(u)]; ok {
+			s.evict(element)
+		}
+	}
+}
+
+// evict removes the provided element from the cache. The caller must hold
+// the mutex.
+func (s *tiered) evict(element *list.Element) {
+	s.recency.Remove(element)
+	delete(s.entries, element.Value.(*cacheEntry).obscured)
+}
+
+// Invalidate removes the mapping for the provided obscured URL from the
+// cache.
+func (s *tiered) Invalidate(obscured *url.URL) {
+	s.uncache(obscured)
+}
+
+// InvalidateAll removes every mapping from the cache.
+func (s *tiered) InvalidateAll() {
+	s.mutex.Lock()
+	s.generation++
+	s.entries = make(map[string]*list.Element)
+	s.recency.Init()
+	s.mutex.Unlock()
+}
+
+// Put places the mapping into the remote store, and then removes any cached
+// mapping for the obscured URL, such that the mapping the remote store
+// keeps, which may differ from the provided one, is cached once read.
+func (s *tiered) Put(ctx context.Context, obscured, original *url.URL) error {
+	err := s.remote.Put(ctx, obscured, original)
+	s.uncache(obscured)
+	return err
+}
+
+// Get retrieves the original form of the provided obscured URL from the
+// cache, falling through to the remote store when it isn't cached.
+func (s *tiered) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	original, generation, ok := s.cached(obscured)
+	if ok {
+		return original, true, nil
+	}
+	original, ok, err := s.remote.Get(ctx, obscured)
+	if ok {
+		s.cache(obscured, original, generation)
+	}
+	return original, ok, err
+}
+
+// Remove deletes the entry for the provided obscured URL from the remote
+// store, and then from the cache, such that lookups racing the removal
+// don't cache the removed mapping again.
+func (s *tiered) Remove(ctx context.Context, obscured *url.URL) error {
+	err := s.remote.Remove(ctx, obscured)
+	s.uncache(obscured)
+	return err
+}
+
+// Clear removes all entries from the remote store and the cache.
+func (s *tiered) Clear(ctx context.Context) error {
+	err := s.remote.Clear(ctx)
+	s.InvalidateAll()
+	return err
+}
+
+// Size computes the size of the remote store.
+func (s *tiered) Size(ctx context.Context) int {
+	return s.remote.Size(ctx)
+}
+
+// Load loads the remote store with the provided mappings, and then removes
+// them from the cache, as loading may replace existing mappings.
+func (s *tiered) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	err := s.remote.Load(ctx, mappings)
+	obscured := make([]*url.URL, 0, mappings.Len())
+	for u := range mappings.Pointers() {
+		obscured = append(obscured, u)
+	}
+	s.uncache(obscured...)
+	return err
+}
+
+// Ping verifies the backend of the remote store is reachable.
+func (s *tiered) Ping(ctx context.Context) error {
+	return obscurer.Ping(ctx, s.remote)
+}
@@ -0,0 +1,253 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/internal/mock"
+	"github.com/freerware/obscurer/store"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTiered_Get tests that mappings are read from the remote store once,
+// and then served from the cache.
+func TestTiered_Get(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	remote := mock.NewStore(ctrl)
+	obscured, original := mustParse("/abc"), mustParse("/this/is/the/way")
+	remote.EXPECT().Get(ctx, obscured).Return(original, true, nil).Times(1)
+	s := store.NewTiered(remote)
+
+	// action.
+	s.Get(ctx, obscured)
+	got, ok, err := s.Get(ctx, obscured)
+
+	// assert.
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, original, got)
+}
+
+// TestTiered_Get_Miss tests that missing mappings aren't cached.
+func TestTiered_Get_Miss(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	remote := mock.NewStore(ctrl)
+	obscured := mustParse("/abc")
+	remote.EXPECT().Get(ctx, obscured).Return(nil, false, nil).Times(2)
+	s := store.NewTiered(remote)
+
+	// action.
+	s.Get(ctx, obscured)
+	_, ok, err := s.Get(ctx, obscured)
+
+	// assert.
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// TestTiered_Put tests that mappings are written through to the remote
+// store, and that the mapping the remote store keeps is cached once read.
+func TestTiered_Put(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	remote := mock.NewStore(ctrl)
+	obscured, original := mustParse("/abc"), mustParse("/this/is/the/way")
+	kept := mustParse("/this/is/the/way?page=1")
+	remote.EXPECT().Get(ctx, obscured).Return(kept, true, nil).Times(1)
+	remote.EXPECT().Put(ctx, obscured, original).Return(nil)
+	s := store.NewTiered(remote)
+	s.Get(ctx, obscured)
+
+	// action.
+	err := s.Put(ctx, obscured, original)
+
+	// assert.
+	assert.NoError(t, err)
+	remote.EXPECT().Get(ctx, obscured).Return(kept, true, nil).Times(1)
+	for i := 0; i < 2; i++ {
+		got, ok, err := s.Get(ctx, obscured)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, kept.String(), got.String())
+	}
+}
+
+// TestTiered_KeyStrategy tests that mappings are cached with the key
+// strategy of the remote store, such that obscured URLs differing only by
+// their query are cached separately.
+func TestTiered_KeyStrategy(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	remote := mock.NewStore(ctrl)
+	first, second := mustParse("/abc?page=1"), mustParse("/abc?page=2")
+	remote.EXPECT().Get(ctx, first).Return(mustParse("/orders?page=1"), true, nil).Times(1)
+	remote.EXPECT().Get(ctx, second).Return(mustParse("/orders?page=2"), true, nil).Times(1)
+	s := store.NewTiered(remote, store.WithCacheKeyStrategy(obscurer.KeyRequestURI))
+	s.Get(ctx, first)
+	s.Get(ctx, second)
+
+	// action.
+	got, ok, err := s.Get(ctx, first)
+
+	// assert.
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "/orders?page=1", got.String())
+}
+
+// TestTiered_Remove_Race tests that a mapping read from the remote store
+// while it is removed isn't cached afterwards.
+func TestTiered_Remove_Race(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	remote := mock.NewStore(ctrl)
+	obscured, original := mustParse("/abc"), mustParse("/this/is/the/way")
+	s := store.NewTiered(remote)
+	remote.EXPECT().Remove(ctx, obscured).Return(nil)
+	remote.EXPECT().Get(ctx, obscured).DoAndReturn(func(ctx context.Context, u *url.URL) (*url.URL, bool, error) {
+		// the mapping is removed after having been read.
+		s.Remove(ctx, u)
+		return original, true, nil
+	})
+	s.Get(ctx, obscured)
+
+	// action.
+	remote.EXPECT().Get(ctx, obscured).Return(nil, false, nil)
+	_, ok, err := s.Get(ctx, obscured)
+
+	// assert.
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// TestTiered_Put_Error tests that mappings failing to be written to the
+// remote store aren't cached.
+func TestTiered_Put_Error(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	remote := mock.NewStore(ctrl)
+	obscured, original := mustParse("/abc"), mustParse("/this/is/the/way")
+	expectedErr := errors.New("whoa")
+	remote.EXPECT().Put(ctx, obscured, original).Return(expectedErr)
+	remote.EXPECT().Get(ctx, obscured).Return(nil, false, nil)
+	s := store.NewTiered(remote)
+
+	// action.
+	err := s.Put(ctx, obscured, original)
+
+	// assert.
+	assert.Equal(t, expectedErr, err)
+	_, ok, err := s.Get(ctx, obscured)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// TestTiered_Eviction tests that the least recently used mappings are
+// evicted once the cache is full, and that removed mappings are evicted.
+func TestTiered_Eviction(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	remote := mock.NewStore(ctrl)
+	a, b := mustParse("/a"), mustParse("/b")
+	remote.EXPECT().Put(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(2)
+	remote.EXPECT().Remove(ctx, b).Return(nil)
+	remote.EXPECT().Get(ctx, a).Return(nil, false, nil)
+	remote.EXPECT().Get(ctx, b).Return(nil, false, nil)
+	s := store.NewTiered(remote, store.WithCacheSize(1))
+	s.Put(ctx, a, mustParse("/this/is/the/way"))
+	s.Put(ctx, b, mustParse("/hey/der"))
+
+	// action.
+	s.Get(ctx, a)
+	s.Remove(ctx, b)
+	_, ok, err := s.Get(ctx, b)
+
+	// assert.
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// TestTiered_TTL tests that cached mappings are read from the remote store
+// again once expired.
+func TestTiered_TTL(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	remote := mock.NewStore(ctrl)
+	obscured, original := mustParse("/abc"), mustParse("/this/is/the/way")
+	remote.EXPECT().Get(ctx, obscured).Return(original, true, nil).Times(2)
+	s := store.NewTiered(remote, store.WithCacheTTL(10*time.Millisecond))
+	s.Get(ctx, obscured)
+	s.Get(ctx, obscured)
+
+	// action.
+	time.Sleep(20 * time.Millisecond)
+	got, ok, err := s.Get(ctx, obscured)
+
+	// assert.
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, original, got)
+}
+
+// TestTiered_Invalidate tests that invalidated mappings are read from the
+// remote store again, without being removed from it.
+func TestTiered_Invalidate(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	remote := mock.NewStore(ctrl)
+	obscured, original := mustParse("/abc"), mustParse("/this/is/the/way")
+	remote.EXPECT().Get(ctx, obscured).Return(original, true, nil).Times(3)
+	s := store.NewTiered(remote).(store.Invalidator)
+	s.Get(ctx, obscured)
+
+	// action.
+	s.Invalidate(obscured)
+	s.Get(ctx, obscured)
+	s.InvalidateAll()
+	got, ok, err := s.Get(ctx, obscured)
+
+	// assert.
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, original, got)
+}
@@ -0,0 +1,98 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/freerware/obscurer"
+)
+
+// tombstoned remembers the obscured URLs whose mappings were removed from
+// the underlying store within a store of tombstones.
+type tombstoned struct {
+	obscurer.Store
+	tombstones obscurer.Store
+}
+
+// WithTombstones constructs a store remembering the obscured URLs whose
+// mappings are removed from the provided store, keeping a tombstone mapping
+// each of them to the original it resolved to within the provided store of
+// tombstones, such that the handler responds to them with 410 Gone. Placing
+// a mapping again lifts its tombstone, and clearing the store clears the
+// tombstones as well.
+func WithTombstones(s, tombstones obscurer.Store) obscurer.TombstoneStore {
+	return &tombstoned{Store: s, tombstones: tombstones}
+}
+
+// Put places the mapping into the underlying store, lifting its tombstone.
+func (s *tombstoned) Put(ctx context.Context, obscured, original *url.URL) error {
+	if err := s.tombstones.Remove(ctx, obscured); err != nil {
+		return err
+	}
+	return s.Store.Put(ctx, obscured, original)
+}
+
+// Remove removes the mapping for the provided obscured URL from the
+// underlying store, leaving a tombstone when it existed.
+func (s *tombstoned) Remove(ctx context.Context, obscured *url.URL) error {
+	original, ok, err := s.Store.Get(ctx, obscured)
+	if err != nil {
+		return err
+	}
+	if ok {
+		if err := s.tombstones.Put(ctx, obscured, original); err != nil {
+			return err
+		}
+	}
+	return s.Store.Remove(ctx, obscured)
+}
+
+// Clear removes every mapping and tombstone.
+func (s *tombstoned) Clear(ctx context.Context) error {
+	if err := s.tombstones.Clear(ctx); err != nil {
+		return err
+	}
+	return s.Store.Clear(ctx)
+}
+
+// Load places the provided mappings into the underlying store, lifting
+// their tombstones.
+func (s *tombstoned) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	for obscured := range mappings.Pointers() {
+		if err := s.tombstones.Remove(ctx, obscured); err != nil {
+			return err
+		}
+	}
+	return s.Store.Load(ctx, mappings)
+}
+
+// Ping verifies the backends of the underlying store and of the store
+// keeping the tombstones are reachable.
+func (s *tombstoned) Ping(ctx context.Context) error {
+	if err := obscurer.Ping(ctx, s.Store); err != nil {
+		return err
+	}
+	return obscurer.Ping(ctx, s.tombstones)
+}
+
+// Buried indicates whether the mapping for the provided obscured URL was
+// removed, and wasn't placed again since.
+func (s *tombstoned) Buried(ctx context.Context, obscured *url.URL) (bool, error) {
+	_, ok, err := s.tombstones.Get(ctx, obscured)
+	return ok, err
+}
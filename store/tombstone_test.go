@@ -0,0 +1,105 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWithTombstones tests that removed mappings leave a tombstone, which is
+// lifted once the mapping is placed again.
+func TestWithTombstones(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := store.WithTombstones(obscurer.NewMemoryStore(), obscurer.NewMemoryStore())
+	obscured, original := mustParse("/abc"), mustParse("/this/is/the/way")
+	require.NoError(t, s.Put(ctx, obscured, original))
+
+	// action + assert.
+	buried, err := s.Buried(ctx, obscured)
+	require.NoError(t, err)
+	assert.False(t, buried)
+
+	require.NoError(t, s.Remove(ctx, obscured))
+	buried, err = s.Buried(ctx, obscured)
+	require.NoError(t, err)
+	assert.True(t, buried)
+	_, ok, err := s.Get(ctx, obscured)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, s.Put(ctx, obscured, original))
+	buried, err = s.Buried(ctx, obscured)
+	require.NoError(t, err)
+	assert.False(t, buried)
+}
+
+// TestWithTombstones_Unknown tests that removing an obscured URL that never
+// resolved leaves no tombstone.
+func TestWithTombstones_Unknown(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := store.WithTombstones(obscurer.NewMemoryStore(), obscurer.NewMemoryStore())
+
+	// action.
+	err := s.Remove(ctx, mustParse("/abc"))
+
+	// assert.
+	require.NoError(t, err)
+	buried, err := s.Buried(ctx, mustParse("/abc"))
+	require.NoError(t, err)
+	assert.False(t, buried)
+}
+
+// TestWithTombstones_Handler tests that the handler responds with 410 Gone
+// to obscured URLs whose resource was deleted, and with 404 Not Found to
+// those that never resolved.
+func TestWithTombstones_Handler(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	s := store.WithTombstones(obscurer.NewMemoryStore(), obscurer.NewMemoryStore())
+	deleted := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/this/is/the/way", func(w http.ResponseWriter, r *http.Request) {
+		if deleted {
+			http.NotFound(w, r)
+		}
+	})
+	handler := obscurer.NewHandler(obscurer.Default, s, mux)
+	original := mustParse("/this/is/the/way")
+	obscured := obscurer.Default.Obscure(original)
+	require.NoError(t, s.Put(ctx, obscured, original))
+	get := func(target string) int {
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, target, nil))
+		return response.Code
+	}
+
+	// action + assert.
+	assert.Equal(t, http.StatusOK, get(obscured.String()))
+	deleted = true
+	assert.Equal(t, http.StatusNotFound, get(obscured.String()))
+	assert.Equal(t, http.StatusGone, get(obscured.String()))
+	assert.Equal(t, http.StatusNotFound, get("/never/was"))
+}
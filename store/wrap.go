@@ -0,0 +1,59 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/otelmetric"
+	"github.com/freerware/obscurer/oteltrace"
+)
+
+// Decorator decorates a store with additional behavior, such as logging or
+// retrying its operations.
+type Decorator func(obscurer.Store) obscurer.Store
+
+// Wrap decorates the provided store with the provided decorators, the first
+// of which is the outermost. For example, a logging decorator preceding a
+// retrying decorator logs each operation once, regardless of how many times
+// it was attempted. Decorators expose the operations of obscurer.Store
+// only, so optional capabilities of the provided store, such as those of
+// an obscurer.ExpiringStore, are hidden by them.
+func Wrap(base obscurer.Store, decorators ...Decorator) obscurer.Store {
+	s := base
+	for i := len(decorators) - 1; i >= 0; i-- {
+		s = decorators[i](s)
+	}
+	return s
+}
+
+// Tracing provides a decorator recording spans with the provided tracer for
+// the operations of the decorated store, as oteltrace.NewStore does.
+func Tracing(tracer oteltrace.Tracer, opts ...oteltrace.Option) Decorator {
+	return func(s obscurer.Store) obscurer.Store {
+		return oteltrace.NewStore(s, tracer, opts...)
+	}
+}
+
+// Metrics provides a decorator recording metrics with the provided meter
+// for the operations of the decorated store, as otelmetric.NewStore does.
+// The instruments are created once, and shared by every decorated store.
+func Metrics(meter otelmetric.Meter, opts ...otelmetric.Option) (Decorator, error) {
+	decorate, err := otelmetric.NewDecorator(meter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return decorate, nil
+}
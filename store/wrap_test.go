@@ -0,0 +1,156 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/internal/mock"
+	"github.com/freerware/obscurer/store"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// named decorates stores by recording the provided name upon each lookup.
+func named(name string, calls *[]string) store.Decorator {
+	return func(s obscurer.Store) obscurer.Store {
+		return &recorder{Store: s, name: name, calls: calls}
+	}
+}
+
+// recorder records its name upon each lookup.
+type recorder struct {
+	obscurer.Store
+	name  string
+	calls *[]string
+}
+
+func (r *recorder) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	*r.calls = append(*r.calls, r.name)
+	return r.Store.Get(ctx, obscured)
+}
+
+// TestWrap tests that decorators are applied with the first outermost.
+func TestWrap(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	base := mock.NewStore(ctrl)
+	base.EXPECT().Get(ctx, gomock.Any()).Return(nil, false, nil)
+	var calls []string
+	s := store.Wrap(base, named("outer", &calls), named("inner", &calls))
+
+	// action.
+	_, _, err := s.Get(ctx, mustParse("/abc"))
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, []string{"outer", "inner"}, calls)
+}
+
+// TestWrap_None tests that the store is provided as is without decorators.
+func TestWrap_None(t *testing.T) {
+	// arrange.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	base := mock.NewStore(ctrl)
+
+	// action + assert.
+	assert.Equal(t, obscurer.Store(base), store.Wrap(base))
+}
+
+// pinging is a store whose backend is reachable unless err is set.
+type pinging struct {
+	obscurer.Store
+	err error
+}
+
+func (s *pinging) Ping(ctx context.Context) error {
+	return s.err
+}
+
+// TestDecorators_Ping tests that decorators forward pings to the store they
+// decorate, and that stores without a backend to verify are reachable.
+func TestDecorators_Ping(t *testing.T) {
+	ctx := context.Background()
+	resolver, err := obscurer.NewRouteObscurer(obscurer.Default, "/users/{id}")
+	require.NoError(t, err)
+	decorators := map[string]func(obscurer.Store) obscurer.Store{
+		"BlueGreen": func(s obscurer.Store) obscurer.Store {
+			bg, err := store.NewBlueGreen(ctx, s)
+			require.NoError(t, err)
+			return bg
+		},
+		"FanOut": func(s obscurer.Store) obscurer.Store {
+			return store.NewFanOut(obscurer.NewMemoryStore(), s)
+		},
+		"Logging": func(s obscurer.Store) obscurer.Store {
+			return store.NewLogging(s, log.New(ioutil.Discard, "", 0))
+		},
+		"Namespace": func(s obscurer.Store) obscurer.Store {
+			return store.WithNamespace(s, "tenant")
+		},
+		"Outbox": func(s obscurer.Store) obscurer.Store {
+			o, err := store.NewOutbox(s, obscurer.NewMemoryStore(), store.WithRelayInterval(0))
+			require.NoError(t, err)
+			return o
+		},
+		"Quota": func(s obscurer.Store) obscurer.Store {
+			return store.NewQuota(s)
+		},
+		"ReadThrough": func(s obscurer.Store) obscurer.Store {
+			return store.NewReadThrough(s, resolver)
+		},
+		"Retrying": func(s obscurer.Store) obscurer.Store {
+			return store.NewRetrying(s)
+		},
+		"Tiered": func(s obscurer.Store) obscurer.Store {
+			return store.NewTiered(s)
+		},
+		"Tombstones": func(s obscurer.Store) obscurer.Store {
+			return store.WithTombstones(s, obscurer.NewMemoryStore())
+		},
+		"WriteBehind": func(s obscurer.Store) obscurer.Store {
+			wb := store.NewWriteBehind(s)
+			t.Cleanup(func() { wb.Close() })
+			return wb
+		},
+	}
+	for name, decorate := range decorators {
+		t.Run(name, func(t *testing.T) {
+			// arrange.
+			unreachable := decorate(&pinging{Store: obscurer.NewMemoryStore(), err: errors.New("whoa")})
+			reachable := decorate(&pinging{Store: obscurer.NewMemoryStore()})
+			local := decorate(obscurer.NewMemoryStore())
+
+			// action.
+			err := obscurer.Ping(ctx, unreachable)
+
+			// assert.
+			assert.EqualError(t, err, "whoa")
+			assert.NoError(t, obscurer.Ping(ctx, reachable))
+			assert.NoError(t, obscurer.Ping(ctx, local))
+		})
+	}
+}
@@ -0,0 +1,283 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/freerware/obscurer"
+)
+
+// DefaultWriteBehindBuffer represents the default number of mappings the
+// write-behind store queues before placing mappings synchronously.
+const DefaultWriteBehindBuffer = 1024
+
+// WriteBehindOptions represents the configuration options for the
+// write-behind store.
+type WriteBehindOptions struct {
+	// Buffer is the number of mappings queued to be placed. Once full,
+	// mappings are placed synchronously until the queue drains.
+	Buffer int
+	// ErrorHandler is invoked when a queued mapping fails to be placed.
+	// When nil, such failures are ignored.
+	ErrorHandler func(error)
+}
+
+// WriteBehindOption applies an option to the provided configuration.
+type WriteBehindOption func(*WriteBehindOptions)
+
+// WithBuffer configures the number of mappings queued to be placed.
+func WithBuffer(size int) WriteBehindOption {
+	return func(o *WriteBehindOptions) {
+		o.Buffer = size
+	}
+}
+
+// WithWriteErrorHandler configures the function invoked when a queued
+// mapping fails to be placed.
+func WithWriteErrorHandler(fn func(error)) WriteBehindOption {
+	return func(o *WriteBehindOptions) {
+		o.ErrorHandler = fn
+	}
+}
+
+// queuedPut represents a mapping queued to be placed.
+type queuedPut struct {
+	ctx      context.Context
+	obscured *url.URL
+	original *url.URL
+}
+
+// WriteBehind queues the mappings placed into it, and places them into an
+// underlying store in the background, so that the latency of the underlying
+// store is kept off the response path.
+type WriteBehind struct {
+	base    obscurer.Store
+	options WriteBehindOptions
+	queue   chan *queuedPut
+
+	// mutex guards pending, which holds the queued mappings keyed by their
+	// obscured path, and is signaled through drained as they are placed.
+	mutex   sync.Mutex
+	drained *sync.Cond
+	pending map[string]*queuedPut
+	// writeMutex serializes writes to the underlying store, so that
+	// mappings removed while queued aren't placed afterwards.
+	writeMutex sync.Mutex
+	closeMutex sync.RWMutex
+	closed     bool
+	done       chan struct{}
+}
+
+// NewWriteBehind constructs a store queuing mappings to be placed into the
+// provided store in the background. Lookups consult the queued mappings
+// before the provided store, so queued mappings resolve immediately within
+// the process. Collisions with the queued mappings are returned by Put,
+// while collisions with the mappings of the provided store are only
+// detected once placed, upon which the *obscurer.CollisionError is provided
+// to the error handler. Queued mappings are placed with the values of the
+// context provided to Put, but without its cancellation.
+func NewWriteBehind(base obscurer.Store, opts ...WriteBehindOption) *WriteBehind {
+	options := WriteBehindOptions{Buffer: DefaultWriteBehindBuffer}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	s := &WriteBehind{
+		base:    base,
+		options: options,
+		queue:   make(chan *queuedPut, options.Buffer),
+		pending: make(map[string]*queuedPut),
+		done:    make(chan struct{}),
+	}
+	s.drained = sync.NewCond(&s.mutex)
+	go s.run()
+	return s
+}
+
+// run places the queued mappings until the store is closed.
+func (s *WriteBehind) run() {
+	defer close(s.done)
+	for put := range s.queue {
+		s.place(put)
+	}
+}
+
+// place places the provided queued mapping into the underlying store,
+// unless it was removed while queued.
+func (s *WriteBehind) place(put *queuedPut) {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+	s.mutex.Lock()
+	queued := s.pending[put.obscured.Path] == put
+	s.mutex.Unlock()
+	if !queued {
+		return
+	}
+	err := s.base.Put(put.ctx, put.obscured, put.original)
+	s.mutex.Lock()
+	if s.pending[put.obscured.Path] == put {
+		delete(s.pending, put.obscured.Path)
+	}
+	s.drained.Broadcast()
+	s.mutex.Unlock()
+	if err != nil && s.options.ErrorHandler != nil {
+		s.options.ErrorHandler(err)
+	}
+}
+
+// Put queues the mapping between the provided obscured URL and it's
+// original form to be placed into the underlying store. The mapping is
+// placed synchronously when the queue is full or the store is closed.
+func (s *WriteBehind) Put(ctx context.Context, obscured, original *url.URL) error {
+	s.closeMutex.RLock()
+	defer s.closeMutex.RUnlock()
+	if s.closed {
+		return s.base.Put(ctx, obscured, original)
+	}
+	s.mutex.Lock()
+	if existing, ok := s.pending[obscured.Path]; ok {
+		s.mutex.Unlock()
+		if existing.original.Path != original.Path {
+			return &obscurer.CollisionError{
+				Obscured: obscured,
+				Existing: existing.original,
+				Original: original,
+			}
+		}
+		return nil
+	}
+	put := &queuedPut{ctx: detached{ctx}, obscured: obscured, original: original}
+	s.pending[obscured.Path] = put
+	s.mutex.Unlock()
+	select {
+	case s.queue <- put:
+		return nil
+	default:
+		s.mutex.Lock()
+		delete(s.pending, obscured.Path)
+		s.drained.Broadcast()
+		s.mutex.Unlock()
+		return s.base.Put(ctx, obscured, original)
+	}
+}
+
+// Get retrieves the original form of the provided obscured URL from the
+// queued mappings, or the underlying store.
+func (s *WriteBehind) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	s.mutex.Lock()
+	put, ok := s.pending[obscured.Path]
+	s.mutex.Unlock()
+	if ok {
+		return put.original, true, nil
+	}
+	return s.base.Get(ctx, obscured)
+}
+
+// Remove removes the mapping for the provided obscured URL from the queued
+// mappings and the underlying store.
+func (s *WriteBehind) Remove(ctx context.Context, obscured *url.URL) error {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+	s.mutex.Lock()
+	delete(s.pending, obscured.Path)
+	s.drained.Broadcast()
+	s.mutex.Unlock()
+	return s.base.Remove(ctx, obscured)
+}
+
+// Clear removes every mapping from the queued mappings and the underlying
+// store.
+func (s *WriteBehind) Clear(ctx context.Context) error {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+	s.mutex.Lock()
+	s.pending = make(map[string]*queuedPut)
+	s.drained.Broadcast()
+	s.mutex.Unlock()
+	return s.base.Clear(ctx)
+}
+
+// Size computes the size of the underlying store, along with the queued
+// mappings. Queued mappings already within the underlying store are
+// counted twice.
+func (s *WriteBehind) Size(ctx context.Context) int {
+	s.mutex.Lock()
+	queued := len(s.pending)
+	s.mutex.Unlock()
+	return s.base.Size(ctx) + queued
+}
+
+// Load loads the provided mappings directly into the underlying store.
+func (s *WriteBehind) Load(ctx context.Context, mappings obscurer.Mappings) error {
+	return s.base.Load(ctx, mappings)
+}
+
+// Ping verifies the backend of the underlying store is reachable.
+func (s *WriteBehind) Ping(ctx context.Context) error {
+	return obscurer.Ping(ctx, s.base)
+}
+
+// Flush waits until every mapping queued has been placed into the
+// underlying store, or the provided context is done.
+func (s *WriteBehind) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	go func() {
+		defer close(flushed)
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		for len(s.pending) > 0 && ctx.Err() == nil {
+			s.drained.Wait()
+		}
+	}()
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		// wake the waiter so that it observes the context being done.
+		s.mutex.Lock()
+		s.drained.Broadcast()
+		s.mutex.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Close stops queuing mappings, and waits until every mapping queued has
+// been placed into the underlying store. Mappings placed afterwards are
+// placed synchronously.
+func (s *WriteBehind) Close() error {
+	s.closeMutex.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.closeMutex.Unlock()
+	<-s.done
+	return nil
+}
+
+// detached is a context carrying the values of its parent without its
+// deadline or cancellation.
+type detached struct {
+	parent context.Context
+}
+
+func (detached) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detached) Done() <-chan struct{}               { return nil }
+func (detached) Err() error                          { return nil }
+func (c detached) Value(key interface{}) interface{} { return c.parent.Value(key) }
@@ -0,0 +1,182 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gatedStore is a store whose placements wait until the gate is opened.
+type gatedStore struct {
+	obscurer.Store
+	gate chan struct{}
+}
+
+func newGatedStore(t *testing.T) *gatedStore {
+	t.Cleanup(func() {
+		obscurer.DefaultStore.Clear(context.Background())
+	})
+	return &gatedStore{Store: obscurer.DefaultStore, gate: make(chan struct{})}
+}
+
+func (s *gatedStore) Put(ctx context.Context, obscured, original *url.URL) error {
+	<-s.gate
+	return s.Store.Put(ctx, obscured, original)
+}
+
+// TestWriteBehind_Put tests that mappings are placed in the background, and
+// resolve while queued.
+func TestWriteBehind_Put(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	base := newGatedStore(t)
+	s := store.NewWriteBehind(base)
+	defer s.Close()
+	obscured, original := mustParse("/abc"), mustParse("/this/is/the/way")
+
+	// action.
+	err := s.Put(ctx, obscured, original)
+
+	// assert.
+	require.NoError(t, err)
+	got, ok, err := s.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, original, got)
+	assert.Equal(t, 0, base.Size(ctx))
+	close(base.gate)
+	require.NoError(t, s.Flush(ctx))
+	assert.Equal(t, 1, base.Size(ctx))
+	assert.Equal(t, 1, s.Size(ctx))
+}
+
+// TestWriteBehind_Put_Collision tests that collisions with queued mappings
+// are returned, and collisions with the underlying store are handled.
+func TestWriteBehind_Put_Collision(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	base := newGatedStore(t)
+	require.NoError(t, base.Store.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way")))
+	errs := make(chan error, 1)
+	s := store.NewWriteBehind(base, store.WithWriteErrorHandler(func(err error) {
+		errs <- err
+	}))
+	defer s.Close()
+
+	// action.
+	err := s.Put(ctx, mustParse("/abc"), mustParse("/hey/der"))
+	collision := s.Put(ctx, mustParse("/abc"), mustParse("/whoa"))
+	close(base.gate)
+
+	// assert.
+	require.NoError(t, err)
+	assert.True(t, errors.Is(collision, obscurer.ErrCollision))
+	select {
+	case err := <-errs:
+		assert.True(t, errors.Is(err, obscurer.ErrCollision))
+	case <-time.After(time.Second):
+		t.Fatal("expected the collision to be handled")
+	}
+}
+
+// TestWriteBehind_Put_Full tests that mappings are placed synchronously once
+// the queue is full.
+func TestWriteBehind_Put_Full(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	base := newGatedStore(t)
+	close(base.gate)
+	s := store.NewWriteBehind(base, store.WithBuffer(0))
+	defer s.Close()
+
+	// action.
+	err := s.Put(ctx, mustParse("/abc"), mustParse("/this/is/the/way"))
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 1, base.Size(ctx))
+}
+
+// TestWriteBehind_Remove tests that mappings removed while queued are not
+// placed.
+func TestWriteBehind_Remove(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	base := newGatedStore(t)
+	s := store.NewWriteBehind(base)
+	obscured := mustParse("/abc")
+	require.NoError(t, s.Put(ctx, obscured, mustParse("/this/is/the/way")))
+
+	// action.
+	err := s.Remove(ctx, obscured)
+	close(base.gate)
+
+	// assert.
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+	_, ok, err := s.Get(ctx, obscured)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 0, base.Size(ctx))
+}
+
+// TestWriteBehind_Close tests that closing places the queued mappings, and
+// that mappings are placed synchronously afterwards.
+func TestWriteBehind_Close(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	base := newGatedStore(t)
+	s := store.NewWriteBehind(base)
+	require.NoError(t, s.Put(ctx, mustParse("/a"), mustParse("/this/is/the/way")))
+	close(base.gate)
+
+	// action.
+	err := s.Close()
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, 1, base.Size(ctx))
+	require.NoError(t, s.Put(ctx, mustParse("/b"), mustParse("/hey/der")))
+	assert.Equal(t, 2, base.Size(ctx))
+}
+
+// TestWriteBehind_Flush_ContextDone tests that flushing stops waiting once
+// the context is done.
+func TestWriteBehind_Flush_ContextDone(t *testing.T) {
+	// arrange.
+	base := newGatedStore(t)
+	s := store.NewWriteBehind(base)
+	require.NoError(t, s.Put(context.Background(), mustParse("/a"), mustParse("/this/is/the/way")))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// action.
+	err := s.Flush(ctx)
+
+	// assert.
+	assert.Equal(t, context.DeadlineExceeded, err)
+	close(base.gate)
+	require.NoError(t, s.Close())
+}
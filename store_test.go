@@ -0,0 +1,555 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStore_Put_SameOriginal tests that placing the same mapping into the
+// store more than once succeeds.
+func TestStore_Put_SameOriginal(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	store := obscurer.DefaultStore
+	original := mustParse("http://www.example.com/this/is/the/way")
+	obscured := obscurer.Default.Obscure(original)
+	require.NoError(t, store.Put(ctx, obscured, original))
+
+	// action + assert.
+	assert.NoError(t, store.Put(ctx, obscured, mustParse(original.String())))
+	assert.Equal(t, 1, store.Size(ctx))
+
+	// cleanup.
+	t.Cleanup(func() {
+		store.Clear(ctx)
+	})
+}
+
+// TestStore_Put_Collision tests that placing a mapping for an obscured URL
+// that already maps to a different URL results in a collision error.
+func TestStore_Put_Collision(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	store := obscurer.DefaultStore
+	original := mustParse("http://www.example.com/this/is/the/way")
+	other := mustParse("http://www.example.com/this/is/not/the/way")
+	obscured := obscurer.Default.Obscure(original)
+	require.NoError(t, store.Put(ctx, obscured, original))
+
+	// action.
+	err := store.Put(ctx, obscured, other)
+
+	// assert.
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, obscurer.ErrCollision))
+	var collisionErr *obscurer.CollisionError
+	require.True(t, errors.As(err, &collisionErr))
+	assert.Equal(t, original.String(), collisionErr.Existing.String())
+	assert.Equal(t, other.String(), collisionErr.Original.String())
+	got, ok, err := store.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, original.String(), got.String(), "expected the existing mapping to be kept")
+
+	// cleanup.
+	t.Cleanup(func() {
+		store.Clear(ctx)
+	})
+}
+
+// TestStore_PutWithTTL tests that mappings placed with a TTL are treated as
+// missing once expired, and can then be replaced.
+func TestStore_PutWithTTL(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	store := obscurer.DefaultStore
+	original := mustParse("http://www.example.com/this/is/the/way")
+	other := mustParse("http://www.example.com/this/is/not/the/way")
+	obscured := obscurer.Default.Obscure(original)
+	require.NoError(t, store.PutWithTTL(ctx, obscured, original, 20*time.Millisecond))
+	got, ok, err := store.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, original.String(), got.String())
+
+	// action.
+	time.Sleep(30 * time.Millisecond)
+
+	// assert.
+	_, ok, err = store.Get(ctx, obscured)
+	require.NoError(t, err)
+	assert.False(t, ok, "expected the mapping to have expired")
+	assert.Equal(t, 0, store.Size(ctx))
+	require.NoError(t, store.Put(ctx, obscured, other))
+	got, ok, err = store.Get(ctx, obscured)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, other.String(), got.String())
+
+	// cleanup.
+	t.Cleanup(func() {
+		store.Clear(ctx)
+	})
+}
+
+// TestStore_Sweep tests that expired mappings are swept in the background,
+// that the sweep resumes once mappings are again held with an expiration,
+// and that it stops once the store is closed.
+func TestStore_Sweep(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	store := obscurer.NewMemoryStore(obscurer.WithSweepInterval(time.Millisecond))
+	var mu sync.Mutex
+	evicted := []string{}
+	store.(obscurer.EvictingStore).OnEvict(func(obscured, original *url.URL, reason obscurer.Reason) {
+		mu.Lock()
+		defer mu.Unlock()
+		evicted = append(evicted, obscured.String())
+	})
+	swept := func(n int) func() bool {
+		return func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(evicted) == n
+		}
+	}
+	expiring := store.(obscurer.ExpiringStore)
+
+	// action.
+	require.NoError(t, expiring.PutWithTTL(ctx, mustParse("/a"), mustParse("/this/is/the/way"), time.Millisecond))
+	require.Eventually(t, swept(1), time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, expiring.PutWithTTL(ctx, mustParse("/b"), mustParse("/hey/der"), time.Millisecond))
+	require.Eventually(t, swept(2), time.Second, time.Millisecond)
+	require.NoError(t, store.(io.Closer).Close())
+	require.NoError(t, expiring.PutWithTTL(ctx, mustParse("/c"), mustParse("/products/1"), time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+
+	// assert.
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"/a", "/b"}, evicted)
+	_, ok, err := store.Get(ctx, mustParse("/c"))
+	require.NoError(t, err)
+	assert.False(t, ok, "expected the mapping to have expired")
+}
+
+// TestStore_GetObscured tests that the obscured form of an original URL can
+// be retrieved until its mapping is removed.
+func TestStore_GetObscured(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	store := obscurer.DefaultStore
+	original := mustParse("http://www.example.com/this/is/the/way")
+	obscured := obscurer.Default.Obscure(original)
+	require.NoError(t, store.Put(ctx, obscured, original))
+
+	// action.
+	got, ok, err := store.GetObscured(ctx, original)
+
+	// assert.
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, obscured.String(), got.String())
+	require.NoError(t, store.Remove(ctx, obscured))
+	_, ok, err = store.GetObscured(ctx, original)
+	require.NoError(t, err)
+	assert.False(t, ok, "expected the removed mapping not to be found")
+
+	// cleanup.
+	t.Cleanup(func() {
+		store.Clear(ctx)
+	})
+}
+
+// TestStore_GetObscured_Replaced tests that original URLs whose mapping
+// expired and was replaced are no longer found.
+func TestStore_GetObscured_Replaced(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	store := obscurer.DefaultStore
+	original := mustParse("/this/is/the/way")
+	other := mustParse("/this/is/not/the/way")
+	obscured := obscurer.Default.Obscure(original)
+	require.NoError(t, store.PutWithTTL(ctx, obscured, original, 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, store.Put(ctx, obscured, other))
+
+	// action.
+	_, ok, err := store.GetObscured(ctx, original)
+
+	// assert.
+	require.NoError(t, err)
+	assert.False(t, ok)
+	got, ok, err := store.GetObscured(ctx, other)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, obscured.String(), got.String())
+
+	// cleanup.
+	t.Cleanup(func() {
+		store.Clear(ctx)
+	})
+}
+
+// TestStore_Range tests that every unexpired mapping is visited until the
+// function returns false.
+func TestStore_Range(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	store := obscurer.DefaultStore
+	require.NoError(t, obscurer.LoadAll(ctx, store, map[*url.URL]*url.URL{
+		mustParse("/a"): mustParse("/this/is/the/way"),
+		mustParse("/b"): mustParse("/hey/der"),
+	}))
+	require.NoError(t, store.PutWithTTL(ctx, mustParse("/c"), mustParse("/gone"), time.Nanosecond))
+	time.Sleep(time.Millisecond)
+	visited := make(map[string]string)
+
+	// action.
+	err := store.Range(ctx, func(obscured, original *url.URL) bool {
+		visited[obscured.Path] = original.String()
+		return true
+	})
+
+	// assert.
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"/a": "/this/is/the/way", "/b": "/hey/der"}, visited)
+	count := 0
+	require.NoError(t, store.Range(ctx, func(obscured, original *url.URL) bool {
+		count++
+		return false
+	}))
+	assert.Equal(t, 1, count, "expected ranging to stop")
+
+	// cleanup.
+	t.Cleanup(func() {
+		store.Clear(ctx)
+	})
+}
+
+// TestStore_Consume tests that mappings placed with a limited number of uses
+// resolve that number of times, and that those without a limit resolve
+// indefinitely.
+func TestStore_Consume(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	store := obscurer.DefaultStore
+	require.NoError(t, store.PutWithUses(ctx, mustParse("/a"), mustParse("/this/is/the/way"), 2))
+	require.NoError(t, store.Put(ctx, mustParse("/b"), mustParse("/hey/der")))
+
+	// action.
+	var consumed []bool
+	for i := 0; i < 3; i++ {
+		_, ok, err := store.Consume(ctx, mustParse("/a"))
+		require.NoError(t, err)
+		consumed = append(consumed, ok)
+	}
+
+	// assert.
+	assert.Equal(t, []bool{true, true, false}, consumed)
+	_, ok, err := store.Get(ctx, mustParse("/a"))
+	require.NoError(t, err)
+	assert.False(t, ok, "expected the exhausted mapping to be removed")
+	for i := 0; i < 3; i++ {
+		got, ok, err := store.Consume(ctx, mustParse("/b"))
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, "/hey/der", got.String())
+	}
+
+	// cleanup.
+	t.Cleanup(func() {
+		store.Clear(ctx)
+	})
+}
+
+// TestStore_Consume_Concurrent tests that concurrent requests can't both
+// consume the last use of a mapping.
+func TestStore_Consume_Concurrent(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	store := obscurer.DefaultStore
+	require.NoError(t, store.PutWithUses(ctx, mustParse("/a"), mustParse("/this/is/the/way"), 3))
+	var consumed int64
+	var wg sync.WaitGroup
+
+	// action.
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok, _ := store.Consume(ctx, mustParse("/a")); ok {
+				atomic.AddInt64(&consumed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// assert.
+	assert.Equal(t, int64(3), consumed)
+
+	// cleanup.
+	t.Cleanup(func() {
+		store.Clear(ctx)
+	})
+}
+
+// syncMapStore mirrors the memory store prior to sharding, backed by
+// sync.Maps with serialized writes, as the baseline of the benchmarks.
+type syncMapStore struct {
+	store   sync.Map
+	reverse sync.Map
+	mutex   sync.Mutex
+}
+
+func (s *syncMapStore) Put(ctx context.Context, obscured, original *url.URL) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, loaded := s.store.Load(obscured.Path); !loaded {
+		s.store.Store(obscured.Path, *original)
+		s.reverse.Store(original.Path, *obscured)
+	}
+	return nil
+}
+
+func (s *syncMapStore) Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error) {
+	value, ok := s.store.Load(obscured.Path)
+	if !ok {
+		return nil, false, nil
+	}
+	original := value.(url.URL)
+	return &original, true, nil
+}
+
+func (s *syncMapStore) Size(ctx context.Context) (size int) {
+	s.store.Range(func(key, value interface{}) bool {
+		size++
+		return true
+	})
+	return
+}
+
+// benchmarkStore represents the operations of the stores compared by the
+// benchmarks.
+type benchmarkStore interface {
+	Put(ctx context.Context, obscured, original *url.URL) error
+	Get(ctx context.Context, obscured *url.URL) (*url.URL, bool, error)
+	Size(ctx context.Context) int
+}
+
+// benchmarkStores provides the stores compared by the benchmarks, each
+// loaded with the provided number of mappings, along with their obscured
+// URLs.
+func benchmarkStores(b *testing.B, n int) (map[string]benchmarkStore, []*url.URL) {
+	ctx := context.Background()
+	stores := map[string]benchmarkStore{
+		"Sharded": obscurer.DefaultStore,
+		"SyncMap": &syncMapStore{},
+	}
+	urls := make([]*url.URL, n)
+	for i := range urls {
+		original := mustParse(fmt.Sprintf("/this/is/the/way/%d", i))
+		urls[i] = obscurer.Default.Obscure(original)
+		for _, s := range stores {
+			s.Put(ctx, urls[i], original)
+		}
+	}
+	b.Cleanup(func() {
+		obscurer.DefaultStore.Clear(ctx)
+	})
+	return stores, urls
+}
+
+func BenchmarkStore_Get_Parallel(b *testing.B) {
+	ctx := context.Background()
+	stores, urls := benchmarkStores(b, 10000)
+	for name, s := range stores {
+		s := s
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					s.Get(ctx, urls[i%len(urls)])
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkStore_Put_Parallel(b *testing.B) {
+	ctx := context.Background()
+	stores, _ := benchmarkStores(b, 0)
+	for name, s := range stores {
+		s := s
+		b.Run(name, func(b *testing.B) {
+			var worker int64
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				id := atomic.AddInt64(&worker, 1)
+				for i := 0; pb.Next(); i++ {
+					u := mustParse(fmt.Sprintf("/%d/%d", id, i%1000))
+					s.Put(ctx, u, u)
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkStore_Size(b *testing.B) {
+	ctx := context.Background()
+	stores, _ := benchmarkStores(b, 10000)
+	for name, s := range stores {
+		s := s
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s.Size(ctx)
+			}
+		})
+	}
+}
+
+// TestStore_Shard tests that vanity aliases and obscured tokens are located
+// within the shards of the store, consistently for the same obscured path.
+func TestStore_Shard(t *testing.T) {
+	// arrange.
+	s, ok := interface{}(obscurer.DefaultStore).(obscurer.ShardedStore)
+	require.True(t, ok)
+	token := obscurer.Default.Obscure(mustParse("/this/is/the/way"))
+	alias := mustParse("/the-way")
+
+	// action.
+	tokenShard, aliasShard := s.Shard(token), s.Shard(alias)
+
+	// assert.
+	for _, shard := range []int{tokenShard, aliasShard} {
+		assert.True(t, shard >= 0 && shard < s.Shards())
+	}
+	assert.Equal(t, tokenShard, s.Shard(&url.URL{Path: token.Path, RawQuery: "x=1"}))
+	assert.Equal(t, aliasShard, s.Shard(mustParse("/the-way")))
+}
+
+// TestStore_OnEvict tests that the application is notified of mappings
+// evicted once expired or exhausted, and not of mappings removed.
+func TestStore_OnEvict(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	store := obscurer.DefaultStore
+	var evicted []string
+	store.OnEvict(func(obscured, original *url.URL, reason obscurer.Reason) {
+		evicted = append(evicted, fmt.Sprintf("%s %s %s", obscured, original, reason))
+	})
+	t.Cleanup(func() {
+		store.OnEvict(nil)
+		store.Clear(ctx)
+	})
+	require.NoError(t, store.PutWithTTL(ctx, mustParse("/a"), mustParse("/this/is/the/way"), time.Millisecond))
+	require.NoError(t, store.PutWithUses(ctx, mustParse("/b"), mustParse("/hey/der"), 1))
+	require.NoError(t, store.Put(ctx, mustParse("/c"), mustParse("/whoa")))
+	time.Sleep(5 * time.Millisecond)
+
+	// action.
+	require.NoError(t, store.Put(ctx, mustParse("/a"), mustParse("/this/is/the/way")))
+	_, ok, err := store.Consume(ctx, mustParse("/b"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NoError(t, store.Remove(ctx, mustParse("/c")))
+
+	// assert.
+	assert.Equal(t, []string{
+		"/a /this/is/the/way expired",
+		"/b /hey/der exhausted",
+	}, evicted)
+}
+
+// TestStore_OnEvict_Uses tests that the application is notified of a
+// mapping with several uses only once its last use is consumed.
+func TestStore_OnEvict_Uses(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	store := obscurer.DefaultStore
+	evictions := 0
+	store.OnEvict(func(obscured, original *url.URL, reason obscurer.Reason) {
+		evictions++
+	})
+	t.Cleanup(func() {
+		store.OnEvict(nil)
+		store.Clear(ctx)
+	})
+	require.NoError(t, store.PutWithUses(ctx, mustParse("/a"), mustParse("/this/is/the/way"), 3))
+
+	// action.
+	var notified []int
+	for i := 0; i < 3; i++ {
+		_, ok, err := store.Consume(ctx, mustParse("/a"))
+		require.NoError(t, err)
+		require.True(t, ok)
+		notified = append(notified, evictions)
+	}
+
+	// assert.
+	assert.Equal(t, []int{0, 0, 1}, notified)
+}
+
+// TestNewMemoryStore_KeyRequestURI tests that obscured URLs differing only
+// by their query have mappings of their own when keyed by request URI.
+func TestNewMemoryStore_KeyRequestURI(t *testing.T) {
+	// arrange.
+	ctx := context.Background()
+	store := obscurer.NewMemoryStore(obscurer.WithKeyStrategy(obscurer.KeyRequestURI))
+	require.NoError(t, store.Put(ctx, mustParse("/abc?page=1"), mustParse("/users?page=1")))
+
+	// action.
+	err := store.Put(ctx, mustParse("/abc?page=2"), mustParse("/users?page=2"))
+
+	// assert.
+	require.NoError(t, err)
+	for _, page := range []string{"1", "2"} {
+		got, ok, err := store.Get(ctx, mustParse("/abc?page="+page))
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, "/users?page="+page, got.String())
+	}
+	_, ok, err := store.Get(ctx, mustParse("/abc"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	visited := make(map[string]string)
+	require.NoError(t, store.(obscurer.RangeStore).Range(ctx, func(obscured, original *url.URL) bool {
+		visited[obscured.String()] = original.String()
+		return true
+	}))
+	assert.Equal(t, map[string]string{
+		"/abc?page=1": "/users?page=1",
+		"/abc?page=2": "/users?page=2",
+	}, visited)
+}
@@ -0,0 +1,192 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrInvalidTemplate represents an error that occurs when parsing a
+// malformed route template.
+var ErrInvalidTemplate = errors.New("obscurer: invalid route template")
+
+// templateSegment represents a single segment of a route template, which is
+// either a literal or a parameter.
+type templateSegment struct {
+	value     string
+	parameter bool
+}
+
+// RouteTemplate represents a route template, such as
+// '/users/{id}/files/{fileID}', where segments enclosed in braces are
+// parameters and all other segments are literals.
+type RouteTemplate struct {
+	raw      string
+	segments []templateSegment
+}
+
+// ParseRouteTemplate parses the provided route template.
+func ParseRouteTemplate(template string) (RouteTemplate, error) {
+	if !strings.HasPrefix(template, "/") {
+		return RouteTemplate{}, fmt.Errorf("%w: %q must start with '/'", ErrInvalidTemplate, template)
+	}
+	parameters := map[string]bool{}
+	var segments []templateSegment
+	for _, segment := range strings.Split(strings.Trim(template, "/"), "/") {
+		if !strings.ContainsAny(segment, "{}") {
+			segments = append(segments, templateSegment{value: segment})
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(segment, "{"), "}")
+		if len(name) != len(segment)-2 || name == "" || strings.ContainsAny(name, "{}") {
+			return RouteTemplate{}, fmt.Errorf("%w: %q has malformed segment %q", ErrInvalidTemplate, template, segment)
+		}
+		if parameters[name] {
+			return RouteTemplate{}, fmt.Errorf("%w: %q has duplicate parameter %q", ErrInvalidTemplate, template, name)
+		}
+		parameters[name] = true
+		segments = append(segments, templateSegment{value: name, parameter: true})
+	}
+	return RouteTemplate{raw: template, segments: segments}, nil
+}
+
+// MustParseRouteTemplate parses the provided route template, and panics
+// when it is malformed.
+func MustParseRouteTemplate(template string) RouteTemplate {
+	t, err := ParseRouteTemplate(template)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// String provides the route template as it was parsed.
+func (t RouteTemplate) String() string {
+	return t.raw
+}
+
+// Parameters provides the names of the parameters in the route template, in
+// the order they appear.
+func (t RouteTemplate) Parameters() (names []string) {
+	for _, segment := range t.segments {
+		if segment.parameter {
+			names = append(names, segment.value)
+		}
+	}
+	return
+}
+
+// Match matches the provided path against the route template, providing
+// the values of the parameters when it matches.
+func (t RouteTemplate) Match(path string) (map[string]string, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) != len(t.segments) {
+		return nil, false
+	}
+	values := map[string]string{}
+	for i, segment := range t.segments {
+		switch {
+		case segment.parameter && parts[i] != "":
+			values[segment.value] = parts[i]
+		case segment.parameter || segment.value != parts[i]:
+			return nil, false
+		}
+	}
+	return values, true
+}
+
+// RouteSource represents a source of the route templates of a router, so
+// that subsystems needing the routes of an application don't depend on a
+// particular framework. See the routes package for adapters.
+type RouteSource interface {
+	// Routes provides the route templates of the router.
+	Routes(ctx context.Context) ([]RouteTemplate, error)
+}
+
+// RouteSourceFunc adapts a function to the RouteSource interface.
+type RouteSourceFunc func(ctx context.Context) ([]RouteTemplate, error)
+
+// Routes provides the route templates of the router.
+func (fn RouteSourceFunc) Routes(ctx context.Context) ([]RouteTemplate, error) {
+	return fn(ctx)
+}
+
+// templatedObscurer obscures only the parameter segments of paths that
+// match one of its route templates, leaving literal segments readable.
+type templatedObscurer struct {
+	base      Obscurer
+	templates []RouteTemplate
+}
+
+// NewTemplated constructs an obscurer that obscures only the parameter
+// segments of paths matching the provided route templates, such that
+// '/users/42/files/7' matching '/users/{id}/files/{fileID}' is obscured
+// to '/users/<obscured>/files/<obscured>'. Each parameter segment is
+// obscured with the provided obscurer, which also obscures the entire path
+// of URLs that match none of the route templates. Templates are matched in
+// the order provided.
+func NewTemplated(base Obscurer, templates ...string) (Obscurer, error) {
+	o := &templatedObscurer{base: base}
+	for _, template := range templates {
+		t, err := ParseRouteTemplate(template)
+		if err != nil {
+			return nil, err
+		}
+		o.templates = append(o.templates, t)
+	}
+	return o, nil
+}
+
+// Obscure obscures the provided URL.
+func (o *templatedObscurer) Obscure(url *url.URL) *url.URL {
+	return o.Reroll(url, 0)
+}
+
+// Reroll obscures the provided URL for the provided attempt.
+func (o *templatedObscurer) Reroll(u *url.URL, attempt int) *url.URL {
+	obscure := func(u *url.URL) *url.URL {
+		if reroller, ok := o.base.(Reroller); ok && attempt > 0 {
+			return reroller.Reroll(u, attempt)
+		}
+		return o.base.Obscure(u)
+	}
+	for _, t := range o.templates {
+		if _, ok := t.Match(u.Path); !ok {
+			continue
+		}
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		for i, segment := range t.segments {
+			if segment.parameter {
+				// the parameter name is included so that equal values of
+				// different parameters are obscured differently.
+				obscured := obscure(&url.URL{Path: "/" + segment.value + "/" + parts[i]})
+				parts[i] = strings.TrimPrefix(obscured.Path, "/")
+			}
+		}
+		result := *u
+		result.Path = "/" + strings.Join(parts, "/")
+		if strings.HasSuffix(u.Path, "/") && len(parts) > 0 {
+			result.Path = result.Path + "/"
+		}
+		result.RawPath = ""
+		return &result
+	}
+	return obscure(u)
+}
@@ -0,0 +1,133 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseRouteTemplate_Invalid tests that malformed route templates
+// result in an error.
+func TestParseRouteTemplate_Invalid(t *testing.T) {
+	for _, template := range []string{
+		"users/{id}",
+		"/users/{id",
+		"/users/id}",
+		"/users/{}",
+		"/users/{{id}}",
+		"/users/x{id}",
+		"/users/{id}/files/{id}",
+	} {
+		t.Run(template, func(t *testing.T) {
+			// action.
+			_, err := obscurer.ParseRouteTemplate(template)
+
+			// assert.
+			assert.True(t, errors.Is(err, obscurer.ErrInvalidTemplate))
+		})
+	}
+}
+
+// TestRouteTemplate_Match tests that paths are matched against route
+// templates.
+func TestRouteTemplate_Match(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		match bool
+		want  map[string]string
+	}{
+		{name: "Match", path: "/users/42/files/7", match: true, want: map[string]string{"id": "42", "fileID": "7"}},
+		{name: "TrailingSlash", path: "/users/42/files/7/", match: true, want: map[string]string{"id": "42", "fileID": "7"}},
+		{name: "LiteralMismatch", path: "/users/42/photos/7", match: false},
+		{name: "TooShort", path: "/users/42/files", match: false},
+		{name: "TooLong", path: "/users/42/files/7/versions", match: false},
+		{name: "EmptyParameter", path: "/users//files/7", match: false},
+	}
+	template := obscurer.MustParseRouteTemplate("/users/{id}/files/{fileID}")
+	assert.Equal(t, []string{"id", "fileID"}, template.Parameters())
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// action.
+			got, ok := template.Match(test.path)
+
+			// assert.
+			assert.Equal(t, test.match, ok)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+// TestTemplated_Obscure tests that only the parameter segments are obscured
+// for paths matching a route template.
+func TestTemplated_Obscure(t *testing.T) {
+	// arrange.
+	o, err := obscurer.NewTemplated(obscurer.Default, "/users/{id}", "/users/{id}/files/{fileID}")
+	require.NoError(t, err)
+	u := mustParse("http://www.example.com/users/42/files/42?download=true")
+
+	// action.
+	got := o.Obscure(u)
+
+	// assert.
+	parts := strings.Split(strings.Trim(got.Path, "/"), "/")
+	require.Len(t, parts, 4)
+	assert.Equal(t, "users", parts[0])
+	assert.Equal(t, "files", parts[2])
+	assert.Equal(t, strings.TrimPrefix(obscurer.Default.Obscure(&url.URL{Path: "/id/42"}).Path, "/"), parts[1])
+	assert.NotEqual(t, parts[1], parts[3], "expected equal values of different parameters to differ")
+	assert.Equal(t, u.RawQuery, got.RawQuery)
+	assert.Equal(t, u.Host, got.Host)
+	assert.Equal(t, got.String(), o.Obscure(u).String())
+}
+
+// TestTemplated_Obscure_NoMatch tests that the entire path is obscured for
+// paths matching no route template.
+func TestTemplated_Obscure_NoMatch(t *testing.T) {
+	// arrange.
+	o, err := obscurer.NewTemplated(obscurer.Default, "/users/{id}")
+	require.NoError(t, err)
+	u := mustParse("http://www.example.com/this/is/the/way")
+
+	// action.
+	got := o.Obscure(u)
+
+	// assert.
+	assert.Equal(t, obscurer.Default.Obscure(u).String(), got.String())
+}
+
+// TestTemplated_Reroll tests that rerolling obscures the parameter segments
+// differently.
+func TestTemplated_Reroll(t *testing.T) {
+	// arrange.
+	o, err := obscurer.NewTemplated(obscurer.Default, "/users/{id}")
+	require.NoError(t, err)
+	u := mustParse("/users/42")
+
+	// action.
+	got := o.(obscurer.Reroller).Reroll(u, 1)
+
+	// assert.
+	assert.True(t, strings.HasPrefix(got.Path, "/users/"))
+	assert.NotEqual(t, o.Obscure(u).Path, got.Path)
+}
@@ -0,0 +1,117 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+)
+
+// Flags represents the behaviors of the handler that can be toggled at
+// runtime. The zero value leaves the handler behaving as configured.
+type Flags struct {
+	// Bypass indicates whether requests are handled as is, without their
+	// URL being resolved, nor the URLs of their response obscured.
+	Bypass bool `json:"bypass"`
+	// FailOpen indicates whether requests whose URL fails to be looked up
+	// are handled with their URL as is, rather than failing, unless the
+	// handler is strict.
+	FailOpen bool `json:"fail_open"`
+	// Strict indicates whether requests whose URL isn't a known obscured
+	// URL are rejected, as when the handler is configured with WithStrict.
+	Strict bool `json:"strict"`
+	// DisableBodyRewriting indicates whether the identifiers and URLs
+	// within bodies are left as is, when the handler is configured with
+	// WithIDFields, WithLinkFields, WithLinkPatterns, WithHypermedia,
+	// WithHTMLRewriting, WithFeedRewriting, WithSitemapRewriting or
+	// WithRequestBodyResolution.
+	DisableBodyRewriting bool `json:"disable_body_rewriting"`
+}
+
+// Toggles holds the flags of the handler, which can be flipped at runtime
+// without restarting. Each request uses the snapshot of the flags taken
+// when it started.
+type Toggles struct {
+	flags atomic.Value
+}
+
+// NewToggles constructs toggles holding the provided flags.
+func NewToggles(flags Flags) *Toggles {
+	t := &Toggles{}
+	t.flags.Store(flags)
+	return t
+}
+
+// Load provides a snapshot of the flags.
+func (t *Toggles) Load() Flags {
+	flags, _ := t.flags.Load().(Flags)
+	return flags
+}
+
+// Store replaces the flags.
+func (t *Toggles) Store(flags Flags) {
+	t.flags.Store(flags)
+}
+
+// ServeHTTP serves the flags as JSON, allowing them to be retrieved with
+// GET, and replaced with PUT. The handler is intended to be mounted on an
+// administrative endpoint, behind the authorization of the application.
+func (t *Toggles) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPut:
+		var flags Flags
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&flags); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		t.Store(flags)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := json.Marshal(t.Load())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
+
+// WithToggles configures the handler to behave according to the flags held
+// by the provided toggles, which can be flipped at runtime.
+func WithToggles(t *Toggles) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.Toggles = t
+	}
+}
+
+// flags provides a snapshot of the flags of the handler.
+func (h *handler) flags() Flags {
+	if h.options.Toggles == nil {
+		return Flags{}
+	}
+	return h.options.Toggles.Load()
+}
@@ -0,0 +1,200 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/internal/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestToggles_ServeHTTP tests that the flags are retrieved and replaced
+// through the toggles endpoint.
+func TestToggles_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		status int
+		flags  obscurer.Flags
+	}{
+		{name: "Get", method: http.MethodGet, status: http.StatusOK, flags: obscurer.Flags{FailOpen: true}},
+		{name: "Put", method: http.MethodPut, body: `{"bypass":true}`, status: http.StatusOK, flags: obscurer.Flags{Bypass: true}},
+		{name: "MalformedPut", method: http.MethodPut, body: `{"verbose":true}`, status: http.StatusBadRequest, flags: obscurer.Flags{FailOpen: true}},
+		{name: "Delete", method: http.MethodDelete, status: http.StatusMethodNotAllowed, flags: obscurer.Flags{FailOpen: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// arrange.
+			assert := assert.New(t)
+			toggles := obscurer.NewToggles(obscurer.Flags{FailOpen: true})
+			request := httptest.NewRequest(test.method, "/admin/toggles", strings.NewReader(test.body))
+			recorder := httptest.NewRecorder()
+
+			// action.
+			toggles.ServeHTTP(recorder, request)
+
+			// assert.
+			assert.Equal(test.status, recorder.Code)
+			assert.Equal(test.flags, toggles.Load())
+			if test.status == http.StatusOK {
+				assert.Equal("application/json", recorder.Header().Get("Content-Type"))
+				assert.Contains(recorder.Body.String(), `"bypass":`+boolString(test.flags.Bypass))
+			}
+		})
+	}
+}
+
+func boolString(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+// TestHandler_Bypass tests that requests are handled as is when the handler
+// is bypassed.
+func TestHandler_Bypass(t *testing.T) {
+	// arrange.
+	assert := assert.New(t)
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	var path string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Header().Set("Location", "/this/is/the/way")
+	})
+	store := mock.NewStore(ctrl)
+	toggles := obscurer.NewToggles(obscurer.Flags{Bypass: true})
+	handler := obscurer.NewHandler(obscurer.Default, store, mux, obscurer.WithToggles(toggles))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// action.
+	response, err := http.Get(server.URL + "/not/the/way")
+
+	// assert.
+	require.NoError(err)
+	assert.Equal(http.StatusOK, response.StatusCode)
+	assert.Equal("/not/the/way", path)
+	assert.Equal("/this/is/the/way", response.Header.Get("Location"))
+}
+
+// TestHandler_FailOpen tests that requests whose URL fails to be looked up
+// are handled as is when the handler fails open.
+func TestHandler_FailOpen(t *testing.T) {
+	// arrange.
+	assert := assert.New(t)
+	require := require.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	handled := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/this/is/the/way", func(w http.ResponseWriter, r *http.Request) {
+		handled = true
+	})
+	store := mock.NewStore(ctrl)
+	toggles := obscurer.NewToggles(obscurer.Flags{FailOpen: true})
+	handler := obscurer.NewHandler(obscurer.Default, store, mux, obscurer.WithToggles(toggles))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	store.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, false, errors.New("whoa")).AnyTimes()
+
+	// action.
+	response, err := http.Get(server.URL + "/this/is/the/way")
+
+	// assert.
+	require.NoError(err)
+	assert.Equal(http.StatusOK, response.StatusCode)
+	assert.True(handled, "expected the request to be handled")
+}
+
+// TestHandler_StrictFlag tests that requests whose URL isn't a known
+// obscured URL are rejected once the handler is made strict at runtime.
+func TestHandler_StrictFlag(t *testing.T) {
+	// arrange.
+	assert := assert.New(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	handled := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/this/is/the/way", func(w http.ResponseWriter, r *http.Request) {
+		handled++
+	})
+	store := mock.NewStore(ctrl)
+	toggles := obscurer.NewToggles(obscurer.Flags{})
+	handler := obscurer.NewHandler(obscurer.Default, store, mux, obscurer.WithToggles(toggles))
+	store.EXPECT().Get(gomock.Any(), gomock.Any()).Return(nil, false, nil).AnyTimes()
+
+	// action.
+	lenient := httptest.NewRecorder()
+	handler.ServeHTTP(lenient, httptest.NewRequest(http.MethodGet, "/this/is/the/way", nil))
+	toggles.Store(obscurer.Flags{Strict: true})
+	strict := httptest.NewRecorder()
+	handler.ServeHTTP(strict, httptest.NewRequest(http.MethodGet, "/this/is/the/way", nil))
+
+	// assert.
+	assert.Equal(http.StatusOK, lenient.Code)
+	assert.Equal(http.StatusNotFound, strict.Code)
+	assert.Equal(obscurer.ErrNotObscured.Error()+"\n", strict.Body.String())
+	assert.Equal(1, handled)
+}
+
+// TestHandler_DisableBodyRewriting tests that identifiers within bodies are
+// left as is when body rewriting is disabled.
+func TestHandler_DisableBodyRewriting(t *testing.T) {
+	// arrange.
+	assert := assert.New(t)
+	require := require.New(t)
+	t.Cleanup(func() { obscurer.DefaultStore.Clear(context.Background()) })
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orders", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":42}`))
+	})
+	toggles := obscurer.NewToggles(obscurer.Flags{DisableBodyRewriting: true})
+	handler := obscurer.NewHandler(
+		obscurer.Default,
+		obscurer.DefaultStore,
+		mux,
+		obscurer.WithIDFields(prefixCodec{prefix: "x"}, "id"),
+		obscurer.WithToggles(toggles),
+	)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// action.
+	response, err := http.Get(server.URL + "/orders")
+
+	// assert.
+	require.NoError(err)
+	body, err := ioutil.ReadAll(response.Body)
+	require.NoError(err)
+	assert.Equal(`{"id":42}`, string(body))
+}
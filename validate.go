@@ -0,0 +1,162 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrNoStore represents an error that occurs when validating a
+	// configuration without a store.
+	ErrNoStore = errors.New("obscurer: no store configured")
+	// ErrNoObscurer represents an error that occurs when validating a
+	// configuration without an obscurer.
+	ErrNoObscurer = errors.New("obscurer: no obscurer configured")
+	// ErrTTLUnsupported represents an error that occurs when validating a
+	// configuration with a TTL whose store isn't an ExpiringStore.
+	ErrTTLUnsupported = errors.New("obscurer: TTL configured, but the store isn't an ExpiringStore")
+	// ErrUsesUnsupported represents an error that occurs when validating a
+	// configuration with uses whose store isn't a ConsumingStore.
+	ErrUsesUnsupported = errors.New("obscurer: uses configured, but the store isn't a ConsumingStore")
+	// ErrInvalidOption represents an error that occurs when validating a
+	// configuration with an option whose value is invalid.
+	ErrInvalidOption = errors.New("obscurer: invalid option")
+)
+
+// ValidationError describes the problems found validating a configuration.
+// ValidationError matches each of its problems when using errors.Is.
+type ValidationError struct {
+	// Problems are the problems found, in the order they were found.
+	Problems []error
+}
+
+// Error describes the problems.
+func (e *ValidationError) Error() string {
+	problems := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		problems[i] = p.Error()
+	}
+	return "obscurer: invalid configuration: " + strings.Join(problems, "; ")
+}
+
+// Is indicates whether any of the problems is the provided error.
+func (e *ValidationError) Is(err error) bool {
+	for _, p := range e.Problems {
+		if errors.Is(p, err) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the configuration of a handler constructed with the
+// provided obscurer, store, and options, as NewHandler would construct it,
+// for option combinations that would silently misbehave at request time,
+// such as a TTL whose store can't expire mappings. It is intended to be
+// called at startup, and returns a *ValidationError describing every
+// problem found, if any.
+func Validate(o Obscurer, s Store, opts ...HandlerOption) error {
+	var options HandlerOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Obscurer != nil {
+		o = options.Obscurer
+	}
+	if options.Store != nil {
+		s = options.Store
+	}
+	var problems []error
+	add := func(err error) {
+		problems = append(problems, err)
+	}
+	if o == nil {
+		add(ErrNoObscurer)
+	}
+	if s == nil {
+		add(ErrNoStore)
+	}
+	if options.TTL < 0 {
+		add(fmt.Errorf("%w: TTL must not be negative", ErrInvalidOption))
+	}
+	if options.Uses < 0 {
+		add(fmt.Errorf("%w: uses must not be negative", ErrInvalidOption))
+	}
+	if options.Limits.MaxPathLength < 0 || options.Limits.MaxTokenLength < 0 {
+		add(fmt.Errorf("%w: length limits must not be negative", ErrInvalidOption))
+	}
+	for _, header := range options.Headers {
+		if !knownHeader(header) {
+			add(fmt.Errorf("%w: header %q isn't obscured", ErrInvalidOption, header))
+		}
+	}
+	if options.Sampling.Percent < 0 || options.Sampling.Percent > 100 {
+		add(fmt.Errorf("%w: sampling percentage must be between 0 and 100", ErrInvalidOption))
+	}
+	if options.MaxBufferedBody < 0 {
+		add(fmt.Errorf("%w: maximum buffered body must not be negative", ErrInvalidOption))
+	}
+	if options.MaxRequestBody < 0 {
+		add(fmt.Errorf("%w: maximum request body must not be negative", ErrInvalidOption))
+	}
+	if options.Removal.Threshold < 0 {
+		add(fmt.Errorf("%w: removal threshold must not be negative", ErrInvalidOption))
+	}
+	for _, class := range options.LocationStatuses {
+		if class < StatusInformational || class > StatusServerError {
+			add(fmt.Errorf("%w: unknown status class %d", ErrInvalidOption, class))
+		}
+	}
+	patterns := append([]PathPattern{}, options.Exclusions...)
+	patterns = append(patterns, options.Inclusions...)
+	patterns = append(patterns, options.ObscuredPaths...)
+	for _, pattern := range append(patterns, options.LinkPatterns...) {
+		if glob, ok := pattern.(globPattern); ok && !glob.valid() {
+			add(fmt.Errorf("%w: malformed glob %q", ErrInvalidOption, string(glob)))
+		}
+	}
+	if _, ok := s.(ExpiringStore); s != nil && !ok && options.TTL > 0 {
+		add(ErrTTLUnsupported)
+	}
+	if _, ok := s.(ConsumingStore); s != nil && !ok && options.Uses > 0 {
+		add(ErrUsesUnsupported)
+	}
+	if options.IDCodec == nil && len(options.IDFields) > 0 {
+		add(fmt.Errorf("%w: ID fields configured without an ID codec", ErrInvalidOption))
+	}
+	if options.IDCodec != nil && len(options.IDFields) == 0 {
+		add(fmt.Errorf("%w: ID codec configured without ID fields", ErrInvalidOption))
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: problems}
+}
+
+// knownHeader indicates whether the header with the provided key is among
+// the headers the handler obscures.
+func knownHeader(key string) bool {
+	for _, header := range obscuredHeaders {
+		if http.CanonicalHeaderKey(key) == header.key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/freerware/obscurer"
+	"github.com/freerware/obscurer/internal/mock"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidate tests that valid configurations pass validation.
+func TestValidate(t *testing.T) {
+	// arrange.
+	opts := []obscurer.HandlerOption{
+		obscurer.WithTTL(time.Hour),
+		obscurer.WithUses(1),
+	}
+
+	// action.
+	err := obscurer.Validate(obscurer.Default, obscurer.DefaultStore, opts...)
+
+	// assert.
+	assert.NoError(t, err)
+}
+
+// TestValidate_Problems tests that every problem of a configuration is
+// reported.
+func TestValidate_Problems(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	tests := []struct {
+		name     string
+		obscurer obscurer.Obscurer
+		store    obscurer.Store
+		opts     []obscurer.HandlerOption
+		expected []error
+	}{
+		{
+			name:     "Missing",
+			expected: []error{obscurer.ErrNoObscurer, obscurer.ErrNoStore},
+		},
+		{
+			name:     "Unsupported",
+			obscurer: obscurer.Default,
+			store:    mock.NewStore(ctrl),
+			opts:     []obscurer.HandlerOption{obscurer.WithTTL(time.Hour), obscurer.WithUses(1)},
+			expected: []error{obscurer.ErrTTLUnsupported, obscurer.ErrUsesUnsupported},
+		},
+		{
+			name:     "Invalid",
+			obscurer: obscurer.Default,
+			store:    obscurer.DefaultStore,
+			opts: []obscurer.HandlerOption{
+				obscurer.WithTTL(-time.Hour),
+				obscurer.WithIDFields(nil, "id"),
+				obscurer.WithLimits(obscurer.Limits{MaxPathLength: -1}),
+				obscurer.WithLocationStatuses(obscurer.StatusClass(6)),
+				obscurer.WithExclusions(obscurer.Glob("/static/[a-")),
+				obscurer.WithRemovalThreshold(-1),
+				obscurer.WithHeaders("Refresh"),
+				obscurer.WithBodySampling(101),
+				obscurer.WithMaxBufferedBody(-1),
+				obscurer.WithMaxRequestBody(-1),
+			},
+			expected: []error{
+				obscurer.ErrInvalidOption,
+				obscurer.ErrInvalidOption,
+				obscurer.ErrInvalidOption,
+				obscurer.ErrInvalidOption,
+				obscurer.ErrInvalidOption,
+				obscurer.ErrInvalidOption,
+				obscurer.ErrInvalidOption,
+				obscurer.ErrInvalidOption,
+				obscurer.ErrInvalidOption,
+				obscurer.ErrInvalidOption,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// action.
+			err := obscurer.Validate(test.obscurer, test.store, test.opts...)
+
+			// assert.
+			var validation *obscurer.ValidationError
+			require.True(t, errors.As(err, &validation))
+			require.Len(t, validation.Problems, len(test.expected))
+			for i, expected := range test.expected {
+				assert.True(t, errors.Is(validation.Problems[i], expected), "expected %q to be %q", validation.Problems[i], expected)
+				assert.True(t, errors.Is(err, expected))
+			}
+		})
+	}
+}
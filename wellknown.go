@@ -0,0 +1,85 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// WellKnownPath represents the path the handler serves the document
+// describing the deployment at, when configured with WithWellKnown.
+const WellKnownPath = "/.well-known/obscurer"
+
+// FormatVersion represents the version of the format of obscured URLs, as
+// reported by the well-known document.
+const FormatVersion = 1
+
+// WellKnown represents the document describing a deployment, which internal
+// tooling can discover how to interact with the deployment from.
+type WellKnown struct {
+	// Version is the version of the format of obscured URLs. When zero,
+	// FormatVersion is reported.
+	Version int `json:"version"`
+	// Obscurer is the name of the obscurer URLs are obscured with.
+	Obscurer string `json:"obscurer,omitempty"`
+	// TokenLength is the length of the tokens obscured URLs consist of.
+	// When zero, the length of the path of an obscured URL, without its
+	// leading slash, is reported.
+	TokenLength int `json:"token_length,omitempty"`
+	// Endpoints are the URLs of the endpoints supported by the deployment,
+	// such as admin or batch endpoints, keyed by their purpose.
+	Endpoints map[string]string `json:"endpoints,omitempty"`
+}
+
+// WithWellKnown configures the handler to serve the provided document at
+// WellKnownPath, such that internal tooling can discover how to interact
+// with the deployment. Requests for the document aren't resolved, nor
+// handled by the wrapped handler.
+func WithWellKnown(doc WellKnown) HandlerOption {
+	return func(o *HandlerOptions) {
+		o.WellKnown = &doc
+	}
+}
+
+// wellKnown serves the well-known document of the deployment, completing
+// it with the version and token length of the provided obscurer.
+func wellKnown(w http.ResponseWriter, r *http.Request, doc WellKnown, o Obscurer) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if doc.Version == 0 {
+		doc.Version = FormatVersion
+	}
+	if doc.TokenLength == 0 {
+		doc.TokenLength = len(strings.TrimPrefix(o.Obscure(&url.URL{Path: "/"}).Path, "/"))
+	}
+	body, err := json.Marshal(doc)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "max-age=3600")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		w.Write(body)
+	}
+}
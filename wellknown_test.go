@@ -0,0 +1,92 @@
+/* Copyright 2021 Freerware
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package obscurer_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/freerware/obscurer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHandler_WellKnown tests that the well-known document is served,
+// completed with the version and token length.
+func TestHandler_WellKnown(t *testing.T) {
+	// arrange.
+	handled := false
+	handler := obscurer.NewHandler(obscurer.NewMD5(), obscurer.DefaultStore,
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handled = true
+		}),
+		obscurer.WithWellKnown(obscurer.WellKnown{
+			Obscurer:  "md5",
+			Endpoints: map[string]string{"batch": "/admin/batch"},
+		}))
+	response := httptest.NewRecorder()
+
+	// action.
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, obscurer.WellKnownPath, nil))
+
+	// assert.
+	assert.False(t, handled)
+	require.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "application/json", response.Header().Get("Content-Type"))
+	var doc obscurer.WellKnown
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &doc))
+	assert.Equal(t, obscurer.WellKnown{
+		Version:     obscurer.FormatVersion,
+		Obscurer:    "md5",
+		TokenLength: 32,
+		Endpoints:   map[string]string{"batch": "/admin/batch"},
+	}, doc)
+}
+
+// TestHandler_WellKnown_Method tests that the well-known document can only
+// be retrieved.
+func TestHandler_WellKnown_Method(t *testing.T) {
+	// arrange.
+	handler := obscurer.NewHandler(obscurer.Default, obscurer.DefaultStore, http.NotFoundHandler(),
+		obscurer.WithWellKnown(obscurer.WellKnown{}))
+	response := httptest.NewRecorder()
+
+	// action.
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodPost, obscurer.WellKnownPath, nil))
+
+	// assert.
+	assert.Equal(t, http.StatusMethodNotAllowed, response.Code)
+	assert.Equal(t, "GET, HEAD", response.Header().Get("Allow"))
+}
+
+// TestHandler_WellKnown_Disabled tests that requests for the well-known
+// document are handled as any other unless it is configured.
+func TestHandler_WellKnown_Disabled(t *testing.T) {
+	// arrange.
+	handled := false
+	handler := obscurer.NewHandler(obscurer.Default, obscurer.DefaultStore,
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handled = true
+		}))
+
+	// action.
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, obscurer.WellKnownPath, nil))
+
+	// assert.
+	assert.True(t, handled)
+}